@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/ortuman/jackal/log"
+)
+
+var listenerProvider = net.Listen
+
+type server struct {
+	cfg     *Config
+	httpSrv *http.Server
+}
+
+var (
+	instMu      sync.RWMutex
+	srv         *server
+	initialized bool
+)
+
+// Initialize initializes the administrative API server.
+func Initialize(cfg *Config) {
+	instMu.Lock()
+	defer instMu.Unlock()
+	if initialized {
+		return
+	}
+	if cfg == nil {
+		return // admin API disabled
+	}
+	srv = &server{
+		cfg:     cfg,
+		httpSrv: &http.Server{Handler: newHandler(cfg.Token)},
+	}
+	go srv.start()
+	initialized = true
+}
+
+// Shutdown shuts down administrative API server.
+func Shutdown() {
+	instMu.Lock()
+	defer instMu.Unlock()
+	if initialized {
+		srv.shutdown()
+		srv = nil
+		initialized = false
+	}
+}
+
+func (s *server) start() {
+	address := s.cfg.BindAddress + ":" + strconv.Itoa(s.cfg.Port)
+	log.Infof("admin: listening at %s", address)
+
+	ln, err := listenerProvider("tcp", address)
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if err := s.httpSrv.Serve(ln); err != http.ErrServerClosed {
+		log.Fatalf("%v", err)
+	}
+}
+
+func (s *server) shutdown() {
+	if err := s.httpSrv.Close(); err != nil {
+		log.Error(err)
+	}
+}
@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import "errors"
+
+const (
+	defaultBindAddress = "127.0.0.1"
+	defaultPort        = 9090
+)
+
+// Config represents administrative API configuration.
+type Config struct {
+	BindAddress string
+	Port        int
+	Token       string
+}
+
+type configProxy struct {
+	BindAddress string `yaml:"bind_addr"`
+	Port        int    `yaml:"port"`
+	Token       string `yaml:"token"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	c.Token = p.Token
+	if len(c.Token) == 0 {
+		return errors.New("admin.Config: must specify an access token")
+	}
+	c.BindAddress = p.BindAddress
+	if len(c.BindAddress) == 0 {
+		c.BindAddress = defaultBindAddress
+	}
+	c.Port = p.Port
+	if c.Port == 0 {
+		c.Port = defaultPort
+	}
+	return nil
+}
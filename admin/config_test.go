@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestConfig(t *testing.T) {
+	cfg := Config{}
+	err := yaml.Unmarshal([]byte(`port: 9999`), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`token: s3cr3t`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "s3cr3t", cfg.Token)
+	require.Equal(t, "127.0.0.1", cfg.BindAddress)
+	require.Equal(t, 9090, cfg.Port)
+
+	rawCfg := `
+bind_addr: 0.0.0.0
+port: 9999
+token: s3cr3t
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, "0.0.0.0", cfg.BindAddress)
+	require.Equal(t, 9999, cfg.Port)
+}
@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ortuman/jackal/log"
+)
+
+const apiPathPrefix = "/v1/"
+
+const maxRequestSize = 65536
+
+var (
+	errUnauthorized     = errors.New("unauthorized")
+	errNotFound         = errors.New("not found")
+	errMethodNotAllowed = errors.New("method not allowed")
+	errBadRequest       = errors.New("bad request")
+	errUserNotFound     = errors.New("user not found")
+	errUserExists       = errors.New("user already exists")
+)
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type handler struct {
+	token []byte
+}
+
+func newHandler(token string) *handler {
+	return &handler{token: []byte(token)}
+}
+
+// ServeHTTP satisfies http.Handler interface.
+func (h *handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !h.isAuthorized(r) {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		writeError(w, http.StatusUnauthorized, errUnauthorized)
+		return
+	}
+	segments, ok := pathSegments(r.URL)
+	if !ok {
+		writeError(w, http.StatusNotFound, errNotFound)
+		return
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxRequestSize)
+
+	switch {
+	case len(segments) == 1 && segments[0] == "users":
+		h.serveUsers(w, r)
+	case len(segments) >= 2 && segments[0] == "users":
+		h.serveUser(w, r, segments[1], segments[2:])
+	case len(segments) == 1 && segments[0] == "messages":
+		h.serveMessages(w, r)
+	default:
+		writeError(w, http.StatusNotFound, errNotFound)
+	}
+}
+
+func (h *handler) serveUser(w http.ResponseWriter, r *http.Request, username string, segments []string) {
+	switch {
+	case len(segments) == 0:
+		h.serveUserAccount(w, r, username)
+	case len(segments) == 1 && segments[0] == "password":
+		h.servePassword(w, r, username)
+	case len(segments) == 1 && segments[0] == "sessions":
+		h.serveSessions(w, r, username)
+	case len(segments) == 2 && segments[0] == "sessions":
+		h.serveSession(w, r, username, segments[1])
+	case len(segments) == 1 && segments[0] == "roster":
+		h.serveRoster(w, r, username)
+	case len(segments) == 2 && segments[0] == "roster":
+		h.serveRosterItem(w, r, username, segments[1])
+	default:
+		writeError(w, http.StatusNotFound, errNotFound)
+	}
+}
+
+func (h *handler) isAuthorized(r *http.Request) bool {
+	const bearerPrefix = "Bearer "
+	authz := r.Header.Get("Authorization")
+	if !strings.HasPrefix(authz, bearerPrefix) {
+		return false
+	}
+	token := []byte(strings.TrimPrefix(authz, bearerPrefix))
+	return subtle.ConstantTimeCompare(token, h.token) == 1
+}
+
+func pathSegments(u *url.URL) ([]string, bool) {
+	p := u.EscapedPath()
+	if !strings.HasPrefix(p, apiPathPrefix) {
+		return nil, false
+	}
+	var segments []string
+	for _, s := range strings.Split(strings.Trim(strings.TrimPrefix(p, apiPathPrefix), "/"), "/") {
+		seg, err := url.PathUnescape(s)
+		if err != nil || len(seg) == 0 {
+			return nil, false
+		}
+		segments = append(segments, seg)
+	}
+	return segments, true
+}
+
+func readJSON(r *http.Request, v interface{}) error {
+	return json.NewDecoder(r.Body).Decode(v)
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Error(err)
+	}
+}
+
+func writeError(w http.ResponseWriter, statusCode int, err error) {
+	writeJSON(w, statusCode, &errorResponse{Error: err.Error()})
+}
+
+func writeInternalError(w http.ResponseWriter, err error) {
+	log.Error(err)
+	writeError(w, http.StatusInternalServerError, errors.New("internal server error"))
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ortuman/jackal/auth"
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "s3cr3t"
+
+func TestHandler_Authorization(t *testing.T) {
+	h := newHandler(testToken)
+
+	req := httptest.NewRequest(http.MethodGet, "/v1/users/ortuman", nil)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req.Header.Set("Authorization", "Bearer wrong")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodGet, "/v2/users", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodGet, "/v1/foo", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_Users(t *testing.T) {
+	tUtilAdminSetup()
+	defer tUtilAdminTeardown()
+
+	h := newHandler(testToken)
+
+	rec := tUtilRequest(h, http.MethodPost, "/v1/users", `{"username":"ortuman","password":"1234"}`)
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	usr, _ := storage.Instance().FetchUser("ortuman")
+	require.NotNil(t, usr)
+	require.True(t, auth.VerifyPassword(usr, "1234"))
+
+	rec = tUtilRequest(h, http.MethodPost, "/v1/users", `{"username":"ortuman","password":"1234"}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/v1/users", `{"username":"romeo@jackal.im","password":"1234"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/v1/users", `{"username":"romeo"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/v1/users", `{"username":`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodGet, "/v1/users", "")
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// fetch user
+	rec = tUtilRequest(h, http.MethodGet, "/v1/users/ortuman", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var usrResp userResponse
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&usrResp))
+	require.Equal(t, "ortuman", usrResp.Username)
+	require.False(t, usrResp.Online)
+
+	rec = tUtilRequest(h, http.MethodGet, "/v1/users/romeo", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	// reset password
+	rec = tUtilRequest(h, http.MethodPut, "/v1/users/ortuman/password", `{"password":"5678"}`)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	usr, _ = storage.Instance().FetchUser("ortuman")
+	require.True(t, auth.VerifyPassword(usr, "5678"))
+
+	rec = tUtilRequest(h, http.MethodPut, "/v1/users/romeo/password", `{"password":"5678"}`)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPut, "/v1/users/ortuman/password", `{}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// delete user
+	stm := tUtilBindStream("ortuman", "balcony")
+	storage.Instance().InsertOrUpdateVCard(xmpp.NewElementNamespace("vCard", "vcard-temp"), "ortuman")
+	storage.Instance().InsertBlockListItems([]model.BlockListItem{{Username: "ortuman", JID: "romeo@jackal.im"}})
+
+	rec = tUtilRequest(h, http.MethodDelete, "/v1/users/ortuman", "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.True(t, stm.IsDisconnected())
+
+	ok, _ := storage.Instance().UserExists("ortuman")
+	require.False(t, ok)
+	vCard, _ := storage.Instance().FetchVCard("ortuman")
+	require.Nil(t, vCard)
+	blItems, _ := storage.Instance().FetchBlockListItems("ortuman")
+	require.Equal(t, 0, len(blItems))
+
+	rec = tUtilRequest(h, http.MethodDelete, "/v1/users/ortuman", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	storage.ActivateMockedError()
+	rec = tUtilRequest(h, http.MethodGet, "/v1/users/ortuman", "")
+	require.Equal(t, http.StatusInternalServerError, rec.Code)
+	storage.DeactivateMockedError()
+}
+
+func TestHandler_Sessions(t *testing.T) {
+	tUtilAdminSetup()
+	defer tUtilAdminTeardown()
+
+	h := newHandler(testToken)
+
+	stm1 := tUtilBindStream("ortuman", "balcony")
+	stm1.SetSecured(true)
+	stm2 := tUtilBindStream("ortuman", "garden")
+
+	rec := tUtilRequest(h, http.MethodGet, "/v1/users/ortuman/sessions", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var sessions []sessionResponse
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&sessions))
+	require.Equal(t, 2, len(sessions))
+	require.Equal(t, "ortuman@jackal.im/balcony", sessions[0].JID)
+	require.True(t, sessions[0].Secured)
+	require.True(t, sessions[0].Available)
+	require.Equal(t, "garden", sessions[1].Resource)
+
+	rec = tUtilRequest(h, http.MethodDelete, "/v1/users/ortuman/sessions/garden", "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.True(t, stm2.IsDisconnected())
+	require.False(t, stm1.IsDisconnected())
+	router.Unbind(stm2)
+
+	rec = tUtilRequest(h, http.MethodDelete, "/v1/users/ortuman/sessions/hall", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodDelete, "/v1/users/ortuman/sessions", "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+	require.True(t, stm1.IsDisconnected())
+
+	rec = tUtilRequest(h, http.MethodPost, "/v1/users/ortuman/sessions", "")
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+}
+
+func TestHandler_Roster(t *testing.T) {
+	tUtilAdminSetup()
+	defer tUtilAdminTeardown()
+
+	h := newHandler(testToken)
+
+	rec := tUtilRequest(h, http.MethodGet, "/v1/users/ortuman/roster", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman"})
+
+	rec = tUtilRequest(h, http.MethodPut, "/v1/users/ortuman/roster/romeo@jackal.im", `{"name":"Romeo","subscription":"both","groups":["Friends"]}`)
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPut, "/v1/users/ortuman/roster/juliet@jackal.im", `{"subscription":"remove"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPut, "/v1/users/ortuman/roster/romeo@", `{}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodGet, "/v1/users/ortuman/roster", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var items []rosterItem
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&items))
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "romeo@jackal.im", items[0].JID)
+	require.Equal(t, "Romeo", items[0].Name)
+	require.Equal(t, rostermodel.SubscriptionBoth, items[0].Subscription)
+	require.Equal(t, []string{"Friends"}, items[0].Groups)
+
+	rec = tUtilRequest(h, http.MethodDelete, "/v1/users/ortuman/roster/romeo@jackal.im", "")
+	require.Equal(t, http.StatusNoContent, rec.Code)
+
+	ri, _ := storage.Instance().FetchRosterItem("ortuman", "romeo@jackal.im")
+	require.Nil(t, ri)
+
+	rec = tUtilRequest(h, http.MethodDelete, "/v1/users/ortuman/roster/romeo@jackal.im", "")
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestHandler_Messages(t *testing.T) {
+	tUtilAdminSetup()
+	defer tUtilAdminTeardown()
+
+	h := newHandler(testToken)
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman"})
+	stm := tUtilBindStream("ortuman", "balcony")
+
+	rec := tUtilRequest(h, http.MethodPost, "/v1/messages", `{"to":"ortuman@jackal.im/garden","subject":"Maintenance","body":"Server restarting in 5 minutes"}`)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var msgResp messageResponse
+	require.Nil(t, json.NewDecoder(rec.Body).Decode(&msgResp))
+	require.True(t, msgResp.Delivered)
+
+	elem := stm.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, msgResp.ID, elem.ID())
+	require.Equal(t, "jackal.im", elem.From())
+	require.Equal(t, xmpp.NormalType, elem.Type())
+	require.Equal(t, "Maintenance", elem.Elements().Child("subject").Text())
+	require.Equal(t, "Server restarting in 5 minutes", elem.Elements().Child("body").Text())
+
+	rec = tUtilRequest(h, http.MethodPost, "/v1/messages", `{"to":"romeo@jackal.im","body":"Hi!"}`)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/v1/messages", `{"to":"romeo@example.org","body":"Hi!"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/v1/messages", `{"to":"jackal.im","body":"Hi!"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/v1/messages", `{"to":"ortuman@jackal.im"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = tUtilRequest(h, http.MethodPost, "/v1/messages", `{"to":"ortuman@jackal.im","type":"groupchat","body":"Hi!"}`)
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// offline recipient (offline module disabled)
+	router.Unbind(stm)
+	rec = tUtilRequest(h, http.MethodPost, "/v1/messages", `{"to":"ortuman@jackal.im","body":"Hi!"}`)
+	require.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func tUtilAdminSetup() {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+}
+
+func tUtilAdminTeardown() {
+	router.Shutdown()
+	storage.Shutdown()
+	host.Shutdown()
+}
+
+func tUtilBindStream(username, resource string) *stream.MockC2S {
+	j, _ := jid.New(username, "jackal.im", resource, true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetAuthenticated(true)
+	stm.SetPresence(xmpp.NewPresence(j, j, xmpp.AvailableType))
+	router.Bind(stm)
+	return stm
+}
+
+func tUtilRequest(h http.Handler, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
@@ -0,0 +1,112 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+type messageRequest struct {
+	To      string `json:"to"`
+	Type    string `json:"type"`
+	Subject string `json:"subject"`
+	Body    string `json:"body"`
+}
+
+type messageResponse struct {
+	ID        string `json:"id"`
+	Delivered bool   `json:"delivered"`
+}
+
+// POST /v1/messages
+func (h *handler) serveMessages(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	var req messageRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	toJID, err := jid.NewWithString(req.To, false)
+	if err != nil || len(toJID.Node()) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("invalid recipient"))
+		return
+	}
+	if !host.IsLocalHost(toJID.Domain()) {
+		writeError(w, http.StatusBadRequest, errors.New("recipient must be a local user"))
+		return
+	}
+	if len(req.Body) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("body is required"))
+		return
+	}
+	switch req.Type {
+	case "":
+		req.Type = xmpp.NormalType
+	case xmpp.NormalType, xmpp.ChatType, xmpp.HeadlineType:
+		break
+	default:
+		writeError(w, http.StatusBadRequest, errors.New("invalid message type"))
+		return
+	}
+	fromJID, _ := jid.New("", toJID.Domain(), "", true)
+
+	msg := xmpp.NewMessageType(uuid.New(), req.Type)
+	msg.SetFromJID(fromJID)
+	msg.SetToJID(toJID)
+	if len(req.Subject) > 0 {
+		subject := xmpp.NewElementName("subject")
+		subject.SetText(req.Subject)
+		msg.AppendElement(subject)
+	}
+	body := xmpp.NewElementName("body")
+	body.SetText(req.Body)
+	msg.AppendElement(body)
+
+	delivered, err := routeMessage(msg)
+	switch err {
+	case nil:
+		log.Infof("admin: sent message %s to %s", msg.ID(), toJID)
+		writeJSON(w, http.StatusOK, &messageResponse{ID: msg.ID(), Delivered: delivered})
+	case router.ErrNotExistingAccount:
+		writeError(w, http.StatusNotFound, errUserNotFound)
+	case router.ErrNotAuthenticated:
+		writeError(w, http.StatusConflict, errors.New("recipient unavailable"))
+	default:
+		writeInternalError(w, err)
+	}
+}
+
+func routeMessage(msg *xmpp.Message) (delivered bool, err error) {
+sendMessage:
+	err = router.MustRoute(msg)
+	switch err {
+	case nil:
+		return true, nil
+	case router.ErrResourceNotFound:
+		// treat the stanza as if it were addressed to <node@domain>
+		msg.SetToJID(msg.ToJID().ToBareJID())
+		goto sendMessage
+	case router.ErrNotAuthenticated:
+		// store it for later delivery
+		if off := module.Modules().Offline; off != nil {
+			off.ArchiveMessage(msg)
+			return false, nil
+		}
+	}
+	return false, err
+}
@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+var errRosterItemNotFound = errors.New("roster item not found")
+
+type rosterItem struct {
+	JID          string   `json:"jid"`
+	Name         string   `json:"name,omitempty"`
+	Subscription string   `json:"subscription"`
+	Ask          bool     `json:"ask"`
+	Groups       []string `json:"groups,omitempty"`
+}
+
+// GET /v1/users/{username}/roster
+func (h *handler) serveRoster(w http.ResponseWriter, r *http.Request, username string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	if !h.checkUserExists(w, username) {
+		return
+	}
+	items, _, err := storage.Instance().FetchRosterItems(username)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	ret := make([]rosterItem, 0, len(items))
+	for _, itm := range items {
+		ret = append(ret, rosterItem{
+			JID:          itm.JID,
+			Name:         itm.Name,
+			Subscription: itm.Subscription,
+			Ask:          itm.Ask,
+			Groups:       itm.Groups,
+		})
+	}
+	writeJSON(w, http.StatusOK, ret)
+}
+
+// PUT, DELETE /v1/users/{username}/roster/{jid}
+func (h *handler) serveRosterItem(w http.ResponseWriter, r *http.Request, username, contact string) {
+	contactJID, err := jid.NewWithString(contact, false)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	contact = contactJID.ToBareJID().String()
+
+	switch r.Method {
+	case http.MethodPut:
+		var req rosterItem
+		if err := readJSON(r, &req); err != nil {
+			writeError(w, http.StatusBadRequest, err)
+			return
+		}
+		ri := &rostermodel.Item{
+			Username:     username,
+			JID:          contact,
+			Name:         req.Name,
+			Subscription: req.Subscription,
+			Ask:          req.Ask,
+			Groups:       req.Groups,
+		}
+		switch ri.Subscription {
+		case "":
+			ri.Subscription = rostermodel.SubscriptionNone
+		case rostermodel.SubscriptionNone, rostermodel.SubscriptionFrom, rostermodel.SubscriptionTo, rostermodel.SubscriptionBoth:
+			break
+		default:
+			writeError(w, http.StatusBadRequest, errors.New("invalid subscription"))
+			return
+		}
+		if !h.checkUserExists(w, username) {
+			return
+		}
+		if err := setRosterItem(ri); err != nil {
+			writeInternalError(w, err)
+			return
+		}
+		log.Infof("admin: updated roster item %s (%s)", contact, username)
+		w.WriteHeader(http.StatusNoContent)
+
+	case http.MethodDelete:
+		ri, err := storage.Instance().FetchRosterItem(username, contact)
+		if err != nil {
+			writeInternalError(w, err)
+			return
+		}
+		if ri == nil {
+			writeError(w, http.StatusNotFound, errRosterItemNotFound)
+			return
+		}
+		if err := removeRosterItem(username, contact); err != nil {
+			writeInternalError(w, err)
+			return
+		}
+		log.Infof("admin: removed roster item %s (%s)", contact, username)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+func setRosterItem(ri *rostermodel.Item) error {
+	if r := module.Modules().Roster; r != nil {
+		return r.SetItem(ri)
+	}
+	_, err := storage.Instance().InsertOrUpdateRosterItem(ri)
+	return err
+}
+
+func removeRosterItem(username, contact string) error {
+	if r := module.Modules().Roster; r != nil {
+		return r.RemoveItem(username, contact)
+	}
+	_, err := storage.Instance().DeleteRosterItem(username, contact)
+	return err
+}
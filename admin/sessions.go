@@ -0,0 +1,86 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/stream"
+)
+
+var errSessionNotFound = errors.New("session not found")
+
+type sessionResponse struct {
+	JID        string `json:"jid"`
+	Resource   string `json:"resource"`
+	Secured    bool   `json:"secured"`
+	Compressed bool   `json:"compressed"`
+	Available  bool   `json:"available"`
+	Priority   int8   `json:"priority"`
+	Status     string `json:"status,omitempty"`
+}
+
+// GET, DELETE /v1/users/{username}/sessions
+func (h *handler) serveSessions(w http.ResponseWriter, r *http.Request, username string) {
+	switch r.Method {
+	case http.MethodGet:
+		stms := router.UserStreams(username)
+		sessions := make([]sessionResponse, 0, len(stms))
+		for _, stm := range stms {
+			sessions = append(sessions, newSessionResponse(stm))
+		}
+		writeJSON(w, http.StatusOK, sessions)
+
+	case http.MethodDelete:
+		for _, stm := range router.UserStreams(username) {
+			kick(stm)
+		}
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+// DELETE /v1/users/{username}/sessions/{resource}
+func (h *handler) serveSession(w http.ResponseWriter, r *http.Request, username, resource string) {
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	for _, stm := range router.UserStreams(username) {
+		if stm.Resource() == resource {
+			kick(stm)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeError(w, http.StatusNotFound, errSessionNotFound)
+}
+
+func kick(stm stream.C2S) {
+	log.Infof("admin: kicking session %s", stm.JID())
+	stm.Disconnect(streamerror.ErrPolicyViolation)
+}
+
+func newSessionResponse(stm stream.C2S) sessionResponse {
+	s := sessionResponse{
+		JID:        stm.JID().String(),
+		Resource:   stm.Resource(),
+		Secured:    stm.IsSecured(),
+		Compressed: stm.IsCompressed(),
+	}
+	if p := stm.Presence(); p != nil {
+		s.Available = p.IsAvailable()
+		s.Priority = p.Priority()
+		s.Status = p.Status()
+	}
+	return s
+}
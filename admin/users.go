@@ -0,0 +1,150 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package admin
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/ortuman/jackal/auth"
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+)
+
+type userRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type userResponse struct {
+	Username string `json:"username"`
+	Online   bool   `json:"online"`
+}
+
+type passwordRequest struct {
+	Password string `json:"password"`
+}
+
+// POST /v1/users
+func (h *handler) serveUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	var req userRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if !isValidUsername(req.Username) {
+		writeError(w, http.StatusBadRequest, errors.New("invalid username"))
+		return
+	}
+	if len(req.Password) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("password is required"))
+		return
+	}
+	exists, err := storage.Instance().UserExists(req.Username)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	if exists {
+		writeError(w, http.StatusConflict, errUserExists)
+		return
+	}
+	user := &model.User{Username: req.Username}
+	auth.SetUserPassword(user, req.Password)
+	if err := storage.Instance().InsertOrUpdateUser(user); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	log.Infof("admin: created user %s", user.Username)
+	writeJSON(w, http.StatusCreated, &userResponse{Username: user.Username})
+}
+
+// GET, DELETE /v1/users/{username}
+func (h *handler) serveUserAccount(w http.ResponseWriter, r *http.Request, username string) {
+	switch r.Method {
+	case http.MethodGet:
+		if !h.checkUserExists(w, username) {
+			return
+		}
+		online := len(router.UserStreams(username)) > 0
+		writeJSON(w, http.StatusOK, &userResponse{Username: username, Online: online})
+
+	case http.MethodDelete:
+		if !h.checkUserExists(w, username) {
+			return
+		}
+		if err := storage.DeleteUserData(username); err != nil {
+			writeInternalError(w, err)
+			return
+		}
+		for _, stm := range router.UserStreams(username) {
+			stm.Disconnect(streamerror.ErrNotAuthorized)
+		}
+		log.Infof("admin: deleted user %s", username)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+	}
+}
+
+// PUT /v1/users/{username}/password
+func (h *handler) servePassword(w http.ResponseWriter, r *http.Request, username string) {
+	if r.Method != http.MethodPut {
+		writeError(w, http.StatusMethodNotAllowed, errMethodNotAllowed)
+		return
+	}
+	var req passwordRequest
+	if err := readJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	if len(req.Password) == 0 {
+		writeError(w, http.StatusBadRequest, errors.New("password is required"))
+		return
+	}
+	user, err := storage.Instance().FetchUser(username)
+	if err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	if user == nil {
+		writeError(w, http.StatusNotFound, errUserNotFound)
+		return
+	}
+	auth.SetUserPassword(user, req.Password)
+	if err := storage.Instance().InsertOrUpdateUser(user); err != nil {
+		writeInternalError(w, err)
+		return
+	}
+	log.Infof("admin: reset password for user %s", username)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *handler) checkUserExists(w http.ResponseWriter, username string) bool {
+	exists, err := storage.Instance().UserExists(username)
+	if err != nil {
+		writeInternalError(w, err)
+		return false
+	}
+	if !exists {
+		writeError(w, http.StatusNotFound, errUserNotFound)
+		return false
+	}
+	return true
+}
+
+func isValidUsername(username string) bool {
+	return len(username) > 0 && !strings.ContainsAny(username, " \"&'/:<>@")
+}
@@ -0,0 +1,79 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/pborman/uuid"
+)
+
+// Anonymous represents an ANONYMOUS authenticator.
+// Authenticated users are given a random username that
+// is never persisted into storage.
+type Anonymous struct {
+	stm           stream.C2S
+	username      string
+	authenticated bool
+}
+
+// NewAnonymous returns a new anonymous authenticator instance.
+func NewAnonymous(stm stream.C2S) *Anonymous {
+	return &Anonymous{stm: stm}
+}
+
+// Mechanism returns authenticator mechanism name.
+func (a *Anonymous) Mechanism() string {
+	return "ANONYMOUS"
+}
+
+// Username returns authenticated username in case
+// authentication process has been completed.
+func (a *Anonymous) Username() string {
+	return a.username
+}
+
+// Authenticated returns whether or not user has been authenticated.
+func (a *Anonymous) Authenticated() bool {
+	return a.authenticated
+}
+
+// UsesChannelBinding returns whether or not anonymous authenticator
+// requires channel binding bytes.
+func (a *Anonymous) UsesChannelBinding() bool {
+	return false
+}
+
+// ProcessElement process an incoming authenticator element.
+func (a *Anonymous) ProcessElement(elem xmpp.XElement) error {
+	if a.authenticated {
+		return nil
+	}
+	// trace information (if any) is ignored
+	var username string
+	for len(username) == 0 {
+		username = uuid.New()
+		exists, err := storage.Instance().UserExists(username)
+		if err != nil {
+			return err
+		}
+		if exists {
+			username = ""
+		}
+	}
+	a.username = username
+	a.authenticated = true
+
+	a.stm.SendElement(xmpp.NewElementNamespace("success", saslNamespace))
+	return nil
+}
+
+// Reset resets anonymous authenticator internal state.
+func (a *Anonymous) Reset() {
+	a.username = ""
+	a.authenticated = false
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthAnonymousAuthentication(t *testing.T) {
+	testStm := authTestSetup(&model.User{Username: "mariana", Password: "1234"})
+	defer authTestTeardown()
+
+	authr := NewAnonymous(testStm)
+	require.Equal(t, "ANONYMOUS", authr.Mechanism())
+	require.False(t, authr.UsesChannelBinding())
+
+	elem := xmpp.NewElementNamespace("auth", "urn:ietf:params:xml:ns:xmpp-sasl")
+	elem.SetAttribute("mechanism", "ANONYMOUS")
+
+	// storage error...
+	storage.ActivateMockedError()
+	require.Equal(t, memstorage.ErrMockedError, authr.ProcessElement(elem))
+	storage.DeactivateMockedError()
+
+	require.Nil(t, authr.ProcessElement(elem))
+	require.True(t, authr.Authenticated())
+	username := authr.Username()
+	require.NotEmpty(t, username)
+
+	// account is never persisted
+	exists, _ := storage.Instance().UserExists(username)
+	require.False(t, exists)
+
+	// already authenticated...
+	require.Nil(t, authr.ProcessElement(elem))
+	require.Equal(t, username, authr.Username())
+
+	authr.Reset()
+	require.False(t, authr.Authenticated())
+	require.Equal(t, "", authr.Username())
+
+	// a new random username is given on every authentication
+	require.Nil(t, authr.ProcessElement(elem))
+	require.NotEqual(t, username, authr.Username())
+}
@@ -53,9 +53,15 @@ func (se *SASLError) Error() string {
 }
 
 var (
+	// ErrSASLAccountDisabled represents a 'account-disabled' authentication error.
+	ErrSASLAccountDisabled = newSASLError("account-disabled")
+
 	// ErrSASLIncorrectEncoding represents a 'incorrect-encoding' authentication error.
 	ErrSASLIncorrectEncoding = newSASLError("incorrect-encoding")
 
+	// ErrSASLInvalidAuthzid represents a 'invalid-authzid' authentication error.
+	ErrSASLInvalidAuthzid = newSASLError("invalid-authzid")
+
 	// ErrSASLMalformedRequest represents a 'malformed-request' authentication error.
 	ErrSASLMalformedRequest = newSASLError("malformed-request")
 
@@ -34,7 +34,9 @@ func authTestTeardown() {
 }
 
 func TestAuthError(t *testing.T) {
+	require.Equal(t, "account-disabled", ErrSASLAccountDisabled.(*SASLError).Error())
 	require.Equal(t, "incorrect-encoding", ErrSASLIncorrectEncoding.(*SASLError).Error())
+	require.Equal(t, "invalid-authzid", ErrSASLInvalidAuthzid.(*SASLError).Error())
 	require.Equal(t, "malformed-request", ErrSASLMalformedRequest.(*SASLError).Error())
 	require.Equal(t, "not-authorized", ErrSASLNotAuthorized.(*SASLError).Error())
 	require.Equal(t, "temporary-auth-failure", ErrSASLTemporaryAuthFailure.(*SASLError).Error())
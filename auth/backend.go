@@ -0,0 +1,90 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"sync"
+
+	"github.com/ortuman/jackal/storage"
+)
+
+// Backend represents a user authentication backend.
+type Backend interface {
+	// CheckPassword reports whether or not password matches
+	// the credentials of a given user.
+	CheckPassword(username, password string) (bool, error)
+
+	// UserExists returns whether or not a user account exists.
+	UserExists(username string) (bool, error)
+}
+
+var (
+	instMu      sync.RWMutex
+	inst        Backend
+	initialized bool
+)
+
+// Initialize initializes authentication backend.
+func Initialize(cfg *Config) {
+	instMu.Lock()
+	defer instMu.Unlock()
+	if initialized {
+		return
+	}
+	switch cfg.Type {
+	case HTTPBackend:
+		inst = newHTTPBackend(cfg.HTTP)
+	default:
+		inst = &storageBackend{}
+	}
+	initialized = true
+}
+
+// Instance returns global authentication backend.
+// Local storage backend is used if it hasn't been initialized.
+func Instance() Backend {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	if inst == nil {
+		return &storageBackend{}
+	}
+	return inst
+}
+
+// IsLocal returns whether or not users are authenticated
+// against local storage credentials.
+func IsLocal() bool {
+	_, ok := Instance().(*storageBackend)
+	return ok
+}
+
+// Shutdown shuts down authentication backend.
+// This method should be used only for testing purposes.
+func Shutdown() {
+	instMu.Lock()
+	defer instMu.Unlock()
+	inst = nil
+	initialized = false
+}
+
+// storageBackend authenticates users against local storage credentials.
+type storageBackend struct{}
+
+func (b *storageBackend) CheckPassword(username, password string) (bool, error) {
+	user, err := storage.Instance().FetchUser(username)
+	if err != nil {
+		return false, err
+	}
+	if user == nil || !VerifyPassword(user, password) {
+		return false, nil
+	}
+	upgradeLegacyCredentials(user)
+	return true, nil
+}
+
+func (b *storageBackend) UserExists(username string) (bool, error) {
+	return storage.Instance().UserExists(username)
+}
@@ -0,0 +1,124 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthStorageBackend(t *testing.T) {
+	authTestSetup(&model.User{Username: "mariana", Password: "1234"})
+	defer authTestTeardown()
+
+	b := Instance()
+	require.IsType(t, &storageBackend{}, b)
+	require.True(t, IsLocal())
+
+	ok, err := b.CheckPassword("mariana", "1234")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	ok, err = b.CheckPassword("mariana", "4321")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	ok, err = b.CheckPassword("ortuman", "1234")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	exists, err := b.UserExists("mariana")
+	require.Nil(t, err)
+	require.True(t, exists)
+
+	storage.ActivateMockedError()
+	_, err = b.CheckPassword("mariana", "1234")
+	require.Equal(t, memstorage.ErrMockedError, err)
+	_, err = b.UserExists("mariana")
+	require.Equal(t, memstorage.ErrMockedError, err)
+	storage.DeactivateMockedError()
+}
+
+func TestAuthHTTPBackend(t *testing.T) {
+	authTestSetup(&model.User{Username: "mariana", Password: "1234"})
+	defer authTestTeardown()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var req httpBackendRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		var res httpBackendResponse
+		switch r.URL.Path {
+		case "/xmpp/check_password":
+			res.Result = req.Username == "ortuman" && req.Password == "qwerty"
+		case "/xmpp/user_exists":
+			res.Result = req.Username == "ortuman"
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(&res)
+	}))
+	defer srv.Close()
+
+	Initialize(&Config{
+		Type: HTTPBackend,
+		HTTP: &HTTPBackendConfig{URL: srv.URL + "/xmpp/", Token: "s3cr3t", Timeout: time.Second},
+	})
+	defer Shutdown()
+
+	b := Instance()
+	require.IsType(t, &httpBackend{}, b)
+	require.False(t, IsLocal())
+
+	exists, err := b.UserExists("ortuman")
+	require.Nil(t, err)
+	require.True(t, exists)
+
+	// local accounts are not taken into account
+	exists, err = b.UserExists("mariana")
+	require.Nil(t, err)
+	require.False(t, exists)
+
+	ok, err := b.CheckPassword("ortuman", "1234")
+	require.Nil(t, err)
+	require.False(t, ok)
+
+	exists, _ = storage.Instance().UserExists("ortuman")
+	require.False(t, exists)
+
+	ok, err = b.CheckPassword("ortuman", "qwerty")
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	// authenticated account mirrored into local storage
+	exists, _ = storage.Instance().UserExists("ortuman")
+	require.True(t, exists)
+
+	// unexpected status code...
+	b2 := newHTTPBackend(&HTTPBackendConfig{URL: srv.URL + "/xmpp", Timeout: time.Second})
+	_, err = b2.UserExists("ortuman")
+	require.NotNil(t, err)
+
+	// unreachable endpoint...
+	srv.Close()
+	_, err = b.CheckPassword("ortuman", "qwerty")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+const defaultHTTPBackendTimeout = time.Duration(5) * time.Second
+
+// BackendType represents an authentication backend type.
+type BackendType int
+
+const (
+	// StorageBackend represents a local storage authentication backend type.
+	StorageBackend BackendType = iota
+
+	// HTTPBackend represents an external HTTP authentication backend type.
+	HTTPBackend
+)
+
+// HTTPBackendConfig represents an external HTTP authentication backend configuration.
+type HTTPBackendConfig struct {
+	URL     string
+	Token   string
+	Timeout time.Duration
+}
+
+type httpBackendProxyType struct {
+	URL     string `yaml:"url"`
+	Token   string `yaml:"token"`
+	Timeout int    `yaml:"timeout"`
+}
+
+// Config represents an authentication backend configuration.
+type Config struct {
+	Type BackendType
+	HTTP *HTTPBackendConfig
+}
+
+type configProxy struct {
+	Type string                `yaml:"type"`
+	HTTP *httpBackendProxyType `yaml:"http"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	switch p.Type {
+	case "", "storage":
+		c.Type = StorageBackend
+
+	case "http":
+		if p.HTTP == nil || len(p.HTTP.URL) == 0 {
+			return errors.New("auth.Config: couldn't read HTTP backend configuration")
+		}
+		if p.HTTP.Timeout < 0 {
+			return errors.New("auth.Config: HTTP backend timeout must be 0 or higher")
+		}
+		c.Type = HTTPBackend
+		c.HTTP = &HTTPBackendConfig{
+			URL:     p.HTTP.URL,
+			Token:   p.HTTP.Token,
+			Timeout: time.Duration(p.HTTP.Timeout) * time.Second,
+		}
+		if c.HTTP.Timeout == 0 {
+			c.HTTP.Timeout = defaultHTTPBackendTimeout
+		}
+
+	default:
+		return fmt.Errorf("auth.Config: unrecognized backend type: %s", p.Type)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestAuthConfig(t *testing.T) {
+	cfg := Config{}
+
+	err := yaml.Unmarshal([]byte(`{}`), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, StorageBackend, cfg.Type)
+
+	storageCfg := `
+  type: storage
+`
+	err = yaml.Unmarshal([]byte(storageCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, StorageBackend, cfg.Type)
+
+	httpCfg := `
+  type: http
+  http:
+    url: https://idp.example.org/xmpp
+    token: s3cr3t
+    timeout: 10
+`
+	cfg = Config{}
+	err = yaml.Unmarshal([]byte(httpCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, HTTPBackend, cfg.Type)
+	require.Equal(t, "https://idp.example.org/xmpp", cfg.HTTP.URL)
+	require.Equal(t, "s3cr3t", cfg.HTTP.Token)
+	require.Equal(t, time.Second*10, cfg.HTTP.Timeout)
+
+	httpCfg2 := `
+  type: http
+  http:
+    url: https://idp.example.org/xmpp
+`
+	cfg = Config{}
+	err = yaml.Unmarshal([]byte(httpCfg2), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, defaultHTTPBackendTimeout, cfg.HTTP.Timeout)
+
+	invalidHTTPCfg := `
+  type: http
+`
+	err = yaml.Unmarshal([]byte(invalidHTTPCfg), &cfg)
+	require.NotNil(t, err)
+
+	invalidHTTPCfg2 := `
+  type: http
+  http:
+    url: https://idp.example.org/xmpp
+    timeout: -1
+`
+	err = yaml.Unmarshal([]byte(invalidHTTPCfg2), &cfg)
+	require.NotNil(t, err)
+
+	invalidCfg := `
+  type: ldap
+`
+	err = yaml.Unmarshal([]byte(invalidCfg), &cfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`[]`), &cfg)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,113 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/util"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// CredentialSHA1 represents a SHA-1 derived user credential.
+	CredentialSHA1 = "sha-1"
+
+	// CredentialSHA256 represents a SHA-256 derived user credential.
+	CredentialSHA256 = "sha-256"
+)
+
+const credentialSaltLength = 32
+
+// NewCredentials derives a SCRAM credential for every supported
+// hash algorithm from a plaintext password.
+func NewCredentials(password string) []model.Credential {
+	return []model.Credential{
+		newCredential(CredentialSHA1, password, util.RandomBytes(credentialSaltLength), iterationsCount),
+		newCredential(CredentialSHA256, password, util.RandomBytes(credentialSaltLength), iterationsCount),
+	}
+}
+
+// SetUserPassword replaces user credentials with the ones derived
+// from password, discarding any legacy plaintext password.
+func SetUserPassword(user *model.User, password string) {
+	user.Password = ""
+	user.Credentials = NewCredentials(password)
+}
+
+// VerifyPassword reports whether or not password matches user credentials.
+func VerifyPassword(user *model.User, password string) bool {
+	if len(user.Credentials) == 0 {
+		// legacy plaintext password
+		return len(user.Password) > 0 && hmac.Equal([]byte(user.Password), []byte(password))
+	}
+	for _, c := range user.Credentials {
+		h := credentialHash(c.Hash)
+		if h == nil {
+			continue
+		}
+		dc := deriveCredential(c.Hash, h, password, c.Salt, c.Iterations)
+		return hmac.Equal(dc.StoredKey, c.StoredKey)
+	}
+	return false
+}
+
+// upgradeLegacyCredentials replaces a legacy plaintext password
+// with its derived credentials and persists the updated user.
+func upgradeLegacyCredentials(user *model.User) {
+	if len(user.Password) == 0 {
+		return
+	}
+	log.Infof("upgrading legacy credentials... (username: %s)", user.Username)
+
+	upgraded := *user
+	SetUserPassword(&upgraded, user.Password)
+	if err := storage.Instance().InsertOrUpdateUser(&upgraded); err != nil {
+		log.Error(err)
+		return
+	}
+	*user = upgraded
+}
+
+func newCredential(hashName string, password string, salt []byte, iterations int) model.Credential {
+	return deriveCredential(hashName, credentialHash(hashName), password, salt, iterations)
+}
+
+func deriveCredential(hashName string, h func() hash.Hash, password string, salt []byte, iterations int) model.Credential {
+	saltedPassword := pbkdf2.Key([]byte(password), salt, iterations, h().Size(), h)
+	clientKey := credentialHMAC(h, []byte("Client Key"), saltedPassword)
+	storedKey := h()
+	storedKey.Write(clientKey)
+	return model.Credential{
+		Hash:       hashName,
+		Salt:       salt,
+		Iterations: iterations,
+		StoredKey:  storedKey.Sum(nil),
+		ServerKey:  credentialHMAC(h, []byte("Server Key"), saltedPassword),
+	}
+}
+
+func credentialHash(hashName string) func() hash.Hash {
+	switch hashName {
+	case CredentialSHA1:
+		return sha1.New
+	case CredentialSHA256:
+		return sha256.New
+	}
+	return nil
+}
+
+func credentialHMAC(h func() hash.Hash, b []byte, key []byte) []byte {
+	m := hmac.New(h, key)
+	m.Write(b)
+	return m.Sum(nil)
+}
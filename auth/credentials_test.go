@@ -0,0 +1,60 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthCredentials(t *testing.T) {
+	credentials := NewCredentials("1234")
+	require.Equal(t, 2, len(credentials))
+	require.Equal(t, CredentialSHA1, credentials[0].Hash)
+	require.Equal(t, 20, len(credentials[0].StoredKey))
+	require.Equal(t, CredentialSHA256, credentials[1].Hash)
+	require.Equal(t, 32, len(credentials[1].ServerKey))
+	require.Equal(t, iterationsCount, credentials[1].Iterations)
+
+	// same password, different salt
+	credentials2 := NewCredentials("1234")
+	require.NotEqual(t, credentials[0].StoredKey, credentials2[0].StoredKey)
+
+	usr := &model.User{Username: "ortuman"}
+	require.False(t, VerifyPassword(usr, ""))
+
+	SetUserPassword(usr, "1234")
+	require.True(t, VerifyPassword(usr, "1234"))
+	require.False(t, VerifyPassword(usr, "12345"))
+
+	// legacy plaintext password
+	usr2 := &model.User{Username: "ortuman", Password: "1234"}
+	require.True(t, VerifyPassword(usr2, "1234"))
+	require.False(t, VerifyPassword(usr2, "12345"))
+}
+
+func TestAuthUpgradeLegacyCredentials(t *testing.T) {
+	authTestSetup(&model.User{Username: "romeo", Password: "1234"})
+	defer authTestTeardown()
+
+	usr, _ := storage.Instance().FetchUser("romeo")
+
+	// storage error
+	storage.ActivateMockedError()
+	upgradeLegacyCredentials(usr)
+	storage.DeactivateMockedError()
+	require.Equal(t, "1234", usr.Password)
+
+	upgradeLegacyCredentials(usr)
+	require.Equal(t, "", usr.Password)
+
+	usr2, _ := storage.Instance().FetchUser("romeo")
+	require.Equal(t, "", usr2.Password)
+	require.True(t, VerifyPassword(usr2, "1234"))
+}
@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"strings"
+
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+var (
+	oidSubjectAltName = asn1.ObjectIdentifier{2, 5, 29, 17}
+	oidXMPPAddr       = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 8, 5}
+)
+
+type otherName struct {
+	TypeID asn1.ObjectIdentifier
+	Value  asn1.RawValue // [0] EXPLICIT ANY
+}
+
+// External represents an EXTERNAL authenticator (XEP-0178).
+type External struct {
+	stm           stream.C2S
+	tr            transport.Transport
+	roots         *x509.CertPool
+	username      string
+	authenticated bool
+}
+
+// NewExternal returns a new external authenticator instance.
+// Client certificates will be validated against roots pool.
+func NewExternal(stm stream.C2S, tr transport.Transport, roots *x509.CertPool) *External {
+	return &External{stm: stm, tr: tr, roots: roots}
+}
+
+// Mechanism returns authenticator mechanism name.
+func (e *External) Mechanism() string {
+	return "EXTERNAL"
+}
+
+// Username returns authenticated username in case
+// authentication process has been completed.
+func (e *External) Username() string {
+	return e.username
+}
+
+// Authenticated returns whether or not user has been authenticated.
+func (e *External) Authenticated() bool {
+	return e.authenticated
+}
+
+// UsesChannelBinding returns whether or not external authenticator
+// requires channel binding bytes.
+func (e *External) UsesChannelBinding() bool {
+	return false
+}
+
+// ProcessElement process an incoming authenticator element.
+func (e *External) ProcessElement(elem xmpp.XElement) error {
+	if e.authenticated {
+		return nil
+	}
+	var authzID string
+	if txt := elem.Text(); len(txt) > 0 && txt != "=" {
+		b, err := base64.StdEncoding.DecodeString(txt)
+		if err != nil {
+			return ErrSASLIncorrectEncoding
+		}
+		authzID = string(b)
+	}
+	certs := e.tr.PeerCertificates()
+	if len(certs) == 0 || !e.verifyCertificate(certs) {
+		return ErrSASLNotAuthorized
+	}
+	usernames := e.certificateUsernames(certs[0])
+
+	var username string
+	if len(authzID) > 0 {
+		j, err := jid.NewWithString(authzID, false)
+		if err != nil || j.Domain() != e.stm.Domain() || len(j.Resource()) > 0 {
+			return ErrSASLInvalidAuthzid
+		}
+		for _, u := range usernames {
+			if u == j.Node() {
+				username = u
+				break
+			}
+		}
+		if len(username) == 0 {
+			return ErrSASLInvalidAuthzid
+		}
+	} else if len(usernames) > 0 {
+		username = usernames[0]
+	}
+	if len(username) == 0 {
+		return ErrSASLNotAuthorized
+	}
+	user, err := storage.Instance().FetchUser(username)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return ErrSASLNotAuthorized
+	}
+	e.username = username
+	e.authenticated = true
+
+	e.stm.SendElement(xmpp.NewElementNamespace("success", saslNamespace))
+	return nil
+}
+
+// Reset resets external authenticator internal state.
+func (e *External) Reset() {
+	e.username = ""
+	e.authenticated = false
+}
+
+func (e *External) verifyCertificate(certs []*x509.Certificate) bool {
+	if e.roots == nil {
+		return false
+	}
+	intermediates := x509.NewCertPool()
+	for _, cert := range certs[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err := certs[0].Verify(x509.VerifyOptions{
+		Roots:         e.roots,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	return err == nil
+}
+
+// certificateUsernames returns the local usernames a client certificate
+// has been issued for, looking at xmppAddr and e-mail SAN identities,
+// and falling back to subject common name.
+func (e *External) certificateUsernames(cert *x509.Certificate) []string {
+	var identities []string
+	identities = append(identities, xmppAddrs(cert)...)
+	identities = append(identities, cert.EmailAddresses...)
+	if len(cert.Subject.CommonName) > 0 {
+		identities = append(identities, cert.Subject.CommonName)
+	}
+	var usernames []string
+	for _, identity := range identities {
+		if !strings.Contains(identity, "@") {
+			identity += "@" + e.stm.Domain()
+		}
+		j, err := jid.NewWithString(identity, false)
+		if err != nil || len(j.Node()) == 0 || j.Domain() != e.stm.Domain() {
+			continue
+		}
+		usernames = append(usernames, j.Node())
+	}
+	return usernames
+}
+
+func xmppAddrs(cert *x509.Certificate) []string {
+	var addrs []string
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSubjectAltName) {
+			continue
+		}
+		var seq asn1.RawValue
+		if _, err := asn1.Unmarshal(ext.Value, &seq); err != nil {
+			return nil
+		}
+		rest := seq.Bytes
+		for len(rest) > 0 {
+			var gn asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &gn); err != nil {
+				return addrs
+			}
+			// otherName [0]
+			if gn.Class != asn1.ClassContextSpecific || gn.Tag != 0 {
+				continue
+			}
+			var on otherName
+			if _, err := asn1.UnmarshalWithParams(gn.FullBytes, &on, "tag:0"); err != nil || !on.TypeID.Equal(oidXMPPAddr) {
+				continue
+			}
+			var addr string
+			if _, err := asn1.Unmarshal(on.Value.Bytes, &addr); err != nil {
+				continue
+			}
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
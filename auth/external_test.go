@@ -0,0 +1,135 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthExternalAuthentication(t *testing.T) {
+	testStm := authTestSetup(&model.User{Username: "mariana", Password: "1234"})
+	defer authTestTeardown()
+
+	ca, caKey := tUtilExternalCA(t)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	tr := &fakeTransport{}
+	authr := NewExternal(testStm, tr, roots)
+	require.Equal(t, "EXTERNAL", authr.Mechanism())
+	require.False(t, authr.UsesChannelBinding())
+
+	elem := xmpp.NewElementNamespace("auth", "urn:ietf:params:xml:ns:xmpp-sasl")
+	elem.SetAttribute("mechanism", "EXTERNAL")
+	elem.SetText("=")
+
+	// no client certificate...
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	// untrusted certificate...
+	otherCA, otherKey := tUtilExternalCA(t)
+	tr.peerCerts = []*x509.Certificate{tUtilExternalCert(t, otherCA, otherKey, "mariana@localhost", nil)}
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	// not existing account...
+	tr.peerCerts = []*x509.Certificate{tUtilExternalCert(t, ca, caKey, "noelia", nil)}
+	require.Equal(t, ErrSASLNotAuthorized, authr.ProcessElement(elem))
+
+	// common name identity...
+	tr.peerCerts = []*x509.Certificate{tUtilExternalCert(t, ca, caKey, "mariana", nil)}
+	require.Nil(t, authr.ProcessElement(elem))
+	require.True(t, authr.Authenticated())
+	require.Equal(t, "mariana", authr.Username())
+
+	authr.Reset()
+	require.False(t, authr.Authenticated())
+	require.Equal(t, "", authr.Username())
+
+	// xmppAddr identity...
+	tr.peerCerts = []*x509.Certificate{tUtilExternalCert(t, ca, caKey, "device-1234", []string{"mariana@localhost"})}
+	require.Nil(t, authr.ProcessElement(elem))
+	require.Equal(t, "mariana", authr.Username())
+	authr.Reset()
+
+	// authorization identity...
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("noelia@localhost")))
+	require.Equal(t, ErrSASLInvalidAuthzid, authr.ProcessElement(elem))
+
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("mariana@localhost")))
+	require.Nil(t, authr.ProcessElement(elem))
+	require.Equal(t, "mariana", authr.Username())
+	authr.Reset()
+
+	// incorrect encoding...
+	elem.SetText("bad-encoding!")
+	require.Equal(t, ErrSASLIncorrectEncoding, authr.ProcessElement(elem))
+}
+
+func tUtilExternalCA(t *testing.T) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "jackal test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	b, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(b)
+	require.Nil(t, err)
+	return cert, key
+}
+
+func tUtilExternalCert(t *testing.T, ca *x509.Certificate, caKey *ecdsa.PrivateKey, cn string, xmppAddrs []string) *x509.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	if len(xmppAddrs) > 0 {
+		var names []asn1.RawValue
+		for _, addr := range xmppAddrs {
+			val, err := asn1.MarshalWithParams(addr, "utf8")
+			require.Nil(t, err)
+			on, err := asn1.MarshalWithParams(otherName{
+				TypeID: oidXMPPAddr,
+				Value:  asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: val},
+			}, "tag:0")
+			require.Nil(t, err)
+			names = append(names, asn1.RawValue{FullBytes: on})
+		}
+		san, err := asn1.Marshal(names)
+		require.Nil(t, err)
+		tmpl.ExtraExtensions = []pkix.Extension{{Id: oidSubjectAltName, Value: san}}
+	}
+	b, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	require.Nil(t, err)
+	cert, err := x509.ParseCertificate(b)
+	require.Nil(t, err)
+	return cert
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+)
+
+type httpBackendRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+}
+
+type httpBackendResponse struct {
+	Result bool `json:"result"`
+}
+
+// httpBackend authenticates users against an external HTTP endpoint.
+//
+// Requests are sent as JSON encoded POST requests to the check_password
+// and user_exists endpoint paths, expecting a JSON encoded boolean
+// result ({"result": true}) in response.
+type httpBackend struct {
+	cfg    *HTTPBackendConfig
+	client *http.Client
+}
+
+func newHTTPBackend(cfg *HTTPBackendConfig) *httpBackend {
+	return &httpBackend{
+		cfg:    cfg,
+		client: &http.Client{Timeout: cfg.Timeout},
+	}
+}
+
+func (b *httpBackend) CheckPassword(username, password string) (bool, error) {
+	ok, err := b.request("check_password", &httpBackendRequest{Username: username, Password: password})
+	if err != nil || !ok {
+		return false, err
+	}
+	// mirror external account into local storage, so that
+	// routing, roster and offline messages keep working.
+	exists, err := storage.Instance().UserExists(username)
+	if err != nil {
+		return false, err
+	}
+	if !exists {
+		log.Infof("creating local account for externally authenticated user: %s", username)
+		if err := storage.Instance().InsertOrUpdateUser(&model.User{Username: username}); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (b *httpBackend) UserExists(username string) (bool, error) {
+	return b.request("user_exists", &httpBackendRequest{Username: username})
+}
+
+func (b *httpBackend) request(endpoint string, body *httpBackendRequest) (bool, error) {
+	reqBody, err := json.Marshal(body)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, strings.TrimSuffix(b.cfg.URL, "/")+"/"+endpoint, bytes.NewReader(reqBody))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if len(b.cfg.Token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+b.cfg.Token)
+	}
+	resp, err := b.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("auth: %s request failed with status code: %d", endpoint, resp.StatusCode)
+	}
+	var res httpBackendResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return false, err
+	}
+	return res.Result, nil
+}
@@ -9,7 +9,6 @@ import (
 	"bytes"
 	"encoding/base64"
 
-	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xmpp"
 )
@@ -68,13 +67,14 @@ func (p *Plain) ProcessElement(elem xmpp.XElement) error {
 	password := string(s[2])
 
 	// validate user and password
-	user, err := storage.Instance().FetchUser(username)
+	ok, err := Instance().CheckPassword(username, password)
 	if err != nil {
 		return err
 	}
-	if user == nil || user.Password != password {
+	if !ok {
 		return ErrSASLNotAuthorized
 	}
+
 	p.username = username
 	p.authenticated = true
 
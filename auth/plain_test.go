@@ -49,6 +49,11 @@ func TestAuthPlainAuthentication(t *testing.T) {
 	require.Equal(t, "mariana", authr.Username())
 	require.True(t, authr.Authenticated())
 
+	// legacy password upgraded...
+	usr, _ := storage.Instance().FetchUser("mariana")
+	require.Equal(t, "", usr.Password)
+	require.True(t, VerifyPassword(usr, "1234"))
+
 	// already authenticated...
 	err = authr.ProcessElement(elem)
 	require.Nil(t, err)
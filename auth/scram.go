@@ -22,7 +22,6 @@ import (
 	"github.com/ortuman/jackal/util"
 	"github.com/ortuman/jackal/xmpp"
 	"github.com/pborman/uuid"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 // ScramType represents a scram autheticator class
@@ -88,7 +87,7 @@ type Scram struct {
 	state         scramState
 	params        *scramParameters
 	user          *model.User
-	salt          []byte
+	credential    *model.Credential
 	srvNonce      string
 	firstMessage  string
 	authenticated bool
@@ -176,7 +175,7 @@ func (s *Scram) Reset() {
 	s.state = startScramState
 	s.params = nil
 	s.user = nil
-	s.salt = nil
+	s.credential = nil
 	s.srvNonce = ""
 	s.firstMessage = ""
 }
@@ -202,12 +201,21 @@ func (s *Scram) handleStart(elem xmpp.XElement) error {
 	if user == nil {
 		return ErrSASLNotAuthorized
 	}
+	credential := user.Credential(s.credentialHash())
+	if credential == nil {
+		if len(user.Password) == 0 {
+			return ErrSASLNotAuthorized
+		}
+		// legacy plaintext password: derive credential on the fly
+		c := newCredential(s.credentialHash(), user.Password, util.RandomBytes(credentialSaltLength), iterationsCount)
+		credential = &c
+	}
 	s.user = user
+	s.credential = credential
 
 	s.srvNonce = cNonce + "-" + uuid.New()
-	s.salt = util.RandomBytes(32)
-	sb64 := base64.StdEncoding.EncodeToString(s.salt)
-	s.firstMessage = fmt.Sprintf("r=%s,s=%s,i=%d", s.srvNonce, sb64, iterationsCount)
+	sb64 := base64.StdEncoding.EncodeToString(s.credential.Salt)
+	s.firstMessage = fmt.Sprintf("r=%s,s=%s,i=%d", s.srvNonce, sb64, s.credential.Iterations)
 
 	respElem := xmpp.NewElementNamespace("challenge", saslNamespace)
 	respElem.SetText(base64.StdEncoding.EncodeToString([]byte(s.firstMessage)))
@@ -226,25 +234,30 @@ func (s *Scram) handleChallenged(elem xmpp.XElement) error {
 	initialMessage := s.params.String()
 	clientFinalMessageBare := fmt.Sprintf("c=%s,r=%s", c, s.srvNonce)
 
-	saltedPassword := s.pbkdf2([]byte(s.user.Password))
-	clientKey := s.hmac([]byte("Client Key"), saltedPassword)
-	storedKey := s.hash(clientKey)
+	proofPrefix := clientFinalMessageBare + ",p="
+	if !strings.HasPrefix(p, proofPrefix) {
+		return ErrSASLNotAuthorized
+	}
+	clientProof, err := base64.StdEncoding.DecodeString(p[len(proofPrefix):])
+	if err != nil || len(clientProof) != s.hKeyLen {
+		return ErrSASLNotAuthorized
+	}
 	authMessage := initialMessage + "," + s.firstMessage + "," + clientFinalMessageBare
-	clientSignature := s.hmac([]byte(authMessage), storedKey)
+	clientSignature := s.hmac([]byte(authMessage), s.credential.StoredKey)
 
-	clientProof := make([]byte, len(clientKey))
-	for i := 0; i < len(clientKey); i++ {
-		clientProof[i] = clientKey[i] ^ clientSignature[i]
+	// recover client key from its proof and check it against stored key
+	clientKey := make([]byte, len(clientProof))
+	for i := 0; i < len(clientProof); i++ {
+		clientKey[i] = clientProof[i] ^ clientSignature[i]
 	}
-	serverKey := s.hmac([]byte("Server Key"), saltedPassword)
-	serverSignature := s.hmac([]byte(authMessage), serverKey)
-
-	clientFinalMessage := clientFinalMessageBare + ",p=" + base64.StdEncoding.EncodeToString(clientProof)
-	if clientFinalMessage != p {
+	if !hmac.Equal(s.hash(clientKey), s.credential.StoredKey) {
 		return ErrSASLNotAuthorized
 	}
+	serverSignature := s.hmac([]byte(authMessage), s.credential.ServerKey)
 	v := "v=" + base64.StdEncoding.EncodeToString(serverSignature)
 
+	upgradeLegacyCredentials(s.user)
+
 	respElem := xmpp.NewElementNamespace("success", saslNamespace)
 	respElem.SetText(base64.StdEncoding.EncodeToString([]byte(v)))
 	s.stm.SendElement(respElem)
@@ -319,8 +332,11 @@ func (s *Scram) getCBindInputString() string {
 	return base64.StdEncoding.EncodeToString(buf.Bytes())
 }
 
-func (s *Scram) pbkdf2(b []byte) []byte {
-	return pbkdf2.Key(b, s.salt, iterationsCount, s.hKeyLen, s.h)
+func (s *Scram) credentialHash() string {
+	if s.tp == ScramSHA1 {
+		return CredentialSHA1
+	}
+	return CredentialSHA256
 }
 
 func (s *Scram) hmac(b []byte, key []byte) []byte {
@@ -15,21 +15,26 @@ import (
 	"encoding/base64"
 	"fmt"
 	"hash"
+	"net"
 	"strconv"
 	"strings"
 	"testing"
 
 	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/transport/compress"
 	"github.com/ortuman/jackal/util"
 	"github.com/ortuman/jackal/xmpp"
+	"github.com/pborman/uuid"
 	"github.com/stretchr/testify/require"
 	"golang.org/x/crypto/pbkdf2"
 )
 
 type fakeTransport struct {
-	cbBytes []byte
+	cbBytes   []byte
+	peerCerts []*x509.Certificate
 }
 
 func (ft *fakeTransport) Read(p []byte) (n int, err error)        { return 0, nil }
@@ -42,7 +47,8 @@ func (ft *fakeTransport) EnableCompression(compress.Level)        { return }
 func (ft *fakeTransport) ChannelBindingBytes(transport.ChannelBindingMechanism) []byte {
 	return ft.cbBytes
 }
-func (ft *fakeTransport) PeerCertificates() []*x509.Certificate { return nil }
+func (ft *fakeTransport) PeerCertificates() []*x509.Certificate { return ft.peerCerts }
+func (ft *fakeTransport) RemoteAddr() net.Addr                  { return nil }
 
 type scramAuthTestCase struct {
 	id          int
@@ -239,7 +245,7 @@ func TestScramBadPayload(t *testing.T) {
 
 func TestScramSuccessTestCases(t *testing.T) {
 	for _, tc := range tt {
-		err := processScramTestCase(t, &tc)
+		err := processScramTestCase(t, &tc, &model.User{Username: "ortuman", Password: "1234"})
 		if err != nil {
 			require.Equal(t, tc.expectedErr, err, fmt.Sprintf("TC identifier: %d", tc.id))
 			continue
@@ -247,12 +253,87 @@ func TestScramSuccessTestCases(t *testing.T) {
 	}
 }
 
-func processScramTestCase(t *testing.T, tc *scramAuthTestCase) error {
+func TestScramHashedCredentials(t *testing.T) {
+	credentials := NewCredentials("1234")
+	for _, tc := range tt {
+		err := processScramTestCase(t, &tc, &model.User{Username: "ortuman", Credentials: credentials})
+		if err != nil {
+			require.Equal(t, tc.expectedErr, err, fmt.Sprintf("TC identifier: %d", tc.id))
+			continue
+		}
+	}
+	// no credentials at all
+	tc := tt[0]
+	err := processScramTestCase(t, &tc, &model.User{Username: "ortuman"})
+	require.Equal(t, ErrSASLNotAuthorized, err)
+}
+
+func TestScramLegacyCredentialsUpgrade(t *testing.T) {
+	tr := &fakeTransport{}
+	testStrm := authTestSetup(&model.User{Username: "ortuman", Password: "1234"})
+	defer authTestTeardown()
+
+	authr := NewScram(testStrm, tr, ScramSHA256, false)
+	res := processScramAuthentication(t, authr, testStrm, "ortuman", "1234")
+	require.Nil(t, res)
+	require.True(t, authr.Authenticated())
+
+	usr, _ := storage.Instance().FetchUser("ortuman")
+	require.Equal(t, "", usr.Password)
+	require.Equal(t, 2, len(usr.Credentials))
+	require.True(t, VerifyPassword(usr, "1234"))
+
+	// authenticate against upgraded credentials
+	authr = NewScram(testStrm, tr, ScramSHA1, false)
+	require.Nil(t, processScramAuthentication(t, authr, testStrm, "ortuman", "1234"))
+	require.True(t, authr.Authenticated())
+
+	// wrong password
+	authr = NewScram(testStrm, tr, ScramSHA1, false)
+	require.Equal(t, ErrSASLNotAuthorized, processScramAuthentication(t, authr, testStrm, "ortuman", "4321"))
+}
+
+func processScramAuthentication(t *testing.T, authr *Scram, testStrm *stream.MockC2S, username, password string) error {
+	auth := xmpp.NewElementNamespace("auth", saslNamespace)
+	auth.SetAttribute("mechanism", authr.Mechanism())
+
+	clientInitialMessage := fmt.Sprintf(`n=%s,r=%s`, username, uuid.New())
+	gs2Header := "n,,"
+	auth.SetText(base64.StdEncoding.EncodeToString([]byte(gs2Header + clientInitialMessage)))
+
+	if err := authr.ProcessElement(auth); err != nil {
+		return err
+	}
+	challenge := testStrm.FetchElement()
+	require.Equal(t, "challenge", challenge.Name())
+
+	srvInitialMessage, _ := base64.StdEncoding.DecodeString(challenge.Text())
+	resp, err := parseScramResponse(challenge.Text())
+	require.Nil(t, err)
+	salt, _ := base64.StdEncoding.DecodeString(resp["s"])
+	iterations, _ := strconv.Atoi(resp["i"])
+
+	cBytes := base64.StdEncoding.EncodeToString([]byte(gs2Header))
+	res := computeScramAuthResult(authr.tp, clientInitialMessage, string(srvInitialMessage), resp["r"], cBytes, password, salt, iterations)
+
+	response := xmpp.NewElementNamespace("response", saslNamespace)
+	response.SetText(base64.StdEncoding.EncodeToString([]byte(res.clientFinalMessage)))
+	if err := authr.ProcessElement(response); err != nil {
+		return err
+	}
+	success := testStrm.FetchElement()
+	require.Equal(t, "success", success.Name())
+	vb64, _ := base64.StdEncoding.DecodeString(success.Text())
+	require.Equal(t, res.v, string(vb64))
+	return nil
+}
+
+func processScramTestCase(t *testing.T, tc *scramAuthTestCase, user *model.User) error {
 	tr := &fakeTransport{}
 	if tc.usesCb {
 		tr.cbBytes = tc.cbBytes
 	}
-	testStrm := authTestSetup(&model.User{Username: "ortuman", Password: "1234"})
+	testStrm := authTestSetup(user)
 	defer authTestTeardown()
 
 	authr := NewScram(testStrm, tr, tc.scramType, tc.usesCb)
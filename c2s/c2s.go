@@ -8,6 +8,7 @@ package c2s
 import (
 	"sync"
 
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/log"
 	"github.com/pkg/errors"
 )
@@ -25,6 +26,8 @@ const (
 	sessionNamespace          = "urn:ietf:params:xml:ns:xmpp-session"
 	saslNamespace             = "urn:ietf:params:xml:ns:xmpp-sasl"
 	blockedErrorNamespace     = "urn:xmpp:blocking:errors"
+	smNamespace               = "urn:xmpp:sm:3"
+	csiNamespace              = "urn:xmpp:csi:0"
 )
 
 var (
@@ -81,7 +84,27 @@ func Shutdown() {
 
 func initializeServer(cfg *Config) (*server, error) {
 	srv := &server{cfg: cfg}
+	if lc := cfg.SASL.Lockout; lc.MaxIPFailures > 0 || lc.MaxUserFailures > 0 {
+		srv.authLimiter = newAuthLimiter(lc.Window, lc.Duration)
+	}
+	for _, mechanism := range cfg.SASL.Mechanisms {
+		if !isBackendMechanism(mechanism) {
+			log.Warnf("%s SASL mechanism disabled: not supported by authentication backend", mechanism)
+		}
+	}
 	servers[cfg.ID] = srv
 	go srv.start()
 	return srv, nil
 }
+
+// isBackendMechanism returns whether or not a SASL mechanism can be
+// verified by the configured authentication backend. External backends
+// are only able to check plaintext passwords, so mechanisms relying on
+// locally stored credentials are not offered along with them.
+func isBackendMechanism(mechanism string) bool {
+	switch mechanism {
+	case "plain", "anonymous":
+		return true
+	}
+	return auth.IsLocal()
+}
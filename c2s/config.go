@@ -6,20 +6,34 @@
 package c2s
 
 import (
+	"crypto/x509"
 	"fmt"
 	"strings"
 	"time"
 
+	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/transport/compress"
+	"github.com/ortuman/jackal/util"
 )
 
 const (
-	defaultTransportConnectTimeout = time.Duration(5) * time.Second
-	defaultTransportMaxStanzaSize  = 32768
-	defaultTransportPort           = 5222
-	defaultTransportKeepAlive      = time.Duration(120) * time.Second
-	defaultTransportURLPath        = "/xmpp/ws"
+	defaultTransportConnectTimeout  = time.Duration(5) * time.Second
+	defaultTransportMaxStanzaSize   = 32768
+	defaultTransportPort            = 5222
+	defaultTransportKeepAlive       = time.Duration(120) * time.Second
+	defaultTransportURLPath         = "/xmpp/ws"
+	defaultBOSHURLPath              = "/http-bind"
+	defaultBOSHWait                 = time.Duration(60) * time.Second
+	defaultBOSHHold                 = 1
+	defaultBOSHInactivity           = time.Duration(60) * time.Second
+	defaultBOSHPolling              = time.Duration(5) * time.Second
+	defaultResumeTimeout            = time.Duration(300) * time.Second
+	defaultLockoutMaxStreamFailures = 3
+	defaultLockoutMaxIPFailures     = 20
+	defaultLockoutMaxUserFailures   = 5
+	defaultLockoutWindow            = time.Duration(300) * time.Second
+	defaultLockoutDuration          = time.Duration(900) * time.Second
 )
 
 // ResourceConflictPolicy represents a resource conflict policy.
@@ -66,6 +80,163 @@ func (c *CompressConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	return nil
 }
 
+// StreamManagementConfig represents a server Stream Management (XEP-0198) configuration.
+type StreamManagementConfig struct {
+	Enabled       bool
+	ResumeTimeout time.Duration
+}
+
+type streamManagementProxyType struct {
+	Enabled       bool `yaml:"enabled"`
+	ResumeTimeout int  `yaml:"resume_timeout"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (sm *StreamManagementConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := streamManagementProxyType{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if p.ResumeTimeout < 0 {
+		return fmt.Errorf("c2s.StreamManagementConfig: resume timeout must be 0 or higher")
+	}
+	sm.Enabled = p.Enabled
+	sm.ResumeTimeout = time.Duration(p.ResumeTimeout) * time.Second
+	if sm.ResumeTimeout == 0 {
+		sm.ResumeTimeout = defaultResumeTimeout
+	}
+	return nil
+}
+
+// SASLConfig represents a server SASL authentication configuration.
+type SASLConfig struct {
+	Mechanisms []string
+	External   ExternalAuthConfig
+	Anonymous  AnonymousAuthConfig
+	Lockout    LockoutConfig
+}
+
+// ExternalAuthConfig represents a SASL EXTERNAL (XEP-0178) configuration.
+type ExternalAuthConfig struct {
+	ClientCAs *x509.CertPool
+}
+
+// AnonymousAuthConfig represents a SASL ANONYMOUS configuration.
+type AnonymousAuthConfig struct {
+	Host string `yaml:"host"`
+}
+
+// LockoutConfig represents a SASL brute-force protection configuration.
+// A zero failure limit disables its corresponding check.
+type LockoutConfig struct {
+	MaxStreamFailures int
+	MaxIPFailures     int
+	MaxUserFailures   int
+	Window            time.Duration
+	Duration          time.Duration
+}
+
+type lockoutProxyType struct {
+	MaxStreamFailures *int `yaml:"max_stream_failures"`
+	MaxIPFailures     *int `yaml:"max_ip_failures"`
+	MaxUserFailures   *int `yaml:"max_user_failures"`
+	Window            int  `yaml:"window"`
+	Duration          int  `yaml:"duration"`
+}
+
+type externalAuthProxyType struct {
+	CAFile string `yaml:"ca_path"`
+}
+
+type saslProxyType struct {
+	Mechanisms []string              `yaml:"mechanisms"`
+	External   externalAuthProxyType `yaml:"external"`
+	Anonymous  AnonymousAuthConfig   `yaml:"anonymous"`
+	Lockout    lockoutProxyType      `yaml:"lockout"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *SASLConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := saslProxyType{}
+
+	// a plain list of mechanisms is also accepted
+	if err := unmarshal(&p.Mechanisms); err != nil {
+		if err := unmarshal(&p); err != nil {
+			return err
+		}
+	}
+	// validate SASL mechanisms
+	var mechanisms []string
+	for _, sasl := range p.Mechanisms {
+		switch sasl {
+		case "plain", "scram_sha_1", "scram_sha_256":
+		case "digest_md5":
+			// DIGEST-MD5 requires plaintext passwords, which are no longer stored
+			log.Warnf("%s SASL mechanism disabled: no longer supported", sasl)
+			continue
+		case "external":
+			if len(p.External.CAFile) == 0 {
+				return fmt.Errorf("c2s.SASLConfig: external mechanism requires a CA bundle")
+			}
+			pool, err := util.LoadCertPool(p.External.CAFile)
+			if err != nil {
+				return err
+			}
+			c.External.ClientCAs = pool
+		case "anonymous":
+			if len(p.Anonymous.Host) == 0 {
+				return fmt.Errorf("c2s.SASLConfig: anonymous mechanism requires a host")
+			}
+			c.Anonymous = p.Anonymous
+		default:
+			return fmt.Errorf("c2s.SASLConfig: unrecognized SASL mechanism: %s", sasl)
+		}
+		mechanisms = append(mechanisms, sasl)
+	}
+	c.Mechanisms = mechanisms
+
+	// brute-force protection
+	lp := p.Lockout
+	for _, v := range []*int{lp.MaxStreamFailures, lp.MaxIPFailures, lp.MaxUserFailures} {
+		if v != nil && *v < 0 {
+			return fmt.Errorf("c2s.SASLConfig: lockout failure limits must be 0 or higher")
+		}
+	}
+	if lp.Window < 0 || lp.Duration < 0 {
+		return fmt.Errorf("c2s.SASLConfig: lockout window and duration must be 0 or higher")
+	}
+	c.Lockout = LockoutConfig{
+		MaxStreamFailures: defaultLockoutMaxStreamFailures,
+		MaxIPFailures:     defaultLockoutMaxIPFailures,
+		MaxUserFailures:   defaultLockoutMaxUserFailures,
+		Window:            time.Duration(lp.Window) * time.Second,
+		Duration:          time.Duration(lp.Duration) * time.Second,
+	}
+	if lp.MaxStreamFailures != nil {
+		c.Lockout.MaxStreamFailures = *lp.MaxStreamFailures
+	}
+	if lp.MaxIPFailures != nil {
+		c.Lockout.MaxIPFailures = *lp.MaxIPFailures
+	}
+	if lp.MaxUserFailures != nil {
+		c.Lockout.MaxUserFailures = *lp.MaxUserFailures
+	}
+	if c.Lockout.Window == 0 {
+		c.Lockout.Window = defaultLockoutWindow
+	}
+	if c.Lockout.Duration == 0 {
+		c.Lockout.Duration = defaultLockoutDuration
+	}
+	return nil
+}
+
+type boshProxyType struct {
+	Wait       int `yaml:"wait"`
+	Hold       int `yaml:"hold"`
+	Inactivity int `yaml:"inactivity"`
+	Polling    int `yaml:"polling"`
+}
+
 // TransportConfig represents an XMPP stream transport configuration.
 type TransportConfig struct {
 	Type        transport.TransportType
@@ -73,14 +244,18 @@ type TransportConfig struct {
 	Port        int
 	KeepAlive   time.Duration
 	URLPath     string
+	BOSH        transport.BOSHConfig
+	DirectTLS   bool
 }
 
 type transportProxyType struct {
-	Type        string `yaml:"type"`
-	BindAddress string `yaml:"bind_addr"`
-	Port        int    `yaml:"port"`
-	KeepAlive   int    `yaml:"keep_alive"`
-	URLPath     string `yaml:"url_path"`
+	Type        string        `yaml:"type"`
+	BindAddress string        `yaml:"bind_addr"`
+	Port        int           `yaml:"port"`
+	KeepAlive   int           `yaml:"keep_alive"`
+	URLPath     string        `yaml:"url_path"`
+	BOSH        boshProxyType `yaml:"bosh"`
+	DirectTLS   bool          `yaml:"direct_tls"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -97,15 +272,49 @@ func (t *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	case "websocket":
 		t.Type = transport.WebSocket
 
+	case "bosh":
+		t.Type = transport.BOSH
+
 	default:
 		return fmt.Errorf("c2s.TransportConfig: unrecognized transport type: %s", p.Type)
 	}
+	if p.DirectTLS && t.Type != transport.Socket {
+		return fmt.Errorf("c2s.TransportConfig: direct TLS is only supported by socket transport")
+	}
 	t.BindAddress = p.BindAddress
 	t.Port = p.Port
+	t.DirectTLS = p.DirectTLS
 
 	t.URLPath = p.URLPath
 	if len(t.URLPath) == 0 {
-		t.URLPath = defaultTransportURLPath
+		if t.Type == transport.BOSH {
+			t.URLPath = defaultBOSHURLPath
+		} else {
+			t.URLPath = defaultTransportURLPath
+		}
+	}
+	if t.Type == transport.BOSH {
+		if p.BOSH.Wait < 0 || p.BOSH.Hold < 0 || p.BOSH.Inactivity < 0 || p.BOSH.Polling < 0 {
+			return fmt.Errorf("c2s.TransportConfig: BOSH values must be 0 or higher")
+		}
+		t.BOSH = transport.BOSHConfig{
+			Wait:       time.Duration(p.BOSH.Wait) * time.Second,
+			Hold:       p.BOSH.Hold,
+			Inactivity: time.Duration(p.BOSH.Inactivity) * time.Second,
+			Polling:    time.Duration(p.BOSH.Polling) * time.Second,
+		}
+		if t.BOSH.Wait == 0 {
+			t.BOSH.Wait = defaultBOSHWait
+		}
+		if t.BOSH.Hold == 0 {
+			t.BOSH.Hold = defaultBOSHHold
+		}
+		if t.BOSH.Inactivity == 0 {
+			t.BOSH.Inactivity = defaultBOSHInactivity
+		}
+		if t.BOSH.Polling == 0 {
+			t.BOSH.Polling = defaultBOSHPolling
+		}
 	}
 
 	// assign transport's defaults
@@ -132,20 +341,22 @@ type Config struct {
 	MaxStanzaSize    int
 	ResourceConflict ResourceConflictPolicy
 	Transport        TransportConfig
-	SASL             []string
+	SASL             SASLConfig
 	Compression      CompressConfig
+	StreamManagement StreamManagementConfig
 }
 
 type configProxy struct {
-	ID               string          `yaml:"id"`
-	Domain           string          `yaml:"domain"`
-	TLS              TLSConfig       `yaml:"tls"`
-	ConnectTimeout   int             `yaml:"connect_timeout"`
-	MaxStanzaSize    int             `yaml:"max_stanza_size"`
-	ResourceConflict string          `yaml:"resource_conflict"`
-	Transport        TransportConfig `yaml:"transport"`
-	SASL             []string        `yaml:"sasl"`
-	Compression      CompressConfig  `yaml:"compression"`
+	ID               string                 `yaml:"id"`
+	Domain           string                 `yaml:"domain"`
+	TLS              TLSConfig              `yaml:"tls"`
+	ConnectTimeout   int                    `yaml:"connect_timeout"`
+	MaxStanzaSize    int                    `yaml:"max_stanza_size"`
+	ResourceConflict string                 `yaml:"resource_conflict"`
+	Transport        TransportConfig        `yaml:"transport"`
+	SASL             SASLConfig             `yaml:"sasl"`
+	Compression      CompressConfig         `yaml:"compression"`
+	StreamManagement StreamManagementConfig `yaml:"stream_management"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -176,18 +387,10 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	default:
 		return fmt.Errorf("c2s.Config: invalid resource_conflict option: %s", rc)
 	}
-	// validate SASL mechanisms
-	for _, sasl := range p.SASL {
-		switch sasl {
-		case "plain", "digest_md5", "scram_sha_1", "scram_sha_256":
-			continue
-		default:
-			return fmt.Errorf("c2s.Config: unrecognized SASL mechanism: %s", sasl)
-		}
-	}
 	cfg.Transport = p.Transport
 	cfg.SASL = p.SASL
 	cfg.Compression = p.Compression
+	cfg.StreamManagement = p.StreamManagement
 	return nil
 }
 
@@ -196,6 +399,9 @@ type streamConfig struct {
 	connectTimeout   time.Duration
 	maxStanzaSize    int
 	resourceConflict ResourceConflictPolicy
-	sasl             []string
+	sasl             SASLConfig
 	compression      CompressConfig
+	sm               StreamManagementConfig
+	directTLS        bool
+	authLimiter      *authLimiter
 }
@@ -37,6 +37,24 @@ func TestCompressionConfig(t *testing.T) {
 	require.NotNil(t, err)
 }
 
+func TestStreamManagementConfig(t *testing.T) {
+	sm := StreamManagementConfig{}
+	err := yaml.Unmarshal([]byte("{enabled: true, resume_timeout: 60}"), &sm)
+	require.Nil(t, err)
+	require.True(t, sm.Enabled)
+	require.Equal(t, time.Second*time.Duration(60), sm.ResumeTimeout)
+
+	err = yaml.Unmarshal([]byte("{enabled: true}"), &sm)
+	require.Nil(t, err)
+	require.Equal(t, defaultResumeTimeout, sm.ResumeTimeout)
+
+	err = yaml.Unmarshal([]byte("{enabled: true, resume_timeout: -1}"), &sm)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("enabled"), &sm)
+	require.NotNil(t, err)
+}
+
 func TestTransportConfig(t *testing.T) {
 	s := TransportConfig{}
 
@@ -54,6 +72,28 @@ func TestTransportConfig(t *testing.T) {
 	require.Equal(t, transport.WebSocket, s.Type)
 	require.Equal(t, 5222, s.Port)
 	require.Equal(t, time.Second*time.Duration(120), s.KeepAlive)
+
+	s = TransportConfig{}
+	err = yaml.Unmarshal([]byte("{type: bosh, bosh: {wait: 30, inactivity: 90}}"), &s)
+	require.Nil(t, err)
+
+	require.Equal(t, transport.BOSH, s.Type)
+	require.Equal(t, "/http-bind", s.URLPath)
+	require.Equal(t, time.Second*time.Duration(30), s.BOSH.Wait)
+	require.Equal(t, defaultBOSHHold, s.BOSH.Hold)
+	require.Equal(t, time.Second*time.Duration(90), s.BOSH.Inactivity)
+	require.Equal(t, defaultBOSHPolling, s.BOSH.Polling)
+
+	err = yaml.Unmarshal([]byte("{type: bosh, bosh: {hold: -1}}"), &s)
+	require.NotNil(t, err)
+
+	s = TransportConfig{}
+	err = yaml.Unmarshal([]byte("{type: socket, port: 5223, direct_tls: true}"), &s)
+	require.Nil(t, err)
+	require.True(t, s.DirectTLS)
+
+	err = yaml.Unmarshal([]byte("{type: websocket, direct_tls: true}"), &s)
+	require.NotNil(t, err)
 }
 
 func TestConfig(t *testing.T) {
@@ -76,16 +116,82 @@ func TestConfig(t *testing.T) {
 	authCfg := `
 connect_timeout: 5
 resource_conflict: reject
-sasl: [plain, digest_md5, scram_sha_1, scram_sha_256]
+sasl: [plain, scram_sha_1, scram_sha_256]
 `
 	err = yaml.Unmarshal([]byte(authCfg), &s)
 	require.Nil(t, err)
-	require.Equal(t, 4, len(s.SASL))
+	require.Equal(t, 3, len(s.SASL.Mechanisms))
+
+	// unsupported digest_md5 mechanism is skipped...
+	s = Config{}
+	err = yaml.Unmarshal([]byte("{sasl: [plain, digest_md5]}"), &s)
+	require.Nil(t, err)
+	require.Equal(t, []string{"plain"}, s.SASL.Mechanisms)
 
 	// invalid auth mechanism...
 	err = yaml.Unmarshal([]byte("{id: default, type: c2s, sasl: [invalid]}"), &s)
 	require.NotNil(t, err)
 
+	// external auth mechanism...
+	authCfg = `
+sasl:
+  mechanisms: [plain, external]
+  external:
+    ca_path: ../testdata/cert/test.server.crt
+`
+	err = yaml.Unmarshal([]byte(authCfg), &s)
+	require.Nil(t, err)
+	require.Equal(t, []string{"plain", "external"}, s.SASL.Mechanisms)
+	require.NotNil(t, s.SASL.External.ClientCAs)
+
+	err = yaml.Unmarshal([]byte("{sasl: {mechanisms: [external]}}"), &s)
+	require.NotNil(t, err)
+	require.Equal(t, "c2s.SASLConfig: external mechanism requires a CA bundle", err.Error())
+
+	// anonymous auth mechanism...
+	err = yaml.Unmarshal([]byte("{sasl: {mechanisms: [anonymous], anonymous: {host: guest.jackal.im}}}"), &s)
+	require.Nil(t, err)
+	require.Equal(t, "guest.jackal.im", s.SASL.Anonymous.Host)
+
+	err = yaml.Unmarshal([]byte("{sasl: {mechanisms: [anonymous]}}"), &s)
+	require.NotNil(t, err)
+	require.Equal(t, "c2s.SASLConfig: anonymous mechanism requires a host", err.Error())
+
+	// brute-force protection...
+	err = yaml.Unmarshal([]byte("{sasl: [plain]}"), &s)
+	require.Nil(t, err)
+	require.Equal(t, defaultLockoutMaxStreamFailures, s.SASL.Lockout.MaxStreamFailures)
+	require.Equal(t, defaultLockoutMaxIPFailures, s.SASL.Lockout.MaxIPFailures)
+	require.Equal(t, defaultLockoutMaxUserFailures, s.SASL.Lockout.MaxUserFailures)
+	require.Equal(t, defaultLockoutWindow, s.SASL.Lockout.Window)
+	require.Equal(t, defaultLockoutDuration, s.SASL.Lockout.Duration)
+
+	authCfg = `
+sasl:
+  mechanisms: [plain]
+  lockout:
+    max_stream_failures: 2
+    max_ip_failures: 0
+    max_user_failures: 10
+    window: 60
+    duration: 120
+`
+	err = yaml.Unmarshal([]byte(authCfg), &s)
+	require.Nil(t, err)
+	require.Equal(t, LockoutConfig{
+		MaxStreamFailures: 2,
+		MaxIPFailures:     0,
+		MaxUserFailures:   10,
+		Window:            time.Minute,
+		Duration:          time.Minute * 2,
+	}, s.SASL.Lockout)
+
+	err = yaml.Unmarshal([]byte("{sasl: {mechanisms: [plain], lockout: {max_user_failures: -1}}}"), &s)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte("{sasl: {mechanisms: [plain], lockout: {window: -1}}}"), &s)
+	require.NotNil(t, err)
+
 	// invalid yaml
 	err = yaml.Unmarshal([]byte("type"), &s)
 	require.NotNil(t, err)
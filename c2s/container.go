@@ -9,14 +9,26 @@ import (
 
 var inContainer inMap
 
-type inMap struct{ m sync.Map }
+type inMap struct {
+	mu sync.Mutex
+	m  sync.Map
+}
 
 func (m *inMap) set(stm stream.C2S) {
-	m.m.Store(stm.ID(), stm)
+	m.mu.Lock()
+	if _, loaded := m.m.LoadOrStore(stm.ID(), stm); !loaded {
+		streamCount.Inc()
+	}
+	m.mu.Unlock()
 	log.Infof("registered c2s stream... (id: %s)", stm.ID())
 }
 
 func (m *inMap) delete(stm stream.C2S) {
-	m.m.Delete(stm.ID())
+	m.mu.Lock()
+	if _, ok := m.m.Load(stm.ID()); ok {
+		m.m.Delete(stm.ID())
+		streamCount.Dec()
+	}
+	m.mu.Unlock()
 	log.Infof("unregistered c2s stream... (id: %s)", stm.ID())
 }
@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+const csiMaxQueuedStanzas = 256
+
+const forwardNamespace = "urn:xmpp:forward:0"
+
+// csiState holds client state indication (XEP-0352) state of a c2s stream.
+type csiState struct {
+	inactive  bool
+	queue     []xmpp.XElement
+	presences map[string]int
+}
+
+func (s *inStream) handleClientState(elem xmpp.XElement) {
+	if len(s.Resource()) == 0 {
+		s.disconnectWithStreamError(streamerror.ErrNotAuthorized)
+		return
+	}
+	switch elem.Name() {
+	case "active":
+		s.csi.inactive = false
+		s.flushClientStateQueue()
+
+	case "inactive":
+		s.csi.inactive = true
+
+	default:
+		s.disconnectWithStreamError(streamerror.ErrUnsupportedStanzaType)
+	}
+}
+
+func (s *inStream) sendElement(elem xmpp.XElement) {
+	if !s.csi.inactive {
+		s.writeElement(elem)
+		return
+	}
+	switch stanza := elem.(type) {
+	case *xmpp.Presence:
+		s.queuePresence(stanza)
+
+	case *xmpp.Message:
+		if isUrgentMessage(stanza) {
+			// urgent message... deliver everything held so far
+			s.flushClientStateQueue()
+			s.writeElement(stanza)
+			return
+		}
+		s.queueClientStateElement(stanza)
+
+	default:
+		s.writeElement(elem)
+	}
+}
+
+// isUrgentMessage returns whether or not a message should be delivered
+// right away to an inactive client, either because it has a body or it
+// forwards one (carbon copies, archived messages...).
+func isUrgentMessage(message *xmpp.Message) bool {
+	if message.IsMessageWithBody() {
+		return true
+	}
+	for _, elem := range message.Elements().All() {
+		forwarded := elem.Elements().ChildNamespace("forwarded", forwardNamespace)
+		if forwarded == nil {
+			continue
+		}
+		if fwdMsg := forwarded.Elements().Child("message"); fwdMsg != nil && fwdMsg.Elements().Child("body") != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *inStream) queuePresence(presence *xmpp.Presence) {
+	from := presence.FromJID().String()
+
+	// only latest presence update per contact is worth delivering
+	if i, ok := s.csi.presences[from]; ok {
+		s.csi.queue[i] = nil
+	}
+	if s.csi.presences == nil {
+		s.csi.presences = make(map[string]int)
+	}
+	s.csi.presences[from] = len(s.csi.queue)
+	s.queueClientStateElement(presence)
+}
+
+func (s *inStream) queueClientStateElement(elem xmpp.XElement) {
+	s.csi.queue = append(s.csi.queue, elem)
+	if len(s.csi.queue) > csiMaxQueuedStanzas {
+		s.flushClientStateQueue()
+	}
+}
+
+func (s *inStream) flushClientStateQueue() {
+	if len(s.csi.queue) == 0 {
+		return
+	}
+	queue := s.csi.queue
+	s.csi.queue = nil
+	s.csi.presences = nil
+
+	var n int
+	for _, elem := range queue {
+		if elem != nil {
+			s.writeElement(elem)
+			n++
+		}
+	}
+	log.Debugf("flushed %d client state queued stanzas... id: %s", n, s.id)
+}
+
+func (s *inStream) discardClientStateQueue() {
+	if len(s.csi.queue) == 0 {
+		return
+	}
+	bounceUndeliveredStanzas(s.csi.queue)
+	s.csi.queue = nil
+	s.csi.presences = nil
+}
@@ -0,0 +1,227 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/offline"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_ClientStateIndication(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "localhost"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+
+	stm, conn := tUtilStreamInit()
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	elem := conn.outboundRead()
+	require.Equal(t, "stream:features", elem.Name())
+	require.NotNil(t, elem.Elements().ChildNamespace("csi", csiNamespace))
+
+	tUtilStreamStartSession(conn, t)
+
+	conn.inboundWrite([]byte(`<inactive xmlns="urn:xmpp:csi:0"/>`))
+	time.Sleep(time.Millisecond * 100) // wait until processed...
+
+	// presence updates are deduplicated per contact
+	romeo, _ := jid.New("romeo", "localhost", "garden", true)
+	juliet, _ := jid.New("juliet", "localhost", "balcony", true)
+
+	p1 := tUtilCSIPresence(romeo, stm.JID(), "away")
+	p2 := tUtilCSIPresence(juliet, stm.JID(), "away")
+	p3 := tUtilCSIPresence(romeo, stm.JID(), "dnd")
+	stm.SendElement(p1)
+	stm.SendElement(p2)
+	stm.SendElement(p3)
+
+	// chat states aren't urgent
+	composing := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	composing.SetFromJID(juliet)
+	composing.SetToJID(stm.JID())
+	composing.AppendElement(xmpp.NewElementNamespace("composing", "http://jabber.org/protocol/chatstates"))
+	stm.SendElement(composing)
+
+	require.Equal(t, 4, tUtilCSIQueueLen(stm))
+
+	// urgent message flushes queued stanzas
+	msg := tUtilSMMessage(stm.JID())
+	stm.SendElement(msg)
+
+	elem = conn.outboundRead()
+	require.Equal(t, p2.ID(), elem.ID())
+	elem = conn.outboundRead()
+	require.Equal(t, p3.ID(), elem.ID())
+	elem = conn.outboundRead()
+	require.Equal(t, composing.ID(), elem.ID())
+	elem = conn.outboundRead()
+	require.Equal(t, msg.ID(), elem.ID())
+
+	// going active flushes queued stanzas
+	p4 := tUtilCSIPresence(romeo, stm.JID(), "xa")
+	stm.SendElement(p4)
+
+	require.Equal(t, 1, tUtilCSIQueueLen(stm))
+
+	conn.inboundWrite([]byte(`<active xmlns="urn:xmpp:csi:0"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, p4.ID(), elem.ID())
+
+	p5 := tUtilCSIPresence(juliet, stm.JID(), "chat")
+	stm.SendElement(p5)
+	elem = conn.outboundRead()
+	require.Equal(t, p5.ID(), elem.ID())
+	require.Equal(t, 0, tUtilCSIQueueLen(stm))
+
+	// carbon copies are as urgent as their forwarded message
+	conn.inboundWrite([]byte(`<inactive xmlns="urn:xmpp:csi:0"/>`))
+	time.Sleep(time.Millisecond * 100) // wait until processed...
+
+	composingCarbon := tUtilCSICarbonCopy(composing, stm.JID())
+	stm.SendElement(composingCarbon)
+	require.Equal(t, 1, tUtilCSIQueueLen(stm))
+
+	carbon := tUtilCSICarbonCopy(tUtilSMMessage(stm.JID()), stm.JID())
+	stm.SendElement(carbon)
+
+	elem = conn.outboundRead()
+	require.Equal(t, composingCarbon.ID(), elem.ID())
+	elem = conn.outboundRead()
+	require.Equal(t, carbon.ID(), elem.ID())
+	require.Equal(t, 0, tUtilCSIQueueLen(stm))
+}
+
+func TestStream_ClientStateIndicationDisconnect(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "localhost"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(&module.Config{
+		Enabled: map[string]struct{}{"offline": {}},
+		Offline: offline.Config{QueueSize: 10},
+	})
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+
+	// detached stream holds queued stanzas until resumption
+	stm, conn := tUtilSMStreamInit("abcd1234")
+	tUtilSMStreamEnable(conn, t)
+
+	conn.inboundWrite([]byte(`<inactive xmlns="urn:xmpp:csi:0"/>`))
+	time.Sleep(time.Millisecond * 100) // wait until processed...
+
+	stm.SendElement(tUtilCSIChatState(stm.JID()))
+	require.Equal(t, 1, tUtilCSIQueueLen(stm))
+
+	conn.Close()
+	time.Sleep(time.Millisecond * 100) // wait until stream is detached...
+	require.Equal(t, detached, stm.getState())
+	require.Equal(t, 0, tUtilCSIQueueLen(stm))
+	require.Equal(t, 1, tUtilSMUnackedLen(stm))
+	stm.Disconnect(nil)
+
+	// disconnected stream stores queued messages offline
+	storage.Instance().DeleteOfflineMessages("user")
+
+	stm, conn = tUtilStreamInit()
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamStartSession(conn, t)
+
+	conn.inboundWrite([]byte(`<inactive xmlns="urn:xmpp:csi:0"/>`))
+	time.Sleep(time.Millisecond * 100) // wait until processed...
+
+	// body-less normal messages are stored offline as well
+	receipt := xmpp.NewMessageType(uuid.New(), xmpp.NormalType)
+	receipt.SetFromJID(stm.JID().ToBareJID())
+	receipt.SetToJID(stm.JID())
+	receipt.AppendElement(xmpp.NewElementNamespace("received", "urn:xmpp:receipts"))
+	stm.SendElement(receipt)
+	stm.SendElement(tUtilCSIChatState(stm.JID()))
+	require.Equal(t, 2, tUtilCSIQueueLen(stm))
+
+	stm.Disconnect(nil)
+	require.True(t, conn.waitClose())
+	time.Sleep(time.Millisecond * 100) // wait until archived...
+
+	cnt, err := storage.Instance().CountOfflineMessages("user")
+	require.Nil(t, err)
+	require.Equal(t, 1, cnt)
+}
+
+func tUtilCSIPresence(from, to *jid.JID, show string) *xmpp.Presence {
+	p := xmpp.NewPresence(from, to, xmpp.AvailableType)
+	p.SetID(uuid.New())
+	showElem := xmpp.NewElementName("show")
+	showElem.SetText(show)
+	p.AppendElement(showElem)
+	return p
+}
+
+func tUtilCSIChatState(to *jid.JID) *xmpp.Message {
+	from, _ := jid.New("juliet", "localhost", "balcony", true)
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	msg.AppendElement(xmpp.NewElementNamespace("composing", "http://jabber.org/protocol/chatstates"))
+	return msg
+}
+
+func tUtilCSICarbonCopy(message *xmpp.Message, to *jid.JID) *xmpp.Message {
+	forwarded := xmpp.NewElementNamespace("forwarded", forwardNamespace)
+	forwarded.AppendElement(message)
+	received := xmpp.NewElementNamespace("received", "urn:xmpp:carbons:2")
+	received.AppendElement(forwarded)
+
+	msg := xmpp.NewMessageType(uuid.New(), message.Type())
+	msg.SetFromJID(to.ToBareJID())
+	msg.SetToJID(to)
+	msg.AppendElement(received)
+	return msg
+}
+
+func tUtilCSIQueueLen(stm *inStream) int {
+	lenCh := make(chan int, 1)
+	stm.actorCh <- func() { lenCh <- len(stm.csi.queue) }
+	return <-lenCh
+}
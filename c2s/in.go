@@ -19,6 +19,7 @@ import (
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/session"
+	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/transport/compress"
@@ -33,6 +34,7 @@ const (
 	authenticating
 	authenticated
 	sessionStarted
+	detached
 	disconnected
 )
 
@@ -45,8 +47,14 @@ type inStream struct {
 	ctx            *stream.Context
 	authenticators []auth.Authenticator
 	activeAuth     auth.Authenticator
+	anonymous      bool
+	authUsername   string
+	authFailures   int
 	actorCh        chan func()
+	doneCh         chan struct{}
 	iqResultCh     chan xmpp.Stanza
+	sm             *smState
+	csi            csiState
 
 	mu            sync.RWMutex
 	jid           *jid.JID
@@ -62,12 +70,13 @@ func newStream(id string, cfg *streamConfig) stream.C2S {
 		id:         id,
 		ctx:        stream.NewContext(),
 		actorCh:    make(chan func(), streamMailboxSize),
+		doneCh:     make(chan struct{}),
 		iqResultCh: make(chan xmpp.Stanza, iqResultMailboxSize),
 	}
 	inContainer.set(s)
 
 	// initialize stream context
-	secured := !(cfg.transport.Type() == transport.Socket)
+	secured := !(cfg.transport.Type() == transport.Socket) || cfg.directTLS
 	s.setSecured(secured)
 	s.setJID(&jid.JID{})
 
@@ -81,7 +90,7 @@ func newStream(id string, cfg *streamConfig) stream.C2S {
 		s.connectTm = time.AfterFunc(cfg.connectTimeout, s.connectTimeout)
 	}
 	go s.loop()
-	go s.doRead() // start reading...
+	go s.doRead(s.sess) // start reading...
 
 	return s
 }
@@ -154,7 +163,7 @@ func (s *inStream) SendElement(elem xmpp.XElement) {
 	if s.getState() == disconnected {
 		return
 	}
-	s.actorCh <- func() { s.writeElement(elem) }
+	s.actorCh <- func() { s.sendElement(elem) }
 }
 
 // Disconnect disconnects remote peer by closing
@@ -174,14 +183,14 @@ func (s *inStream) Disconnect(err error) {
 func (s *inStream) initializeAuthenticators() {
 	tr := s.cfg.transport
 	var authenticators []auth.Authenticator
-	for _, a := range s.cfg.sasl {
+	for _, a := range s.cfg.sasl.Mechanisms {
+		if !isBackendMechanism(a) {
+			continue
+		}
 		switch a {
 		case "plain":
 			authenticators = append(authenticators, auth.NewPlain(s))
 
-		case "digest_md5":
-			authenticators = append(authenticators, auth.NewDigestMD5(s))
-
 		case "scram_sha_1":
 			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA1, false))
 			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA1, true))
@@ -189,6 +198,12 @@ func (s *inStream) initializeAuthenticators() {
 		case "scram_sha_256":
 			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA256, false))
 			authenticators = append(authenticators, auth.NewScram(s, tr, auth.ScramSHA256, true))
+
+		case "external":
+			authenticators = append(authenticators, auth.NewExternal(s, tr, s.cfg.sasl.External.ClientCAs))
+
+		case "anonymous":
+			authenticators = append(authenticators, auth.NewAnonymous(s))
 		}
 	}
 	s.authenticators = authenticators
@@ -199,6 +214,9 @@ func (s *inStream) connectTimeout() {
 }
 
 func (s *inStream) handleElement(elem xmpp.XElement) {
+	if s.sm != nil && elem.IsStanza() {
+		s.sm.inH++
+	}
 	switch s.getState() {
 	case connecting:
 		s.handleConnecting(elem)
@@ -262,6 +280,9 @@ func (s *inStream) unauthenticatedFeatures() []xmpp.XElement {
 		mechanisms := xmpp.NewElementName("mechanisms")
 		mechanisms.SetNamespace(saslNamespace)
 		for _, athr := range s.authenticators {
+			if !s.isMechanismAvailable(athr) {
+				continue
+			}
 			mechanism := xmpp.NewElementName("mechanism")
 			mechanism.SetText(athr.Mechanism())
 			mechanisms.AppendElement(mechanism)
@@ -305,6 +326,10 @@ func (s *inStream) authenticatedFeatures() []xmpp.XElement {
 		ver := xmpp.NewElementNamespace("ver", "urn:xmpp:features:rosterver")
 		features = append(features, ver)
 	}
+	if s.cfg.sm.Enabled {
+		features = append(features, xmpp.NewElementNamespace("sm", smNamespace))
+	}
+	features = append(features, xmpp.NewElementNamespace("csi", csiNamespace))
 	return features
 }
 
@@ -348,9 +373,12 @@ func (s *inStream) handleAuthenticating(elem xmpp.XElement) {
 		return
 	}
 	authr := s.activeAuth
+	if s.checkAuthLockout(authr, elem) {
+		return
+	}
 	s.continueAuthentication(elem, authr)
 	if authr.Authenticated() {
-		s.finishAuthentication(authr.Username())
+		s.finishAuthentication(authr)
 	}
 }
 
@@ -371,6 +399,20 @@ func (s *inStream) handleAuthenticated(elem xmpp.XElement) {
 			s.startSession(iq)
 		}
 
+	case "enable", "resume", "r", "a":
+		if elem.Namespace() != smNamespace {
+			s.disconnectWithStreamError(streamerror.ErrUnsupportedStanzaType)
+			return
+		}
+		s.handleStreamManagement(elem)
+
+	case "active", "inactive":
+		if elem.Namespace() != csiNamespace {
+			s.disconnectWithStreamError(streamerror.ErrUnsupportedStanzaType)
+			return
+		}
+		s.handleClientState(elem)
+
 	default:
 		s.disconnectWithStreamError(streamerror.ErrUnsupportedStanzaType)
 	}
@@ -381,6 +423,14 @@ func (s *inStream) handleSessionStarted(elem xmpp.XElement) {
 	if p := module.Modules().Ping; p != nil {
 		p.SchedulePing(s)
 	}
+	switch elem.Namespace() {
+	case smNamespace:
+		s.handleStreamManagement(elem)
+		return
+	case csiNamespace:
+		s.handleClientState(elem)
+		return
+	}
 	stanza, ok := elem.(xmpp.Stanza)
 	if !ok {
 		s.disconnectWithStreamError(streamerror.ErrUnsupportedStanzaType)
@@ -414,7 +464,13 @@ func (s *inStream) proceedStartTLS(elem xmpp.XElement) {
 
 	s.writeElement(xmpp.NewElementNamespace("proceed", tlsNamespace))
 
-	s.cfg.transport.StartTLS(&tls.Config{Certificates: host.Certificates()}, false)
+	tlsCfg := &tls.Config{GetCertificate: host.GetCertificate(s.Domain())}
+	if cas := s.cfg.sasl.External.ClientCAs; cas != nil {
+		// request client certificate for EXTERNAL authentication
+		tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+		tlsCfg.ClientCAs = cas
+	}
+	s.cfg.transport.StartTLS(tlsCfg, false)
 
 	log.Infof("secured stream... id: %s", s.id)
 	s.restartSession()
@@ -454,14 +510,19 @@ func (s *inStream) startAuthentication(elem xmpp.XElement) {
 		s.disconnectWithStreamError(streamerror.ErrInvalidNamespace)
 		return
 	}
+	s.authUsername = ""
+
 	mechanism := elem.Attributes().Get("mechanism")
 	for _, authr := range s.authenticators {
-		if authr.Mechanism() == mechanism {
+		if authr.Mechanism() == mechanism && s.isMechanismAvailable(authr) {
+			if s.checkAuthLockout(authr, elem) {
+				return
+			}
 			if err := s.continueAuthentication(elem, authr); err != nil {
 				return
 			}
 			if authr.Authenticated() {
-				s.finishAuthentication(authr.Username())
+				s.finishAuthentication(authr)
 			} else {
 				s.activeAuth = authr
 				s.setState(authenticating)
@@ -477,8 +538,14 @@ func (s *inStream) startAuthentication(elem xmpp.XElement) {
 
 func (s *inStream) continueAuthentication(elem xmpp.XElement, authr auth.Authenticator) error {
 	err := authr.ProcessElement(elem)
+	if err != nil {
+		authCount.WithLabelValues(authr.Mechanism(), "failure").Inc()
+	} else if authr.Authenticated() {
+		authCount.WithLabelValues(authr.Mechanism(), "success").Inc()
+	}
 	if saslErr, ok := err.(*auth.SASLError); ok {
 		s.failAuthentication(saslErr.Element())
+		s.registerAuthFailure()
 	} else if err != nil {
 		log.Error(err)
 		s.failAuthentication(auth.ErrSASLTemporaryAuthFailure.(*auth.SASLError).Element())
@@ -486,7 +553,27 @@ func (s *inStream) continueAuthentication(elem xmpp.XElement, authr auth.Authent
 	return err
 }
 
-func (s *inStream) finishAuthentication(username string) {
+func (s *inStream) isMechanismAvailable(authr auth.Authenticator) bool {
+	switch authr.Mechanism() {
+	case "EXTERNAL":
+		// client certificate must have been presented
+		return len(s.cfg.transport.PeerCertificates()) > 0
+	case "ANONYMOUS":
+		return s.Domain() == s.cfg.sasl.Anonymous.Host
+	}
+	return true
+}
+
+func (s *inStream) finishAuthentication(authr auth.Authenticator) {
+	username := authr.Username()
+	s.anonymous = authr.Mechanism() == "ANONYMOUS"
+
+	if l := s.cfg.authLimiter; l != nil && len(s.authUsername) > 0 {
+		l.reset("user:" + s.authUsername)
+	}
+	s.authUsername = ""
+	s.authFailures = 0
+
 	if s.activeAuth != nil {
 		s.activeAuth.Reset()
 		s.activeAuth = nil
@@ -642,6 +729,12 @@ func (s *inStream) processPresence(presence *xmpp.Presence) {
 	if r := module.Modules().Roster; r != nil {
 		r.ProcessPresence(presence)
 	}
+	// discover entity capabilities
+	if replyOnBehalf && presence.IsAvailable() {
+		if caps := module.Modules().Caps; caps != nil {
+			caps.ProcessPresence(presence)
+		}
+	}
 	// deliver offline messages
 	if replyOnBehalf && presence.IsAvailable() && presence.Priority() >= 0 {
 		if off := module.Modules().Offline; off != nil {
@@ -657,12 +750,23 @@ sendMessage:
 	err := router.Route(message)
 	switch err {
 	case nil:
-		break
+		if mam := module.Modules().Mam; mam != nil {
+			mam.ArchiveMessage(message)
+		}
+		if carbons := module.Modules().Carbons; carbons != nil {
+			carbons.ProcessMessage(message)
+		}
 	case router.ErrResourceNotFound:
 		// treat the stanza as if it were addressed to <node@domain>
 		toJID = toJID.ToBareJID()
 		goto sendMessage
 	case router.ErrNotAuthenticated:
+		if mam := module.Modules().Mam; mam != nil {
+			mam.ArchiveMessage(message)
+		}
+		if carbons := module.Modules().Carbons; carbons != nil {
+			carbons.ProcessMessage(message)
+		}
 		if off := module.Modules().Offline; off != nil {
 			off.ArchiveMessage(message)
 			return
@@ -684,6 +788,7 @@ func (s *inStream) loop() {
 		case f := <-s.actorCh:
 			f()
 			if s.getState() == disconnected {
+				close(s.doneCh)
 				return
 			}
 		case resIQ := <-s.iqResultCh:
@@ -695,15 +800,18 @@ func (s *inStream) loop() {
 }
 
 // runs on it's own goroutine
-func (s *inStream) doRead() {
-	elem, sErr := s.sess.Receive()
+func (s *inStream) doRead(sess *session.Session) {
+	elem, sErr := sess.Receive()
 	if sErr == nil {
 		s.actorCh <- func() {
+			if sess != s.sess {
+				return // stale session
+			}
 			s.readElement(elem)
 		}
 	} else {
 		s.actorCh <- func() {
-			if s.getState() == disconnected {
+			if s.getState() == disconnected || sess != s.sess {
 				return
 			}
 			s.handleSessionError(sErr)
@@ -712,6 +820,10 @@ func (s *inStream) doRead() {
 }
 
 func (s *inStream) handleSessionError(sErr *session.Error) {
+	if s.isResumable(sErr.UnderlyingErr) {
+		s.detach()
+		return
+	}
 	switch err := sErr.UnderlyingErr.(type) {
 	case nil:
 		s.disconnect(nil)
@@ -735,7 +847,18 @@ func (s *inStream) writeStanzaErrorResponse(elem xmpp.XElement, stanzaErr *xmpp.
 }
 
 func (s *inStream) writeElement(elem xmpp.XElement) {
+	queue := s.sm != nil && elem.IsStanza()
+	if queue {
+		s.queueUnackedStanza(elem)
+	}
+	switch s.getState() {
+	case detached, disconnected:
+		return
+	}
 	s.sess.Send(elem)
+	if queue {
+		s.requestAck()
+	}
 }
 
 func (s *inStream) readElement(elem xmpp.XElement) {
@@ -743,7 +866,7 @@ func (s *inStream) readElement(elem xmpp.XElement) {
 		s.handleElement(elem)
 	}
 	if s.getState() != disconnected {
-		go s.doRead() // keep reading...
+		go s.doRead(s.sess) // keep reading...
 	}
 }
 
@@ -785,17 +908,74 @@ func (s *inStream) disconnectClosingSession(closeSession, unbind bool) {
 			r.ProcessPresence(xmpp.NewPresence(s.JID(), s.JID().ToBareJID(), xmpp.UnavailableType))
 		}
 	}
-	if closeSession {
+	isDetached := s.getState() == detached
+	if closeSession && !isDetached {
 		s.sess.Close()
 	}
 	// unregister stream
 	if unbind {
 		router.Unbind(s)
 	}
+	// client state queued stanzas won't be delivered anymore
+	s.discardClientStateQueue()
+
+	if s.sm != nil {
+		s.terminateStreamManagement()
+	}
+	// anonymous accounts only last as long as their session
+	if s.anonymous {
+		s.deleteAnonymousAccount()
+	}
 	inContainer.delete(s)
 
 	s.setState(disconnected)
-	s.cfg.transport.Close()
+	if !isDetached {
+		s.cfg.transport.Close()
+	}
+}
+
+func (s *inStream) deleteAnonymousAccount() {
+	username := s.Username()
+
+	// unsubscribe from roster contacts before removing stored data
+	if r := module.Modules().Roster; r != nil {
+		items, _, err := storage.Instance().FetchRosterItems(username)
+		if err != nil {
+			log.Error(err)
+		}
+		for _, itm := range items {
+			if err := r.RemoveItem(username, itm.JID); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+	if err := storage.DeleteUserData(username); err != nil {
+		log.Error(err)
+		return
+	}
+	log.Infof("deleted anonymous account: %s", username)
+}
+
+// bounceUndeliveredStanzas stores offline or bounces back
+// a set of stanzas that couldn't be delivered to the stream.
+func bounceUndeliveredStanzas(elems []xmpp.XElement) {
+	for _, elem := range elems {
+		switch stanza := elem.(type) {
+		case *xmpp.Message:
+			if stanza.IsError() {
+				continue
+			}
+			if off := module.Modules().Offline; off != nil {
+				off.ArchiveMessage(stanza)
+				continue
+			}
+			router.Route(stanza.ServiceUnavailableError())
+		case *xmpp.IQ:
+			if stanza.IsGet() || stanza.IsSet() {
+				router.Route(stanza.ServiceUnavailableError())
+			}
+		}
+	}
 }
 
 func (s *inStream) isBlockedJID(j *jid.JID) bool {
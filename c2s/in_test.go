@@ -9,8 +9,12 @@ import (
 	"testing"
 	"time"
 
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
@@ -20,6 +24,7 @@ import (
 	"github.com/ortuman/jackal/xmpp"
 	"github.com/ortuman/jackal/xmpp/jid"
 	"github.com/pborman/uuid"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -90,6 +95,25 @@ func TestStream_Features(t *testing.T) {
 	elem = conn2.outboundRead()
 	require.Equal(t, "stream:features", elem.Name())
 	require.NotNil(t, elem.Elements().ChildNamespace("mechanisms", saslNamespace))
+
+	// external authentication backend only verifies plaintext passwords
+	auth.Initialize(&auth.Config{Type: auth.HTTPBackend, HTTP: &auth.HTTPBackendConfig{URL: "http://127.0.0.1/xmpp"}})
+	defer auth.Shutdown()
+
+	stm3, conn3 := tUtilStreamInit()
+	stm3.setSecured(true)
+
+	tUtilStreamOpen(conn3)
+	_ = conn3.outboundRead() // read stream opening...
+
+	elem = conn3.outboundRead()
+	require.Equal(t, "stream:features", elem.Name())
+
+	var mechanisms []string
+	for _, m := range elem.Elements().ChildNamespace("mechanisms", saslNamespace).Elements().All() {
+		mechanisms = append(mechanisms, m.Text())
+	}
+	require.Equal(t, []string{"PLAIN"}, mechanisms)
 }
 
 func TestStream_TLS(t *testing.T) {
@@ -143,15 +167,21 @@ func TestStream_FailAuthenticate(t *testing.T) {
 	elem := conn.outboundRead()
 	require.Equal(t, "failure", elem.Name())
 
+	// digest-md5 is not offered
 	conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="DIGEST-MD5"/>`))
 
 	elem = conn.outboundRead()
-	require.Equal(t, "challenge", elem.Name())
+	require.Equal(t, "failure", elem.Name())
+	require.NotNil(t, elem.Elements().Child("invalid-mechanism"))
+
+	failures := tUtilAuthCount(t, "PLAIN", "failure")
 
-	conn.inboundWrite([]byte(`<response xmlns="urn:ietf:params:xml:ns:xmpp-sasl">dXNlcm5hbWU9Im9ydHVtYW4iLHJlYWxtPSJsb2NhbGhvc3QiLG5vbmNlPSJuY3prcXJFb3Uyait4ek1pcUgxV1lBdHh6dlNCSzFVbHNOejNLQUJsSjd3PSIsY25vbmNlPSJlcHNMSzhFQU8xVWVFTUpLVjdZNXgyYUtqaHN2UXpSMGtIdFM0ZGljdUFzPSIsbmM9MDAwMDAwMDEsZGlnZXN0LXVyaT0ieG1wcC9sb2NhbGhvc3QiLHFvcD1hdXRoLHJlc3BvbnNlPTVmODRmNTk2YWE4ODc0OWY2ZjZkZTYyZjliNjhkN2I2LGNoYXJzZXQ9dXRmLTg=</response>`))
+	// wrong password ("\x00user\x00pencil2")
+	conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="PLAIN">AHVzZXIAcGVuY2lsMg==</auth>`))
 
 	elem = conn.outboundRead()
 	require.Equal(t, "failure", elem.Name())
+	require.Equal(t, failures+1, tUtilAuthCount(t, "PLAIN", "failure"))
 
 	// non-SASL
 	conn.inboundWrite([]byte(`<iq type='set' id='auth2'><query xmlns='jabber:iq:auth'>
@@ -166,6 +196,105 @@ func TestStream_FailAuthenticate(t *testing.T) {
 	require.NotNil(t, elem.Elements().Child("error"))
 }
 
+func TestStream_AnonymousAuthenticate(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "localhost"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	conn := newFakeSocketConn()
+	tr := transport.NewSocketTransport(conn, 4096)
+	cfg := tUtilInStreamDefaultConfig(tr)
+	cfg.sasl = SASLConfig{
+		Mechanisms: []string{"plain", "anonymous"},
+		Anonymous:  AnonymousAuthConfig{Host: "localhost"},
+	}
+	stm := newStream("abcd1234", cfg).(*inStream)
+	stm.setSecured(true)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	elem := conn.outboundRead()
+	require.Equal(t, "stream:features", elem.Name())
+
+	var mechanisms []string
+	for _, m := range elem.Elements().ChildNamespace("mechanisms", saslNamespace).Elements().All() {
+		mechanisms = append(mechanisms, m.Text())
+	}
+	require.Equal(t, []string{"PLAIN", "ANONYMOUS"}, mechanisms)
+
+	conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="ANONYMOUS"/>`))
+
+	elem = conn.outboundRead()
+	require.Equal(t, "success", elem.Name())
+
+	time.Sleep(time.Millisecond * 100) // wait until authenticated...
+
+	username := stm.Username()
+	require.NotEmpty(t, username)
+	require.True(t, stm.IsAuthenticated())
+
+	exists, _ := storage.Instance().UserExists(username)
+	require.False(t, exists)
+
+	// ephemeral account data
+	storage.Instance().InsertOrUpdateVCard(xmpp.NewElementNamespace("vCard", "vcard-temp"), username)
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.NormalType)
+	msg.SetFromJID(stm.JID())
+	msg.SetToJID(stm.JID())
+	storage.Instance().InsertOfflineMessage(msg, username)
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     username,
+		JID:          "ortuman@localhost",
+		Subscription: rostermodel.SubscriptionNone,
+	})
+	storage.Instance().InsertOrUpdatePrivateXML([]xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", username)
+	storage.Instance().InsertArchiveMessage(&mammodel.Message{ID: uuid.New(), Username: username, With: "ortuman@localhost", Message: msg, Stamp: time.Now()})
+	storage.Instance().InsertBlockListItems([]model.BlockListItem{{Username: username, JID: "romeo@localhost"}})
+	storage.Instance().InsertOrUpdatePubSubNode(&pubsubmodel.Node{Host: stm.JID().ToBareJID().String(), Name: "urn:xmpp:avatar:data"})
+
+	stm.Disconnect(nil)
+	require.True(t, conn.waitClose())
+
+	vCard, _ := storage.Instance().FetchVCard(username)
+	require.Nil(t, vCard)
+	cnt, _ := storage.Instance().CountOfflineMessages(username)
+	require.Equal(t, 0, cnt)
+	items, _, _ := storage.Instance().FetchRosterItems(username)
+	require.Equal(t, 0, len(items))
+	namespaces, _ := storage.Instance().FetchPrivateXMLNamespaces(username)
+	require.Equal(t, 0, len(namespaces))
+	msgs, _ := storage.Instance().FetchArchiveMessages(username, &mammodel.Filter{})
+	require.Equal(t, 0, len(msgs))
+	blItems, _ := storage.Instance().FetchBlockListItems(username)
+	require.Equal(t, 0, len(blItems))
+	nodes, _ := storage.Instance().FetchPubSubNodes(stm.JID().ToBareJID().String())
+	require.Equal(t, 0, len(nodes))
+
+	// anonymous mechanism is bound to its configured host
+	conn2 := newFakeSocketConn()
+	cfg = tUtilInStreamDefaultConfig(transport.NewSocketTransport(conn2, 4096))
+	cfg.sasl = SASLConfig{
+		Mechanisms: []string{"anonymous"},
+		Anonymous:  AnonymousAuthConfig{Host: "guest.localhost"},
+	}
+	newStream("abcd5678", cfg)
+
+	tUtilStreamOpen(conn2)
+	_ = conn2.outboundRead() // read stream opening...
+	_ = conn2.outboundRead() // read stream features...
+
+	conn2.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="ANONYMOUS"/>`))
+
+	elem = conn2.outboundRead()
+	require.Equal(t, "failure", elem.Name())
+	require.NotNil(t, elem.Elements().Child("invalid-mechanism"))
+}
+
 func TestStream_Compression(t *testing.T) {
 	host.Initialize([]host.Config{{Name: "localhost"}})
 	router.Initialize(&router.Config{})
@@ -394,6 +523,66 @@ func TestStream_SendMessage(t *testing.T) {
 	require.Equal(t, msgID, elem.ID())
 }
 
+func TestStream_SendMessageToOfflineUser(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "localhost"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(&module.Config{Enabled: map[string]struct{}{"carbons": {}}})
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Password: "1234"})
+
+	stm, conn := tUtilStreamInit()
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamStartSession(conn, t)
+
+	require.Equal(t, sessionStarted, stm.getState())
+
+	// define a second carbons enabled resource...
+	jFrom, _ := jid.New("user", "localhost", "balcony", true)
+	j2, _ := jid.New("user", "localhost", "garden", true)
+	jTo, _ := jid.New("ortuman", "localhost", "", true)
+
+	stm2 := stream.NewMockC2S("abcd7890", j2)
+	stm2.Context().SetBool(true, "carbons:enabled")
+	router.Bind(stm2)
+
+	msgID := uuid.New()
+	msg := xmpp.NewMessageType(msgID, xmpp.ChatType)
+	msg.SetFromJID(jFrom)
+	msg.SetToJID(jTo)
+	body := xmpp.NewElementName("body")
+	body.SetText("Hi buddy!")
+	msg.AppendElement(body)
+
+	conn.inboundWrite([]byte(msg.String()))
+
+	// offline storage is disabled...
+	elem := conn.outboundRead()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+
+	// ...but sent message is still carbon copied
+	elem = stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.NotNil(t, elem.Elements().ChildNamespace("sent", "urn:xmpp:carbons:2"))
+}
+
 func TestStream_SendToBlockedJID(t *testing.T) {
 	host.Initialize([]host.Config{{Name: "localhost"}})
 	router.Initialize(&router.Config{})
@@ -444,22 +633,19 @@ func tUtilStreamOpen(conn *fakeSocketConn) {
 }
 
 func tUtilStreamAuthenticate(conn *fakeSocketConn, t *testing.T) {
-	conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="DIGEST-MD5"/>`))
+	// "\x00user\x00pencil"
+	conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="PLAIN">AHVzZXIAcGVuY2ls</auth>`))
 
 	elem := conn.outboundRead()
-	require.Equal(t, "challenge", elem.Name())
-
-	conn.inboundWrite([]byte(`<response xmlns="urn:ietf:params:xml:ns:xmpp-sasl">dXNlcm5hbWU9InVzZXIiLHJlYWxtPSJsb2NhbGhvc3QiLG5vbmNlPSJuY3prcXJFb3Uyait4ek1pcUgxV1lBdHh6dlNCSzFVbHNOejNLQUJsSjd3PSIsY25vbmNlPSJlcHNMSzhFQU8xVWVFTUpLVjdZNXgyYUtqaHN2UXpSMGtIdFM0ZGljdUFzPSIsbmM9MDAwMDAwMDEsZGlnZXN0LXVyaT0ieG1wcC9sb2NhbGhvc3QiLHFvcD1hdXRoLHJlc3BvbnNlPTVmODRmNTk2YWE4ODc0OWY2ZjZkZTYyZjliNjhkN2I2LGNoYXJzZXQ9dXRmLTg=</response>`))
-
-	elem = conn.outboundRead()
-	require.Equal(t, "challenge", elem.Name())
-
-	conn.inboundWrite([]byte(`<response xmlns="urn:ietf:params:xml:ns:xmpp-sasl"/>`))
-
-	elem = conn.outboundRead()
 	require.Equal(t, "success", elem.Name())
 }
 
+func tUtilAuthCount(t *testing.T, mechanism, result string) float64 {
+	m := &dto.Metric{}
+	require.Nil(t, authCount.WithLabelValues(mechanism, result).Write(m))
+	return m.GetCounter().GetValue()
+}
+
 func tUtilStreamStartSession(conn *fakeSocketConn, t *testing.T) {
 	conn.inboundWrite([]byte(`<iq type="set" id="bind_1">
 <bind xmlns="urn:ietf:params:xml:ns:xmpp-bind">
@@ -497,7 +683,7 @@ func tUtilInStreamDefaultConfig(tr transport.Transport) *streamConfig {
 		maxStanzaSize:    8192,
 		resourceConflict: Reject,
 		compression:      CompressConfig{Level: compress.DefaultCompression},
-		sasl:             []string{"plain", "digest_md5", "scram_sha_1", "scram_sha_256"},
+		sasl:             SASLConfig{Mechanisms: []string{"plain", "scram_sha_1", "scram_sha_256"}},
 	}
 }
 
@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ortuman/jackal/auth"
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+// authLimiter keeps track of SASL authentication failures within a sliding
+// time window, temporarily locking out keys (remote addresses or usernames)
+// once their failure limit has been reached.
+type authLimiter struct {
+	window    time.Duration
+	duration  time.Duration
+	mu        sync.Mutex
+	failures  map[string][]time.Time
+	lockouts  map[string]time.Time
+	lastPurge time.Time
+	now       func() time.Time
+}
+
+func newAuthLimiter(window, duration time.Duration) *authLimiter {
+	return &authLimiter{
+		window:   window,
+		duration: duration,
+		failures: make(map[string][]time.Time),
+		lockouts: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// isLocked returns whether or not a key is currently locked out.
+func (l *authLimiter) isLocked(key string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	until, ok := l.lockouts[key]
+	if !ok {
+		return false
+	}
+	if !l.now().Before(until) {
+		delete(l.lockouts, key)
+		return false
+	}
+	return true
+}
+
+// registerFailure records a new authentication failure for a given key,
+// returning true in case it has just been locked out.
+func (l *authLimiter) registerFailure(key string, maxFailures int) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.purge(now)
+
+	failures := append(l.recentFailures(key, now), now)
+	if len(failures) < maxFailures {
+		l.failures[key] = failures
+		return false
+	}
+	delete(l.failures, key)
+	l.lockouts[key] = now.Add(l.duration)
+	return true
+}
+
+// reset clears any failure registered for a given key.
+func (l *authLimiter) reset(key string) {
+	l.mu.Lock()
+	delete(l.failures, key)
+	l.mu.Unlock()
+}
+
+func (l *authLimiter) recentFailures(key string, now time.Time) []time.Time {
+	failures := l.failures[key]
+	for len(failures) > 0 && now.Sub(failures[0]) >= l.window {
+		failures = failures[1:]
+	}
+	return failures
+}
+
+func (l *authLimiter) purge(now time.Time) {
+	if now.Sub(l.lastPurge) < l.window {
+		return
+	}
+	for key := range l.failures {
+		if len(l.recentFailures(key, now)) == 0 {
+			delete(l.failures, key)
+		}
+	}
+	for key, until := range l.lockouts {
+		if !now.Before(until) {
+			delete(l.lockouts, key)
+		}
+	}
+	l.lastPurge = now
+}
+
+// remoteHost returns the host part of a remote network address.
+func remoteHost(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// saslUsername extracts the username claimed by a SASL payload,
+// returning an empty string if none can be found.
+func saslUsername(mechanism string, elem xmpp.XElement) string {
+	b, err := base64.StdEncoding.DecodeString(elem.Text())
+	if err != nil || len(b) == 0 {
+		return ""
+	}
+	switch {
+	case mechanism == "PLAIN":
+		// [authzid] UTF8NUL authcid UTF8NUL passwd
+		s := bytes.Split(b, []byte{0})
+		if len(s) == 3 {
+			return string(s[1])
+		}
+
+	case strings.HasPrefix(mechanism, "SCRAM-"):
+		// gs2-header n=username,r=nonce
+		sp := strings.Split(string(b), ",")
+		for i := 2; i < len(sp); i++ {
+			if strings.HasPrefix(sp[i], "n=") {
+				return strings.NewReplacer("=2C", ",", "=3D", "=").Replace(sp[i][2:])
+			}
+		}
+	}
+	return ""
+}
+
+// checkAuthLockout rejects an authentication attempt whenever it comes from
+// a locked out remote address or targets a locked out account.
+func (s *inStream) checkAuthLockout(authr auth.Authenticator, elem xmpp.XElement) bool {
+	l := s.cfg.authLimiter
+	if l == nil {
+		return false
+	}
+	if host := remoteHost(s.cfg.transport.RemoteAddr()); len(host) > 0 && l.isLocked("ip:"+host) {
+		log.Infof("rejected authentication from locked out address: %s (id: %s)", host, s.id)
+		s.disconnectWithStreamError(streamerror.ErrPolicyViolation)
+		return true
+	}
+	if username := saslUsername(authr.Mechanism(), elem); len(username) > 0 {
+		// normalize username, so that every spelling of an account shares its lockout
+		if j, err := jid.New(username, s.Domain(), "", false); err == nil {
+			username = j.Node()
+		}
+		s.authUsername = username
+	}
+	if len(s.authUsername) > 0 && l.isLocked("user:"+s.authUsername) {
+		log.Infof("rejected authentication for locked out user: %s (id: %s)", s.authUsername, s.id)
+		s.failAuthentication(auth.ErrSASLAccountDisabled.(*auth.SASLError).Element())
+		s.registerAuthFailure()
+		return true
+	}
+	return false
+}
+
+// registerAuthFailure accounts a failed authentication attempt, disconnecting
+// the stream once its failure limit has been reached.
+func (s *inStream) registerAuthFailure() {
+	lc := s.cfg.sasl.Lockout
+	if l := s.cfg.authLimiter; l != nil {
+		host := remoteHost(s.cfg.transport.RemoteAddr())
+		if len(host) > 0 && lc.MaxIPFailures > 0 && l.registerFailure("ip:"+host, lc.MaxIPFailures) {
+			log.Warnf("address locked out after %d authentication failures: %s", lc.MaxIPFailures, host)
+			lockoutCount.WithLabelValues("ip").Inc()
+		}
+		username := s.authUsername
+		if len(username) > 0 && lc.MaxUserFailures > 0 && l.registerFailure("user:"+username, lc.MaxUserFailures) {
+			log.Warnf("user locked out after %d authentication failures: %s", lc.MaxUserFailures, username)
+			lockoutCount.WithLabelValues("user").Inc()
+		}
+	}
+	s.authFailures++
+	if lc.MaxStreamFailures > 0 && s.authFailures >= lc.MaxStreamFailures {
+		log.Infof("too many authentication failures... id: %s", s.id)
+		lockoutCount.WithLabelValues("stream").Inc()
+		s.disconnectWithStreamError(streamerror.ErrPolicyViolation)
+	}
+}
@@ -0,0 +1,181 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"encoding/base64"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuthLimiter(t *testing.T) {
+	now := time.Now()
+	l := newAuthLimiter(time.Minute, time.Minute*5)
+	l.now = func() time.Time { return now }
+
+	require.False(t, l.registerFailure("user:ortuman", 3))
+	now = now.Add(time.Second * 30)
+	require.False(t, l.registerFailure("user:ortuman", 3))
+	require.False(t, l.isLocked("user:ortuman"))
+
+	// first failure slides out of window
+	now = now.Add(time.Second * 31)
+	require.False(t, l.registerFailure("user:ortuman", 3))
+	require.False(t, l.isLocked("user:ortuman"))
+
+	require.True(t, l.registerFailure("user:ortuman", 3))
+	require.True(t, l.isLocked("user:ortuman"))
+	require.False(t, l.isLocked("ip:127.0.0.1"))
+
+	// lockout expiration
+	now = now.Add(time.Minute * 5)
+	require.False(t, l.isLocked("user:ortuman"))
+
+	require.False(t, l.registerFailure("user:ortuman", 2))
+	l.reset("user:ortuman")
+	require.False(t, l.registerFailure("user:ortuman", 2))
+	require.True(t, l.registerFailure("user:ortuman", 2))
+
+	// stale entries are purged
+	now = now.Add(time.Hour)
+	require.False(t, l.registerFailure("ip:127.0.0.1", 2))
+	require.Equal(t, 1, len(l.failures))
+	require.Equal(t, 0, len(l.lockouts))
+}
+
+func TestAuthLimiter_SASLUsername(t *testing.T) {
+	elem := xmpp.NewElementNamespace("auth", saslNamespace)
+	require.Equal(t, "", saslUsername("PLAIN", elem))
+
+	elem.SetText("not-base64!")
+	require.Equal(t, "", saslUsername("PLAIN", elem))
+
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("\x00ortuman\x001234")))
+	require.Equal(t, "ortuman", saslUsername("PLAIN", elem))
+
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("n,,n=ort=2Cuman,r=fyko+d2lbbFgONRv9qkxdawL")))
+	require.Equal(t, "ort,uman", saslUsername("SCRAM-SHA-1", elem))
+
+	elem.SetText(base64.StdEncoding.EncodeToString([]byte("n")))
+	require.Equal(t, "", saslUsername("SCRAM-SHA-256", elem))
+
+	require.Equal(t, "", saslUsername("EXTERNAL", elem))
+
+	require.Equal(t, "", remoteHost(nil))
+	require.Equal(t, "str", remoteHost(remoteAddr))
+	require.Equal(t, "192.0.2.1", remoteHost(&net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 5222}))
+}
+
+func TestStream_AuthenticationLockout(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "localhost"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+
+	// per-user lockout
+	limiter := newAuthLimiter(time.Minute, time.Minute)
+	stm, conn := tUtilLockoutStreamInit(limiter, LockoutConfig{MaxStreamFailures: 5, MaxUserFailures: 2})
+
+	userLockouts := tUtilLockoutCount(t, "user")
+	streamLockouts := tUtilLockoutCount(t, "stream")
+
+	tUtilLockoutPlainAuth(conn, "user", "wrong")
+	require.Equal(t, "not-authorized", tUtilLockoutFailure(conn, t))
+
+	tUtilLockoutPlainAuth(conn, "user", "wrong")
+	require.Equal(t, "not-authorized", tUtilLockoutFailure(conn, t))
+	require.Equal(t, userLockouts+1, tUtilLockoutCount(t, "user"))
+
+	// valid credentials are rejected as well
+	tUtilLockoutPlainAuth(conn, "user", "pencil")
+	require.Equal(t, "account-disabled", tUtilLockoutFailure(conn, t))
+
+	// every spelling of a locked out username is rejected
+	tUtilLockoutPlainAuth(conn, "USER", "pencil")
+	require.Equal(t, "account-disabled", tUtilLockoutFailure(conn, t))
+
+	// per-stream failure limit reached
+	tUtilLockoutPlainAuth(conn, "user", "pencil")
+	require.True(t, conn.waitClose())
+	require.False(t, stm.IsAuthenticated())
+	require.Equal(t, streamLockouts+1, tUtilLockoutCount(t, "stream"))
+
+	// per-address lockout
+	limiter = newAuthLimiter(time.Minute, time.Minute)
+	_, conn = tUtilLockoutStreamInit(limiter, LockoutConfig{MaxIPFailures: 1})
+
+	ipLockouts := tUtilLockoutCount(t, "ip")
+
+	tUtilLockoutPlainAuth(conn, "user", "wrong")
+	require.Equal(t, "not-authorized", tUtilLockoutFailure(conn, t))
+	require.Equal(t, ipLockouts+1, tUtilLockoutCount(t, "ip"))
+
+	tUtilLockoutPlainAuth(conn, "user", "pencil")
+	require.True(t, conn.waitClose())
+
+	// successful authentication resets user failures
+	limiter = newAuthLimiter(time.Minute, time.Minute)
+	stm, conn = tUtilLockoutStreamInit(limiter, LockoutConfig{MaxUserFailures: 2})
+
+	tUtilLockoutPlainAuth(conn, "user", "wrong")
+	require.Equal(t, "not-authorized", tUtilLockoutFailure(conn, t))
+
+	tUtilLockoutPlainAuth(conn, "user", "pencil")
+	require.Equal(t, "success", conn.outboundRead().Name())
+
+	time.Sleep(time.Millisecond * 100) // wait until authenticated...
+	require.True(t, stm.IsAuthenticated())
+	require.Equal(t, 0, len(limiter.failures))
+}
+
+func tUtilLockoutStreamInit(limiter *authLimiter, lc LockoutConfig) (*inStream, *fakeSocketConn) {
+	conn := newFakeSocketConn()
+	tr := transport.NewSocketTransport(conn, 4096)
+	cfg := tUtilInStreamDefaultConfig(tr)
+	cfg.sasl.Lockout = lc
+	cfg.authLimiter = limiter
+	stm := newStream("abcd1234", cfg).(*inStream)
+	stm.setSecured(true)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+	return stm, conn
+}
+
+func tUtilLockoutPlainAuth(conn *fakeSocketConn, username, password string) {
+	b64 := base64.StdEncoding.EncodeToString([]byte("\x00" + username + "\x00" + password))
+	conn.inboundWrite([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="PLAIN">` + b64 + `</auth>`))
+}
+
+func tUtilLockoutFailure(conn *fakeSocketConn, t *testing.T) string {
+	elem := conn.outboundRead()
+	require.Equal(t, "failure", elem.Name())
+	require.Equal(t, 1, elem.Elements().Count())
+	return elem.Elements().All()[0].Name()
+}
+
+func tUtilLockoutCount(t *testing.T, scope string) float64 {
+	m := &dto.Metric{}
+	require.Nil(t, lockoutCount.WithLabelValues(scope).Write(m))
+	return m.GetCounter().GetValue()
+}
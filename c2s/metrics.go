@@ -0,0 +1,35 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var (
+	streamCount = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: "jackal",
+		Subsystem: "c2s",
+		Name:      "streams",
+		Help:      "Number of active c2s streams.",
+	})
+
+	authCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "jackal",
+		Subsystem: "c2s",
+		Name:      "auth_total",
+		Help:      "Number of SASL authentication attempts, by mechanism and result.",
+	}, []string{"mechanism", "result"})
+
+	lockoutCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "jackal",
+		Subsystem: "c2s",
+		Name:      "auth_lockouts_total",
+		Help:      "Number of SASL authentication lockouts, by scope (stream, ip or user).",
+	}, []string{"scope"})
+)
+
+func init() {
+	prometheus.MustRegister(streamCount, authCount, lockoutCount)
+}
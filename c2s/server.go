@@ -23,12 +23,14 @@ import (
 var listenerProvider = net.Listen
 
 type server struct {
-	cfg        *Config
-	ln         net.Listener
-	wsSrv      *http.Server
-	wsUpgrader *websocket.Upgrader
-	stmCounter uint64
-	listening  uint32
+	cfg         *Config
+	ln          net.Listener
+	wsSrv       *http.Server
+	wsUpgrader  *websocket.Upgrader
+	boshSrv     *http.Server
+	authLimiter *authLimiter
+	stmCounter  uint64
+	listening   uint32
 }
 
 func (s *server) start() {
@@ -45,6 +47,8 @@ func (s *server) start() {
 	case transport.WebSocket:
 		err = s.listenWebSocketConn(address)
 		break
+	case transport.BOSH:
+		err = s.listenBOSHConn(address)
 	}
 	if err != nil {
 		log.Fatalf("%v", err)
@@ -56,6 +60,18 @@ func (s *server) listenSocketConn(address string) error {
 	if err != nil {
 		return err
 	}
+	if s.cfg.Transport.DirectTLS {
+		// XEP-0368: SRV records for XMPP over TLS (https://xmpp.org/extensions/xep-0368.html)
+		tlsCfg := &tls.Config{
+			GetCertificate: host.GetCertificate(""),
+			NextProtos:     []string{"xmpp-client"},
+		}
+		if cas := s.cfg.SASL.External.ClientCAs; cas != nil {
+			tlsCfg.ClientAuth = tls.VerifyClientCertIfGiven
+			tlsCfg.ClientCAs = cas
+		}
+		ln = tls.NewListener(ln, tlsCfg)
+	}
 	s.ln = ln
 
 	atomic.StoreUint32(&s.listening, 1)
@@ -72,7 +88,7 @@ func (s *server) listenSocketConn(address string) error {
 func (s *server) listenWebSocketConn(address string) error {
 	http.HandleFunc(s.cfg.Transport.URLPath, s.websocketUpgrade)
 
-	s.wsSrv = &http.Server{TLSConfig: &tls.Config{Certificates: host.Certificates()}}
+	s.wsSrv = &http.Server{TLSConfig: &tls.Config{GetCertificate: host.GetCertificate("")}}
 	s.wsUpgrader = &websocket.Upgrader{
 		Subprotocols: []string{"xmpp"},
 		CheckOrigin:  func(r *http.Request) bool { return r.Header.Get("Sec-WebSocket-Protocol") == "xmpp" },
@@ -87,6 +103,29 @@ func (s *server) listenWebSocketConn(address string) error {
 	return s.wsSrv.ServeTLS(ln, "", "")
 }
 
+func (s *server) listenBOSHConn(address string) error {
+	boshCfg := s.cfg.Transport.BOSH
+	boshCfg.MaxRequestSize = s.cfg.MaxStanzaSize
+
+	mux := http.NewServeMux()
+	mux.Handle(s.cfg.Transport.URLPath, transport.NewBOSHHandler(boshCfg, s.startStream))
+	s.boshSrv = &http.Server{
+		Handler:   mux,
+		TLSConfig: &tls.Config{GetCertificate: host.GetCertificate("")},
+	}
+
+	// start listening
+	ln, err := listenerProvider("tcp", address)
+	if err != nil {
+		return err
+	}
+	atomic.StoreUint32(&s.listening, 1)
+	if err := s.boshSrv.ServeTLS(ln, "", ""); err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
 func (s *server) websocketUpgrade(w http.ResponseWriter, r *http.Request) {
 	conn, err := s.wsUpgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -103,6 +142,8 @@ func (s *server) shutdown() error {
 			return s.ln.Close()
 		case transport.WebSocket:
 			return s.wsSrv.Close()
+		case transport.BOSH:
+			return s.boshSrv.Close()
 		}
 	}
 	return nil
@@ -116,6 +157,9 @@ func (s *server) startStream(tr transport.Transport) {
 		maxStanzaSize:    s.cfg.MaxStanzaSize,
 		sasl:             s.cfg.SASL,
 		compression:      s.cfg.Compression,
+		sm:               s.cfg.StreamManagement,
+		directTLS:        s.cfg.Transport.DirectTLS,
+		authLimiter:      s.authLimiter,
 	}
 	newStream(s.nextID(), cfg)
 }
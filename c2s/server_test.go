@@ -6,18 +6,28 @@
 package c2s
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
 	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
 	"net"
 	"net/http"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/gorilla/websocket"
 	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/util"
+	"github.com/ortuman/jackal/xmpp"
 	"github.com/stretchr/testify/require"
 )
 
@@ -69,6 +79,126 @@ func TestC2SSocketServer(t *testing.T) {
 	host.Shutdown()
 }
 
+func TestC2SDirectTLSServer(t *testing.T) {
+	privKeyFile := "../testdata/cert/test.server.key"
+	certFile := "../testdata/cert/test.server.crt"
+	cer, err := util.LoadCertificate(privKeyFile, certFile, "localhost")
+	require.Nil(t, err)
+
+	host.Initialize([]host.Config{{Name: "localhost", Certificate: cer}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		SASL:             SASLConfig{Mechanisms: []string{"plain"}},
+		Transport: TransportConfig{
+			Type:      transport.Socket,
+			Port:      9996,
+			DirectTLS: true,
+		},
+	}
+	go Initialize([]Config{cfg})
+	defer Shutdown()
+
+	time.Sleep(time.Millisecond * 150)
+
+	conn, err := tls.Dial("tcp", "127.0.0.1:9996", &tls.Config{
+		ServerName:         "localhost",
+		NextProtos:         []string{"xmpp-client"},
+		InsecureSkipVerify: true,
+	})
+	require.Nil(t, err)
+	defer conn.Close()
+
+	require.Equal(t, "xmpp-client", conn.ConnectionState().NegotiatedProtocol)
+
+	_, err = conn.Write([]byte(`<?xml version="1.0"?><stream:stream xmlns:stream="http://etherx.jabber.org/streams" version="1.0" xmlns="jabber:client" to="localhost">`))
+	require.Nil(t, err)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var resp string
+	buf := make([]byte, 4096)
+	for !strings.Contains(resp, "</stream:features>") {
+		n, err := conn.Read(buf)
+		require.Nil(t, err)
+		resp += string(buf[:n])
+	}
+	// already secured stream
+	require.False(t, strings.Contains(resp, "starttls"))
+	require.True(t, strings.Contains(resp, "<mechanism>PLAIN</mechanism>"))
+}
+
+func TestC2SExternalAuthentication(t *testing.T) {
+	privKeyFile := "../testdata/cert/test.server.key"
+	certFile := "../testdata/cert/test.server.crt"
+	cer, err := util.LoadCertificate(privKeyFile, certFile, "localhost")
+	require.Nil(t, err)
+
+	host.Initialize([]host.Config{{Name: "localhost", Certificate: cer}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Password: "1234"})
+
+	ca, clientCer := tUtilClientCertificate(t, "ortuman")
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(ca)
+
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		SASL: SASLConfig{
+			Mechanisms: []string{"plain", "external"},
+			External:   ExternalAuthConfig{ClientCAs: clientCAs},
+		},
+		Transport: TransportConfig{
+			Type:      transport.Socket,
+			Port:      9995,
+			DirectTLS: true,
+		},
+	}
+	go Initialize([]Config{cfg})
+	defer Shutdown()
+
+	time.Sleep(time.Millisecond * 150)
+
+	conn, err := tls.Dial("tcp", "127.0.0.1:9995", &tls.Config{
+		ServerName:         "localhost",
+		Certificates:       []tls.Certificate{clientCer},
+		InsecureSkipVerify: true,
+	})
+	require.Nil(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(`<?xml version="1.0"?><stream:stream xmlns:stream="http://etherx.jabber.org/streams" version="1.0" xmlns="jabber:client" to="localhost">`))
+	require.Nil(t, err)
+
+	resp := tUtilReadUntil(t, conn, "</stream:features>")
+	require.True(t, strings.Contains(resp, "<mechanism>EXTERNAL</mechanism>"))
+
+	_, err = conn.Write([]byte(`<auth xmlns="urn:ietf:params:xml:ns:xmpp-sasl" mechanism="EXTERNAL">=</auth>`))
+	require.Nil(t, err)
+
+	resp = tUtilReadUntil(t, conn, "xmpp-sasl")
+	require.True(t, strings.Contains(resp, "<success"), resp)
+}
+
 func TestC2SWebSocketServer(t *testing.T) {
 	privKeyFile := "../testdata/cert/test.server.key"
 	certFile := "../testdata/cert/test.server.crt"
@@ -124,3 +254,104 @@ func TestC2SWebSocketServer(t *testing.T) {
 	storage.Shutdown()
 	host.Shutdown()
 }
+
+func TestC2SBOSHServer(t *testing.T) {
+	privKeyFile := "../testdata/cert/test.server.key"
+	certFile := "../testdata/cert/test.server.crt"
+	cer, err := util.LoadCertificate(privKeyFile, certFile, "localhost")
+	require.Nil(t, err)
+
+	host.Initialize([]host.Config{{Name: "localhost", Certificate: cer}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+
+	errCh := make(chan error)
+	cfg := Config{
+		ID:               "srv-1234",
+		ConnectTimeout:   time.Second * time.Duration(5),
+		MaxStanzaSize:    8192,
+		ResourceConflict: Reject,
+		Transport: TransportConfig{
+			Type:    transport.BOSH,
+			URLPath: "/http-bind",
+			Port:    9997,
+			BOSH:    transport.BOSHConfig{Wait: time.Second, Hold: 1, Inactivity: time.Second},
+		},
+	}
+	go Initialize([]Config{cfg})
+
+	go func() {
+		time.Sleep(time.Millisecond * 150)
+		cl := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}}
+
+		body := `<body xmlns="http://jabber.org/protocol/httpbind" rid="1" to="localhost" wait="1" hold="1"/>`
+		resp, err := cl.Post("https://127.0.0.1:9997/http-bind", "text/xml; charset=utf-8", strings.NewReader(body))
+		if err != nil {
+			errCh <- err
+			return
+		}
+		defer resp.Body.Close()
+
+		elem, err := xmpp.NewParser(resp.Body, xmpp.DefaultMode, 0).ParseElement()
+		if err != nil {
+			errCh <- err
+			return
+		}
+		if len(elem.Attributes().Get("sid")) == 0 || elem.Elements().Child("stream:features") == nil {
+			errCh <- fmt.Errorf("unexpected session creation response: %v", elem)
+			return
+		}
+		Shutdown()
+		errCh <- nil
+	}()
+	err = <-errCh
+	require.Nil(t, err)
+
+	router.Shutdown()
+	storage.Shutdown()
+	host.Shutdown()
+}
+
+func tUtilReadUntil(t *testing.T, conn net.Conn, str string) string {
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var resp string
+	buf := make([]byte, 4096)
+	for !strings.Contains(resp, str) {
+		n, err := conn.Read(buf)
+		require.Nil(t, err)
+		resp += string(buf[:n])
+	}
+	return resp
+}
+
+func tUtilClientCertificate(t *testing.T, username string) (*x509.Certificate, tls.Certificate) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	caTmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "jackal test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	b, err := x509.CreateCertificate(rand.Reader, caTmpl, caTmpl, &caKey.PublicKey, caKey)
+	require.Nil(t, err)
+	ca, err := x509.ParseCertificate(b)
+	require.Nil(t, err)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: username},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	b, err = x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	require.Nil(t, err)
+	return ca, tls.Certificate{Certificate: [][]byte{b}, PrivateKey: key}
+}
@@ -0,0 +1,292 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/pborman/uuid"
+)
+
+const (
+	smAckRequestInterval = 5
+	smMaxUnackedStanzas  = 1000
+)
+
+var smContainer smMap
+
+type smMap struct{ m sync.Map }
+
+func (m *smMap) set(id string, stm *inStream) {
+	m.m.Store(id, stm)
+}
+
+func (m *smMap) get(id string) *inStream {
+	stm, ok := m.m.Load(id)
+	if !ok {
+		return nil
+	}
+	return stm.(*inStream)
+}
+
+func (m *smMap) delete(id string) {
+	m.m.Delete(id)
+}
+
+// smState holds stream management (XEP-0198) state of a c2s stream.
+type smState struct {
+	id        string
+	resumable bool
+	inH       uint32
+	outH      uint32
+	unacked   []xmpp.XElement
+	resumeTm  *time.Timer
+}
+
+func (s *inStream) handleStreamManagement(elem xmpp.XElement) {
+	switch elem.Name() {
+	case "enable":
+		s.enableStreamManagement(elem)
+	case "r":
+		s.sendAck()
+	case "a":
+		s.processAck(elem)
+	case "resume":
+		s.resumeStream(elem)
+	default:
+		s.disconnectWithStreamError(streamerror.ErrUnsupportedStanzaType)
+	}
+}
+
+func (s *inStream) enableStreamManagement(elem xmpp.XElement) {
+	if !s.cfg.sm.Enabled || s.sm != nil || len(s.Resource()) == 0 {
+		s.failStreamManagement("unexpected-request")
+		return
+	}
+	s.sm = &smState{}
+
+	enabled := xmpp.NewElementNamespace("enabled", smNamespace)
+	if resume := elem.Attributes().Get("resume"); resume == "true" || resume == "1" {
+		s.sm.id = uuid.New()
+		s.sm.resumable = true
+		smContainer.set(s.sm.id, s)
+
+		enabled.SetAttribute("id", s.sm.id)
+		enabled.SetAttribute("resume", "true")
+		enabled.SetAttribute("max", strconv.Itoa(int(s.resumeTimeout().Seconds())))
+	}
+	s.writeElement(enabled)
+
+	log.Infof("enabled stream management... id: %s", s.id)
+}
+
+func (s *inStream) sendAck() {
+	if s.sm == nil {
+		s.failStreamManagement("unexpected-request")
+		return
+	}
+	a := xmpp.NewElementNamespace("a", smNamespace)
+	a.SetAttribute("h", strconv.FormatUint(uint64(s.sm.inH), 10))
+	s.writeElement(a)
+}
+
+func (s *inStream) processAck(elem xmpp.XElement) {
+	if s.sm == nil {
+		s.failStreamManagement("unexpected-request")
+		return
+	}
+	h, err := strconv.ParseUint(elem.Attributes().Get("h"), 10, 32)
+	if err != nil {
+		s.disconnectWithStreamError(streamerror.ErrPolicyViolation)
+		return
+	}
+	s.ackStanzas(uint32(h))
+}
+
+func (s *inStream) ackStanzas(h uint32) {
+	ackedH := s.sm.outH - uint32(len(s.sm.unacked))
+	n := int(h - ackedH)
+	if n < 0 || n > len(s.sm.unacked) {
+		// peer acknowledged more stanzas than it was sent
+		n = len(s.sm.unacked)
+	}
+	s.sm.unacked = s.sm.unacked[n:]
+}
+
+func (s *inStream) queueUnackedStanza(elem xmpp.XElement) {
+	s.sm.outH++
+	s.sm.unacked = append(s.sm.unacked, elem)
+	if len(s.sm.unacked) > smMaxUnackedStanzas {
+		s.disconnectWithStreamError(streamerror.ErrPolicyViolation)
+	}
+}
+
+func (s *inStream) requestAck() {
+	if len(s.sm.unacked)%smAckRequestInterval == 0 {
+		s.sess.Send(xmpp.NewElementNamespace("r", smNamespace))
+	}
+}
+
+func (s *inStream) resumeStream(elem xmpp.XElement) {
+	if !s.cfg.sm.Enabled || len(s.Resource()) > 0 {
+		s.failStreamManagement("unexpected-request")
+		return
+	}
+	h, err := strconv.ParseUint(elem.Attributes().Get("h"), 10, 32)
+	if err != nil {
+		s.disconnectWithStreamError(streamerror.ErrPolicyViolation)
+		return
+	}
+	prevID := elem.Attributes().Get("previd")
+
+	stm := smContainer.get(prevID)
+	if stm == nil || stm.Username() != s.Username() || !stm.resume(prevID, s, uint32(h)) {
+		s.failStreamManagement("item-not-found")
+		return
+	}
+	// transport has been handed over to the resumed stream
+	inContainer.delete(s)
+	s.setState(disconnected)
+}
+
+// resume reattaches the given stream transport to a detached stream.
+func (s *inStream) resume(prevID string, stm *inStream, h uint32) bool {
+	if s.getState() == disconnected {
+		return false
+	}
+	resCh := make(chan bool, 1)
+	cfg, sess := stm.cfg, stm.sess
+	secured, compressed := stm.IsSecured(), stm.IsCompressed()
+	f := func() {
+		if s.sm == nil || s.sm.id != prevID || s.getState() == disconnected {
+			resCh <- false
+			return
+		}
+		if s.getState() != detached {
+			// previous transport is still alive
+			s.sess.Close()
+			s.cfg.transport.Close()
+		}
+		if s.sm.resumeTm != nil {
+			s.sm.resumeTm.Stop()
+			s.sm.resumeTm = nil
+		}
+		s.cfg = cfg
+		s.sess = sess
+		s.sess.SetJID(s.JID())
+		s.setSecured(secured)
+		s.setCompressed(compressed)
+
+		s.ackStanzas(h)
+
+		resumed := xmpp.NewElementNamespace("resumed", smNamespace)
+		resumed.SetAttribute("h", strconv.FormatUint(uint64(s.sm.inH), 10))
+		resumed.SetAttribute("previd", prevID)
+		s.sess.Send(resumed)
+
+		// resend unacknowledged stanzas
+		for _, elem := range s.sm.unacked {
+			s.sess.Send(elem)
+		}
+		s.setState(sessionStarted)
+
+		if p := module.Modules().Ping; p != nil {
+			p.SchedulePing(s)
+		}
+		go s.doRead(s.sess) // start reading...
+
+		log.Infof("resumed stream... id: %s", s.id)
+		resCh <- true
+	}
+	// previous stream actor might exit at any time
+	select {
+	case s.actorCh <- f:
+	case <-s.doneCh:
+		return false
+	}
+	select {
+	case ok := <-resCh:
+		return ok
+	case <-s.doneCh:
+		return false
+	}
+}
+
+func (s *inStream) isResumable(sErr error) bool {
+	if s.sm == nil || !s.sm.resumable || s.getState() != sessionStarted || s.sess.IsClosedByPeer() {
+		return false
+	}
+	switch sErr {
+	case nil, streamerror.ErrConnectionTimeout:
+		return true
+	}
+	switch sErr.(type) {
+	case *streamerror.Error, *xmpp.StanzaError:
+		return false
+	}
+	return true
+}
+
+func (s *inStream) detach() {
+	// stop pinging...
+	if p := module.Modules().Ping; p != nil {
+		p.CancelPing(s)
+	}
+	s.setState(detached)
+	s.cfg.transport.Close()
+
+	// client state queued stanzas are held back until resumption
+	s.flushClientStateQueue()
+
+	var tm *time.Timer
+	tm = time.AfterFunc(s.resumeTimeout(), func() {
+		f := func() {
+			if s.getState() == detached && s.sm.resumeTm == tm {
+				log.Infof("stream resumption timed out... id: %s", s.id)
+				s.disconnectClosingSession(false, true)
+			}
+		}
+		select {
+		case s.actorCh <- f:
+		case <-s.doneCh: // stream already disconnected
+		}
+	})
+	s.sm.resumeTm = tm
+
+	log.Infof("detached stream... id: %s", s.id)
+}
+
+func (s *inStream) resumeTimeout() time.Duration {
+	if s.cfg.sm.ResumeTimeout > 0 {
+		return s.cfg.sm.ResumeTimeout
+	}
+	return defaultResumeTimeout
+}
+
+func (s *inStream) terminateStreamManagement() {
+	if s.sm.resumeTm != nil {
+		s.sm.resumeTm.Stop()
+		s.sm.resumeTm = nil
+	}
+	if s.sm.resumable {
+		smContainer.delete(s.sm.id)
+	}
+	// bounce or store unacknowledged stanzas
+	bounceUndeliveredStanzas(s.sm.unacked)
+	s.sm.unacked = nil
+}
+
+func (s *inStream) failStreamManagement(condition string) {
+	failed := xmpp.NewElementNamespace("failed", smNamespace)
+	failed.AppendElement(xmpp.NewElementNamespace(condition, "urn:ietf:params:xml:ns:xmpp-stanzas"))
+	s.writeElement(failed)
+}
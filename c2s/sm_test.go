@@ -0,0 +1,271 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package c2s
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/module/offline"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_EnableStreamManagement(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "localhost"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+
+	stm, conn := tUtilSMStreamInit("abcd1234")
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	elem := conn.outboundRead()
+	require.Equal(t, "stream:features", elem.Name())
+	require.NotNil(t, elem.Elements().ChildNamespace("sm", smNamespace))
+
+	// ack request before enabling
+	conn.inboundWrite([]byte(`<r xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "failed", elem.Name())
+	require.NotNil(t, elem.Elements().Child("unexpected-request"))
+
+	tUtilStreamStartSession(conn, t)
+
+	conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "enabled", elem.Name())
+	require.Equal(t, smNamespace, elem.Namespace())
+	require.Equal(t, "", elem.Attributes().Get("resume"))
+
+	// enable twice
+	conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "failed", elem.Name())
+
+	conn.inboundWrite([]byte(`<presence/>`))
+	conn.inboundWrite([]byte(`<r xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn.outboundRead()
+	require.Equal(t, "a", elem.Name())
+	require.Equal(t, "1", elem.Attributes().Get("h"))
+
+	// outgoing stanzas
+	for i := 0; i < 2; i++ {
+		stm.SendElement(tUtilSMMessage(stm.JID()))
+		elem = conn.outboundRead()
+		require.Equal(t, "message", elem.Name())
+	}
+	time.Sleep(time.Millisecond * 100) // wait until processed...
+	require.Equal(t, 2, tUtilSMUnackedLen(stm))
+
+	conn.inboundWrite([]byte(`<a xmlns="urn:xmpp:sm:3" h="1"/>`))
+	time.Sleep(time.Millisecond * 100) // wait until processed...
+	require.Equal(t, 1, tUtilSMUnackedLen(stm))
+
+	conn.inboundWrite([]byte(`<a xmlns="urn:xmpp:sm:3" h="2"/>`))
+	time.Sleep(time.Millisecond * 100) // wait until processed...
+	require.Equal(t, 0, tUtilSMUnackedLen(stm))
+}
+
+func TestStream_ResumeStream(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "localhost"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+
+	stm, conn := tUtilSMStreamInit("abcd1234")
+	smID := tUtilSMStreamEnable(conn, t)
+
+	stm.SendElement(tUtilSMMessage(stm.JID()))
+	elem := conn.outboundRead()
+	require.Equal(t, "message", elem.Name())
+
+	// detach stream
+	conn.Close()
+	time.Sleep(time.Millisecond * 100) // wait until stream is detached...
+	require.Equal(t, detached, stm.getState())
+	require.Equal(t, 1, len(router.UserStreams("user")))
+
+	msg := tUtilSMMessage(stm.JID())
+	stm.SendElement(msg)
+
+	// resume from a different stream
+	stm2, conn2 := tUtilSMStreamInit("abcd5678")
+	tUtilStreamOpen(conn2)
+	_ = conn2.outboundRead() // read stream opening...
+	_ = conn2.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn2, t)
+
+	tUtilStreamOpen(conn2)
+	_ = conn2.outboundRead() // read stream opening...
+	_ = conn2.outboundRead() // read stream features...
+
+	conn2.inboundWrite([]byte(`<resume xmlns="urn:xmpp:sm:3" previd="unknown" h="0"/>`))
+	elem = conn2.outboundRead()
+	require.Equal(t, "failed", elem.Name())
+	require.NotNil(t, elem.Elements().Child("item-not-found"))
+
+	conn2.inboundWrite([]byte(`<resume xmlns="urn:xmpp:sm:3" previd="` + smID + `" h="1"/>`))
+	elem = conn2.outboundRead()
+	require.Equal(t, "resumed", elem.Name())
+	require.Equal(t, smID, elem.Attributes().Get("previd"))
+	require.Equal(t, "0", elem.Attributes().Get("h"))
+
+	elem = conn2.outboundRead()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, msg.ID(), elem.ID())
+
+	time.Sleep(time.Millisecond * 100) // wait until transport is handed over...
+	require.Equal(t, disconnected, stm2.getState())
+	require.Equal(t, sessionStarted, stm.getState())
+
+	// resumed stream keeps reading from the new transport
+	conn2.inboundWrite([]byte(`<r xmlns="urn:xmpp:sm:3"/>`))
+	elem = conn2.outboundRead()
+	require.Equal(t, "a", elem.Name())
+}
+
+func TestStream_ResumeTimeout(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "localhost"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(&module.Config{
+		Enabled: map[string]struct{}{"offline": {}},
+		Offline: offline.Config{QueueSize: 10},
+	})
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+
+	stm, conn := tUtilSMStreamInit("abcd1234")
+	tUtilSMStreamEnable(conn, t)
+
+	conn.Close()
+	time.Sleep(time.Millisecond * 100) // wait until stream is detached...
+	require.Equal(t, detached, stm.getState())
+
+	stm.SendElement(tUtilSMMessage(stm.JID()))
+
+	time.Sleep(time.Millisecond * 750) // wait until resumption times out...
+	require.Equal(t, disconnected, stm.getState())
+	require.Equal(t, 0, len(router.UserStreams("user")))
+
+	cnt, err := storage.Instance().CountOfflineMessages("user")
+	require.Nil(t, err)
+	require.Equal(t, 1, cnt)
+}
+
+func TestStream_ResumeExitedStream(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "localhost"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "user", Password: "pencil"})
+
+	stm, conn := tUtilSMStreamInit("abcd1234")
+	smID := tUtilSMStreamEnable(conn, t)
+
+	stm.Disconnect(nil)
+	require.True(t, conn.waitClose())
+
+	// actor loop has already exited
+	stm.setState(detached)
+
+	stm2, _ := tUtilSMStreamInit("abcd5678")
+	resCh := make(chan bool, 1)
+	go func() { resCh <- stm.resume(smID, stm2, 0) }()
+	select {
+	case ok := <-resCh:
+		require.False(t, ok)
+	case <-time.After(time.Second):
+		require.Fail(t, "stream resumption blocked")
+	}
+	stm2.Disconnect(nil)
+}
+
+func tUtilSMStreamInit(id string) (*inStream, *fakeSocketConn) {
+	conn := newFakeSocketConn()
+	tr := transport.NewSocketTransport(conn, 4096)
+	cfg := tUtilInStreamDefaultConfig(tr)
+	cfg.sm = StreamManagementConfig{Enabled: true, ResumeTimeout: time.Millisecond * 500}
+	stm := newStream(id, cfg)
+	return stm.(*inStream), conn
+}
+
+func tUtilSMStreamEnable(conn *fakeSocketConn, t *testing.T) string {
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamAuthenticate(conn, t)
+
+	tUtilStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+
+	tUtilStreamStartSession(conn, t)
+
+	conn.inboundWrite([]byte(`<enable xmlns="urn:xmpp:sm:3" resume="true"/>`))
+	elem := conn.outboundRead()
+	require.Equal(t, "enabled", elem.Name())
+	require.Equal(t, "true", elem.Attributes().Get("resume"))
+	require.NotEmpty(t, elem.Attributes().Get("id"))
+	return elem.Attributes().Get("id")
+}
+
+func tUtilSMMessage(to *jid.JID) *xmpp.Message {
+	from, _ := jid.New("ortuman", "localhost", "garden", true)
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	body := xmpp.NewElementName("body")
+	body.SetText("Hi buddy!")
+	msg.AppendElement(body)
+	return msg
+}
+
+func tUtilSMUnackedLen(stm *inStream) int {
+	lenCh := make(chan int, 1)
+	stm.actorCh <- func() { lenCh <- len(stm.sm.unacked) }
+	return <-lenCh
+}
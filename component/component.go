@@ -9,7 +9,12 @@ import (
 	"fmt"
 	"sync"
 
+	"github.com/ortuman/jackal/component/httpupload"
+	"github.com/ortuman/jackal/component/muc"
+	"github.com/ortuman/jackal/component/pubsub"
+	"github.com/ortuman/jackal/component/xep0114"
 	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xmpp"
 )
@@ -47,6 +52,19 @@ func Initialize(cfg *Config) {
 		}
 		comps[host] = c
 	}
+	// start external component listeners (XEP-0114)
+	discoInfo := module.Modules().DiscoInfo
+	extHosts := make(map[string]struct{})
+	for i := range cfg.External {
+		extCfg := &cfg.External[i]
+		_, ok1 := comps[extCfg.Host]
+		_, ok2 := extHosts[extCfg.Host]
+		if ok1 || ok2 {
+			log.Fatalf("%v", fmt.Errorf("component host name conflict: %s", extCfg.Host))
+		}
+		extHosts[extCfg.Host] = struct{}{}
+		xep0114.New(extCfg, discoInfo, shutdownCh)
+	}
 	initialized = true
 }
 
@@ -89,11 +107,15 @@ func GetAll() []Component {
 
 func loadComponents(cfg *Config) []Component {
 	var ret []Component
-	/*
-		discoInfo := module.Modules().DiscoInfo
-		if cfg.HttpUpload != nil {
-			ret = append(ret, httpupload.New(cfg.HttpUpload, discoInfo, shutdownCh))
-		}
-	*/
+	discoInfo := module.Modules().DiscoInfo
+	if cfg.HttpUpload != nil {
+		ret = append(ret, httpupload.New(cfg.HttpUpload, discoInfo, shutdownCh))
+	}
+	if cfg.Muc != nil {
+		ret = append(ret, muc.New(cfg.Muc, discoInfo, shutdownCh))
+	}
+	if cfg.PubSub != nil {
+		ret = append(ret, pubsub.New(cfg.PubSub, discoInfo, shutdownCh))
+	}
 	return ret
 }
@@ -5,7 +5,17 @@
 
 package component
 
+import (
+	"github.com/ortuman/jackal/component/httpupload"
+	"github.com/ortuman/jackal/component/muc"
+	"github.com/ortuman/jackal/component/pubsub"
+	"github.com/ortuman/jackal/component/xep0114"
+)
+
 // Config contains all components configuration.
 type Config struct {
-	// HttpUpload *httpupload.Config `yaml:"http_upload"`
+	HttpUpload *httpupload.Config `yaml:"http_upload"`
+	Muc        *muc.Config        `yaml:"muc"`
+	PubSub     *pubsub.Config     `yaml:"pubsub"`
+	External   []xep0114.Config   `yaml:"external"`
 }
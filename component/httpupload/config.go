@@ -0,0 +1,94 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"errors"
+	"net/url"
+	"time"
+)
+
+const (
+	defaultName        = "HTTP File Upload"
+	defaultPort        = 4430
+	defaultUploadPath  = "/var/lib/jackal/httpupload"
+	defaultSizeLimit   = 1048576
+	defaultSlotTimeout = time.Duration(5) * time.Minute
+)
+
+// Config represents HTTP File Upload component (XEP-0363) configuration.
+type Config struct {
+	Host        string
+	Name        string
+	BaseURL     string
+	BindAddress string
+	Port        int
+	CertFile    string
+	PrivKeyFile string
+	UploadPath  string
+	SizeLimit   int64
+	Quota       int64
+	ExpireAfter time.Duration
+}
+
+type configProxy struct {
+	Host        string `yaml:"host"`
+	Name        string `yaml:"name"`
+	BaseURL     string `yaml:"base_url"`
+	BindAddress string `yaml:"bind_addr"`
+	Port        int    `yaml:"port"`
+	CertFile    string `yaml:"cert_path"`
+	PrivKeyFile string `yaml:"privkey_path"`
+	UploadPath  string `yaml:"upload_path"`
+	SizeLimit   int64  `yaml:"size_limit"`
+	Quota       int64  `yaml:"quota"`
+	ExpireAfter int    `yaml:"expire_after"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Host) == 0 {
+		return errors.New("httpupload.Config: host must be specified")
+	}
+	u, err := url.Parse(p.BaseURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return errors.New("httpupload.Config: invalid base url")
+	}
+	if (len(p.CertFile) > 0) != (len(p.PrivKeyFile) > 0) {
+		return errors.New("httpupload.Config: both cert and private key paths must be specified")
+	}
+	if p.SizeLimit < 0 || p.Quota < 0 || p.ExpireAfter < 0 {
+		return errors.New("httpupload.Config: size limit, quota and expiration must be 0 or higher")
+	}
+	c.Host = p.Host
+	c.Name = p.Name
+	if len(c.Name) == 0 {
+		c.Name = defaultName
+	}
+	c.BaseURL = p.BaseURL
+	c.BindAddress = p.BindAddress
+	c.Port = p.Port
+	if c.Port == 0 {
+		c.Port = defaultPort
+	}
+	c.CertFile = p.CertFile
+	c.PrivKeyFile = p.PrivKeyFile
+	c.UploadPath = p.UploadPath
+	if len(c.UploadPath) == 0 {
+		c.UploadPath = defaultUploadPath
+	}
+	c.SizeLimit = p.SizeLimit
+	if c.SizeLimit == 0 {
+		c.SizeLimit = defaultSizeLimit
+	}
+	c.Quota = p.Quota
+	c.ExpireAfter = time.Duration(p.ExpireAfter) * time.Second
+	return nil
+}
@@ -0,0 +1,362 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const mailboxSize = 2048
+
+const (
+	httpUploadNamespace = "urn:xmpp:http:upload:0"
+
+	discoInfoNamespace = "http://jabber.org/protocol/disco#info"
+)
+
+type slot struct {
+	id          string
+	owner       string
+	filename    string
+	size        int64
+	contentType string
+	tm          *time.Timer
+}
+
+type file struct {
+	owner string
+	size  int64
+	tm    *time.Timer
+}
+
+// fileMetadata is stored next to every uploaded file,
+// so that it can be restored on component start.
+type fileMetadata struct {
+	Owner string `json:"owner"`
+	Size  int64  `json:"size"`
+}
+
+// HTTPUpload represents an HTTP File Upload service component.
+type HTTPUpload struct {
+	cfg        *Config
+	basePath   string
+	slots      map[string]*slot
+	files      map[string]*file
+	usage      map[string]int64
+	srv        *server
+	actorCh    chan func()
+	shutdownCh <-chan struct{}
+}
+
+// New returns an HTTP File Upload component.
+func New(cfg *Config, disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *HTTPUpload {
+	x := &HTTPUpload{
+		cfg:        cfg,
+		slots:      make(map[string]*slot),
+		files:      make(map[string]*file),
+		usage:      make(map[string]int64),
+		actorCh:    make(chan func(), mailboxSize),
+		shutdownCh: shutdownCh,
+	}
+	if u, err := url.Parse(cfg.BaseURL); err == nil {
+		x.basePath = strings.TrimSuffix(u.Path, "/")
+	}
+	if err := os.MkdirAll(cfg.UploadPath, os.ModePerm); err != nil {
+		log.Error(err)
+	}
+	x.loadFiles()
+	go x.loop()
+
+	x.srv = newServer(cfg, http.HandlerFunc(x.serveHTTP))
+	go x.srv.start()
+
+	if disco != nil {
+		disco.RegisterServerItem(xep0030.Item{Jid: cfg.Host, Name: cfg.Name})
+		disco.RegisterProvider(cfg.Host, x)
+	}
+	return x
+}
+
+// Host returns HTTP File Upload component host name.
+func (x *HTTPUpload) Host() string {
+	return x.cfg.Host
+}
+
+// ProcessStanza processes a stanza addressed to the HTTP File Upload service.
+func (x *HTTPUpload) ProcessStanza(stanza xmpp.Stanza, stm stream.C2S) {
+	x.actorCh <- func() { x.processStanza(stanza, stm) }
+}
+
+// Identities returns all identities associated to the HTTP File Upload service.
+func (x *HTTPUpload) Identities(toJID, fromJID *jid.JID, node string) []xep0030.Identity {
+	if !toJID.IsServer() || node != "" {
+		return nil
+	}
+	return []xep0030.Identity{{Category: "store", Type: "file", Name: x.cfg.Name}}
+}
+
+// Items returns HTTP File Upload service items.
+func (x *HTTPUpload) Items(toJID, fromJID *jid.JID, node string) ([]xep0030.Item, *xmpp.StanzaError) {
+	return nil, nil
+}
+
+// Features returns all features associated to the HTTP File Upload service.
+func (x *HTTPUpload) Features(toJID, fromJID *jid.JID, node string) ([]xep0030.Feature, *xmpp.StanzaError) {
+	if !toJID.IsServer() || node != "" {
+		return nil, xmpp.ErrItemNotFound
+	}
+	return []xep0030.Feature{discoInfoNamespace, httpUploadNamespace}, nil
+}
+
+// Form returns the data form announcing the service maximum file size.
+func (x *HTTPUpload) Form(toJID, fromJID *jid.JID, node string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	if !toJID.IsServer() || node != "" {
+		return nil, nil
+	}
+	return &xep0004.DataForm{
+		Type: xep0004.Result,
+		Fields: []xep0004.Field{
+			{Var: "FORM_TYPE", Type: xep0004.Hidden, Values: []string{httpUploadNamespace}},
+			{Var: "max-file-size", Values: []string{strconv.FormatInt(x.cfg.SizeLimit, 10)}},
+		},
+	}, nil
+}
+
+// runs on it's own goroutine
+func (x *HTTPUpload) loop() {
+	for {
+		select {
+		case f := <-x.actorCh:
+			f()
+		case <-x.shutdownCh:
+			x.srv.shutdown()
+			return
+		}
+	}
+}
+
+func (x *HTTPUpload) inActor(f func()) {
+	doneCh := make(chan struct{})
+	select {
+	case x.actorCh <- func() {
+		f()
+		close(doneCh)
+	}:
+		<-doneCh
+	case <-x.shutdownCh:
+	}
+}
+
+func (x *HTTPUpload) afterFunc(d time.Duration, f func()) *time.Timer {
+	return time.AfterFunc(d, func() {
+		select {
+		case x.actorCh <- f:
+		case <-x.shutdownCh:
+		}
+	})
+}
+
+func (x *HTTPUpload) processStanza(stanza xmpp.Stanza, stm stream.C2S) {
+	switch stanza := stanza.(type) {
+	case *xmpp.IQ:
+		if req := stanza.Elements().ChildNamespace("request", httpUploadNamespace); req != nil && stanza.IsGet() {
+			if stanza.ToJID().IsServer() {
+				x.requestSlot(stanza, req, stm)
+				return
+			}
+		}
+		if stanza.IsGet() || stanza.IsSet() {
+			stm.SendElement(stanza.ServiceUnavailableError())
+		}
+	case *xmpp.Message:
+		if !stanza.IsError() {
+			stm.SendElement(stanza.ServiceUnavailableError())
+		}
+	}
+}
+
+func (x *HTTPUpload) requestSlot(iq *xmpp.IQ, req xmpp.XElement, stm stream.C2S) {
+	filename := req.Attributes().Get("filename")
+	size, err := strconv.ParseInt(req.Attributes().Get("size"), 10, 64)
+	if err != nil || size <= 0 || !isValidFilename(filename) {
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	if size > x.cfg.SizeLimit {
+		maxSize := xmpp.NewElementName("max-file-size")
+		maxSize.SetText(strconv.FormatInt(x.cfg.SizeLimit, 10))
+		tooLarge := xmpp.NewElementNamespace("file-too-large", httpUploadNamespace)
+		tooLarge.AppendElement(maxSize)
+		stm.SendElement(xmpp.NewErrorStanzaFromStanza(iq, xmpp.ErrNotAcceptable, []xmpp.XElement{tooLarge}))
+		return
+	}
+	owner := iq.FromJID().ToBareJID().String()
+	if x.cfg.Quota > 0 && x.usage[owner]+size > x.cfg.Quota {
+		stm.SendElement(iq.ResourceConstraintError())
+		return
+	}
+	s := &slot{
+		id:          uuid.New(),
+		owner:       owner,
+		filename:    filename,
+		size:        size,
+		contentType: req.Attributes().Get("content-type"),
+	}
+	s.tm = x.afterFunc(defaultSlotTimeout, func() { x.releaseSlot(s.id) })
+	x.slots[s.id] = s
+	x.usage[owner] += size // reserve slot space
+
+	fileURL := strings.TrimSuffix(x.cfg.BaseURL, "/") + "/" + s.id + "/" + url.PathEscape(filename)
+	put := xmpp.NewElementName("put")
+	put.SetAttribute("url", fileURL)
+	get := xmpp.NewElementName("get")
+	get.SetAttribute("url", fileURL)
+
+	slotElem := xmpp.NewElementNamespace("slot", httpUploadNamespace)
+	slotElem.AppendElement(put)
+	slotElem.AppendElement(get)
+
+	result := iq.ResultIQ()
+	result.AppendElement(slotElem)
+	stm.SendElement(result)
+}
+
+// releaseSlot discards a non used upload slot.
+func (x *HTTPUpload) releaseSlot(id string) {
+	s := x.slots[id]
+	if s == nil {
+		return
+	}
+	delete(x.slots, id)
+	x.releaseUsage(s.owner, s.size)
+}
+
+// takeSlot returns and removes an upload slot, making sure
+// it's not reused by a later request.
+func (x *HTTPUpload) takeSlot(id, filename string) *slot {
+	s := x.slots[id]
+	if s == nil || s.filename != filename {
+		return nil
+	}
+	s.tm.Stop()
+	delete(x.slots, id)
+	return s
+}
+
+func (x *HTTPUpload) registerFile(id, owner string, size int64, expireAfter time.Duration) {
+	f := &file{owner: owner, size: size}
+	if expireAfter > 0 {
+		f.tm = x.afterFunc(expireAfter, func() { x.expireFile(id) })
+	}
+	x.files[id] = f
+}
+
+func (x *HTTPUpload) expireFile(id string) {
+	f := x.files[id]
+	if f == nil {
+		return
+	}
+	delete(x.files, id)
+	if err := os.RemoveAll(filepath.Join(x.cfg.UploadPath, id)); err != nil {
+		log.Error(err)
+	}
+	if err := os.Remove(x.metadataPath(id)); err != nil && !os.IsNotExist(err) {
+		log.Error(err)
+	}
+	x.releaseUsage(f.owner, f.size)
+	log.Infof("httpupload: expired file... (id: %s)", id)
+}
+
+func (x *HTTPUpload) releaseUsage(owner string, size int64) {
+	if len(owner) == 0 {
+		return
+	}
+	x.usage[owner] -= size
+	if x.usage[owner] <= 0 {
+		delete(x.usage, owner)
+	}
+}
+
+// loadFiles schedules expiration of files uploaded before
+// the component was started.
+func (x *HTTPUpload) loadFiles() {
+	dirs, err := ioutil.ReadDir(x.cfg.UploadPath)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for _, dir := range dirs {
+		if !dir.IsDir() {
+			continue
+		}
+		var expireAfter time.Duration
+		if x.cfg.ExpireAfter > 0 {
+			expireAfter = x.cfg.ExpireAfter - time.Since(dir.ModTime())
+			if expireAfter <= 0 {
+				expireAfter = time.Nanosecond
+			}
+		}
+		id := dir.Name()
+		md, err := x.readMetadata(id)
+		if err != nil {
+			log.Error(err)
+			md = &fileMetadata{}
+		}
+		x.registerFile(id, md.Owner, md.Size, expireAfter)
+		if len(md.Owner) > 0 {
+			x.usage[md.Owner] += md.Size
+		}
+	}
+}
+
+func (x *HTTPUpload) writeMetadata(id string, md *fileMetadata) error {
+	b, err := json.Marshal(md)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(x.metadataPath(id), b, 0600)
+}
+
+func (x *HTTPUpload) readMetadata(id string) (*fileMetadata, error) {
+	b, err := ioutil.ReadFile(x.metadataPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var md fileMetadata
+	if err := json.Unmarshal(b, &md); err != nil {
+		return nil, err
+	}
+	return &md, nil
+}
+
+// metadataPath returns file metadata path, which is kept outside of the
+// upload directory in order not to be served along with the file.
+func (x *HTTPUpload) metadataPath(id string) string {
+	return filepath.Join(x.cfg.UploadPath, id+".meta")
+}
+
+func isValidFilename(filename string) bool {
+	if len(filename) == 0 || filename == "." || filename == ".." {
+		return false
+	}
+	return !strings.ContainsAny(filename, "/\\")
+}
@@ -0,0 +1,152 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPUpload_Config(t *testing.T) {
+	var cfg Config
+	err := cfg.UnmarshalYAML(func(v interface{}) error {
+		*v.(*configProxy) = configProxy{BaseURL: "https://jackal.im:4430/upload"}
+		return nil
+	})
+	require.NotNil(t, err) // missing host
+
+	err = cfg.UnmarshalYAML(func(v interface{}) error {
+		*v.(*configProxy) = configProxy{Host: "upload.jackal.im", BaseURL: "jackal.im/upload"}
+		return nil
+	})
+	require.NotNil(t, err) // invalid base url
+
+	err = cfg.UnmarshalYAML(func(v interface{}) error {
+		*v.(*configProxy) = configProxy{Host: "upload.jackal.im", BaseURL: "https://jackal.im:4430/upload", Quota: -1}
+		return nil
+	})
+	require.NotNil(t, err)
+
+	err = cfg.UnmarshalYAML(func(v interface{}) error {
+		*v.(*configProxy) = configProxy{Host: "upload.jackal.im", BaseURL: "https://jackal.im:4430/upload", ExpireAfter: 600}
+		return nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, "upload.jackal.im", cfg.Host)
+	require.Equal(t, defaultName, cfg.Name)
+	require.Equal(t, defaultPort, cfg.Port)
+	require.Equal(t, defaultUploadPath, cfg.UploadPath)
+	require.Equal(t, int64(defaultSizeLimit), cfg.SizeLimit)
+	require.Equal(t, time.Duration(600)*time.Second, cfg.ExpireAfter)
+}
+
+func TestHTTPUpload_Disco(t *testing.T) {
+	x, shutdownCh := tUtilHTTPUploadNew(t, 0)
+	defer tUtilHTTPUploadShutdown(x, shutdownCh)
+
+	require.Equal(t, "upload.jackal.im", x.Host())
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	srvJID, _ := jid.New("", "upload.jackal.im", "", true)
+
+	identities := x.Identities(srvJID, j, "")
+	require.Equal(t, 1, len(identities))
+	require.Equal(t, "store", identities[0].Category)
+	require.Equal(t, "file", identities[0].Type)
+
+	features, sErr := x.Features(srvJID, j, "")
+	require.Nil(t, sErr)
+	require.Contains(t, features, httpUploadNamespace)
+
+	form, sErr := x.Form(srvJID, j, "")
+	require.Nil(t, sErr)
+	require.NotNil(t, form)
+	require.Equal(t, 2, len(form.Fields))
+	require.Equal(t, "max-file-size", form.Fields[1].Var)
+	require.Equal(t, []string{"1024"}, form.Fields[1].Values)
+}
+
+func TestHTTPUpload_RequestSlot(t *testing.T) {
+	x, shutdownCh := tUtilHTTPUploadNew(t, 1536)
+	defer tUtilHTTPUploadShutdown(x, shutdownCh)
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+
+	// bad request
+	x.ProcessStanza(tUtilRequestSlotIQ(j, "", "512"), stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+
+	// file too large
+	x.ProcessStanza(tUtilRequestSlotIQ(j, "image.jpg", "2048"), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+	tooLarge := elem.Error().Elements().ChildNamespace("file-too-large", httpUploadNamespace)
+	require.NotNil(t, tooLarge)
+	require.Equal(t, "1024", tooLarge.Elements().Child("max-file-size").Text())
+
+	// slot granted
+	x.ProcessStanza(tUtilRequestSlotIQ(j, "my image.jpg", "1024"), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	slot := elem.Elements().ChildNamespace("slot", httpUploadNamespace)
+	require.NotNil(t, slot)
+	putURL := slot.Elements().Child("put").Attributes().Get("url")
+	require.Contains(t, putURL, "http://upload.jackal.im/upload/")
+	require.Contains(t, putURL, "/my%20image.jpg")
+	require.Equal(t, putURL, slot.Elements().Child("get").Attributes().Get("url"))
+
+	// quota exceeded
+	x.ProcessStanza(tUtilRequestSlotIQ(j, "image.jpg", "1024"), stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ErrResourceConstraint.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func tUtilHTTPUploadNew(t *testing.T, quota int64) (*HTTPUpload, chan struct{}) {
+	dir, err := ioutil.TempDir("", "httpupload")
+	require.Nil(t, err)
+
+	shutdownCh := make(chan struct{})
+	cfg := &Config{
+		Host:        "upload.jackal.im",
+		Name:        defaultName,
+		BaseURL:     "http://upload.jackal.im/upload",
+		BindAddress: "127.0.0.1",
+		UploadPath:  dir,
+		SizeLimit:   1024,
+		Quota:       quota,
+	}
+	return New(cfg, nil, shutdownCh), shutdownCh
+}
+
+func tUtilHTTPUploadShutdown(x *HTTPUpload, shutdownCh chan struct{}) {
+	close(shutdownCh)
+	os.RemoveAll(x.cfg.UploadPath)
+}
+
+func tUtilRequestSlotIQ(fromJID *jid.JID, filename, size string) *xmpp.IQ {
+	srvJID, _ := jid.New("", "upload.jackal.im", "", true)
+
+	req := xmpp.NewElementNamespace("request", httpUploadNamespace)
+	req.SetAttribute("filename", filename)
+	req.SetAttribute("size", size)
+	req.SetAttribute("content-type", "image/jpeg")
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(fromJID)
+	iq.SetToJID(srvJID)
+	iq.AppendElement(req)
+	return iq
+}
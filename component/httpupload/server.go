@@ -0,0 +1,156 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ortuman/jackal/log"
+)
+
+type server struct {
+	cfg     *Config
+	httpSrv *http.Server
+}
+
+func newServer(cfg *Config, handler http.Handler) *server {
+	address := cfg.BindAddress + ":" + strconv.Itoa(cfg.Port)
+	return &server{
+		cfg:     cfg,
+		httpSrv: &http.Server{Addr: address, Handler: handler},
+	}
+}
+
+func (s *server) start() {
+	log.Infof("httpupload: listening at %s", s.httpSrv.Addr)
+
+	var err error
+	if len(s.cfg.CertFile) > 0 {
+		err = s.httpSrv.ListenAndServeTLS(s.cfg.CertFile, s.cfg.PrivKeyFile)
+	} else {
+		err = s.httpSrv.ListenAndServe()
+	}
+	if err != nil && err != http.ErrServerClosed {
+		log.Error(err)
+	}
+}
+
+func (s *server) shutdown() {
+	s.httpSrv.Close()
+}
+
+func (x *HTTPUpload) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	id, filename, ok := x.parsePath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		x.putFile(w, r, id, filename)
+	case http.MethodGet, http.MethodHead:
+		x.getFile(w, r, id, filename)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (x *HTTPUpload) putFile(w http.ResponseWriter, r *http.Request, id, filename string) {
+	var s *slot
+	x.inActor(func() { s = x.takeSlot(id, filename) })
+	if s == nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+	status := x.storeFile(r, s)
+	if status != http.StatusCreated {
+		x.inActor(func() { x.releaseUsage(s.owner, s.size) })
+		w.WriteHeader(status)
+		return
+	}
+	x.inActor(func() { x.registerFile(s.id, s.owner, s.size, x.cfg.ExpireAfter) })
+	log.Infof("httpupload: stored file... (id: %s, size: %d)", s.id, s.size)
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (x *HTTPUpload) storeFile(r *http.Request, s *slot) int {
+	if r.ContentLength > s.size {
+		return http.StatusRequestEntityTooLarge
+	} else if r.ContentLength != s.size {
+		return http.StatusBadRequest
+	}
+	if len(s.contentType) > 0 && r.Header.Get("Content-Type") != s.contentType {
+		return http.StatusBadRequest
+	}
+	dir := filepath.Join(x.cfg.UploadPath, s.id)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		log.Error(err)
+		return http.StatusInternalServerError
+	}
+	f, err := os.Create(filepath.Join(dir, s.filename))
+	if err != nil {
+		log.Error(err)
+		return http.StatusInternalServerError
+	}
+	n, err := io.Copy(f, io.LimitReader(r.Body, s.size))
+	f.Close()
+	if err != nil || n != s.size {
+		if err != nil {
+			log.Error(err)
+		}
+		os.RemoveAll(dir)
+		return http.StatusBadRequest
+	}
+	if err := x.writeMetadata(s.id, &fileMetadata{Owner: s.owner, Size: s.size}); err != nil {
+		log.Error(err)
+		os.RemoveAll(dir)
+		return http.StatusInternalServerError
+	}
+	return http.StatusCreated
+}
+
+func (x *HTTPUpload) getFile(w http.ResponseWriter, r *http.Request, id, filename string) {
+	var exists bool
+	x.inActor(func() { _, exists = x.files[id] })
+	if !exists {
+		http.NotFound(w, r)
+		return
+	}
+	f, err := os.Open(filepath.Join(x.cfg.UploadPath, id, filename))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeContent(w, r, filename, fi.ModTime(), f)
+}
+
+// parsePath extracts slot identifier and file name from a request path
+// with the form '<base_path>/<id>/<filename>'.
+func (x *HTTPUpload) parsePath(path string) (id string, filename string, ok bool) {
+	if !strings.HasPrefix(path, x.basePath+"/") {
+		return "", "", false
+	}
+	parts := strings.Split(path[len(x.basePath)+1:], "/")
+	if len(parts) != 2 || len(parts[0]) == 0 || !isValidFilename(parts[1]) {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
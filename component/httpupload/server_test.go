@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package httpupload
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_PutGet(t *testing.T) {
+	x, shutdownCh := tUtilHTTPUploadNew(t, 0)
+	defer tUtilHTTPUploadShutdown(x, shutdownCh)
+
+	putURL := tUtilRequestSlot(t, x, "image.jpg", "4")
+	u, _ := url.Parse(putURL)
+
+	// not existing slot
+	rec := tUtilServeHTTP(x, http.MethodPut, "/upload/"+uuid.New()+"/image.jpg", "image/jpeg", []byte{1, 2, 3, 4})
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	// not yet uploaded
+	rec = tUtilServeHTTP(x, http.MethodGet, u.Path, "", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = tUtilServeHTTP(x, http.MethodPut, u.Path, "image/jpeg", []byte{1, 2, 3, 4})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	// slots can be used only once
+	rec = tUtilServeHTTP(x, http.MethodPut, u.Path, "image/jpeg", []byte{1, 2, 3, 4})
+	require.Equal(t, http.StatusForbidden, rec.Code)
+
+	rec = tUtilServeHTTP(x, http.MethodGet, u.Path, "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, []byte{1, 2, 3, 4}, rec.Body.Bytes())
+}
+
+func TestServer_PutSizeMismatch(t *testing.T) {
+	x, shutdownCh := tUtilHTTPUploadNew(t, 4)
+	defer tUtilHTTPUploadShutdown(x, shutdownCh)
+
+	putURL := tUtilRequestSlot(t, x, "image.jpg", "4")
+	u, _ := url.Parse(putURL)
+
+	rec := tUtilServeHTTP(x, http.MethodPut, u.Path, "image/jpeg", []byte{1, 2, 3, 4, 5})
+	require.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+	// reserved quota must be released
+	tUtilRequestSlot(t, x, "image.jpg", "4")
+}
+
+func TestServer_Expiration(t *testing.T) {
+	x, shutdownCh := tUtilHTTPUploadNew(t, 0)
+	defer tUtilHTTPUploadShutdown(x, shutdownCh)
+	x.cfg.ExpireAfter = time.Millisecond * 250
+
+	putURL := tUtilRequestSlot(t, x, "image.jpg", "4")
+	u, _ := url.Parse(putURL)
+
+	rec := tUtilServeHTTP(x, http.MethodPut, u.Path, "image/jpeg", []byte{1, 2, 3, 4})
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	time.Sleep(time.Millisecond * 500)
+
+	rec = tUtilServeHTTP(x, http.MethodGet, u.Path, "", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	dirs, _ := ioutil.ReadDir(x.cfg.UploadPath)
+	require.Equal(t, 0, len(dirs))
+}
+
+func TestServer_LoadFiles(t *testing.T) {
+	dir, err := ioutil.TempDir("", "httpupload")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	id := uuid.New()
+	require.Nil(t, os.MkdirAll(filepath.Join(dir, id), os.ModePerm))
+	require.Nil(t, ioutil.WriteFile(filepath.Join(dir, id, "image.jpg"), []byte{1, 2, 3, 4}, os.ModePerm))
+
+	shutdownCh := make(chan struct{})
+	defer close(shutdownCh)
+
+	x := New(&Config{
+		Host:        "upload.jackal.im",
+		BaseURL:     "http://upload.jackal.im/upload",
+		BindAddress: "127.0.0.1",
+		UploadPath:  dir,
+		SizeLimit:   1024,
+	}, nil, shutdownCh)
+
+	rec := tUtilServeHTTP(x, http.MethodGet, "/upload/"+id+"/image.jpg", "", nil)
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, []byte{1, 2, 3, 4}, rec.Body.Bytes())
+}
+
+func TestServer_LoadFilesMetadata(t *testing.T) {
+	x, shutdownCh := tUtilHTTPUploadNew(t, 6)
+	defer os.RemoveAll(x.cfg.UploadPath)
+
+	putURL := tUtilRequestSlot(t, x, "image.jpg", "4")
+	u, _ := url.Parse(putURL)
+
+	rec := tUtilServeHTTP(x, http.MethodPut, u.Path, "image/jpeg", []byte{1, 2, 3, 4})
+	require.Equal(t, http.StatusCreated, rec.Code)
+	close(shutdownCh)
+
+	// restart component
+	shutdownCh = make(chan struct{})
+	defer close(shutdownCh)
+	x = New(x.cfg, nil, shutdownCh)
+
+	var f *file
+	var usage int64
+	x.inActor(func() {
+		id := filepath.Base(filepath.Dir(u.Path))
+		f = x.files[id]
+		usage = x.usage["ortuman@jackal.im"]
+	})
+	require.NotNil(t, f)
+	require.Equal(t, "ortuman@jackal.im", f.owner)
+	require.Equal(t, int64(4), f.size)
+	require.Equal(t, int64(4), usage)
+
+	// metadata is not served
+	rec = tUtilServeHTTP(x, http.MethodGet, filepath.Dir(u.Path)+".meta", "", nil)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func tUtilRequestSlot(t *testing.T, x *HTTPUpload, filename, size string) string {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+
+	x.ProcessStanza(tUtilRequestSlotIQ(j, filename, size), stm)
+	elem := stm.FetchElement()
+	slot := elem.Elements().ChildNamespace("slot", httpUploadNamespace)
+	require.NotNil(t, slot)
+	return slot.Elements().Child("put").Attributes().Get("url")
+}
+
+func tUtilServeHTTP(x *HTTPUpload, method, path, contentType string, body []byte) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, bytes.NewReader(body))
+	if len(contentType) > 0 {
+		req.Header.Set("Content-Type", contentType)
+	}
+	rec := httptest.NewRecorder()
+	x.serveHTTP(rec, req)
+	return rec
+}
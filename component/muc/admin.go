@@ -0,0 +1,213 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+type adminChange struct {
+	occ         *occupant
+	jid         *jid.JID
+	role        string
+	affiliation string
+}
+
+func (m *Muc) processAdminIQ(r *room, iq *xmpp.IQ, query xmpp.XElement, stm stream.C2S) {
+	if iq.IsGet() {
+		m.sendAdminList(r, iq, query, stm)
+	} else if iq.IsSet() {
+		m.applyAdminChanges(r, iq, query, stm)
+	}
+}
+
+func (m *Muc) sendAdminList(r *room, iq *xmpp.IQ, query xmpp.XElement, stm stream.C2S) {
+	item := query.Elements().Child("item")
+	if item == nil {
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	fromJID := iq.FromJID()
+	q := xmpp.NewElementNamespace("query", mucAdminNamespace)
+
+	if affiliation := item.Attributes().Get("affiliation"); len(affiliation) > 0 {
+		if !isValidAffiliation(affiliation) || affiliation == mucmodel.AffiliationNone {
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+		reqAffiliation := r.affiliation(fromJID)
+		if reqAffiliation != mucmodel.AffiliationOwner && reqAffiliation != mucmodel.AffiliationAdmin {
+			stm.SendElement(iq.ForbiddenError())
+			return
+		}
+		for _, j := range r.affiliationJIDs(affiliation) {
+			itemElem := xmpp.NewElementName("item")
+			itemElem.SetAttribute("affiliation", affiliation)
+			itemElem.SetAttribute("jid", j)
+			q.AppendElement(itemElem)
+		}
+	} else if role := item.Attributes().Get("role"); len(role) > 0 {
+		if !isValidRole(role) || role == roleNone {
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+		if reqOcc := r.occupantByJID(fromJID); reqOcc == nil || reqOcc.role != roleModerator {
+			stm.SendElement(iq.ForbiddenError())
+			return
+		}
+		for _, occ := range r.occupants {
+			if occ.role != role {
+				continue
+			}
+			itemElem := xmpp.NewElementName("item")
+			itemElem.SetAttribute("affiliation", r.affiliation(occ.jid))
+			itemElem.SetAttribute("role", occ.role)
+			itemElem.SetAttribute("nick", occ.nick)
+			itemElem.SetAttribute("jid", occ.jid.String())
+			q.AppendElement(itemElem)
+		}
+	} else {
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	result := iq.ResultIQ()
+	result.AppendElement(q)
+	stm.SendElement(result)
+}
+
+func (m *Muc) applyAdminChanges(r *room, iq *xmpp.IQ, query xmpp.XElement, stm stream.C2S) {
+	items := query.Elements().Children("item")
+	if len(items) == 0 {
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	// validate all changes before applying any of them
+	var changes []adminChange
+	for _, item := range items {
+		change, sErr := m.adminChange(r, iq.FromJID(), item)
+		if sErr != nil {
+			stm.SendElement(xmpp.NewErrorStanzaFromStanza(iq, sErr, nil))
+			return
+		}
+		changes = append(changes, *change)
+	}
+	for _, change := range changes {
+		if change.occ != nil {
+			m.changeRole(r, change.occ, change.role)
+		} else {
+			m.changeAffiliation(r, change.jid, change.affiliation)
+		}
+	}
+	m.persistRoom(r)
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (m *Muc) adminChange(r *room, fromJID *jid.JID, item xmpp.XElement) (*adminChange, *xmpp.StanzaError) {
+	reqAffiliation := r.affiliation(fromJID)
+	isReqPrivileged := reqAffiliation == mucmodel.AffiliationOwner || reqAffiliation == mucmodel.AffiliationAdmin
+
+	if role := item.Attributes().Get("role"); len(role) > 0 {
+		if !isValidRole(role) {
+			return nil, xmpp.ErrBadRequest
+		}
+		if reqOcc := r.occupantByJID(fromJID); reqOcc == nil || reqOcc.role != roleModerator {
+			return nil, xmpp.ErrForbidden
+		}
+		occ := r.occupantByNick(item.Attributes().Get("nick"))
+		if occ == nil {
+			return nil, xmpp.ErrNotAcceptable
+		}
+		switch r.affiliation(occ.jid) {
+		case mucmodel.AffiliationOwner, mucmodel.AffiliationAdmin:
+			if role != roleModerator {
+				return nil, xmpp.ErrNotAllowed
+			}
+		}
+		if (role == roleModerator || occ.role == roleModerator) && !isReqPrivileged {
+			return nil, xmpp.ErrNotAllowed
+		}
+		return &adminChange{occ: occ, role: role}, nil
+	}
+	if affiliation := item.Attributes().Get("affiliation"); len(affiliation) > 0 {
+		if !isValidAffiliation(affiliation) {
+			return nil, xmpp.ErrBadRequest
+		}
+		j, err := jid.NewWithString(item.Attributes().Get("jid"), false)
+		if err != nil {
+			return nil, xmpp.ErrBadRequest
+		}
+		if !isReqPrivileged {
+			return nil, xmpp.ErrForbidden
+		}
+		curAffiliation := r.affiliation(j)
+		if reqAffiliation == mucmodel.AffiliationAdmin {
+			if !isMemberAffiliation(curAffiliation) || !isMemberAffiliation(affiliation) {
+				return nil, xmpp.ErrNotAllowed
+			}
+		}
+		if curAffiliation == mucmodel.AffiliationOwner && affiliation != mucmodel.AffiliationOwner {
+			if len(r.affiliationJIDs(mucmodel.AffiliationOwner)) == 1 {
+				return nil, xmpp.ErrConflict // room must keep at least one owner
+			}
+		}
+		return &adminChange{jid: j.ToBareJID(), affiliation: affiliation}, nil
+	}
+	return nil, xmpp.ErrBadRequest
+}
+
+func (m *Muc) changeRole(r *room, occ *occupant, role string) {
+	if role == roleNone {
+		m.exitRoom(r, occ, xmpp.NewPresence(occ.jid, r.occupantJID(occ.nick), xmpp.UnavailableType), statusKicked)
+		return
+	}
+	occ.role = role
+	m.broadcastOccupantPresence(r, occ, occ.presence)
+}
+
+func (m *Muc) changeAffiliation(r *room, j *jid.JID, affiliation string) {
+	r.setAffiliation(j, affiliation)
+
+	for _, occ := range r.occupantsByBareJID(j) {
+		unavailable := xmpp.NewPresence(occ.jid, r.occupantJID(occ.nick), xmpp.UnavailableType)
+		switch {
+		case affiliation == mucmodel.AffiliationOutcast:
+			m.exitRoom(r, occ, unavailable, statusBanned)
+		case affiliation == mucmodel.AffiliationNone && r.entity.MembersOnly:
+			m.exitRoom(r, occ, unavailable, statusAffiliation)
+		default:
+			occ.role = r.defaultRole(affiliation)
+			m.broadcastOccupantPresence(r, occ, occ.presence)
+		}
+	}
+}
+
+func isValidRole(role string) bool {
+	switch role {
+	case roleModerator, roleParticipant, roleVisitor, roleNone:
+		return true
+	}
+	return false
+}
+
+func isValidAffiliation(affiliation string) bool {
+	switch affiliation {
+	case mucmodel.AffiliationOwner, mucmodel.AffiliationAdmin, mucmodel.AffiliationMember,
+		mucmodel.AffiliationOutcast, mucmodel.AffiliationNone:
+		return true
+	}
+	return false
+}
+
+func isMemberAffiliation(affiliation string) bool {
+	switch affiliation {
+	case mucmodel.AffiliationMember, mucmodel.AffiliationOutcast, mucmodel.AffiliationNone:
+		return true
+	}
+	return false
+}
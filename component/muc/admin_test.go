@@ -0,0 +1,134 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuc_KickOccupant(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im"}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := tUtilMucStream(j1)
+	stm2 := tUtilMucStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilMucCreateInstantRoom(t, m, stm1, roomJID, "firstwitch")
+	tUtilMucJoinRoom(t, m, stm2, roomJID, "secondwitch")
+	_ = stm1.FetchElement()
+
+	// participants can't kick
+	iq := tUtilMucAdminIQ(j2, roomJID, xmpp.SetType, map[string]string{"nick": "firstwitch", "role": roleNone})
+	m.ProcessStanza(iq, stm2)
+	elem := stm2.FetchElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.NotNil(t, elem.Error().Elements().Child("forbidden"))
+
+	// owners can't be kicked
+	iq = tUtilMucAdminIQ(j1, roomJID, xmpp.SetType, map[string]string{"nick": "firstwitch", "role": roleNone})
+	m.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("not-allowed"))
+
+	iq = tUtilMucAdminIQ(j1, roomJID, xmpp.SetType, map[string]string{"nick": "secondwitch", "role": roleNone})
+	m.ProcessStanza(iq, stm1)
+
+	elem = stm2.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{statusKicked, statusSelfPresence}, tUtilMucStatusCodes(elem.Elements().ChildNamespace("x", mucUserNamespace)))
+
+	elem = stm1.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, "coven@conference.jackal.im/secondwitch", elem.From())
+	require.Equal(t, []string{statusKicked}, tUtilMucStatusCodes(elem.Elements().ChildNamespace("x", mucUserNamespace)))
+
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.Equal(t, iq.ID(), elem.ID())
+}
+
+func TestMuc_BanUser(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im"}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := tUtilMucStream(j1)
+	stm2 := tUtilMucStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilMucCreateInstantRoom(t, m, stm1, roomJID, "firstwitch")
+	tUtilMucJoinRoom(t, m, stm2, roomJID, "secondwitch")
+	_ = stm1.FetchElement()
+
+	// the last owner can't be removed
+	iq := tUtilMucAdminIQ(j1, roomJID, xmpp.SetType, map[string]string{"jid": "ortuman@jackal.im", "affiliation": mucmodel.AffiliationNone})
+	m.ProcessStanza(iq, stm1)
+	elem := stm1.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("conflict"))
+
+	iq = tUtilMucAdminIQ(j1, roomJID, xmpp.SetType, map[string]string{"jid": "noelia@jackal.im", "affiliation": mucmodel.AffiliationOutcast})
+	m.ProcessStanza(iq, stm1)
+
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, []string{statusBanned, statusSelfPresence}, tUtilMucStatusCodes(elem.Elements().ChildNamespace("x", mucUserNamespace)))
+
+	_ = stm1.FetchElement() // secondwitch unavailable presence
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// fetch outcast list
+	iq = tUtilMucAdminIQ(j1, roomJID, xmpp.GetType, map[string]string{"affiliation": mucmodel.AffiliationOutcast})
+	m.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	items := elem.Elements().ChildNamespace("query", mucAdminNamespace).Elements().Children("item")
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "noelia@jackal.im", items[0].Attributes().Get("jid"))
+
+	// banned users can't join again
+	p := tUtilMucJoinPresence(j2, tUtilMucOccupantJID(roomJID, "secondwitch"))
+	m.ProcessStanza(p, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.NotNil(t, elem.Error().Elements().Child("forbidden"))
+}
+
+func tUtilMucAdminIQ(from, to *jid.JID, iqType string, attrs map[string]string) *xmpp.IQ {
+	iq := xmpp.NewIQType(uuid.New(), iqType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+	item := xmpp.NewElementName("item")
+	for label, value := range attrs {
+		item.SetAttribute(label, value)
+	}
+	q := xmpp.NewElementNamespace("query", mucAdminNamespace)
+	q.AppendElement(item)
+	iq.AppendElement(q)
+	return iq
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+func (m *Muc) processMessage(message *xmpp.Message, stm stream.C2S) {
+	toJID := message.ToJID()
+	r := m.rooms[toJID.ToBareJID().String()]
+	if r == nil {
+		stm.SendElement(message.ItemNotFoundError())
+		return
+	}
+	occ := r.occupantByJID(message.FromJID())
+	if occ == nil {
+		stm.SendElement(message.NotAcceptableError())
+		return
+	}
+	if toJID.IsFullWithUser() {
+		m.sendPrivateMessage(r, occ, message, stm)
+		return
+	}
+	if !message.IsGroupChat() {
+		stm.SendElement(message.BadRequestError())
+		return
+	}
+	subject := message.Elements().Child("subject")
+	if subject != nil && message.Elements().Child("body") == nil {
+		m.changeSubject(r, occ, message, subject.Text(), stm)
+		return
+	}
+	if occ.role == roleVisitor || occ.role == roleNone {
+		stm.SendElement(message.ForbiddenError())
+		return
+	}
+	m.broadcastMessage(r, occ, message)
+	if message.IsMessageWithBody() {
+		m.addToHistory(r, occ, message)
+	}
+}
+
+func (m *Muc) sendPrivateMessage(r *room, occ *occupant, message *xmpp.Message, stm stream.C2S) {
+	if message.IsGroupChat() {
+		stm.SendElement(message.BadRequestError())
+		return
+	}
+	target := r.occupantByNick(message.ToJID().Resource())
+	if target == nil {
+		stm.SendElement(message.ItemNotFoundError())
+		return
+	}
+	msg, err := xmpp.NewMessageFromElement(message, r.occupantJID(occ.nick), target.jid)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	msg.AppendElement(xmpp.NewElementNamespace("x", mucUserNamespace))
+	m.route(target, msg)
+}
+
+func (m *Muc) changeSubject(r *room, occ *occupant, message *xmpp.Message, subject string, stm stream.C2S) {
+	if occ.role != roleModerator {
+		stm.SendElement(message.ForbiddenError())
+		return
+	}
+	r.entity.Subject = subject
+	m.persistRoom(r)
+	m.broadcastMessage(r, occ, message)
+}
+
+func (m *Muc) broadcastMessage(r *room, occ *occupant, message *xmpp.Message) {
+	fromJID := r.occupantJID(occ.nick)
+	for _, o := range r.occupants {
+		msg, err := xmpp.NewMessageFromElement(message, fromJID, o.jid)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		m.route(o, msg)
+	}
+}
+
+func (m *Muc) addToHistory(r *room, occ *occupant, message *xmpp.Message) {
+	msg, err := xmpp.NewMessageFromElement(message, r.occupantJID(occ.nick), r.jid)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	r.appendHistory(msg, m.cfg.MaxHistory)
+}
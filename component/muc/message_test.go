@@ -0,0 +1,201 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuc_GroupChatMessage(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im", MaxHistory: 1}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := tUtilMucStream(j1)
+	stm2 := tUtilMucStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilMucCreateInstantRoom(t, m, stm1, roomJID, "firstwitch")
+
+	// not an occupant
+	msg := tUtilMucMessage(j2, roomJID, xmpp.GroupChatType, "Harpier cries: 'tis time, 'tis time.")
+	m.ProcessStanza(msg, stm2)
+	elem := stm2.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("not-acceptable"))
+
+	// wrong type
+	msg = tUtilMucMessage(j1, roomJID, xmpp.ChatType, "Thrice the brinded cat hath mew'd.")
+	m.ProcessStanza(msg, stm1)
+	elem = stm1.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("bad-request"))
+
+	msg = tUtilMucMessage(j1, roomJID, xmpp.GroupChatType, "Thrice the brinded cat hath mew'd.")
+	m.ProcessStanza(msg, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, msg.ID(), elem.ID())
+	require.Equal(t, "coven@conference.jackal.im/firstwitch", elem.From())
+
+	msg = tUtilMucMessage(j1, roomJID, xmpp.GroupChatType, "Thrice and once the hedge-pig whined.")
+	m.ProcessStanza(msg, stm1)
+	_ = stm1.FetchElement()
+
+	// history on join
+	p := tUtilMucJoinPresence(j2, tUtilMucOccupantJID(roomJID, "secondwitch"))
+	m.ProcessStanza(p, stm2)
+	_ = stm2.FetchElement() // firstwitch presence
+	_ = stm2.FetchElement() // self presence
+
+	elem = stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, msg.ID(), elem.ID())
+	require.Equal(t, "coven@conference.jackal.im/firstwitch", elem.From())
+	require.NotNil(t, elem.Elements().ChildNamespace("delay", "urn:xmpp:delay"))
+
+	elem = stm2.FetchElement()
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	_ = stm1.FetchElement() // secondwitch presence
+
+	// negative history size is ignored
+	j3, _ := jid.New("romeo", "jackal.im", "garden", true)
+	stm3 := tUtilMucStream(j3)
+	defer router.Unbind(stm3)
+
+	p = tUtilMucJoinPresence(j3, tUtilMucOccupantJID(roomJID, "thirdwitch"))
+	history := xmpp.NewElementName("history")
+	history.SetAttribute("maxstanzas", "-1")
+	p.Elements().ChildNamespace("x", mucNamespace).(*xmpp.Element).AppendElement(history)
+	m.ProcessStanza(p, stm3)
+	_ = stm3.FetchElement() // firstwitch presence
+	_ = stm3.FetchElement() // secondwitch presence
+	_ = stm3.FetchElement() // self presence
+
+	elem = stm3.FetchElement()
+	require.Equal(t, msg.ID(), elem.ID())
+
+	_ = stm1.FetchElement() // thirdwitch presence
+	_ = stm2.FetchElement() // thirdwitch presence
+
+	// visitors can't send messages to moderated rooms
+	m.inActor(func() { m.rooms[roomJID.String()].occupantByJID(j2).role = roleVisitor })
+
+	msg = tUtilMucMessage(j2, roomJID, xmpp.GroupChatType, "Round about the cauldron go.")
+	m.ProcessStanza(msg, stm2)
+	elem = stm2.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("forbidden"))
+}
+
+func TestMuc_ChangeSubject(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im"}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := tUtilMucStream(j1)
+	stm2 := tUtilMucStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilMucCreateInstantRoom(t, m, stm1, roomJID, "firstwitch")
+	tUtilMucJoinRoom(t, m, stm2, roomJID, "secondwitch")
+	_ = stm1.FetchElement()
+
+	subject := xmpp.NewElementName("subject")
+	subject.SetText("Fire Burn and Cauldron Bubble!")
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.GroupChatType)
+	msg.SetFromJID(j2)
+	msg.SetToJID(roomJID)
+	msg.AppendElement(subject)
+
+	m.ProcessStanza(msg, stm2)
+	elem := stm2.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("forbidden"))
+
+	msg.SetFromJID(j1)
+	m.ProcessStanza(msg, stm1)
+
+	elem = stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "Fire Burn and Cauldron Bubble!", elem.Elements().Child("subject").Text())
+
+	m.inActor(func() {
+		require.Equal(t, "Fire Burn and Cauldron Bubble!", m.rooms[roomJID.String()].entity.Subject)
+	})
+}
+
+func TestMuc_PrivateMessage(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im"}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := tUtilMucStream(j1)
+	stm2 := tUtilMucStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilMucCreateInstantRoom(t, m, stm1, roomJID, "firstwitch")
+	tUtilMucJoinRoom(t, m, stm2, roomJID, "secondwitch")
+	_ = stm1.FetchElement()
+
+	msg := tUtilMucMessage(j2, tUtilMucOccupantJID(roomJID, "nobody"), xmpp.ChatType, "I'll give thee a wind.")
+	m.ProcessStanza(msg, stm2)
+	elem := stm2.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("item-not-found"))
+
+	msg = tUtilMucMessage(j2, tUtilMucOccupantJID(roomJID, "firstwitch"), xmpp.ChatType, "I'll give thee a wind.")
+	m.ProcessStanza(msg, stm2)
+	elem = stm1.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, msg.ID(), elem.ID())
+	require.Equal(t, "coven@conference.jackal.im/secondwitch", elem.From())
+	require.Equal(t, j1.String(), elem.To())
+	require.NotNil(t, elem.Elements().ChildNamespace("x", mucUserNamespace))
+}
+
+func tUtilMucMessage(from, to *jid.JID, messageType, text string) *xmpp.Message {
+	msg := xmpp.NewMessageType(uuid.New(), messageType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	body := xmpp.NewElementName("body")
+	body.SetText(text)
+	msg.AppendElement(body)
+	return msg
+}
+
+func tUtilMucJoinRoom(t *testing.T, m *Muc, stm *stream.MockC2S, roomJID *jid.JID, nick string) {
+	m.ProcessStanza(tUtilMucJoinPresence(stm.JID(), tUtilMucOccupantJID(roomJID, nick)), stm)
+	for {
+		elem := stm.FetchElement()
+		require.NotEqual(t, xmpp.ErrorType, elem.Type())
+		if elem.Name() == "message" && elem.Elements().Child("subject") != nil {
+			return // subject is the last element sent on join
+		}
+	}
+}
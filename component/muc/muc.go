@@ -0,0 +1,275 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"errors"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+const mailboxSize = 2048
+
+const defaultMaxHistory = 20
+
+const (
+	mucNamespace      = "http://jabber.org/protocol/muc"
+	mucUserNamespace  = "http://jabber.org/protocol/muc#user"
+	mucAdminNamespace = "http://jabber.org/protocol/muc#admin"
+	mucOwnerNamespace = "http://jabber.org/protocol/muc#owner"
+
+	discoInfoNamespace  = "http://jabber.org/protocol/disco#info"
+	discoItemsNamespace = "http://jabber.org/protocol/disco#items"
+)
+
+// Config represents Multi-User Chat component (XEP-0045) configuration.
+type Config struct {
+	Host       string
+	Name       string
+	MaxHistory int
+}
+
+type configProxy struct {
+	Host       string `yaml:"host"`
+	Name       string `yaml:"name"`
+	MaxHistory int    `yaml:"max_history"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Host) == 0 {
+		return errors.New("muc.Config: host must be specified")
+	}
+	c.Host = p.Host
+	c.Name = p.Name
+	if len(c.Name) == 0 {
+		c.Name = "Chatrooms"
+	}
+	c.MaxHistory = p.MaxHistory
+	if c.MaxHistory == 0 {
+		c.MaxHistory = defaultMaxHistory
+	}
+	return nil
+}
+
+// Muc represents a Multi-User Chat service component.
+type Muc struct {
+	cfg        *Config
+	rooms      map[string]*room
+	actorCh    chan func()
+	shutdownCh <-chan struct{}
+}
+
+// New returns a Multi-User Chat component.
+func New(cfg *Config, disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *Muc {
+	m := &Muc{
+		cfg:        cfg,
+		rooms:      make(map[string]*room),
+		actorCh:    make(chan func(), mailboxSize),
+		shutdownCh: shutdownCh,
+	}
+	m.loadRooms()
+	go m.loop()
+	if disco != nil {
+		disco.RegisterServerItem(xep0030.Item{Jid: cfg.Host, Name: cfg.Name})
+		disco.RegisterProvider(cfg.Host, m)
+	}
+	return m
+}
+
+// Host returns Multi-User Chat component host name.
+func (m *Muc) Host() string {
+	return m.cfg.Host
+}
+
+// ProcessStanza processes a stanza addressed to the Multi-User Chat
+// service or to any of its rooms.
+func (m *Muc) ProcessStanza(stanza xmpp.Stanza, stm stream.C2S) {
+	m.actorCh <- func() { m.processStanza(stanza, stm) }
+}
+
+// Identities returns all identities associated to the Multi-User Chat service
+// or to one of its rooms.
+func (m *Muc) Identities(toJID, fromJID *jid.JID, node string) []xep0030.Identity {
+	if node != "" {
+		return nil
+	}
+	var ret []xep0030.Identity
+	m.inActor(func() {
+		if toJID.IsServer() {
+			ret = []xep0030.Identity{{Category: "conference", Type: "text", Name: m.cfg.Name}}
+			return
+		}
+		if r := m.rooms[toJID.ToBareJID().String()]; r != nil && !r.locked {
+			ret = []xep0030.Identity{{Category: "conference", Type: "text", Name: r.entity.Name}}
+		}
+	})
+	return ret
+}
+
+// Items returns all public rooms hosted by the Multi-User Chat service.
+func (m *Muc) Items(toJID, fromJID *jid.JID, node string) ([]xep0030.Item, *xmpp.StanzaError) {
+	if node != "" || !toJID.IsServer() {
+		return nil, nil
+	}
+	var ret []xep0030.Item
+	m.inActor(func() {
+		for _, r := range m.rooms {
+			if r.locked || !r.entity.Public {
+				continue
+			}
+			ret = append(ret, xep0030.Item{Jid: r.jid.String(), Name: r.entity.Name})
+		}
+	})
+	return ret, nil
+}
+
+// Features returns all features associated to the Multi-User Chat service
+// or to one of its rooms.
+func (m *Muc) Features(toJID, fromJID *jid.JID, node string) ([]xep0030.Feature, *xmpp.StanzaError) {
+	if node != "" {
+		return nil, nil
+	}
+	if toJID.IsServer() {
+		return []xep0030.Feature{discoInfoNamespace, discoItemsNamespace, mucNamespace}, nil
+	}
+	var ret []xep0030.Feature
+	var sErr *xmpp.StanzaError
+	m.inActor(func() {
+		r := m.rooms[toJID.ToBareJID().String()]
+		if r == nil || r.locked {
+			sErr = xmpp.ErrItemNotFound
+			return
+		}
+		ret = r.features()
+	})
+	return ret, sErr
+}
+
+// Form returns the data form associated to the Multi-User Chat service.
+func (m *Muc) Form(toJID, fromJID *jid.JID, node string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	return nil, nil
+}
+
+// runs on it's own goroutine
+func (m *Muc) loop() {
+	for {
+		select {
+		case f := <-m.actorCh:
+			f()
+		case <-m.shutdownCh:
+			return
+		}
+	}
+}
+
+func (m *Muc) inActor(f func()) {
+	doneCh := make(chan struct{})
+	m.actorCh <- func() {
+		f()
+		close(doneCh)
+	}
+	<-doneCh
+}
+
+func (m *Muc) processStanza(stanza xmpp.Stanza, stm stream.C2S) {
+	toJID := stanza.ToJID()
+	if toJID.IsServer() {
+		// stanzas addressed to the service itself
+		switch stanza := stanza.(type) {
+		case *xmpp.IQ:
+			if stanza.IsGet() || stanza.IsSet() {
+				stm.SendElement(stanza.ServiceUnavailableError())
+			}
+		case *xmpp.Message:
+			stm.SendElement(stanza.ServiceUnavailableError())
+		}
+		return
+	}
+	switch stanza := stanza.(type) {
+	case *xmpp.Presence:
+		m.processPresence(stanza, stm)
+	case *xmpp.Message:
+		m.processMessage(stanza, stm)
+	case *xmpp.IQ:
+		m.processIQ(stanza, stm)
+	}
+	if r := m.rooms[toJID.ToBareJID().String()]; r != nil {
+		m.removeUnavailableOccupants(r)
+	}
+}
+
+func (m *Muc) processIQ(iq *xmpp.IQ, stm stream.C2S) {
+	r := m.rooms[iq.ToJID().ToBareJID().String()]
+	if r == nil || !iq.ToJID().IsBare() {
+		if iq.IsGet() || iq.IsSet() {
+			stm.SendElement(iq.ServiceUnavailableError())
+		}
+		return
+	}
+	if q := iq.Elements().ChildNamespace("query", mucAdminNamespace); q != nil {
+		m.processAdminIQ(r, iq, q, stm)
+		return
+	}
+	if q := iq.Elements().ChildNamespace("query", mucOwnerNamespace); q != nil {
+		m.processOwnerIQ(r, iq, q, stm)
+		return
+	}
+	if iq.IsGet() || iq.IsSet() {
+		stm.SendElement(iq.ServiceUnavailableError())
+	}
+}
+
+func (m *Muc) loadRooms() {
+	rooms, err := storage.Instance().FetchRooms()
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for _, entity := range rooms {
+		roomJID, err := jid.NewWithString(entity.RoomJID, true)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if roomJID.Domain() != m.cfg.Host {
+			continue
+		}
+		r := newRoom(roomJID)
+		r.entity = entity
+		r.persistent = true
+		m.rooms[entity.RoomJID] = r
+	}
+}
+
+func (m *Muc) persistRoom(r *room) {
+	if !r.persistent {
+		return
+	}
+	if err := storage.Instance().InsertOrUpdateRoom(&r.entity); err != nil {
+		log.Error(err)
+	}
+}
+
+func (m *Muc) destroyRoom(r *room) {
+	delete(m.rooms, r.jid.String())
+	if r.persistent {
+		if err := storage.Instance().DeleteRoom(r.jid.String()); err != nil {
+			log.Error(err)
+		}
+	}
+	log.Infof("destroyed muc room... (%s)", r.jid.String())
+}
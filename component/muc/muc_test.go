@@ -0,0 +1,317 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuc_Config(t *testing.T) {
+	var cfg Config
+	err := cfg.UnmarshalYAML(func(v interface{}) error {
+		*v.(*configProxy) = configProxy{}
+		return nil
+	})
+	require.NotNil(t, err)
+
+	err = cfg.UnmarshalYAML(func(v interface{}) error {
+		*v.(*configProxy) = configProxy{Host: "conference.jackal.im"}
+		return nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, "conference.jackal.im", cfg.Host)
+	require.Equal(t, "Chatrooms", cfg.Name)
+	require.Equal(t, defaultMaxHistory, cfg.MaxHistory)
+}
+
+func TestMuc_Disco(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im", Name: "Chatrooms"}, nil, shutdownCh)
+	require.Equal(t, "conference.jackal.im", m.Host())
+
+	srvJID, _ := jid.New("", "conference.jackal.im", "", true)
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	identities := m.Identities(srvJID, j, "")
+	require.Equal(t, 1, len(identities))
+	require.Equal(t, "conference", identities[0].Category)
+	require.Equal(t, "text", identities[0].Type)
+
+	features, sErr := m.Features(srvJID, j, "")
+	require.Nil(t, sErr)
+	require.Contains(t, features, mucNamespace)
+
+	_, sErr = m.Features(roomJID, j, "")
+	require.Equal(t, xmpp.ErrItemNotFound, sErr)
+
+	// create a public instant room
+	stm := tUtilMucStream(j)
+	defer router.Unbind(stm)
+
+	m.ProcessStanza(xmpp.NewPresence(j, tUtilMucOccupantJID(roomJID, "witch"), xmpp.AvailableType), stm)
+	elem := stm.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+
+	items, sErr := m.Items(srvJID, j, "")
+	require.Nil(t, sErr)
+	require.Equal(t, 0, len(items)) // hidden by default
+
+	m.inActor(func() { m.rooms[roomJID.String()].entity.Public = true })
+
+	items, sErr = m.Items(srvJID, j, "")
+	require.Nil(t, sErr)
+	require.Equal(t, 1, len(items))
+	require.Equal(t, roomJID.String(), items[0].Jid)
+
+	features, sErr = m.Features(roomJID, j, "")
+	require.Nil(t, sErr)
+	require.Contains(t, features, "muc_public")
+	require.Contains(t, features, "muc_temporary")
+}
+
+func TestMuc_CreateRoom(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im"}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := tUtilMucStream(j1)
+	stm2 := tUtilMucStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	// missing nick
+	m.ProcessStanza(tUtilMucJoinPresence(j1, roomJID), stm1)
+	elem := stm1.FetchElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.NotNil(t, elem.Error().Elements().Child("jid-malformed"))
+
+	m.ProcessStanza(tUtilMucJoinPresence(j1, tUtilMucOccupantJID(roomJID, "firstwitch")), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, "coven@conference.jackal.im/firstwitch", elem.From())
+
+	x := elem.Elements().ChildNamespace("x", mucUserNamespace)
+	require.NotNil(t, x)
+	require.Equal(t, mucmodel.AffiliationOwner, x.Elements().Child("item").Attributes().Get("affiliation"))
+	require.Equal(t, roleModerator, x.Elements().Child("item").Attributes().Get("role"))
+	require.Equal(t, []string{statusSelfPresence, statusRoomCreated}, tUtilMucStatusCodes(x))
+
+	// room remains locked until configured
+	m.ProcessStanza(tUtilMucJoinPresence(j2, tUtilMucOccupantJID(roomJID, "secondwitch")), stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.NotNil(t, elem.Error().Elements().Child("item-not-found"))
+}
+
+func TestMuc_JoinRoom(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im", MaxHistory: 10}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	j3, _ := jid.New("romeo", "jackal.im", "orchard", true)
+
+	stm1 := tUtilMucStream(j1)
+	stm2 := tUtilMucStream(j2)
+	stm3 := tUtilMucStream(j3)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+	defer router.Unbind(stm3)
+
+	tUtilMucCreateInstantRoom(t, m, stm1, roomJID, "firstwitch")
+
+	m.ProcessStanza(tUtilMucJoinPresence(j2, tUtilMucOccupantJID(roomJID, "secondwitch")), stm2)
+
+	// existing occupant presence
+	elem := stm2.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, "coven@conference.jackal.im/firstwitch", elem.From())
+
+	// self presence
+	elem = stm2.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, "coven@conference.jackal.im/secondwitch", elem.From())
+	x := elem.Elements().ChildNamespace("x", mucUserNamespace)
+	require.Equal(t, []string{statusSelfPresence}, tUtilMucStatusCodes(x))
+	require.Equal(t, roleParticipant, x.Elements().Child("item").Attributes().Get("role"))
+
+	// room subject
+	elem = stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.NotNil(t, elem.Elements().Child("subject"))
+
+	// new occupant presence broadcasted
+	elem = stm1.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, "coven@conference.jackal.im/secondwitch", elem.From())
+	x = elem.Elements().ChildNamespace("x", mucUserNamespace)
+	require.Equal(t, j2.String(), x.Elements().Child("item").Attributes().Get("jid")) // moderators see real JIDs
+
+	// nick conflict
+	m.ProcessStanza(tUtilMucJoinPresence(j3, tUtilMucOccupantJID(roomJID, "secondwitch")), stm3)
+	elem = stm3.FetchElement()
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.NotNil(t, elem.Error().Elements().Child("conflict"))
+
+	// change nick
+	m.ProcessStanza(tUtilMucJoinPresence(j2, tUtilMucOccupantJID(roomJID, "thirdwitch")), stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	x = elem.Elements().ChildNamespace("x", mucUserNamespace)
+	require.Equal(t, "thirdwitch", x.Elements().Child("item").Attributes().Get("nick"))
+	require.Equal(t, []string{statusNickChanged, statusSelfPresence}, tUtilMucStatusCodes(x))
+
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.AvailableType, elem.Type())
+	require.Equal(t, "coven@conference.jackal.im/thirdwitch", elem.From())
+
+	_ = stm1.FetchElement()
+	_ = stm1.FetchElement()
+
+	// leave room
+	m.ProcessStanza(xmpp.NewPresence(j2, tUtilMucOccupantJID(roomJID, "thirdwitch"), xmpp.UnavailableType), stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	require.Equal(t, "coven@conference.jackal.im/thirdwitch", elem.From())
+
+	// temporary room is destroyed when last occupant leaves
+	m.ProcessStanza(xmpp.NewPresence(j1, tUtilMucOccupantJID(roomJID, "firstwitch"), xmpp.UnavailableType), stm1)
+	_ = stm1.FetchElement()
+
+	m.inActor(func() { require.Nil(t, m.rooms[roomJID.String()]) })
+}
+
+func TestMuc_JoinRestrictedRoom(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im"}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := tUtilMucStream(j1)
+	stm2 := tUtilMucStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilMucCreateInstantRoom(t, m, stm1, roomJID, "firstwitch")
+
+	// password protected
+	m.inActor(func() { m.rooms[roomJID.String()].entity.Password = "cauldronburn" })
+
+	m.ProcessStanza(tUtilMucJoinPresence(j2, tUtilMucOccupantJID(roomJID, "secondwitch")), stm2)
+	elem := stm2.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("not-authorized"))
+
+	// members only
+	m.inActor(func() {
+		r := m.rooms[roomJID.String()]
+		r.entity.Password = ""
+		r.entity.MembersOnly = true
+	})
+	m.ProcessStanza(tUtilMucJoinPresence(j2, tUtilMucOccupantJID(roomJID, "secondwitch")), stm2)
+	elem = stm2.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("registration-required"))
+
+	// banned
+	m.inActor(func() {
+		r := m.rooms[roomJID.String()]
+		r.entity.MembersOnly = false
+		r.setAffiliation(j2, mucmodel.AffiliationOutcast)
+	})
+	m.ProcessStanza(tUtilMucJoinPresence(j2, tUtilMucOccupantJID(roomJID, "secondwitch")), stm2)
+	elem = stm2.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("forbidden"))
+}
+
+func TestMuc_LoadPersistentRooms(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	storage.Instance().InsertOrUpdateRoom(&mucmodel.Room{RoomJID: "coven@conference.jackal.im", Name: "The Coven", Public: true})
+	storage.Instance().InsertOrUpdateRoom(&mucmodel.Room{RoomJID: "coven@muc.jackal.im"})
+
+	m := New(&Config{Host: "conference.jackal.im"}, nil, shutdownCh)
+
+	srvJID, _ := jid.New("", "conference.jackal.im", "", true)
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	items, _ := m.Items(srvJID, j, "")
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "The Coven", items[0].Name)
+}
+
+func tUtilMucInitialize() chan struct{} {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	return make(chan struct{})
+}
+
+func tUtilMucShutdown(shutdownCh chan struct{}) {
+	close(shutdownCh)
+	storage.Shutdown()
+	router.Shutdown()
+	host.Shutdown()
+}
+
+func tUtilMucStream(j *jid.JID) *stream.MockC2S {
+	stm := stream.NewMockC2S(uuid.New(), j)
+	router.Bind(stm)
+	return stm
+}
+
+func tUtilMucOccupantJID(roomJID *jid.JID, nick string) *jid.JID {
+	j, _ := jid.New(roomJID.Node(), roomJID.Domain(), nick, true)
+	return j
+}
+
+func tUtilMucJoinPresence(from, to *jid.JID) *xmpp.Presence {
+	p := xmpp.NewPresence(from, to, xmpp.AvailableType)
+	p.AppendElement(xmpp.NewElementNamespace("x", mucNamespace))
+	return p
+}
+
+func tUtilMucCreateInstantRoom(t *testing.T, m *Muc, stm *stream.MockC2S, roomJID *jid.JID, nick string) {
+	m.ProcessStanza(xmpp.NewPresence(stm.JID(), tUtilMucOccupantJID(roomJID, nick), xmpp.AvailableType), stm)
+	elem := stm.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.AvailableType, elem.Type())
+}
+
+func tUtilMucStatusCodes(x xmpp.XElement) []string {
+	var codes []string
+	for _, status := range x.Elements().Children("status") {
+		codes = append(codes, status.Attributes().Get("code"))
+	}
+	return codes
+}
@@ -0,0 +1,244 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"strconv"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+const roomConfigFormType = "http://jabber.org/protocol/muc#roomconfig"
+
+const (
+	roomNameField          = "muc#roomconfig_roomname"
+	roomDescField          = "muc#roomconfig_roomdesc"
+	persistentRoomField    = "muc#roomconfig_persistentroom"
+	publicRoomField        = "muc#roomconfig_publicroom"
+	membersOnlyField       = "muc#roomconfig_membersonly"
+	moderatedRoomField     = "muc#roomconfig_moderatedroom"
+	passwordProtectedField = "muc#roomconfig_passwordprotectedroom"
+	roomSecretField        = "muc#roomconfig_roomsecret"
+	whoIsField             = "muc#roomconfig_whois"
+	maxUsersField          = "muc#roomconfig_maxusers"
+)
+
+func (m *Muc) processOwnerIQ(r *room, iq *xmpp.IQ, query xmpp.XElement, stm stream.C2S) {
+	if r.affiliation(iq.FromJID()) != mucmodel.AffiliationOwner {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	if iq.IsGet() {
+		result := iq.ResultIQ()
+		q := xmpp.NewElementNamespace("query", mucOwnerNamespace)
+		q.AppendElement(r.configForm().Element())
+		result.AppendElement(q)
+		stm.SendElement(result)
+		return
+	}
+	if !iq.IsSet() {
+		return
+	}
+	if destroy := query.Elements().Child("destroy"); destroy != nil {
+		m.destroyRoomWithOccupants(r, destroy)
+		stm.SendElement(iq.ResultIQ())
+		return
+	}
+	formElem := query.Elements().ChildNamespace("x", "jabber:x:data")
+	if formElem == nil {
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	form, err := xep0004.NewFormFromElement(formElem)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	switch form.Type {
+	case xep0004.Cancel:
+		if r.locked {
+			m.destroyRoomWithOccupants(r, nil)
+		}
+	case xep0004.Submit:
+		if !m.applyConfigForm(r, form) {
+			stm.SendElement(iq.NotAcceptableError())
+			return
+		}
+	default:
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (m *Muc) applyConfigForm(r *room, form *xep0004.DataForm) bool {
+	entity := r.entity
+	persistent := r.persistent
+	passwordProtected := len(entity.Password) > 0
+
+	for _, field := range form.Fields {
+		var value string
+		if len(field.Values) > 0 {
+			value = field.Values[0]
+		}
+		switch field.Var {
+		case roomNameField:
+			entity.Name = value
+		case roomDescField:
+			entity.Description = value
+		case persistentRoomField:
+			persistent = isTrueValue(value)
+		case publicRoomField:
+			entity.Public = isTrueValue(value)
+		case membersOnlyField:
+			entity.MembersOnly = isTrueValue(value)
+		case moderatedRoomField:
+			entity.Moderated = isTrueValue(value)
+		case passwordProtectedField:
+			passwordProtected = isTrueValue(value)
+		case roomSecretField:
+			entity.Password = value
+		case whoIsField:
+			switch value {
+			case "anyone":
+				entity.NonAnonymous = true
+			case "moderators":
+				entity.NonAnonymous = false
+			default:
+				return false
+			}
+		case maxUsersField:
+			if len(value) == 0 || value == "none" {
+				entity.MaxOccupants = 0
+			} else {
+				maxUsers, err := strconv.Atoi(value)
+				if err != nil || maxUsers < 0 {
+					return false
+				}
+				entity.MaxOccupants = maxUsers
+			}
+		}
+	}
+	if !passwordProtected {
+		entity.Password = ""
+	} else if len(entity.Password) == 0 {
+		return false
+	}
+	becameMembersOnly := entity.MembersOnly && !r.entity.MembersOnly
+	wasPersistent := r.persistent
+
+	r.entity = entity
+	r.persistent = persistent
+	r.locked = false
+
+	if persistent {
+		m.persistRoom(r)
+	} else if wasPersistent {
+		if err := storage.Instance().DeleteRoom(r.jid.String()); err != nil {
+			log.Error(err)
+		}
+	}
+	if becameMembersOnly {
+		// exiting occupants are removed from the room occupant list
+		var nonMembers []*occupant
+		for _, occ := range r.occupants {
+			if r.affiliation(occ.jid) == mucmodel.AffiliationNone {
+				nonMembers = append(nonMembers, occ)
+			}
+		}
+		for _, occ := range nonMembers {
+			unavailable := xmpp.NewPresence(occ.jid, r.occupantJID(occ.nick), xmpp.UnavailableType)
+			m.exitRoom(r, occ, unavailable, statusMembersOnly)
+		}
+	}
+	return true
+}
+
+func (m *Muc) destroyRoomWithOccupants(r *room, destroy xmpp.XElement) {
+	for _, occ := range r.occupants {
+		p := xmpp.NewPresence(r.occupantJID(occ.nick), occ.jid, xmpp.UnavailableType)
+		x := xmpp.NewElementNamespace("x", mucUserNamespace)
+		item := xmpp.NewElementName("item")
+		item.SetAttribute("affiliation", mucmodel.AffiliationNone)
+		item.SetAttribute("role", roleNone)
+		x.AppendElement(item)
+		if destroy != nil {
+			x.AppendElement(xmpp.NewElementFromElement(destroy))
+		}
+		p.AppendElement(x)
+		m.route(occ, p)
+	}
+	r.occupants = nil
+	m.destroyRoom(r)
+}
+
+func (r *room) configForm() *xep0004.DataForm {
+	whoIs := "moderators"
+	if r.entity.NonAnonymous {
+		whoIs = "anyone"
+	}
+	maxUsers := "none"
+	if r.entity.MaxOccupants > 0 {
+		maxUsers = strconv.Itoa(r.entity.MaxOccupants)
+	}
+	return &xep0004.DataForm{
+		Type:         xep0004.Form,
+		Title:        "Configuration for " + r.jid.String() + " room",
+		Instructions: "Complete this form to modify the configuration of your room.",
+		Fields: []xep0004.Field{
+			{Var: "FORM_TYPE", Type: xep0004.Hidden, Values: []string{roomConfigFormType}},
+			{Var: roomNameField, Type: xep0004.TextSingle, Label: "Natural-Language Room Name", Values: []string{r.entity.Name}},
+			{Var: roomDescField, Type: xep0004.TextSingle, Label: "Short Description of Room", Values: []string{r.entity.Description}},
+			{Var: persistentRoomField, Type: xep0004.Boolean, Label: "Make Room Persistent?", Values: []string{boolValue(r.persistent)}},
+			{Var: publicRoomField, Type: xep0004.Boolean, Label: "Make Room Publicly Searchable?", Values: []string{boolValue(r.entity.Public)}},
+			{Var: membersOnlyField, Type: xep0004.Boolean, Label: "Make Room Members-Only?", Values: []string{boolValue(r.entity.MembersOnly)}},
+			{Var: moderatedRoomField, Type: xep0004.Boolean, Label: "Make Room Moderated?", Values: []string{boolValue(r.entity.Moderated)}},
+			{Var: passwordProtectedField, Type: xep0004.Boolean, Label: "Password Required to Enter?", Values: []string{boolValue(len(r.entity.Password) > 0)}},
+			{Var: roomSecretField, Type: xep0004.TextPrivate, Label: "Password", Values: []string{r.entity.Password}},
+			{
+				Var:    whoIsField,
+				Type:   xep0004.ListSingle,
+				Label:  "Who May Discover Real JIDs?",
+				Values: []string{whoIs},
+				Options: []xep0004.Option{
+					{Label: "Moderators Only", Value: "moderators"},
+					{Label: "Anyone", Value: "anyone"},
+				},
+			},
+			{
+				Var:    maxUsersField,
+				Type:   xep0004.ListSingle,
+				Label:  "Maximum Number of Occupants",
+				Values: []string{maxUsers},
+				Options: []xep0004.Option{
+					{Label: "10", Value: "10"},
+					{Label: "20", Value: "20"},
+					{Label: "30", Value: "30"},
+					{Label: "50", Value: "50"},
+					{Label: "100", Value: "100"},
+					{Label: "None", Value: "none"},
+				},
+			},
+		},
+	}
+}
+
+func boolValue(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func isTrueValue(value string) bool {
+	return value == "1" || value == "true"
+}
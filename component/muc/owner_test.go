@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMuc_ConfigureRoom(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im"}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := tUtilMucStream(j1)
+	stm2 := tUtilMucStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	// reserved room
+	m.ProcessStanza(tUtilMucJoinPresence(j1, tUtilMucOccupantJID(roomJID, "firstwitch")), stm1)
+	_ = stm1.FetchElement()
+
+	// only owners can configure the room
+	iq := tUtilMucOwnerIQ(j2, roomJID, xmpp.GetType, nil)
+	m.ProcessStanza(iq, stm2)
+	elem := stm2.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("forbidden"))
+
+	iq = tUtilMucOwnerIQ(j1, roomJID, xmpp.GetType, nil)
+	m.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	formElem := elem.Elements().ChildNamespace("query", mucOwnerNamespace).Elements().ChildNamespace("x", "jabber:x:data")
+	form, err := xep0004.NewFormFromElement(formElem)
+	require.Nil(t, err)
+	require.Equal(t, xep0004.Form, form.Type)
+
+	// password protected room with no password
+	iq = tUtilMucOwnerIQ(j1, roomJID, xmpp.SetType, &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: []xep0004.Field{
+			{Var: passwordProtectedField, Values: []string{"1"}},
+		},
+	})
+	m.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("not-acceptable"))
+
+	iq = tUtilMucOwnerIQ(j1, roomJID, xmpp.SetType, &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: []xep0004.Field{
+			{Var: roomNameField, Values: []string{"The Coven"}},
+			{Var: persistentRoomField, Values: []string{"1"}},
+			{Var: publicRoomField, Values: []string{"1"}},
+			{Var: whoIsField, Values: []string{"anyone"}},
+			{Var: maxUsersField, Values: []string{"20"}},
+		},
+	})
+	m.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	room, err := storage.Instance().FetchRoom("coven@conference.jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, room)
+	require.Equal(t, "The Coven", room.Name)
+	require.True(t, room.Public)
+	require.True(t, room.NonAnonymous)
+	require.Equal(t, 20, room.MaxOccupants)
+
+	// room is unlocked now
+	tUtilMucJoinRoom(t, m, stm2, roomJID, "secondwitch")
+}
+
+func TestMuc_MembersOnlyRoom(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im"}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm1 := tUtilMucStream(j1)
+	defer router.Unbind(stm1)
+
+	tUtilMucCreateInstantRoom(t, m, stm1, roomJID, "firstwitch")
+
+	var stms []*stream.MockC2S
+	for _, username := range []string{"noelia", "romeo", "juliet"} {
+		j, _ := jid.New(username, "jackal.im", "garden", true)
+		stm := tUtilMucStream(j)
+		defer router.Unbind(stm)
+
+		tUtilMucJoinRoom(t, m, stm, roomJID, username)
+		stms = append(stms, stm)
+	}
+	iq := tUtilMucOwnerIQ(j1, roomJID, xmpp.SetType, &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: []xep0004.Field{
+			{Var: membersOnlyField, Values: []string{"1"}},
+		},
+	})
+	m.ProcessStanza(iq, stm1)
+
+	// every non-member occupant is removed from the room
+	for _, stm := range stms {
+		for {
+			elem := stm.FetchElement()
+			require.Equal(t, "presence", elem.Name())
+			if elem.Type() == xmpp.UnavailableType && elem.From() == tUtilMucOccupantJID(roomJID, stm.JID().Node()).String() {
+				codes := tUtilMucStatusCodes(elem.Elements().ChildNamespace("x", mucUserNamespace))
+				require.Contains(t, codes, statusMembersOnly)
+				break
+			}
+		}
+	}
+	var occupants int
+	m.inActor(func() { occupants = len(m.rooms[roomJID.String()].occupants) })
+	require.Equal(t, 1, occupants)
+}
+
+func TestMuc_DestroyRoom(t *testing.T) {
+	shutdownCh := tUtilMucInitialize()
+	defer tUtilMucShutdown(shutdownCh)
+
+	m := New(&Config{Host: "conference.jackal.im"}, nil, shutdownCh)
+
+	roomJID, _ := jid.New("coven", "conference.jackal.im", "", true)
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := tUtilMucStream(j1)
+	stm2 := tUtilMucStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilMucCreateInstantRoom(t, m, stm1, roomJID, "firstwitch")
+	tUtilMucJoinRoom(t, m, stm2, roomJID, "secondwitch")
+	_ = stm1.FetchElement()
+
+	iq := tUtilMucOwnerIQ(j1, roomJID, xmpp.SetType, nil)
+	destroy := xmpp.NewElementName("destroy")
+	reason := xmpp.NewElementName("reason")
+	reason.SetText("Macbeth doth come.")
+	destroy.AppendElement(reason)
+	iq.Elements().ChildNamespace("query", mucOwnerNamespace).(*xmpp.Element).AppendElement(destroy)
+
+	m.ProcessStanza(iq, stm1)
+
+	elem := stm2.FetchElement()
+	require.Equal(t, "presence", elem.Name())
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	x := elem.Elements().ChildNamespace("x", mucUserNamespace)
+	require.NotNil(t, x)
+	require.NotNil(t, x.Elements().Child("destroy"))
+
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.UnavailableType, elem.Type())
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	m.inActor(func() {
+		require.Nil(t, m.rooms[roomJID.String()])
+	})
+}
+
+func tUtilMucOwnerIQ(from, to *jid.JID, iqType string, form *xep0004.DataForm) *xmpp.IQ {
+	iq := xmpp.NewIQType(uuid.New(), iqType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+	q := xmpp.NewElementNamespace("query", mucOwnerNamespace)
+	if form != nil {
+		q.AppendElement(form.Element())
+	}
+	iq.AppendElement(q)
+	return iq
+}
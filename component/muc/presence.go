@@ -0,0 +1,311 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+// presence status codes
+const (
+	statusNonAnonymous = "100"
+	statusSelfPresence = "110"
+	statusRoomCreated  = "201"
+	statusBanned       = "301"
+	statusNickChanged  = "303"
+	statusKicked       = "307"
+	statusAffiliation  = "321"
+	statusMembersOnly  = "322"
+)
+
+func (m *Muc) processPresence(presence *xmpp.Presence, stm stream.C2S) {
+	fromJID := presence.FromJID()
+	toJID := presence.ToJID()
+	r := m.rooms[toJID.ToBareJID().String()]
+
+	if presence.IsUnavailable() {
+		if r == nil {
+			return
+		}
+		if occ := r.occupantByJID(fromJID); occ != nil {
+			m.exitRoom(r, occ, presence)
+		}
+		return
+	}
+	if !presence.IsAvailable() {
+		return
+	}
+	if !toJID.IsFullWithUser() {
+		stm.SendElement(presence.JidMalformedError())
+		return
+	}
+	if _, err := jid.New(toJID.Node(), toJID.Domain(), toJID.Resource(), false); err != nil {
+		stm.SendElement(presence.JidMalformedError())
+		return
+	}
+	if r == nil {
+		m.createRoom(presence, stm)
+		return
+	}
+	if occ := r.occupantByJID(fromJID); occ != nil {
+		if occ.nick == toJID.Resource() {
+			// occupant presence update
+			occ.presence = presence
+			m.broadcastOccupantPresence(r, occ, presence)
+		} else {
+			m.changeNick(r, occ, presence, stm)
+		}
+		return
+	}
+	m.joinRoom(r, presence, stm)
+}
+
+func (m *Muc) createRoom(presence *xmpp.Presence, stm stream.C2S) {
+	fromJID := presence.FromJID()
+	roomJID := presence.ToJID().ToBareJID()
+
+	r := newRoom(roomJID)
+	r.setAffiliation(fromJID, mucmodel.AffiliationOwner)
+
+	// rooms requested by MUC-aware clients remain locked until configured
+	instantRoom := presence.Elements().ChildNamespace("x", mucNamespace) == nil
+	r.locked = !instantRoom
+
+	occ := &occupant{
+		nick:     presence.ToJID().Resource(),
+		jid:      fromJID,
+		role:     roleModerator,
+		presence: presence,
+	}
+	r.addOccupant(occ)
+	m.rooms[roomJID.String()] = r
+
+	log.Infof("created muc room... (%s)", roomJID.String())
+
+	x := m.userElement(r, occ, occ)
+	x.AppendElement(statusElement(statusSelfPresence))
+	x.AppendElement(statusElement(statusRoomCreated))
+	m.sendOccupantPresence(r, occ, presence, occ, x)
+}
+
+func (m *Muc) joinRoom(r *room, presence *xmpp.Presence, stm stream.C2S) {
+	fromJID := presence.FromJID()
+	nick := presence.ToJID().Resource()
+	joinElem := presence.Elements().ChildNamespace("x", mucNamespace)
+
+	if r.locked {
+		stm.SendElement(presence.ItemNotFoundError())
+		return
+	}
+	affiliation := r.affiliation(fromJID)
+	switch {
+	case affiliation == mucmodel.AffiliationOutcast:
+		stm.SendElement(presence.ForbiddenError())
+		return
+	case r.entity.MembersOnly && affiliation == mucmodel.AffiliationNone:
+		stm.SendElement(presence.RegistrationRequiredError())
+		return
+	}
+	if len(r.entity.Password) > 0 && affiliation != mucmodel.AffiliationOwner {
+		var password string
+		if joinElem != nil {
+			if pwdElem := joinElem.Elements().Child("password"); pwdElem != nil {
+				password = pwdElem.Text()
+			}
+		}
+		if password != r.entity.Password {
+			stm.SendElement(presence.NotAuthorizedError())
+			return
+		}
+	}
+	if occ := r.occupantByNick(nick); occ != nil && !occ.jid.Matches(fromJID, jid.MatchesBare) {
+		stm.SendElement(presence.ConflictError())
+		return
+	}
+	isPrivileged := affiliation == mucmodel.AffiliationOwner || affiliation == mucmodel.AffiliationAdmin
+	if r.entity.MaxOccupants > 0 && len(r.occupants) >= r.entity.MaxOccupants && !isPrivileged {
+		stm.SendElement(presence.ServiceUnavailableError())
+		return
+	}
+	occ := &occupant{
+		nick:     nick,
+		jid:      fromJID,
+		role:     r.defaultRole(affiliation),
+		presence: presence,
+	}
+	// send current occupants presence to the new occupant
+	for _, o := range r.occupants {
+		m.sendOccupantPresence(r, o, o.presence, occ, m.userElement(r, o, occ))
+	}
+	r.addOccupant(occ)
+
+	var selfCodes []string
+	if r.entity.NonAnonymous {
+		selfCodes = append(selfCodes, statusNonAnonymous)
+	}
+	m.broadcastOccupantPresence(r, occ, presence, selfCodes...)
+
+	// send discussion history
+	m.sendHistory(r, occ, joinElem)
+
+	// send room subject
+	subjectMsg := xmpp.NewMessageType("", xmpp.GroupChatType)
+	subjectMsg.SetFromJID(r.jid)
+	subjectMsg.SetToJID(occ.jid)
+	subject := xmpp.NewElementName("subject")
+	subject.SetText(r.entity.Subject)
+	subjectMsg.AppendElement(subject)
+	m.route(occ, subjectMsg)
+}
+
+func (m *Muc) changeNick(r *room, occ *occupant, presence *xmpp.Presence, stm stream.C2S) {
+	newNick := presence.ToJID().Resource()
+	if o := r.occupantByNick(newNick); o != nil && !o.jid.Matches(occ.jid, jid.MatchesBare) {
+		stm.SendElement(presence.ConflictError())
+		return
+	}
+	unavailable := xmpp.NewPresence(occ.jid, r.occupantJID(occ.nick), xmpp.UnavailableType)
+	for _, o := range r.occupants {
+		x := m.userElement(r, occ, o)
+		x.Elements().Child("item").(*xmpp.Element).SetAttribute("nick", newNick)
+		x.AppendElement(statusElement(statusNickChanged))
+		if o == occ {
+			x.AppendElement(statusElement(statusSelfPresence))
+		}
+		m.sendOccupantPresence(r, occ, unavailable, o, x)
+	}
+	occ.nick = newNick
+	occ.presence = presence
+	m.broadcastOccupantPresence(r, occ, presence)
+}
+
+func (m *Muc) exitRoom(r *room, occ *occupant, presence *xmpp.Presence, statusCodes ...string) {
+	for _, o := range r.occupants {
+		if o.unavailable && o != occ {
+			continue
+		}
+		x := m.userElement(r, occ, o)
+		x.Elements().Child("item").(*xmpp.Element).SetAttribute("role", roleNone)
+		for _, code := range statusCodes {
+			x.AppendElement(statusElement(code))
+		}
+		if o == occ {
+			x.AppendElement(statusElement(statusSelfPresence))
+		}
+		m.sendOccupantPresence(r, occ, presence, o, x)
+	}
+	r.removeOccupant(occ)
+
+	if len(r.occupants) == 0 && !r.persistent {
+		m.destroyRoom(r)
+	}
+}
+
+func (m *Muc) removeUnavailableOccupants(r *room) {
+	for {
+		var gone *occupant
+		for _, occ := range r.occupants {
+			if occ.unavailable {
+				gone = occ
+				break
+			}
+		}
+		if gone == nil {
+			return
+		}
+		m.exitRoom(r, gone, xmpp.NewPresence(gone.jid, r.occupantJID(gone.nick), xmpp.UnavailableType))
+	}
+}
+
+func (m *Muc) sendHistory(r *room, occ *occupant, joinElem xmpp.XElement) {
+	history := r.history
+	if joinElem != nil {
+		if historyElem := joinElem.Elements().Child("history"); historyElem != nil {
+			if maxStanzas, err := strconv.Atoi(historyElem.Attributes().Get("maxstanzas")); err == nil {
+				if maxStanzas >= 0 && maxStanzas < len(history) {
+					history = history[len(history)-maxStanzas:]
+				}
+			}
+			if seconds, err := strconv.Atoi(historyElem.Attributes().Get("seconds")); err == nil {
+				since := time.Now().Add(-time.Second * time.Duration(seconds))
+				for len(history) > 0 && history[0].stamp.Before(since) {
+					history = history[1:]
+				}
+			}
+		}
+	}
+	for _, h := range history {
+		msg, err := xmpp.NewMessageFromElement(h.message, h.message.FromJID(), occ.jid)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		delay := xmpp.NewElementNamespace("delay", "urn:xmpp:delay")
+		delay.SetAttribute("from", r.jid.String())
+		delay.SetAttribute("stamp", h.stamp.UTC().Format("2006-01-02T15:04:05Z"))
+		msg.AppendElement(delay)
+		m.route(occ, msg)
+	}
+}
+
+func (m *Muc) broadcastOccupantPresence(r *room, occ *occupant, presence *xmpp.Presence, selfStatusCodes ...string) {
+	for _, o := range r.occupants {
+		x := m.userElement(r, occ, o)
+		if o == occ {
+			for _, code := range selfStatusCodes {
+				x.AppendElement(statusElement(code))
+			}
+			x.AppendElement(statusElement(statusSelfPresence))
+		}
+		m.sendOccupantPresence(r, occ, presence, o, x)
+	}
+}
+
+func (m *Muc) sendOccupantPresence(r *room, occ *occupant, presence *xmpp.Presence, to *occupant, x xmpp.XElement) {
+	p, err := xmpp.NewPresenceFromElement(presence, r.occupantJID(occ.nick), to.jid)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	p.RemoveElementsNamespace("x", mucNamespace)
+	p.RemoveElementsNamespace("x", mucUserNamespace)
+	p.AppendElement(x)
+	m.route(to, p)
+}
+
+func (m *Muc) userElement(r *room, occ *occupant, to *occupant) *xmpp.Element {
+	x := xmpp.NewElementNamespace("x", mucUserNamespace)
+	item := xmpp.NewElementName("item")
+	item.SetAttribute("affiliation", r.affiliation(occ.jid))
+	item.SetAttribute("role", occ.role)
+	if r.entity.NonAnonymous || to.role == roleModerator || occ == to {
+		item.SetAttribute("jid", occ.jid.String())
+	}
+	x.AppendElement(item)
+	return x
+}
+
+func (m *Muc) route(occ *occupant, stanza xmpp.Stanza) {
+	switch router.Route(stanza) {
+	case router.ErrNotAuthenticated, router.ErrResourceNotFound, router.ErrNotExistingAccount:
+		// occupant went offline without leaving the room
+		occ.unavailable = true
+	}
+}
+
+func statusElement(code string) xmpp.XElement {
+	status := xmpp.NewElementName("status")
+	status.SetAttribute("code", code)
+	return status
+}
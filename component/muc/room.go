@@ -0,0 +1,193 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package muc
+
+import (
+	"time"
+
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+// occupant roles
+const (
+	roleModerator   = "moderator"
+	roleParticipant = "participant"
+	roleVisitor     = "visitor"
+	roleNone        = "none"
+)
+
+type occupant struct {
+	nick        string
+	jid         *jid.JID
+	role        string
+	presence    *xmpp.Presence
+	unavailable bool
+}
+
+type historyMessage struct {
+	message *xmpp.Message
+	stamp   time.Time
+}
+
+type room struct {
+	jid        *jid.JID
+	entity     mucmodel.Room
+	persistent bool
+	locked     bool
+	occupants  []*occupant
+	history    []historyMessage
+}
+
+func newRoom(roomJID *jid.JID) *room {
+	return &room{
+		jid:    roomJID,
+		entity: mucmodel.Room{RoomJID: roomJID.String()},
+	}
+}
+
+func (r *room) occupantJID(nick string) *jid.JID {
+	j, _ := jid.New(r.jid.Node(), r.jid.Domain(), nick, true)
+	return j
+}
+
+func (r *room) occupantByNick(nick string) *occupant {
+	for _, occ := range r.occupants {
+		if occ.nick == nick {
+			return occ
+		}
+	}
+	return nil
+}
+
+func (r *room) occupantByJID(j *jid.JID) *occupant {
+	for _, occ := range r.occupants {
+		if occ.jid.String() == j.String() {
+			return occ
+		}
+	}
+	return nil
+}
+
+func (r *room) occupantsByBareJID(j *jid.JID) []*occupant {
+	var ret []*occupant
+	for _, occ := range r.occupants {
+		if occ.jid.Matches(j, jid.MatchesBare) {
+			ret = append(ret, occ)
+		}
+	}
+	return ret
+}
+
+func (r *room) addOccupant(occ *occupant) {
+	r.occupants = append(r.occupants, occ)
+}
+
+func (r *room) removeOccupant(occ *occupant) {
+	for i, o := range r.occupants {
+		if o == occ {
+			r.occupants = append(r.occupants[:i], r.occupants[i+1:]...)
+			return
+		}
+	}
+}
+
+func (r *room) affiliation(j *jid.JID) string {
+	bareJID := j.ToBareJID().String()
+	for _, aff := range r.entity.Affiliations {
+		if aff.JID == bareJID {
+			return aff.Affiliation
+		}
+	}
+	return mucmodel.AffiliationNone
+}
+
+func (r *room) setAffiliation(j *jid.JID, affiliation string) {
+	bareJID := j.ToBareJID().String()
+	for i, aff := range r.entity.Affiliations {
+		if aff.JID == bareJID {
+			if affiliation == mucmodel.AffiliationNone {
+				r.entity.Affiliations = append(r.entity.Affiliations[:i], r.entity.Affiliations[i+1:]...)
+			} else {
+				r.entity.Affiliations[i].Affiliation = affiliation
+			}
+			return
+		}
+	}
+	if affiliation != mucmodel.AffiliationNone {
+		r.entity.Affiliations = append(r.entity.Affiliations, mucmodel.Affiliation{JID: bareJID, Affiliation: affiliation})
+	}
+}
+
+func (r *room) affiliationJIDs(affiliation string) []string {
+	var ret []string
+	for _, aff := range r.entity.Affiliations {
+		if aff.Affiliation == affiliation {
+			ret = append(ret, aff.JID)
+		}
+	}
+	return ret
+}
+
+func (r *room) defaultRole(affiliation string) string {
+	switch affiliation {
+	case mucmodel.AffiliationOwner, mucmodel.AffiliationAdmin:
+		return roleModerator
+	case mucmodel.AffiliationMember:
+		return roleParticipant
+	}
+	if r.entity.Moderated {
+		return roleVisitor
+	}
+	return roleParticipant
+}
+
+func (r *room) appendHistory(message *xmpp.Message, maxHistory int) {
+	if maxHistory <= 0 {
+		return
+	}
+	r.history = append(r.history, historyMessage{message: message, stamp: time.Now()})
+	if len(r.history) > maxHistory {
+		r.history = r.history[len(r.history)-maxHistory:]
+	}
+}
+
+func (r *room) features() []xep0030.Feature {
+	features := []xep0030.Feature{mucNamespace}
+	if r.persistent {
+		features = append(features, "muc_persistent")
+	} else {
+		features = append(features, "muc_temporary")
+	}
+	if r.entity.Public {
+		features = append(features, "muc_public")
+	} else {
+		features = append(features, "muc_hidden")
+	}
+	if r.entity.MembersOnly {
+		features = append(features, "muc_membersonly")
+	} else {
+		features = append(features, "muc_open")
+	}
+	if r.entity.Moderated {
+		features = append(features, "muc_moderated")
+	} else {
+		features = append(features, "muc_unmoderated")
+	}
+	if len(r.entity.Password) > 0 {
+		features = append(features, "muc_passwordprotected")
+	} else {
+		features = append(features, "muc_unsecured")
+	}
+	if r.entity.NonAnonymous {
+		features = append(features, "muc_nonanonymous")
+	} else {
+		features = append(features, "muc_semianonymous")
+	}
+	return features
+}
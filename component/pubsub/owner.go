@@ -0,0 +1,366 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsub
+
+import (
+	"strconv"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const nodeConfigFormType = "http://jabber.org/protocol/pubsub#node_config"
+
+const (
+	titleField           = "pubsub#title"
+	accessModelField     = "pubsub#access_model"
+	maxItemsField        = "pubsub#max_items"
+	persistItemsField    = "pubsub#persist_items"
+	deliverPayloadsField = "pubsub#deliver_payloads"
+	notifyRetractField   = "pubsub#notify_retract"
+	notifyDeleteField    = "pubsub#notify_delete"
+)
+
+func (p *PubSub) createNode(iq *xmpp.IQ, create, configure xmpp.XElement, stm stream.C2S) {
+	fromJID := iq.FromJID()
+	if !host.IsLocalHost(fromJID.Domain()) {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	name := create.Attributes().Get("node")
+	instant := len(name) == 0
+	if instant {
+		name = uuid.New()
+	}
+	n, err := storage.Instance().FetchPubSubNode(p.cfg.Host, name)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if n != nil {
+		stm.SendElement(iq.ConflictError())
+		return
+	}
+	n = &pubsubmodel.Node{
+		Host:    p.cfg.Host,
+		Name:    name,
+		Options: p.defaultOptions(),
+	}
+	n.SetAffiliation(fromJID.ToBareJID().String(), pubsubmodel.AffiliationOwner)
+
+	if configure != nil {
+		if formElem := configure.Elements().ChildNamespace("x", "jabber:x:data"); formElem != nil {
+			form, err := xep0004.NewFormFromElement(formElem)
+			if err != nil || form.Type != xep0004.Submit {
+				stm.SendElement(iq.BadRequestError())
+				return
+			}
+			if !applyConfigForm(n, form) {
+				stm.SendElement(iq.NotAcceptableError())
+				return
+			}
+		}
+	}
+	if err := storage.Instance().InsertOrUpdatePubSubNode(n); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	log.Infof("created pubsub node... (%s)", name)
+
+	result := iq.ResultIQ()
+	if instant {
+		c := xmpp.NewElementName("create")
+		c.SetAttribute("node", name)
+		ps := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+		ps.AppendElement(c)
+		result.AppendElement(ps)
+	}
+	stm.SendElement(result)
+}
+
+func (p *PubSub) defaultOptions() pubsubmodel.Options {
+	return pubsubmodel.Options{
+		AccessModel:     pubsubmodel.AccessModelOpen,
+		MaxItems:        p.cfg.MaxItems,
+		PersistItems:    true,
+		DeliverPayloads: true,
+		NotifyRetract:   true,
+		NotifyDelete:    true,
+	}
+}
+
+func (p *PubSub) processOwnerIQ(iq *xmpp.IQ, ps xmpp.XElement, stm stream.C2S) {
+	var cmd xmpp.XElement
+	for _, name := range []string{"configure", "delete", "affiliations"} {
+		if cmd = ps.Elements().Child(name); cmd != nil {
+			break
+		}
+	}
+	if cmd == nil || (cmd.Name() == "delete" && !iq.IsSet()) {
+		if iq.IsGet() || iq.IsSet() {
+			stm.SendElement(iq.FeatureNotImplementedError())
+		}
+		return
+	}
+	n := p.loadNode(iq, cmd.Attributes().Get("node"), stm)
+	if n == nil {
+		return
+	}
+	if n.Affiliation(iq.FromJID().ToBareJID().String()) != pubsubmodel.AffiliationOwner {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	switch cmd.Name() {
+	case "configure":
+		p.configureNode(n, iq, cmd, stm)
+	case "delete":
+		p.deleteNode(n, iq, stm)
+	case "affiliations":
+		p.manageAffiliations(n, iq, cmd, stm)
+	}
+}
+
+func (p *PubSub) configureNode(n *pubsubmodel.Node, iq *xmpp.IQ, configure xmpp.XElement, stm stream.C2S) {
+	if iq.IsGet() {
+		c := xmpp.NewElementName("configure")
+		c.SetAttribute("node", n.Name)
+		c.AppendElement(configForm(n).Element())
+		ps := xmpp.NewElementNamespace("pubsub", pubSubOwnerNamespace)
+		ps.AppendElement(c)
+		result := iq.ResultIQ()
+		result.AppendElement(ps)
+		stm.SendElement(result)
+		return
+	}
+	if !iq.IsSet() {
+		return
+	}
+	formElem := configure.Elements().ChildNamespace("x", "jabber:x:data")
+	if formElem == nil {
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	form, err := xep0004.NewFormFromElement(formElem)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	switch form.Type {
+	case xep0004.Cancel:
+		break
+	case xep0004.Submit:
+		if !applyConfigForm(n, form) {
+			stm.SendElement(iq.NotAcceptableError())
+			return
+		}
+		if err := storage.Instance().InsertOrUpdatePubSubNode(n); err != nil {
+			log.Error(err)
+			stm.SendElement(iq.InternalServerError())
+			return
+		}
+	default:
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (p *PubSub) deleteNode(n *pubsubmodel.Node, iq *xmpp.IQ, stm stream.C2S) {
+	if err := storage.Instance().DeletePubSubNode(n.Host, n.Name); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if n.Options.NotifyDelete {
+		del := xmpp.NewElementName("delete")
+		del.SetAttribute("node", n.Name)
+		p.notifySubscribers(n, del)
+	}
+	log.Infof("deleted pubsub node... (%s)", n.Name)
+
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (p *PubSub) manageAffiliations(n *pubsubmodel.Node, iq *xmpp.IQ, affiliations xmpp.XElement, stm stream.C2S) {
+	if iq.IsGet() {
+		affs := xmpp.NewElementName("affiliations")
+		affs.SetAttribute("node", n.Name)
+		for _, aff := range n.Affiliations {
+			a := xmpp.NewElementName("affiliation")
+			a.SetAttribute("jid", aff.JID)
+			a.SetAttribute("affiliation", aff.Affiliation)
+			affs.AppendElement(a)
+		}
+		ps := xmpp.NewElementNamespace("pubsub", pubSubOwnerNamespace)
+		ps.AppendElement(affs)
+		result := iq.ResultIQ()
+		result.AppendElement(ps)
+		stm.SendElement(result)
+		return
+	}
+	if !iq.IsSet() {
+		return
+	}
+	for _, a := range affiliations.Elements().Children("affiliation") {
+		j, err := jid.NewWithString(a.Attributes().Get("jid"), false)
+		if err != nil {
+			stm.SendElement(iq.JidMalformedError())
+			return
+		}
+		aff := a.Attributes().Get("affiliation")
+		if !isValidAffiliation(aff) {
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+		bareJID := j.ToBareJID().String()
+		n.SetAffiliation(bareJID, aff)
+		if aff == pubsubmodel.AffiliationOutcast {
+			deleteSubscriptions(n, bareJID)
+		}
+	}
+	if !hasOwner(n) {
+		stm.SendElement(iq.NotAcceptableError())
+		return
+	}
+	if n.Options.AccessModel == pubsubmodel.AccessModelWhitelist {
+		purgeNonWhitelistedSubscriptions(n)
+	}
+	if err := storage.Instance().InsertOrUpdatePubSubNode(n); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	stm.SendElement(iq.ResultIQ())
+}
+
+func configForm(n *pubsubmodel.Node) *xep0004.DataForm {
+	return &xep0004.DataForm{
+		Type:         xep0004.Form,
+		Title:        "Configuration for " + n.Name + " node",
+		Instructions: "Complete this form to modify the configuration of your node.",
+		Fields: []xep0004.Field{
+			{Var: "FORM_TYPE", Type: xep0004.Hidden, Values: []string{nodeConfigFormType}},
+			{Var: titleField, Type: xep0004.TextSingle, Label: "A friendly name for the node", Values: []string{n.Options.Title}},
+			{
+				Var:    accessModelField,
+				Type:   xep0004.ListSingle,
+				Label:  "Who may subscribe and retrieve items",
+				Values: []string{n.Options.AccessModel},
+				Options: []xep0004.Option{
+					{Label: "Open", Value: pubsubmodel.AccessModelOpen},
+					{Label: "Presence Sharing", Value: pubsubmodel.AccessModelPresence},
+					{Label: "Whitelist", Value: pubsubmodel.AccessModelWhitelist},
+				},
+			},
+			{Var: maxItemsField, Type: xep0004.TextSingle, Label: "Max # of items to persist", Values: []string{strconv.Itoa(n.Options.MaxItems)}},
+			{Var: persistItemsField, Type: xep0004.Boolean, Label: "Persist items to storage", Values: []string{boolValue(n.Options.PersistItems)}},
+			{Var: deliverPayloadsField, Type: xep0004.Boolean, Label: "Deliver payloads with event notifications", Values: []string{boolValue(n.Options.DeliverPayloads)}},
+			{Var: notifyRetractField, Type: xep0004.Boolean, Label: "Notify subscribers when items are removed from the node", Values: []string{boolValue(n.Options.NotifyRetract)}},
+			{Var: notifyDeleteField, Type: xep0004.Boolean, Label: "Notify subscribers when the node is deleted", Values: []string{boolValue(n.Options.NotifyDelete)}},
+		},
+	}
+}
+
+func applyConfigForm(n *pubsubmodel.Node, form *xep0004.DataForm) bool {
+	opts := n.Options
+	for _, field := range form.Fields {
+		var value string
+		if len(field.Values) > 0 {
+			value = field.Values[0]
+		}
+		switch field.Var {
+		case titleField:
+			opts.Title = value
+		case accessModelField:
+			switch value {
+			case pubsubmodel.AccessModelOpen, pubsubmodel.AccessModelPresence, pubsubmodel.AccessModelWhitelist:
+				opts.AccessModel = value
+			default:
+				return false
+			}
+		case maxItemsField:
+			maxItems, err := strconv.Atoi(value)
+			if err != nil || maxItems < 0 {
+				return false
+			}
+			opts.MaxItems = maxItems
+		case persistItemsField:
+			opts.PersistItems = isTrueValue(value)
+		case deliverPayloadsField:
+			opts.DeliverPayloads = isTrueValue(value)
+		case notifyRetractField:
+			opts.NotifyRetract = isTrueValue(value)
+		case notifyDeleteField:
+			opts.NotifyDelete = isTrueValue(value)
+		}
+	}
+	n.Options = opts
+	if opts.AccessModel == pubsubmodel.AccessModelWhitelist {
+		purgeNonWhitelistedSubscriptions(n)
+	}
+	return true
+}
+
+func purgeNonWhitelistedSubscriptions(n *pubsubmodel.Node) {
+	var subs []pubsubmodel.Subscription
+	for _, sub := range n.Subscriptions {
+		if j, err := jid.NewWithString(sub.JID, true); err == nil {
+			if n.Affiliation(j.ToBareJID().String()) != pubsubmodel.AffiliationNone {
+				subs = append(subs, sub)
+			}
+		}
+	}
+	n.Subscriptions = subs
+}
+
+func deleteSubscriptions(n *pubsubmodel.Node, bareJID string) {
+	var subs []pubsubmodel.Subscription
+	for _, sub := range n.Subscriptions {
+		if j, err := jid.NewWithString(sub.JID, true); err == nil && j.ToBareJID().String() != bareJID {
+			subs = append(subs, sub)
+		}
+	}
+	n.Subscriptions = subs
+}
+
+func hasOwner(n *pubsubmodel.Node) bool {
+	for _, aff := range n.Affiliations {
+		if aff.Affiliation == pubsubmodel.AffiliationOwner {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidAffiliation(affiliation string) bool {
+	switch affiliation {
+	case pubsubmodel.AffiliationOwner, pubsubmodel.AffiliationPublisher, pubsubmodel.AffiliationMember,
+		pubsubmodel.AffiliationOutcast, pubsubmodel.AffiliationNone:
+		return true
+	}
+	return false
+}
+
+func boolValue(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+func isTrueValue(value string) bool {
+	return value == "1" || value == "true"
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsub
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/pborman/uuid"
+)
+
+func (p *PubSub) publishItem(iq *xmpp.IQ, publish xmpp.XElement, stm stream.C2S) {
+	n := p.loadNode(iq, publish.Attributes().Get("node"), stm)
+	if n == nil {
+		return
+	}
+	publisher := iq.FromJID().ToBareJID().String()
+	if !canPublish(n, publisher) {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	item := &pubsubmodel.Item{Publisher: publisher, Stamp: time.Now()}
+	if itemElem := publish.Elements().Child("item"); itemElem != nil {
+		payloads := itemElem.Elements().All()
+		if len(payloads) > 1 {
+			stm.SendElement(pubSubError(iq, xmpp.ErrBadRequest, "invalid-payload"))
+			return
+		}
+		if len(payloads) == 1 {
+			item.Payload = payloads[0]
+		}
+		item.ID = itemElem.Attributes().Get("id")
+	}
+	if len(item.ID) == 0 {
+		item.ID = uuid.New()
+	}
+	if n.Options.PersistItems {
+		if err := storage.Instance().InsertOrUpdatePubSubNodeItem(item, n.Host, n.Name); err != nil {
+			log.Error(err)
+			stm.SendElement(iq.InternalServerError())
+			return
+		}
+		p.trimNodeItems(n)
+	}
+	items := xmpp.NewElementName("items")
+	items.SetAttribute("node", n.Name)
+	items.AppendElement(itemElement(item, n.Options.DeliverPayloads))
+	p.notifySubscribers(n, items)
+
+	itemRes := xmpp.NewElementName("item")
+	itemRes.SetAttribute("id", item.ID)
+	pub := xmpp.NewElementName("publish")
+	pub.SetAttribute("node", n.Name)
+	pub.AppendElement(itemRes)
+	ps := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+	ps.AppendElement(pub)
+	result := iq.ResultIQ()
+	result.AppendElement(ps)
+	stm.SendElement(result)
+}
+
+func (p *PubSub) retractItem(iq *xmpp.IQ, retract xmpp.XElement, stm stream.C2S) {
+	n := p.loadNode(iq, retract.Attributes().Get("node"), stm)
+	if n == nil {
+		return
+	}
+	if !canPublish(n, iq.FromJID().ToBareJID().String()) {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	itemElem := retract.Elements().Child("item")
+	if itemElem == nil || len(itemElem.Attributes().Get("id")) == 0 {
+		stm.SendElement(pubSubError(iq, xmpp.ErrBadRequest, "item-required"))
+		return
+	}
+	itemID := itemElem.Attributes().Get("id")
+
+	items, err := storage.Instance().FetchPubSubNodeItems(n.Host, n.Name)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	var found bool
+	for _, item := range items {
+		if item.ID == itemID {
+			found = true
+			break
+		}
+	}
+	if !found {
+		stm.SendElement(iq.ItemNotFoundError())
+		return
+	}
+	if err := storage.Instance().DeletePubSubNodeItem(n.Host, n.Name, itemID); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if notify := retract.Attributes().Get("notify"); n.Options.NotifyRetract || isTrueValue(notify) {
+		r := xmpp.NewElementName("retract")
+		r.SetAttribute("id", itemID)
+		items := xmpp.NewElementName("items")
+		items.SetAttribute("node", n.Name)
+		items.AppendElement(r)
+		p.notifySubscribers(n, items)
+	}
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (p *PubSub) retrieveItems(iq *xmpp.IQ, itemsElem xmpp.XElement, stm stream.C2S) {
+	n := p.loadNode(iq, itemsElem.Attributes().Get("node"), stm)
+	if n == nil {
+		return
+	}
+	if !p.checkAccess(n, iq, stm) {
+		return
+	}
+	items, err := storage.Instance().FetchPubSubNodeItems(n.Host, n.Name)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	// filter requested item identifiers
+	if reqItems := itemsElem.Elements().Children("item"); len(reqItems) > 0 {
+		ids := make(map[string]struct{}, len(reqItems))
+		for _, reqItem := range reqItems {
+			ids[reqItem.Attributes().Get("id")] = struct{}{}
+		}
+		var filtered []pubsubmodel.Item
+		for _, item := range items {
+			if _, ok := ids[item.ID]; ok {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+	if maxItems, err := strconv.Atoi(itemsElem.Attributes().Get("max_items")); err == nil && maxItems >= 0 && maxItems < len(items) {
+		items = items[len(items)-maxItems:]
+	}
+	res := xmpp.NewElementName("items")
+	res.SetAttribute("node", n.Name)
+	for i := range items {
+		res.AppendElement(itemElement(&items[i], true))
+	}
+	ps := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+	ps.AppendElement(res)
+	result := iq.ResultIQ()
+	result.AppendElement(ps)
+	stm.SendElement(result)
+}
+
+// trimNodeItems removes oldest node items beyond its max items limit.
+func (p *PubSub) trimNodeItems(n *pubsubmodel.Node) {
+	if n.Options.MaxItems == 0 {
+		return
+	}
+	items, err := storage.Instance().FetchPubSubNodeItems(n.Host, n.Name)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for i := 0; i < len(items)-n.Options.MaxItems; i++ {
+		if err := storage.Instance().DeletePubSubNodeItem(n.Host, n.Name, items[i].ID); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+func itemElement(item *pubsubmodel.Item, withPayload bool) xmpp.XElement {
+	e := xmpp.NewElementName("item")
+	e.SetAttribute("id", item.ID)
+	if withPayload && item.Payload != nil {
+		e.AppendElement(item.Payload)
+	}
+	return e
+}
+
+func canPublish(n *pubsubmodel.Node, bareJID string) bool {
+	switch n.Affiliation(bareJID) {
+	case pubsubmodel.AffiliationOwner, pubsubmodel.AffiliationPublisher:
+		return true
+	}
+	return false
+}
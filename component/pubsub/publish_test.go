@@ -0,0 +1,224 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubSub_PublishItem(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	stm1 := tUtilPubSubStream(j1)
+	stm2 := tUtilPubSubStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilPubSubCreateNode(t, p, stm1, "princely_musings")
+	tUtilPubSubSubscribe(t, p, stm2, "princely_musings")
+
+	// not a publisher
+	iq := tUtilPubSubIQ(j2, xmpp.SetType, pubSubNamespace, tUtilPubSubPublish("princely_musings", "ae890ac52d0df67ed7cfdf51b644e901"))
+	p.ProcessStanza(iq, stm2)
+	elem := stm2.FetchElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	iq = tUtilPubSubIQ(j1, xmpp.SetType, pubSubNamespace, tUtilPubSubPublish("princely_musings", "ae890ac52d0df67ed7cfdf51b644e901"))
+	p.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	item := elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("publish").Elements().Child("item")
+	require.Equal(t, "ae890ac52d0df67ed7cfdf51b644e901", item.Attributes().Get("id"))
+
+	// event notification
+	elem = stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "pubsub.jackal.im", elem.From())
+	items := elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, "princely_musings", items.Attributes().Get("node"))
+	item = items.Elements().Child("item")
+	require.Equal(t, "ae890ac52d0df67ed7cfdf51b644e901", item.Attributes().Get("id"))
+	require.NotNil(t, item.Elements().Child("entry"))
+
+	// auto generated item identifier
+	iq = tUtilPubSubIQ(j1, xmpp.SetType, pubSubNamespace, tUtilPubSubPublish("princely_musings", ""))
+	p.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	item = elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("publish").Elements().Child("item")
+	require.NotEmpty(t, item.Attributes().Get("id"))
+	_ = stm2.FetchElement()
+
+	nodeItems, _ := storage.Instance().FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 2, len(nodeItems))
+
+	// unknown node
+	iq = tUtilPubSubIQ(j1, xmpp.SetType, pubSubNamespace, tUtilPubSubPublish("unknown", ""))
+	p.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestPubSub_MaxItems(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := New(&Config{Host: "pubsub.jackal.im", Name: "Publish-Subscribe", MaxItems: 2}, nil, shutdownCh)
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := tUtilPubSubStream(j)
+	defer router.Unbind(stm)
+
+	tUtilPubSubCreateNode(t, p, stm, "princely_musings")
+
+	for _, id := range []string{"1", "2", "3"} {
+		iq := tUtilPubSubIQ(j, xmpp.SetType, pubSubNamespace, tUtilPubSubPublish("princely_musings", id))
+		p.ProcessStanza(iq, stm)
+		elem := stm.FetchElement()
+		require.Equal(t, xmpp.ResultType, elem.Type())
+	}
+	nodeItems, _ := storage.Instance().FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 2, len(nodeItems))
+	require.Equal(t, "2", nodeItems[0].ID)
+	require.Equal(t, "3", nodeItems[1].ID)
+}
+
+func TestPubSub_RetractItem(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	stm1 := tUtilPubSubStream(j1)
+	stm2 := tUtilPubSubStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilPubSubCreateNode(t, p, stm1, "princely_musings")
+
+	iq := tUtilPubSubIQ(j1, xmpp.SetType, pubSubNamespace, tUtilPubSubPublish("princely_musings", "1"))
+	p.ProcessStanza(iq, stm1)
+	_ = stm1.FetchElement()
+
+	tUtilPubSubSubscribe(t, p, stm2, "princely_musings")
+
+	retract := tUtilPubSubElement("retract", "princely_musings")
+	retract.AppendElement(tUtilPubSubItem("2"))
+	iq = tUtilPubSubIQ(j1, xmpp.SetType, pubSubNamespace, retract)
+	p.ProcessStanza(iq, stm1)
+	elem := stm1.FetchElement()
+	require.Equal(t, xmpp.ErrItemNotFound.Error(), elem.Error().Elements().All()[0].Name())
+
+	retract = tUtilPubSubElement("retract", "princely_musings")
+	retract.AppendElement(tUtilPubSubItem("1"))
+	iq = tUtilPubSubIQ(j1, xmpp.SetType, pubSubNamespace, retract)
+	p.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// retract notification
+	elem = stm2.FetchElement()
+	items := elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, "1", items.Elements().Child("retract").Attributes().Get("id"))
+
+	nodeItems, _ := storage.Instance().FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 0, len(nodeItems))
+}
+
+func TestPubSub_RetrieveItems(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	stm1 := tUtilPubSubStream(j1)
+	stm2 := tUtilPubSubStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilPubSubCreateNode(t, p, stm1, "princely_musings")
+
+	for _, id := range []string{"1", "2", "3"} {
+		iq := tUtilPubSubIQ(j1, xmpp.SetType, pubSubNamespace, tUtilPubSubPublish("princely_musings", id))
+		p.ProcessStanza(iq, stm1)
+		_ = stm1.FetchElement()
+	}
+	iq := tUtilPubSubIQ(j2, xmpp.GetType, pubSubNamespace, tUtilPubSubElement("items", "princely_musings"))
+	p.ProcessStanza(iq, stm2)
+	elem := stm2.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	items := elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("items")
+	require.Equal(t, 3, len(items.Elements().Children("item")))
+
+	// max items
+	itemsElem := tUtilPubSubElement("items", "princely_musings")
+	itemsElem.SetAttribute("max_items", "2")
+	iq = tUtilPubSubIQ(j2, xmpp.GetType, pubSubNamespace, itemsElem)
+	p.ProcessStanza(iq, stm2)
+	elem = stm2.FetchElement()
+	items = elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("items")
+	require.Equal(t, 2, len(items.Elements().Children("item")))
+	require.Equal(t, "2", items.Elements().Children("item")[0].Attributes().Get("id"))
+
+	// specific items
+	itemsElem = tUtilPubSubElement("items", "princely_musings")
+	itemsElem.AppendElement(tUtilPubSubItem("1"))
+	iq = tUtilPubSubIQ(j2, xmpp.GetType, pubSubNamespace, itemsElem)
+	p.ProcessStanza(iq, stm2)
+	elem = stm2.FetchElement()
+	items = elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("items")
+	require.Equal(t, 1, len(items.Elements().Children("item")))
+	require.Equal(t, "1", items.Elements().Child("item").Attributes().Get("id"))
+
+	// whitelisted node
+	n, _ := storage.Instance().FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	n.Options.AccessModel = pubsubmodel.AccessModelWhitelist
+	storage.Instance().InsertOrUpdatePubSubNode(n)
+
+	iq = tUtilPubSubIQ(j2, xmpp.GetType, pubSubNamespace, tUtilPubSubElement("items", "princely_musings"))
+	p.ProcessStanza(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func tUtilPubSubItem(id string) *xmpp.Element {
+	item := xmpp.NewElementName("item")
+	item.SetAttribute("id", id)
+	return item
+}
+
+func tUtilPubSubPublish(node, itemID string) xmpp.XElement {
+	entry := xmpp.NewElementNamespace("entry", "http://www.w3.org/2005/Atom")
+	title := xmpp.NewElementName("title")
+	title.SetText("Soliloquy")
+	entry.AppendElement(title)
+
+	item := xmpp.NewElementName("item")
+	if len(itemID) > 0 {
+		item.SetAttribute("id", itemID)
+	}
+	item.AppendElement(entry)
+
+	publish := tUtilPubSubElement("publish", node)
+	publish.AppendElement(item)
+	return publish
+}
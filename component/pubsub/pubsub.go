@@ -0,0 +1,320 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsub
+
+import (
+	"errors"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const mailboxSize = 2048
+
+const defaultMaxItems = 10
+
+const (
+	pubSubNamespace       = "http://jabber.org/protocol/pubsub"
+	pubSubOwnerNamespace  = "http://jabber.org/protocol/pubsub#owner"
+	pubSubEventNamespace  = "http://jabber.org/protocol/pubsub#event"
+	pubSubErrorsNamespace = "http://jabber.org/protocol/pubsub#errors"
+
+	discoInfoNamespace  = "http://jabber.org/protocol/disco#info"
+	discoItemsNamespace = "http://jabber.org/protocol/disco#items"
+)
+
+var serviceFeatures = []xep0030.Feature{
+	discoInfoNamespace,
+	discoItemsNamespace,
+	pubSubNamespace,
+	pubSubNamespace + "#access-open",
+	pubSubNamespace + "#access-presence",
+	pubSubNamespace + "#access-whitelist",
+	pubSubNamespace + "#config-node",
+	pubSubNamespace + "#create-and-configure",
+	pubSubNamespace + "#create-nodes",
+	pubSubNamespace + "#delete-nodes",
+	pubSubNamespace + "#instant-nodes",
+	pubSubNamespace + "#item-ids",
+	pubSubNamespace + "#modify-affiliations",
+	pubSubNamespace + "#persistent-items",
+	pubSubNamespace + "#publish",
+	pubSubNamespace + "#retract-items",
+	pubSubNamespace + "#retrieve-items",
+	pubSubNamespace + "#subscribe",
+}
+
+// Config represents Publish-Subscribe component (XEP-0060) configuration.
+type Config struct {
+	Host     string
+	Name     string
+	MaxItems int
+}
+
+type configProxy struct {
+	Host     string `yaml:"host"`
+	Name     string `yaml:"name"`
+	MaxItems int    `yaml:"max_items"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	if len(p.Host) == 0 {
+		return errors.New("pubsub.Config: host must be specified")
+	}
+	if p.MaxItems < 0 {
+		return errors.New("pubsub.Config: max items must be 0 or higher")
+	}
+	c.Host = p.Host
+	c.Name = p.Name
+	if len(c.Name) == 0 {
+		c.Name = "Publish-Subscribe"
+	}
+	c.MaxItems = p.MaxItems
+	if c.MaxItems == 0 {
+		c.MaxItems = defaultMaxItems
+	}
+	return nil
+}
+
+// PubSub represents a Publish-Subscribe service component.
+type PubSub struct {
+	cfg        *Config
+	actorCh    chan func()
+	shutdownCh <-chan struct{}
+}
+
+// New returns a Publish-Subscribe component.
+func New(cfg *Config, disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *PubSub {
+	p := &PubSub{
+		cfg:        cfg,
+		actorCh:    make(chan func(), mailboxSize),
+		shutdownCh: shutdownCh,
+	}
+	go p.loop()
+	if disco != nil {
+		disco.RegisterServerItem(xep0030.Item{Jid: cfg.Host, Name: cfg.Name})
+		disco.RegisterProvider(cfg.Host, p)
+	}
+	return p
+}
+
+// Host returns Publish-Subscribe component host name.
+func (p *PubSub) Host() string {
+	return p.cfg.Host
+}
+
+// ProcessStanza processes a stanza addressed to the Publish-Subscribe service.
+func (p *PubSub) ProcessStanza(stanza xmpp.Stanza, stm stream.C2S) {
+	p.actorCh <- func() { p.processStanza(stanza, stm) }
+}
+
+// Identities returns all identities associated to the Publish-Subscribe service
+// or to one of its nodes.
+func (p *PubSub) Identities(toJID, fromJID *jid.JID, node string) []xep0030.Identity {
+	if !toJID.IsServer() {
+		return nil
+	}
+	if node == "" {
+		return []xep0030.Identity{{Category: "pubsub", Type: "service", Name: p.cfg.Name}}
+	}
+	var ret []xep0030.Identity
+	p.inActor(func() {
+		n, err := storage.Instance().FetchPubSubNode(p.cfg.Host, node)
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		if n != nil {
+			ret = []xep0030.Identity{{Category: "pubsub", Type: "leaf", Name: n.Options.Title}}
+		}
+	})
+	return ret
+}
+
+// Items returns all nodes hosted by the Publish-Subscribe service.
+func (p *PubSub) Items(toJID, fromJID *jid.JID, node string) ([]xep0030.Item, *xmpp.StanzaError) {
+	if node != "" || !toJID.IsServer() {
+		return nil, nil
+	}
+	var ret []xep0030.Item
+	var sErr *xmpp.StanzaError
+	p.inActor(func() {
+		nodes, err := storage.Instance().FetchPubSubNodes(p.cfg.Host)
+		if err != nil {
+			log.Error(err)
+			sErr = xmpp.ErrInternalServerError
+			return
+		}
+		for _, n := range nodes {
+			ret = append(ret, xep0030.Item{Jid: p.cfg.Host, Node: n.Name, Name: n.Options.Title})
+		}
+	})
+	return ret, sErr
+}
+
+// Features returns all features associated to the Publish-Subscribe service
+// or to one of its nodes.
+func (p *PubSub) Features(toJID, fromJID *jid.JID, node string) ([]xep0030.Feature, *xmpp.StanzaError) {
+	if !toJID.IsServer() {
+		return nil, xmpp.ErrItemNotFound
+	}
+	if node == "" {
+		return serviceFeatures, nil
+	}
+	var ret []xep0030.Feature
+	var sErr *xmpp.StanzaError
+	p.inActor(func() {
+		n, err := storage.Instance().FetchPubSubNode(p.cfg.Host, node)
+		switch {
+		case err != nil:
+			log.Error(err)
+			sErr = xmpp.ErrInternalServerError
+		case n == nil:
+			sErr = xmpp.ErrItemNotFound
+		default:
+			ret = []xep0030.Feature{discoInfoNamespace, pubSubNamespace}
+		}
+	})
+	return ret, sErr
+}
+
+// Form returns the data form associated to the Publish-Subscribe service.
+func (p *PubSub) Form(toJID, fromJID *jid.JID, node string) (*xep0004.DataForm, *xmpp.StanzaError) {
+	return nil, nil
+}
+
+// runs on it's own goroutine
+func (p *PubSub) loop() {
+	for {
+		select {
+		case f := <-p.actorCh:
+			f()
+		case <-p.shutdownCh:
+			return
+		}
+	}
+}
+
+func (p *PubSub) inActor(f func()) {
+	doneCh := make(chan struct{})
+	p.actorCh <- func() {
+		f()
+		close(doneCh)
+	}
+	<-doneCh
+}
+
+func (p *PubSub) processStanza(stanza xmpp.Stanza, stm stream.C2S) {
+	switch stanza := stanza.(type) {
+	case *xmpp.IQ:
+		if stanza.ToJID().IsServer() {
+			p.processIQ(stanza, stm)
+			return
+		}
+		if stanza.IsGet() || stanza.IsSet() {
+			stm.SendElement(stanza.ServiceUnavailableError())
+		}
+	case *xmpp.Message:
+		if !stanza.IsError() {
+			stm.SendElement(stanza.ServiceUnavailableError())
+		}
+	}
+}
+
+func (p *PubSub) processIQ(iq *xmpp.IQ, stm stream.C2S) {
+	if ps := iq.Elements().ChildNamespace("pubsub", pubSubNamespace); ps != nil {
+		p.processPubSubIQ(iq, ps, stm)
+		return
+	}
+	if ps := iq.Elements().ChildNamespace("pubsub", pubSubOwnerNamespace); ps != nil {
+		p.processOwnerIQ(iq, ps, stm)
+		return
+	}
+	if iq.IsGet() || iq.IsSet() {
+		stm.SendElement(iq.ServiceUnavailableError())
+	}
+}
+
+func (p *PubSub) processPubSubIQ(iq *xmpp.IQ, ps xmpp.XElement, stm stream.C2S) {
+	e := ps.Elements()
+	switch {
+	case iq.IsSet() && e.Child("create") != nil:
+		p.createNode(iq, e.Child("create"), e.Child("configure"), stm)
+	case iq.IsSet() && e.Child("publish") != nil:
+		p.publishItem(iq, e.Child("publish"), stm)
+	case iq.IsSet() && e.Child("retract") != nil:
+		p.retractItem(iq, e.Child("retract"), stm)
+	case iq.IsSet() && e.Child("subscribe") != nil:
+		p.subscribe(iq, e.Child("subscribe"), stm)
+	case iq.IsSet() && e.Child("unsubscribe") != nil:
+		p.unsubscribe(iq, e.Child("unsubscribe"), stm)
+	case iq.IsGet() && e.Child("items") != nil:
+		p.retrieveItems(iq, e.Child("items"), stm)
+	case iq.IsGet() || iq.IsSet():
+		stm.SendElement(iq.FeatureNotImplementedError())
+	}
+}
+
+// loadNode fetches a node from storage, replying with the proper
+// error in case it can't be found.
+func (p *PubSub) loadNode(iq *xmpp.IQ, name string, stm stream.C2S) *pubsubmodel.Node {
+	if len(name) == 0 {
+		stm.SendElement(pubSubError(iq, xmpp.ErrBadRequest, "nodeid-required"))
+		return nil
+	}
+	n, err := storage.Instance().FetchPubSubNode(p.cfg.Host, name)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return nil
+	}
+	if n == nil {
+		stm.SendElement(iq.ItemNotFoundError())
+		return nil
+	}
+	return n
+}
+
+func (p *PubSub) notifySubscribers(n *pubsubmodel.Node, eventElem xmpp.XElement) {
+	serviceJID, _ := jid.New("", p.cfg.Host, "", true)
+	for _, sub := range n.Subscriptions {
+		if sub.Subscription != pubsubmodel.SubscriptionSubscribed {
+			continue
+		}
+		toJID, err := jid.NewWithString(sub.JID, true)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		event := xmpp.NewElementNamespace("event", pubSubEventNamespace)
+		event.AppendElement(eventElem)
+
+		msg := xmpp.NewMessageType(uuid.New(), xmpp.NormalType)
+		msg.SetFromJID(serviceJID)
+		msg.SetToJID(toJID)
+		msg.AppendElement(event)
+		router.Route(msg)
+	}
+}
+
+func pubSubError(iq *xmpp.IQ, stanzaErr *xmpp.StanzaError, condition string) xmpp.Stanza {
+	return xmpp.NewErrorStanzaFromStanza(iq, stanzaErr, []xmpp.XElement{
+		xmpp.NewElementNamespace(condition, pubSubErrorsNamespace),
+	})
+}
@@ -0,0 +1,346 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubSub_Config(t *testing.T) {
+	var cfg Config
+	err := cfg.UnmarshalYAML(func(v interface{}) error {
+		*v.(*configProxy) = configProxy{}
+		return nil
+	})
+	require.NotNil(t, err)
+
+	err = cfg.UnmarshalYAML(func(v interface{}) error {
+		*v.(*configProxy) = configProxy{Host: "pubsub.jackal.im", MaxItems: -1}
+		return nil
+	})
+	require.NotNil(t, err)
+
+	err = cfg.UnmarshalYAML(func(v interface{}) error {
+		*v.(*configProxy) = configProxy{Host: "pubsub.jackal.im"}
+		return nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, "pubsub.jackal.im", cfg.Host)
+	require.Equal(t, "Publish-Subscribe", cfg.Name)
+	require.Equal(t, defaultMaxItems, cfg.MaxItems)
+}
+
+func TestPubSub_Disco(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+	require.Equal(t, "pubsub.jackal.im", p.Host())
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	srvJID, _ := jid.New("", "pubsub.jackal.im", "", true)
+
+	identities := p.Identities(srvJID, j, "")
+	require.Equal(t, 1, len(identities))
+	require.Equal(t, "pubsub", identities[0].Category)
+	require.Equal(t, "service", identities[0].Type)
+
+	features, sErr := p.Features(srvJID, j, "")
+	require.Nil(t, sErr)
+	require.Contains(t, features, pubSubNamespace)
+
+	_, sErr = p.Features(srvJID, j, "princely_musings")
+	require.Equal(t, xmpp.ErrItemNotFound, sErr)
+
+	stm := tUtilPubSubStream(j)
+	defer router.Unbind(stm)
+
+	tUtilPubSubCreateNode(t, p, stm, "princely_musings")
+
+	items, sErr := p.Items(srvJID, j, "")
+	require.Nil(t, sErr)
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "princely_musings", items[0].Node)
+
+	identities = p.Identities(srvJID, j, "princely_musings")
+	require.Equal(t, 1, len(identities))
+	require.Equal(t, "leaf", identities[0].Type)
+
+	features, sErr = p.Features(srvJID, j, "princely_musings")
+	require.Nil(t, sErr)
+	require.Contains(t, features, pubSubNamespace)
+}
+
+func TestPubSub_CreateNode(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := tUtilPubSubStream(j)
+	defer router.Unbind(stm)
+
+	tUtilPubSubCreateNode(t, p, stm, "princely_musings")
+
+	// already existing node
+	iq := tUtilPubSubIQ(j, xmpp.SetType, pubSubNamespace, tUtilPubSubElement("create", "princely_musings"))
+	p.ProcessStanza(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+
+	// instant node
+	iq = tUtilPubSubIQ(j, xmpp.SetType, pubSubNamespace, xmpp.NewElementName("create"))
+	p.ProcessStanza(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	create := elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("create")
+	require.NotNil(t, create)
+	require.NotEmpty(t, create.Attributes().Get("node"))
+
+	// create and configure
+	form := &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: []xep0004.Field{
+			{Var: "FORM_TYPE", Type: xep0004.Hidden, Values: []string{nodeConfigFormType}},
+			{Var: accessModelField, Values: []string{pubsubmodel.AccessModelWhitelist}},
+			{Var: maxItemsField, Values: []string{"5"}},
+		},
+	}
+	configure := xmpp.NewElementName("configure")
+	configure.AppendElement(form.Element())
+	iq = tUtilPubSubIQ(j, xmpp.SetType, pubSubNamespace, tUtilPubSubElement("create", "news"), configure)
+	p.ProcessStanza(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	n, _ := storage.Instance().FetchPubSubNode("pubsub.jackal.im", "news")
+	require.NotNil(t, n)
+	require.Equal(t, pubsubmodel.AccessModelWhitelist, n.Options.AccessModel)
+	require.Equal(t, 5, n.Options.MaxItems)
+	require.Equal(t, pubsubmodel.AffiliationOwner, n.Affiliation("ortuman@jackal.im"))
+
+	// remote entities are not allowed to create nodes
+	j2, _ := jid.New("noelia", "example.org", "yard", true)
+	stm2 := tUtilPubSubStream(j2)
+	defer router.Unbind(stm2)
+
+	iq = tUtilPubSubIQ(j2, xmpp.SetType, pubSubNamespace, tUtilPubSubElement("create", "remote"))
+	p.ProcessStanza(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+}
+
+func TestPubSub_ConfigureNode(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	stm1 := tUtilPubSubStream(j1)
+	stm2 := tUtilPubSubStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilPubSubCreateNode(t, p, stm1, "princely_musings")
+
+	// not an owner
+	iq := tUtilPubSubIQ(j2, xmpp.GetType, pubSubOwnerNamespace, tUtilPubSubElement("configure", "princely_musings"))
+	p.ProcessStanza(iq, stm2)
+	elem := stm2.FetchElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	iq = tUtilPubSubIQ(j1, xmpp.GetType, pubSubOwnerNamespace, tUtilPubSubElement("configure", "princely_musings"))
+	p.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	configure := elem.Elements().ChildNamespace("pubsub", pubSubOwnerNamespace).Elements().Child("configure")
+	require.NotNil(t, configure)
+	form, err := xep0004.NewFormFromElement(configure.Elements().ChildNamespace("x", "jabber:x:data"))
+	require.Nil(t, err)
+	require.Equal(t, xep0004.Form, form.Type)
+
+	form.Type = xep0004.Submit
+	for i := range form.Fields {
+		switch form.Fields[i].Var {
+		case titleField:
+			form.Fields[i].Values = []string{"Princely Musings (Atom)"}
+		case accessModelField:
+			form.Fields[i].Values = []string{"unknown"}
+		}
+	}
+	submit := tUtilPubSubElement("configure", "princely_musings")
+	submit.AppendElement(form.Element())
+	iq = tUtilPubSubIQ(j1, xmpp.SetType, pubSubOwnerNamespace, submit)
+	p.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	for i := range form.Fields {
+		if form.Fields[i].Var == accessModelField {
+			form.Fields[i].Values = []string{pubsubmodel.AccessModelPresence}
+		}
+	}
+	submit = tUtilPubSubElement("configure", "princely_musings")
+	submit.AppendElement(form.Element())
+	iq = tUtilPubSubIQ(j1, xmpp.SetType, pubSubOwnerNamespace, submit)
+	p.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	n, _ := storage.Instance().FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, "Princely Musings (Atom)", n.Options.Title)
+	require.Equal(t, pubsubmodel.AccessModelPresence, n.Options.AccessModel)
+}
+
+func TestPubSub_DeleteNode(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	stm1 := tUtilPubSubStream(j1)
+	stm2 := tUtilPubSubStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilPubSubCreateNode(t, p, stm1, "princely_musings")
+	tUtilPubSubSubscribe(t, p, stm2, "princely_musings")
+
+	iq := tUtilPubSubIQ(j2, xmpp.SetType, pubSubOwnerNamespace, tUtilPubSubElement("delete", "princely_musings"))
+	p.ProcessStanza(iq, stm2)
+	elem := stm2.FetchElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	iq = tUtilPubSubIQ(j1, xmpp.SetType, pubSubOwnerNamespace, tUtilPubSubElement("delete", "princely_musings"))
+	p.ProcessStanza(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// delete notification
+	elem = stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	event := elem.Elements().ChildNamespace("event", pubSubEventNamespace)
+	require.NotNil(t, event)
+	require.NotNil(t, event.Elements().Child("delete"))
+
+	n, _ := storage.Instance().FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, n)
+}
+
+func TestPubSub_ManageAffiliations(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := tUtilPubSubStream(j)
+	defer router.Unbind(stm)
+
+	tUtilPubSubCreateNode(t, p, stm, "princely_musings")
+
+	affs := tUtilPubSubElement("affiliations", "princely_musings")
+	affs.AppendElement(tUtilPubSubAffiliation("noelia@jackal.im", pubsubmodel.AffiliationPublisher))
+	iq := tUtilPubSubIQ(j, xmpp.SetType, pubSubOwnerNamespace, affs)
+	p.ProcessStanza(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// removing last owner
+	affs = tUtilPubSubElement("affiliations", "princely_musings")
+	affs.AppendElement(tUtilPubSubAffiliation("ortuman@jackal.im", pubsubmodel.AffiliationNone))
+	iq = tUtilPubSubIQ(j, xmpp.SetType, pubSubOwnerNamespace, affs)
+	p.ProcessStanza(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ErrNotAcceptable.Error(), elem.Error().Elements().All()[0].Name())
+
+	iq = tUtilPubSubIQ(j, xmpp.GetType, pubSubOwnerNamespace, tUtilPubSubElement("affiliations", "princely_musings"))
+	p.ProcessStanza(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	affiliations := elem.Elements().ChildNamespace("pubsub", pubSubOwnerNamespace).Elements().Child("affiliations")
+	require.Equal(t, 2, len(affiliations.Elements().Children("affiliation")))
+}
+
+func tUtilPubSubInitialize() chan struct{} {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	return make(chan struct{})
+}
+
+func tUtilPubSubShutdown(shutdownCh chan struct{}) {
+	close(shutdownCh)
+	storage.Shutdown()
+	router.Shutdown()
+	host.Shutdown()
+}
+
+func tUtilPubSubNew(shutdownCh chan struct{}) *PubSub {
+	return New(&Config{Host: "pubsub.jackal.im", Name: "Publish-Subscribe", MaxItems: defaultMaxItems}, nil, shutdownCh)
+}
+
+func tUtilPubSubStream(j *jid.JID) *stream.MockC2S {
+	stm := stream.NewMockC2S(uuid.New(), j)
+	router.Bind(stm)
+	return stm
+}
+
+func tUtilPubSubIQ(from *jid.JID, iqType, namespace string, elems ...xmpp.XElement) *xmpp.IQ {
+	srvJID, _ := jid.New("", "pubsub.jackal.im", "", true)
+	iq := xmpp.NewIQType(uuid.New(), iqType)
+	iq.SetFromJID(from)
+	iq.SetToJID(srvJID)
+	ps := xmpp.NewElementNamespace("pubsub", namespace)
+	ps.AppendElements(elems)
+	iq.AppendElement(ps)
+	return iq
+}
+
+func tUtilPubSubElement(name, node string) *xmpp.Element {
+	e := xmpp.NewElementName(name)
+	e.SetAttribute("node", node)
+	return e
+}
+
+func tUtilPubSubAffiliation(j, affiliation string) xmpp.XElement {
+	e := xmpp.NewElementName("affiliation")
+	e.SetAttribute("jid", j)
+	e.SetAttribute("affiliation", affiliation)
+	return e
+}
+
+func tUtilPubSubCreateNode(t *testing.T, p *PubSub, stm *stream.MockC2S, node string) {
+	iq := tUtilPubSubIQ(stm.JID(), xmpp.SetType, pubSubNamespace, tUtilPubSubElement("create", node))
+	p.ProcessStanza(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+}
+
+func tUtilPubSubSubscribe(t *testing.T, p *PubSub, stm *stream.MockC2S, node string) {
+	sub := tUtilPubSubElement("subscribe", node)
+	sub.SetAttribute("jid", stm.JID().String())
+	iq := tUtilPubSubIQ(stm.JID(), xmpp.SetType, pubSubNamespace, sub)
+	p.ProcessStanza(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+}
@@ -0,0 +1,139 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsub
+
+import (
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+func (p *PubSub) subscribe(iq *xmpp.IQ, subscribe xmpp.XElement, stm stream.C2S) {
+	n := p.loadNode(iq, subscribe.Attributes().Get("node"), stm)
+	if n == nil {
+		return
+	}
+	subJID, err := jid.NewWithString(subscribe.Attributes().Get("jid"), false)
+	if err != nil || !subJID.Matches(iq.FromJID(), jid.MatchesBare) {
+		stm.SendElement(pubSubError(iq, xmpp.ErrBadRequest, "invalid-jid"))
+		return
+	}
+	if !p.checkAccess(n, iq, stm) {
+		return
+	}
+	sub := n.Subscription(subJID.String())
+	if sub == nil {
+		n.Subscriptions = append(n.Subscriptions, pubsubmodel.Subscription{
+			SubID:        uuid.New(),
+			JID:          subJID.String(),
+			Subscription: pubsubmodel.SubscriptionSubscribed,
+		})
+		if err := storage.Instance().InsertOrUpdatePubSubNode(n); err != nil {
+			log.Error(err)
+			stm.SendElement(iq.InternalServerError())
+			return
+		}
+		sub = &n.Subscriptions[len(n.Subscriptions)-1]
+	}
+	s := xmpp.NewElementName("subscription")
+	s.SetAttribute("node", n.Name)
+	s.SetAttribute("jid", sub.JID)
+	s.SetAttribute("subid", sub.SubID)
+	s.SetAttribute("subscription", sub.Subscription)
+	ps := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+	ps.AppendElement(s)
+	result := iq.ResultIQ()
+	result.AppendElement(ps)
+	stm.SendElement(result)
+}
+
+func (p *PubSub) unsubscribe(iq *xmpp.IQ, unsubscribe xmpp.XElement, stm stream.C2S) {
+	n := p.loadNode(iq, unsubscribe.Attributes().Get("node"), stm)
+	if n == nil {
+		return
+	}
+	subJID, err := jid.NewWithString(unsubscribe.Attributes().Get("jid"), false)
+	if err != nil {
+		stm.SendElement(pubSubError(iq, xmpp.ErrBadRequest, "invalid-jid"))
+		return
+	}
+	if !subJID.Matches(iq.FromJID(), jid.MatchesBare) {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	sub := n.Subscription(subJID.String())
+	if sub == nil {
+		stm.SendElement(pubSubError(iq, xmpp.ErrUnexpectedCondition, "not-subscribed"))
+		return
+	}
+	if subID := unsubscribe.Attributes().Get("subid"); len(subID) > 0 && subID != sub.SubID {
+		stm.SendElement(pubSubError(iq, xmpp.ErrNotAcceptable, "invalid-subid"))
+		return
+	}
+	n.DeleteSubscription(subJID.String())
+	if err := storage.Instance().InsertOrUpdatePubSubNode(n); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	stm.SendElement(iq.ResultIQ())
+}
+
+// checkAccess reports whether or not the IQ sender is allowed to subscribe
+// to or retrieve items from a node, replying with the proper error otherwise.
+func (p *PubSub) checkAccess(n *pubsubmodel.Node, iq *xmpp.IQ, stm stream.C2S) bool {
+	bareJID := iq.FromJID().ToBareJID().String()
+	affiliation := n.Affiliation(bareJID)
+	switch affiliation {
+	case pubsubmodel.AffiliationOwner:
+		return true
+	case pubsubmodel.AffiliationOutcast:
+		stm.SendElement(iq.ForbiddenError())
+		return false
+	}
+	switch n.Options.AccessModel {
+	case pubsubmodel.AccessModelPresence:
+		if !p.isPresenceSubscribed(n, bareJID) {
+			stm.SendElement(pubSubError(iq, xmpp.ErrNotAuthorized, "presence-subscription-required"))
+			return false
+		}
+	case pubsubmodel.AccessModelWhitelist:
+		if affiliation == pubsubmodel.AffiliationNone {
+			stm.SendElement(pubSubError(iq, xmpp.ErrNotAllowed, "closed-node"))
+			return false
+		}
+	}
+	return true
+}
+
+// isPresenceSubscribed returns whether or not a JID is subscribed
+// to the presence of any of the node owners.
+func (p *PubSub) isPresenceSubscribed(n *pubsubmodel.Node, bareJID string) bool {
+	for _, aff := range n.Affiliations {
+		if aff.Affiliation != pubsubmodel.AffiliationOwner {
+			continue
+		}
+		ownerJID, err := jid.NewWithString(aff.JID, true)
+		if err != nil || !host.IsLocalHost(ownerJID.Domain()) {
+			continue
+		}
+		ri, err := storage.Instance().FetchRosterItem(ownerJID.Node(), bareJID)
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		if ri != nil && (ri.Subscription == rostermodel.SubscriptionFrom || ri.Subscription == rostermodel.SubscriptionBoth) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsub
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPubSub_Subscribe(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	stm1 := tUtilPubSubStream(j1)
+	stm2 := tUtilPubSubStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilPubSubCreateNode(t, p, stm1, "princely_musings")
+
+	// subscribing someone else
+	sub := tUtilPubSubElement("subscribe", "princely_musings")
+	sub.SetAttribute("jid", j1.String())
+	iq := tUtilPubSubIQ(j2, xmpp.SetType, pubSubNamespace, sub)
+	p.ProcessStanza(iq, stm2)
+	elem := stm2.FetchElement()
+	require.Equal(t, xmpp.ErrBadRequest.Error(), elem.Error().Elements().All()[0].Name())
+	require.NotNil(t, elem.Error().Elements().ChildNamespace("invalid-jid", pubSubErrorsNamespace))
+
+	sub = tUtilPubSubElement("subscribe", "princely_musings")
+	sub.SetAttribute("jid", j2.String())
+	iq = tUtilPubSubIQ(j2, xmpp.SetType, pubSubNamespace, sub)
+	p.ProcessStanza(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	subscription := elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("subscription")
+	require.Equal(t, j2.String(), subscription.Attributes().Get("jid"))
+	require.Equal(t, pubsubmodel.SubscriptionSubscribed, subscription.Attributes().Get("subscription"))
+	require.NotEmpty(t, subscription.Attributes().Get("subid"))
+
+	n, _ := storage.Instance().FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 1, len(n.Subscriptions))
+
+	// unsubscribe
+	unsub := tUtilPubSubElement("unsubscribe", "princely_musings")
+	unsub.SetAttribute("jid", j2.String())
+	iq = tUtilPubSubIQ(j2, xmpp.SetType, pubSubNamespace, unsub)
+	p.ProcessStanza(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	p.ProcessStanza(iq, stm2)
+	elem = stm2.FetchElement()
+	require.NotNil(t, elem.Error().Elements().ChildNamespace("not-subscribed", pubSubErrorsNamespace))
+
+	n, _ = storage.Instance().FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 0, len(n.Subscriptions))
+}
+
+func TestPubSub_PresenceAccessModel(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	stm1 := tUtilPubSubStream(j1)
+	stm2 := tUtilPubSubStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilPubSubCreateNode(t, p, stm1, "princely_musings")
+
+	n, _ := storage.Instance().FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	n.Options.AccessModel = pubsubmodel.AccessModelPresence
+	storage.Instance().InsertOrUpdatePubSubNode(n)
+
+	sub := tUtilPubSubElement("subscribe", "princely_musings")
+	sub.SetAttribute("jid", j2.String())
+	iq := tUtilPubSubIQ(j2, xmpp.SetType, pubSubNamespace, sub)
+	p.ProcessStanza(iq, stm2)
+	elem := stm2.FetchElement()
+	require.Equal(t, xmpp.ErrNotAuthorized.Error(), elem.Error().Elements().All()[0].Name())
+	require.NotNil(t, elem.Error().Elements().ChildNamespace("presence-subscription-required", pubSubErrorsNamespace))
+
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     "ortuman",
+		JID:          "noelia@jackal.im",
+		Subscription: rostermodel.SubscriptionFrom,
+	})
+	p.ProcessStanza(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+}
+
+func TestPubSub_WhitelistAccessModel(t *testing.T) {
+	shutdownCh := tUtilPubSubInitialize()
+	defer tUtilPubSubShutdown(shutdownCh)
+
+	p := tUtilPubSubNew(shutdownCh)
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	stm1 := tUtilPubSubStream(j1)
+	stm2 := tUtilPubSubStream(j2)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+
+	tUtilPubSubCreateNode(t, p, stm1, "princely_musings")
+
+	n, _ := storage.Instance().FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	n.Options.AccessModel = pubsubmodel.AccessModelWhitelist
+	storage.Instance().InsertOrUpdatePubSubNode(n)
+
+	sub := tUtilPubSubElement("subscribe", "princely_musings")
+	sub.SetAttribute("jid", j2.String())
+	iq := tUtilPubSubIQ(j2, xmpp.SetType, pubSubNamespace, sub)
+	p.ProcessStanza(iq, stm2)
+	elem := stm2.FetchElement()
+	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
+	require.NotNil(t, elem.Error().Elements().ChildNamespace("closed-node", pubSubErrorsNamespace))
+
+	// whitelist subscriber
+	affs := tUtilPubSubElement("affiliations", "princely_musings")
+	affs.AppendElement(tUtilPubSubAffiliation("noelia@jackal.im", pubsubmodel.AffiliationMember))
+	p.ProcessStanza(tUtilPubSubIQ(j1, xmpp.SetType, pubSubOwnerNamespace, affs), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	p.ProcessStanza(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// outcasts lose their subscriptions
+	affs = tUtilPubSubElement("affiliations", "princely_musings")
+	affs.AppendElement(tUtilPubSubAffiliation("noelia@jackal.im", pubsubmodel.AffiliationOutcast))
+	p.ProcessStanza(tUtilPubSubIQ(j1, xmpp.SetType, pubSubOwnerNamespace, affs), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	n, _ = storage.Instance().FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 0, len(n.Subscriptions))
+
+	p.ProcessStanza(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+}
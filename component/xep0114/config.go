@@ -0,0 +1,100 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0114
+
+import (
+	"errors"
+	"time"
+)
+
+const (
+	defaultTransportPort      = 5275
+	defaultTransportKeepAlive = time.Duration(10) * time.Minute
+	defaultConnectTimeout     = time.Duration(5) * time.Second
+	defaultMaxStanzaSize      = 131072
+)
+
+// TransportConfig represents external component transport configuration.
+type TransportConfig struct {
+	BindAddress string
+	Port        int
+	KeepAlive   time.Duration
+}
+
+type transportConfigProxy struct {
+	BindAddress string `yaml:"bind_addr"`
+	Port        int    `yaml:"port"`
+	KeepAlive   int    `yaml:"keep_alive"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := transportConfigProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	c.BindAddress = p.BindAddress
+	c.Port = p.Port
+	if c.Port == 0 {
+		c.Port = defaultTransportPort
+	}
+	if p.KeepAlive > 0 {
+		c.KeepAlive = time.Duration(p.KeepAlive) * time.Second
+	} else {
+		c.KeepAlive = defaultTransportKeepAlive
+	}
+	return nil
+}
+
+// Config represents an external component configuration.
+type Config struct {
+	Host           string
+	Name           string
+	Secret         string
+	ConnectTimeout time.Duration
+	MaxStanzaSize  int
+	Transport      TransportConfig
+}
+
+type configProxy struct {
+	Host           string          `yaml:"host"`
+	Name           string          `yaml:"name"`
+	Secret         string          `yaml:"secret"`
+	ConnectTimeout int             `yaml:"connect_timeout"`
+	MaxStanzaSize  int             `yaml:"max_stanza_size"`
+	Transport      TransportConfig `yaml:"transport"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	c.Host = p.Host
+	if len(c.Host) == 0 {
+		return errors.New("xep0114.Config: host must be specified")
+	}
+	c.Name = p.Name
+	c.Secret = p.Secret
+	if len(c.Secret) == 0 {
+		return errors.New("xep0114.Config: secret must be specified")
+	}
+	c.ConnectTimeout = time.Duration(p.ConnectTimeout) * time.Second
+	if c.ConnectTimeout == 0 {
+		c.ConnectTimeout = defaultConnectTimeout
+	}
+	c.MaxStanzaSize = p.MaxStanzaSize
+	if c.MaxStanzaSize == 0 {
+		c.MaxStanzaSize = defaultMaxStanzaSize
+	}
+	c.Transport = p.Transport
+	if c.Transport.Port == 0 {
+		c.Transport.Port = defaultTransportPort
+		c.Transport.KeepAlive = defaultTransportKeepAlive
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0114
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestTransportConfig(t *testing.T) {
+	trCfg := TransportConfig{}
+	err := yaml.Unmarshal([]byte(`bind_addr 0.0.0.0`), &trCfg)
+	require.NotNil(t, err)
+
+	err = yaml.Unmarshal([]byte(`bind_addr: 0.0.0.0`), &trCfg)
+	require.Nil(t, err)
+	require.Equal(t, "0.0.0.0", trCfg.BindAddress)
+	require.Equal(t, 5275, trCfg.Port)
+	require.Equal(t, time.Duration(600)*time.Second, trCfg.KeepAlive)
+}
+
+func TestConfig(t *testing.T) {
+	cfg := Config{}
+	err := yaml.Unmarshal([]byte(`secret: s3cr3t`), &cfg)
+	require.NotNil(t, err) // missing host
+
+	err = yaml.Unmarshal([]byte(`host: gateway.jackal.im`), &cfg)
+	require.NotNil(t, err) // missing secret
+
+	rawCfg := `
+host: gateway.jackal.im
+name: Gateway
+secret: s3cr3t
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.Nil(t, err) // defaults
+	require.Equal(t, "gateway.jackal.im", cfg.Host)
+	require.Equal(t, "Gateway", cfg.Name)
+	require.Equal(t, defaultConnectTimeout, cfg.ConnectTimeout)
+	require.Equal(t, defaultMaxStanzaSize, cfg.MaxStanzaSize)
+	require.Equal(t, defaultTransportPort, cfg.Transport.Port)
+
+	rawCfg = `
+host: gateway.jackal.im
+secret: s3cr3t
+connect_timeout: 3
+max_stanza_size: 8192
+transport:
+  port: 5999
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, 3*time.Second, cfg.ConnectTimeout)
+	require.Equal(t, 8192, cfg.MaxStanzaSize)
+	require.Equal(t, 5999, cfg.Transport.Port)
+}
@@ -0,0 +1,294 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0114
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/ortuman/jackal/errors"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/session"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+const streamMailboxSize = 64
+
+const (
+	connecting uint32 = iota
+	handshaking
+	authenticated
+	disconnected
+)
+
+type streamConfig struct {
+	host           string
+	secret         string
+	transport      transport.Transport
+	connectTimeout time.Duration
+	maxStanzaSize  int
+}
+
+type inStream struct {
+	id        string
+	cfg       *streamConfig
+	ext       *External
+	state     uint32
+	connectTm *time.Timer
+	sess      *session.Session
+	actorCh   chan func()
+}
+
+func newInStream(cfg *streamConfig, ext *External) *inStream {
+	s := &inStream{
+		id:      nextInID(),
+		cfg:     cfg,
+		ext:     ext,
+		actorCh: make(chan func(), streamMailboxSize),
+	}
+	j, _ := jid.New("", cfg.host, "", true)
+	s.sess = session.New(s.id, &session.Config{
+		JID:           j,
+		Transport:     cfg.transport,
+		MaxStanzaSize: cfg.maxStanzaSize,
+		RemoteDomain:  cfg.host,
+		IsComponent:   true,
+	})
+	if cfg.connectTimeout > 0 {
+		s.connectTm = time.AfterFunc(cfg.connectTimeout, s.connectTimeout)
+	}
+	go s.loop()
+	go s.doRead() // start reading transport...
+	return s
+}
+
+// ID returns stream identifier.
+func (s *inStream) ID() string {
+	return s.id
+}
+
+// SendElement writes an XMPP element to the component stream.
+func (s *inStream) SendElement(elem xmpp.XElement) {
+	if s.getState() == disconnected {
+		return
+	}
+	s.actorCh <- func() { s.writeElement(elem) }
+}
+
+// Disconnect disconnects component stream.
+func (s *inStream) Disconnect(err error) {
+	if s.getState() == disconnected {
+		return
+	}
+	waitCh := make(chan struct{})
+	s.actorCh <- func() {
+		s.disconnect(err)
+		close(waitCh)
+	}
+	<-waitCh
+}
+
+func (s *inStream) connectTimeout() {
+	s.actorCh <- func() { s.disconnect(streamerror.ErrConnectionTimeout) }
+}
+
+// runs on its own goroutine
+func (s *inStream) loop() {
+	for {
+		f := <-s.actorCh
+		f()
+		if s.getState() == disconnected {
+			return
+		}
+	}
+}
+
+// runs on its own goroutine
+func (s *inStream) doRead() {
+	if elem, sErr := s.sess.Receive(); sErr == nil {
+		s.actorCh <- func() {
+			s.readElement(elem)
+		}
+	} else {
+		s.actorCh <- func() {
+			if s.getState() == disconnected {
+				return // already disconnected...
+			}
+			s.handleSessionError(sErr)
+		}
+	}
+}
+
+func (s *inStream) handleElement(elem xmpp.XElement) {
+	switch s.getState() {
+	case connecting:
+		s.handleConnecting(elem)
+	case handshaking:
+		s.handleHandshaking(elem)
+	case authenticated:
+		s.handleAuthenticated(elem)
+	}
+}
+
+func (s *inStream) handleConnecting(elem xmpp.XElement) {
+	// cancel connection timeout timer
+	if s.connectTm != nil {
+		s.connectTm.Stop()
+		s.connectTm = nil
+	}
+	s.sess.Open()
+	s.setState(handshaking)
+}
+
+func (s *inStream) handleHandshaking(elem xmpp.XElement) {
+	if elem.Name() != "handshake" {
+		s.disconnectWithStreamError(streamerror.ErrNotAuthorized)
+		return
+	}
+	h := sha1.New()
+	h.Write([]byte(s.sess.StreamID() + s.cfg.secret))
+	if hex.EncodeToString(h.Sum(nil)) != elem.Text() {
+		log.Infof("failed component handshake... (host: %s)", s.cfg.host)
+		s.disconnectWithStreamError(streamerror.ErrNotAuthorized)
+		return
+	}
+	if !s.ext.bind(s) {
+		log.Infof("component host already connected... (host: %s)", s.cfg.host)
+		s.disconnectWithStreamError(streamerror.ErrConflict)
+		return
+	}
+	log.Infof("component stream authenticated... (host: %s)", s.cfg.host)
+
+	s.setState(authenticated)
+	s.writeElement(xmpp.NewElementName("handshake"))
+}
+
+func (s *inStream) handleAuthenticated(elem xmpp.XElement) {
+	stanza, ok := elem.(xmpp.Stanza)
+	if !ok {
+		s.disconnectWithStreamError(streamerror.ErrUnsupportedStanzaType)
+		return
+	}
+	switch err := router.Route(stanza); err {
+	case nil:
+	case router.ErrNotAuthenticated:
+		if message, ok := stanza.(*xmpp.Message); ok {
+			if off := module.Modules().Offline; off != nil {
+				off.ArchiveMessage(message)
+				return
+			}
+		}
+		s.writeRoutingError(stanza, xmpp.ErrServiceUnavailable)
+	case router.ErrFailedRemoteConnect:
+		s.writeRoutingError(stanza, xmpp.ErrRemoteServerNotFound)
+	default:
+		s.writeRoutingError(stanza, xmpp.ErrServiceUnavailable)
+	}
+}
+
+func (s *inStream) writeRoutingError(stanza xmpp.Stanza, stanzaErr *xmpp.StanzaError) {
+	switch stanza := stanza.(type) {
+	case *xmpp.IQ:
+		if !stanza.IsGet() && !stanza.IsSet() {
+			return
+		}
+	case *xmpp.Presence:
+		return
+	}
+	s.writeStanzaErrorResponse(stanza, stanzaErr)
+}
+
+func (s *inStream) writeStanzaErrorResponse(elem xmpp.XElement, stanzaErr *xmpp.StanzaError) {
+	resp := xmpp.NewElementFromElement(elem)
+	resp.SetType(xmpp.ErrorType)
+	resp.SetFrom(elem.To())
+	resp.SetTo(elem.From())
+	resp.AppendElement(stanzaErr.Element())
+	s.writeElement(resp)
+}
+
+func (s *inStream) writeElement(elem xmpp.XElement) {
+	s.sess.Send(elem)
+}
+
+func (s *inStream) readElement(elem xmpp.XElement) {
+	if elem != nil {
+		s.handleElement(elem)
+	}
+	if s.getState() != disconnected {
+		go s.doRead()
+	}
+}
+
+func (s *inStream) handleSessionError(sErr *session.Error) {
+	switch err := sErr.UnderlyingErr.(type) {
+	case nil:
+		s.disconnect(nil)
+	case *streamerror.Error:
+		s.disconnectWithStreamError(err)
+	case *xmpp.StanzaError:
+		s.writeStanzaErrorResponse(sErr.Element, err)
+	default:
+		log.Error(err)
+		s.disconnectWithStreamError(streamerror.ErrUndefinedCondition)
+	}
+}
+
+func (s *inStream) disconnect(err error) {
+	if s.getState() == disconnected {
+		return
+	}
+	switch err {
+	case nil:
+		s.disconnectClosingSession(false)
+	default:
+		if stmErr, ok := err.(*streamerror.Error); ok {
+			s.disconnectWithStreamError(stmErr)
+		} else {
+			log.Error(err)
+			s.disconnectClosingSession(false)
+		}
+	}
+}
+
+func (s *inStream) disconnectWithStreamError(err *streamerror.Error) {
+	if s.getState() == connecting {
+		s.sess.Open()
+	}
+	s.writeElement(err.Element())
+	s.disconnectClosingSession(true)
+}
+
+func (s *inStream) disconnectClosingSession(closeSession bool) {
+	if closeSession {
+		s.sess.Close()
+	}
+	s.ext.unbind(s)
+
+	s.setState(disconnected)
+	s.cfg.transport.Close()
+}
+
+func (s *inStream) setState(state uint32) {
+	atomic.StoreUint32(&s.state, state)
+}
+
+func (s *inStream) getState() uint32 {
+	return atomic.LoadUint32(&s.state)
+}
+
+var inStreamCounter uint64
+
+func nextInID() string {
+	return fmt.Sprintf("comp:in:%d", atomic.AddUint64(&inStreamCounter, 1))
+}
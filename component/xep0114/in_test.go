@@ -0,0 +1,185 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0114
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/transport"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStream_ConnectTimeout(t *testing.T) {
+	stm, _ := tUtilInStreamInit(t, tUtilExternal())
+	time.Sleep(time.Millisecond * 1500)
+	require.Equal(t, disconnected, stm.getState())
+}
+
+func TestStream_Disconnect(t *testing.T) {
+	stm, conn := tUtilInStreamInit(t, tUtilExternal())
+	stm.Disconnect(nil)
+	require.True(t, conn.waitClose())
+
+	require.Equal(t, disconnected, stm.getState())
+}
+
+func TestStream_HostUnknown(t *testing.T) {
+	stm, conn := tUtilInStreamInit(t, tUtilExternal())
+	conn.inboundWriteString(`<?xml version="1.0"?>
+	<stream:stream xmlns:stream="http://etherx.jabber.org/streams" xmlns="jabber:component:accept" to="foo.jackal.im">
+`)
+	require.True(t, conn.waitClose())
+	require.Equal(t, disconnected, stm.getState())
+}
+
+func TestStream_Handshake(t *testing.T) {
+	tUtilRouterInit()
+	defer tUtilRouterShutdown()
+
+	// invalid handshake
+	stm, conn := tUtilInStreamInit(t, tUtilExternal())
+	tUtilInStreamOpen(conn)
+	elem := conn.outboundRead()
+	require.Equal(t, "stream:stream", elem.Name())
+	require.Equal(t, "gateway.jackal.im", elem.From())
+	require.Equal(t, handshaking, stm.getState())
+
+	conn.inboundWriteString(`<handshake>0123456789abcdef</handshake>`)
+	require.True(t, conn.waitClose())
+	require.Equal(t, disconnected, stm.getState())
+	require.False(t, router.IsComponentHost("gateway.jackal.im"))
+
+	// valid handshake
+	disco := xep0030.New(nil)
+	ext := &External{cfg: &Config{Host: "gateway.jackal.im", Name: "Gateway"}, disco: disco}
+	stm, conn = tUtilInStreamInit(t, ext)
+	tUtilInStreamHandshake(t, stm, conn)
+	require.Equal(t, authenticated, stm.getState())
+	require.True(t, router.IsComponentHost("gateway.jackal.im"))
+
+	// host conflict
+	stm2, conn2 := tUtilInStreamInit(t, ext)
+	tUtilInStreamOpen(conn2)
+	_ = conn2.outboundRead() // stream:stream
+	conn2.inboundWriteString(`<handshake>` + tUtilHandshakeDigest(stm2) + `</handshake>`)
+	require.True(t, conn2.waitClose())
+	require.Equal(t, disconnected, stm2.getState())
+	require.True(t, router.IsComponentHost("gateway.jackal.im"))
+
+	stm.Disconnect(nil)
+	require.True(t, conn.waitClose())
+	require.False(t, router.IsComponentHost("gateway.jackal.im"))
+}
+
+func TestStream_Routing(t *testing.T) {
+	tUtilRouterInit()
+	defer tUtilRouterShutdown()
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Password: "1234"})
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	userStm := stream.NewMockC2S(uuid.New(), j)
+	userStm.SetAuthenticated(true)
+	router.Bind(userStm)
+
+	stm, conn := tUtilInStreamInit(t, tUtilExternal())
+	tUtilInStreamHandshake(t, stm, conn)
+
+	// component -> user
+	conn.inboundWriteString(`<message from="bot@gateway.jackal.im" to="ortuman@jackal.im/balcony" type="chat"><body>hi!</body></message>`)
+	elem := userStm.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "bot@gateway.jackal.im", elem.From())
+
+	// component -> unknown user
+	conn.inboundWriteString(`<iq id="abc" from="bot@gateway.jackal.im" to="noelia@jackal.im" type="get"><ping xmlns="urn:xmpp:ping"/></iq>`)
+	elem = conn.outboundRead()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, xmpp.ErrorType, elem.Type())
+	require.NotNil(t, elem.Elements().Child("error"))
+
+	// user -> component
+	gwJID, _ := jid.New("bot", "gateway.jackal.im", "", true)
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(j)
+	msg.SetToJID(gwJID)
+	require.Nil(t, router.Route(msg))
+
+	elem = conn.outboundRead()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, msg.ID(), elem.ID())
+	require.Equal(t, "bot@gateway.jackal.im", elem.To())
+
+	// invalid 'from' domain
+	conn.inboundWriteString(`<message from="bot@jackal.im" to="ortuman@jackal.im/balcony" type="chat"><body>hi!</body></message>`)
+	require.True(t, conn.waitClose())
+	require.Equal(t, disconnected, stm.getState())
+}
+
+func tUtilRouterInit() {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+}
+
+func tUtilRouterShutdown() {
+	router.Shutdown()
+	storage.Shutdown()
+	host.Shutdown()
+}
+
+func tUtilExternal() *External {
+	return &External{cfg: &Config{Host: "gateway.jackal.im", Name: "Gateway"}}
+}
+
+func tUtilInStreamInit(t *testing.T, ext *External) (*inStream, *fakeSocketConn) {
+	conn := newFakeSocketConn()
+	tr := transport.NewSocketTransport(conn, 4096)
+	stm := newInStream(&streamConfig{
+		host:           "gateway.jackal.im",
+		secret:         "s3cr3t",
+		transport:      tr,
+		connectTimeout: time.Second,
+		maxStanzaSize:  8192,
+	}, ext)
+	return stm, conn
+}
+
+func tUtilInStreamOpen(conn *fakeSocketConn) {
+	s := `<?xml version="1.0"?>
+	<stream:stream xmlns:stream="http://etherx.jabber.org/streams" xmlns="jabber:component:accept" to="gateway.jackal.im">
+`
+	conn.inboundWriteString(s)
+}
+
+func tUtilInStreamHandshake(t *testing.T, stm *inStream, conn *fakeSocketConn) {
+	tUtilInStreamOpen(conn)
+	elem := conn.outboundRead()
+	require.Equal(t, "stream:stream", elem.Name())
+
+	conn.inboundWriteString(`<handshake>` + tUtilHandshakeDigest(stm) + `</handshake>`)
+	elem = conn.outboundRead()
+	require.Equal(t, "handshake", elem.Name())
+	require.Equal(t, 0, elem.Elements().Count())
+}
+
+func tUtilHandshakeDigest(stm *inStream) string {
+	h := sha1.New()
+	h.Write([]byte(stm.sess.StreamID() + "s3cr3t"))
+	return hex.EncodeToString(h.Sum(nil))
+}
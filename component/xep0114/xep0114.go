@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0114
+
+import (
+	"net"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/transport"
+)
+
+var listenerProvider = net.Listen
+
+// External represents an external component listener (XEP-0114).
+type External struct {
+	cfg       *Config
+	disco     *xep0030.DiscoInfo
+	ln        net.Listener
+	listening uint32
+	mu        sync.Mutex
+	stm       stream.InOutStream
+}
+
+// New returns a new external component listener instance.
+func New(cfg *Config, disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *External {
+	e := &External{
+		cfg:   cfg,
+		disco: disco,
+	}
+	go e.start()
+	go func() {
+		<-shutdownCh
+		e.shutdown()
+	}()
+	return e
+}
+
+// Host returns external component host domain.
+func (e *External) Host() string {
+	return e.cfg.Host
+}
+
+func (e *External) start() {
+	bindAddr := e.cfg.Transport.BindAddress
+	port := e.cfg.Transport.Port
+	address := bindAddr + ":" + strconv.Itoa(port)
+
+	log.Infof("xep0114: listening at %s (host: %s)", address, e.cfg.Host)
+
+	if err := e.listenConn(address); err != nil {
+		log.Fatalf("%v", err)
+	}
+}
+
+func (e *External) shutdown() {
+	if atomic.CompareAndSwapUint32(&e.listening, 1, 0) {
+		e.ln.Close()
+	}
+	e.mu.Lock()
+	stm := e.stm
+	e.mu.Unlock()
+	if stm != nil {
+		stm.Disconnect(nil)
+	}
+}
+
+func (e *External) listenConn(address string) error {
+	ln, err := listenerProvider("tcp", address)
+	if err != nil {
+		return err
+	}
+	e.ln = ln
+
+	atomic.StoreUint32(&e.listening, 1)
+	for atomic.LoadUint32(&e.listening) == 1 {
+		conn, err := ln.Accept()
+		if err == nil {
+			go e.startStream(transport.NewSocketTransport(conn, e.cfg.Transport.KeepAlive))
+			continue
+		}
+	}
+	return nil
+}
+
+func (e *External) startStream(tr transport.Transport) {
+	newInStream(&streamConfig{
+		host:           e.cfg.Host,
+		secret:         e.cfg.Secret,
+		transport:      tr,
+		connectTimeout: e.cfg.ConnectTimeout,
+		maxStanzaSize:  e.cfg.MaxStanzaSize,
+	}, e)
+}
+
+// bind marks stm as the authenticated stream for the component host.
+// Only one stream per host can be bound at a time.
+func (e *External) bind(stm stream.InOutStream) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stm != nil {
+		return false
+	}
+	e.stm = stm
+	router.BindComponent(e.cfg.Host, stm)
+	if e.disco != nil {
+		e.disco.RegisterServerItem(xep0030.Item{Jid: e.cfg.Host, Name: e.cfg.Name})
+	}
+	return true
+}
+
+func (e *External) unbind(stm stream.InOutStream) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.stm != stm {
+		return
+	}
+	e.stm = nil
+	router.UnbindComponent(e.cfg.Host)
+	if e.disco != nil {
+		e.disco.UnregisterServerItem(xep0030.Item{Jid: e.cfg.Host, Name: e.cfg.Name})
+	}
+}
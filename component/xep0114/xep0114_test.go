@@ -0,0 +1,209 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0114
+
+import (
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternal_Listen(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	shutdownCh := make(chan struct{})
+	cfg := Config{
+		Host:           "gateway.jackal.im",
+		Secret:         "s3cr3t",
+		ConnectTimeout: time.Second * time.Duration(5),
+		MaxStanzaSize:  8192,
+		Transport: TransportConfig{
+			Port:      12779,
+			KeepAlive: time.Duration(600) * time.Second,
+		},
+	}
+	x := New(&cfg, nil, shutdownCh)
+	require.Equal(t, "gateway.jackal.im", x.Host())
+
+	time.Sleep(time.Millisecond * 150)
+
+	conn, err := net.Dial("tcp", "127.0.0.1:12779")
+	require.Nil(t, err)
+	defer conn.Close()
+
+	_, err = conn.Write([]byte(`<?xml version="1.0" encoding="UTF-8">`))
+	require.Nil(t, err)
+
+	close(shutdownCh)
+	time.Sleep(time.Millisecond * 150) // wait until listener is closed
+
+	require.Equal(t, uint32(0), atomic.LoadUint32(&x.listening))
+}
+
+func TestExternal_BindUnbind(t *testing.T) {
+	router.Initialize(&router.Config{})
+	defer router.Shutdown()
+
+	disco := xep0030.New(nil)
+	x := &External{cfg: &Config{Host: "gateway.jackal.im", Name: "Gateway"}, disco: disco}
+
+	stm1 := &inStream{id: "comp:in:1"}
+	stm2 := &inStream{id: "comp:in:2"}
+
+	require.True(t, x.bind(stm1))
+	require.True(t, router.IsComponentHost("gateway.jackal.im"))
+	require.False(t, x.bind(stm2))
+
+	x.unbind(stm2) // not bound... should be ignored
+	require.True(t, router.IsComponentHost("gateway.jackal.im"))
+
+	x.unbind(stm1)
+	require.False(t, router.IsComponentHost("gateway.jackal.im"))
+}
+
+var errFakeSockAlreadyClosed = errors.New("fakeSockReaderWriter: already closed")
+
+type fakeSockReaderWriter struct {
+	r *io.PipeReader
+	w *io.PipeWriter
+}
+
+func newFakeSockReaderWriter() *fakeSockReaderWriter {
+	pr, pw := io.Pipe()
+	return &fakeSockReaderWriter{r: pr, w: pw}
+}
+
+func (frw *fakeSockReaderWriter) Write(b []byte) (n int, err error) {
+	return frw.w.Write(b)
+}
+
+func (frw *fakeSockReaderWriter) Read(b []byte) (n int, err error) {
+	return frw.r.Read(b)
+}
+
+func (frw *fakeSockReaderWriter) Close() error {
+	frw.w.Close()
+	frw.r.Close()
+	return nil
+}
+
+type fakeSocketConn struct {
+	rd      *fakeSockReaderWriter
+	wr      *fakeSockReaderWriter
+	wrCh    chan []byte
+	closeCh chan struct{}
+	closed  uint32
+}
+
+func newFakeSocketConn() *fakeSocketConn {
+	fc := &fakeSocketConn{
+		rd:      newFakeSockReaderWriter(),
+		wr:      newFakeSockReaderWriter(),
+		wrCh:    make(chan []byte, 256),
+		closeCh: make(chan struct{}, 1),
+	}
+	go fc.loop()
+	return fc
+}
+
+func (c *fakeSocketConn) Read(b []byte) (n int, err error) {
+	if atomic.LoadUint32(&c.closed) == 1 {
+		return 0, errFakeSockAlreadyClosed
+	}
+	return c.rd.Read(b)
+}
+
+func (c *fakeSocketConn) Write(b []byte) (n int, err error) {
+	if atomic.LoadUint32(&c.closed) == 1 {
+		return 0, errFakeSockAlreadyClosed
+	}
+	wb := make([]byte, len(b))
+	copy(wb, b)
+	c.wrCh <- wb
+	return len(wb), nil
+}
+
+func (c *fakeSocketConn) Close() error {
+	if atomic.CompareAndSwapUint32(&c.closed, 0, 1) {
+		c.wr.Close()
+		c.rd.Close()
+		close(c.closeCh)
+		return nil
+	}
+	return errFakeSockAlreadyClosed
+}
+
+func (c *fakeSocketConn) LocalAddr() net.Addr                  { return localAddr }
+func (c *fakeSocketConn) RemoteAddr() net.Addr                 { return remoteAddr }
+func (c *fakeSocketConn) SetDeadline(t time.Time) error        { return nil }
+func (c *fakeSocketConn) SetReadDeadline(t time.Time) error    { return nil }
+func (c *fakeSocketConn) SetWriteDeadline(t time.Time) error   { return nil }
+func (c *fakeSocketConn) ConnectionState() tls.ConnectionState { return tls.ConnectionState{} }
+
+func (c *fakeSocketConn) inboundWriteString(s string) (n int, err error) {
+	return c.rd.Write([]byte(s))
+}
+
+func (c *fakeSocketConn) outboundRead() xmpp.XElement {
+	var elem xmpp.XElement
+	var err error
+	p := xmpp.NewParser(c.wr, xmpp.SocketStream, 0)
+	for err == nil {
+		elem, err = p.ParseElement()
+		if elem != nil {
+			return elem
+		}
+	}
+	return &xmpp.Element{}
+}
+
+func (c *fakeSocketConn) waitClose() bool {
+	select {
+	case <-c.closeCh:
+		return true
+	case <-time.After(time.Second * 5):
+		return false // timed out
+	}
+}
+
+func (c *fakeSocketConn) loop() {
+	for {
+		select {
+		case b := <-c.wrCh:
+			c.wr.Write(b)
+		case <-c.closeCh:
+			return
+		}
+	}
+}
+
+type fakeAddr int
+
+var (
+	localAddr  = fakeAddr(1)
+	remoteAddr = fakeAddr(2)
+)
+
+func (a fakeAddr) Network() string { return "net" }
+func (a fakeAddr) String() string  { return "str" }
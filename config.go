@@ -9,6 +9,8 @@ import (
 	"bytes"
 	"io/ioutil"
 
+	"github.com/ortuman/jackal/admin"
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/c2s"
 	"github.com/ortuman/jackal/component"
 	"github.com/ortuman/jackal/host"
@@ -34,8 +36,10 @@ type TLSConfig struct {
 type Config struct {
 	PIDFile    string           `yaml:"pid_path"`
 	Debug      DebugConfig      `yaml:"debug"`
+	Admin      *admin.Config    `yaml:"admin"`
 	Logger     log.Config       `yaml:"logger"`
 	Storage    storage.Config   `yaml:"storage"`
+	Auth       auth.Config      `yaml:"auth"`
 	Hosts      []host.Config    `yaml:"hosts"`
 	Modules    module.Config    `yaml:"modules"`
 	Components component.Config `yaml:"components"`
@@ -24,6 +24,9 @@ var (
 	// ErrHostUnknown represents 'host-unknown' stream error.
 	ErrHostUnknown = newStreamError("host-unknown")
 
+	// ErrConflict represents 'conflict' stream error.
+	ErrConflict = newStreamError("conflict")
+
 	// ErrInvalidFrom represents 'invalid-from' stream error.
 	ErrInvalidFrom = newStreamError("invalid-from")
 
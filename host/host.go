@@ -7,6 +7,9 @@ package host
 
 import (
 	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
 	"log"
 	"sync"
 
@@ -28,20 +31,22 @@ func Initialize(configurations []Config) {
 	if initialized {
 		return
 	}
-	if len(configurations) > 0 {
-		for _, h := range configurations {
-			hosts[h.Name] = h.Certificate
-		}
-	} else {
-		cer, err := util.LoadCertificate("", "", defaultDomain)
-		if err != nil {
-			log.Fatalf("%v", err)
-		}
-		hosts[defaultDomain] = cer
-	}
+	hosts = loadHosts(configurations)
 	initialized = true
 }
 
+// Reload replaces current registered domains and certificates
+// with the ones contained in configurations.
+func Reload(configurations []Config) {
+	h := loadHosts(configurations)
+
+	instMu.Lock()
+	defer instMu.Unlock()
+	if initialized {
+		hosts = h
+	}
+}
+
 // Shutdown shuts down host sub system.
 func Shutdown() {
 	instMu.Lock()
@@ -71,6 +76,50 @@ func IsLocalHost(domain string) bool {
 	return ok
 }
 
+// Certificate returns the certificate configured for a local domain.
+func Certificate(domain string) (*tls.Certificate, error) {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	cer, ok := hosts[domain]
+	if !ok {
+		return nil, fmt.Errorf("host: no certificate found for domain: %s", domain)
+	}
+	return &cer, nil
+}
+
+// GetCertificate returns a tls.Config GetCertificate callback that selects the
+// certificate matching client requested server name (SNI).
+// If no server name is indicated, or it doesn't belong to any local domain,
+// fallbackDomain certificate will be used instead. Otherwise, any configured
+// certificate valid for the requested server name will be chosen.
+func GetCertificate(fallbackDomain string) func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+		domain := hello.ServerName
+		if len(domain) > 0 {
+			if cer, err := Certificate(domain); err == nil {
+				return cer, nil
+			}
+			if len(fallbackDomain) > 0 && IsLocalHost(fallbackDomain) {
+				return Certificate(fallbackDomain)
+			}
+			if cer := matchingCertificate(domain); cer != nil {
+				return cer, nil
+			}
+			return nil, fmt.Errorf("host: no certificate found for domain: %s", domain)
+		}
+		domain = fallbackDomain
+		if len(domain) == 0 {
+			// no way to choose... unless there's a single one
+			if names := HostNames(); len(names) == 1 {
+				domain = names[0]
+			} else {
+				return nil, errors.New("host: no server name indicated")
+			}
+		}
+		return Certificate(domain)
+	}
+}
+
 // Certificates returns an array of all configured domain certificates.
 func Certificates() []tls.Certificate {
 	instMu.RLock()
@@ -81,3 +130,40 @@ func Certificates() []tls.Certificate {
 	}
 	return certs
 }
+
+func loadHosts(configurations []Config) map[string]tls.Certificate {
+	h := make(map[string]tls.Certificate)
+	if len(configurations) > 0 {
+		for _, c := range configurations {
+			h[c.Name] = c.Certificate
+		}
+	} else {
+		cer, err := util.LoadCertificate("", "", defaultDomain)
+		if err != nil {
+			log.Fatalf("%v", err)
+		}
+		h[defaultDomain] = cer
+	}
+	return h
+}
+
+func matchingCertificate(domain string) *tls.Certificate {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	for _, cer := range hosts {
+		leaf := cer.Leaf
+		if leaf == nil {
+			if len(cer.Certificate) == 0 {
+				continue
+			}
+			var err error
+			if leaf, err = x509.ParseCertificate(cer.Certificate[0]); err != nil {
+				continue
+			}
+		}
+		if leaf.VerifyHostname(domain) == nil {
+			return &cer
+		}
+	}
+	return nil
+}
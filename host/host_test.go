@@ -6,8 +6,15 @@
 package host
 
 import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/ortuman/jackal/util"
 	"github.com/stretchr/testify/require"
@@ -33,3 +40,84 @@ func TestHostInitialize(t *testing.T) {
 	Initialize([]Config{{Name: "localhost", Certificate: cer}})
 	require.Equal(t, 1, len(Certificates()))
 }
+
+func TestHostReload(t *testing.T) {
+	Initialize([]Config{{Name: "jackal.im"}})
+	defer Shutdown()
+
+	Reload([]Config{{Name: "jackal.im"}, {Name: "example.org"}})
+	require.True(t, IsLocalHost("jackal.im"))
+	require.True(t, IsLocalHost("example.org"))
+	require.Equal(t, 2, len(HostNames()))
+
+	Reload([]Config{{Name: "example.org"}})
+	require.False(t, IsLocalHost("jackal.im"))
+	require.True(t, IsLocalHost("example.org"))
+}
+
+func TestHostGetCertificate(t *testing.T) {
+	privKeyFile := "../testdata/cert/test.server.key"
+	certFile := "../testdata/cert/test.server.crt"
+	cer, err := util.LoadCertificate(privKeyFile, certFile, "localhost")
+	require.Nil(t, err)
+
+	Initialize([]Config{{Name: "localhost", Certificate: cer}, {Name: "jackal.im"}})
+	defer Shutdown()
+
+	getCertificate := GetCertificate("localhost")
+
+	c, err := getCertificate(&tls.ClientHelloInfo{ServerName: "localhost"})
+	require.Nil(t, err)
+	require.Equal(t, cer.Certificate, c.Certificate)
+
+	// fallback domain
+	c, err = getCertificate(&tls.ClientHelloInfo{})
+	require.Nil(t, err)
+	require.Equal(t, cer.Certificate, c.Certificate)
+
+	// not a local domain
+	c, err = getCertificate(&tls.ClientHelloInfo{ServerName: "example.org"})
+	require.Nil(t, err)
+	require.Equal(t, cer.Certificate, c.Certificate)
+
+	c, err = GetCertificate("")(&tls.ClientHelloInfo{ServerName: "example.org"})
+	require.Nil(t, c)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "example.org")
+
+	// no server name indicated
+	c, err = GetCertificate("")(&tls.ClientHelloInfo{})
+	require.Nil(t, c)
+	require.NotNil(t, err)
+}
+
+func TestHostGetCertificateMatchingName(t *testing.T) {
+	cer := tUtilCertificate(t, "jackal.im", "*.jackal.im")
+
+	Initialize([]Config{{Name: "jackal.im", Certificate: cer}, {Name: "example.org"}})
+	defer Shutdown()
+
+	// certificate valid for a subdomain
+	c, err := GetCertificate("")(&tls.ClientHelloInfo{ServerName: "conference.jackal.im"})
+	require.Nil(t, err)
+	require.Equal(t, cer.Certificate, c.Certificate)
+
+	c, err = GetCertificate("")(&tls.ClientHelloInfo{ServerName: "jackal.org"})
+	require.Nil(t, c)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "jackal.org")
+}
+
+func tUtilCertificate(t *testing.T, dnsNames ...string) tls.Certificate {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err)
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+		DNSNames:     dnsNames,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &priv.PublicKey, priv)
+	require.Nil(t, err)
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: priv}
+}
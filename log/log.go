@@ -16,6 +16,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -34,7 +35,7 @@ var (
 
 // Logger object is used to log messages for a specific system or application component.
 type Logger struct {
-	level     LogLevel
+	level     int32
 	outWriter io.Writer
 	f         *os.File
 	recCh     chan record
@@ -44,7 +45,7 @@ type Logger struct {
 
 func newLogger(cfg *Config, outWriter io.Writer) (*Logger, error) {
 	l := &Logger{
-		level:     cfg.Level,
+		level:     int32(cfg.Level),
 		outWriter: outWriter,
 	}
 	if len(cfg.LogPath) > 0 {
@@ -79,6 +80,13 @@ func Initialize(cfg *Config) {
 	initialized = true
 }
 
+// SetLevel changes the default logger level.
+func SetLevel(level LogLevel) {
+	if inst := instance(); inst != nil {
+		atomic.StoreInt32(&inst.level, int32(level))
+	}
+}
+
 func instance() *Logger {
 	instMu.RLock()
 	defer instMu.RUnlock()
@@ -101,7 +109,7 @@ func Shutdown() {
 // Debugf logs a 'debug' message to the log file
 // and echoes it to the console.
 func Debugf(format string, args ...interface{}) {
-	if inst := instance(); inst != nil && inst.level <= DebugLevel {
+	if inst := instance(); inst != nil && inst.getLevel() <= DebugLevel {
 		ci := getCallerInfo()
 		inst.writeLog(ci.pkg, ci.filename, ci.line, format, DebugLevel, true, args...)
 	}
@@ -110,7 +118,7 @@ func Debugf(format string, args ...interface{}) {
 // Infof logs an 'info' message to the log file
 // and echoes it to the console.
 func Infof(format string, args ...interface{}) {
-	if inst := instance(); inst != nil && inst.level <= InfoLevel {
+	if inst := instance(); inst != nil && inst.getLevel() <= InfoLevel {
 		ci := getCallerInfo()
 		inst.writeLog(ci.pkg, ci.filename, ci.line, format, InfoLevel, true, args...)
 	}
@@ -119,7 +127,7 @@ func Infof(format string, args ...interface{}) {
 // Warnf logs a 'warning' message to the log file
 // and echoes it to the console.
 func Warnf(format string, args ...interface{}) {
-	if inst := instance(); inst != nil && inst.level <= WarningLevel {
+	if inst := instance(); inst != nil && inst.getLevel() <= WarningLevel {
 		ci := getCallerInfo()
 		inst.writeLog(ci.pkg, ci.filename, ci.line, format, WarningLevel, true, args...)
 	}
@@ -128,7 +136,7 @@ func Warnf(format string, args ...interface{}) {
 // Errorf logs an 'error' message to the log file
 // and echoes it to the console.
 func Errorf(format string, args ...interface{}) {
-	if inst := instance(); inst != nil && inst.level <= ErrorLevel {
+	if inst := instance(); inst != nil && inst.getLevel() <= ErrorLevel {
 		ci := getCallerInfo()
 		inst.writeLog(ci.pkg, ci.filename, ci.line, format, ErrorLevel, true, args...)
 	}
@@ -137,7 +145,7 @@ func Errorf(format string, args ...interface{}) {
 // Error logs an 'error' value to the log file
 // and echoes it to the console.
 func Error(err error) {
-	if inst := instance(); inst != nil && inst.level <= ErrorLevel {
+	if inst := instance(); inst != nil && inst.getLevel() <= ErrorLevel {
 		ci := getCallerInfo()
 		inst.writeLog(ci.pkg, ci.filename, ci.line, "%v", ErrorLevel, true, err)
 	}
@@ -168,6 +176,10 @@ type record struct {
 	continueCh chan struct{}
 }
 
+func (l *Logger) getLevel() LogLevel {
+	return LogLevel(atomic.LoadInt32(&l.level))
+}
+
 func (l *Logger) writeLog(pkg, file string, line int, format string, level LogLevel, async bool, args ...interface{}) {
 	entry := record{
 		level:      level,
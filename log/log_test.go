@@ -170,6 +170,15 @@ func TestFatalLog(t *testing.T) {
 	<-continueCh
 }
 
+func TestSetLevel(t *testing.T) {
+	Initialize(&Config{Level: ErrorLevel})
+	defer Shutdown()
+
+	require.Equal(t, ErrorLevel, instance().getLevel())
+	SetLevel(DebugLevel)
+	require.Equal(t, DebugLevel, instance().getLevel())
+}
+
 func TestLogFile(t *testing.T) {
 	logPath := "../testdata/log_file.log"
 
@@ -6,14 +6,19 @@
 package main
 
 import (
+	"bufio"
 	"flag"
 	"fmt"
 	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strconv"
+	"syscall"
 
+	"github.com/ortuman/jackal/admin"
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/c2s"
 	"github.com/ortuman/jackal/component"
 	"github.com/ortuman/jackal/host"
@@ -22,7 +27,9 @@ import (
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/s2s"
 	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/xep0227"
 	"github.com/ortuman/jackal/version"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var logoStr = []string{
@@ -35,13 +42,17 @@ var logoStr = []string{
 }
 
 const usageStr = `
-Usage: jackal [options]
+Usage: jackal [options] [command]
 
 Server Options:
     -c, --config <file>    Configuration file path
 Common Options:
     -h, --help             Show this message
     -v, --version          Show version
+Commands:
+    migrate                Apply pending storage schema migrations and exit
+    export <file>          Export user accounts into a XEP-0227 file and exit
+    import <file>          Import user accounts from a XEP-0227 file and exit
 `
 
 func main() {
@@ -74,6 +85,21 @@ func main() {
 		fmt.Fprintf(os.Stdout, "jackal version: %v\n", version.ApplicationVersion)
 		return
 	}
+	command := flag.Arg(0)
+	switch command {
+	case "", "migrate":
+		break
+	case "export", "import":
+		if len(flag.Arg(1)) == 0 {
+			fmt.Fprintf(os.Stderr, "jackal: %s: missing file argument\n", command)
+			flag.Usage()
+			return
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "jackal: unknown command: %s\n", command)
+		flag.Usage()
+		return
+	}
 	// load configuration
 	var cfg Config
 	if err := cfg.FromFile(configFile); err != nil {
@@ -83,8 +109,35 @@ func main() {
 	// initialize subsystems... (order matters)
 	log.Initialize(&cfg.Logger)
 
+	if command == "migrate" {
+		ver, err := storage.Migrate(&cfg.Storage)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "jackal: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Fprintf(os.Stdout, "jackal: storage schema is up to date (version: %d)\n", ver)
+		return
+	}
+
 	storage.Initialize(&cfg.Storage)
 
+	if command == "export" || command == "import" {
+		var err error
+		if command == "export" {
+			err = exportAccounts(flag.Arg(1), cfg.Hosts)
+		} else {
+			err = importAccounts(flag.Arg(1))
+		}
+		storage.Shutdown()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "jackal: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	auth.Initialize(&cfg.Auth)
+
 	host.Initialize(cfg.Hosts)
 
 	router.Initialize(&router.Config{GetS2SOut: s2s.GetS2SOut})
@@ -109,6 +162,12 @@ func main() {
 		go initDebugServer(cfg.Debug.Port)
 	}
 
+	// start serving admin API...
+	admin.Initialize(cfg.Admin)
+
+	// reload configuration on SIGHUP...
+	go waitForReload(configFile)
+
 	// start serving s2s...
 	s2s.Initialize(cfg.S2S)
 
@@ -116,9 +175,34 @@ func main() {
 	c2s.Initialize(cfg.C2S)
 }
 
+func waitForReload(configFile string) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	for range sigCh {
+		if err := reloadConfig(configFile); err != nil {
+			log.Errorf("couldn't reload configuration: %v", err)
+		}
+	}
+}
+
+func reloadConfig(configFile string) error {
+	var cfg Config
+	if err := cfg.FromFile(configFile); err != nil {
+		return err
+	}
+	log.SetLevel(cfg.Logger.Level)
+	host.Reload(cfg.Hosts)
+	module.Reload(&cfg.Modules)
+
+	log.Infof("configuration reloaded... (%s)", configFile)
+	return nil
+}
+
 var debugSrv *http.Server
 
 func initDebugServer(port int) {
+	http.Handle("/metrics", promhttp.Handler())
+
 	debugSrv = &http.Server{}
 	ln, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
 	if err != nil {
@@ -127,6 +211,43 @@ func initDebugServer(port int) {
 	debugSrv.Serve(ln)
 }
 
+func exportAccounts(path string, hosts []host.Config) error {
+	domain := "localhost"
+	if len(hosts) > 0 {
+		domain = hosts[0].Name
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := bufio.NewWriter(file)
+	if err := xep0227.Export(w, storage.Instance(), domain); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "jackal: user accounts exported to %s\n", path)
+	return nil
+}
+
+func importAccounts(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	count, err := xep0227.Import(bufio.NewReader(file), storage.Instance())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stdout, "jackal: %d user accounts imported from %s\n", count, path)
+	return nil
+}
+
 func createPIDFile(pidFile string) error {
 	if len(pidFile) == 0 {
 		return nil
@@ -0,0 +1,42 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package capsmodel
+
+import "encoding/gob"
+
+// Capabilities represents the set of features advertised
+// by an entity through its capabilities verification string.
+type Capabilities struct {
+	Node     string
+	Ver      string
+	Features []string
+}
+
+// HasFeature returns whether or not capabilities include a given feature.
+func (c *Capabilities) HasFeature(feature string) bool {
+	for _, f := range c.Features {
+		if f == feature {
+			return true
+		}
+	}
+	return false
+}
+
+// FromGob deserializes a Capabilities entity
+// from it's gob binary representation.
+func (c *Capabilities) FromGob(dec *gob.Decoder) {
+	dec.Decode(&c.Node)
+	dec.Decode(&c.Ver)
+	dec.Decode(&c.Features)
+}
+
+// ToGob converts a Capabilities entity
+// to it's gob binary representation.
+func (c *Capabilities) ToGob(enc *gob.Encoder) {
+	enc.Encode(&c.Node)
+	enc.Encode(&c.Ver)
+	enc.Encode(&c.Features)
+}
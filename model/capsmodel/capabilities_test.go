@@ -0,0 +1,34 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package capsmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapabilities_Serialize(t *testing.T) {
+	c1 := Capabilities{
+		Node:     "http://code.google.com/p/exodus",
+		Ver:      "QgayPKawpkPSDYmwT/WM94uAlu0=",
+		Features: []string{"http://jabber.org/protocol/caps", "http://jabber.org/protocol/disco#info"},
+	}
+	buf := new(bytes.Buffer)
+	c1.ToGob(gob.NewEncoder(buf))
+
+	var c2 Capabilities
+	c2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, c1, c2)
+}
+
+func TestCapabilities_HasFeature(t *testing.T) {
+	c := Capabilities{Features: []string{"http://jabber.org/protocol/tune+notify"}}
+	require.True(t, c.HasFeature("http://jabber.org/protocol/tune+notify"))
+	require.False(t, c.HasFeature("http://jabber.org/protocol/tune"))
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mammodel
+
+import (
+	"encoding/gob"
+	"errors"
+	"time"
+
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+// Message represents an archived message entity.
+type Message struct {
+	ID       string
+	Username string
+	With     string
+	Message  *xmpp.Message
+	Stamp    time.Time
+}
+
+// FromGob deserializes a Message entity
+// from it's gob binary representation.
+func (m *Message) FromGob(dec *gob.Decoder) {
+	dec.Decode(&m.ID)
+	dec.Decode(&m.Username)
+	dec.Decode(&m.With)
+	el := &xmpp.Element{}
+	el.FromGob(dec)
+	fromJID, _ := jid.NewWithString(el.From(), true)
+	toJID, _ := jid.NewWithString(el.To(), true)
+	m.Message, _ = xmpp.NewMessageFromElement(el, fromJID, toJID)
+	dec.Decode(&m.Stamp)
+}
+
+// ToGob converts a Message entity
+// to it's gob binary representation.
+func (m *Message) ToGob(enc *gob.Encoder) {
+	enc.Encode(&m.ID)
+	enc.Encode(&m.Username)
+	enc.Encode(&m.With)
+	m.Message.ToGob(enc)
+	enc.Encode(&m.Stamp)
+}
+
+// ErrMessageNotFound will be returned when a filter 'after' or 'before'
+// message could not be found within user's archive.
+var ErrMessageNotFound = errors.New("mammodel: archived message not found")
+
+// Filter represents an archive query filter.
+type Filter struct {
+	With  string
+	Start time.Time
+	End   time.Time
+
+	// After and Before restrict results to the messages archived
+	// right after or before the one with the given identifier.
+	After  string
+	Before string
+
+	// Offset sets the number of matching messages to be skipped,
+	// and Limit the maximum number of them to be returned (0 meaning no limit).
+	Offset int
+	Limit  int
+
+	// Backwards makes Offset and Limit be applied starting from the
+	// latest matching message. Results are always in chronological order.
+	Backwards bool
+}
+
+// IsBackwards returns whether or not the filter pages
+// backwards through the archive.
+func (f *Filter) IsBackwards() bool {
+	return f.Backwards || len(f.Before) > 0
+}
+
+// Matches returns whether or not an archived message
+// satisfies the filter criteria.
+func (f *Filter) Matches(m *Message) bool {
+	if len(f.With) > 0 && m.With != f.With {
+		return false
+	}
+	if !f.Start.IsZero() && m.Stamp.Before(f.Start) {
+		return false
+	}
+	if !f.End.IsZero() && m.Stamp.After(f.End) {
+		return false
+	}
+	return true
+}
+
+// Page returns the page of a chronologically ordered set of
+// messages satisfying the filter criteria.
+func (f *Filter) Page(msgs []Message) ([]Message, error) {
+	from, to := 0, len(msgs)
+	if len(f.After) > 0 {
+		idx := indexOfMessage(msgs, f.After)
+		if idx == -1 {
+			return nil, ErrMessageNotFound
+		}
+		from = idx + 1
+	}
+	if len(f.Before) > 0 {
+		idx := indexOfMessage(msgs, f.Before)
+		if idx == -1 {
+			return nil, ErrMessageNotFound
+		}
+		to = idx
+	}
+	var ret []Message
+	if f.IsBackwards() {
+		for i, skipped := to-1, 0; i >= from && (f.Limit == 0 || len(ret) < f.Limit); i-- {
+			if !f.Matches(&msgs[i]) {
+				continue
+			}
+			if skipped < f.Offset {
+				skipped++
+				continue
+			}
+			ret = append(ret, msgs[i])
+		}
+		for i, j := 0, len(ret)-1; i < j; i, j = i+1, j-1 {
+			ret[i], ret[j] = ret[j], ret[i]
+		}
+		return ret, nil
+	}
+	for i, skipped := from, 0; i < to && (f.Limit == 0 || len(ret) < f.Limit); i++ {
+		if !f.Matches(&msgs[i]) {
+			continue
+		}
+		if skipped < f.Offset {
+			skipped++
+			continue
+		}
+		ret = append(ret, msgs[i])
+	}
+	return ret, nil
+}
+
+func indexOfMessage(msgs []Message, id string) int {
+	for i, m := range msgs {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
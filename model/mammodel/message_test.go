@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mammodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMessage_Serialize(t *testing.T) {
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", true)
+	j2, _ := jid.NewWithString("noelia@jackal.im/garden", true)
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+
+	m1 := Message{
+		ID:       uuid.New(),
+		Username: "ortuman",
+		With:     "noelia@jackal.im",
+		Message:  msg,
+		Stamp:    time.Now().UTC(),
+	}
+	buf := new(bytes.Buffer)
+	m1.ToGob(gob.NewEncoder(buf))
+
+	var m2 Message
+	m2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, m1.ID, m2.ID)
+	require.Equal(t, m1.Username, m2.Username)
+	require.Equal(t, m1.With, m2.With)
+	require.Equal(t, m1.Message.String(), m2.Message.String())
+	require.True(t, m1.Stamp.Equal(m2.Stamp))
+}
+
+func TestFilter_Matches(t *testing.T) {
+	now := time.Now()
+	m := &Message{With: "noelia@jackal.im", Stamp: now}
+
+	require.True(t, (&Filter{}).Matches(m))
+	require.True(t, (&Filter{With: "noelia@jackal.im"}).Matches(m))
+	require.False(t, (&Filter{With: "romeo@jackal.im"}).Matches(m))
+	require.True(t, (&Filter{Start: now.Add(-time.Hour), End: now.Add(time.Hour)}).Matches(m))
+	require.False(t, (&Filter{Start: now.Add(time.Minute)}).Matches(m))
+	require.False(t, (&Filter{End: now.Add(-time.Minute)}).Matches(m))
+}
+
+func TestFilter_Page(t *testing.T) {
+	now := time.Now()
+	msgs := []Message{
+		{ID: "a1", With: "noelia@jackal.im", Stamp: now.Add(-time.Minute * 3)},
+		{ID: "a2", With: "romeo@jackal.im", Stamp: now.Add(-time.Minute * 2)},
+		{ID: "a3", With: "noelia@jackal.im", Stamp: now.Add(-time.Minute)},
+		{ID: "a4", With: "noelia@jackal.im", Stamp: now},
+	}
+	ids := func(msgs []Message) []string {
+		var ret []string
+		for _, m := range msgs {
+			ret = append(ret, m.ID)
+		}
+		return ret
+	}
+	page, err := (&Filter{}).Page(msgs)
+	require.Nil(t, err)
+	require.Equal(t, []string{"a1", "a2", "a3", "a4"}, ids(page))
+
+	page, _ = (&Filter{With: "noelia@jackal.im", Limit: 2}).Page(msgs)
+	require.Equal(t, []string{"a1", "a3"}, ids(page))
+
+	page, _ = (&Filter{Offset: 1, Limit: 2}).Page(msgs)
+	require.Equal(t, []string{"a2", "a3"}, ids(page))
+
+	page, _ = (&Filter{After: "a2"}).Page(msgs)
+	require.Equal(t, []string{"a3", "a4"}, ids(page))
+
+	page, _ = (&Filter{Backwards: true, Limit: 3}).Page(msgs)
+	require.Equal(t, []string{"a2", "a3", "a4"}, ids(page))
+
+	page, _ = (&Filter{Before: "a4", With: "noelia@jackal.im", Limit: 1}).Page(msgs)
+	require.Equal(t, []string{"a3"}, ids(page))
+
+	_, err = (&Filter{After: "a5"}).Page(msgs)
+	require.Equal(t, ErrMessageNotFound, err)
+	_, err = (&Filter{Before: "a5"}).Page(msgs)
+	require.Equal(t, ErrMessageNotFound, err)
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mammodel
+
+import "encoding/gob"
+
+const (
+	// DefaultAlways represents 'always' archiving default behavior.
+	DefaultAlways = "always"
+
+	// DefaultNever represents 'never' archiving default behavior.
+	DefaultNever = "never"
+
+	// DefaultRoster represents 'roster' archiving default behavior.
+	DefaultRoster = "roster"
+)
+
+// Prefs represents user archiving preferences.
+type Prefs struct {
+	Username string
+	Default  string
+	Always   []string
+	Never    []string
+}
+
+// FromGob deserializes a Prefs entity
+// from it's gob binary representation.
+func (p *Prefs) FromGob(dec *gob.Decoder) {
+	dec.Decode(&p.Username)
+	dec.Decode(&p.Default)
+	dec.Decode(&p.Always)
+	dec.Decode(&p.Never)
+}
+
+// ToGob converts a Prefs entity
+// to it's gob binary representation.
+func (p *Prefs) ToGob(enc *gob.Encoder) {
+	enc.Encode(&p.Username)
+	enc.Encode(&p.Default)
+	enc.Encode(&p.Always)
+	enc.Encode(&p.Never)
+}
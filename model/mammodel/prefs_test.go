@@ -0,0 +1,29 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mammodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefs_Serialize(t *testing.T) {
+	p1 := Prefs{
+		Username: "ortuman",
+		Default:  DefaultRoster,
+		Always:   []string{"noelia@jackal.im"},
+		Never:    []string{"romeo@jackal.im", "juliet@jackal.im"},
+	}
+	buf := new(bytes.Buffer)
+	p1.ToGob(gob.NewEncoder(buf))
+
+	var p2 Prefs
+	p2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, p1, p2)
+}
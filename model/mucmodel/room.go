@@ -0,0 +1,82 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"encoding/gob"
+)
+
+// room affiliation values
+const (
+	AffiliationOwner   = "owner"
+	AffiliationAdmin   = "admin"
+	AffiliationMember  = "member"
+	AffiliationOutcast = "outcast"
+	AffiliationNone    = "none"
+)
+
+// Affiliation represents a room affiliation storage entity.
+type Affiliation struct {
+	JID         string
+	Affiliation string
+}
+
+// Room represents a multi-user chat room storage entity.
+type Room struct {
+	RoomJID      string
+	Name         string
+	Description  string
+	Subject      string
+	Password     string
+	Public       bool
+	MembersOnly  bool
+	Moderated    bool
+	NonAnonymous bool
+	MaxOccupants int
+	Affiliations []Affiliation
+}
+
+// FromGob deserializes a Room entity from it's gob binary representation.
+func (r *Room) FromGob(dec *gob.Decoder) {
+	dec.Decode(&r.RoomJID)
+	dec.Decode(&r.Name)
+	dec.Decode(&r.Description)
+	dec.Decode(&r.Subject)
+	dec.Decode(&r.Password)
+	dec.Decode(&r.Public)
+	dec.Decode(&r.MembersOnly)
+	dec.Decode(&r.Moderated)
+	dec.Decode(&r.NonAnonymous)
+	dec.Decode(&r.MaxOccupants)
+	var ln int
+	dec.Decode(&ln)
+	for i := 0; i < ln; i++ {
+		var aff Affiliation
+		dec.Decode(&aff.JID)
+		dec.Decode(&aff.Affiliation)
+		r.Affiliations = append(r.Affiliations, aff)
+	}
+}
+
+// ToGob converts a Room entity to it's gob binary representation.
+func (r *Room) ToGob(enc *gob.Encoder) {
+	enc.Encode(&r.RoomJID)
+	enc.Encode(&r.Name)
+	enc.Encode(&r.Description)
+	enc.Encode(&r.Subject)
+	enc.Encode(&r.Password)
+	enc.Encode(&r.Public)
+	enc.Encode(&r.MembersOnly)
+	enc.Encode(&r.Moderated)
+	enc.Encode(&r.NonAnonymous)
+	enc.Encode(&r.MaxOccupants)
+	ln := len(r.Affiliations)
+	enc.Encode(&ln)
+	for _, aff := range r.Affiliations {
+		enc.Encode(&aff.JID)
+		enc.Encode(&aff.Affiliation)
+	}
+}
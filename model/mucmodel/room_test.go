@@ -0,0 +1,46 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package mucmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoom_Serialize(t *testing.T) {
+	r1 := Room{
+		RoomJID:      "coven@conference.jackal.im",
+		Name:         "The Coven",
+		Description:  "A place for witches",
+		Subject:      "Fire Burn and Cauldron Bubble!",
+		Password:     "cauldronburn",
+		Public:       true,
+		MembersOnly:  true,
+		Moderated:    true,
+		NonAnonymous: true,
+		MaxOccupants: 30,
+		Affiliations: []Affiliation{
+			{JID: "crone1@jackal.im", Affiliation: AffiliationOwner},
+			{JID: "wiccarocks@jackal.im", Affiliation: AffiliationMember},
+		},
+	}
+	buf := new(bytes.Buffer)
+	r1.ToGob(gob.NewEncoder(buf))
+	r2 := Room{}
+	r2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, r1, r2)
+
+	// room with no affiliations
+	r3 := Room{RoomJID: "darkcave@conference.jackal.im"}
+	buf.Reset()
+	r3.ToGob(gob.NewEncoder(buf))
+	r4 := Room{}
+	r4.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, r3, r4)
+}
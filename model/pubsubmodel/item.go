@@ -0,0 +1,47 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsubmodel
+
+import (
+	"encoding/gob"
+	"time"
+
+	"github.com/ortuman/jackal/xmpp"
+)
+
+// Item represents a published node item storage entity.
+type Item struct {
+	ID        string
+	Publisher string
+	Payload   xmpp.XElement
+	Stamp     time.Time
+}
+
+// FromGob deserializes an Item entity from it's gob binary representation.
+func (i *Item) FromGob(dec *gob.Decoder) {
+	dec.Decode(&i.ID)
+	dec.Decode(&i.Publisher)
+	var hasPayload bool
+	dec.Decode(&hasPayload)
+	if hasPayload {
+		el := &xmpp.Element{}
+		el.FromGob(dec)
+		i.Payload = el
+	}
+	dec.Decode(&i.Stamp)
+}
+
+// ToGob converts an Item entity to it's gob binary representation.
+func (i *Item) ToGob(enc *gob.Encoder) {
+	enc.Encode(&i.ID)
+	enc.Encode(&i.Publisher)
+	hasPayload := i.Payload != nil
+	enc.Encode(&hasPayload)
+	if hasPayload {
+		i.Payload.ToGob(enc)
+	}
+	enc.Encode(&i.Stamp)
+}
@@ -0,0 +1,48 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsubmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestItem_Serialize(t *testing.T) {
+	entry := xmpp.NewElementNamespace("entry", "http://www.w3.org/2005/Atom")
+	title := xmpp.NewElementName("title")
+	title.SetText("Soliloquy")
+	entry.AppendElement(title)
+
+	i1 := Item{
+		ID:        uuid.New(),
+		Publisher: "hamlet@jackal.im",
+		Payload:   entry,
+		Stamp:     time.Now().UTC(),
+	}
+	buf := new(bytes.Buffer)
+	i1.ToGob(gob.NewEncoder(buf))
+	i2 := Item{}
+	i2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, i1.ID, i2.ID)
+	require.Equal(t, i1.Publisher, i2.Publisher)
+	require.Equal(t, i1.Payload.String(), i2.Payload.String())
+	require.True(t, i1.Stamp.Equal(i2.Stamp))
+
+	// item with no payload
+	i3 := Item{ID: uuid.New(), Publisher: "hamlet@jackal.im"}
+	buf.Reset()
+	i3.ToGob(gob.NewEncoder(buf))
+	i4 := Item{}
+	i4.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, i3.ID, i4.ID)
+	require.Nil(t, i4.Payload)
+}
@@ -0,0 +1,161 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsubmodel
+
+import (
+	"encoding/gob"
+)
+
+// node access model values
+const (
+	AccessModelOpen      = "open"
+	AccessModelPresence  = "presence"
+	AccessModelWhitelist = "whitelist"
+)
+
+// node affiliation values
+const (
+	AffiliationOwner     = "owner"
+	AffiliationPublisher = "publisher"
+	AffiliationMember    = "member"
+	AffiliationOutcast   = "outcast"
+	AffiliationNone      = "none"
+)
+
+// SubscriptionSubscribed represents a 'subscribed' subscription state.
+const SubscriptionSubscribed = "subscribed"
+
+// Options represents a node configuration.
+type Options struct {
+	Title           string
+	AccessModel     string
+	MaxItems        int
+	PersistItems    bool
+	DeliverPayloads bool
+	NotifyRetract   bool
+	NotifyDelete    bool
+}
+
+// Affiliation represents a node affiliation storage entity.
+type Affiliation struct {
+	JID         string
+	Affiliation string
+}
+
+// Subscription represents a node subscription storage entity.
+type Subscription struct {
+	SubID        string
+	JID          string
+	Subscription string
+}
+
+// Node represents a publish-subscribe node storage entity.
+type Node struct {
+	Host          string
+	Name          string
+	Options       Options
+	Affiliations  []Affiliation
+	Subscriptions []Subscription
+}
+
+// FromGob deserializes a Node entity from it's gob binary representation.
+func (n *Node) FromGob(dec *gob.Decoder) {
+	dec.Decode(&n.Host)
+	dec.Decode(&n.Name)
+	dec.Decode(&n.Options.Title)
+	dec.Decode(&n.Options.AccessModel)
+	dec.Decode(&n.Options.MaxItems)
+	dec.Decode(&n.Options.PersistItems)
+	dec.Decode(&n.Options.DeliverPayloads)
+	dec.Decode(&n.Options.NotifyRetract)
+	dec.Decode(&n.Options.NotifyDelete)
+	var ln int
+	dec.Decode(&ln)
+	for i := 0; i < ln; i++ {
+		var aff Affiliation
+		dec.Decode(&aff.JID)
+		dec.Decode(&aff.Affiliation)
+		n.Affiliations = append(n.Affiliations, aff)
+	}
+	dec.Decode(&ln)
+	for i := 0; i < ln; i++ {
+		var sub Subscription
+		dec.Decode(&sub.SubID)
+		dec.Decode(&sub.JID)
+		dec.Decode(&sub.Subscription)
+		n.Subscriptions = append(n.Subscriptions, sub)
+	}
+}
+
+// ToGob converts a Node entity to it's gob binary representation.
+func (n *Node) ToGob(enc *gob.Encoder) {
+	enc.Encode(&n.Host)
+	enc.Encode(&n.Name)
+	enc.Encode(&n.Options.Title)
+	enc.Encode(&n.Options.AccessModel)
+	enc.Encode(&n.Options.MaxItems)
+	enc.Encode(&n.Options.PersistItems)
+	enc.Encode(&n.Options.DeliverPayloads)
+	enc.Encode(&n.Options.NotifyRetract)
+	enc.Encode(&n.Options.NotifyDelete)
+	ln := len(n.Affiliations)
+	enc.Encode(&ln)
+	for _, aff := range n.Affiliations {
+		enc.Encode(&aff.JID)
+		enc.Encode(&aff.Affiliation)
+	}
+	ln = len(n.Subscriptions)
+	enc.Encode(&ln)
+	for _, sub := range n.Subscriptions {
+		enc.Encode(&sub.SubID)
+		enc.Encode(&sub.JID)
+		enc.Encode(&sub.Subscription)
+	}
+}
+
+// Affiliation returns the affiliation associated to a bare JID.
+func (n *Node) Affiliation(jid string) string {
+	for _, aff := range n.Affiliations {
+		if aff.JID == jid {
+			return aff.Affiliation
+		}
+	}
+	return AffiliationNone
+}
+
+// SetAffiliation sets the affiliation associated to a bare JID,
+// removing it in case affiliation value is 'none'.
+func (n *Node) SetAffiliation(jid, affiliation string) {
+	for i, aff := range n.Affiliations {
+		if aff.JID == jid {
+			n.Affiliations = append(n.Affiliations[:i], n.Affiliations[i+1:]...)
+			break
+		}
+	}
+	if affiliation != AffiliationNone {
+		n.Affiliations = append(n.Affiliations, Affiliation{JID: jid, Affiliation: affiliation})
+	}
+}
+
+// Subscription returns the subscription associated to a JID.
+func (n *Node) Subscription(jid string) *Subscription {
+	for i := range n.Subscriptions {
+		if n.Subscriptions[i].JID == jid {
+			return &n.Subscriptions[i]
+		}
+	}
+	return nil
+}
+
+// DeleteSubscription removes the subscription associated to a JID.
+func (n *Node) DeleteSubscription(jid string) {
+	for i, sub := range n.Subscriptions {
+		if sub.JID == jid {
+			n.Subscriptions = append(n.Subscriptions[:i], n.Subscriptions[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,73 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package pubsubmodel
+
+import (
+	"bytes"
+	"encoding/gob"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNode_Serialize(t *testing.T) {
+	n1 := Node{
+		Host: "pubsub.jackal.im",
+		Name: "princely_musings",
+		Options: Options{
+			Title:           "Princely Musings (Atom)",
+			AccessModel:     AccessModelWhitelist,
+			MaxItems:        10,
+			PersistItems:    true,
+			DeliverPayloads: true,
+			NotifyRetract:   true,
+			NotifyDelete:    true,
+		},
+		Affiliations: []Affiliation{
+			{JID: "hamlet@jackal.im", Affiliation: AffiliationOwner},
+			{JID: "horatio@jackal.im", Affiliation: AffiliationMember},
+		},
+		Subscriptions: []Subscription{
+			{SubID: "ba49252aaa4f5d320c24d3766f0bdcade78c78d3", JID: "horatio@jackal.im", Subscription: SubscriptionSubscribed},
+		},
+	}
+	buf := new(bytes.Buffer)
+	n1.ToGob(gob.NewEncoder(buf))
+	n2 := Node{}
+	n2.FromGob(gob.NewDecoder(buf))
+	require.Equal(t, n1, n2)
+}
+
+func TestNode_Affiliations(t *testing.T) {
+	n := Node{}
+	require.Equal(t, AffiliationNone, n.Affiliation("hamlet@jackal.im"))
+
+	n.SetAffiliation("hamlet@jackal.im", AffiliationOwner)
+	n.SetAffiliation("horatio@jackal.im", AffiliationMember)
+	require.Equal(t, AffiliationOwner, n.Affiliation("hamlet@jackal.im"))
+	require.Equal(t, AffiliationMember, n.Affiliation("horatio@jackal.im"))
+
+	n.SetAffiliation("horatio@jackal.im", AffiliationPublisher)
+	require.Equal(t, AffiliationPublisher, n.Affiliation("horatio@jackal.im"))
+	require.Equal(t, 2, len(n.Affiliations))
+
+	n.SetAffiliation("horatio@jackal.im", AffiliationNone)
+	require.Equal(t, AffiliationNone, n.Affiliation("horatio@jackal.im"))
+	require.Equal(t, 1, len(n.Affiliations))
+}
+
+func TestNode_Subscriptions(t *testing.T) {
+	n := Node{}
+	require.Nil(t, n.Subscription("horatio@jackal.im"))
+
+	n.Subscriptions = append(n.Subscriptions, Subscription{SubID: "1", JID: "horatio@jackal.im", Subscription: SubscriptionSubscribed})
+	sub := n.Subscription("horatio@jackal.im")
+	require.NotNil(t, sub)
+	require.Equal(t, "1", sub.SubID)
+
+	n.DeleteSubscription("horatio@jackal.im")
+	require.Nil(t, n.Subscription("horatio@jackal.im"))
+}
@@ -12,12 +12,36 @@ import (
 	"github.com/ortuman/jackal/xmpp"
 )
 
+// Credential represents a salted SCRAM (RFC 5802) credential
+// derived from a user password for a given hash algorithm.
+type Credential struct {
+	Hash       string
+	Salt       []byte
+	Iterations int
+	StoredKey  []byte
+	ServerKey  []byte
+}
+
 // User represents a user storage entity.
 type User struct {
-	Username       string
+	Username string
+
+	// Password is only kept for legacy plaintext accounts,
+	// and it's cleared as soon as the user credentials get upgraded.
 	Password       string
 	LastPresence   *xmpp.Presence
 	LastPresenceAt time.Time
+	Credentials    []Credential
+}
+
+// Credential returns user credential associated to a given hash algorithm.
+func (u *User) Credential(hash string) *Credential {
+	for i := 0; i < len(u.Credentials); i++ {
+		if u.Credentials[i].Hash == hash {
+			return &u.Credentials[i]
+		}
+	}
+	return nil
 }
 
 // FromGob deserializes a User entity from it's gob binary representation.
@@ -32,6 +56,17 @@ func (u *User) FromGob(dec *gob.Decoder) {
 		u.LastPresence = p
 		dec.Decode(&u.LastPresenceAt)
 	}
+	var credentialsCount int
+	dec.Decode(&credentialsCount)
+	for i := 0; i < credentialsCount; i++ {
+		var c Credential
+		dec.Decode(&c.Hash)
+		dec.Decode(&c.Salt)
+		dec.Decode(&c.Iterations)
+		dec.Decode(&c.StoredKey)
+		dec.Decode(&c.ServerKey)
+		u.Credentials = append(u.Credentials, c)
+	}
 }
 
 // ToGob converts a User entity to it's gob binary representation.
@@ -45,4 +80,13 @@ func (u *User) ToGob(enc *gob.Encoder) {
 		u.LastPresenceAt = time.Now()
 		enc.Encode(&u.LastPresenceAt)
 	}
+	credentialsCount := len(u.Credentials)
+	enc.Encode(&credentialsCount)
+	for _, c := range u.Credentials {
+		enc.Encode(&c.Hash)
+		enc.Encode(&c.Salt)
+		enc.Encode(&c.Iterations)
+		enc.Encode(&c.StoredKey)
+		enc.Encode(&c.ServerKey)
+	}
 }
@@ -25,6 +25,9 @@ func TestModelUser(t *testing.T) {
 	usr1.Username = "ortuman"
 	usr1.Password = "1234"
 	usr1.LastPresence = xmpp.NewPresence(j1, j2, xmpp.AvailableType)
+	usr1.Credentials = []Credential{
+		{Hash: "sha-1", Salt: []byte{1, 2}, Iterations: 4096, StoredKey: []byte{3, 4}, ServerKey: []byte{5, 6}},
+	}
 
 	buf := new(bytes.Buffer)
 	usr1.ToGob(gob.NewEncoder(buf))
@@ -34,4 +37,8 @@ func TestModelUser(t *testing.T) {
 	require.Equal(t, usr1.Password, usr2.Password)
 	require.Equal(t, usr1.LastPresence.String(), usr2.LastPresence.String())
 	require.NotEqual(t, time.Time{}, usr2.LastPresenceAt)
+	require.Equal(t, usr1.Credentials, usr2.Credentials)
+
+	require.NotNil(t, usr2.Credential("sha-1"))
+	require.Nil(t, usr2.Credential("sha-256"))
 }
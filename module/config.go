@@ -13,6 +13,7 @@ import (
 	"github.com/ortuman/jackal/module/xep0077"
 	"github.com/ortuman/jackal/module/xep0092"
 	"github.com/ortuman/jackal/module/xep0199"
+	"github.com/ortuman/jackal/module/xep0313"
 )
 
 // Config represents C2S modules configuration.
@@ -23,6 +24,7 @@ type Config struct {
 	Registration xep0077.Config
 	Version      xep0092.Config
 	Ping         xep0199.Config
+	Mam          xep0313.Config
 }
 
 type configProxy struct {
@@ -32,6 +34,7 @@ type configProxy struct {
 	Registration xep0077.Config `yaml:"mod_registration"`
 	Version      xep0092.Config `yaml:"mod_version"`
 	Ping         xep0199.Config `yaml:"mod_ping"`
+	Mam          xep0313.Config `yaml:"mod_mam"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -45,7 +48,7 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	for _, mod := range p.Enabled {
 		switch mod {
 		case "roster", "last_activity", "private", "vcard", "registration", "version", "blocking_command",
-			"ping", "offline":
+			"ping", "offline", "caps", "pep", "carbons", "mam":
 			break
 		default:
 			return fmt.Errorf("module.Config: unrecognized module: %s", mod)
@@ -58,5 +61,6 @@ func (cfg *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 	cfg.Registration = p.Registration
 	cfg.Version = p.Version
 	cfg.Ping = p.Ping
+	cfg.Mam = p.Mam
 	return nil
 }
@@ -0,0 +1,56 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package module
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type mailbox interface {
+	MailboxSize() int
+}
+
+var mailboxDepthDesc = prometheus.NewDesc(
+	"jackal_module_mailbox_depth",
+	"Number of requests waiting in module actor mailbox, by module.",
+	[]string{"module"}, nil,
+)
+
+// mailboxCollector reports current mailbox depth
+// of every active module at collection time.
+type mailboxCollector struct{}
+
+func init() {
+	prometheus.MustRegister(mailboxCollector{})
+}
+
+func (mailboxCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- mailboxDepthDesc
+}
+
+func (mailboxCollector) Collect(ch chan<- prometheus.Metric) {
+	instMu.RLock()
+	defer instMu.RUnlock()
+	for _, mod := range mods.all {
+		mb, ok := mod.(mailbox)
+		if !ok {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(mailboxDepthDesc, prometheus.GaugeValue, float64(mb.MailboxSize()), moduleName(mod))
+	}
+}
+
+// moduleName returns module package name (i.e. '*xep0030.DiscoInfo' -> 'xep0030')
+func moduleName(mod Module) string {
+	name := strings.TrimPrefix(fmt.Sprintf("%T", mod), "*")
+	if i := strings.Index(name, "."); i != -1 {
+		return name[:i]
+	}
+	return name
+}
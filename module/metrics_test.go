@@ -0,0 +1,34 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package module
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_MailboxMetrics(t *testing.T) {
+	Initialize(&Config{Enabled: map[string]struct{}{"roster": {}, "ping": {}}})
+	defer Shutdown()
+
+	ch := make(chan prometheus.Metric, 16)
+	mailboxCollector{}.Collect(ch)
+	close(ch)
+
+	depths := map[string]float64{}
+	for metric := range ch {
+		m := &dto.Metric{}
+		require.Nil(t, metric.Write(m))
+		depths[m.GetLabel()[0].GetValue()] = m.GetGauge().GetValue()
+	}
+	require.Equal(t, 3, len(depths))
+	require.Contains(t, depths, "xep0030")
+	require.Contains(t, depths, "roster")
+	require.Contains(t, depths, "xep0199")
+}
@@ -16,8 +16,12 @@ import (
 	"github.com/ortuman/jackal/module/xep0054"
 	"github.com/ortuman/jackal/module/xep0077"
 	"github.com/ortuman/jackal/module/xep0092"
+	"github.com/ortuman/jackal/module/xep0115"
+	"github.com/ortuman/jackal/module/xep0163"
 	"github.com/ortuman/jackal/module/xep0191"
 	"github.com/ortuman/jackal/module/xep0199"
+	"github.com/ortuman/jackal/module/xep0280"
+	"github.com/ortuman/jackal/module/xep0313"
 	"github.com/ortuman/jackal/stream"
 	"github.com/ortuman/jackal/xmpp"
 )
@@ -49,17 +53,33 @@ type Mods struct {
 	VCard        *xep0054.VCard
 	Register     *xep0077.Register
 	Version      *xep0092.Version
+	Caps         *xep0115.EntityCaps
+	Pep          *xep0163.Pep
 	BlockingCmd  *xep0191.BlockingCommand
 	Ping         *xep0199.Ping
+	Carbons      *xep0280.Carbons
+	Mam          *xep0313.Mam
 
 	iqHandlers []IQHandler
 	all        []Module
 }
 
+// featuresUnregisterer represents a module that announces
+// its features through the disco info module.
+type featuresUnregisterer interface {
+	UnregisterFeatures()
+}
+
+// handlersUnregisterer represents a module that installs
+// handlers on other modules.
+type handlersUnregisterer interface {
+	UnregisterHandlers()
+}
+
 var (
 	instMu      sync.RWMutex
 	mods        Mods
-	shutdownCh  chan struct{}
+	shutdownChs map[Module]chan struct{}
 	initialized bool
 )
 
@@ -70,10 +90,43 @@ func Initialize(cfg *Config) {
 	if initialized {
 		return
 	}
-	initializeModules(cfg)
+	shutdownChs = make(map[Module]chan struct{})
+	mods = loadModules(cfg, Mods{})
 	initialized = true
 }
 
+// Reload applies a new modules configuration, starting newly enabled modules
+// and stopping disabled ones. Modules that remain enabled keep their
+// state and are reconfigured in place.
+func Reload(cfg *Config) {
+	instMu.Lock()
+	defer instMu.Unlock()
+	if !initialized {
+		return
+	}
+	prev := mods
+	mods = loadModules(cfg, prev)
+
+	// stop no longer active modules
+	for _, mod := range prev.all {
+		if mods.isActive(mod) {
+			continue
+		}
+		// a replacing instance keeps announcing module features
+		if u, ok := mod.(featuresUnregisterer); ok && !mods.isReplaced(mod) {
+			u.UnregisterFeatures()
+		}
+		// surviving modules must not invoke stopped module handlers
+		if u, ok := mod.(handlersUnregisterer); ok {
+			u.UnregisterHandlers()
+		}
+		if shutdownCh := shutdownChs[mod]; shutdownCh != nil {
+			close(shutdownCh)
+			delete(shutdownChs, mod)
+		}
+	}
+}
+
 // Shutdown shuts down module sub system stopping every active module.
 // This method should be used only for testing purposes.
 func Shutdown() {
@@ -82,20 +135,25 @@ func Shutdown() {
 	if !initialized {
 		return
 	}
-	close(shutdownCh)
+	for _, shutdownCh := range shutdownChs {
+		close(shutdownCh)
+	}
+	shutdownChs = nil
 	mods = Mods{}
 	initialized = false
 }
 
 // Modules returns current active modules.
 func Modules() Mods {
+	instMu.RLock()
+	defer instMu.RUnlock()
 	return mods
 }
 
 // ProcessIQ process a module IQ returning 'service unavailable'
 // in case it can't be properly handled.
 func ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
-	for _, handler := range mods.iqHandlers {
+	for _, handler := range Modules().iqHandlers {
 		if !handler.MatchesIQ(iq) {
 			continue
 		}
@@ -109,73 +167,188 @@ func ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
 	}
 }
 
-func initializeModules(cfg *Config) {
-	shutdownCh = make(chan struct{})
+func (m *Mods) register(mod Module) {
+	if iqHandler, ok := mod.(IQHandler); ok {
+		m.iqHandlers = append(m.iqHandlers, iqHandler)
+	}
+	m.all = append(m.all, mod)
+}
+
+func (m *Mods) isActive(mod Module) bool {
+	for _, activeMod := range m.all {
+		if activeMod == mod {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Mods) isReplaced(mod Module) bool {
+	for _, activeMod := range m.all {
+		if moduleName(activeMod) == moduleName(mod) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadModules(cfg *Config, prev Mods) Mods {
+	var m Mods
 
 	// XEP-0030: Service Discovery (https://xmpp.org/extensions/xep-0030.html)
-	mods.DiscoInfo = xep0030.New(shutdownCh)
-	mods.iqHandlers = append(mods.iqHandlers, mods.DiscoInfo)
-	mods.all = append(mods.all, mods.DiscoInfo)
+	if m.DiscoInfo = prev.DiscoInfo; m.DiscoInfo == nil {
+		shutdownCh := make(chan struct{})
+		m.DiscoInfo = xep0030.New(shutdownCh)
+		shutdownChs[m.DiscoInfo] = shutdownCh
+	}
+	m.register(m.DiscoInfo)
 
 	// Roster (https://xmpp.org/rfcs/rfc3921.html#roster)
 	if _, ok := cfg.Enabled["roster"]; ok {
-		mods.Roster = roster.New(&cfg.Roster, shutdownCh)
-		mods.iqHandlers = append(mods.iqHandlers, mods.Roster)
-		mods.all = append(mods.all, mods.Roster)
+		if m.Roster = prev.Roster; m.Roster != nil {
+			m.Roster.SetConfig(&cfg.Roster)
+		} else {
+			shutdownCh := make(chan struct{})
+			m.Roster = roster.New(&cfg.Roster, shutdownCh)
+			shutdownChs[m.Roster] = shutdownCh
+		}
+		m.register(m.Roster)
 	}
 
 	// XEP-0012: Last Activity (https://xmpp.org/extensions/xep-0012.html)
 	if _, ok := cfg.Enabled["last_activity"]; ok {
-		mods.LastActivity = xep0012.New(mods.DiscoInfo, shutdownCh)
-		mods.iqHandlers = append(mods.iqHandlers, mods.LastActivity)
-		mods.all = append(mods.all, mods.LastActivity)
+		if m.LastActivity = prev.LastActivity; m.LastActivity == nil {
+			shutdownCh := make(chan struct{})
+			m.LastActivity = xep0012.New(m.DiscoInfo, shutdownCh)
+			shutdownChs[m.LastActivity] = shutdownCh
+		}
+		m.register(m.LastActivity)
 	}
 
 	// XEP-0049: Private XML Storage (https://xmpp.org/extensions/xep-0049.html)
 	if _, ok := cfg.Enabled["private"]; ok {
-		mods.Private = xep0049.New(shutdownCh)
-		mods.iqHandlers = append(mods.iqHandlers, mods.Private)
-		mods.all = append(mods.all, mods.Private)
+		if m.Private = prev.Private; m.Private == nil {
+			shutdownCh := make(chan struct{})
+			m.Private = xep0049.New(shutdownCh)
+			shutdownChs[m.Private] = shutdownCh
+		}
+		m.register(m.Private)
 	}
 
 	// XEP-0054: vcard-temp (https://xmpp.org/extensions/xep-0054.html)
 	if _, ok := cfg.Enabled["vcard"]; ok {
-		mods.VCard = xep0054.New(mods.DiscoInfo, shutdownCh)
-		mods.iqHandlers = append(mods.iqHandlers, mods.VCard)
-		mods.all = append(mods.all, mods.VCard)
+		if m.VCard = prev.VCard; m.VCard == nil {
+			shutdownCh := make(chan struct{})
+			m.VCard = xep0054.New(m.DiscoInfo, shutdownCh)
+			shutdownChs[m.VCard] = shutdownCh
+		}
+		m.register(m.VCard)
 	}
 
 	// XEP-0077: In-band registration (https://xmpp.org/extensions/xep-0077.html)
 	if _, ok := cfg.Enabled["registration"]; ok {
-		mods.Register = xep0077.New(&cfg.Registration, mods.DiscoInfo, shutdownCh)
-		mods.iqHandlers = append(mods.iqHandlers, mods.Register)
-		mods.all = append(mods.all, mods.Register)
+		if m.Register = prev.Register; m.Register != nil {
+			m.Register.SetConfig(&cfg.Registration)
+		} else {
+			shutdownCh := make(chan struct{})
+			m.Register = xep0077.New(&cfg.Registration, m.DiscoInfo, shutdownCh)
+			shutdownChs[m.Register] = shutdownCh
+		}
+		m.register(m.Register)
 	}
 
 	// XEP-0092: Software Version (https://xmpp.org/extensions/xep-0092.html)
 	if _, ok := cfg.Enabled["version"]; ok {
-		mods.Version = xep0092.New(&cfg.Version, mods.DiscoInfo, shutdownCh)
-		mods.iqHandlers = append(mods.iqHandlers, mods.Version)
-		mods.all = append(mods.all, mods.Version)
+		if m.Version = prev.Version; m.Version != nil {
+			m.Version.SetConfig(&cfg.Version)
+		} else {
+			shutdownCh := make(chan struct{})
+			m.Version = xep0092.New(&cfg.Version, m.DiscoInfo, shutdownCh)
+			shutdownChs[m.Version] = shutdownCh
+		}
+		m.register(m.Version)
+	}
+
+	// XEP-0115: Entity Capabilities (https://xmpp.org/extensions/xep-0115.html)
+	_, capsEnabled := cfg.Enabled["caps"]
+	_, pepEnabled := cfg.Enabled["pep"]
+	if capsEnabled || pepEnabled { // PEP notifications filtering relies on entity capabilities
+		if m.Caps = prev.Caps; m.Caps == nil {
+			shutdownCh := make(chan struct{})
+			m.Caps = xep0115.New(shutdownCh)
+			shutdownChs[m.Caps] = shutdownCh
+		}
+		m.register(m.Caps)
 	}
 
 	// XEP-0160: Offline message storage (https://xmpp.org/extensions/xep-0160.html)
 	if _, ok := cfg.Enabled["offline"]; ok {
-		mods.Offline = offline.New(&cfg.Offline, mods.DiscoInfo, shutdownCh)
-		mods.all = append(mods.all, mods.Offline)
+		if m.Offline = prev.Offline; m.Offline != nil {
+			m.Offline.SetConfig(&cfg.Offline)
+		} else {
+			shutdownCh := make(chan struct{})
+			m.Offline = offline.New(&cfg.Offline, m.DiscoInfo, shutdownCh)
+			shutdownChs[m.Offline] = shutdownCh
+		}
+		m.register(m.Offline)
+	}
+
+	// XEP-0163: Personal Eventing Protocol (https://xmpp.org/extensions/xep-0163.html)
+	if _, ok := cfg.Enabled["pep"]; ok {
+		if prev.Pep != nil && m.Roster == prev.Roster && m.Caps == prev.Caps {
+			m.Pep = prev.Pep
+		} else {
+			shutdownCh := make(chan struct{})
+			m.Pep = xep0163.New(m.DiscoInfo, m.Roster, m.Caps, shutdownCh)
+			shutdownChs[m.Pep] = shutdownCh
+		}
+		m.register(m.Pep)
 	}
 
 	// XEP-0191: Blocking Command (https://xmpp.org/extensions/xep-0191.html)
 	if _, ok := cfg.Enabled["blocking_command"]; ok {
-		mods.BlockingCmd = xep0191.New(mods.DiscoInfo, mods.Roster, shutdownCh)
-		mods.iqHandlers = append(mods.iqHandlers, mods.BlockingCmd)
-		mods.all = append(mods.all, mods.BlockingCmd)
+		if prev.BlockingCmd != nil && m.Roster == prev.Roster {
+			m.BlockingCmd = prev.BlockingCmd
+		} else {
+			shutdownCh := make(chan struct{})
+			m.BlockingCmd = xep0191.New(m.DiscoInfo, m.Roster, shutdownCh)
+			shutdownChs[m.BlockingCmd] = shutdownCh
+		}
+		m.register(m.BlockingCmd)
 	}
 
 	// XEP-0199: XMPP Ping (https://xmpp.org/extensions/xep-0199.html)
 	if _, ok := cfg.Enabled["ping"]; ok {
-		mods.Ping = xep0199.New(&cfg.Ping, mods.DiscoInfo, shutdownCh)
-		mods.iqHandlers = append(mods.iqHandlers, mods.Ping)
-		mods.all = append(mods.all, mods.Ping)
+		if m.Ping = prev.Ping; m.Ping != nil {
+			m.Ping.SetConfig(&cfg.Ping)
+		} else {
+			shutdownCh := make(chan struct{})
+			m.Ping = xep0199.New(&cfg.Ping, m.DiscoInfo, shutdownCh)
+			shutdownChs[m.Ping] = shutdownCh
+		}
+		m.register(m.Ping)
+	}
+
+	// XEP-0280: Message Carbons (https://xmpp.org/extensions/xep-0280.html)
+	if _, ok := cfg.Enabled["carbons"]; ok {
+		if m.Carbons = prev.Carbons; m.Carbons == nil {
+			shutdownCh := make(chan struct{})
+			m.Carbons = xep0280.New(m.DiscoInfo, shutdownCh)
+			shutdownChs[m.Carbons] = shutdownCh
+		}
+		m.register(m.Carbons)
+	}
+
+	// XEP-0313: Message Archive Management (https://xmpp.org/extensions/xep-0313.html)
+	if _, ok := cfg.Enabled["mam"]; ok {
+		if m.Mam = prev.Mam; m.Mam != nil {
+			m.Mam.SetConfig(&cfg.Mam)
+		} else {
+			shutdownCh := make(chan struct{})
+			m.Mam = xep0313.New(&cfg.Mam, m.DiscoInfo, shutdownCh)
+			shutdownChs[m.Mam] = shutdownCh
+		}
+		m.register(m.Mam)
 	}
+	return m
 }
@@ -0,0 +1,64 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package module
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/module/offline"
+	"github.com/stretchr/testify/require"
+)
+
+func TestModule_Reload(t *testing.T) {
+	Initialize(&Config{
+		Enabled: map[string]struct{}{"roster": {}, "offline": {}, "blocking_command": {}},
+		Offline: offline.Config{QueueSize: 10},
+	})
+	defer Shutdown()
+
+	mods := Modules()
+	require.NotNil(t, mods.Roster)
+	require.NotNil(t, mods.Offline)
+	require.NotNil(t, mods.BlockingCmd)
+	require.Nil(t, mods.Pep)
+
+	Reload(&Config{
+		Enabled: map[string]struct{}{"offline": {}, "blocking_command": {}, "pep": {}},
+		Offline: offline.Config{QueueSize: 20},
+	})
+	reloaded := Modules()
+
+	// preserved modules
+	require.Equal(t, mods.DiscoInfo, reloaded.DiscoInfo)
+	require.Equal(t, mods.Offline, reloaded.Offline)
+
+	// disabled modules
+	require.Nil(t, reloaded.Roster)
+
+	// roster dependant modules are recreated
+	require.NotNil(t, reloaded.BlockingCmd)
+	require.True(t, mods.BlockingCmd != reloaded.BlockingCmd)
+
+	// enabled modules
+	require.NotNil(t, reloaded.Pep)
+	require.NotNil(t, reloaded.Caps)
+
+	require.Equal(t, 5, len(reloaded.all))
+	require.Equal(t, 5, len(shutdownChs))
+
+	Reload(&Config{
+		Enabled: map[string]struct{}{"roster": {}, "pep": {}},
+	})
+	rereloaded := Modules()
+
+	// roster dependant PEP is recreated over preserved capabilities module
+	require.Equal(t, reloaded.Caps, rereloaded.Caps)
+	require.NotNil(t, rereloaded.Pep)
+	require.True(t, reloaded.Pep != rereloaded.Pep)
+
+	require.Equal(t, 4, len(rereloaded.all))
+	require.Equal(t, 4, len(shutdownChs))
+}
@@ -28,6 +28,7 @@ type Config struct {
 // Offline represents an offline server stream module.
 type Offline struct {
 	cfg        *Config
+	disco      *xep0030.DiscoInfo
 	actorCh    chan func()
 	shutdownCh <-chan struct{}
 }
@@ -36,6 +37,7 @@ type Offline struct {
 func New(config *Config, disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *Offline {
 	r := &Offline{
 		cfg:        config,
+		disco:      disco,
 		actorCh:    make(chan func(), mailboxSize),
 		shutdownCh: shutdownCh,
 	}
@@ -57,6 +59,26 @@ func (o *Offline) DeliverOfflineMessages(stm stream.C2S) {
 	o.actorCh <- func() { o.deliverOfflineMessages(stm) }
 }
 
+// SetConfig replaces module configuration, applying it
+// to every request processed from now on.
+func (o *Offline) SetConfig(cfg *Config) {
+	o.actorCh <- func() { o.cfg = cfg }
+}
+
+// UnregisterFeatures unregisters every feature announced by the module
+// through the disco info module.
+func (o *Offline) UnregisterFeatures() {
+	if o.disco != nil {
+		o.disco.UnregisterServerFeature(offlineNamespace)
+	}
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (o *Offline) MailboxSize() int {
+	return len(o.actorCh)
+}
+
 // runs on it's own goroutine
 func (o *Offline) loop() {
 	for {
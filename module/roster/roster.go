@@ -91,6 +91,42 @@ func (r *Roster) OnlinePresencesMatchingJID(j *jid.JID) []*xmpp.Presence {
 	return ret
 }
 
+// SetItem inserts or updates a user roster item on behalf of the server,
+// pushing it to every user's resource that requested the roster.
+func (r *Roster) SetItem(ri *rostermodel.Item) error {
+	errCh := make(chan error, 1)
+	r.actorCh <- func() {
+		errCh <- r.insertItem(ri)
+	}
+	return <-errCh
+}
+
+// RemoveItem deletes a user roster item on behalf of the server,
+// pushing its removal to every user's resource that requested the roster.
+func (r *Roster) RemoveItem(username, contactJID string) error {
+	errCh := make(chan error, 1)
+	r.actorCh <- func() {
+		errCh <- r.deleteItem(&rostermodel.Item{
+			Username:     username,
+			JID:          contactJID,
+			Subscription: rostermodel.SubscriptionRemove,
+		})
+	}
+	return <-errCh
+}
+
+// SetConfig replaces module configuration, applying it
+// to every request processed from now on.
+func (r *Roster) SetConfig(cfg *Config) {
+	r.actorCh <- func() { r.cfg = cfg }
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (r *Roster) MailboxSize() int {
+	return len(r.actorCh)
+}
+
 // runs on it's own goroutine
 func (r *Roster) loop() {
 	for {
@@ -216,7 +252,7 @@ func (r *Roster) updateItem(ri *rostermodel.Item, stm stream.C2S) error {
 			Ask:          ri.Ask,
 		}
 	}
-	return r.insertItem(usrRi, userJID)
+	return r.insertItem(usrRi)
 }
 
 func (r *Roster) removeItem(ri *rostermodel.Item, stm stream.C2S) error {
@@ -250,7 +286,7 @@ func (r *Roster) removeItem(ri *rostermodel.Item, stm stream.C2S) error {
 		if err != nil {
 			return err
 		}
-		if err := r.deleteItem(usrRi, userJID); err != nil {
+		if err := r.deleteItem(usrRi); err != nil {
 			return err
 		}
 	}
@@ -266,14 +302,14 @@ func (r *Roster) removeItem(ri *rostermodel.Item, stm stream.C2S) error {
 			switch cntRi.Subscription {
 			case rostermodel.SubscriptionBoth:
 				cntRi.Subscription = rostermodel.SubscriptionTo
-				if r.insertItem(cntRi, contactJID); err != nil {
+				if r.insertItem(cntRi); err != nil {
 					return err
 				}
 				fallthrough
 
 			default:
 				cntRi.Subscription = rostermodel.SubscriptionNone
-				if r.insertItem(cntRi, contactJID); err != nil {
+				if r.insertItem(cntRi); err != nil {
 					return err
 				}
 			}
@@ -341,7 +377,7 @@ func (r *Roster) processSubscribe(presence *xmpp.Presence) error {
 				Ask:          true,
 			}
 		}
-		if r.insertItem(usrRi, userJID); err != nil {
+		if r.insertItem(usrRi); err != nil {
 			return err
 		}
 	}
@@ -390,7 +426,7 @@ func (r *Roster) processSubscribed(presence *xmpp.Presence) error {
 				Ask:          false,
 			}
 		}
-		if r.insertItem(cntRi, contactJID); err != nil {
+		if r.insertItem(cntRi); err != nil {
 			return err
 		}
 	}
@@ -413,7 +449,7 @@ func (r *Roster) processSubscribed(presence *xmpp.Presence) error {
 				return nil
 			}
 			usrRi.Ask = false
-			if r.insertItem(usrRi, userJID); err != nil {
+			if r.insertItem(usrRi); err != nil {
 				return err
 			}
 		}
@@ -444,7 +480,7 @@ func (r *Roster) processUnsubscribe(presence *xmpp.Presence) error {
 			default:
 				usrRi.Subscription = rostermodel.SubscriptionNone
 			}
-			if r.insertItem(usrRi, userJID); err != nil {
+			if r.insertItem(usrRi); err != nil {
 				return err
 			}
 		}
@@ -465,7 +501,7 @@ func (r *Roster) processUnsubscribe(presence *xmpp.Presence) error {
 			default:
 				cntRi.Subscription = rostermodel.SubscriptionNone
 			}
-			if r.insertItem(cntRi, contactJID); err != nil {
+			if r.insertItem(cntRi); err != nil {
 				return err
 			}
 		}
@@ -507,7 +543,7 @@ func (r *Roster) processUnsubscribed(presence *xmpp.Presence) error {
 			default:
 				cntRi.Subscription = rostermodel.SubscriptionNone
 			}
-			if r.insertItem(cntRi, contactJID); err != nil {
+			if r.insertItem(cntRi); err != nil {
 				return err
 			}
 		}
@@ -532,7 +568,7 @@ routePresence:
 				}
 			}
 			usrRi.Ask = false
-			if r.insertItem(usrRi, userJID); err != nil {
+			if r.insertItem(usrRi); err != nil {
 				return err
 			}
 		}
@@ -667,32 +703,32 @@ func (r *Roster) onlineJIDMatchesJID(onlineJID, j *jid.JID) bool {
 	return onlineJID.Matches(j, jid.MatchesDomain)
 }
 
-func (r *Roster) insertItem(ri *rostermodel.Item, pushTo *jid.JID) error {
+func (r *Roster) insertItem(ri *rostermodel.Item) error {
 	v, err := storage.Instance().InsertOrUpdateRosterItem(ri)
 	if err != nil {
 		return err
 	}
 	ri.Ver = v.Ver
-	return r.pushItem(ri, pushTo)
+	return r.pushItem(ri)
 }
 
-func (r *Roster) deleteItem(ri *rostermodel.Item, pushTo *jid.JID) error {
+func (r *Roster) deleteItem(ri *rostermodel.Item) error {
 	v, err := storage.Instance().DeleteRosterItem(ri.Username, ri.JID)
 	if err != nil {
 		return err
 	}
 	ri.Ver = v.Ver
-	return r.pushItem(ri, pushTo)
+	return r.pushItem(ri)
 }
 
-func (r *Roster) pushItem(ri *rostermodel.Item, to *jid.JID) error {
+func (r *Roster) pushItem(ri *rostermodel.Item) error {
 	query := xmpp.NewElementNamespace("query", rosterNamespace)
 	if r.cfg.Versioning {
 		query.SetAttribute("ver", fmt.Sprintf("v%d", ri.Ver))
 	}
 	query.AppendElement(ri.Element())
 
-	stms := router.UserStreams(to.Node())
+	stms := router.UserStreams(ri.Username)
 	for _, stm := range stms {
 		if !stm.Context().Bool(rosterRequestedCtxKey) {
 			continue
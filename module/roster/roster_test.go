@@ -257,6 +257,52 @@ func TestRoster_RemoveItem(t *testing.T) {
 	require.Nil(t, ri)
 }
 
+func TestRoster_SetAndRemoveItem(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j)
+	stm.SetAuthenticated(true)
+	stm.Context().SetBool(true, rosterRequestedCtxKey)
+	router.Bind(stm)
+
+	r := New(&Config{}, nil)
+
+	err := r.SetItem(&rostermodel.Item{
+		Username:     "ortuman",
+		JID:          "noelia@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+	require.Nil(t, err)
+
+	// expecting roster push...
+	elem := stm.FetchElement()
+	require.Equal(t, xmpp.SetType, elem.Type())
+	itm := elem.Elements().ChildNamespace("query", rosterNamespace).Elements().Child("item")
+	require.Equal(t, rostermodel.SubscriptionBoth, itm.Attributes().Get("subscription"))
+
+	ri, _ := storage.Instance().FetchRosterItem("ortuman", "noelia@jackal.im")
+	require.NotNil(t, ri)
+
+	require.Nil(t, r.RemoveItem("ortuman", "noelia@jackal.im"))
+
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.SetType, elem.Type())
+	itm = elem.Elements().ChildNamespace("query", rosterNamespace).Elements().Child("item")
+	require.Equal(t, rostermodel.SubscriptionRemove, itm.Attributes().Get("subscription"))
+
+	ri, _ = storage.Instance().FetchRosterItem("ortuman", "noelia@jackal.im")
+	require.Nil(t, ri)
+}
+
 func TestRoster_OnlineJIDs(t *testing.T) {
 	host.Initialize([]host.Config{{Name: "jackal.im"}})
 	router.Initialize(&router.Config{})
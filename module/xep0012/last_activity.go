@@ -26,6 +26,7 @@ const lastActivityNamespace = "jabber:iq:last"
 // LastActivity represents a last activity stream module.
 type LastActivity struct {
 	startTime  time.Time
+	disco      *xep0030.DiscoInfo
 	actorCh    chan func()
 	shutdownCh <-chan struct{}
 }
@@ -34,6 +35,7 @@ type LastActivity struct {
 func New(disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *LastActivity {
 	x := &LastActivity{
 		startTime:  time.Now(),
+		disco:      disco,
 		actorCh:    make(chan func(), mailboxSize),
 		shutdownCh: shutdownCh,
 	}
@@ -57,6 +59,21 @@ func (x *LastActivity) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
 	x.actorCh <- func() { x.processIQ(iq, stm) }
 }
 
+// UnregisterFeatures unregisters every feature announced by the module
+// through the disco info module.
+func (x *LastActivity) UnregisterFeatures() {
+	if x.disco != nil {
+		x.disco.UnregisterServerFeature(lastActivityNamespace)
+		x.disco.UnregisterAccountFeature(lastActivityNamespace)
+	}
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *LastActivity) MailboxSize() int {
+	return len(x.actorCh)
+}
+
 // runs on it's own goroutine
 func (x *LastActivity) loop() {
 	for {
@@ -66,6 +66,16 @@ func (di *DiscoInfo) UnregisterServerFeature(feature string) {
 	di.srvProvider.unregisterServerFeature(feature)
 }
 
+// RegisterAccountIdentity registers a new identity associated to all account domains.
+func (di *DiscoInfo) RegisterAccountIdentity(identity Identity) {
+	di.srvProvider.registerAccountIdentity(identity)
+}
+
+// UnregisterAccountIdentity unregisters a previously registered account identity.
+func (di *DiscoInfo) UnregisterAccountIdentity(identity Identity) {
+	di.srvProvider.unregisterAccountIdentity(identity)
+}
+
 // RegisterAccountFeature registers a new feature associated to all account domains.
 func (di *DiscoInfo) RegisterAccountFeature(feature string) {
 	di.srvProvider.registerAccountFeature(feature)
@@ -106,6 +116,12 @@ func (di *DiscoInfo) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
 	di.actorCh <- func() { di.processIQ(iq, stm) }
 }
 
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (di *DiscoInfo) MailboxSize() int {
+	return len(di.actorCh)
+}
+
 // runs on it's own goroutine
 func (di *DiscoInfo) loop() {
 	for {
@@ -18,10 +18,11 @@ import (
 )
 
 type serverProvider struct {
-	mu              sync.RWMutex
-	serverItems     []Item
-	serverFeatures  []Feature
-	accountFeatures []Feature
+	mu                sync.RWMutex
+	serverItems       []Item
+	serverFeatures    []Feature
+	accountIdentities []Identity
+	accountFeatures   []Feature
 }
 
 func (sp *serverProvider) Identities(toJID, fromJID *jid.JID, node string) []Identity {
@@ -31,7 +32,9 @@ func (sp *serverProvider) Identities(toJID, fromJID *jid.JID, node string) []Ide
 	if toJID.IsServer() {
 		return []Identity{{Type: "im", Category: "server", Name: "jackal"}}
 	} else {
-		return []Identity{{Type: "registered", Category: "account"}}
+		sp.mu.RLock()
+		defer sp.mu.RUnlock()
+		return append([]Identity{{Type: "registered", Category: "account"}}, sp.accountIdentities...)
 	}
 }
 
@@ -121,6 +124,28 @@ func (sp *serverProvider) unregisterServerFeature(feature Feature) {
 	}
 }
 
+func (sp *serverProvider) registerAccountIdentity(identity Identity) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	for _, idt := range sp.accountIdentities {
+		if idt == identity {
+			return
+		}
+	}
+	sp.accountIdentities = append(sp.accountIdentities, identity)
+}
+
+func (sp *serverProvider) unregisterAccountIdentity(identity Identity) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+	for i, idt := range sp.accountIdentities {
+		if idt == identity {
+			sp.accountIdentities = append(sp.accountIdentities[:i], sp.accountIdentities[i+1:]...)
+			return
+		}
+	}
+}
+
 func (sp *serverProvider) registerAccountFeature(feature Feature) {
 	sp.mu.Lock()
 	defer sp.mu.Unlock()
@@ -79,6 +79,15 @@ func TestServerProvider_Identities(t *testing.T) {
 	require.Equal(t, sp.Identities(accJID.ToBareJID(), accJID, ""), []Identity{
 		{Type: "registered", Category: "account"},
 	})
+
+	sp.registerAccountIdentity(Identity{Type: "pep", Category: "pubsub"})
+	sp.registerAccountIdentity(Identity{Type: "pep", Category: "pubsub"})
+	require.Equal(t, sp.Identities(accJID.ToBareJID(), accJID, ""), []Identity{
+		{Type: "registered", Category: "account"},
+		{Type: "pep", Category: "pubsub"},
+	})
+	sp.unregisterAccountIdentity(Identity{Type: "pep", Category: "pubsub"})
+	require.Equal(t, 1, len(sp.Identities(accJID.ToBareJID(), accJID, "")))
 }
 
 func TestServerProvider_Items(t *testing.T) {
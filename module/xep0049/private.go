@@ -46,6 +46,12 @@ func (x *Private) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
 	x.actorCh <- func() { x.processIQ(iq, stm) }
 }
 
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *Private) MailboxSize() int {
+	return len(x.actorCh)
+}
+
 // runs on it's own goroutine
 func (x *Private) loop() {
 	for {
@@ -19,6 +19,7 @@ const vCardNamespace = "vcard-temp"
 
 // VCard represents a vCard server stream module.
 type VCard struct {
+	disco      *xep0030.DiscoInfo
 	actorCh    chan func()
 	shutdownCh <-chan struct{}
 }
@@ -26,6 +27,7 @@ type VCard struct {
 // New returns a vCard IQ handler module.
 func New(disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *VCard {
 	v := &VCard{
+		disco:      disco,
 		actorCh:    make(chan func(), mailboxSize),
 		shutdownCh: shutdownCh,
 	}
@@ -49,6 +51,21 @@ func (x *VCard) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
 	x.actorCh <- func() { x.processIQ(iq, stm) }
 }
 
+// UnregisterFeatures unregisters every feature announced by the module
+// through the disco info module.
+func (x *VCard) UnregisterFeatures() {
+	if x.disco != nil {
+		x.disco.UnregisterServerFeature(vCardNamespace)
+		x.disco.UnregisterAccountFeature(vCardNamespace)
+	}
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *VCard) MailboxSize() int {
+	return len(x.actorCh)
+}
+
 // runs on it's own goroutine
 func (x *VCard) loop() {
 	for {
@@ -0,0 +1,164 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0059
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/ortuman/jackal/xmpp"
+)
+
+// RSMNamespace specifies result set management namespace.
+const RSMNamespace = "http://jabber.org/protocol/rsm"
+
+// ErrItemNotFound will be returned by Range when 'after' or 'before'
+// item could not be found within the result set.
+var ErrItemNotFound = errors.New("xep0059: item not found")
+
+// Request represents a result set management request.
+type Request struct {
+	Max      int
+	After    string
+	Before   string
+	LastPage bool
+	Index    int
+}
+
+// NewRequestFromElement parses a result set management request
+// from a 'set' element.
+func NewRequestFromElement(elem xmpp.XElement) (*Request, error) {
+	if elem.Name() != "set" {
+		return nil, fmt.Errorf("xep0059: invalid set element name: %s", elem.Name())
+	}
+	if ns := elem.Namespace(); ns != RSMNamespace {
+		return nil, fmt.Errorf("xep0059: invalid set namespace: %s", ns)
+	}
+	req := &Request{Max: -1}
+	if maxElem := elem.Elements().Child("max"); maxElem != nil {
+		max, err := strconv.Atoi(maxElem.Text())
+		if err != nil || max < 0 {
+			return nil, fmt.Errorf("xep0059: invalid max value: %s", maxElem.Text())
+		}
+		req.Max = max
+	}
+	if indexElem := elem.Elements().Child("index"); indexElem != nil {
+		index, err := strconv.Atoi(indexElem.Text())
+		if err != nil || index < 0 {
+			return nil, fmt.Errorf("xep0059: invalid index value: %s", indexElem.Text())
+		}
+		req.Index = index
+	}
+	if afterElem := elem.Elements().Child("after"); afterElem != nil {
+		req.After = afterElem.Text()
+	}
+	if beforeElem := elem.Elements().Child("before"); beforeElem != nil {
+		req.Before = beforeElem.Text()
+		req.LastPage = len(req.Before) == 0
+	}
+	return req, nil
+}
+
+// IsBackwards returns whether or not the request pages
+// backwards through the result set.
+func (r *Request) IsBackwards() bool {
+	return r.LastPage || len(r.Before) > 0
+}
+
+// Range returns the [from, to) interval of the requested page
+// within an ordered set of item identifiers.
+// Page size is limited to maxPageSize in case request doesn't
+// specify a smaller one.
+func (r *Request) Range(ids []string, maxPageSize int) (from, to int, err error) {
+	max := maxPageSize
+	if r.Max >= 0 && r.Max < max {
+		max = r.Max
+	}
+	count := len(ids)
+	switch {
+	case len(r.After) > 0:
+		idx := indexOf(ids, r.After)
+		if idx == -1 {
+			return 0, 0, ErrItemNotFound
+		}
+		from = idx + 1
+		to = minInt(from+max, count)
+
+	case len(r.Before) > 0:
+		idx := indexOf(ids, r.Before)
+		if idx == -1 {
+			return 0, 0, ErrItemNotFound
+		}
+		to = idx
+		from = maxInt(to-max, 0)
+
+	case r.LastPage:
+		to = count
+		from = maxInt(to-max, 0)
+
+	default:
+		from = minInt(r.Index, count)
+		to = minInt(from+max, count)
+	}
+	return from, to, nil
+}
+
+// Result represents a result set management response.
+// Negative FirstIndex and Count values are left out of the response.
+type Result struct {
+	First      string
+	FirstIndex int
+	Last       string
+	Count      int
+}
+
+// Element returns result set 'set' element representation.
+func (r *Result) Element() xmpp.XElement {
+	set := xmpp.NewElementNamespace("set", RSMNamespace)
+	if len(r.First) > 0 {
+		first := xmpp.NewElementName("first")
+		if r.FirstIndex >= 0 {
+			first.SetAttribute("index", strconv.Itoa(r.FirstIndex))
+		}
+		first.SetText(r.First)
+		set.AppendElement(first)
+	}
+	if len(r.Last) > 0 {
+		last := xmpp.NewElementName("last")
+		last.SetText(r.Last)
+		set.AppendElement(last)
+	}
+	if r.Count >= 0 {
+		count := xmpp.NewElementName("count")
+		count.SetText(strconv.Itoa(r.Count))
+		set.AppendElement(count)
+	}
+	return set
+}
+
+func indexOf(ids []string, id string) int {
+	for i, s := range ids {
+		if s == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
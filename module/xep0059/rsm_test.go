@@ -0,0 +1,108 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0059
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRequest_FromElement(t *testing.T) {
+	_, err := NewRequestFromElement(xmpp.NewElementNamespace("query", RSMNamespace))
+	require.NotNil(t, err)
+
+	_, err = NewRequestFromElement(xmpp.NewElementNamespace("set", "jabber:x:data"))
+	require.NotNil(t, err)
+
+	set := xmpp.NewElementNamespace("set", RSMNamespace)
+	max := xmpp.NewElementName("max")
+	max.SetText("ten")
+	set.AppendElement(max)
+	_, err = NewRequestFromElement(set)
+	require.NotNil(t, err)
+
+	req, err := NewRequestFromElement(xmpp.NewElementNamespace("set", RSMNamespace))
+	require.Nil(t, err)
+	require.Equal(t, -1, req.Max)
+	require.False(t, req.IsBackwards())
+
+	set = xmpp.NewElementNamespace("set", RSMNamespace)
+	max = xmpp.NewElementName("max")
+	max.SetText("10")
+	set.AppendElement(max)
+	after := xmpp.NewElementName("after")
+	after.SetText("a1")
+	set.AppendElement(after)
+	req, err = NewRequestFromElement(set)
+	require.Nil(t, err)
+	require.Equal(t, 10, req.Max)
+	require.Equal(t, "a1", req.After)
+
+	set = xmpp.NewElementNamespace("set", RSMNamespace)
+	set.AppendElement(xmpp.NewElementName("before"))
+	req, err = NewRequestFromElement(set)
+	require.Nil(t, err)
+	require.True(t, req.LastPage)
+	require.True(t, req.IsBackwards())
+}
+
+func TestRequest_Range(t *testing.T) {
+	ids := []string{"a", "b", "c", "d", "e"}
+
+	req := &Request{Max: -1}
+	from, to, err := req.Range(ids, 3)
+	require.Nil(t, err)
+	require.Equal(t, 0, from)
+	require.Equal(t, 3, to)
+
+	req = &Request{Max: 2, After: "b"}
+	from, to, _ = req.Range(ids, 3)
+	require.Equal(t, 2, from)
+	require.Equal(t, 4, to)
+
+	req = &Request{Max: 10, Before: "d"}
+	from, to, _ = req.Range(ids, 10)
+	require.Equal(t, 0, from)
+	require.Equal(t, 3, to)
+
+	req = &Request{Max: 2, LastPage: true}
+	from, to, _ = req.Range(ids, 10)
+	require.Equal(t, 3, from)
+	require.Equal(t, 5, to)
+
+	req = &Request{Max: 2, Index: 4}
+	from, to, _ = req.Range(ids, 10)
+	require.Equal(t, 4, from)
+	require.Equal(t, 5, to)
+
+	req = &Request{Max: 2, After: "z"}
+	_, _, err = req.Range(ids, 10)
+	require.Equal(t, ErrItemNotFound, err)
+}
+
+func TestResult_Element(t *testing.T) {
+	res := &Result{First: "a", FirstIndex: 2, Last: "c", Count: 5}
+	elem := res.Element()
+	require.Equal(t, "set", elem.Name())
+	require.Equal(t, RSMNamespace, elem.Namespace())
+	require.Equal(t, "a", elem.Elements().Child("first").Text())
+	require.Equal(t, "2", elem.Elements().Child("first").Attributes().Get("index"))
+	require.Equal(t, "c", elem.Elements().Child("last").Text())
+	require.Equal(t, "5", elem.Elements().Child("count").Text())
+
+	res = &Result{Count: 0}
+	elem = res.Element()
+	require.Nil(t, elem.Elements().Child("first"))
+	require.Equal(t, "0", elem.Elements().Child("count").Text())
+
+	// unknown index and count
+	res = &Result{First: "a", FirstIndex: -1, Last: "c", Count: -1}
+	elem = res.Element()
+	require.Equal(t, "", elem.Elements().Child("first").Attributes().Get("index"))
+	require.Nil(t, elem.Elements().Child("count"))
+}
@@ -6,6 +6,7 @@
 package xep0077
 
 import (
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/model"
 	"github.com/ortuman/jackal/module/xep0030"
@@ -31,6 +32,7 @@ type Config struct {
 // Register represents an in-band server stream module.
 type Register struct {
 	cfg        *Config
+	disco      *xep0030.DiscoInfo
 	actorCh    chan func()
 	shutdownCh <-chan struct{}
 }
@@ -39,6 +41,7 @@ type Register struct {
 func New(config *Config, disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *Register {
 	r := &Register{
 		cfg:        config,
+		disco:      disco,
 		actorCh:    make(chan func(), mailboxSize),
 		shutdownCh: shutdownCh,
 	}
@@ -61,6 +64,26 @@ func (x *Register) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
 	x.actorCh <- func() { x.processIQ(iq, stm) }
 }
 
+// SetConfig replaces module configuration, applying it
+// to every request processed from now on.
+func (x *Register) SetConfig(cfg *Config) {
+	x.actorCh <- func() { x.cfg = cfg }
+}
+
+// UnregisterFeatures unregisters every feature announced by the module
+// through the disco info module.
+func (x *Register) UnregisterFeatures() {
+	if x.disco != nil {
+		x.disco.UnregisterServerFeature(registerNamespace)
+	}
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *Register) MailboxSize() int {
+	return len(x.actorCh)
+}
+
 // runs on it's own goroutine
 func (x *Register) loop() {
 	for {
@@ -131,13 +154,18 @@ func (x *Register) sendRegistrationFields(iq *xmpp.IQ, query xmpp.XElement, stm
 }
 
 func (x *Register) registerNewUser(iq *xmpp.IQ, query xmpp.XElement, stm stream.C2S) {
+	if !auth.IsLocal() {
+		// accounts are managed by the external authentication backend
+		stm.SendElement(iq.NotAllowedError())
+		return
+	}
 	userEl := query.Elements().Child("username")
 	passwordEl := query.Elements().Child("password")
 	if userEl == nil || passwordEl == nil || len(userEl.Text()) == 0 || len(passwordEl.Text()) == 0 {
 		stm.SendElement(iq.BadRequestError())
 		return
 	}
-	exists, err := storage.Instance().UserExists(userEl.Text())
+	exists, err := auth.Instance().UserExists(userEl.Text())
 	if err != nil {
 		log.Errorf("%v", err)
 		stm.SendElement(iq.InternalServerError())
@@ -149,9 +177,9 @@ func (x *Register) registerNewUser(iq *xmpp.IQ, query xmpp.XElement, stm stream.
 	}
 	user := model.User{
 		Username:     userEl.Text(),
-		Password:     passwordEl.Text(),
 		LastPresence: xmpp.NewPresence(stm.JID(), stm.JID(), xmpp.UnavailableType),
 	}
+	auth.SetUserPassword(&user, passwordEl.Text())
 	if err := storage.Instance().InsertOrUpdateUser(&user); err != nil {
 		log.Error(err)
 		stm.SendElement(iq.InternalServerError())
@@ -170,7 +198,7 @@ func (x *Register) cancelRegistration(iq *xmpp.IQ, query xmpp.XElement, stm stre
 		stm.SendElement(iq.BadRequestError())
 		return
 	}
-	if err := storage.Instance().DeleteUser(stm.Username()); err != nil {
+	if err := storage.DeleteUserData(stm.Username()); err != nil {
 		log.Error(err)
 		stm.SendElement(iq.InternalServerError())
 		return
@@ -183,7 +211,7 @@ func (x *Register) changePassword(password string, username string, iq *xmpp.IQ,
 		stm.SendElement(iq.NotAllowedError())
 		return
 	}
-	if username != stm.Username() {
+	if username != stm.Username() || !auth.IsLocal() {
 		stm.SendElement(iq.NotAllowedError())
 		return
 	}
@@ -202,8 +230,8 @@ func (x *Register) changePassword(password string, username string, iq *xmpp.IQ,
 		stm.SendElement(iq.ResultIQ())
 		return
 	}
-	if user.Password != password {
-		user.Password = password
+	if !auth.VerifyPassword(user, password) || len(user.Password) > 0 {
+		auth.SetUserPassword(user, password)
 		if err := storage.Instance().InsertOrUpdateUser(user); err != nil {
 			log.Error(err)
 			stm.SendElement(iq.InternalServerError())
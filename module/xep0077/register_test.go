@@ -8,6 +8,7 @@ package xep0077
 import (
 	"testing"
 
+	"github.com/ortuman/jackal/auth"
 	"github.com/ortuman/jackal/model"
 	"github.com/ortuman/jackal/storage"
 	"github.com/ortuman/jackal/stream"
@@ -179,6 +180,21 @@ func TestXEP0077_RegisterUser(t *testing.T) {
 
 	usr, _ := storage.Instance().FetchUser("ortuman")
 	require.NotNil(t, usr)
+
+	// accounts managed by an external authentication backend
+	auth.Initialize(&auth.Config{Type: auth.HTTPBackend, HTTP: &auth.HTTPBackendConfig{URL: "http://127.0.0.1/xmpp"}})
+	defer auth.Shutdown()
+
+	stm2 := stream.NewMockC2S("abcd5678", j)
+	defer stm2.Disconnect(nil)
+
+	username.SetText("romeo")
+	x.ProcessIQ(iq, stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
+
+	exists, _ := storage.Instance().UserExists("romeo")
+	require.False(t, exists)
 }
 
 func TestXEP0077_CancelRegistration(t *testing.T) {
@@ -293,5 +309,16 @@ func TestXEP0077_ChangePassword(t *testing.T) {
 
 	usr, _ := storage.Instance().FetchUser("ortuman")
 	require.NotNil(t, usr)
-	require.Equal(t, "5678", usr.Password)
+	require.Equal(t, "", usr.Password)
+	require.True(t, auth.VerifyPassword(usr, "5678"))
+	require.False(t, auth.VerifyPassword(usr, "1234"))
+
+	// passwords managed by an external authentication backend
+	auth.Initialize(&auth.Config{Type: auth.HTTPBackend, HTTP: &auth.HTTPBackendConfig{URL: "http://127.0.0.1/xmpp"}})
+	defer auth.Shutdown()
+
+	password.SetText("abcd")
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ErrNotAllowed.Error(), elem.Error().Elements().All()[0].Name())
 }
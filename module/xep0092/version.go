@@ -35,6 +35,7 @@ type Config struct {
 // Version represents a version module.
 type Version struct {
 	cfg        *Config
+	disco      *xep0030.DiscoInfo
 	actorCh    chan func()
 	shutdownCh <-chan struct{}
 }
@@ -43,6 +44,7 @@ type Version struct {
 func New(config *Config, disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *Version {
 	v := &Version{
 		cfg:        config,
+		disco:      disco,
 		actorCh:    make(chan func(), mailboxSize),
 		shutdownCh: shutdownCh,
 	}
@@ -65,6 +67,26 @@ func (x *Version) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
 	x.actorCh <- func() { x.processIQ(iq, stm) }
 }
 
+// SetConfig replaces module configuration, applying it
+// to every request processed from now on.
+func (x *Version) SetConfig(cfg *Config) {
+	x.actorCh <- func() { x.cfg = cfg }
+}
+
+// UnregisterFeatures unregisters every feature announced by the module
+// through the disco info module.
+func (x *Version) UnregisterFeatures() {
+	if x.disco != nil {
+		x.disco.UnregisterServerFeature(versionNamespace)
+	}
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *Version) MailboxSize() int {
+	return len(x.actorCh)
+}
+
 // runs on it's own goroutine
 func (x *Version) loop() {
 	for {
@@ -0,0 +1,292 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0115
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/capsmodel"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const mailboxSize = 2048
+
+const queryTimeout = time.Second * 15
+
+const (
+	capsNamespace      = "http://jabber.org/protocol/caps"
+	discoInfoNamespace = "http://jabber.org/protocol/disco#info"
+)
+
+// CapabilitiesHandler represents a function invoked every time
+// the capabilities of an available entity become known.
+type CapabilitiesHandler func(j *jid.JID, caps *capsmodel.Capabilities)
+
+type capsHandler struct {
+	id int
+	h  CapabilitiesHandler
+}
+
+type capsQuery struct {
+	id    string
+	node  string
+	ver   string
+	hash  string
+	jids  []*jid.JID
+	timer *time.Timer
+}
+
+// EntityCaps represents an entity capabilities stream module.
+type EntityCaps struct {
+	mu         sync.RWMutex
+	caps       map[string]*capsmodel.Capabilities
+	queries    map[string]*capsQuery
+	pending    map[string]*capsQuery
+	handlers   []capsHandler
+	handlerID  int
+	actorCh    chan func()
+	shutdownCh <-chan struct{}
+}
+
+// New returns an entity capabilities module.
+func New(shutdownCh <-chan struct{}) *EntityCaps {
+	x := &EntityCaps{
+		caps:       make(map[string]*capsmodel.Capabilities),
+		queries:    make(map[string]*capsQuery),
+		pending:    make(map[string]*capsQuery),
+		actorCh:    make(chan func(), mailboxSize),
+		shutdownCh: shutdownCh,
+	}
+	go x.loop()
+	return x
+}
+
+// RegisterCapabilitiesHandler registers a new capabilities handler,
+// returning an identifier that can be used to unregister it.
+// Handlers are invoked from the module own goroutine.
+func (x *EntityCaps) RegisterCapabilitiesHandler(h CapabilitiesHandler) int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.handlerID++
+	x.handlers = append(x.handlers, capsHandler{id: x.handlerID, h: h})
+	return x.handlerID
+}
+
+// UnregisterCapabilitiesHandler unregisters a previously registered
+// capabilities handler.
+func (x *EntityCaps) UnregisterCapabilitiesHandler(id int) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var handlers []capsHandler
+	for _, ch := range x.handlers {
+		if ch.id != id {
+			handlers = append(handlers, ch)
+		}
+	}
+	x.handlers = handlers
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the entity capabilities module.
+func (x *EntityCaps) MatchesIQ(iq *xmpp.IQ) bool {
+	if !iq.IsResult() && !iq.IsError() {
+		return false
+	}
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	_, ok := x.queries[iq.ID()]
+	return ok
+}
+
+// ProcessIQ processes a disco info query response
+// issued by the entity capabilities module.
+func (x *EntityCaps) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
+	x.actorCh <- func() { x.processIQ(iq) }
+}
+
+// ProcessPresence extracts entity capabilities from an available
+// presence, querying them in case they haven't been previously discovered.
+func (x *EntityCaps) ProcessPresence(presence *xmpp.Presence) {
+	x.actorCh <- func() { x.processPresence(presence) }
+}
+
+// Capabilities returns the already discovered capabilities
+// associated to a presence, or nil if they're still unknown.
+func (x *EntityCaps) Capabilities(presence *xmpp.Presence) *capsmodel.Capabilities {
+	c := presence.Elements().ChildNamespace("c", capsNamespace)
+	if c == nil {
+		return nil
+	}
+	return x.fetchCapabilities(c.Attributes().Get("ver"))
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *EntityCaps) MailboxSize() int {
+	return len(x.actorCh)
+}
+
+// runs on it's own goroutine
+func (x *EntityCaps) loop() {
+	for {
+		select {
+		case f := <-x.actorCh:
+			f()
+		case <-x.shutdownCh:
+			x.mu.Lock()
+			for _, q := range x.queries {
+				q.timer.Stop()
+			}
+			x.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (x *EntityCaps) processPresence(presence *xmpp.Presence) {
+	fromJID := presence.FromJID()
+	if !presence.IsAvailable() || !fromJID.IsFullWithUser() {
+		return
+	}
+	c := presence.Elements().ChildNamespace("c", capsNamespace)
+	if c == nil {
+		return
+	}
+	node := c.Attributes().Get("node")
+	ver := c.Attributes().Get("ver")
+	hash := c.Attributes().Get("hash")
+	if len(node) == 0 || len(ver) == 0 || !isSupportedHash(hash) {
+		// legacy format can't be verified
+		return
+	}
+	if caps := x.fetchCapabilities(ver); caps != nil {
+		x.notifyHandlers(fromJID, caps)
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if q := x.pending[ver]; q != nil {
+		// already querying...
+		for _, j := range q.jids {
+			if j.Matches(fromJID, jid.MatchesBare|jid.MatchesResource) {
+				return
+			}
+		}
+		q.jids = append(q.jids, fromJID)
+		return
+	}
+	q := &capsQuery{
+		id:   uuid.New(),
+		node: node,
+		ver:  ver,
+		hash: hash,
+		jids: []*jid.JID{fromJID},
+	}
+	q.timer = time.AfterFunc(queryTimeout, func() {
+		x.actorCh <- func() { x.cancelQuery(q) }
+	})
+	x.queries[q.id] = q
+	x.pending[ver] = q
+
+	srvJID, _ := jid.New("", fromJID.Domain(), "", true)
+
+	iq := xmpp.NewIQType(q.id, xmpp.GetType)
+	iq.SetFromJID(srvJID)
+	iq.SetToJID(fromJID)
+	query := xmpp.NewElementNamespace("query", discoInfoNamespace)
+	query.SetAttribute("node", node+"#"+ver)
+	iq.AppendElement(query)
+
+	router.Route(iq)
+}
+
+func (x *EntityCaps) processIQ(iq *xmpp.IQ) {
+	x.mu.Lock()
+	q := x.queries[iq.ID()]
+	if q != nil {
+		q.timer.Stop()
+		delete(x.queries, q.id)
+		delete(x.pending, q.ver)
+	}
+	x.mu.Unlock()
+
+	if q == nil || !iq.IsResult() {
+		return
+	}
+	query := iq.Elements().ChildNamespace("query", discoInfoNamespace)
+	if query == nil {
+		return
+	}
+	ver, err := verificationString(query)
+	if err != nil {
+		log.Warnf("xep0115: %v", err)
+		return
+	}
+	if hashVerificationString(ver, q.hash) != q.ver {
+		log.Warnf("xep0115: capabilities verification failed... ver: %s", q.ver)
+		return
+	}
+	caps := &capsmodel.Capabilities{Node: q.node, Ver: q.ver}
+	for _, f := range query.Elements().Children("feature") {
+		caps.Features = append(caps.Features, f.Attributes().Get("var"))
+	}
+	if err := storage.Instance().InsertOrUpdateCapabilities(caps); err != nil {
+		log.Error(err)
+		return
+	}
+	x.mu.Lock()
+	x.caps[caps.Ver] = caps
+	x.mu.Unlock()
+
+	for _, j := range q.jids {
+		x.notifyHandlers(j, caps)
+	}
+}
+
+func (x *EntityCaps) cancelQuery(q *capsQuery) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if x.queries[q.id] == q {
+		delete(x.queries, q.id)
+		delete(x.pending, q.ver)
+	}
+}
+
+func (x *EntityCaps) fetchCapabilities(ver string) *capsmodel.Capabilities {
+	x.mu.RLock()
+	caps := x.caps[ver]
+	x.mu.RUnlock()
+	if caps != nil {
+		return caps
+	}
+	caps, err := storage.Instance().FetchCapabilities(ver)
+	if err != nil {
+		log.Error(err)
+		return nil
+	}
+	if caps != nil {
+		x.mu.Lock()
+		x.caps[ver] = caps
+		x.mu.Unlock()
+	}
+	return caps
+}
+
+func (x *EntityCaps) notifyHandlers(j *jid.JID, caps *capsmodel.Capabilities) {
+	x.mu.RLock()
+	handlers := x.handlers
+	x.mu.RUnlock()
+	for _, ch := range handlers {
+		ch.h(j, caps)
+	}
+}
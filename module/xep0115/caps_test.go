@@ -0,0 +1,168 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0115
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model/capsmodel"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0115_Matching(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	x := New(nil)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.ResultType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	x.queries[iq.ID()] = &capsQuery{id: iq.ID()}
+	require.True(t, x.MatchesIQ(iq))
+
+	iq.SetType(xmpp.GetType)
+	require.False(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0115_DiscoverCapabilities(t *testing.T) {
+	tUtilCapsInitialize()
+	defer tUtilCapsShutdown()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	router.Bind(stm)
+	defer router.Unbind(stm)
+
+	x := New(nil)
+
+	handledCh := make(chan *capsmodel.Capabilities, 1)
+	handlerID := x.RegisterCapabilitiesHandler(func(hj *jid.JID, caps *capsmodel.Capabilities) {
+		require.Equal(t, j.String(), hj.String())
+		handledCh <- caps
+	})
+
+	p := tUtilCapsPresence(j, "QgayPKawpkPSDYmwT/WM94uAlu0=")
+	require.Nil(t, x.Capabilities(p))
+
+	x.ProcessPresence(p)
+	elem := stm.FetchElement()
+	require.Equal(t, "iq", elem.Name())
+	require.Equal(t, xmpp.GetType, elem.Type())
+	require.Equal(t, "jackal.im", elem.From())
+	q := elem.Elements().ChildNamespace("query", discoInfoNamespace)
+	require.NotNil(t, q)
+	require.Equal(t, "http://code.google.com/p/exodus#QgayPKawpkPSDYmwT/WM94uAlu0=", q.Attributes().Get("node"))
+
+	// pending query
+	x.ProcessPresence(p)
+
+	iq := xmpp.NewIQType(elem.ID(), xmpp.ResultType)
+	iq.SetFromJID(j)
+	iq.SetToJID(elem.(*xmpp.IQ).FromJID())
+	iq.AppendElement(tUtilCapsSimpleQuery())
+	require.True(t, x.MatchesIQ(iq))
+	x.ProcessIQ(iq, stm)
+
+	select {
+	case caps := <-handledCh:
+		require.Equal(t, "QgayPKawpkPSDYmwT/WM94uAlu0=", caps.Ver)
+		require.True(t, caps.HasFeature("http://jabber.org/protocol/muc"))
+	case <-time.After(time.Second):
+		require.Fail(t, "capabilities handler not invoked")
+	}
+	select {
+	case <-handledCh:
+		require.Fail(t, "capabilities handler invoked twice")
+	default:
+	}
+	caps, _ := storage.Instance().FetchCapabilities("QgayPKawpkPSDYmwT/WM94uAlu0=")
+	require.NotNil(t, caps)
+	require.Equal(t, "http://code.google.com/p/exodus", caps.Node)
+
+	require.NotNil(t, x.Capabilities(p))
+
+	// already discovered capabilities
+	x.ProcessPresence(p)
+	select {
+	case <-handledCh:
+	case <-time.After(time.Second):
+		require.Fail(t, "capabilities handler not invoked")
+	}
+
+	// unregistered handler
+	x.UnregisterCapabilitiesHandler(handlerID)
+	x.ProcessPresence(p)
+	select {
+	case <-handledCh:
+		require.Fail(t, "unregistered capabilities handler invoked")
+	case <-time.After(time.Millisecond * 250):
+	}
+}
+
+func TestXEP0115_VerificationFailure(t *testing.T) {
+	tUtilCapsInitialize()
+	defer tUtilCapsShutdown()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	stm := stream.NewMockC2S(uuid.New(), j)
+	router.Bind(stm)
+	defer router.Unbind(stm)
+
+	x := New(nil)
+
+	p := tUtilCapsPresence(j, "q07IKJEyjvHSyhy//CH0CxmKi8w=")
+	x.ProcessPresence(p)
+	elem := stm.FetchElement()
+	require.Equal(t, "iq", elem.Name())
+
+	iq := xmpp.NewIQType(elem.ID(), xmpp.ResultType)
+	iq.SetFromJID(j)
+	iq.SetToJID(elem.(*xmpp.IQ).FromJID())
+	iq.AppendElement(tUtilCapsSimpleQuery())
+	x.ProcessIQ(iq, stm)
+
+	// query is no longer pending
+	x.ProcessPresence(p)
+	elem = stm.FetchElement()
+	require.Equal(t, "iq", elem.Name())
+	require.NotEqual(t, iq.ID(), elem.ID())
+
+	caps, _ := storage.Instance().FetchCapabilities("q07IKJEyjvHSyhy//CH0CxmKi8w=")
+	require.Nil(t, caps)
+	require.Nil(t, x.Capabilities(p))
+}
+
+func tUtilCapsInitialize() {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+}
+
+func tUtilCapsShutdown() {
+	router.Shutdown()
+	storage.Shutdown()
+	host.Shutdown()
+}
+
+func tUtilCapsPresence(j *jid.JID, ver string) *xmpp.Presence {
+	p := xmpp.NewPresence(j, j.ToBareJID(), xmpp.AvailableType)
+	c := xmpp.NewElementNamespace("c", capsNamespace)
+	c.SetAttribute("hash", "sha-1")
+	c.SetAttribute("node", "http://code.google.com/p/exodus")
+	c.SetAttribute("ver", ver)
+	p.AppendElement(c)
+	return p
+}
@@ -0,0 +1,142 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0115
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"sort"
+
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+const formTypeField = "FORM_TYPE"
+
+var hashFuncs = map[string]func() hash.Hash{
+	"sha-1":   sha1.New,
+	"sha-224": sha256.New224,
+	"sha-256": sha256.New,
+	"sha-384": sha512.New384,
+	"sha-512": sha512.New,
+}
+
+func isSupportedHash(h string) bool {
+	_, ok := hashFuncs[h]
+	return ok
+}
+
+// hashVerificationString returns the base64 encoded hash
+// of a verification string using the given algorithm.
+func hashVerificationString(s string, algorithm string) string {
+	hashFn, ok := hashFuncs[algorithm]
+	if !ok {
+		return ""
+	}
+	h := hashFn()
+	h.Write([]byte(s))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// verificationString generates the verification string
+// of a disco info query result (XEP-0115 5.1).
+func verificationString(query xmpp.XElement) (string, error) {
+	var identities, features []string
+	for _, identity := range query.Elements().Children("identity") {
+		attrs := identity.Attributes()
+		identities = append(identities, attrs.Get("category")+"/"+attrs.Get("type")+"/"+attrs.Get("xml:lang")+"/"+attrs.Get("name"))
+	}
+	for _, feature := range query.Elements().Children("feature") {
+		features = append(features, feature.Attributes().Get("var"))
+	}
+	sort.Strings(identities)
+	sort.Strings(features)
+	if hasDuplicates(identities) {
+		return "", fmt.Errorf("duplicated identity")
+	}
+	if hasDuplicates(features) {
+		return "", fmt.Errorf("duplicated feature")
+	}
+	forms, err := extendedForms(query)
+	if err != nil {
+		return "", err
+	}
+	buf := bytes.NewBuffer(nil)
+	for _, identity := range identities {
+		buf.WriteString(identity + "<")
+	}
+	for _, feature := range features {
+		buf.WriteString(feature + "<")
+	}
+	for _, form := range forms {
+		buf.WriteString(form)
+	}
+	return buf.String(), nil
+}
+
+// extendedForms returns the verification string representation
+// of every service discovery extension form, sorted by form type.
+func extendedForms(query xmpp.XElement) ([]string, error) {
+	var formTypes []string
+	forms := make(map[string]string)
+	for _, formElem := range query.Elements().ChildrenNamespace("x", "jabber:x:data") {
+		form, err := xep0004.NewFormFromElement(formElem)
+		if err != nil {
+			return nil, err
+		}
+		var formType string
+		var fields []xep0004.Field
+		for _, field := range form.Fields {
+			if field.Var == formTypeField {
+				if field.Type == xep0004.Hidden && len(field.Values) > 0 {
+					formType = field.Values[0]
+				}
+				continue
+			}
+			fields = append(fields, field)
+		}
+		if len(formType) == 0 {
+			continue // forms without FORM_TYPE are ignored
+		}
+		if _, ok := forms[formType]; ok {
+			return nil, fmt.Errorf("duplicated form type: %s", formType)
+		}
+		sort.Slice(fields, func(i, j int) bool { return fields[i].Var < fields[j].Var })
+
+		buf := bytes.NewBufferString(formType + "<")
+		for _, field := range fields {
+			buf.WriteString(field.Var + "<")
+			values := append([]string(nil), field.Values...)
+			sort.Strings(values)
+			for _, value := range values {
+				buf.WriteString(value + "<")
+			}
+		}
+		forms[formType] = buf.String()
+		formTypes = append(formTypes, formType)
+	}
+	sort.Strings(formTypes)
+
+	var ret []string
+	for _, formType := range formTypes {
+		ret = append(ret, forms[formType])
+	}
+	return ret, nil
+}
+
+func hasDuplicates(sorted []string) bool {
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i] == sorted[i-1] {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0115
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerificationString_Simple(t *testing.T) {
+	query := tUtilCapsSimpleQuery()
+
+	s, err := verificationString(query)
+	require.Nil(t, err)
+	require.Equal(t, "client/pc//Exodus 0.9.1<http://jabber.org/protocol/caps<http://jabber.org/protocol/disco#info<http://jabber.org/protocol/disco#items<http://jabber.org/protocol/muc<", s)
+	require.Equal(t, "QgayPKawpkPSDYmwT/WM94uAlu0=", hashVerificationString(s, "sha-1"))
+
+	// duplicated features
+	query.AppendElement(tUtilCapsFeature("http://jabber.org/protocol/muc"))
+	_, err = verificationString(query)
+	require.NotNil(t, err)
+}
+
+func TestVerificationString_Complex(t *testing.T) {
+	query := xmpp.NewElementNamespace("query", discoInfoNamespace)
+	query.AppendElement(tUtilCapsIdentity("client", "pc", "en", "Psi 0.11"))
+	query.AppendElement(tUtilCapsIdentity("client", "pc", "el", "Ψ 0.11"))
+	query.AppendElement(tUtilCapsFeature("http://jabber.org/protocol/caps"))
+	query.AppendElement(tUtilCapsFeature("http://jabber.org/protocol/disco#info"))
+	query.AppendElement(tUtilCapsFeature("http://jabber.org/protocol/disco#items"))
+	query.AppendElement(tUtilCapsFeature("http://jabber.org/protocol/muc"))
+
+	form := xmpp.NewElementNamespace("x", "jabber:x:data")
+	form.SetAttribute("type", "result")
+	form.AppendElement(tUtilCapsField("FORM_TYPE", "hidden", "urn:xmpp:dataforms:softwareinfo"))
+	form.AppendElement(tUtilCapsField("ip_version", "text-multi", "ipv4", "ipv6"))
+	form.AppendElement(tUtilCapsField("os", "", "Mac"))
+	form.AppendElement(tUtilCapsField("os_version", "", "10.5.1"))
+	form.AppendElement(tUtilCapsField("software", "", "Psi"))
+	form.AppendElement(tUtilCapsField("software_version", "", "0.11"))
+	query.AppendElement(form)
+
+	s, err := verificationString(query)
+	require.Nil(t, err)
+	require.Equal(t, "client/pc/el/Ψ 0.11<client/pc/en/Psi 0.11<http://jabber.org/protocol/caps<http://jabber.org/protocol/disco#info<http://jabber.org/protocol/disco#items<http://jabber.org/protocol/muc<urn:xmpp:dataforms:softwareinfo<ip_version<ipv4<ipv6<os<Mac<os_version<10.5.1<software<Psi<software_version<0.11<", s)
+	require.Equal(t, "q07IKJEyjvHSyhy//CH0CxmKi8w=", hashVerificationString(s, "sha-1"))
+
+	// duplicated form type
+	query.AppendElement(form)
+	_, err = verificationString(query)
+	require.NotNil(t, err)
+}
+
+func TestVerificationString_Hash(t *testing.T) {
+	require.True(t, isSupportedHash("sha-1"))
+	require.True(t, isSupportedHash("sha-256"))
+	require.False(t, isSupportedHash("md5"))
+	require.Equal(t, "", hashVerificationString("foo<", "md5"))
+}
+
+func tUtilCapsSimpleQuery() *xmpp.Element {
+	query := xmpp.NewElementNamespace("query", discoInfoNamespace)
+	query.AppendElement(tUtilCapsIdentity("client", "pc", "", "Exodus 0.9.1"))
+	query.AppendElement(tUtilCapsFeature("http://jabber.org/protocol/caps"))
+	query.AppendElement(tUtilCapsFeature("http://jabber.org/protocol/disco#info"))
+	query.AppendElement(tUtilCapsFeature("http://jabber.org/protocol/disco#items"))
+	query.AppendElement(tUtilCapsFeature("http://jabber.org/protocol/muc"))
+	return query
+}
+
+func tUtilCapsIdentity(category, typ, lang, name string) xmpp.XElement {
+	identity := xmpp.NewElementName("identity")
+	identity.SetAttribute("category", category)
+	identity.SetAttribute("type", typ)
+	if len(lang) > 0 {
+		identity.SetAttribute("xml:lang", lang)
+	}
+	identity.SetAttribute("name", name)
+	return identity
+}
+
+func tUtilCapsFeature(feature string) xmpp.XElement {
+	f := xmpp.NewElementName("feature")
+	f.SetAttribute("var", feature)
+	return f
+}
+
+func tUtilCapsField(name, typ string, values ...string) xmpp.XElement {
+	field := xmpp.NewElementName("field")
+	field.SetAttribute("var", name)
+	if len(typ) > 0 {
+		field.SetAttribute("type", typ)
+	}
+	for _, v := range values {
+		value := xmpp.NewElementName("value")
+		value.SetText(v)
+		field.AppendElement(value)
+	}
+	return field
+}
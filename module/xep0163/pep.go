@@ -0,0 +1,621 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0163
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/capsmodel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/module/roster"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/module/xep0115"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const mailboxSize = 2048
+
+const defaultMaxItems = 1
+
+const (
+	pubSubNamespace       = "http://jabber.org/protocol/pubsub"
+	pubSubOwnerNamespace  = "http://jabber.org/protocol/pubsub#owner"
+	pubSubEventNamespace  = "http://jabber.org/protocol/pubsub#event"
+	pubSubErrorsNamespace = "http://jabber.org/protocol/pubsub#errors"
+)
+
+const (
+	accessModelField  = "pubsub#access_model"
+	maxItemsField     = "pubsub#max_items"
+	persistItemsField = "pubsub#persist_items"
+)
+
+var accountFeatures = []xep0030.Feature{
+	pubSubNamespace,
+	pubSubNamespace + "#access-open",
+	pubSubNamespace + "#access-presence",
+	pubSubNamespace + "#access-whitelist",
+	pubSubNamespace + "#auto-create",
+	pubSubNamespace + "#create-nodes",
+	pubSubNamespace + "#delete-nodes",
+	pubSubNamespace + "#filtered-notifications",
+	pubSubNamespace + "#last-published",
+	pubSubNamespace + "#persistent-items",
+	pubSubNamespace + "#publish",
+	pubSubNamespace + "#publish-options",
+	pubSubNamespace + "#retract-items",
+	pubSubNamespace + "#retrieve-items",
+}
+
+// Pep represents a Personal Eventing Protocol stream module.
+type Pep struct {
+	roster     *roster.Roster
+	caps       *xep0115.EntityCaps
+	capsID     int
+	disco      *xep0030.DiscoInfo
+	actorCh    chan func()
+	shutdownCh <-chan struct{}
+}
+
+// New returns a Personal Eventing Protocol IQ handler module.
+func New(disco *xep0030.DiscoInfo, roster *roster.Roster, caps *xep0115.EntityCaps, shutdownCh <-chan struct{}) *Pep {
+	x := &Pep{
+		roster:     roster,
+		caps:       caps,
+		disco:      disco,
+		actorCh:    make(chan func(), mailboxSize),
+		shutdownCh: shutdownCh,
+	}
+	go x.loop()
+	if caps != nil {
+		x.capsID = caps.RegisterCapabilitiesHandler(func(j *jid.JID, c *capsmodel.Capabilities) {
+			select {
+			case x.actorCh <- func() { x.deliverLastItems(j, c) }:
+			case <-shutdownCh:
+			}
+		})
+	}
+	if disco != nil {
+		disco.RegisterAccountIdentity(xep0030.Identity{Category: "pubsub", Type: "pep"})
+		for _, feature := range accountFeatures {
+			disco.RegisterAccountFeature(feature)
+		}
+	}
+	return x
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the Personal Eventing Protocol module.
+func (x *Pep) MatchesIQ(iq *xmpp.IQ) bool {
+	if !iq.IsGet() && !iq.IsSet() {
+		return false
+	}
+	toJID := iq.ToJID()
+	if !toJID.IsBare() || len(toJID.Node()) == 0 {
+		return false
+	}
+	e := iq.Elements()
+	return e.ChildNamespace("pubsub", pubSubNamespace) != nil || e.ChildNamespace("pubsub", pubSubOwnerNamespace) != nil
+}
+
+// ProcessIQ processes a Personal Eventing Protocol IQ
+// taking according actions over the associated stream.
+func (x *Pep) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
+	x.actorCh <- func() { x.processIQ(iq, stm) }
+}
+
+// UnregisterFeatures unregisters every feature announced by the module
+// through the disco info module.
+func (x *Pep) UnregisterFeatures() {
+	if x.disco != nil {
+		x.disco.UnregisterAccountIdentity(xep0030.Identity{Category: "pubsub", Type: "pep"})
+		for _, feature := range accountFeatures {
+			x.disco.UnregisterAccountFeature(feature)
+		}
+	}
+}
+
+// UnregisterHandlers unregisters every handler installed
+// by the module on the entity capabilities module.
+func (x *Pep) UnregisterHandlers() {
+	if x.caps != nil {
+		x.caps.UnregisterCapabilitiesHandler(x.capsID)
+	}
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *Pep) MailboxSize() int {
+	return len(x.actorCh)
+}
+
+// runs on it's own goroutine
+func (x *Pep) loop() {
+	for {
+		select {
+		case f := <-x.actorCh:
+			f()
+		case <-x.shutdownCh:
+			return
+		}
+	}
+}
+
+func (x *Pep) processIQ(iq *xmpp.IQ, stm stream.C2S) {
+	ownerJID := iq.ToJID().ToBareJID()
+	isOwner := iq.FromJID().Matches(ownerJID, jid.MatchesBare)
+
+	if ps := iq.Elements().ChildNamespace("pubsub", pubSubNamespace); ps != nil {
+		e := ps.Elements()
+		switch {
+		case iq.IsGet() && e.Child("items") != nil:
+			x.retrieveItems(ownerJID, iq, e.Child("items"), stm)
+		case iq.IsSet() && !isOwner:
+			stm.SendElement(iq.ForbiddenError())
+		case iq.IsSet() && e.Child("publish") != nil:
+			x.publishItem(ownerJID, iq, e.Child("publish"), e.Child("publish-options"), stm)
+		case iq.IsSet() && e.Child("retract") != nil:
+			x.retractItem(ownerJID, iq, e.Child("retract"), stm)
+		case iq.IsSet() && e.Child("create") != nil:
+			x.createNode(ownerJID, iq, e.Child("create"), e.Child("configure"), stm)
+		default:
+			stm.SendElement(iq.FeatureNotImplementedError())
+		}
+		return
+	}
+	ps := iq.Elements().ChildNamespace("pubsub", pubSubOwnerNamespace)
+	switch {
+	case !isOwner:
+		stm.SendElement(iq.ForbiddenError())
+	case iq.IsSet() && ps.Elements().Child("delete") != nil:
+		x.deleteNode(ownerJID, iq, ps.Elements().Child("delete"), stm)
+	default:
+		stm.SendElement(iq.FeatureNotImplementedError())
+	}
+}
+
+func (x *Pep) createNode(ownerJID *jid.JID, iq *xmpp.IQ, create, configure xmpp.XElement, stm stream.C2S) {
+	name := create.Attributes().Get("node")
+	if len(name) == 0 {
+		stm.SendElement(pubSubError(iq, xmpp.ErrNotAcceptable, "nodeid-required"))
+		return
+	}
+	n, err := storage.Instance().FetchPubSubNode(ownerJID.String(), name)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if n != nil {
+		stm.SendElement(iq.ConflictError())
+		return
+	}
+	n = newNode(ownerJID, name)
+	if configure != nil {
+		form, err := nodeOptionsForm(configure)
+		if err != nil || (form != nil && !applyOptionsForm(&n.Options, form)) {
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+	}
+	if err := storage.Instance().InsertOrUpdatePubSubNode(n); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (x *Pep) deleteNode(ownerJID *jid.JID, iq *xmpp.IQ, del xmpp.XElement, stm stream.C2S) {
+	n := x.loadNode(ownerJID, iq, del.Attributes().Get("node"), stm)
+	if n == nil {
+		return
+	}
+	if err := storage.Instance().DeletePubSubNode(n.Host, n.Name); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	d := xmpp.NewElementName("delete")
+	d.SetAttribute("node", n.Name)
+	x.notify(ownerJID, n, d)
+
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (x *Pep) publishItem(ownerJID *jid.JID, iq *xmpp.IQ, publish, publishOpts xmpp.XElement, stm stream.C2S) {
+	name := publish.Attributes().Get("node")
+	if len(name) == 0 {
+		stm.SendElement(pubSubError(iq, xmpp.ErrBadRequest, "nodeid-required"))
+		return
+	}
+	var optsForm *xep0004.DataForm
+	if publishOpts != nil {
+		form, err := nodeOptionsForm(publishOpts)
+		if err != nil {
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+		optsForm = form
+	}
+	n, err := storage.Instance().FetchPubSubNode(ownerJID.String(), name)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if n == nil {
+		// auto-create node
+		n = newNode(ownerJID, name)
+		if optsForm != nil && !applyOptionsForm(&n.Options, optsForm) {
+			stm.SendElement(pubSubError(iq, xmpp.ErrConflict, "precondition-not-met"))
+			return
+		}
+		if err := storage.Instance().InsertOrUpdatePubSubNode(n); err != nil {
+			log.Error(err)
+			stm.SendElement(iq.InternalServerError())
+			return
+		}
+	} else if optsForm != nil {
+		// publish options act as preconditions over already existing nodes
+		opts := n.Options
+		if !applyOptionsForm(&opts, optsForm) || opts != n.Options {
+			stm.SendElement(pubSubError(iq, xmpp.ErrConflict, "precondition-not-met"))
+			return
+		}
+	}
+	item := &pubsubmodel.Item{Publisher: ownerJID.String(), Stamp: time.Now()}
+	if itemElem := publish.Elements().Child("item"); itemElem != nil {
+		payloads := itemElem.Elements().All()
+		if len(payloads) > 1 {
+			stm.SendElement(pubSubError(iq, xmpp.ErrBadRequest, "invalid-payload"))
+			return
+		}
+		if len(payloads) == 1 {
+			item.Payload = payloads[0]
+		}
+		item.ID = itemElem.Attributes().Get("id")
+	}
+	if len(item.ID) == 0 {
+		item.ID = uuid.New()
+	}
+	if n.Options.PersistItems {
+		if err := storage.Instance().InsertOrUpdatePubSubNodeItem(item, n.Host, n.Name); err != nil {
+			log.Error(err)
+			stm.SendElement(iq.InternalServerError())
+			return
+		}
+		x.trimNodeItems(n)
+	}
+	x.notify(ownerJID, n, itemsElement(n, item))
+
+	itemRes := xmpp.NewElementName("item")
+	itemRes.SetAttribute("id", item.ID)
+	pub := xmpp.NewElementName("publish")
+	pub.SetAttribute("node", n.Name)
+	pub.AppendElement(itemRes)
+	ps := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+	ps.AppendElement(pub)
+	result := iq.ResultIQ()
+	result.AppendElement(ps)
+	stm.SendElement(result)
+}
+
+func (x *Pep) retractItem(ownerJID *jid.JID, iq *xmpp.IQ, retract xmpp.XElement, stm stream.C2S) {
+	n := x.loadNode(ownerJID, iq, retract.Attributes().Get("node"), stm)
+	if n == nil {
+		return
+	}
+	itemElem := retract.Elements().Child("item")
+	if itemElem == nil || len(itemElem.Attributes().Get("id")) == 0 {
+		stm.SendElement(pubSubError(iq, xmpp.ErrBadRequest, "item-required"))
+		return
+	}
+	itemID := itemElem.Attributes().Get("id")
+	if err := storage.Instance().DeletePubSubNodeItem(n.Host, n.Name, itemID); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if notify := retract.Attributes().Get("notify"); notify == "1" || notify == "true" {
+		r := xmpp.NewElementName("retract")
+		r.SetAttribute("id", itemID)
+		items := xmpp.NewElementName("items")
+		items.SetAttribute("node", n.Name)
+		items.AppendElement(r)
+		x.notify(ownerJID, n, items)
+	}
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (x *Pep) retrieveItems(ownerJID *jid.JID, iq *xmpp.IQ, itemsElem xmpp.XElement, stm stream.C2S) {
+	n := x.loadNode(ownerJID, iq, itemsElem.Attributes().Get("node"), stm)
+	if n == nil {
+		return
+	}
+	if !x.isAllowedToAccess(n, ownerJID, iq.FromJID()) {
+		switch n.Options.AccessModel {
+		case pubsubmodel.AccessModelPresence:
+			stm.SendElement(pubSubError(iq, xmpp.ErrNotAuthorized, "presence-subscription-required"))
+		default:
+			stm.SendElement(pubSubError(iq, xmpp.ErrNotAllowed, "closed-node"))
+		}
+		return
+	}
+	items, err := storage.Instance().FetchPubSubNodeItems(n.Host, n.Name)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	if reqItems := itemsElem.Elements().Children("item"); len(reqItems) > 0 {
+		ids := make(map[string]struct{}, len(reqItems))
+		for _, reqItem := range reqItems {
+			ids[reqItem.Attributes().Get("id")] = struct{}{}
+		}
+		var filtered []pubsubmodel.Item
+		for _, item := range items {
+			if _, ok := ids[item.ID]; ok {
+				filtered = append(filtered, item)
+			}
+		}
+		items = filtered
+	}
+	if maxItems, err := strconv.Atoi(itemsElem.Attributes().Get("max_items")); err == nil && maxItems >= 0 && maxItems < len(items) {
+		items = items[len(items)-maxItems:]
+	}
+	res := xmpp.NewElementName("items")
+	res.SetAttribute("node", n.Name)
+	for i := range items {
+		res.AppendElement(itemElement(&items[i]))
+	}
+	ps := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+	ps.AppendElement(res)
+	result := iq.ResultIQ()
+	result.AppendElement(ps)
+	stm.SendElement(result)
+}
+
+// deliverLastItems sends last published item of every node a resource is
+// interested in, including both its own and its roster contacts nodes.
+func (x *Pep) deliverLastItems(j *jid.JID, caps *capsmodel.Capabilities) {
+	ownerJIDs := []*jid.JID{j.ToBareJID()}
+	if host.IsLocalHost(j.Domain()) {
+		ris, _, err := storage.Instance().FetchRosterItems(j.Node())
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		for _, ri := range ris {
+			switch ri.Subscription {
+			case rostermodel.SubscriptionTo, rostermodel.SubscriptionBoth:
+				ownerJIDs = append(ownerJIDs, ri.ContactJID())
+			}
+		}
+	}
+	for _, ownerJID := range ownerJIDs {
+		nodes, err := storage.Instance().FetchPubSubNodes(ownerJID.String())
+		if err != nil {
+			log.Error(err)
+			continue
+		}
+		for i := range nodes {
+			n := &nodes[i]
+			if !caps.HasFeature(n.Name+"+notify") || !x.isAllowedToAccess(n, ownerJID, j) {
+				continue
+			}
+			items, err := storage.Instance().FetchPubSubNodeItems(n.Host, n.Name)
+			if err != nil {
+				log.Error(err)
+				continue
+			}
+			if len(items) == 0 {
+				continue
+			}
+			x.sendEvent(ownerJID, j, itemsElement(n, &items[len(items)-1]))
+		}
+	}
+}
+
+// notify sends an event notification to every available resource
+// of the node owner and its contacts interested in it.
+func (x *Pep) notify(ownerJID *jid.JID, n *pubsubmodel.Node, eventElem xmpp.XElement) {
+	if x.caps == nil {
+		return // no way to know who's interested
+	}
+	presences := x.onlinePresences(ownerJID)
+	if n.Options.AccessModel != pubsubmodel.AccessModelWhitelist {
+		ris, _, err := storage.Instance().FetchRosterItems(ownerJID.Node())
+		if err != nil {
+			log.Error(err)
+			return
+		}
+		for _, ri := range ris {
+			switch ri.Subscription {
+			case rostermodel.SubscriptionFrom, rostermodel.SubscriptionBoth:
+				presences = append(presences, x.onlinePresences(ri.ContactJID())...)
+			}
+		}
+	}
+	for _, p := range presences {
+		caps := x.caps.Capabilities(p)
+		if caps == nil || !caps.HasFeature(n.Name+"+notify") {
+			continue
+		}
+		x.sendEvent(ownerJID, p.FromJID(), eventElem)
+	}
+}
+
+func (x *Pep) sendEvent(ownerJID, toJID *jid.JID, eventElem xmpp.XElement) {
+	event := xmpp.NewElementNamespace("event", pubSubEventNamespace)
+	event.AppendElement(eventElem)
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.HeadlineType)
+	msg.SetFromJID(ownerJID)
+	msg.SetToJID(toJID)
+	msg.AppendElement(event)
+	router.Route(msg)
+}
+
+func (x *Pep) onlinePresences(j *jid.JID) []*xmpp.Presence {
+	if x.roster != nil {
+		return x.roster.OnlinePresencesMatchingJID(j)
+	}
+	// roster disabled
+	if !host.IsLocalHost(j.Domain()) {
+		return nil
+	}
+	var ret []*xmpp.Presence
+	for _, stm := range router.UserStreams(j.Node()) {
+		if p := stm.Presence(); p != nil && p.IsAvailable() {
+			ret = append(ret, p)
+		}
+	}
+	return ret
+}
+
+// isAllowedToAccess returns whether or not a JID is allowed
+// to retrieve items from a given node.
+func (x *Pep) isAllowedToAccess(n *pubsubmodel.Node, ownerJID, j *jid.JID) bool {
+	if j.Matches(ownerJID, jid.MatchesBare) {
+		return true
+	}
+	switch n.Options.AccessModel {
+	case pubsubmodel.AccessModelOpen:
+		return true
+	case pubsubmodel.AccessModelPresence:
+		ri, err := storage.Instance().FetchRosterItem(ownerJID.Node(), j.ToBareJID().String())
+		if err != nil {
+			log.Error(err)
+			return false
+		}
+		return ri != nil && (ri.Subscription == rostermodel.SubscriptionFrom || ri.Subscription == rostermodel.SubscriptionBoth)
+	}
+	return false
+}
+
+func (x *Pep) loadNode(ownerJID *jid.JID, iq *xmpp.IQ, name string, stm stream.C2S) *pubsubmodel.Node {
+	if len(name) == 0 {
+		stm.SendElement(pubSubError(iq, xmpp.ErrBadRequest, "nodeid-required"))
+		return nil
+	}
+	n, err := storage.Instance().FetchPubSubNode(ownerJID.String(), name)
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return nil
+	}
+	if n == nil {
+		stm.SendElement(iq.ItemNotFoundError())
+		return nil
+	}
+	return n
+}
+
+// trimNodeItems removes oldest node items beyond its max items limit.
+func (x *Pep) trimNodeItems(n *pubsubmodel.Node) {
+	if n.Options.MaxItems == 0 {
+		return
+	}
+	items, err := storage.Instance().FetchPubSubNodeItems(n.Host, n.Name)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	for i := 0; i < len(items)-n.Options.MaxItems; i++ {
+		if err := storage.Instance().DeletePubSubNodeItem(n.Host, n.Name, items[i].ID); err != nil {
+			log.Error(err)
+		}
+	}
+}
+
+func newNode(ownerJID *jid.JID, name string) *pubsubmodel.Node {
+	n := &pubsubmodel.Node{
+		Host: ownerJID.String(),
+		Name: name,
+		Options: pubsubmodel.Options{
+			AccessModel:     pubsubmodel.AccessModelPresence,
+			MaxItems:        defaultMaxItems,
+			PersistItems:    true,
+			DeliverPayloads: true,
+			NotifyRetract:   true,
+			NotifyDelete:    true,
+		},
+	}
+	n.SetAffiliation(ownerJID.String(), pubsubmodel.AffiliationOwner)
+	return n
+}
+
+func nodeOptionsForm(elem xmpp.XElement) (*xep0004.DataForm, error) {
+	formElem := elem.Elements().ChildNamespace("x", "jabber:x:data")
+	if formElem == nil {
+		return nil, nil
+	}
+	return xep0004.NewFormFromElement(formElem)
+}
+
+func applyOptionsForm(opts *pubsubmodel.Options, form *xep0004.DataForm) bool {
+	for _, field := range form.Fields {
+		var value string
+		if len(field.Values) > 0 {
+			value = field.Values[0]
+		}
+		switch field.Var {
+		case accessModelField:
+			switch value {
+			case pubsubmodel.AccessModelOpen, pubsubmodel.AccessModelPresence, pubsubmodel.AccessModelWhitelist:
+				opts.AccessModel = value
+			default:
+				return false
+			}
+		case maxItemsField:
+			if value == "max" {
+				opts.MaxItems = 0
+				continue
+			}
+			maxItems, err := strconv.Atoi(value)
+			if err != nil || maxItems < 0 {
+				return false
+			}
+			opts.MaxItems = maxItems
+		case persistItemsField:
+			opts.PersistItems = value == "1" || value == "true"
+		}
+	}
+	return true
+}
+
+func itemsElement(n *pubsubmodel.Node, item *pubsubmodel.Item) xmpp.XElement {
+	items := xmpp.NewElementName("items")
+	items.SetAttribute("node", n.Name)
+	items.AppendElement(itemElement(item))
+	return items
+}
+
+func itemElement(item *pubsubmodel.Item) xmpp.XElement {
+	e := xmpp.NewElementName("item")
+	e.SetAttribute("id", item.ID)
+	if item.Payload != nil {
+		e.AppendElement(item.Payload)
+	}
+	return e
+}
+
+func pubSubError(iq *xmpp.IQ, stanzaErr *xmpp.StanzaError, condition string) xmpp.Stanza {
+	return xmpp.NewErrorStanzaFromStanza(iq, stanzaErr, []xmpp.XElement{
+		xmpp.NewElementNamespace(condition, pubSubErrorsNamespace),
+	})
+}
@@ -0,0 +1,262 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0163
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model/capsmodel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/module/xep0115"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0163_Matching(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	x := New(nil, nil, nil, nil)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.AppendElement(xmpp.NewElementNamespace("pubsub", pubSubNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	// addressed to the server
+	srvJID, _ := jid.New("", "jackal.im", "", true)
+	iq.SetToJID(srvJID)
+	require.False(t, x.MatchesIQ(iq))
+
+	iq2 := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq2.SetFromJID(j)
+	iq2.SetToJID(j.ToBareJID())
+	iq2.AppendElement(xmpp.NewElementNamespace("pubsub", pubSubOwnerNamespace))
+	require.True(t, x.MatchesIQ(iq2))
+
+	iq2.SetType(xmpp.ResultType)
+	require.False(t, x.MatchesIQ(iq2))
+}
+
+func TestXEP0163_PublishAndRetrieve(t *testing.T) {
+	tUtilPepInitialize()
+	defer tUtilPepShutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	j3, _ := jid.New("romeo", "jackal.im", "garden", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm3 := stream.NewMockC2S(uuid.New(), j3)
+
+	x := New(nil, nil, nil, nil)
+
+	// publish on someone else's account
+	x.ProcessIQ(tUtilPepPublishIQ(j2, j1.ToBareJID(), "urn:xmpp:avatar:metadata", "a1"), stm2)
+	elem := stm2.FetchElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	// auto-create node
+	x.ProcessIQ(tUtilPepPublishIQ(j1, j1.ToBareJID(), "urn:xmpp:avatar:metadata", "a1"), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	n, _ := storage.Instance().FetchPubSubNode("ortuman@jackal.im", "urn:xmpp:avatar:metadata")
+	require.NotNil(t, n)
+	require.Equal(t, pubsubmodel.AccessModelPresence, n.Options.AccessModel)
+	require.Equal(t, pubsubmodel.AffiliationOwner, n.Affiliation("ortuman@jackal.im"))
+
+	// replace last item
+	x.ProcessIQ(tUtilPepPublishIQ(j1, j1.ToBareJID(), "urn:xmpp:avatar:metadata", "a2"), stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	items, _ := storage.Instance().FetchPubSubNodeItems("ortuman@jackal.im", "urn:xmpp:avatar:metadata")
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "a2", items[0].ID)
+
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     "ortuman",
+		JID:          "noelia@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+
+	// presence subscribed contact
+	x.ProcessIQ(tUtilPepItemsIQ(j2, j1.ToBareJID(), "urn:xmpp:avatar:metadata"), stm2)
+	elem = stm2.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	itemsElem := elem.Elements().ChildNamespace("pubsub", pubSubNamespace).Elements().Child("items")
+	require.Equal(t, 1, len(itemsElem.Elements().Children("item")))
+	require.Equal(t, "a2", itemsElem.Elements().Child("item").Attributes().Get("id"))
+
+	// not subscribed entity
+	x.ProcessIQ(tUtilPepItemsIQ(j3, j1.ToBareJID(), "urn:xmpp:avatar:metadata"), stm3)
+	elem = stm3.FetchElement()
+	require.Equal(t, xmpp.ErrNotAuthorized.Error(), elem.Error().Elements().All()[0].Name())
+
+	// publish options preconditions
+	iq := tUtilPepPublishIQ(j1, j1.ToBareJID(), "urn:xmpp:avatar:metadata", "a3")
+	iq.Elements().ChildNamespace("pubsub", pubSubNamespace).(*xmpp.Element).AppendElement(tUtilPepPublishOptions(pubsubmodel.AccessModelOpen))
+	x.ProcessIQ(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ErrConflict.Error(), elem.Error().Elements().All()[0].Name())
+	require.NotNil(t, elem.Error().Elements().ChildNamespace("precondition-not-met", pubSubErrorsNamespace))
+
+	iq = tUtilPepPublishIQ(j1, j1.ToBareJID(), "eu.siacs.conversations.axolotl.devicelist", "current")
+	iq.Elements().ChildNamespace("pubsub", pubSubNamespace).(*xmpp.Element).AppendElement(tUtilPepPublishOptions(pubsubmodel.AccessModelOpen))
+	x.ProcessIQ(iq, stm1)
+	elem = stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	x.ProcessIQ(tUtilPepItemsIQ(j3, j1.ToBareJID(), "eu.siacs.conversations.axolotl.devicelist"), stm3)
+	elem = stm3.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+}
+
+func TestXEP0163_Notify(t *testing.T) {
+	tUtilPepInitialize()
+	defer tUtilPepShutdown()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "yard", true)
+	j3, _ := jid.New("noelia", "jackal.im", "garden", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm3 := stream.NewMockC2S(uuid.New(), j3)
+	router.Bind(stm1)
+	router.Bind(stm2)
+	router.Bind(stm3)
+	defer router.Unbind(stm1)
+	defer router.Unbind(stm2)
+	defer router.Unbind(stm3)
+
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     "ortuman",
+		JID:          "noelia@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     "noelia",
+		JID:          "ortuman@jackal.im",
+		Subscription: rostermodel.SubscriptionBoth,
+	})
+	caps := &capsmodel.Capabilities{
+		Node:     "http://code.google.com/p/exodus",
+		Ver:      "QgayPKawpkPSDYmwT/WM94uAlu0=",
+		Features: []string{"http://jabber.org/protocol/tune", "http://jabber.org/protocol/tune+notify"},
+	}
+	storage.Instance().InsertOrUpdateCapabilities(caps)
+
+	stm1.SetPresence(xmpp.NewPresence(j1, j1.ToBareJID(), xmpp.AvailableType))
+	stm2.SetPresence(tUtilPepCapsPresence(j2, caps))
+	stm3.SetPresence(xmpp.NewPresence(j3, j3.ToBareJID(), xmpp.AvailableType))
+
+	ec := xep0115.New(nil)
+	x := New(nil, nil, ec, nil)
+
+	x.ProcessIQ(tUtilPepPublishIQ(j1, j1.ToBareJID(), "http://jabber.org/protocol/tune", "t1"), stm1)
+	elem := stm1.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	// only interested resources get notified
+	elem = stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, "ortuman@jackal.im", elem.From())
+	items := elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, "http://jabber.org/protocol/tune", items.Attributes().Get("node"))
+	require.Equal(t, "t1", items.Elements().Child("item").Attributes().Get("id"))
+
+	// last published item delivery on capabilities discovery
+	ec.ProcessPresence(tUtilPepCapsPresence(j3, caps))
+
+	elem = stm3.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	items = elem.Elements().ChildNamespace("event", pubSubEventNamespace).Elements().Child("items")
+	require.Equal(t, "t1", items.Elements().Child("item").Attributes().Get("id"))
+}
+
+func tUtilPepInitialize() {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+}
+
+func tUtilPepShutdown() {
+	router.Shutdown()
+	storage.Shutdown()
+	host.Shutdown()
+}
+
+func tUtilPepCapsPresence(j *jid.JID, caps *capsmodel.Capabilities) *xmpp.Presence {
+	p := xmpp.NewPresence(j, j.ToBareJID(), xmpp.AvailableType)
+	c := xmpp.NewElementNamespace("c", "http://jabber.org/protocol/caps")
+	c.SetAttribute("hash", "sha-1")
+	c.SetAttribute("node", caps.Node)
+	c.SetAttribute("ver", caps.Ver)
+	p.AppendElement(c)
+	return p
+}
+
+func tUtilPepPublishIQ(from, to *jid.JID, node, itemID string) *xmpp.IQ {
+	tune := xmpp.NewElementNamespace("tune", "http://jabber.org/protocol/tune")
+	item := xmpp.NewElementName("item")
+	item.SetAttribute("id", itemID)
+	item.AppendElement(tune)
+	publish := xmpp.NewElementName("publish")
+	publish.SetAttribute("node", node)
+	publish.AppendElement(item)
+	ps := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+	ps.AppendElement(publish)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+	iq.AppendElement(ps)
+	return iq
+}
+
+func tUtilPepItemsIQ(from, to *jid.JID, node string) *xmpp.IQ {
+	items := xmpp.NewElementName("items")
+	items.SetAttribute("node", node)
+	ps := xmpp.NewElementNamespace("pubsub", pubSubNamespace)
+	ps.AppendElement(items)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(to)
+	iq.AppendElement(ps)
+	return iq
+}
+
+func tUtilPepPublishOptions(accessModel string) xmpp.XElement {
+	form := xmpp.NewElementNamespace("x", "jabber:x:data")
+	form.SetAttribute("type", "submit")
+	for _, fv := range [][2]string{
+		{"FORM_TYPE", "http://jabber.org/protocol/pubsub#publish-options"},
+		{accessModelField, accessModel},
+	} {
+		field := xmpp.NewElementName("field")
+		field.SetAttribute("var", fv[0])
+		value := xmpp.NewElementName("value")
+		value.SetText(fv[1])
+		field.AppendElement(value)
+		form.AppendElement(field)
+	}
+	opts := xmpp.NewElementName("publish-options")
+	opts.AppendElement(form)
+	return opts
+}
@@ -30,6 +30,7 @@ const (
 // BlockingCommand returns a blocking command IQ handler module.
 type BlockingCommand struct {
 	roster     *roster.Roster
+	disco      *xep0030.DiscoInfo
 	actorCh    chan func()
 	shutdownCh <-chan struct{}
 }
@@ -38,6 +39,7 @@ type BlockingCommand struct {
 func New(disco *xep0030.DiscoInfo, roster *roster.Roster, shutdownCh <-chan struct{}) *BlockingCommand {
 	b := &BlockingCommand{
 		roster:     roster,
+		disco:      disco,
 		actorCh:    make(chan func(), mailboxSize),
 		shutdownCh: shutdownCh,
 	}
@@ -65,6 +67,21 @@ func (x *BlockingCommand) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
 	x.actorCh <- func() { x.processIQ(iq, stm) }
 }
 
+// UnregisterFeatures unregisters every feature announced by the module
+// through the disco info module.
+func (x *BlockingCommand) UnregisterFeatures() {
+	if x.disco != nil {
+		x.disco.UnregisterServerFeature(blockingCommandNamespace)
+		x.disco.UnregisterAccountFeature(blockingCommandNamespace)
+	}
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *BlockingCommand) MailboxSize() int {
+	return len(x.actorCh)
+}
+
 // runs on it's own goroutine
 func (x *BlockingCommand) loop() {
 	for {
@@ -58,6 +58,7 @@ type Ping struct {
 	cfg         *Config
 	pings       map[string]*ping
 	activePings map[string]*ping
+	disco       *xep0030.DiscoInfo
 	actorCh     chan func()
 	shutdownCh  <-chan struct{}
 }
@@ -68,6 +69,7 @@ func New(config *Config, disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *
 		cfg:         config,
 		pings:       make(map[string]*ping),
 		activePings: make(map[string]*ping),
+		disco:       disco,
 		actorCh:     make(chan func(), mailboxSize),
 		shutdownCh:  shutdownCh,
 	}
@@ -102,6 +104,27 @@ func (x *Ping) CancelPing(stm stream.C2S) {
 	x.actorCh <- func() { x.cancelPing(stm) }
 }
 
+// SetConfig replaces module configuration, applying it
+// to every ping scheduled from now on.
+func (x *Ping) SetConfig(cfg *Config) {
+	x.actorCh <- func() { x.setConfig(cfg) }
+}
+
+// UnregisterFeatures unregisters every feature announced by the module
+// through the disco info module.
+func (x *Ping) UnregisterFeatures() {
+	if x.disco != nil {
+		x.disco.UnregisterServerFeature(pingNamespace)
+		x.disco.UnregisterAccountFeature(pingNamespace)
+	}
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *Ping) MailboxSize() int {
+	return len(x.actorCh)
+}
+
 // runs on it's own goroutine
 func (x *Ping) loop() {
 	for {
@@ -141,6 +164,18 @@ func (x *Ping) processIQ(iq *xmpp.IQ, stm stream.C2S) {
 	}
 }
 
+func (x *Ping) setConfig(cfg *Config) {
+	if !cfg.Send {
+		// stop pinging...
+		for _, pi := range x.pings {
+			pi.timer.Stop()
+		}
+		x.pings = make(map[string]*ping)
+		x.activePings = make(map[string]*ping)
+	}
+	x.cfg = cfg
+}
+
 func (x *Ping) schedulePing(stm stream.C2S) {
 	if !x.cfg.Send || !stm.JID().IsFull() {
 		return
@@ -0,0 +1,191 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0280
+
+import (
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+const mailboxSize = 2048
+
+const (
+	carbonsNamespace = "urn:xmpp:carbons:2"
+	forwardNamespace = "urn:xmpp:forward:0"
+	hintsNamespace   = "urn:xmpp:hints"
+)
+
+const carbonsEnabledCtxKey = "carbons:enabled"
+
+// Carbons represents a message carbons stream module.
+type Carbons struct {
+	disco      *xep0030.DiscoInfo
+	actorCh    chan func()
+	shutdownCh <-chan struct{}
+}
+
+// New returns a message carbons IQ handler module.
+func New(disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *Carbons {
+	x := &Carbons{
+		disco:      disco,
+		actorCh:    make(chan func(), mailboxSize),
+		shutdownCh: shutdownCh,
+	}
+	go x.loop()
+	if disco != nil {
+		disco.RegisterServerFeature(carbonsNamespace)
+	}
+	return x
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the message carbons module.
+func (x *Carbons) MatchesIQ(iq *xmpp.IQ) bool {
+	if !iq.IsSet() {
+		return false
+	}
+	e := iq.Elements()
+	return e.ChildNamespace("enable", carbonsNamespace) != nil || e.ChildNamespace("disable", carbonsNamespace) != nil
+}
+
+// ProcessIQ processes a message carbons IQ taking
+// according actions over the associated stream.
+func (x *Carbons) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
+	x.actorCh <- func() { x.processIQ(iq, stm) }
+}
+
+// ProcessMessage forwards a copy of an already routed message
+// to every other carbons enabled resource of both sender and recipient.
+func (x *Carbons) ProcessMessage(message *xmpp.Message) {
+	x.actorCh <- func() { x.processMessage(message) }
+}
+
+// UnregisterFeatures unregisters every feature announced by the module
+// through the disco info module.
+func (x *Carbons) UnregisterFeatures() {
+	if x.disco != nil {
+		x.disco.UnregisterServerFeature(carbonsNamespace)
+	}
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *Carbons) MailboxSize() int {
+	return len(x.actorCh)
+}
+
+// runs on it's own goroutine
+func (x *Carbons) loop() {
+	for {
+		select {
+		case f := <-x.actorCh:
+			f()
+		case <-x.shutdownCh:
+			return
+		}
+	}
+}
+
+func (x *Carbons) processIQ(iq *xmpp.IQ, stm stream.C2S) {
+	if !iq.ToJID().IsServer() && !iq.ToJID().Matches(stm.JID(), jid.MatchesBare) {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	enabled := iq.Elements().ChildNamespace("enable", carbonsNamespace) != nil
+	stm.Context().SetBool(enabled, carbonsEnabledCtxKey)
+	stm.SendElement(iq.ResultIQ())
+}
+
+func (x *Carbons) processMessage(message *xmpp.Message) {
+	if !isMessageCopyable(message) {
+		return
+	}
+	fromJID := message.FromJID()
+	toJID := message.ToJID()
+
+	if fromJID.IsFullWithUser() && host.IsLocalHost(fromJID.Domain()) {
+		stms := router.UserStreams(fromJID.Node())
+		for _, stm := range stms {
+			if stm.Resource() == fromJID.Resource() {
+				continue
+			}
+			x.sendCopy(message, "sent", stm)
+		}
+	}
+	if !toJID.IsServer() && host.IsLocalHost(toJID.Domain()) {
+		stms := router.UserStreams(toJID.Node())
+		var rcpStm stream.C2S
+		if toJID.IsFullWithUser() {
+			for _, stm := range stms {
+				if stm.Resource() == toJID.Resource() {
+					rcpStm = stm
+					break
+				}
+			}
+		} else {
+			rcpStm = highestPriorityStream(stms)
+		}
+		for _, stm := range stms {
+			if stm == rcpStm {
+				continue
+			}
+			x.sendCopy(message, "received", stm)
+		}
+	}
+}
+
+func (x *Carbons) sendCopy(message *xmpp.Message, wrapperName string, stm stream.C2S) {
+	if !stm.Context().Bool(carbonsEnabledCtxKey) {
+		return
+	}
+	forwarded := xmpp.NewElementNamespace("forwarded", forwardNamespace)
+	forwarded.AppendElement(message)
+
+	wrapper := xmpp.NewElementNamespace(wrapperName, carbonsNamespace)
+	wrapper.AppendElement(forwarded)
+
+	msg := xmpp.NewMessageType(message.ID(), message.Type())
+	msg.SetFromJID(stm.JID().ToBareJID())
+	msg.SetToJID(stm.JID())
+	msg.AppendElement(wrapper)
+	stm.SendElement(msg)
+}
+
+// highestPriorityStream returns the stream that would have
+// received a message addressed to a bare JID.
+func highestPriorityStream(stms []stream.C2S) stream.C2S {
+	if len(stms) == 0 {
+		return nil
+	}
+	stm := stms[0]
+	var highestPriority int8
+	if p := stm.Presence(); p != nil {
+		highestPriority = p.Priority()
+	}
+	for i := 1; i < len(stms); i++ {
+		if p := stms[i].Presence(); p != nil && p.Priority() > highestPriority {
+			stm = stms[i]
+			highestPriority = p.Priority()
+		}
+	}
+	return stm
+}
+
+func isMessageCopyable(message *xmpp.Message) bool {
+	if !message.IsChat() {
+		return false
+	}
+	e := message.Elements()
+	if e.ChildNamespace("private", carbonsNamespace) != nil || e.ChildNamespace("no-copy", hintsNamespace) != nil {
+		return false
+	}
+	// don't copy already forwarded carbons
+	return e.ChildNamespace("sent", carbonsNamespace) == nil && e.ChildNamespace("received", carbonsNamespace) == nil
+}
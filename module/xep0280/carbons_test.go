@@ -0,0 +1,149 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0280
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/router"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0280_Matching(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	x := New(nil, nil)
+
+	// test MatchesIQ
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.AppendElement(xmpp.NewElementNamespace("enable", carbonsNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq2 := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq2.AppendElement(xmpp.NewElementNamespace("disable", carbonsNamespace))
+	require.True(t, x.MatchesIQ(iq2))
+
+	iq2.SetType(xmpp.GetType)
+	require.False(t, x.MatchesIQ(iq2))
+}
+
+func TestXEP0280_EnableDisable(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j)
+	defer stm.Disconnect(nil)
+
+	x := New(nil, nil)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j2)
+	iq.AppendElement(xmpp.NewElementNamespace("enable", carbonsNamespace))
+	x.ProcessIQ(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xmpp.ErrForbidden.Error(), elem.Error().Elements().All()[0].Name())
+
+	iq.SetToJID(j.ToBareJID())
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.True(t, stm.Context().Bool(carbonsEnabledCtxKey))
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("disable", carbonsNamespace))
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.False(t, stm.Context().Bool(carbonsEnabledCtxKey))
+}
+
+func TestXEP0280_ProcessMessage(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("ortuman", "jackal.im", "garden", true)
+	j3, _ := jid.New("noelia", "jackal.im", "yard", true)
+	j4, _ := jid.New("noelia", "jackal.im", "hall", true)
+
+	stm1 := stream.NewMockC2S(uuid.New(), j1)
+	stm2 := stream.NewMockC2S(uuid.New(), j2)
+	stm3 := stream.NewMockC2S(uuid.New(), j3)
+	stm4 := stream.NewMockC2S(uuid.New(), j4)
+	for _, stm := range []*stream.MockC2S{stm1, stm2, stm3, stm4} {
+		stm.SetPresence(xmpp.NewPresence(stm.JID(), stm.JID().ToBareJID(), xmpp.AvailableType))
+		stm.Context().SetBool(true, carbonsEnabledCtxKey)
+		router.Bind(stm)
+		defer stm.Disconnect(nil)
+	}
+
+	x := New(nil, nil)
+
+	// private message
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j3.ToBareJID())
+	msg.AppendElement(xmpp.NewElementNamespace("private", carbonsNamespace))
+	x.ProcessMessage(msg)
+
+	msg2 := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg2.SetFromJID(j1)
+	msg2.SetToJID(j3.ToBareJID())
+	x.ProcessMessage(msg2)
+
+	// sent copy
+	elem := stm2.FetchElement()
+	require.Equal(t, "message", elem.Name())
+	require.Equal(t, msg2.ID(), elem.ID())
+	require.Equal(t, j2.ToBareJID().String(), elem.From())
+	sent := elem.Elements().ChildNamespace("sent", carbonsNamespace)
+	require.NotNil(t, sent)
+	require.NotNil(t, sent.Elements().ChildNamespace("forwarded", forwardNamespace))
+
+	// received copy (highest priority stream got the original)
+	elem = stm4.FetchElement()
+	require.Equal(t, msg2.ID(), elem.ID())
+	require.NotNil(t, elem.Elements().ChildNamespace("received", carbonsNamespace))
+
+	// no-copy hint
+	msg3 := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg3.SetFromJID(j3)
+	msg3.SetToJID(j1)
+	msg3.AppendElement(xmpp.NewElementNamespace("no-copy", hintsNamespace))
+	x.ProcessMessage(msg3)
+
+	msg4 := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg4.SetFromJID(j3)
+	msg4.SetToJID(j1)
+	x.ProcessMessage(msg4)
+
+	elem = stm4.FetchElement()
+	require.Equal(t, msg4.ID(), elem.ID())
+	require.NotNil(t, elem.Elements().ChildNamespace("sent", carbonsNamespace))
+
+	elem = stm2.FetchElement()
+	require.Equal(t, msg4.ID(), elem.ID())
+	require.NotNil(t, elem.Elements().ChildNamespace("received", carbonsNamespace))
+}
@@ -0,0 +1,503 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0030"
+	"github.com/ortuman/jackal/module/xep0059"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+)
+
+const mailboxSize = 2048
+
+const (
+	mamNamespace     = "urn:xmpp:mam:2"
+	forwardNamespace = "urn:xmpp:forward:0"
+	delayNamespace   = "urn:xmpp:delay"
+)
+
+const defaultMaxPageSize = 50
+
+// Config represents Message Archive Management module (XEP-0313) configuration.
+type Config struct {
+	Default     string
+	MaxPageSize int
+}
+
+type configProxy struct {
+	Default     string `yaml:"default"`
+	MaxPageSize int    `yaml:"max_page_size"`
+}
+
+// UnmarshalYAML satisfies Unmarshaler interface.
+func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	p := configProxy{}
+	if err := unmarshal(&p); err != nil {
+		return err
+	}
+	switch p.Default {
+	case "":
+		c.Default = mammodel.DefaultAlways
+	case mammodel.DefaultAlways, mammodel.DefaultNever, mammodel.DefaultRoster:
+		c.Default = p.Default
+	default:
+		return fmt.Errorf("xep0313.Config: unrecognized default archiving mode: %s", p.Default)
+	}
+	c.MaxPageSize = p.MaxPageSize
+	if c.MaxPageSize < 0 {
+		return fmt.Errorf("xep0313.Config: max page size must be 0 or higher")
+	}
+	if c.MaxPageSize == 0 {
+		c.MaxPageSize = defaultMaxPageSize
+	}
+	return nil
+}
+
+// Mam represents a message archive management server stream module.
+type Mam struct {
+	cfg        *Config
+	disco      *xep0030.DiscoInfo
+	actorCh    chan func()
+	shutdownCh <-chan struct{}
+}
+
+// New returns a message archive management IQ handler module.
+func New(config *Config, disco *xep0030.DiscoInfo, shutdownCh <-chan struct{}) *Mam {
+	x := &Mam{
+		cfg:        withDefaults(config),
+		disco:      disco,
+		actorCh:    make(chan func(), mailboxSize),
+		shutdownCh: shutdownCh,
+	}
+	go x.loop()
+	if disco != nil {
+		disco.RegisterAccountFeature(mamNamespace)
+	}
+	return x
+}
+
+// MatchesIQ returns whether or not an IQ should be
+// processed by the message archive management module.
+func (x *Mam) MatchesIQ(iq *xmpp.IQ) bool {
+	e := iq.Elements()
+	return e.ChildNamespace("query", mamNamespace) != nil || e.ChildNamespace("prefs", mamNamespace) != nil
+}
+
+// ProcessIQ processes a message archive management IQ
+// taking according actions over the associated stream.
+func (x *Mam) ProcessIQ(iq *xmpp.IQ, stm stream.C2S) {
+	x.actorCh <- func() { x.processIQ(iq, stm) }
+}
+
+// ArchiveMessage stores a routed message into sender and recipient
+// archives, as long as they're local users and their archiving
+// preferences allow it.
+func (x *Mam) ArchiveMessage(message *xmpp.Message) {
+	x.actorCh <- func() { x.archiveMessage(message) }
+}
+
+// SetConfig replaces module configuration, applying it
+// to every request processed from now on.
+func (x *Mam) SetConfig(config *Config) {
+	cfg := withDefaults(config)
+	x.actorCh <- func() { x.cfg = cfg }
+}
+
+// UnregisterFeatures unregisters every feature announced by the module
+// through the disco info module.
+func (x *Mam) UnregisterFeatures() {
+	if x.disco != nil {
+		x.disco.UnregisterAccountFeature(mamNamespace)
+	}
+}
+
+// MailboxSize returns the number of requests waiting
+// to be processed by the module.
+func (x *Mam) MailboxSize() int {
+	return len(x.actorCh)
+}
+
+func withDefaults(config *Config) *Config {
+	cfg := *config
+	if len(cfg.Default) == 0 {
+		cfg.Default = mammodel.DefaultAlways
+	}
+	if cfg.MaxPageSize == 0 {
+		cfg.MaxPageSize = defaultMaxPageSize
+	}
+	return &cfg
+}
+
+// runs on it's own goroutine
+func (x *Mam) loop() {
+	for {
+		select {
+		case f := <-x.actorCh:
+			f()
+		case <-x.shutdownCh:
+			return
+		}
+	}
+}
+
+func (x *Mam) processIQ(iq *xmpp.IQ, stm stream.C2S) {
+	toJID := iq.ToJID()
+	if !toJID.IsServer() && toJID.Node() != stm.Username() {
+		stm.SendElement(iq.ForbiddenError())
+		return
+	}
+	if query := iq.Elements().ChildNamespace("query", mamNamespace); query != nil {
+		if iq.IsGet() {
+			x.sendQueryForm(iq, stm)
+		} else if iq.IsSet() {
+			x.processQuery(iq, query, stm)
+		}
+		return
+	}
+	if prefs := iq.Elements().ChildNamespace("prefs", mamNamespace); prefs != nil {
+		if iq.IsGet() {
+			x.sendPrefs(iq, stm)
+		} else if iq.IsSet() {
+			x.setPrefs(iq, prefs, stm)
+		}
+		return
+	}
+	stm.SendElement(iq.BadRequestError())
+}
+
+func (x *Mam) archiveMessage(message *xmpp.Message) {
+	if !isMessageArchivable(message) {
+		return
+	}
+	fromJID := message.FromJID()
+	toJID := message.ToJID()
+	stamp := time.Now()
+
+	if host.IsLocalHost(fromJID.Domain()) && len(fromJID.Node()) > 0 {
+		x.archive(fromJID.Node(), toJID.ToBareJID(), message, stamp)
+	}
+	if host.IsLocalHost(toJID.Domain()) && len(toJID.Node()) > 0 && !toJID.Matches(fromJID, jid.MatchesNode|jid.MatchesDomain) {
+		x.archive(toJID.Node(), fromJID.ToBareJID(), message, stamp)
+	}
+}
+
+func (x *Mam) archive(username string, with *jid.JID, message *xmpp.Message, stamp time.Time) {
+	archivable, err := x.isArchivable(username, with)
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	if !archivable {
+		return
+	}
+	am := &mammodel.Message{
+		ID:       uuid.New(),
+		Username: username,
+		With:     with.String(),
+		Message:  message,
+		Stamp:    stamp,
+	}
+	if err := storage.Instance().InsertArchiveMessage(am); err != nil {
+		log.Error(err)
+		return
+	}
+	log.Infof("archived message... (%s) id: %s", username, message.ID())
+}
+
+func (x *Mam) isArchivable(username string, with *jid.JID) (bool, error) {
+	prefs, err := x.fetchPrefs(username)
+	if err != nil {
+		return false, err
+	}
+	withJID := with.String()
+	for _, j := range prefs.Never {
+		if j == withJID {
+			return false, nil
+		}
+	}
+	for _, j := range prefs.Always {
+		if j == withJID {
+			return true, nil
+		}
+	}
+	switch prefs.Default {
+	case mammodel.DefaultAlways:
+		return true, nil
+	case mammodel.DefaultRoster:
+		ri, err := storage.Instance().FetchRosterItem(username, withJID)
+		if err != nil {
+			return false, err
+		}
+		return ri != nil, nil
+	default:
+		return false, nil
+	}
+}
+
+func (x *Mam) sendQueryForm(iq *xmpp.IQ, stm stream.C2S) {
+	form := &xep0004.DataForm{
+		Type: xep0004.Form,
+		Fields: []xep0004.Field{
+			{Var: "FORM_TYPE", Type: xep0004.Hidden, Values: []string{mamNamespace}},
+			{Var: "with", Type: xep0004.JidSingle},
+			{Var: "start", Type: xep0004.TextSingle},
+			{Var: "end", Type: xep0004.TextSingle},
+		},
+	}
+	query := xmpp.NewElementNamespace("query", mamNamespace)
+	query.AppendElement(form.Element())
+
+	result := iq.ResultIQ()
+	result.AppendElement(query)
+	stm.SendElement(result)
+}
+
+func (x *Mam) processQuery(iq *xmpp.IQ, query xmpp.XElement, stm stream.C2S) {
+	filter := &mammodel.Filter{}
+	if formElem := query.Elements().ChildNamespace("x", "jabber:x:data"); formElem != nil {
+		form, err := xep0004.NewFormFromElement(formElem)
+		if err != nil {
+			log.Error(err)
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+		filter, err = filterFromForm(form)
+		if err != nil {
+			log.Error(err)
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+	}
+	req := &xep0059.Request{Max: -1}
+	if setElem := query.Elements().ChildNamespace("set", xep0059.RSMNamespace); setElem != nil {
+		var err error
+		req, err = xep0059.NewRequestFromElement(setElem)
+		if err != nil {
+			log.Error(err)
+			stm.SendElement(iq.BadRequestError())
+			return
+		}
+	}
+	max := x.cfg.MaxPageSize
+	if req.Max >= 0 && req.Max < max {
+		max = req.Max
+	}
+	filter.After = req.After
+	filter.Before = req.Before
+	filter.Backwards = req.IsBackwards()
+
+	indexed := len(req.After) == 0 && !filter.Backwards
+	if indexed {
+		filter.Offset = req.Index
+	}
+	filter.Limit = max + 1 // one extra message tells whether the page is the last one
+
+	userJID := stm.JID()
+	msgs, err := storage.Instance().FetchArchiveMessages(userJID.Node(), filter)
+	switch err {
+	case nil:
+		break
+	case mammodel.ErrMessageNotFound:
+		stm.SendElement(iq.ItemNotFoundError())
+		return
+	default:
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	complete := len(msgs) <= max
+	if !complete {
+		if filter.Backwards {
+			msgs = msgs[1:]
+		} else {
+			msgs = msgs[:max]
+		}
+	}
+	queryID := query.Attributes().Get("queryid")
+	for _, m := range msgs {
+		stm.SendElement(resultMessage(userJID, queryID, &m))
+	}
+	// archive size is not computed, as it would require going through it
+	set := &xep0059.Result{FirstIndex: -1, Count: -1}
+	if len(msgs) > 0 {
+		set.First = msgs[0].ID
+		set.Last = msgs[len(msgs)-1].ID
+		if indexed {
+			set.FirstIndex = filter.Offset
+		}
+	}
+	fin := xmpp.NewElementNamespace("fin", mamNamespace)
+	if complete {
+		fin.SetAttribute("complete", "true")
+	}
+	fin.AppendElement(set.Element())
+
+	result := iq.ResultIQ()
+	result.AppendElement(fin)
+	stm.SendElement(result)
+}
+
+func (x *Mam) sendPrefs(iq *xmpp.IQ, stm stream.C2S) {
+	prefs, err := x.fetchPrefs(stm.Username())
+	if err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	result := iq.ResultIQ()
+	result.AppendElement(prefsElement(prefs))
+	stm.SendElement(result)
+}
+
+func (x *Mam) setPrefs(iq *xmpp.IQ, prefsElem xmpp.XElement, stm stream.C2S) {
+	prefs := &mammodel.Prefs{
+		Username: stm.Username(),
+		Default:  prefsElem.Attributes().Get("default"),
+	}
+	switch prefs.Default {
+	case mammodel.DefaultAlways, mammodel.DefaultNever, mammodel.DefaultRoster:
+		break
+	default:
+		stm.SendElement(iq.BadRequestError())
+		return
+	}
+	var err error
+	if prefs.Always, err = jidList(prefsElem.Elements().Child("always")); err != nil {
+		stm.SendElement(iq.JidMalformedError())
+		return
+	}
+	if prefs.Never, err = jidList(prefsElem.Elements().Child("never")); err != nil {
+		stm.SendElement(iq.JidMalformedError())
+		return
+	}
+	if err := storage.Instance().InsertOrUpdateArchivePrefs(prefs); err != nil {
+		log.Error(err)
+		stm.SendElement(iq.InternalServerError())
+		return
+	}
+	result := iq.ResultIQ()
+	result.AppendElement(prefsElement(prefs))
+	stm.SendElement(result)
+}
+
+func (x *Mam) fetchPrefs(username string) (*mammodel.Prefs, error) {
+	prefs, err := storage.Instance().FetchArchivePrefs(username)
+	if err != nil {
+		return nil, err
+	}
+	if prefs == nil {
+		prefs = &mammodel.Prefs{Username: username, Default: x.cfg.Default}
+	}
+	return prefs, nil
+}
+
+func filterFromForm(form *xep0004.DataForm) (*mammodel.Filter, error) {
+	filter := &mammodel.Filter{}
+	for _, field := range form.Fields {
+		if len(field.Values) == 0 {
+			continue
+		}
+		value := field.Values[0]
+		switch field.Var {
+		case "FORM_TYPE":
+			if value != mamNamespace {
+				return nil, fmt.Errorf("xep0313: unexpected form type: %s", value)
+			}
+		case "with":
+			j, err := jid.NewWithString(value, false)
+			if err != nil {
+				return nil, err
+			}
+			filter.With = j.ToBareJID().String()
+		case "start":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, err
+			}
+			filter.Start = t
+		case "end":
+			t, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				return nil, err
+			}
+			filter.End = t
+		}
+	}
+	return filter, nil
+}
+
+func resultMessage(userJID *jid.JID, queryID string, m *mammodel.Message) *xmpp.Message {
+	delay := xmpp.NewElementNamespace("delay", delayNamespace)
+	delay.SetAttribute("stamp", m.Stamp.UTC().Format("2006-01-02T15:04:05Z"))
+
+	forwarded := xmpp.NewElementNamespace("forwarded", forwardNamespace)
+	forwarded.AppendElement(delay)
+	forwarded.AppendElement(m.Message)
+
+	result := xmpp.NewElementNamespace("result", mamNamespace)
+	if len(queryID) > 0 {
+		result.SetAttribute("queryid", queryID)
+	}
+	result.SetAttribute("id", m.ID)
+	result.AppendElement(forwarded)
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.NormalType)
+	msg.SetFromJID(userJID.ToBareJID())
+	msg.SetToJID(userJID)
+	msg.AppendElement(result)
+	return msg
+}
+
+func prefsElement(prefs *mammodel.Prefs) xmpp.XElement {
+	elem := xmpp.NewElementNamespace("prefs", mamNamespace)
+	elem.SetAttribute("default", prefs.Default)
+
+	always := xmpp.NewElementName("always")
+	for _, j := range prefs.Always {
+		jidElem := xmpp.NewElementName("jid")
+		jidElem.SetText(j)
+		always.AppendElement(jidElem)
+	}
+	never := xmpp.NewElementName("never")
+	for _, j := range prefs.Never {
+		jidElem := xmpp.NewElementName("jid")
+		jidElem.SetText(j)
+		never.AppendElement(jidElem)
+	}
+	elem.AppendElement(always)
+	elem.AppendElement(never)
+	return elem
+}
+
+func jidList(elem xmpp.XElement) ([]string, error) {
+	if elem == nil {
+		return nil, nil
+	}
+	var ret []string
+	for _, jidElem := range elem.Elements().Children("jid") {
+		j, err := jid.NewWithString(jidElem.Text(), false)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, j.ToBareJID().String())
+	}
+	return ret, nil
+}
+
+func isMessageArchivable(message *xmpp.Message) bool {
+	return (message.IsNormal() || message.IsChat()) && message.IsMessageWithBody()
+}
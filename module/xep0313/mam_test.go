@@ -0,0 +1,396 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0313
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/module/xep0004"
+	"github.com/ortuman/jackal/module/xep0059"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestXEP0313_Config(t *testing.T) {
+	var cfg Config
+	require.Nil(t, yaml.Unmarshal([]byte(`max_page_size: 10`), &cfg))
+	require.Equal(t, mammodel.DefaultAlways, cfg.Default)
+	require.Equal(t, 10, cfg.MaxPageSize)
+
+	require.Nil(t, yaml.Unmarshal([]byte(`default: roster`), &cfg))
+	require.Equal(t, mammodel.DefaultRoster, cfg.Default)
+	require.Equal(t, defaultMaxPageSize, cfg.MaxPageSize)
+
+	require.NotNil(t, yaml.Unmarshal([]byte(`default: sometimes`), &cfg))
+	require.NotNil(t, yaml.Unmarshal([]byte(`max_page_size: -1`), &cfg))
+}
+
+func TestXEP0313_Matching(t *testing.T) {
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	x := New(&Config{}, nil, nil)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	require.False(t, x.MatchesIQ(iq))
+
+	iq.AppendElement(xmpp.NewElementNamespace("query", mamNamespace))
+	require.True(t, x.MatchesIQ(iq))
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("prefs", mamNamespace))
+	require.True(t, x.MatchesIQ(iq))
+}
+
+func TestXEP0313_ArchiveMessage(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}, {Name: "jackal.org"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+	shutdownCh := make(chan struct{})
+	defer close(shutdownCh)
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	j3, _ := jid.New("romeo", "jabber.org", "orchard", true)
+	j4, _ := jid.New("ortuman", "jackal.org", "garage", true)
+
+	x := New(&Config{}, nil, shutdownCh)
+
+	storage.Instance().InsertOrUpdateArchivePrefs(&mammodel.Prefs{
+		Username: "noelia",
+		Default:  mammodel.DefaultAlways,
+		Never:    []string{"ortuman@jackal.im"},
+	})
+	x.ArchiveMessage(tUtilMessage(j1, j2, xmpp.ChatType))
+	x.ArchiveMessage(tUtilMessage(j1, j3, xmpp.ChatType))
+	x.ArchiveMessage(tUtilMessage(j3, j2, xmpp.ChatType))
+	x.ArchiveMessage(tUtilMessage(j1, j2, xmpp.GroupChatType))
+	x.ArchiveMessage(tUtilMessage(j1, j1, xmpp.ChatType))
+	x.ArchiveMessage(tUtilMessage(j1, j4, xmpp.ChatType))
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	defer stm.Disconnect(nil)
+
+	// make sure every message has been archived
+	x.ProcessIQ(tUtilQueryIQ(j1, nil, nil), stm)
+	for {
+		if elem := stm.FetchElement(); elem.Name() == "iq" {
+			break
+		}
+	}
+	msgs, _ := storage.Instance().FetchArchiveMessages("ortuman", &mammodel.Filter{})
+	require.Equal(t, 5, len(msgs))
+	require.Equal(t, "noelia@jackal.im", msgs[0].With)
+	require.Equal(t, "romeo@jabber.org", msgs[1].With)
+	require.Equal(t, "ortuman@jackal.im", msgs[2].With) // self-addressed messages are archived once
+
+	// same node on a different local domain
+	var withs []string
+	for _, m := range msgs[3:] {
+		withs = append(withs, m.With)
+	}
+	require.ElementsMatch(t, []string{"ortuman@jackal.org", "ortuman@jackal.im"}, withs)
+
+	msgs, _ = storage.Instance().FetchArchiveMessages("noelia", &mammodel.Filter{})
+	require.Equal(t, 1, len(msgs))
+	require.Equal(t, "romeo@jabber.org", msgs[0].With)
+
+	msgs, _ = storage.Instance().FetchArchiveMessages("romeo", &mammodel.Filter{})
+	require.Equal(t, 0, len(msgs))
+}
+
+func TestXEP0313_RosterArchivingPrefs(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	x := New(&Config{Default: mammodel.DefaultRoster}, nil, nil)
+
+	storage.Instance().InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     "ortuman",
+		JID:          "noelia@jackal.im",
+		Subscription: "both",
+	})
+	j, _ := jid.New("noelia", "jackal.im", "", true)
+	ok, err := x.isArchivable("ortuman", j)
+	require.Nil(t, err)
+	require.True(t, ok)
+
+	j, _ = jid.New("romeo", "jackal.im", "", true)
+	ok, err = x.isArchivable("ortuman", j)
+	require.Nil(t, err)
+	require.False(t, ok)
+}
+
+func TestXEP0313_Query(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer func() {
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+	j2, _ := jid.New("noelia", "jackal.im", "garden", true)
+	j3, _ := jid.New("romeo", "jackal.im", "orchard", true)
+
+	now := time.Now()
+	var ids []string
+	for i := 0; i < 5; i++ {
+		to := j2
+		if i%2 == 1 {
+			to = j3
+		}
+		am := &mammodel.Message{
+			ID:       uuid.New(),
+			Username: "ortuman",
+			With:     to.ToBareJID().String(),
+			Message:  tUtilMessage(j1, to, xmpp.ChatType),
+			Stamp:    now.Add(time.Duration(i-5) * time.Hour),
+		}
+		storage.Instance().InsertArchiveMessage(am)
+		ids = append(ids, am.ID)
+	}
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	defer stm.Disconnect(nil)
+
+	x := New(&Config{MaxPageSize: 2}, nil, nil)
+
+	// query form
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j1.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("query", mamNamespace))
+	x.ProcessIQ(iq, stm)
+	elem := stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+	require.NotNil(t, elem.Elements().ChildNamespace("query", mamNamespace).Elements().ChildNamespace("x", "jabber:x:data"))
+
+	// other user's archive
+	iq = tUtilQueryIQ(j1, nil, nil)
+	iq.SetToJID(j2.ToBareJID())
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("forbidden"))
+
+	// first page
+	iq = tUtilQueryIQ(j1, nil, nil)
+	x.ProcessIQ(iq, stm)
+	results, fin := tUtilFetchQueryResults(t, stm)
+	require.Equal(t, 2, len(results))
+	require.Equal(t, ids[0], results[0].Attributes().Get("id"))
+	require.Equal(t, "q1", results[0].Attributes().Get("queryid"))
+	require.NotNil(t, results[0].Elements().ChildNamespace("forwarded", forwardNamespace).Elements().Child("message"))
+	require.Equal(t, "", fin.Attributes().Get("complete"))
+	set := fin.Elements().ChildNamespace("set", xep0059.RSMNamespace)
+	require.Nil(t, set.Elements().Child("count"))
+	require.Equal(t, ids[0], set.Elements().Child("first").Text())
+	require.Equal(t, "0", set.Elements().Child("first").Attributes().Get("index"))
+	require.Equal(t, ids[1], set.Elements().Child("last").Text())
+
+	// next page
+	iq = tUtilQueryIQ(j1, nil, &xep0059.Request{Max: 10, After: ids[1]})
+	x.ProcessIQ(iq, stm)
+	results, fin = tUtilFetchQueryResults(t, stm)
+	require.Equal(t, 2, len(results))
+	require.Equal(t, ids[2], results[0].Attributes().Get("id"))
+	require.Equal(t, ids[3], results[1].Attributes().Get("id"))
+
+	// indexed page
+	iq = tUtilQueryIQ(j1, nil, &xep0059.Request{Max: 10, Index: 3})
+	x.ProcessIQ(iq, stm)
+	results, fin = tUtilFetchQueryResults(t, stm)
+	require.Equal(t, 2, len(results))
+	require.Equal(t, ids[3], results[0].Attributes().Get("id"))
+	require.Equal(t, ids[4], results[1].Attributes().Get("id"))
+	require.Equal(t, "true", fin.Attributes().Get("complete"))
+	set = fin.Elements().ChildNamespace("set", xep0059.RSMNamespace)
+	require.Equal(t, "3", set.Elements().Child("first").Attributes().Get("index"))
+
+	// last page
+	iq = tUtilQueryIQ(j1, nil, &xep0059.Request{Max: 1, LastPage: true})
+	x.ProcessIQ(iq, stm)
+	results, fin = tUtilFetchQueryResults(t, stm)
+	require.Equal(t, 1, len(results))
+	require.Equal(t, ids[4], results[0].Attributes().Get("id"))
+	require.Equal(t, "", fin.Attributes().Get("complete"))
+
+	// previous page
+	iq = tUtilQueryIQ(j1, nil, &xep0059.Request{Max: 10, Before: ids[2]})
+	x.ProcessIQ(iq, stm)
+	results, fin = tUtilFetchQueryResults(t, stm)
+	require.Equal(t, 2, len(results))
+	require.Equal(t, ids[0], results[0].Attributes().Get("id"))
+	require.Equal(t, ids[1], results[1].Attributes().Get("id"))
+	require.Equal(t, "true", fin.Attributes().Get("complete"))
+	set = fin.Elements().ChildNamespace("set", xep0059.RSMNamespace)
+	require.Equal(t, "", set.Elements().Child("first").Attributes().Get("index"))
+
+	// filtered by 'with' and 'start'
+	form := &xep0004.DataForm{
+		Type: xep0004.Submit,
+		Fields: []xep0004.Field{
+			{Var: "FORM_TYPE", Type: xep0004.Hidden, Values: []string{mamNamespace}},
+			{Var: "with", Values: []string{"noelia@jackal.im"}},
+			{Var: "start", Values: []string{now.Add(-210 * time.Minute).UTC().Format(time.RFC3339)}},
+		},
+	}
+	iq = tUtilQueryIQ(j1, form, nil)
+	x.ProcessIQ(iq, stm)
+	results, fin = tUtilFetchQueryResults(t, stm)
+	require.Equal(t, 2, len(results))
+	require.Equal(t, ids[2], results[0].Attributes().Get("id"))
+	require.Equal(t, ids[4], results[1].Attributes().Get("id"))
+	require.Equal(t, "true", fin.Attributes().Get("complete"))
+
+	// unknown item
+	iq = tUtilQueryIQ(j1, nil, &xep0059.Request{Max: 1, After: uuid.New()})
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("item-not-found"))
+
+	// bad form
+	form.Fields[2].Values = []string{"yesterday"}
+	iq = tUtilQueryIQ(j1, form, nil)
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("bad-request"))
+}
+
+func TestXEP0313_Prefs(t *testing.T) {
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	defer storage.Shutdown()
+
+	j, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	stm := stream.NewMockC2S(uuid.New(), j)
+	defer stm.Disconnect(nil)
+
+	x := New(&Config{Default: mammodel.DefaultNever}, nil, nil)
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(xmpp.NewElementNamespace("prefs", mamNamespace))
+	x.ProcessIQ(iq, stm)
+	elem := stm.FetchElement()
+	prefs := elem.Elements().ChildNamespace("prefs", mamNamespace)
+	require.NotNil(t, prefs)
+	require.Equal(t, mammodel.DefaultNever, prefs.Attributes().Get("default"))
+
+	prefsElem := xmpp.NewElementNamespace("prefs", mamNamespace)
+	prefsElem.SetAttribute("default", "sometimes")
+	iq = xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(prefsElem)
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.NotNil(t, elem.Error().Elements().Child("bad-request"))
+
+	always := xmpp.NewElementName("always")
+	jidElem := xmpp.NewElementName("jid")
+	jidElem.SetText("noelia@jackal.im")
+	always.AppendElement(jidElem)
+	prefsElem = xmpp.NewElementNamespace("prefs", mamNamespace)
+	prefsElem.SetAttribute("default", mammodel.DefaultRoster)
+	prefsElem.AppendElement(always)
+
+	iq = xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(j)
+	iq.SetToJID(j.ToBareJID())
+	iq.AppendElement(prefsElem)
+	x.ProcessIQ(iq, stm)
+	elem = stm.FetchElement()
+	require.Equal(t, xmpp.ResultType, elem.Type())
+
+	p, _ := storage.Instance().FetchArchivePrefs("ortuman")
+	require.NotNil(t, p)
+	require.Equal(t, mammodel.DefaultRoster, p.Default)
+	require.Equal(t, []string{"noelia@jackal.im"}, p.Always)
+}
+
+func tUtilMessage(from, to *jid.JID, messageType string) *xmpp.Message {
+	msg := xmpp.NewMessageType(uuid.New(), messageType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	body := xmpp.NewElementName("body")
+	body.SetText("I'll give thee a wind.")
+	msg.AppendElement(body)
+	return msg
+}
+
+func tUtilQueryIQ(from *jid.JID, form *xep0004.DataForm, req *xep0059.Request) *xmpp.IQ {
+	query := xmpp.NewElementNamespace("query", mamNamespace)
+	query.SetAttribute("queryid", "q1")
+	if form != nil {
+		query.AppendElement(form.Element())
+	}
+	if req != nil {
+		set := xmpp.NewElementNamespace("set", xep0059.RSMNamespace)
+		if req.Max >= 0 {
+			max := xmpp.NewElementName("max")
+			max.SetText(strconv.Itoa(req.Max))
+			set.AppendElement(max)
+		}
+		if len(req.After) > 0 {
+			after := xmpp.NewElementName("after")
+			after.SetText(req.After)
+			set.AppendElement(after)
+		}
+		if len(req.Before) > 0 || req.LastPage {
+			before := xmpp.NewElementName("before")
+			before.SetText(req.Before)
+			set.AppendElement(before)
+		}
+		if req.Index > 0 {
+			index := xmpp.NewElementName("index")
+			index.SetText(strconv.Itoa(req.Index))
+			set.AppendElement(index)
+		}
+		query.AppendElement(set)
+	}
+	iq := xmpp.NewIQType(uuid.New(), xmpp.SetType)
+	iq.SetFromJID(from)
+	iq.SetToJID(from.ToBareJID())
+	iq.AppendElement(query)
+	return iq
+}
+
+func tUtilFetchQueryResults(t *testing.T, stm *stream.MockC2S) ([]xmpp.XElement, xmpp.XElement) {
+	var results []xmpp.XElement
+	for {
+		elem := stm.FetchElement()
+		if elem.Name() == "iq" {
+			require.Equal(t, xmpp.ResultType, elem.Type())
+			fin := elem.Elements().ChildNamespace("fin", mamNamespace)
+			require.NotNil(t, fin)
+			return results, fin
+		}
+		result := elem.Elements().ChildNamespace("result", mamNamespace)
+		require.NotNil(t, result)
+		results = append(results, result)
+	}
+}
@@ -0,0 +1,45 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package router
+
+import (
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var routedStanzas = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "jackal",
+	Subsystem: "router",
+	Name:      "routed_stanzas_total",
+	Help:      "Number of routed stanzas, by stanza type and routing result.",
+}, []string{"type", "result"})
+
+func init() {
+	prometheus.MustRegister(routedStanzas)
+}
+
+func observeRoute(stanza xmpp.Stanza, err error) {
+	routedStanzas.WithLabelValues(stanza.Name(), routeResult(err)).Inc()
+}
+
+func routeResult(err error) string {
+	switch err {
+	case nil:
+		return "routed"
+	case ErrNotExistingAccount:
+		return "not_existing_account"
+	case ErrResourceNotFound:
+		return "resource_not_found"
+	case ErrNotAuthenticated:
+		return "not_authenticated"
+	case ErrBlockedJID:
+		return "blocked_jid"
+	case ErrFailedRemoteConnect:
+		return "failed_remote_connect"
+	default:
+		return "error"
+	}
+}
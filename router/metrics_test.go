@@ -0,0 +1,76 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package router
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/ortuman/jackal/stream"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouter_Metrics(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	Initialize(&Config{})
+	defer func() {
+		Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", false)
+	j2, _ := jid.NewWithString("hamlet@jackal.im/balcony", false)
+	j3, _ := jid.NewWithString("romeo@jackal.im/balcony", false)
+
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "hamlet", Password: ""})
+
+	stm := stream.NewMockC2S(uuid.New(), j1)
+	Bind(stm)
+	defer Unbind(stm)
+
+	routed := tUtilRoutedStanzas(t, "message", "routed")
+	notAuth := tUtilRoutedStanzas(t, "presence", "not_authenticated")
+	notExisting := tUtilRoutedStanzas(t, "iq", "not_existing_account")
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(j2)
+	msg.SetToJID(j1)
+	require.Nil(t, Route(msg))
+
+	require.Equal(t, ErrNotAuthenticated, Route(xmpp.NewPresence(j1, j2, xmpp.AvailableType)))
+
+	iq := xmpp.NewIQType(uuid.New(), xmpp.GetType)
+	iq.SetFromJID(j1)
+	iq.SetToJID(j3)
+	require.Equal(t, ErrNotExistingAccount, Route(iq))
+
+	require.Equal(t, routed+1, tUtilRoutedStanzas(t, "message", "routed"))
+	require.Equal(t, notAuth+1, tUtilRoutedStanzas(t, "presence", "not_authenticated"))
+	require.Equal(t, notExisting+1, tUtilRoutedStanzas(t, "iq", "not_existing_account"))
+}
+
+func TestRouter_RouteResult(t *testing.T) {
+	require.Equal(t, "routed", routeResult(nil))
+	require.Equal(t, "blocked_jid", routeResult(ErrBlockedJID))
+	require.Equal(t, "resource_not_found", routeResult(ErrResourceNotFound))
+	require.Equal(t, "failed_remote_connect", routeResult(ErrFailedRemoteConnect))
+	require.Equal(t, "error", routeResult(memstorage.ErrMockedError))
+}
+
+func tUtilRoutedStanzas(t *testing.T, stanzaType, result string) float64 {
+	m := &dto.Metric{}
+	require.Nil(t, routedStanzas.WithLabelValues(stanzaType, result).Write(m))
+	return m.GetCounter().GetValue()
+}
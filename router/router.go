@@ -50,6 +50,7 @@ type router struct {
 	cfg          *Config
 	mu           sync.RWMutex
 	localStreams map[string][]stream.C2S
+	compStreams  map[string]stream.InOutStream
 	blockListsMu sync.RWMutex
 	blockLists   map[string][]*jid.JID
 }
@@ -72,6 +73,7 @@ func Initialize(cfg *Config) {
 		cfg:          cfg,
 		blockLists:   make(map[string][]*jid.JID),
 		localStreams: make(map[string][]stream.C2S),
+		compStreams:  make(map[string]stream.InOutStream),
 	}
 	initialized = true
 }
@@ -100,6 +102,26 @@ func Unbind(stm stream.C2S) {
 	instance().unbind(stm)
 }
 
+// BindComponent marks an external component stream as binded
+// to a given domain.
+func BindComponent(domain string, stm stream.InOutStream) {
+	instance().bindComponent(domain, stm)
+}
+
+// UnbindComponent unbinds a previously binded component stream.
+func UnbindComponent(domain string) {
+	instance().unbindComponent(domain)
+}
+
+// IsComponentHost returns true if domain is bound to
+// an external component stream.
+func IsComponentHost(domain string) bool {
+	instMu.RLock()
+	r := inst
+	instMu.RUnlock()
+	return r != nil && r.componentStream(domain) != nil
+}
+
 // UserStreams returns all streams associated to a user.
 func UserStreams(username string) []stream.C2S {
 	return instance().userStreams(username)
@@ -178,6 +200,26 @@ func (r *router) unbind(stm stream.C2S) {
 	log.Infof("unbinded c2s stream... (%s/%s)", stm.Username(), stm.Resource())
 }
 
+func (r *router) bindComponent(domain string, stm stream.InOutStream) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.compStreams[domain] = stm
+	log.Infof("binded component stream... (%s)", domain)
+}
+
+func (r *router) unbindComponent(domain string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.compStreams, domain)
+	log.Infof("unbinded component stream... (%s)", domain)
+}
+
+func (r *router) componentStream(domain string) stream.InOutStream {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.compStreams[domain]
+}
+
 func (r *router) userStreams(username string) []stream.C2S {
 	r.mu.Lock()
 	defer r.mu.Unlock()
@@ -237,7 +279,17 @@ func (r *router) getBlockList(username string) []*jid.JID {
 }
 
 func (r *router) route(element xmpp.Stanza, ignoreBlocking bool) error {
+	err := r.routeStanza(element, ignoreBlocking)
+	observeRoute(element, err)
+	return err
+}
+
+func (r *router) routeStanza(element xmpp.Stanza, ignoreBlocking bool) error {
 	toJID := element.ToJID()
+	if stm := r.componentStream(toJID.Domain()); stm != nil {
+		stm.SendElement(element)
+		return nil
+	}
 	if !ignoreBlocking && !toJID.IsServer() {
 		if r.isBlockedJID(element.FromJID(), toJID.Node()) {
 			return ErrBlockedJID
@@ -232,3 +232,37 @@ func TestC2SManager_BlockedJID(t *testing.T) {
 	iq.SetToJID(j1)
 	require.Equal(t, ErrBlockedJID, Route(iq))
 }
+
+func TestC2SManager_ComponentRouting(t *testing.T) {
+	outS2S := fakeS2SOut{}
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	Initialize(&Config{GetS2SOut: func(_, _ string) (stream.S2SOut, error) { return &outS2S, nil }})
+	defer func() {
+		Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", false)
+	j2, _ := jid.NewWithString("ortuman@gateway.jackal.im", false)
+
+	comp := fakeS2SOut{}
+	BindComponent("gateway.jackal.im", &comp)
+	require.True(t, IsComponentHost("gateway.jackal.im"))
+	require.False(t, IsComponentHost("jackal.im"))
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(j1)
+	msg.SetToJID(j2)
+	require.Nil(t, Route(msg))
+	require.Equal(t, 1, len(comp.elems))
+	require.Equal(t, 0, len(outS2S.elems))
+
+	UnbindComponent("gateway.jackal.im")
+	require.False(t, IsComponentHost("gateway.jackal.im"))
+
+	require.Nil(t, Route(msg))
+	require.Equal(t, 1, len(comp.elems))
+	require.Equal(t, 1, len(outS2S.elems))
+}
@@ -28,12 +28,14 @@ type TransportConfig struct {
 	BindAddress string
 	Port        int
 	KeepAlive   time.Duration
+	DirectTLS   bool
 }
 
 type transportConfigProxy struct {
 	BindAddress string `yaml:"bind_addr"`
 	Port        int    `yaml:"port"`
 	KeepAlive   int    `yaml:"keep_alive"`
+	DirectTLS   bool   `yaml:"direct_tls"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -44,6 +46,7 @@ func (c *TransportConfig) UnmarshalYAML(unmarshal func(interface{}) error) error
 	}
 	c.BindAddress = p.BindAddress
 	c.Port = p.Port
+	c.DirectTLS = p.DirectTLS
 	if c.Port == 0 {
 		c.Port = defaultTransportPort
 	}
@@ -118,4 +121,5 @@ type streamConfig struct {
 	maxStanzaSize  int
 	dbVerify       xmpp.XElement
 	dialer         *dialer
+	directTLS      bool
 }
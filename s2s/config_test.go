@@ -40,6 +40,15 @@ keep_alive: 200
 	require.Equal(t, "127.0.0.1", trCfg.BindAddress)
 	require.Equal(t, 5999, trCfg.Port)
 	require.Equal(t, time.Duration(200)*time.Second, trCfg.KeepAlive)
+	require.False(t, trCfg.DirectTLS)
+
+	rawCfg = `
+port: 5270
+direct_tls: true
+`
+	err = yaml.Unmarshal([]byte(rawCfg), &trCfg)
+	require.Nil(t, err)
+	require.True(t, trCfg.DirectTLS)
 }
 
 func TestConfig(t *testing.T) {
@@ -14,21 +14,36 @@ import (
 
 var inContainer inMap
 
-type inMap struct{ m sync.Map }
+type inMap struct {
+	mu sync.Mutex
+	m  sync.Map
+}
 
 func (m *inMap) set(stm stream.S2SIn) {
-	m.m.Store(stm.ID(), stm)
+	m.mu.Lock()
+	if _, loaded := m.m.LoadOrStore(stm.ID(), stm); !loaded {
+		streamCount.WithLabelValues("in").Inc()
+	}
+	m.mu.Unlock()
 	log.Infof("registered s2s in stream... (id: %s)", stm.ID())
 }
 
 func (m *inMap) delete(stm stream.S2SIn) {
-	m.m.Delete(stm.ID())
+	m.mu.Lock()
+	if _, ok := m.m.Load(stm.ID()); ok {
+		m.m.Delete(stm.ID())
+		streamCount.WithLabelValues("in").Dec()
+	}
+	m.mu.Unlock()
 	log.Infof("unregistered s2s in stream... (id: %s)", stm.ID())
 }
 
 var outContainer outMap
 
-type outMap struct{ m sync.Map }
+type outMap struct {
+	mu sync.Mutex
+	m  sync.Map
+}
 
 func (m *outMap) getOrDial(localDomain, remoteDomain string) (stream.S2SOut, error) {
 	domainPair := localDomain + ":" + remoteDomain
@@ -40,6 +55,7 @@ func (m *outMap) getOrDial(localDomain, remoteDomain string) (stream.S2SOut, err
 			m.m.Delete(domainPair)
 			return nil, err
 		}
+		streamCount.WithLabelValues("out").Inc()
 		s.(*outStream).start(outCfg)
 		log.Infof("registered s2s out stream... (domainpair: %s)", domainPair)
 	}
@@ -48,6 +64,11 @@ func (m *outMap) getOrDial(localDomain, remoteDomain string) (stream.S2SOut, err
 
 func (m *outMap) delete(stm stream.S2SOut) {
 	domainPair := stm.ID()
-	m.m.Delete(domainPair)
+	m.mu.Lock()
+	if _, ok := m.m.Load(domainPair); ok {
+		m.m.Delete(domainPair)
+		streamCount.WithLabelValues("out").Dec()
+	}
+	m.mu.Unlock()
 	log.Infof("unregistered s2s out stream... (domainpair: %s)", domainPair)
 }
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/transport"
 )
 
@@ -46,8 +47,15 @@ func (d *dialer) dial(localDomain, remoteDomain string) (*streamConfig, error) {
 		return nil, err
 	}
 	tlsConfig := &tls.Config{
-		ServerName:   remoteDomain,
-		Certificates: host.Certificates(),
+		ServerName: remoteDomain,
+		GetClientCertificate: func(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cer, err := host.Certificate(localDomain)
+			if err != nil {
+				log.Warnf("%v", err)
+				return &tls.Certificate{}, nil // continue without client certificate
+			}
+			return cer, nil
+		},
 	}
 	tr := transport.NewSocketTransport(conn, d.cfg.Transport.KeepAlive)
 	return &streamConfig{
@@ -46,6 +46,9 @@ func newInStream(cfg *streamConfig) *inStream {
 		cfg:     cfg,
 		actorCh: make(chan func(), streamMailboxSize),
 	}
+	if cfg.directTLS {
+		s.secured = 1
+	}
 	// register into stream container
 	inContainer.set(s)
 
@@ -189,7 +192,23 @@ func (s *inStream) handleConnected(elem xmpp.XElement) {
 				}
 				return
 			}
-			router.Route(elem)
+			err := router.Route(elem)
+			if message, ok := elem.(*xmpp.Message); ok {
+				switch err {
+				case nil:
+					if mam := module.Modules().Mam; mam != nil {
+						mam.ArchiveMessage(message)
+					}
+					if carbons := module.Modules().Carbons; carbons != nil {
+						carbons.ProcessMessage(message)
+					}
+				case router.ErrNotAuthenticated:
+					// archive messages addressed to offline users as well
+					if mam := module.Modules().Mam; mam != nil {
+						mam.ArchiveMessage(message)
+					}
+				}
+			}
 		}
 	}
 }
@@ -206,9 +225,9 @@ func (s *inStream) proceedStartTLS(elem xmpp.XElement) {
 	s.writeElement(xmpp.NewElementNamespace("proceed", tlsNamespace))
 
 	s.cfg.transport.StartTLS(&tls.Config{
-		ServerName:   s.localDomain,
-		ClientAuth:   tls.VerifyClientCertIfGiven,
-		Certificates: host.Certificates(),
+		ServerName:     s.localDomain,
+		ClientAuth:     tls.VerifyClientCertIfGiven,
+		GetCertificate: host.GetCertificate(s.localDomain),
 	}, false)
 	atomic.StoreUint32(&s.secured, 1)
 
@@ -260,7 +279,7 @@ func (s *inStream) failAuthentication(reason, text string) {
 }
 
 func (s *inStream) authorizeDialbackKey(elem xmpp.XElement) {
-	if !host.IsLocalHost(elem.To()) {
+	if !host.IsLocalHost(elem.To()) && !router.IsComponentHost(elem.To()) {
 		s.writeStanzaErrorResponse(elem, xmpp.ErrItemNotFound)
 		return
 	}
@@ -307,7 +326,7 @@ func (s *inStream) authorizeDialbackKey(elem xmpp.XElement) {
 }
 
 func (s *inStream) verifyDialbackKey(elem xmpp.XElement) {
-	if !host.IsLocalHost(elem.To()) {
+	if !host.IsLocalHost(elem.To()) && !router.IsComponentHost(elem.To()) {
 		s.writeStanzaErrorResponse(elem, xmpp.ErrItemNotFound)
 		return
 	}
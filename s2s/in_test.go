@@ -14,6 +14,8 @@ import (
 	"time"
 
 	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/mammodel"
 	"github.com/ortuman/jackal/module"
 	"github.com/ortuman/jackal/module/offline"
 	"github.com/ortuman/jackal/module/xep0077"
@@ -372,6 +374,45 @@ func TestStream_SendElement(t *testing.T) {
 	require.True(t, conn.waitClose())
 }
 
+func TestStream_ArchiveOfflineMessage(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	router.Initialize(&router.Config{})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	module.Initialize(&module.Config{Enabled: map[string]struct{}{"mam": {}}})
+	defer func() {
+		module.Shutdown()
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+	storage.Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Password: "1234"})
+
+	fromJID, _ := jid.New("noelia", "localhost", "garden", true)
+	toJID, _ := jid.New("ortuman", "jackal.im", "balcony", true)
+
+	stm, conn := tUtilInStreamInit(t, false)
+	tUtilInStreamOpen(conn)
+	_ = conn.outboundRead() // read stream opening...
+	_ = conn.outboundRead() // read stream features...
+	atomic.StoreUint32(&stm.secured, 1)
+	atomic.StoreUint32(&stm.authenticated, 1)
+
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(fromJID)
+	msg.SetToJID(toJID)
+	msg.AppendElement(xmpp.NewElementName("body"))
+	conn.inboundWriteString(msg.String())
+
+	var msgs []mammodel.Message
+	for i := 0; i < 50 && len(msgs) == 0; i++ {
+		time.Sleep(time.Millisecond * 20)
+		msgs, _ = storage.Instance().FetchArchiveMessages("ortuman", &mammodel.Filter{})
+	}
+	require.Equal(t, 1, len(msgs))
+	require.Equal(t, "noelia@localhost", msgs[0].With)
+	require.Equal(t, msg.ID(), msgs[0].Message.ID())
+}
+
 func tUtilInStreamInit(t *testing.T, loadPeerCertificate bool) (*inStream, *fakeSocketConn) {
 	cfg, conn := tUtilInStreamDefaultConfig(t, loadPeerCertificate)
 	stm := newInStream(cfg)
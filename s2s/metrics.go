@@ -0,0 +1,19 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package s2s
+
+import "github.com/prometheus/client_golang/prometheus"
+
+var streamCount = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: "jackal",
+	Subsystem: "s2s",
+	Name:      "streams",
+	Help:      "Number of active s2s streams, by direction.",
+}, []string{"direction"})
+
+func init() {
+	prometheus.MustRegister(streamCount)
+}
@@ -41,7 +41,7 @@ func Initialize(cfg *Config) {
 		return
 	}
 	defaultDialer = newDialer(cfg)
-	srv = &server{cfg: cfg}
+	srv = &server{cfg: cfg, dialer: defaultDialer}
 	go srv.start()
 	initialized = true
 }
@@ -6,10 +6,12 @@
 package s2s
 
 import (
+	"crypto/tls"
 	"net"
 	"strconv"
 	"sync/atomic"
 
+	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/transport"
 )
@@ -18,6 +20,7 @@ var listenerProvider = net.Listen
 
 type server struct {
 	cfg       *Config
+	dialer    *dialer
 	ln        net.Listener
 	listening uint32
 }
@@ -45,6 +48,14 @@ func (s *server) listenConn(address string) error {
 	if err != nil {
 		return err
 	}
+	if s.cfg.Transport.DirectTLS {
+		// XEP-0368: SRV records for XMPP over TLS (https://xmpp.org/extensions/xep-0368.html)
+		ln = tls.NewListener(ln, &tls.Config{
+			ClientAuth:     tls.VerifyClientCertIfGiven,
+			GetCertificate: host.GetCertificate(""),
+			NextProtos:     []string{"xmpp-server"},
+		})
+	}
 	s.ln = ln
 
 	atomic.StoreUint32(&s.listening, 1)
@@ -64,6 +75,7 @@ func (s *server) startStream(tr transport.Transport) {
 		transport:      tr,
 		connectTimeout: s.cfg.ConnectTimeout,
 		maxStanzaSize:  s.cfg.MaxStanzaSize,
-		dialer:         newDialerCopy(defaultDialer),
+		dialer:         newDialerCopy(s.dialer),
+		directTLS:      s.cfg.Transport.DirectTLS,
 	})
 }
@@ -6,13 +6,16 @@
 package s2s
 
 import (
+	"crypto/tls"
 	"net"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/util"
 	"github.com/stretchr/testify/require"
 )
 
@@ -60,3 +63,58 @@ func TestS2SSocketServer(t *testing.T) {
 	storage.Shutdown()
 	host.Shutdown()
 }
+
+func TestS2SDirectTLSServer(t *testing.T) {
+	privKeyFile := "../testdata/cert/test.server.key"
+	certFile := "../testdata/cert/test.server.crt"
+	cer, err := util.LoadCertificate(privKeyFile, certFile, "localhost")
+	require.Nil(t, err)
+
+	host.Initialize([]host.Config{{Name: "localhost", Certificate: cer}})
+	storage.Initialize(&storage.Config{Type: storage.Memory})
+	router.Initialize(&router.Config{})
+	defer func() {
+		router.Shutdown()
+		storage.Shutdown()
+		host.Shutdown()
+	}()
+
+	cfg := Config{
+		ConnectTimeout: time.Second * time.Duration(5),
+		MaxStanzaSize:  8192,
+		Transport: TransportConfig{
+			Port:      12779,
+			KeepAlive: time.Duration(600) * time.Second,
+			DirectTLS: true,
+		},
+	}
+	go Initialize(&cfg)
+	defer Shutdown()
+
+	time.Sleep(time.Millisecond * 150)
+
+	conn, err := tls.Dial("tcp", "127.0.0.1:12779", &tls.Config{
+		ServerName:         "localhost",
+		NextProtos:         []string{"xmpp-server"},
+		InsecureSkipVerify: true,
+	})
+	require.Nil(t, err)
+	defer conn.Close()
+
+	require.Equal(t, "xmpp-server", conn.ConnectionState().NegotiatedProtocol)
+
+	_, err = conn.Write([]byte(`<?xml version="1.0"?><stream:stream xmlns:stream="http://etherx.jabber.org/streams" version="1.0" xmlns="jabber:server" from="jackal.im" to="localhost">`))
+	require.Nil(t, err)
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	var resp string
+	buf := make([]byte, 4096)
+	for !strings.Contains(resp, "</stream:features>") {
+		n, err := conn.Read(buf)
+		require.Nil(t, err)
+		resp += string(buf[:n])
+	}
+	// already secured stream
+	require.False(t, strings.Contains(resp, "starttls"))
+	require.True(t, strings.Contains(resp, "dialback"))
+}
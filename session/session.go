@@ -6,6 +6,7 @@
 package session
 
 import (
+	"bytes"
 	stdxml "encoding/xml"
 	"fmt"
 	"io"
@@ -17,6 +18,7 @@ import (
 	"github.com/ortuman/jackal/errors"
 	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/router"
 	"github.com/ortuman/jackal/transport"
 	"github.com/ortuman/jackal/xmpp"
 	"github.com/ortuman/jackal/xmpp/jid"
@@ -25,11 +27,12 @@ import (
 )
 
 const (
-	jabberClientNamespace = "jabber:client"
-	jabberServerNamespace = "jabber:server"
-	framedStreamNamespace = "urn:ietf:params:xml:ns:xmpp-framing"
-	streamNamespace       = "http://etherx.jabber.org/streams"
-	dialbackNamespace     = "jabber:server:dialback"
+	jabberClientNamespace    = "jabber:client"
+	jabberServerNamespace    = "jabber:server"
+	jabberComponentNamespace = "jabber:component:accept"
+	framedStreamNamespace    = "urn:ietf:params:xml:ns:xmpp-framing"
+	streamNamespace          = "http://etherx.jabber.org/streams"
+	dialbackNamespace        = "jabber:server:dialback"
 )
 
 type namespaceSettable interface {
@@ -69,6 +72,10 @@ type Config struct {
 	// IsInitiating defines whether or not this is an initiating
 	// entity session.
 	IsInitiating bool
+
+	// IsComponent defines whether or not this session is established
+	// by an external component (XEP-0114).
+	IsComponent bool
 }
 
 // Session represents an XMPP session between the two peers.
@@ -79,8 +86,10 @@ type Session struct {
 	remoteDomain string
 	isServer     bool
 	isInitiating bool
+	isComponent  bool
 	opened       uint32
 	started      uint32
+	closedByPeer uint32
 
 	mu       sync.RWMutex
 	streamID string
@@ -93,7 +102,7 @@ func New(id string, config *Config) *Session {
 	switch config.Transport.Type() {
 	case transport.Socket:
 		parsingMode = xmpp.SocketStream
-	case transport.WebSocket:
+	case transport.WebSocket, transport.BOSH:
 		parsingMode = xmpp.WebSocketStream
 	}
 	s := &Session{
@@ -103,6 +112,7 @@ func New(id string, config *Config) *Session {
 		remoteDomain: config.RemoteDomain,
 		isServer:     config.IsServer,
 		isInitiating: config.IsInitiating,
+		isComponent:  config.IsComponent,
 		sJID:         config.JID,
 	}
 	if !s.isInitiating {
@@ -151,7 +161,7 @@ func (s *Session) Open() error {
 		}
 		buf.WriteString(`<?xml version="1.0"?>`)
 
-	case transport.WebSocket:
+	case transport.WebSocket, transport.BOSH:
 		ops = xmpp.NewElementName("open")
 		ops.SetAttribute("xmlns", framedStreamNamespace)
 		includeClosing = true
@@ -170,7 +180,9 @@ func (s *Session) Open() error {
 		ops.SetAttribute("to", s.remoteDomain)
 		s.mu.RUnlock()
 	}
-	ops.SetAttribute("version", "1.0")
+	if !s.isComponent {
+		ops.SetAttribute("version", "1.0")
+	}
 	ops.ToXML(buf, includeClosing)
 
 	openStr := buf.String()
@@ -189,12 +201,18 @@ func (s *Session) Close() error {
 	switch s.tr.Type() {
 	case transport.Socket:
 		io.WriteString(s.tr, "</stream:stream>")
-	case transport.WebSocket:
+	case transport.WebSocket, transport.BOSH:
 		io.WriteString(s.tr, fmt.Sprintf(`<close xmlns="%s" />`, framedStreamNamespace))
 	}
 	return nil
 }
 
+// IsClosedByPeer returns whether or not remote peer
+// gracefully closed the stream.
+func (s *Session) IsClosedByPeer() bool {
+	return atomic.LoadUint32(&s.closedByPeer) == 1
+}
+
 // Send writes an XML element to the underlying session transport.
 func (s *Session) Send(elem xmpp.XElement) {
 	// clear namespace if sending a stanza
@@ -202,7 +220,12 @@ func (s *Session) Send(elem xmpp.XElement) {
 		e.SetNamespace("")
 	}
 	log.Debugf("SEND(%s): %v", s.id, elem)
-	elem.ToXML(s.tr, true)
+
+	// serialize element before writing it, so that framed
+	// transports receive it at once
+	buf := &bytes.Buffer{}
+	elem.ToXML(buf, true)
+	s.tr.Write(buf.Bytes())
 }
 
 // Receive returns next incoming session element.
@@ -276,7 +299,7 @@ func (s *Session) extractAddresses(elem xmpp.XElement) (*jid.JID, *jid.JID, *Err
 	var err error
 
 	from := elem.From()
-	if !s.isServer {
+	if !s.isServer && !s.isComponent {
 		// do not validate 'from' address until full user JID has been set
 		if s.jid().IsFullWithUser() {
 			if len(from) > 0 && !s.isValidFrom(from) {
@@ -331,7 +354,7 @@ func (s *Session) validateStreamElement(elem xmpp.XElement) *Error {
 			return &Error{UnderlyingErr: streamerror.ErrInvalidNamespace}
 		}
 
-	case transport.WebSocket:
+	case transport.WebSocket, transport.BOSH:
 		if elem.Name() != "open" {
 			return &Error{UnderlyingErr: streamerror.ErrUnsupportedStanzaType}
 		}
@@ -340,7 +363,14 @@ func (s *Session) validateStreamElement(elem xmpp.XElement) *Error {
 		}
 	}
 	to := elem.To()
-	if len(to) > 0 && !host.IsLocalHost(to) {
+	if s.isComponent {
+		// component streams are not versioned
+		if to != s.jid().Domain() {
+			return &Error{UnderlyingErr: streamerror.ErrHostUnknown}
+		}
+		return nil
+	}
+	if len(to) > 0 && !host.IsLocalHost(to) && !(s.isServer && router.IsComponentHost(to)) {
 		return &Error{UnderlyingErr: streamerror.ErrHostUnknown}
 	}
 	if elem.Version() != "1.0" {
@@ -358,6 +388,9 @@ func (s *Session) validateNamespace(elem xmpp.XElement) *Error {
 }
 
 func (s *Session) namespace() string {
+	if s.isComponent {
+		return jabberComponentNamespace
+	}
 	if s.isServer {
 		return jabberServerNamespace
 	}
@@ -376,6 +409,7 @@ func (s *Session) mapErrorToSessionError(err error) *Error {
 		break
 
 	case xmpp.ErrStreamClosedByPeer:
+		atomic.StoreUint32(&s.closedByPeer, 1)
 		s.Close()
 
 	case xmpp.ErrTooLargeStanza:
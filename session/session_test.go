@@ -11,6 +11,7 @@ import (
 	"crypto/x509"
 	stdxml "encoding/xml"
 	"io"
+	"net"
 	"testing"
 
 	"github.com/ortuman/jackal/errors"
@@ -43,6 +44,7 @@ func (t *fakeTransport) StartTLS(cfg *tls.Config, asClient bool)
 func (t *fakeTransport) EnableCompression(compress.Level)                             {}
 func (t *fakeTransport) ChannelBindingBytes(transport.ChannelBindingMechanism) []byte { return nil }
 func (t *fakeTransport) PeerCertificates() []*x509.Certificate                        { return nil }
+func (t *fakeTransport) RemoteAddr() net.Addr                                         { return nil }
 
 func TestSession_Open(t *testing.T) {
 	j, _ := jid.NewWithString("jackal.im", true)
@@ -370,7 +372,9 @@ func TestSession_MapErrorq(t *testing.T) {
 	require.Equal(t, &Error{}, sess.mapErrorToSessionError(nil))
 	require.Equal(t, &Error{}, sess.mapErrorToSessionError(io.EOF))
 	require.Equal(t, &Error{}, sess.mapErrorToSessionError(io.ErrUnexpectedEOF))
+	require.False(t, sess.IsClosedByPeer())
 	require.Equal(t, &Error{}, sess.mapErrorToSessionError(xmpp.ErrStreamClosedByPeer))
+	require.True(t, sess.IsClosedByPeer())
 
 	require.Equal(t, &Error{UnderlyingErr: streamerror.ErrPolicyViolation}, sess.mapErrorToSessionError(xmpp.ErrTooLargeStanza))
 	require.Equal(t, &Error{UnderlyingErr: streamerror.ErrInvalidXML}, sess.mapErrorToSessionError(&stdxml.SyntaxError{}))
@@ -378,3 +382,42 @@ func TestSession_MapErrorq(t *testing.T) {
 	er := errors.New("err")
 	require.Equal(t, &Error{UnderlyingErr: er}, sess.mapErrorToSessionError(er))
 }
+
+func TestSession_Component(t *testing.T) {
+	j, _ := jid.NewWithString("gateway.jackal.im", true)
+
+	tr := newFakeTransport(transport.Socket)
+	sess := New(uuid.New(), &Config{JID: j, Transport: tr, RemoteDomain: "gateway.jackal.im", IsComponent: true})
+	sess.Open()
+	pr := xmpp.NewParser(tr.wrBuf, xmpp.SocketStream, 0)
+	_, _ = pr.ParseElement() // read xml header
+	elem, err := pr.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "jabber:component:accept", elem.Namespace())
+	require.Equal(t, "gateway.jackal.im", elem.From())
+	require.Equal(t, sess.StreamID(), elem.ID())
+	require.Equal(t, "", elem.Version())
+
+	stm := xmpp.NewElementNamespace("stream:stream", "jabber:component:accept")
+	stm.SetAttribute("xmlns:stream", "http://etherx.jabber.org/streams")
+	stm.SetTo("jackal.im")
+	sErr := sess.validateStreamElement(stm)
+	require.NotNil(t, sErr)
+	require.Equal(t, streamerror.ErrHostUnknown, sErr.UnderlyingErr)
+
+	stm.SetTo("gateway.jackal.im")
+	require.Nil(t, sess.validateStreamElement(stm))
+
+	msg := xmpp.NewElementNamespace("message", "jabber:component:accept")
+	msg.SetFrom("ortuman@jackal.im")
+	msg.SetTo("noelia@jackal.im")
+	_, _, sErr = sess.extractAddresses(msg)
+	require.NotNil(t, sErr)
+	require.Equal(t, streamerror.ErrInvalidFrom, sErr.UnderlyingErr)
+
+	msg.SetFrom("ortuman@gateway.jackal.im/irc")
+	from, to, sErr := sess.extractAddresses(msg)
+	require.Nil(t, sErr)
+	require.Equal(t, "ortuman@gateway.jackal.im/irc", from.String())
+	require.Equal(t, "noelia@jackal.im", to.String())
+}
@@ -6,24 +6,24 @@
 CREATE TABLE IF NOT EXISTS users (
     username VARCHAR(256) PRIMARY KEY,
     password TEXT NOT NULL,
+    credentials TEXT NOT NULL,
     last_presence TEXT NOT NULL,
     last_presence_at DATETIME NOT NULL,
     updated_at DATETIME NOT NULL,
     created_at DATETIME NOT NULL
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-CREATE TABLE roster_notifications (
+CREATE TABLE IF NOT EXISTS roster_notifications (
     contact VARCHAR(256) NOT NULL,
     jid VARCHAR(512) NOT NULL,
     elements TEXT NOT NULL,
     updated_at DATETIME NOT NULL,
     created_at DATETIME NOT NULL,
-    PRIMARY KEY (contact, jid)
+    PRIMARY KEY (contact, jid),
+    INDEX i_roster_notifications_jid (jid)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-CREATE INDEX i_roster_notifications_jid ON roster_notifications(jid);
-
-CREATE TABLE roster_items (
+CREATE TABLE IF NOT EXISTS roster_items (
     username VARCHAR(256) NOT NULL,
     jid VARCHAR(512) NOT NULL,
     name TEXT NOT NULL,
@@ -33,41 +33,38 @@ CREATE TABLE roster_items (
     ver INT NOT NULL DEFAULT 0,
     updated_at DATETIME NOT NULL,
     created_at DATETIME NOT NULL,
-    PRIMARY KEY (username, jid)
+    PRIMARY KEY (username, jid),
+    INDEX i_roster_items_username (username),
+    INDEX i_roster_items_jid (jid)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-CREATE INDEX i_roster_items_username ON roster_items(username);
-CREATE INDEX i_roster_items_jid ON roster_items(jid);
-
-CREATE TABLE roster_versions (
+CREATE TABLE IF NOT EXISTS roster_versions (
     username VARCHAR(256) NOT NULL,
     ver INT NOT NULL DEFAULT 0,
     last_deletion_ver INT NOT NULL DEFAULT 0,
     updated_at DATETIME NOT NULL,
     created_at DATETIME NOT NULL,
     PRIMARY KEY (username)
-) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci;
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
 CREATE TABLE IF NOT EXISTS blocklist_items (
     username VARCHAR(256) NOT NULL,
     jid VARCHAR(512) NOT NULL,
     created_at DATETIME NOT NULL,
-    PRIMARY KEY(username, jid)
+    PRIMARY KEY (username, jid),
+    INDEX i_blocklist_items_username (username)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-CREATE INDEX i_blocklist_items_username ON blocklist_items(username);
-
 CREATE TABLE IF NOT EXISTS private_storage (
     username VARCHAR(256) NOT NULL,
     namespace VARCHAR(512) NOT NULL,
     data MEDIUMTEXT NOT NULL,
     updated_at DATETIME NOT NULL,
     created_at DATETIME NOT NULL,
-    PRIMARY KEY (username, namespace)
+    PRIMARY KEY (username, namespace),
+    INDEX i_private_storage_username (username)
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-CREATE INDEX i_private_storage_username ON private_storage(username);
-
 CREATE TABLE IF NOT EXISTS vcards (
     username VARCHAR(256) PRIMARY KEY,
     vcard MEDIUMTEXT NOT NULL,
@@ -78,7 +75,117 @@ CREATE TABLE IF NOT EXISTS vcards (
 CREATE TABLE IF NOT EXISTS offline_messages (
     username VARCHAR(256) NOT NULL,
     data MEDIUMTEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX i_offline_messages_username (username)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS muc_rooms (
+    room_jid VARCHAR(256) PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    password VARCHAR(256) NOT NULL,
+    public BOOL NOT NULL,
+    members_only BOOL NOT NULL,
+    moderated BOOL NOT NULL,
+    non_anonymous BOOL NOT NULL,
+    max_occupants INT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS muc_affiliations (
+    room_jid VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (room_jid, jid),
+    INDEX i_muc_affiliations_room_jid (room_jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS archive_messages (
+    id VARCHAR(64) PRIMARY KEY,
+    username VARCHAR(256) NOT NULL,
+    with_jid VARCHAR(512) NOT NULL,
+    data MEDIUMTEXT NOT NULL,
+    stamp DATETIME(6) NOT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX i_archive_messages_username_stamp (username, stamp)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS archive_prefs (
+    username VARCHAR(256) PRIMARY KEY,
+    default_mode VARCHAR(16) NOT NULL,
+    always TEXT NOT NULL,
+    never TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
     created_at DATETIME NOT NULL
 ) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
 
-CREATE INDEX i_offline_messages_username ON offline_messages(username);
+CREATE TABLE IF NOT EXISTS pubsub_nodes (
+    host VARCHAR(256) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    title TEXT NOT NULL,
+    access_model VARCHAR(32) NOT NULL,
+    max_items INT NOT NULL,
+    persist_items BOOL NOT NULL,
+    deliver_payloads BOOL NOT NULL,
+    notify_retract BOOL NOT NULL,
+    notify_delete BOOL NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, name)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS pubsub_affiliations (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, node, jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS pubsub_subscriptions (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    subid VARCHAR(64) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    subscription VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, node, jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS pubsub_items (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    item_id VARCHAR(256) NOT NULL,
+    publisher VARCHAR(256) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    stamp DATETIME(6) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, node, item_id),
+    INDEX i_pubsub_items_host_node_stamp (host, node, stamp)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS capabilities (
+    ver VARCHAR(256) PRIMARY KEY,
+    node VARCHAR(512) NOT NULL,
+    features TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci;
+
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    description TEXT NOT NULL,
+    applied_at DATETIME NOT NULL
+);
+
+INSERT INTO schema_migrations (version, description, applied_at) VALUES
+    (1, 'initial schema', NOW()),
+    (2, 'user credentials', NOW()),
+    (3, 'multi-user chat', NOW()),
+    (4, 'message archive', NOW()),
+    (5, 'publish-subscribe', NOW()),
+    (6, 'entity capabilities', NOW());
@@ -0,0 +1,199 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+CREATE TABLE IF NOT EXISTS users (
+    username VARCHAR(256) PRIMARY KEY,
+    password TEXT NOT NULL,
+    credentials TEXT NOT NULL DEFAULT '',
+    last_presence TEXT NOT NULL DEFAULT '',
+    last_presence_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS roster_notifications (
+    contact VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    elements TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (contact, jid)
+);
+
+CREATE INDEX IF NOT EXISTS i_roster_notifications_jid ON roster_notifications(jid);
+
+CREATE TABLE IF NOT EXISTS roster_items (
+    username VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    name TEXT NOT NULL,
+    subscription TEXT NOT NULL,
+    "groups" TEXT NOT NULL,
+    ask BOOLEAN NOT NULL,
+    ver INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (username, jid)
+);
+
+CREATE INDEX IF NOT EXISTS i_roster_items_username ON roster_items(username);
+CREATE INDEX IF NOT EXISTS i_roster_items_jid ON roster_items(jid);
+
+CREATE TABLE IF NOT EXISTS roster_versions (
+    username VARCHAR(256) NOT NULL,
+    ver INTEGER NOT NULL DEFAULT 0,
+    last_deletion_ver INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (username)
+);
+
+CREATE TABLE IF NOT EXISTS blocklist_items (
+    username VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (username, jid)
+);
+
+CREATE INDEX IF NOT EXISTS i_blocklist_items_username ON blocklist_items(username);
+
+CREATE TABLE IF NOT EXISTS private_storage (
+    username VARCHAR(256) NOT NULL,
+    namespace VARCHAR(512) NOT NULL,
+    data TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (username, namespace)
+);
+
+CREATE INDEX IF NOT EXISTS i_private_storage_username ON private_storage(username);
+
+CREATE TABLE IF NOT EXISTS vcards (
+    username VARCHAR(256) PRIMARY KEY,
+    vcard TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS offline_messages (
+    username VARCHAR(256) NOT NULL,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS i_offline_messages_username ON offline_messages(username);
+
+CREATE TABLE IF NOT EXISTS muc_rooms (
+    room_jid VARCHAR(256) PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    password VARCHAR(256) NOT NULL,
+    public BOOLEAN NOT NULL,
+    members_only BOOLEAN NOT NULL,
+    moderated BOOLEAN NOT NULL,
+    non_anonymous BOOLEAN NOT NULL,
+    max_occupants INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS muc_affiliations (
+    room_jid VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (room_jid, jid)
+);
+
+CREATE INDEX IF NOT EXISTS i_muc_affiliations_room_jid ON muc_affiliations(room_jid);
+
+CREATE TABLE IF NOT EXISTS archive_messages (
+    id VARCHAR(64) PRIMARY KEY,
+    username VARCHAR(256) NOT NULL,
+    with_jid VARCHAR(512) NOT NULL,
+    data TEXT NOT NULL,
+    stamp TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS i_archive_messages_username_stamp ON archive_messages(username, stamp);
+
+CREATE TABLE IF NOT EXISTS archive_prefs (
+    username VARCHAR(256) PRIMARY KEY,
+    default_mode VARCHAR(16) NOT NULL,
+    always TEXT NOT NULL,
+    never TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS pubsub_nodes (
+    host VARCHAR(256) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    title TEXT NOT NULL,
+    access_model VARCHAR(32) NOT NULL,
+    max_items INTEGER NOT NULL,
+    persist_items BOOLEAN NOT NULL,
+    deliver_payloads BOOLEAN NOT NULL,
+    notify_retract BOOLEAN NOT NULL,
+    notify_delete BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, name)
+);
+
+CREATE TABLE IF NOT EXISTS pubsub_affiliations (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, node, jid)
+);
+
+CREATE TABLE IF NOT EXISTS pubsub_subscriptions (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    subid VARCHAR(64) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    subscription VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, node, jid)
+);
+
+CREATE TABLE IF NOT EXISTS pubsub_items (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    item_id VARCHAR(256) NOT NULL,
+    publisher VARCHAR(256) NOT NULL,
+    payload TEXT NOT NULL,
+    stamp TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, node, item_id)
+);
+
+CREATE INDEX IF NOT EXISTS i_pubsub_items_host_node_stamp ON pubsub_items(host, node, stamp);
+
+CREATE TABLE IF NOT EXISTS capabilities (
+    ver VARCHAR(256) PRIMARY KEY,
+    node VARCHAR(512) NOT NULL,
+    features TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS schema_migrations (
+    version INT PRIMARY KEY,
+    description TEXT NOT NULL,
+    applied_at TIMESTAMP NOT NULL
+);
+
+INSERT INTO schema_migrations (version, description, applied_at) VALUES
+    (1, 'initial schema', NOW()),
+    (2, 'user credentials', NOW()),
+    (3, 'multi-user chat', NOW()),
+    (4, 'message archive', NOW()),
+    (5, 'publish-subscribe', NOW()),
+    (6, 'entity capabilities', NOW());
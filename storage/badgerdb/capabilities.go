@@ -0,0 +1,38 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model/capsmodel"
+)
+
+// InsertOrUpdateCapabilities inserts a new capabilities entity into storage,
+// or updates it in case it's been previously inserted.
+func (b *Storage) InsertOrUpdateCapabilities(caps *capsmodel.Capabilities) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(caps, b.capabilitiesKey(caps.Ver), tx)
+	})
+}
+
+// FetchCapabilities retrieves from storage a capabilities entity
+// associated to a given verification string.
+func (b *Storage) FetchCapabilities(ver string) (*capsmodel.Capabilities, error) {
+	var caps capsmodel.Capabilities
+	err := b.fetch(&caps, b.capabilitiesKey(ver))
+	switch err {
+	case nil:
+		return &caps, nil
+	case errBadgerDBEntityNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+func (b *Storage) capabilitiesKey(ver string) []byte {
+	return []byte("capabilities:" + ver)
+}
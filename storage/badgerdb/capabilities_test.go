@@ -0,0 +1,36 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/capsmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_Capabilities(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	caps := capsmodel.Capabilities{
+		Node:     "http://code.google.com/p/exodus",
+		Ver:      "QgayPKawpkPSDYmwT/WM94uAlu0=",
+		Features: []string{"http://jabber.org/protocol/caps", "http://jabber.org/protocol/disco#info"},
+	}
+	require.Nil(t, h.db.InsertOrUpdateCapabilities(&caps))
+
+	c, err := h.db.FetchCapabilities("QgayPKawpkPSDYmwT/WM94uAlu0=")
+	require.Nil(t, err)
+	require.NotNil(t, c)
+	require.Equal(t, caps, *c)
+
+	c, err = h.db.FetchCapabilities("q07IKJEyjvHSyhy//CH0CxmKi8w=")
+	require.Nil(t, err)
+	require.Nil(t, c)
+}
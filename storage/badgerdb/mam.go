@@ -0,0 +1,144 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"bytes"
+	"encoding/gob"
+	"fmt"
+
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model/mammodel"
+)
+
+// InsertArchiveMessage inserts a new message entity into
+// user's archive.
+func (b *Storage) InsertArchiveMessage(message *mammodel.Message) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		key := b.archiveMessageKey(message)
+		if err := b.insertOrUpdate(message, key, tx); err != nil {
+			return err
+		}
+		// index message key by identifier, so that pages can be seeked
+		return tx.Set(b.archiveMessageIDKey(message.Username, message.ID), key)
+	})
+}
+
+// FetchArchiveMessages retrieves from storage all user's archived
+// messages satisfying filter criteria, in chronological order.
+func (b *Storage) FetchArchiveMessages(username string, filter *mammodel.Filter) ([]mammodel.Message, error) {
+	var msgs []mammodel.Message
+	err := b.db.View(func(tx *badger.Txn) error {
+		prefix := []byte("archiveMessages:" + username + ":")
+		opts := badger.DefaultIteratorOptions
+		opts.Reverse = filter.IsBackwards()
+
+		seekKey := prefix
+		if opts.Reverse {
+			seekKey = append(append([]byte(nil), prefix...), 0xff)
+		}
+		cursor := filter.After
+		if opts.Reverse {
+			cursor = filter.Before
+		}
+		if len(cursor) > 0 {
+			key, err := b.getVal(b.archiveMessageIDKey(username, cursor), tx)
+			if err != nil {
+				return err
+			}
+			if key == nil {
+				return mammodel.ErrMessageNotFound
+			}
+			seekKey = key
+		}
+		iter := tx.NewIterator(opts)
+		defer iter.Close()
+
+		var skipped int
+		for iter.Seek(seekKey); iter.ValidForPrefix(prefix); iter.Next() {
+			if filter.Limit > 0 && len(msgs) == filter.Limit {
+				break
+			}
+			it := iter.Item()
+			if len(cursor) > 0 && bytes.Equal(it.Key(), seekKey) {
+				continue
+			}
+			val, err := it.Value()
+			if err != nil {
+				return err
+			}
+			var m mammodel.Message
+			m.FromGob(gob.NewDecoder(bytes.NewReader(val)))
+			if !filter.Matches(&m) {
+				continue
+			}
+			if skipped < filter.Offset {
+				skipped++
+				continue
+			}
+			msgs = append(msgs, m)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if filter.IsBackwards() {
+		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+			msgs[i], msgs[j] = msgs[j], msgs[i]
+		}
+	}
+	return msgs, nil
+}
+
+// InsertOrUpdateArchivePrefs inserts a new archiving preferences entity
+// into storage, or updates it in case it's been previously inserted.
+func (b *Storage) InsertOrUpdateArchivePrefs(prefs *mammodel.Prefs) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(prefs, b.archivePrefsKey(prefs.Username), tx)
+	})
+}
+
+// FetchArchivePrefs retrieves from storage user's archiving preferences.
+func (b *Storage) FetchArchivePrefs(username string) (*mammodel.Prefs, error) {
+	var prefs mammodel.Prefs
+	err := b.fetch(&prefs, b.archivePrefsKey(username))
+	switch err {
+	case nil:
+		return &prefs, nil
+	case errBadgerDBEntityNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// DeleteArchive deletes from storage all user's archived messages
+// along with its archiving preferences.
+func (b *Storage) DeleteArchive(username string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		if err := b.deletePrefix([]byte("archiveMessages:"+username+":"), tx); err != nil {
+			return err
+		}
+		if err := b.deletePrefix([]byte("archiveMessageIDs:"+username+":"), tx); err != nil {
+			return err
+		}
+		return b.delete(b.archivePrefsKey(username), tx)
+	})
+}
+
+func (b *Storage) archiveMessageKey(message *mammodel.Message) []byte {
+	// zero padded timestamp keeps keys sorted in chronological order
+	return []byte(fmt.Sprintf("archiveMessages:%s:%020d:%s", message.Username, message.Stamp.UnixNano(), message.ID))
+}
+
+func (b *Storage) archiveMessageIDKey(username, id string) []byte {
+	return []byte("archiveMessageIDs:" + username + ":" + id)
+}
+
+func (b *Storage) archivePrefsKey(username string) []byte {
+	return []byte("archivePrefs:" + username)
+}
@@ -0,0 +1,133 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_ArchiveMessages(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", true)
+	j2, _ := jid.NewWithString("noelia@jackal.im/garden", true)
+	j3, _ := jid.NewWithString("romeo@jackal.im/orchard", true)
+
+	now := time.Now()
+	m1 := tUtilArchiveMessage(j1, j2, now.Add(-time.Minute))
+	m2 := tUtilArchiveMessage(j1, j3, now)
+
+	require.Nil(t, h.db.InsertArchiveMessage(m2))
+	require.Nil(t, h.db.InsertArchiveMessage(m1))
+
+	msgs, err := h.db.FetchArchiveMessages("ortuman", &mammodel.Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(msgs))
+	require.Equal(t, m1.ID, msgs[0].ID)
+	require.Equal(t, m2.ID, msgs[1].ID)
+	require.Equal(t, m1.Message.String(), msgs[0].Message.String())
+
+	msgs, err = h.db.FetchArchiveMessages("ortuman", &mammodel.Filter{With: "romeo@jackal.im"})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(msgs))
+	require.Equal(t, m2.ID, msgs[0].ID)
+
+	msgs, err = h.db.FetchArchiveMessages("ortuman", &mammodel.Filter{Start: now.Add(-time.Second)})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(msgs))
+	require.Equal(t, m2.ID, msgs[0].ID)
+
+	// paged results
+	m3 := tUtilArchiveMessage(j1, j2, now.Add(time.Minute))
+	require.Nil(t, h.db.InsertArchiveMessage(m3))
+
+	msgs, err = h.db.FetchArchiveMessages("ortuman", &mammodel.Filter{After: m1.ID, Limit: 1})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(msgs))
+	require.Equal(t, m2.ID, msgs[0].ID)
+
+	msgs, err = h.db.FetchArchiveMessages("ortuman", &mammodel.Filter{Backwards: true, Limit: 2})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(msgs))
+	require.Equal(t, m2.ID, msgs[0].ID)
+	require.Equal(t, m3.ID, msgs[1].ID)
+
+	msgs, err = h.db.FetchArchiveMessages("ortuman", &mammodel.Filter{Before: m3.ID, With: "noelia@jackal.im"})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(msgs))
+	require.Equal(t, m1.ID, msgs[0].ID)
+
+	msgs, err = h.db.FetchArchiveMessages("ortuman", &mammodel.Filter{Offset: 1, Limit: 1})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(msgs))
+	require.Equal(t, m2.ID, msgs[0].ID)
+
+	_, err = h.db.FetchArchiveMessages("ortuman", &mammodel.Filter{After: uuid.New()})
+	require.Equal(t, mammodel.ErrMessageNotFound, err)
+
+	msgs, err = h.db.FetchArchiveMessages("ortuman2", &mammodel.Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 0, len(msgs))
+
+	require.Nil(t, h.db.DeleteArchive("ortuman"))
+
+	msgs, err = h.db.FetchArchiveMessages("ortuman", &mammodel.Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 0, len(msgs))
+}
+
+func TestBadgerDB_ArchivePrefs(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	prefs := mammodel.Prefs{
+		Username: "ortuman",
+		Default:  mammodel.DefaultRoster,
+		Always:   []string{"noelia@jackal.im"},
+		Never:    []string{"romeo@jackal.im"},
+	}
+	require.Nil(t, h.db.InsertOrUpdateArchivePrefs(&prefs))
+
+	p, err := h.db.FetchArchivePrefs("ortuman")
+	require.Nil(t, err)
+	require.NotNil(t, p)
+	require.Equal(t, prefs, *p)
+
+	p, err = h.db.FetchArchivePrefs("noelia")
+	require.Nil(t, err)
+	require.Nil(t, p)
+
+	require.Nil(t, h.db.DeleteArchive("ortuman"))
+
+	p, err = h.db.FetchArchivePrefs("ortuman")
+	require.Nil(t, err)
+	require.Nil(t, p)
+}
+
+func tUtilArchiveMessage(from, to *jid.JID, stamp time.Time) *mammodel.Message {
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	return &mammodel.Message{
+		ID:       uuid.New(),
+		Username: from.Node(),
+		With:     to.ToBareJID().String(),
+		Message:  msg,
+		Stamp:    stamp,
+	}
+}
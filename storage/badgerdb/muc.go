@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model/mucmodel"
+)
+
+// InsertOrUpdateRoom inserts a new room entity into storage,
+// or updates it in case it's been previously inserted.
+func (b *Storage) InsertOrUpdateRoom(room *mucmodel.Room) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(room, b.roomKey(room.RoomJID), tx)
+	})
+}
+
+// DeleteRoom deletes a room entity from storage.
+func (b *Storage) DeleteRoom(roomJID string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.delete(b.roomKey(roomJID), tx)
+	})
+}
+
+// FetchRoom retrieves from storage a room entity.
+func (b *Storage) FetchRoom(roomJID string) (*mucmodel.Room, error) {
+	var room mucmodel.Room
+	err := b.fetch(&room, b.roomKey(roomJID))
+	switch err {
+	case nil:
+		return &room, nil
+	case errBadgerDBEntityNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchRooms retrieves from storage all persisted room entities.
+func (b *Storage) FetchRooms() ([]mucmodel.Room, error) {
+	var rooms []mucmodel.Room
+	if err := b.fetchAll(&rooms, []byte("mucRooms:")); err != nil {
+		return nil, err
+	}
+	return rooms, nil
+}
+
+func (b *Storage) roomKey(roomJID string) []byte {
+	return []byte("mucRooms:" + roomJID)
+}
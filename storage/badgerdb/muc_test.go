@@ -0,0 +1,53 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_Rooms(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	room := mucmodel.Room{
+		RoomJID:      "coven@conference.jackal.im",
+		Name:         "The Coven",
+		Subject:      "Fire Burn and Cauldron Bubble!",
+		Public:       true,
+		MaxOccupants: 30,
+		Affiliations: []mucmodel.Affiliation{
+			{JID: "ortuman@jackal.im", Affiliation: mucmodel.AffiliationOwner},
+		},
+	}
+	require.Nil(t, h.db.InsertOrUpdateRoom(&room))
+
+	r, err := h.db.FetchRoom("coven@conference.jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, r)
+	require.Equal(t, room, *r)
+
+	r, err = h.db.FetchRoom("darkcave@conference.jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, r)
+
+	require.Nil(t, h.db.InsertOrUpdateRoom(&mucmodel.Room{RoomJID: "darkcave@conference.jackal.im"}))
+
+	rooms, err := h.db.FetchRooms()
+	require.Nil(t, err)
+	require.Equal(t, 2, len(rooms))
+
+	require.Nil(t, h.db.DeleteRoom("coven@conference.jackal.im"))
+
+	r, err = h.db.FetchRoom("coven@conference.jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, r)
+}
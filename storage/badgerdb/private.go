@@ -34,6 +34,29 @@ func (b *Storage) FetchPrivateXML(namespace string, username string) ([]xmpp.XEl
 	}
 }
 
+// FetchPrivateXMLNamespaces retrieves from storage all private element
+// namespaces associated to a given user.
+func (b *Storage) FetchPrivateXMLNamespaces(username string) ([]string, error) {
+	var ret []string
+	prefix := b.privateStorageKey(username, "")
+	err := b.forEachKey(prefix, func(k []byte) error {
+		ret = append(ret, string(k[len(prefix):]))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// DeletePrivateXML deletes from storage all private elements
+// associated to a given user.
+func (b *Storage) DeletePrivateXML(username string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.deletePrefix(b.privateStorageKey(username, ""), tx)
+	})
+}
+
 func (b *Storage) privateStorageKey(username, namespace string) []byte {
 	return []byte("privateElements:" + username + ":" + namespace)
 }
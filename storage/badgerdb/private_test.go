@@ -30,4 +30,18 @@ func TestBadgerDB_PrivateXML(t *testing.T) {
 	prvs2, err := h.db.FetchPrivateXML("exodus:ns", "ortuman2")
 	require.Nil(t, prvs2)
 	require.Nil(t, err)
+
+	namespaces, err := h.db.FetchPrivateXMLNamespaces("ortuman")
+	require.Nil(t, err)
+	require.Equal(t, []string{"exodus:ns"}, namespaces)
+
+	namespaces, err = h.db.FetchPrivateXMLNamespaces("ortuman2")
+	require.Nil(t, err)
+	require.Nil(t, namespaces)
+
+	require.Nil(t, h.db.DeletePrivateXML("ortuman"))
+
+	prvs, err = h.db.FetchPrivateXML("exodus:ns", "ortuman")
+	require.Nil(t, err)
+	require.Nil(t, prvs)
 }
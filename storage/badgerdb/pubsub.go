@@ -0,0 +1,96 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/dgraph-io/badger"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+)
+
+// InsertOrUpdatePubSubNode inserts a new pubsub node entity into storage,
+// or updates it in case it's been previously inserted.
+func (b *Storage) InsertOrUpdatePubSubNode(node *pubsubmodel.Node) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(node, b.pubSubNodeKey(node.Host, node.Name), tx)
+	})
+}
+
+// FetchPubSubNode retrieves from storage a pubsub node entity.
+func (b *Storage) FetchPubSubNode(host, name string) (*pubsubmodel.Node, error) {
+	var node pubsubmodel.Node
+	err := b.fetch(&node, b.pubSubNodeKey(host, name))
+	switch err {
+	case nil:
+		return &node, nil
+	case errBadgerDBEntityNotFound:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchPubSubNodes retrieves from storage all pubsub node entities
+// associated to a given host.
+func (b *Storage) FetchPubSubNodes(host string) ([]pubsubmodel.Node, error) {
+	var nodes []pubsubmodel.Node
+	if err := b.fetchAll(&nodes, []byte("pubSubNodes:"+host+":")); err != nil {
+		return nil, err
+	}
+	return nodes, nil
+}
+
+// DeletePubSubNode deletes a pubsub node entity from storage,
+// along with all its published items.
+func (b *Storage) DeletePubSubNode(host, name string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		if err := b.deletePrefix(b.pubSubItemsPrefix(host, name), tx); err != nil {
+			return err
+		}
+		return b.delete(b.pubSubNodeKey(host, name), tx)
+	})
+}
+
+// InsertOrUpdatePubSubNodeItem inserts a new pubsub item entity into storage,
+// or updates it in case it's been previously inserted.
+func (b *Storage) InsertOrUpdatePubSubNodeItem(item *pubsubmodel.Item, host, name string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.insertOrUpdate(item, b.pubSubItemKey(host, name, item.ID), tx)
+	})
+}
+
+// DeletePubSubNodeItem deletes a pubsub item entity from storage.
+func (b *Storage) DeletePubSubNodeItem(host, name, itemID string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.delete(b.pubSubItemKey(host, name, itemID), tx)
+	})
+}
+
+// FetchPubSubNodeItems retrieves from storage all items published
+// to a given node, in chronological order.
+func (b *Storage) FetchPubSubNodeItems(host, name string) ([]pubsubmodel.Item, error) {
+	var items []pubsubmodel.Item
+	if err := b.fetchAll(&items, b.pubSubItemsPrefix(host, name)); err != nil {
+		return nil, err
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Stamp.Before(items[j].Stamp) })
+	return items, nil
+}
+
+func (b *Storage) pubSubNodeKey(host, name string) []byte {
+	return []byte("pubSubNodes:" + host + ":" + name)
+}
+
+func (b *Storage) pubSubItemsPrefix(host, name string) []byte {
+	// node name is length prefixed, as it may contain any separator character
+	return []byte(fmt.Sprintf("pubSubItems:%s:%d:%s:", host, len(name), name))
+}
+
+func (b *Storage) pubSubItemKey(host, name, itemID string) []byte {
+	return append(b.pubSubItemsPrefix(host, name), itemID...)
+}
@@ -0,0 +1,97 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package badgerdb
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBadgerDB_PubSubNodes(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	node := pubsubmodel.Node{
+		Host:    "pubsub.jackal.im",
+		Name:    "princely_musings",
+		Options: pubsubmodel.Options{AccessModel: pubsubmodel.AccessModelOpen, MaxItems: 10},
+		Affiliations: []pubsubmodel.Affiliation{
+			{JID: "ortuman@jackal.im", Affiliation: pubsubmodel.AffiliationOwner},
+		},
+		Subscriptions: []pubsubmodel.Subscription{
+			{SubID: "1", JID: "noelia@jackal.im", Subscription: pubsubmodel.SubscriptionSubscribed},
+		},
+	}
+	require.Nil(t, h.db.InsertOrUpdatePubSubNode(&node))
+
+	n, err := h.db.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.NotNil(t, n)
+	require.Equal(t, node, *n)
+
+	n, err = h.db.FetchPubSubNode("pubsub.jackal.im", "news")
+	require.Nil(t, err)
+	require.Nil(t, n)
+
+	node2 := pubsubmodel.Node{Host: "pubsub.jackal.im", Name: "news"}
+	require.Nil(t, h.db.InsertOrUpdatePubSubNode(&node2))
+
+	nodes, err := h.db.FetchPubSubNodes("pubsub.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(nodes))
+
+	require.Nil(t, h.db.DeletePubSubNode("pubsub.jackal.im", "news"))
+
+	nodes, err = h.db.FetchPubSubNodes("pubsub.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(nodes))
+}
+
+func TestBadgerDB_PubSubNodeItems(t *testing.T) {
+	t.Parallel()
+
+	h := tUtilBadgerDBSetup()
+	defer tUtilBadgerDBTeardown(h)
+
+	payload := xmpp.NewElementNamespace("entry", "http://www.w3.org/2005/Atom")
+	now := time.Now()
+	i1 := pubsubmodel.Item{ID: "1", Publisher: "ortuman@jackal.im", Payload: payload, Stamp: now}
+	i2 := pubsubmodel.Item{ID: "2", Publisher: "ortuman@jackal.im", Payload: payload, Stamp: now.Add(time.Second)}
+	i3 := pubsubmodel.Item{ID: "3", Publisher: "ortuman@jackal.im", Payload: payload, Stamp: now}
+
+	require.Nil(t, h.db.InsertOrUpdatePubSubNodeItem(&i2, "pubsub.jackal.im", "princely_musings"))
+	require.Nil(t, h.db.InsertOrUpdatePubSubNodeItem(&i1, "pubsub.jackal.im", "princely_musings"))
+	require.Nil(t, h.db.InsertOrUpdatePubSubNodeItem(&i3, "pubsub.jackal.im", "princely_musings:2"))
+
+	items, err := h.db.FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(items))
+	require.Equal(t, "1", items[0].ID)
+	require.Equal(t, "2", items[1].ID)
+	require.Equal(t, payload.String(), items[0].Payload.String())
+
+	require.Nil(t, h.db.DeletePubSubNodeItem("pubsub.jackal.im", "princely_musings", "1"))
+
+	items, err = h.db.FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(items))
+
+	require.Nil(t, h.db.DeletePubSubNode("pubsub.jackal.im", "princely_musings"))
+
+	items, err = h.db.FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Equal(t, 0, len(items))
+
+	items, err = h.db.FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings:2")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(items))
+}
@@ -52,6 +52,20 @@ func (b *Storage) UserExists(username string) (bool, error) {
 	}
 }
 
+// FetchUsernames retrieves from storage the username of every user entity.
+func (b *Storage) FetchUsernames() ([]string, error) {
+	var ret []string
+	prefix := []byte("users:")
+	err := b.forEachKey(prefix, func(k []byte) error {
+		ret = append(ret, string(k[len(prefix):]))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
 func (b *Storage) userKey(username string) []byte {
 	return []byte("users:" + username)
 }
@@ -19,6 +19,7 @@ func TestBadgerDB_User(t *testing.T) {
 	defer tUtilBadgerDBTeardown(h)
 
 	usr := model.User{Username: "ortuman", Password: "1234"}
+	usr.Credentials = []model.Credential{{Hash: "sha-1", Salt: []byte{1}, Iterations: 4096, StoredKey: []byte{2}, ServerKey: []byte{3}}}
 
 	err := h.db.InsertOrUpdateUser(&usr)
 	require.Nil(t, err)
@@ -27,11 +28,16 @@ func TestBadgerDB_User(t *testing.T) {
 	require.Nil(t, err)
 	require.Equal(t, "ortuman", usr2.Username)
 	require.Equal(t, "1234", usr2.Password)
+	require.Equal(t, usr.Credentials, usr2.Credentials)
 
 	exists, err := h.db.UserExists("ortuman")
 	require.Nil(t, err)
 	require.True(t, exists)
 
+	usernames, err := h.db.FetchUsernames()
+	require.Nil(t, err)
+	require.Equal(t, []string{"ortuman"}, usernames)
+
 	usr3, err := h.db.FetchUser("ortuman2")
 	require.Nil(t, usr3)
 	require.Nil(t, err)
@@ -33,6 +33,14 @@ func (b *Storage) FetchVCard(username string) (xmpp.XElement, error) {
 	}
 }
 
+// DeleteVCard deletes from storage a vCard element associated
+// to a given user.
+func (b *Storage) DeleteVCard(username string) error {
+	return b.db.Update(func(tx *badger.Txn) error {
+		return b.delete(b.vCardKey(username), tx)
+	})
+}
+
 func (b *Storage) vCardKey(username string) []byte {
 	return []byte("vCards:" + username)
 }
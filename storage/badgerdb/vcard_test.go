@@ -35,4 +35,10 @@ func TestBadgerDB_VCard(t *testing.T) {
 	vcard3, err := h.db.FetchVCard("ortuman2")
 	require.Nil(t, vcard3)
 	require.Nil(t, err)
+
+	require.Nil(t, h.db.DeleteVCard("ortuman"))
+
+	vcard4, err := h.db.FetchVCard("ortuman")
+	require.Nil(t, vcard4)
+	require.Nil(t, err)
 }
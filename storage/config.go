@@ -13,7 +13,10 @@ import (
 	"github.com/ortuman/jackal/storage/sql"
 )
 
-const defaultMySQLPoolSize = 16
+const (
+	defaultMySQLPoolSize      = 16
+	defaultPostgreSQLPoolSize = 16
+)
 
 // StorageType represents a storage manager type.
 type StorageType int
@@ -27,19 +30,29 @@ const (
 
 	// Memory represents a in-memstorage storage type.
 	Memory
+
+	// PostgreSQL represents a PostgreSQL storage type.
+	PostgreSQL
+
+	// SQLite represents an embedded SQLite storage type.
+	SQLite
 )
 
 // Config represents an storage manager configuration.
 type Config struct {
-	Type     StorageType
-	MySQL    *sql.Config
-	BadgerDB *badgerdb.Config
+	Type       StorageType
+	MySQL      *sql.Config
+	PostgreSQL *sql.Config
+	SQLite     *sql.SQLiteConfig
+	BadgerDB   *badgerdb.Config
 }
 
 type storageProxyType struct {
-	Type     string           `yaml:"type"`
-	MySQL    *sql.Config      `yaml:"mysql"`
-	BadgerDB *badgerdb.Config `yaml:"badgerdb"`
+	Type       string            `yaml:"type"`
+	MySQL      *sql.Config       `yaml:"mysql"`
+	PostgreSQL *sql.Config       `yaml:"postgresql"`
+	SQLite     *sql.SQLiteConfig `yaml:"sqlite"`
+	BadgerDB   *badgerdb.Config  `yaml:"badgerdb"`
 }
 
 // UnmarshalYAML satisfies Unmarshaler interface.
@@ -61,6 +74,32 @@ func (c *Config) UnmarshalYAML(unmarshal func(interface{}) error) error {
 			c.MySQL.PoolSize = defaultMySQLPoolSize
 		}
 
+	case "postgresql":
+		if p.PostgreSQL == nil {
+			return errors.New("storage.Config: couldn't read PostgreSQL configuration")
+		}
+		c.Type = PostgreSQL
+
+		// assign storage defaults
+		c.PostgreSQL = p.PostgreSQL
+		if c.PostgreSQL.PoolSize == 0 {
+			c.PostgreSQL.PoolSize = defaultPostgreSQLPoolSize
+		}
+
+	case "sqlite":
+		if !sql.SQLiteSupported {
+			return errors.New("storage.Config: SQLite support not available, jackal must be built with 'sqlite' tag")
+		}
+		if p.SQLite == nil {
+			return errors.New("storage.Config: couldn't read SQLite configuration")
+		}
+		c.Type = SQLite
+
+		c.SQLite = p.SQLite
+		if len(c.SQLite.Path) == 0 {
+			c.SQLite.Path = "./jackal.db"
+		}
+
 	case "badgerdb":
 		if p.BadgerDB == nil {
 			return errors.New("storage.Config: couldn't read BadgerDB configuration")
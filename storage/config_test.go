@@ -8,6 +8,7 @@ package storage
 import (
 	"testing"
 
+	"github.com/ortuman/jackal/storage/sql"
 	"github.com/stretchr/testify/require"
 	"gopkg.in/yaml.v2"
 )
@@ -60,6 +61,58 @@ func TestStorageConfig(t *testing.T) {
 	err = yaml.Unmarshal([]byte(invalidMySQLCfg), &cfg)
 	require.NotNil(t, err)
 
+	postgreSQLCfg := `
+  type: postgresql
+  postgresql:
+    host: 127.0.0.1:5432
+    user: jackal
+    password: password
+    database: jackaldb
+    ssl_mode: disable
+`
+
+	err = yaml.Unmarshal([]byte(postgreSQLCfg), &cfg)
+	require.Nil(t, err)
+	require.Equal(t, PostgreSQL, cfg.Type)
+	require.Equal(t, "jackal", cfg.PostgreSQL.User)
+	require.Equal(t, "jackaldb", cfg.PostgreSQL.Database)
+	require.Equal(t, "disable", cfg.PostgreSQL.SSLMode)
+	require.Equal(t, defaultPostgreSQLPoolSize, cfg.PostgreSQL.PoolSize)
+
+	invalidPostgreSQLCfg := `
+  type: postgresql
+`
+	err = yaml.Unmarshal([]byte(invalidPostgreSQLCfg), &cfg)
+	require.NotNil(t, err)
+
+	sqliteCfg := `
+  type: sqlite
+  sqlite:
+    path: /var/lib/jackal/jackal.db
+`
+	err = yaml.Unmarshal([]byte(sqliteCfg), &cfg)
+	if sql.SQLiteSupported {
+		require.Nil(t, err)
+		require.Equal(t, SQLite, cfg.Type)
+		require.Equal(t, "/var/lib/jackal/jackal.db", cfg.SQLite.Path)
+
+		sqliteCfg2 := `
+  type: sqlite
+  sqlite: {}
+`
+		err = yaml.Unmarshal([]byte(sqliteCfg2), &cfg)
+		require.Nil(t, err)
+		require.Equal(t, "./jackal.db", cfg.SQLite.Path)
+	} else {
+		require.NotNil(t, err) // built without SQLite support
+	}
+
+	invalidSQLiteCfg := `
+  type: sqlite
+`
+	err = yaml.Unmarshal([]byte(invalidSQLiteCfg), &cfg)
+	require.NotNil(t, err)
+
 	invalidCfg := `
   type: invalid
 `
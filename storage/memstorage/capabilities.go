@@ -0,0 +1,34 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import "github.com/ortuman/jackal/model/capsmodel"
+
+// InsertOrUpdateCapabilities inserts a new capabilities entity into storage,
+// or updates it in case it's been previously inserted.
+func (m *Storage) InsertOrUpdateCapabilities(caps *capsmodel.Capabilities) error {
+	return m.inWriteLock(func() error {
+		c := *caps
+		c.Features = append([]string(nil), caps.Features...)
+		m.capabilities[caps.Ver] = &c
+		return nil
+	})
+}
+
+// FetchCapabilities retrieves from storage a capabilities entity
+// associated to a given verification string.
+func (m *Storage) FetchCapabilities(ver string) (*capsmodel.Capabilities, error) {
+	var ret *capsmodel.Capabilities
+	err := m.inReadLock(func() error {
+		if c := m.capabilities[ver]; c != nil {
+			caps := *c
+			caps.Features = append([]string(nil), c.Features...)
+			ret = &caps
+		}
+		return nil
+	})
+	return ret, err
+}
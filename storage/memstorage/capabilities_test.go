@@ -0,0 +1,39 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/capsmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStorageInsertOrUpdateCapabilities(t *testing.T) {
+	caps := capsmodel.Capabilities{
+		Node:     "http://code.google.com/p/exodus",
+		Ver:      "QgayPKawpkPSDYmwT/WM94uAlu0=",
+		Features: []string{"http://jabber.org/protocol/caps"},
+	}
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdateCapabilities(&caps))
+	s.DeactivateMockedError()
+	require.Nil(t, s.InsertOrUpdateCapabilities(&caps))
+
+	s.ActivateMockedError()
+	_, err := s.FetchCapabilities("QgayPKawpkPSDYmwT/WM94uAlu0=")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	c, err := s.FetchCapabilities("QgayPKawpkPSDYmwT/WM94uAlu0=")
+	require.Nil(t, err)
+	require.Equal(t, caps, *c)
+
+	c, err = s.FetchCapabilities("q07IKJEyjvHSyhy//CH0CxmKi8w=")
+	require.Nil(t, err)
+	require.Nil(t, c)
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"sort"
+
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+// InsertArchiveMessage inserts a new message entity into
+// user's archive.
+func (m *Storage) InsertArchiveMessage(message *mammodel.Message) error {
+	return m.inWriteLock(func() error {
+		am := *message
+		am.Message, _ = xmpp.NewMessageFromElement(message.Message, message.Message.FromJID(), message.Message.ToJID())
+
+		msgs := append(m.archiveMessages[message.Username], am)
+		sort.SliceStable(msgs, func(i, j int) bool { return msgs[i].Stamp.Before(msgs[j].Stamp) })
+		m.archiveMessages[message.Username] = msgs
+		return nil
+	})
+}
+
+// FetchArchiveMessages retrieves from storage all user's archived
+// messages satisfying filter criteria, in chronological order.
+func (m *Storage) FetchArchiveMessages(username string, filter *mammodel.Filter) ([]mammodel.Message, error) {
+	var ret []mammodel.Message
+	err := m.inReadLock(func() error {
+		var err error
+		ret, err = filter.Page(m.archiveMessages[username])
+		return err
+	})
+	return ret, err
+}
+
+// InsertOrUpdateArchivePrefs inserts a new archiving preferences entity
+// into storage, or updates it in case it's been previously inserted.
+func (m *Storage) InsertOrUpdateArchivePrefs(prefs *mammodel.Prefs) error {
+	return m.inWriteLock(func() error {
+		p := *prefs
+		p.Always = append([]string(nil), prefs.Always...)
+		p.Never = append([]string(nil), prefs.Never...)
+		m.archivePrefs[prefs.Username] = &p
+		return nil
+	})
+}
+
+// FetchArchivePrefs retrieves from storage user's archiving preferences.
+func (m *Storage) FetchArchivePrefs(username string) (*mammodel.Prefs, error) {
+	var ret *mammodel.Prefs
+	err := m.inReadLock(func() error {
+		if p := m.archivePrefs[username]; p != nil {
+			prefs := *p
+			ret = &prefs
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// DeleteArchive deletes from storage all user's archived messages
+// along with its archiving preferences.
+func (m *Storage) DeleteArchive(username string) error {
+	return m.inWriteLock(func() error {
+		delete(m.archiveMessages, username)
+		delete(m.archivePrefs, username)
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStorageInsertArchiveMessage(t *testing.T) {
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", true)
+	j2, _ := jid.NewWithString("noelia@jackal.im/garden", true)
+	j3, _ := jid.NewWithString("romeo@jackal.im/orchard", true)
+
+	now := time.Now()
+	m1 := tUtilArchiveMessage(j1, j2, now.Add(-time.Minute))
+	m2 := tUtilArchiveMessage(j1, j3, now)
+
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertArchiveMessage(m2))
+	s.DeactivateMockedError()
+	require.Nil(t, s.InsertArchiveMessage(m2))
+	require.Nil(t, s.InsertArchiveMessage(m1))
+
+	s.ActivateMockedError()
+	_, err := s.FetchArchiveMessages("ortuman", &mammodel.Filter{})
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	msgs, err := s.FetchArchiveMessages("ortuman", &mammodel.Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 2, len(msgs))
+	require.Equal(t, m1.ID, msgs[0].ID)
+	require.Equal(t, m2.ID, msgs[1].ID)
+
+	msgs, err = s.FetchArchiveMessages("ortuman", &mammodel.Filter{With: "romeo@jackal.im"})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(msgs))
+	require.Equal(t, m2.ID, msgs[0].ID)
+
+	msgs, err = s.FetchArchiveMessages("ortuman", &mammodel.Filter{End: now.Add(-time.Second)})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(msgs))
+	require.Equal(t, m1.ID, msgs[0].ID)
+
+	msgs, err = s.FetchArchiveMessages("noelia", &mammodel.Filter{})
+	require.Nil(t, err)
+	require.Equal(t, 0, len(msgs))
+}
+
+func TestMockStorageInsertOrUpdateArchivePrefs(t *testing.T) {
+	prefs := mammodel.Prefs{
+		Username: "ortuman",
+		Default:  mammodel.DefaultRoster,
+		Always:   []string{"noelia@jackal.im"},
+	}
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdateArchivePrefs(&prefs))
+	s.DeactivateMockedError()
+	require.Nil(t, s.InsertOrUpdateArchivePrefs(&prefs))
+
+	prefs.Default = mammodel.DefaultAlways
+	require.Nil(t, s.InsertOrUpdateArchivePrefs(&prefs))
+
+	s.ActivateMockedError()
+	_, err := s.FetchArchivePrefs("ortuman")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	p, err := s.FetchArchivePrefs("ortuman")
+	require.Nil(t, err)
+	require.NotNil(t, p)
+	require.Equal(t, prefs, *p)
+
+	p, err = s.FetchArchivePrefs("noelia")
+	require.Nil(t, err)
+	require.Nil(t, p)
+}
+
+func TestMockStorageDeleteArchive(t *testing.T) {
+	j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", true)
+	j2, _ := jid.NewWithString("noelia@jackal.im/garden", true)
+
+	s := New()
+	s.InsertArchiveMessage(tUtilArchiveMessage(j1, j2, time.Now()))
+	s.InsertOrUpdateArchivePrefs(&mammodel.Prefs{Username: "ortuman", Default: mammodel.DefaultAlways})
+
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.DeleteArchive("ortuman"))
+	s.DeactivateMockedError()
+	require.Nil(t, s.DeleteArchive("ortuman"))
+
+	msgs, _ := s.FetchArchiveMessages("ortuman", &mammodel.Filter{})
+	require.Equal(t, 0, len(msgs))
+	p, _ := s.FetchArchivePrefs("ortuman")
+	require.Nil(t, p)
+}
+
+func tUtilArchiveMessage(from, to *jid.JID, stamp time.Time) *mammodel.Message {
+	msg := xmpp.NewMessageType(uuid.New(), xmpp.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+	return &mammodel.Message{
+		ID:       uuid.New(),
+		Username: from.Node(),
+		With:     to.ToBareJID().String(),
+		Message:  msg,
+		Stamp:    stamp,
+	}
+}
@@ -11,6 +11,10 @@ import (
 	"sync/atomic"
 
 	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/capsmodel"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
 	"github.com/ortuman/jackal/model/rostermodel"
 	"github.com/ortuman/jackal/xmpp"
 )
@@ -31,6 +35,12 @@ type Storage struct {
 	privateXML          map[string][]xmpp.XElement
 	offlineMessages     map[string][]*xmpp.Message
 	blockListItems      map[string][]model.BlockListItem
+	rooms               map[string]*mucmodel.Room
+	archiveMessages     map[string][]mammodel.Message
+	archivePrefs        map[string]*mammodel.Prefs
+	pubSubNodes         map[string]map[string]*pubsubmodel.Node
+	pubSubItems         map[string]map[string][]pubsubmodel.Item
+	capabilities        map[string]*capsmodel.Capabilities
 }
 
 // New returns a new in memory storage instance.
@@ -44,6 +54,12 @@ func New() *Storage {
 		privateXML:          make(map[string][]xmpp.XElement),
 		offlineMessages:     make(map[string][]*xmpp.Message),
 		blockListItems:      make(map[string][]model.BlockListItem),
+		rooms:               make(map[string]*mucmodel.Room),
+		archiveMessages:     make(map[string][]mammodel.Message),
+		archivePrefs:        make(map[string]*mammodel.Prefs),
+		pubSubNodes:         make(map[string]map[string]*pubsubmodel.Node),
+		pubSubItems:         make(map[string]map[string][]pubsubmodel.Item),
+		capabilities:        make(map[string]*capsmodel.Capabilities),
 	}
 }
 
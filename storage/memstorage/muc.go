@@ -0,0 +1,52 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import "github.com/ortuman/jackal/model/mucmodel"
+
+// InsertOrUpdateRoom inserts a new room entity into storage,
+// or updates it in case it's been previously inserted.
+func (m *Storage) InsertOrUpdateRoom(room *mucmodel.Room) error {
+	return m.inWriteLock(func() error {
+		r := *room
+		r.Affiliations = append([]mucmodel.Affiliation(nil), room.Affiliations...)
+		m.rooms[room.RoomJID] = &r
+		return nil
+	})
+}
+
+// DeleteRoom deletes a room entity from storage.
+func (m *Storage) DeleteRoom(roomJID string) error {
+	return m.inWriteLock(func() error {
+		delete(m.rooms, roomJID)
+		return nil
+	})
+}
+
+// FetchRoom retrieves from storage a room entity.
+func (m *Storage) FetchRoom(roomJID string) (*mucmodel.Room, error) {
+	var ret *mucmodel.Room
+	err := m.inReadLock(func() error {
+		if r := m.rooms[roomJID]; r != nil {
+			room := *r
+			ret = &room
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// FetchRooms retrieves from storage all persisted room entities.
+func (m *Storage) FetchRooms() ([]mucmodel.Room, error) {
+	var ret []mucmodel.Room
+	err := m.inReadLock(func() error {
+		for _, r := range m.rooms {
+			ret = append(ret, *r)
+		}
+		return nil
+	})
+	return ret, err
+}
@@ -0,0 +1,69 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStorageInsertOrUpdateRoom(t *testing.T) {
+	room := mucmodel.Room{
+		RoomJID: "coven@conference.jackal.im",
+		Name:    "The Coven",
+		Affiliations: []mucmodel.Affiliation{
+			{JID: "ortuman@jackal.im", Affiliation: mucmodel.AffiliationOwner},
+		},
+	}
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdateRoom(&room))
+	s.DeactivateMockedError()
+	require.Nil(t, s.InsertOrUpdateRoom(&room))
+
+	room.Name = "The Coven 2"
+	require.Nil(t, s.InsertOrUpdateRoom(&room))
+
+	s.ActivateMockedError()
+	_, err := s.FetchRoom("coven@conference.jackal.im")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	r, err := s.FetchRoom("coven@conference.jackal.im")
+	require.Nil(t, err)
+	require.NotNil(t, r)
+	require.Equal(t, room, *r)
+
+	r, err = s.FetchRoom("darkcave@conference.jackal.im")
+	require.Nil(t, err)
+	require.Nil(t, r)
+
+	s.ActivateMockedError()
+	_, err = s.FetchRooms()
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	rooms, err := s.FetchRooms()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(rooms))
+}
+
+func TestMockStorageDeleteRoom(t *testing.T) {
+	room := mucmodel.Room{RoomJID: "coven@conference.jackal.im"}
+	s := New()
+	s.InsertOrUpdateRoom(&room)
+
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.DeleteRoom("coven@conference.jackal.im"))
+	s.DeactivateMockedError()
+
+	require.Nil(t, s.DeleteRoom("coven@conference.jackal.im"))
+
+	r, _ := s.FetchRoom("coven@conference.jackal.im")
+	require.Nil(t, r)
+}
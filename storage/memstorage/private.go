@@ -5,7 +5,12 @@
 
 package memstorage
 
-import "github.com/ortuman/jackal/xmpp"
+import (
+	"sort"
+	"strings"
+
+	"github.com/ortuman/jackal/xmpp"
+)
 
 // InsertOrUpdatePrivateXML inserts a new private element into storage,
 // or updates it in case it's been previously inserted.
@@ -29,3 +34,34 @@ func (m *Storage) FetchPrivateXML(namespace string, username string) ([]xmpp.XEl
 	})
 	return ret, err
 }
+
+// FetchPrivateXMLNamespaces retrieves from storage all private element
+// namespaces associated to a given user.
+func (m *Storage) FetchPrivateXMLNamespaces(username string) ([]string, error) {
+	var ret []string
+	err := m.inReadLock(func() error {
+		prefix := username + ":"
+		for k := range m.privateXML {
+			if strings.HasPrefix(k, prefix) {
+				ret = append(ret, strings.TrimPrefix(k, prefix))
+			}
+		}
+		return nil
+	})
+	sort.Strings(ret)
+	return ret, err
+}
+
+// DeletePrivateXML deletes from storage all private elements
+// associated to a given user.
+func (m *Storage) DeletePrivateXML(username string) error {
+	return m.inWriteLock(func() error {
+		prefix := username + ":"
+		for k := range m.privateXML {
+			if strings.HasPrefix(k, prefix) {
+				delete(m.privateXML, k)
+			}
+		}
+		return nil
+	})
+}
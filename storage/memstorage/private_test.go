@@ -37,3 +37,34 @@ func TestMockStorageFetchPrivateXML(t *testing.T) {
 	elems, _ := s.FetchPrivateXML("exodus:ns", "ortuman")
 	require.Equal(t, 1, len(elems))
 }
+
+func TestMockStorageFetchPrivateXMLNamespaces(t *testing.T) {
+	s := New()
+	s.InsertOrUpdatePrivateXML([]xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "ortuman")
+	s.InsertOrUpdatePrivateXML([]xmpp.XElement{xmpp.NewElementNamespace("bookmarks", "storage:bookmarks")}, "storage:bookmarks", "ortuman")
+	s.InsertOrUpdatePrivateXML([]xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "romeo")
+
+	s.ActivateMockedError()
+	_, err := s.FetchPrivateXMLNamespaces("ortuman")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+	namespaces, err := s.FetchPrivateXMLNamespaces("ortuman")
+	require.Nil(t, err)
+	require.Equal(t, []string{"exodus:ns", "storage:bookmarks"}, namespaces)
+}
+
+func TestMockStorageDeletePrivateXML(t *testing.T) {
+	s := New()
+	s.InsertOrUpdatePrivateXML([]xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "ortuman")
+	s.InsertOrUpdatePrivateXML([]xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "romeo")
+
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.DeletePrivateXML("ortuman"))
+	s.DeactivateMockedError()
+	require.Nil(t, s.DeletePrivateXML("ortuman"))
+
+	elems, _ := s.FetchPrivateXML("exodus:ns", "ortuman")
+	require.Nil(t, elems)
+	elems, _ = s.FetchPrivateXML("exodus:ns", "romeo")
+	require.Equal(t, 1, len(elems))
+}
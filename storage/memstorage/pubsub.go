@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import "github.com/ortuman/jackal/model/pubsubmodel"
+
+// InsertOrUpdatePubSubNode inserts a new pubsub node entity into storage,
+// or updates it in case it's been previously inserted.
+func (m *Storage) InsertOrUpdatePubSubNode(node *pubsubmodel.Node) error {
+	return m.inWriteLock(func() error {
+		nodes := m.pubSubNodes[node.Host]
+		if nodes == nil {
+			nodes = make(map[string]*pubsubmodel.Node)
+			m.pubSubNodes[node.Host] = nodes
+		}
+		nodes[node.Name] = copyPubSubNode(node)
+		return nil
+	})
+}
+
+// FetchPubSubNode retrieves from storage a pubsub node entity.
+func (m *Storage) FetchPubSubNode(host, name string) (*pubsubmodel.Node, error) {
+	var ret *pubsubmodel.Node
+	err := m.inReadLock(func() error {
+		if n := m.pubSubNodes[host][name]; n != nil {
+			ret = copyPubSubNode(n)
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// FetchPubSubNodes retrieves from storage all pubsub node entities
+// associated to a given host.
+func (m *Storage) FetchPubSubNodes(host string) ([]pubsubmodel.Node, error) {
+	var ret []pubsubmodel.Node
+	err := m.inReadLock(func() error {
+		for _, n := range m.pubSubNodes[host] {
+			ret = append(ret, *copyPubSubNode(n))
+		}
+		return nil
+	})
+	return ret, err
+}
+
+// DeletePubSubNode deletes a pubsub node entity from storage,
+// along with all its published items.
+func (m *Storage) DeletePubSubNode(host, name string) error {
+	return m.inWriteLock(func() error {
+		delete(m.pubSubNodes[host], name)
+		delete(m.pubSubItems[host], name)
+		return nil
+	})
+}
+
+// InsertOrUpdatePubSubNodeItem inserts a new pubsub item entity into storage,
+// or updates it in case it's been previously inserted.
+func (m *Storage) InsertOrUpdatePubSubNodeItem(item *pubsubmodel.Item, host, name string) error {
+	return m.inWriteLock(func() error {
+		nodeItems := m.pubSubItems[host]
+		if nodeItems == nil {
+			nodeItems = make(map[string][]pubsubmodel.Item)
+			m.pubSubItems[host] = nodeItems
+		}
+		items := nodeItems[name]
+		for i := 0; i < len(items); i++ {
+			if items[i].ID == item.ID {
+				items = append(items[:i], items[i+1:]...)
+				break
+			}
+		}
+		nodeItems[name] = append(items, *item)
+		return nil
+	})
+}
+
+// DeletePubSubNodeItem deletes a pubsub item entity from storage.
+func (m *Storage) DeletePubSubNodeItem(host, name, itemID string) error {
+	return m.inWriteLock(func() error {
+		items := m.pubSubItems[host][name]
+		for i := 0; i < len(items); i++ {
+			if items[i].ID == itemID {
+				m.pubSubItems[host][name] = append(items[:i], items[i+1:]...)
+				break
+			}
+		}
+		return nil
+	})
+}
+
+// FetchPubSubNodeItems retrieves from storage all items published
+// to a given node, in chronological order.
+func (m *Storage) FetchPubSubNodeItems(host, name string) ([]pubsubmodel.Item, error) {
+	var ret []pubsubmodel.Item
+	err := m.inReadLock(func() error {
+		ret = append(ret, m.pubSubItems[host][name]...)
+		return nil
+	})
+	return ret, err
+}
+
+func copyPubSubNode(node *pubsubmodel.Node) *pubsubmodel.Node {
+	n := *node
+	n.Affiliations = append([]pubsubmodel.Affiliation(nil), node.Affiliations...)
+	n.Subscriptions = append([]pubsubmodel.Subscription(nil), node.Subscriptions...)
+	return &n
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package memstorage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMockStorageInsertOrUpdatePubSubNode(t *testing.T) {
+	node := pubsubmodel.Node{
+		Host:    "pubsub.jackal.im",
+		Name:    "princely_musings",
+		Options: pubsubmodel.Options{AccessModel: pubsubmodel.AccessModelOpen, MaxItems: 10},
+		Affiliations: []pubsubmodel.Affiliation{
+			{JID: "ortuman@jackal.im", Affiliation: pubsubmodel.AffiliationOwner},
+		},
+	}
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdatePubSubNode(&node))
+	s.DeactivateMockedError()
+	require.Nil(t, s.InsertOrUpdatePubSubNode(&node))
+
+	node.Options.Title = "Princely Musings (Atom)"
+	require.Nil(t, s.InsertOrUpdatePubSubNode(&node))
+
+	s.ActivateMockedError()
+	_, err := s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	n, err := s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.NotNil(t, n)
+	require.Equal(t, node, *n)
+
+	n, err = s.FetchPubSubNode("pubsub.jackal.im", "news")
+	require.Nil(t, err)
+	require.Nil(t, n)
+
+	s.ActivateMockedError()
+	_, err = s.FetchPubSubNodes("pubsub.jackal.im")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	nodes, err := s.FetchPubSubNodes("pubsub.jackal.im")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(nodes))
+}
+
+func TestMockStorageDeletePubSubNode(t *testing.T) {
+	node := pubsubmodel.Node{Host: "pubsub.jackal.im", Name: "princely_musings"}
+	s := New()
+	s.InsertOrUpdatePubSubNode(&node)
+	s.InsertOrUpdatePubSubNodeItem(&pubsubmodel.Item{ID: "1"}, "pubsub.jackal.im", "princely_musings")
+
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.DeletePubSubNode("pubsub.jackal.im", "princely_musings"))
+	s.DeactivateMockedError()
+
+	require.Nil(t, s.DeletePubSubNode("pubsub.jackal.im", "princely_musings"))
+
+	n, _ := s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, n)
+	items, _ := s.FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 0, len(items))
+}
+
+func TestMockStoragePubSubNodeItems(t *testing.T) {
+	payload := xmpp.NewElementNamespace("entry", "http://www.w3.org/2005/Atom")
+	i1 := pubsubmodel.Item{ID: "1", Publisher: "ortuman@jackal.im", Payload: payload, Stamp: time.Now()}
+	i2 := pubsubmodel.Item{ID: "2", Publisher: "ortuman@jackal.im", Payload: payload, Stamp: time.Now()}
+
+	s := New()
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.InsertOrUpdatePubSubNodeItem(&i1, "pubsub.jackal.im", "princely_musings"))
+	s.DeactivateMockedError()
+
+	require.Nil(t, s.InsertOrUpdatePubSubNodeItem(&i1, "pubsub.jackal.im", "princely_musings"))
+	require.Nil(t, s.InsertOrUpdatePubSubNodeItem(&i2, "pubsub.jackal.im", "princely_musings"))
+	require.Nil(t, s.InsertOrUpdatePubSubNodeItem(&i1, "pubsub.jackal.im", "princely_musings"))
+
+	s.ActivateMockedError()
+	_, err := s.FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+
+	items, err := s.FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(items))
+	require.Equal(t, "2", items[0].ID)
+	require.Equal(t, "1", items[1].ID)
+
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.DeletePubSubNodeItem("pubsub.jackal.im", "princely_musings", "2"))
+	s.DeactivateMockedError()
+
+	require.Nil(t, s.DeletePubSubNodeItem("pubsub.jackal.im", "princely_musings", "2"))
+	items, _ = s.FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "1", items[0].ID)
+}
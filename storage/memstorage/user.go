@@ -5,7 +5,11 @@
 
 package memstorage
 
-import "github.com/ortuman/jackal/model"
+import (
+	"sort"
+
+	"github.com/ortuman/jackal/model"
+)
 
 // InsertOrUpdateUser inserts a new user entity into storage,
 // or updates it in case it's been previously inserted.
@@ -43,3 +47,16 @@ func (m *Storage) UserExists(username string) (bool, error) {
 	})
 	return ret, err
 }
+
+// FetchUsernames retrieves from storage the username of every user entity.
+func (m *Storage) FetchUsernames() ([]string, error) {
+	var ret []string
+	err := m.inReadLock(func() error {
+		for username := range m.users {
+			ret = append(ret, username)
+		}
+		return nil
+	})
+	sort.Strings(ret)
+	return ret, err
+}
@@ -62,3 +62,17 @@ func TestMockStorageDeleteUser(t *testing.T) {
 	usr, _ := s.FetchUser("ortuman")
 	require.Nil(t, usr)
 }
+
+func TestMockStorageFetchUsernames(t *testing.T) {
+	s := New()
+	_ = s.InsertOrUpdateUser(&model.User{Username: "romeo"})
+	_ = s.InsertOrUpdateUser(&model.User{Username: "juliet"})
+
+	s.ActivateMockedError()
+	_, err := s.FetchUsernames()
+	require.Equal(t, ErrMockedError, err)
+	s.DeactivateMockedError()
+	usernames, err := s.FetchUsernames()
+	require.Nil(t, err)
+	require.Equal(t, []string{"juliet", "romeo"}, usernames)
+}
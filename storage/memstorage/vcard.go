@@ -26,3 +26,12 @@ func (m *Storage) FetchVCard(username string) (xmpp.XElement, error) {
 	})
 	return ret, err
 }
+
+// DeleteVCard deletes from storage a vCard element associated
+// to a given user.
+func (m *Storage) DeleteVCard(username string) error {
+	return m.inWriteLock(func() error {
+		delete(m.vCards, username)
+		return nil
+	})
+}
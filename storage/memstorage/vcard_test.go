@@ -41,3 +41,18 @@ func TestMockStorageFetchVCard(t *testing.T) {
 	elem, _ := s.FetchVCard("ortuman")
 	require.NotNil(t, elem)
 }
+
+func TestMockStorageDeleteVCard(t *testing.T) {
+	vCard := xmpp.NewElementName("vCard")
+
+	s := New()
+	s.InsertOrUpdateVCard(vCard, "ortuman")
+
+	s.ActivateMockedError()
+	require.Equal(t, ErrMockedError, s.DeleteVCard("ortuman"))
+	s.DeactivateMockedError()
+	require.Nil(t, s.DeleteVCard("ortuman"))
+
+	elem, _ := s.FetchVCard("ortuman")
+	require.Nil(t, elem)
+}
@@ -0,0 +1,281 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package storage
+
+import (
+	"time"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/capsmodel"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var operationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+	Namespace: "jackal",
+	Subsystem: "storage",
+	Name:      "operation_duration_seconds",
+	Help:      "Storage operation latency in seconds, by backend and operation.",
+	Buckets:   prometheus.ExponentialBuckets(0.0001, 4, 10),
+}, []string{"backend", "operation"})
+
+func init() {
+	prometheus.MustRegister(operationDuration)
+}
+
+// measuredStorage decorates a storage backend recording
+// the latency of every operation.
+//
+// The backend is deliberately not embedded, so that any method added
+// to the Storage interface must be explicitly wrapped here.
+type measuredStorage struct {
+	storage  Storage
+	duration prometheus.ObserverVec
+}
+
+func newMeasuredStorage(s Storage, backend string) *measuredStorage {
+	return &measuredStorage{
+		storage:  s,
+		duration: operationDuration.MustCurryWith(prometheus.Labels{"backend": backend}),
+	}
+}
+
+func (s *measuredStorage) observe(operation string, start time.Time) {
+	s.duration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
+
+func (s *measuredStorage) Shutdown() {
+	s.storage.Shutdown()
+}
+
+func (s *measuredStorage) InsertOrUpdateUser(user *model.User) error {
+	defer s.observe("InsertOrUpdateUser", time.Now())
+	return s.storage.InsertOrUpdateUser(user)
+}
+
+func (s *measuredStorage) DeleteUser(username string) error {
+	defer s.observe("DeleteUser", time.Now())
+	return s.storage.DeleteUser(username)
+}
+
+func (s *measuredStorage) FetchUser(username string) (*model.User, error) {
+	defer s.observe("FetchUser", time.Now())
+	return s.storage.FetchUser(username)
+}
+
+func (s *measuredStorage) UserExists(username string) (bool, error) {
+	defer s.observe("UserExists", time.Now())
+	return s.storage.UserExists(username)
+}
+
+func (s *measuredStorage) FetchUsernames() ([]string, error) {
+	defer s.observe("FetchUsernames", time.Now())
+	return s.storage.FetchUsernames()
+}
+
+func (s *measuredStorage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Version, error) {
+	defer s.observe("InsertOrUpdateRosterItem", time.Now())
+	return s.storage.InsertOrUpdateRosterItem(ri)
+}
+
+func (s *measuredStorage) DeleteRosterItem(username, jid string) (rostermodel.Version, error) {
+	defer s.observe("DeleteRosterItem", time.Now())
+	return s.storage.DeleteRosterItem(username, jid)
+}
+
+func (s *measuredStorage) FetchRosterItems(username string) ([]rostermodel.Item, rostermodel.Version, error) {
+	defer s.observe("FetchRosterItems", time.Now())
+	return s.storage.FetchRosterItems(username)
+}
+
+func (s *measuredStorage) FetchRosterItem(username, jid string) (*rostermodel.Item, error) {
+	defer s.observe("FetchRosterItem", time.Now())
+	return s.storage.FetchRosterItem(username, jid)
+}
+
+func (s *measuredStorage) InsertOrUpdateRosterNotification(rn *rostermodel.Notification) error {
+	defer s.observe("InsertOrUpdateRosterNotification", time.Now())
+	return s.storage.InsertOrUpdateRosterNotification(rn)
+}
+
+func (s *measuredStorage) DeleteRosterNotification(contact, jid string) error {
+	defer s.observe("DeleteRosterNotification", time.Now())
+	return s.storage.DeleteRosterNotification(contact, jid)
+}
+
+func (s *measuredStorage) FetchRosterNotification(contact string, jid string) (*rostermodel.Notification, error) {
+	defer s.observe("FetchRosterNotification", time.Now())
+	return s.storage.FetchRosterNotification(contact, jid)
+}
+
+func (s *measuredStorage) FetchRosterNotifications(contact string) ([]rostermodel.Notification, error) {
+	defer s.observe("FetchRosterNotifications", time.Now())
+	return s.storage.FetchRosterNotifications(contact)
+}
+
+func (s *measuredStorage) InsertOfflineMessage(message *xmpp.Message, username string) error {
+	defer s.observe("InsertOfflineMessage", time.Now())
+	return s.storage.InsertOfflineMessage(message, username)
+}
+
+func (s *measuredStorage) CountOfflineMessages(username string) (int, error) {
+	defer s.observe("CountOfflineMessages", time.Now())
+	return s.storage.CountOfflineMessages(username)
+}
+
+func (s *measuredStorage) FetchOfflineMessages(username string) ([]*xmpp.Message, error) {
+	defer s.observe("FetchOfflineMessages", time.Now())
+	return s.storage.FetchOfflineMessages(username)
+}
+
+func (s *measuredStorage) DeleteOfflineMessages(username string) error {
+	defer s.observe("DeleteOfflineMessages", time.Now())
+	return s.storage.DeleteOfflineMessages(username)
+}
+
+func (s *measuredStorage) InsertOrUpdateVCard(vCard xmpp.XElement, username string) error {
+	defer s.observe("InsertOrUpdateVCard", time.Now())
+	return s.storage.InsertOrUpdateVCard(vCard, username)
+}
+
+func (s *measuredStorage) FetchVCard(username string) (xmpp.XElement, error) {
+	defer s.observe("FetchVCard", time.Now())
+	return s.storage.FetchVCard(username)
+}
+
+func (s *measuredStorage) DeleteVCard(username string) error {
+	defer s.observe("DeleteVCard", time.Now())
+	return s.storage.DeleteVCard(username)
+}
+
+func (s *measuredStorage) FetchPrivateXML(namespace string, username string) ([]xmpp.XElement, error) {
+	defer s.observe("FetchPrivateXML", time.Now())
+	return s.storage.FetchPrivateXML(namespace, username)
+}
+
+func (s *measuredStorage) InsertOrUpdatePrivateXML(privateXML []xmpp.XElement, namespace string, username string) error {
+	defer s.observe("InsertOrUpdatePrivateXML", time.Now())
+	return s.storage.InsertOrUpdatePrivateXML(privateXML, namespace, username)
+}
+
+func (s *measuredStorage) FetchPrivateXMLNamespaces(username string) ([]string, error) {
+	defer s.observe("FetchPrivateXMLNamespaces", time.Now())
+	return s.storage.FetchPrivateXMLNamespaces(username)
+}
+
+func (s *measuredStorage) DeletePrivateXML(username string) error {
+	defer s.observe("DeletePrivateXML", time.Now())
+	return s.storage.DeletePrivateXML(username)
+}
+
+func (s *measuredStorage) InsertBlockListItems(items []model.BlockListItem) error {
+	defer s.observe("InsertBlockListItems", time.Now())
+	return s.storage.InsertBlockListItems(items)
+}
+
+func (s *measuredStorage) DeleteBlockListItems(items []model.BlockListItem) error {
+	defer s.observe("DeleteBlockListItems", time.Now())
+	return s.storage.DeleteBlockListItems(items)
+}
+
+func (s *measuredStorage) FetchBlockListItems(username string) ([]model.BlockListItem, error) {
+	defer s.observe("FetchBlockListItems", time.Now())
+	return s.storage.FetchBlockListItems(username)
+}
+
+func (s *measuredStorage) InsertOrUpdateRoom(room *mucmodel.Room) error {
+	defer s.observe("InsertOrUpdateRoom", time.Now())
+	return s.storage.InsertOrUpdateRoom(room)
+}
+
+func (s *measuredStorage) DeleteRoom(roomJID string) error {
+	defer s.observe("DeleteRoom", time.Now())
+	return s.storage.DeleteRoom(roomJID)
+}
+
+func (s *measuredStorage) FetchRoom(roomJID string) (*mucmodel.Room, error) {
+	defer s.observe("FetchRoom", time.Now())
+	return s.storage.FetchRoom(roomJID)
+}
+
+func (s *measuredStorage) FetchRooms() ([]mucmodel.Room, error) {
+	defer s.observe("FetchRooms", time.Now())
+	return s.storage.FetchRooms()
+}
+
+func (s *measuredStorage) InsertArchiveMessage(message *mammodel.Message) error {
+	defer s.observe("InsertArchiveMessage", time.Now())
+	return s.storage.InsertArchiveMessage(message)
+}
+
+func (s *measuredStorage) FetchArchiveMessages(username string, filter *mammodel.Filter) ([]mammodel.Message, error) {
+	defer s.observe("FetchArchiveMessages", time.Now())
+	return s.storage.FetchArchiveMessages(username, filter)
+}
+
+func (s *measuredStorage) InsertOrUpdateArchivePrefs(prefs *mammodel.Prefs) error {
+	defer s.observe("InsertOrUpdateArchivePrefs", time.Now())
+	return s.storage.InsertOrUpdateArchivePrefs(prefs)
+}
+
+func (s *measuredStorage) FetchArchivePrefs(username string) (*mammodel.Prefs, error) {
+	defer s.observe("FetchArchivePrefs", time.Now())
+	return s.storage.FetchArchivePrefs(username)
+}
+
+func (s *measuredStorage) DeleteArchive(username string) error {
+	defer s.observe("DeleteArchive", time.Now())
+	return s.storage.DeleteArchive(username)
+}
+
+func (s *measuredStorage) InsertOrUpdatePubSubNode(node *pubsubmodel.Node) error {
+	defer s.observe("InsertOrUpdatePubSubNode", time.Now())
+	return s.storage.InsertOrUpdatePubSubNode(node)
+}
+
+func (s *measuredStorage) FetchPubSubNode(host, name string) (*pubsubmodel.Node, error) {
+	defer s.observe("FetchPubSubNode", time.Now())
+	return s.storage.FetchPubSubNode(host, name)
+}
+
+func (s *measuredStorage) FetchPubSubNodes(host string) ([]pubsubmodel.Node, error) {
+	defer s.observe("FetchPubSubNodes", time.Now())
+	return s.storage.FetchPubSubNodes(host)
+}
+
+func (s *measuredStorage) DeletePubSubNode(host, name string) error {
+	defer s.observe("DeletePubSubNode", time.Now())
+	return s.storage.DeletePubSubNode(host, name)
+}
+
+func (s *measuredStorage) InsertOrUpdatePubSubNodeItem(item *pubsubmodel.Item, host, name string) error {
+	defer s.observe("InsertOrUpdatePubSubNodeItem", time.Now())
+	return s.storage.InsertOrUpdatePubSubNodeItem(item, host, name)
+}
+
+func (s *measuredStorage) DeletePubSubNodeItem(host, name, itemID string) error {
+	defer s.observe("DeletePubSubNodeItem", time.Now())
+	return s.storage.DeletePubSubNodeItem(host, name, itemID)
+}
+
+func (s *measuredStorage) FetchPubSubNodeItems(host, name string) ([]pubsubmodel.Item, error) {
+	defer s.observe("FetchPubSubNodeItems", time.Now())
+	return s.storage.FetchPubSubNodeItems(host, name)
+}
+
+func (s *measuredStorage) InsertOrUpdateCapabilities(caps *capsmodel.Capabilities) error {
+	defer s.observe("InsertOrUpdateCapabilities", time.Now())
+	return s.storage.InsertOrUpdateCapabilities(caps)
+}
+
+func (s *measuredStorage) FetchCapabilities(ver string) (*capsmodel.Capabilities, error) {
+	defer s.observe("FetchCapabilities", time.Now())
+	return s.storage.FetchCapabilities(ver)
+}
@@ -0,0 +1,40 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package storage
+
+import (
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_Metrics(t *testing.T) {
+	Initialize(&Config{Type: Memory})
+	defer Shutdown()
+
+	_, ok := backend().(*memstorage.Storage)
+	require.True(t, ok)
+
+	count := tUtilOperationCount(t, "memory", "FetchUser")
+
+	require.Nil(t, Instance().InsertOrUpdateUser(&model.User{Username: "ortuman", Password: "1234"}))
+	usr, err := Instance().FetchUser("ortuman")
+	require.Nil(t, err)
+	require.Equal(t, "ortuman", usr.Username)
+
+	require.Equal(t, count+1, tUtilOperationCount(t, "memory", "FetchUser"))
+}
+
+func tUtilOperationCount(t *testing.T, backend, operation string) uint64 {
+	m := &dto.Metric{}
+	h := operationDuration.WithLabelValues(backend, operation).(prometheus.Metric)
+	require.Nil(t, h.Write(m))
+	return m.GetHistogram().GetSampleCount()
+}
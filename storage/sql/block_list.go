@@ -17,8 +17,7 @@ import (
 func (s *Storage) InsertBlockListItems(items []model.BlockListItem) error {
 	return s.inTransaction(func(tx *sql.Tx) error {
 		for _, item := range items {
-			_, err := sq.Insert("blocklist_items").
-				Options("IGNORE").
+			_, err := s.insertIgnore("blocklist_items").
 				Columns("username", "jid", "created_at").
 				Values(item.Username, item.JID, nowExpr).
 				RunWith(tx).Exec()
@@ -34,7 +33,7 @@ func (s *Storage) InsertBlockListItems(items []model.BlockListItem) error {
 func (s *Storage) DeleteBlockListItems(items []model.BlockListItem) error {
 	return s.inTransaction(func(tx *sql.Tx) error {
 		for _, item := range items {
-			_, err := sq.Delete("blocklist_items").
+			_, err := s.sq.Delete("blocklist_items").
 				Where(sq.And{sq.Eq{"username": item.Username}, sq.Eq{"jid": item.JID}}).
 				RunWith(tx).Exec()
 			if err != nil {
@@ -48,7 +47,7 @@ func (s *Storage) DeleteBlockListItems(items []model.BlockListItem) error {
 // FetchBlockListItems retrieves from storage all block list item entities
 // associated to a given user.
 func (s *Storage) FetchBlockListItems(username string) ([]model.BlockListItem, error) {
-	q := sq.Select("username", "jid").
+	q := s.sq.Select("username", "jid").
 		From("blocklist_items").
 		Where(sq.Eq{"username": username}).
 		OrderBy("created_at")
@@ -0,0 +1,49 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model/capsmodel"
+)
+
+// InsertOrUpdateCapabilities inserts a new capabilities entity into storage,
+// or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdateCapabilities(caps *capsmodel.Capabilities) error {
+	features := strings.Join(caps.Features, ";")
+	q := s.sq.Insert("capabilities").
+		Columns("ver", "node", "features", "updated_at", "created_at").
+		Values(caps.Ver, caps.Node, features, nowExpr, nowExpr).
+		Suffix(s.onConflictUpdate("ver")+" node = ?, features = ?, updated_at = CURRENT_TIMESTAMP", caps.Node, features)
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
+
+// FetchCapabilities retrieves from storage a capabilities entity
+// associated to a given verification string.
+func (s *Storage) FetchCapabilities(ver string) (*capsmodel.Capabilities, error) {
+	q := s.sq.Select("ver", "node", "features").
+		From("capabilities").
+		Where(sq.Eq{"ver": ver})
+
+	var caps capsmodel.Capabilities
+	var features string
+	err := q.RunWith(s.db).QueryRow().Scan(&caps.Ver, &caps.Node, &features)
+	switch err {
+	case nil:
+		if len(features) > 0 {
+			caps.Features = strings.Split(features, ";")
+		}
+		return &caps, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model/capsmodel"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorageInsertCapabilities(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		caps := capsmodel.Capabilities{
+			Node:     "http://code.google.com/p/exodus",
+			Ver:      "QgayPKawpkPSDYmwT/WM94uAlu0=",
+			Features: []string{"http://jabber.org/protocol/caps", "http://jabber.org/protocol/muc"},
+		}
+		features := "http://jabber.org/protocol/caps;http://jabber.org/protocol/muc"
+
+		s, mock := d.newMock()
+		mock.ExpectExec("INSERT INTO capabilities (.+) "+d.upsert+" (.+)").
+			WithArgs(caps.Ver, caps.Node, features, caps.Node, features).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := s.InsertOrUpdateCapabilities(&caps)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectExec("INSERT INTO capabilities (.+) " + d.upsert + " (.+)").
+			WillReturnError(errMySQLStorage)
+
+		err = s.InsertOrUpdateCapabilities(&caps)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageFetchCapabilities(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		var capsColumns = []string{"ver", "node", "features"}
+
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM capabilities (.+)").
+			WithArgs("QgayPKawpkPSDYmwT/WM94uAlu0=").
+			WillReturnRows(sqlmock.NewRows(capsColumns))
+
+		caps, err := s.FetchCapabilities("QgayPKawpkPSDYmwT/WM94uAlu0=")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Nil(t, caps)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM capabilities (.+)").
+			WithArgs("QgayPKawpkPSDYmwT/WM94uAlu0=").
+			WillReturnRows(sqlmock.NewRows(capsColumns).
+				AddRow("QgayPKawpkPSDYmwT/WM94uAlu0=", "http://code.google.com/p/exodus", "http://jabber.org/protocol/caps;http://jabber.org/protocol/muc"))
+
+		caps, err = s.FetchCapabilities("QgayPKawpkPSDYmwT/WM94uAlu0=")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.NotNil(t, caps)
+		require.Equal(t, "http://code.google.com/p/exodus", caps.Node)
+		require.Equal(t, []string{"http://jabber.org/protocol/caps", "http://jabber.org/protocol/muc"}, caps.Features)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM capabilities (.+)").
+			WithArgs("QgayPKawpkPSDYmwT/WM94uAlu0=").
+			WillReturnError(errMySQLStorage)
+
+		_, err = s.FetchCapabilities("QgayPKawpkPSDYmwT/WM94uAlu0=")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
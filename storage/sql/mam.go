@@ -0,0 +1,177 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+// InsertArchiveMessage inserts a new message entity into
+// user's archive.
+func (s *Storage) InsertArchiveMessage(message *mammodel.Message) error {
+	q := s.sq.Insert("archive_messages").
+		Columns("id", "username", "with_jid", "data", "stamp", "created_at").
+		Values(message.ID, message.Username, message.With, message.Message.String(), message.Stamp, nowExpr)
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
+
+// FetchArchiveMessages retrieves from storage all user's archived
+// messages satisfying filter criteria, in chronological order.
+func (s *Storage) FetchArchiveMessages(username string, filter *mammodel.Filter) ([]mammodel.Message, error) {
+	backwards := filter.IsBackwards()
+
+	q := s.sq.Select("id", "username", "with_jid", "data", "stamp").
+		From("archive_messages").
+		Where(sq.Eq{"username": username})
+
+	if len(filter.With) > 0 {
+		q = q.Where(sq.Eq{"with_jid": filter.With})
+	}
+	if !filter.Start.IsZero() {
+		q = q.Where(sq.GtOrEq{"stamp": filter.Start})
+	}
+	if !filter.End.IsZero() {
+		q = q.Where(sq.LtOrEq{"stamp": filter.End})
+	}
+	if len(filter.After) > 0 {
+		stamp, err := s.archiveMessageStamp(username, filter.After)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where(sq.Or{sq.Gt{"stamp": stamp}, sq.And{sq.Eq{"stamp": stamp}, sq.Gt{"id": filter.After}}})
+	}
+	if len(filter.Before) > 0 {
+		stamp, err := s.archiveMessageStamp(username, filter.Before)
+		if err != nil {
+			return nil, err
+		}
+		q = q.Where(sq.Or{sq.Lt{"stamp": stamp}, sq.And{sq.Eq{"stamp": stamp}, sq.Lt{"id": filter.Before}}})
+	}
+	if backwards {
+		q = q.OrderBy("stamp DESC", "id DESC")
+	} else {
+		q = q.OrderBy("stamp", "id")
+	}
+	if filter.Limit > 0 {
+		q = q.Limit(uint64(filter.Limit))
+	}
+	if filter.Offset > 0 {
+		q = q.Offset(uint64(filter.Offset))
+	}
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var msgs []mammodel.Message
+	for rows.Next() {
+		var m mammodel.Message
+		var data string
+		if err := rows.Scan(&m.ID, &m.Username, &m.With, &data, &m.Stamp); err != nil {
+			return nil, err
+		}
+		parser := xmpp.NewParser(strings.NewReader(data), xmpp.DefaultMode, 0)
+		el, err := parser.ParseElement()
+		if err != nil {
+			return nil, err
+		}
+		fromJID, _ := jid.NewWithString(el.From(), true)
+		toJID, _ := jid.NewWithString(el.To(), true)
+		m.Message, err = xmpp.NewMessageFromElement(el, fromJID, toJID)
+		if err != nil {
+			return nil, err
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if backwards {
+		for i, j := 0, len(msgs)-1; i < j; i, j = i+1, j-1 {
+			msgs[i], msgs[j] = msgs[j], msgs[i]
+		}
+	}
+	return msgs, nil
+}
+
+func (s *Storage) archiveMessageStamp(username, id string) (time.Time, error) {
+	var stamp time.Time
+	err := s.sq.Select("stamp").
+		From("archive_messages").
+		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"id": id}}).
+		RunWith(s.db).QueryRow().Scan(&stamp)
+	switch err {
+	case nil:
+		return stamp, nil
+	case sql.ErrNoRows:
+		return time.Time{}, mammodel.ErrMessageNotFound
+	default:
+		return time.Time{}, err
+	}
+}
+
+// InsertOrUpdateArchivePrefs inserts a new archiving preferences entity
+// into storage, or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdateArchivePrefs(prefs *mammodel.Prefs) error {
+	always := strings.Join(prefs.Always, ";")
+	never := strings.Join(prefs.Never, ";")
+	q := s.sq.Insert("archive_prefs").
+		Columns("username", "default_mode", "always", "never", "updated_at", "created_at").
+		Values(prefs.Username, prefs.Default, always, never, nowExpr, nowExpr).
+		Suffix(s.onConflictUpdate("username")+" default_mode = ?, always = ?, never = ?, updated_at = CURRENT_TIMESTAMP", prefs.Default, always, never)
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
+
+// FetchArchivePrefs retrieves from storage user's archiving preferences.
+func (s *Storage) FetchArchivePrefs(username string) (*mammodel.Prefs, error) {
+	q := s.sq.Select("username", "default_mode", "always", "never").
+		From("archive_prefs").
+		Where(sq.Eq{"username": username})
+
+	var prefs mammodel.Prefs
+	var always, never string
+	err := q.RunWith(s.db).QueryRow().Scan(&prefs.Username, &prefs.Default, &always, &never)
+	switch err {
+	case nil:
+		prefs.Always = splitJIDList(always)
+		prefs.Never = splitJIDList(never)
+		return &prefs, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// DeleteArchive deletes from storage all user's archived messages
+// along with its archiving preferences.
+func (s *Storage) DeleteArchive(username string) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		_, err := s.sq.Delete("archive_messages").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = s.sq.Delete("archive_prefs").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		return err
+	})
+}
+
+func splitJIDList(s string) []string {
+	if len(s) == 0 {
+		return nil
+	}
+	return strings.Split(s, ";")
+}
@@ -0,0 +1,222 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+var archiveMessageTestColumns = []string{"id", "username", "with_jid", "data", "stamp"}
+
+func TestStorageInsertArchiveMessage(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		j1, _ := jid.NewWithString("ortuman@jackal.im/balcony", true)
+		j2, _ := jid.NewWithString("noelia@jackal.im/garden", true)
+
+		msg := xmpp.NewMessageType("abc1234", xmpp.ChatType)
+		msg.SetFromJID(j1)
+		msg.SetToJID(j2)
+
+		m := mammodel.Message{
+			ID:       "a1",
+			Username: "ortuman",
+			With:     "noelia@jackal.im",
+			Message:  msg,
+			Stamp:    time.Now(),
+		}
+		s, mock := d.newMock()
+		mock.ExpectExec("INSERT INTO archive_messages (.+)").
+			WithArgs("a1", "ortuman", "noelia@jackal.im", msg.String(), m.Stamp).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := s.InsertArchiveMessage(&m)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectExec("INSERT INTO archive_messages (.+)").
+			WillReturnError(errMySQLStorage)
+
+		err = s.InsertArchiveMessage(&m)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageFetchArchiveMessages(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		data := `<message id="abc1234" type="chat" from="ortuman@jackal.im/balcony" to="noelia@jackal.im/garden"/>`
+		now := time.Now()
+
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM archive_messages (.+)").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(archiveMessageTestColumns).
+				AddRow("a1", "ortuman", "noelia@jackal.im", data, now).
+				AddRow("a2", "ortuman", "noelia@jackal.im", data, now))
+
+		msgs, err := s.FetchArchiveMessages("ortuman", &mammodel.Filter{})
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, 2, len(msgs))
+		require.Equal(t, "a1", msgs[0].ID)
+		require.Equal(t, "abc1234", msgs[0].Message.ID())
+
+		start := now.Add(-time.Hour)
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM archive_messages WHERE username = (.+) AND with_jid = (.+) AND stamp >= (.+) AND stamp <= (.+)").
+			WithArgs("ortuman", "noelia@jackal.im", start, now).
+			WillReturnRows(sqlmock.NewRows(archiveMessageTestColumns))
+
+		msgs, err = s.FetchArchiveMessages("ortuman", &mammodel.Filter{With: "noelia@jackal.im", Start: start, End: now})
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, 0, len(msgs))
+
+		// paged backwards from a given message
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT stamp FROM archive_messages WHERE \\(username = (.+) AND id = (.+)\\)").
+			WithArgs("ortuman", "a3").
+			WillReturnRows(sqlmock.NewRows([]string{"stamp"}).AddRow(now))
+		mock.ExpectQuery("SELECT (.+) FROM archive_messages WHERE username = (.+) AND \\(stamp < (.+) OR \\(stamp = (.+) AND id < (.+)\\)\\) ORDER BY stamp DESC, id DESC LIMIT 2").
+			WithArgs("ortuman", now, now, "a3").
+			WillReturnRows(sqlmock.NewRows(archiveMessageTestColumns).
+				AddRow("a2", "ortuman", "noelia@jackal.im", data, now).
+				AddRow("a1", "ortuman", "noelia@jackal.im", data, now))
+
+		msgs, err = s.FetchArchiveMessages("ortuman", &mammodel.Filter{Before: "a3", Limit: 2})
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, 2, len(msgs))
+		require.Equal(t, "a1", msgs[0].ID)
+		require.Equal(t, "a2", msgs[1].ID)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM archive_messages WHERE username = (.+) ORDER BY stamp, id LIMIT 2 OFFSET 3").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(archiveMessageTestColumns))
+
+		_, err = s.FetchArchiveMessages("ortuman", &mammodel.Filter{Offset: 3, Limit: 2})
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		// unknown message
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT stamp FROM archive_messages (.+)").
+			WithArgs("ortuman", "a3").
+			WillReturnRows(sqlmock.NewRows([]string{"stamp"}))
+
+		_, err = s.FetchArchiveMessages("ortuman", &mammodel.Filter{After: "a3"})
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, mammodel.ErrMessageNotFound, err)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM archive_messages (.+)").
+			WithArgs("ortuman").
+			WillReturnError(errMySQLStorage)
+
+		_, err = s.FetchArchiveMessages("ortuman", &mammodel.Filter{})
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageInsertArchivePrefs(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		prefs := mammodel.Prefs{
+			Username: "ortuman",
+			Default:  mammodel.DefaultRoster,
+			Always:   []string{"noelia@jackal.im", "romeo@jackal.im"},
+		}
+		s, mock := d.newMock()
+		mock.ExpectExec("INSERT INTO archive_prefs (.+) "+d.upsert+" (.+)").
+			WithArgs("ortuman", "roster", "noelia@jackal.im;romeo@jackal.im", "", "roster", "noelia@jackal.im;romeo@jackal.im", "").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := s.InsertOrUpdateArchivePrefs(&prefs)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectExec("INSERT INTO archive_prefs (.+) " + d.upsert + " (.+)").
+			WillReturnError(errMySQLStorage)
+
+		err = s.InsertOrUpdateArchivePrefs(&prefs)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageFetchArchivePrefs(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		var prefsColumns = []string{"username", "default_mode", "always", "never"}
+
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM archive_prefs (.+)").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(prefsColumns))
+
+		prefs, err := s.FetchArchivePrefs("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Nil(t, prefs)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM archive_prefs (.+)").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(prefsColumns).AddRow("ortuman", "always", "", "romeo@jackal.im"))
+
+		prefs, err = s.FetchArchivePrefs("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.NotNil(t, prefs)
+		require.Equal(t, mammodel.DefaultAlways, prefs.Default)
+		require.Nil(t, prefs.Always)
+		require.Equal(t, []string{"romeo@jackal.im"}, prefs.Never)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM archive_prefs (.+)").
+			WithArgs("ortuman").
+			WillReturnError(errMySQLStorage)
+
+		_, err = s.FetchArchivePrefs("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageDeleteArchive(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM archive_messages (.+)").
+			WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectExec("DELETE FROM archive_prefs (.+)").
+			WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := s.DeleteArchive("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM archive_messages (.+)").
+			WithArgs("ortuman").WillReturnError(errMySQLStorage)
+		mock.ExpectRollback()
+
+		err = s.DeleteArchive("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
@@ -0,0 +1,151 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/ortuman/jackal/log"
+)
+
+// ErrSchemaTooNew will be returned when database schema version
+// is newer than the one supported by this binary.
+var ErrSchemaTooNew = errors.New("sql: database schema is newer than supported")
+
+type migration struct {
+	version     int
+	description string
+	up          map[string][]string // driver to statements
+}
+
+// migrations must be kept in ascending version order.
+//
+// MySQL implicitly commits every DDL statement, so a failing migration
+// might have been partially applied before its version gets recorded.
+// Hence, migration statements must be safe to run again (creating tables
+// 'IF NOT EXISTS') or be the only statement of their migration.
+var migrations = []migration{
+	{version: 1, description: "initial schema", up: map[string][]string{
+		mySQLDriver:      mySQLSchemaV1,
+		postgreSQLDriver: postgreSQLSchemaV1,
+		sqliteDriver:     sqliteSchemaV1,
+	}},
+	{version: 2, description: "user credentials", up: map[string][]string{
+		mySQLDriver:      mySQLSchemaV2,
+		postgreSQLDriver: postgreSQLSchemaV2,
+		sqliteDriver:     sqliteSchemaV2,
+	}},
+	{version: 3, description: "multi-user chat", up: map[string][]string{
+		mySQLDriver:      mySQLSchemaV3,
+		postgreSQLDriver: postgreSQLSchemaV3,
+		sqliteDriver:     sqliteSchemaV3,
+	}},
+	{version: 4, description: "message archive", up: map[string][]string{
+		mySQLDriver:      mySQLSchemaV4,
+		postgreSQLDriver: postgreSQLSchemaV4,
+		sqliteDriver:     sqliteSchemaV4,
+	}},
+	{version: 5, description: "publish-subscribe", up: map[string][]string{
+		mySQLDriver:      mySQLSchemaV5,
+		postgreSQLDriver: postgreSQLSchemaV5,
+		sqliteDriver:     sqliteSchemaV5,
+	}},
+	{version: 6, description: "entity capabilities", up: map[string][]string{
+		mySQLDriver:      mySQLSchemaV6,
+		postgreSQLDriver: postgreSQLSchemaV6,
+		sqliteDriver:     sqliteSchemaV6,
+	}},
+}
+
+// LatestSchemaVersion returns the newest schema version known by this binary.
+func LatestSchemaVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].version
+}
+
+// SchemaVersion returns current database schema version.
+func (s *Storage) SchemaVersion() (int, error) {
+	q := fmt.Sprintf("CREATE TABLE IF NOT EXISTS schema_migrations (version INT PRIMARY KEY, description TEXT NOT NULL, applied_at %s NOT NULL)", s.timestampType())
+	if _, err := s.db.Exec(q); err != nil {
+		return 0, err
+	}
+	var ver int
+	err := s.sq.Select("COALESCE(MAX(version), 0)").
+		From("schema_migrations").
+		RunWith(s.db).QueryRow().Scan(&ver)
+	if err != nil {
+		return 0, err
+	}
+	return ver, nil
+}
+
+// Migrate applies in order every pending schema migration,
+// returning the resulting schema version.
+func (s *Storage) Migrate() (int, error) {
+	ver, err := s.SchemaVersion()
+	if err != nil {
+		return 0, err
+	}
+	if ver > LatestSchemaVersion() {
+		return ver, ErrSchemaTooNew
+	}
+	for _, m := range migrations {
+		if m.version <= ver {
+			continue
+		}
+		log.Infof("sql: applying schema migration %d (%s)...", m.version, m.description)
+
+		err := s.inTransaction(func(tx *sql.Tx) error {
+			for i, stmt := range m.up[s.driver] {
+				if _, err := tx.Exec(stmt); err != nil {
+					if s.driver == mySQLDriver && i > 0 {
+						return fmt.Errorf("%v (statements preceding #%d have already been committed)", err, i+1)
+					}
+					return err
+				}
+			}
+			_, err := s.sq.Insert("schema_migrations").
+				Columns("version", "description", "applied_at").
+				Values(m.version, m.description, nowExpr).
+				RunWith(tx).Exec()
+			return err
+		})
+		if err != nil {
+			return ver, fmt.Errorf("sql: schema migration %d failed: %v", m.version, err)
+		}
+		ver = m.version
+	}
+	return ver, nil
+}
+
+func (s *Storage) initSchema(manualMigrations bool) error {
+	ver, err := s.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	latestVer := LatestSchemaVersion()
+	switch {
+	case ver > latestVer:
+		return fmt.Errorf("sql: database schema version %d is newer than supported version %d", ver, latestVer)
+	case ver == latestVer:
+		return nil
+	case manualMigrations:
+		return fmt.Errorf("sql: database schema version %d is outdated (expected %d)... run 'jackal migrate'", ver, latestVer)
+	}
+	_, err = s.Migrate()
+	return err
+}
+
+func (s *Storage) timestampType() string {
+	if s.driver == postgreSQLDriver {
+		return "TIMESTAMP"
+	}
+	return "DATETIME"
+}
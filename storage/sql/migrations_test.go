@@ -0,0 +1,158 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMySQLStorageSchemaVersion(t *testing.T) {
+	s, mock := NewMock()
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations (.+)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COALESCE(.+) FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(3))
+
+	ver, err := s.SchemaVersion()
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 3, ver)
+
+	s, mock = NewMock()
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations (.+)").
+		WillReturnError(errMySQLStorage)
+
+	_, err = s.SchemaVersion()
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, errMySQLStorage, err)
+}
+
+func TestMySQLStorageMigrate(t *testing.T) {
+	defer tUtilSetMigrations([]migration{
+		{version: 1, description: "first", up: map[string][]string{mySQLDriver: {"CREATE TABLE a (id INT)"}}},
+		{version: 2, description: "second", up: map[string][]string{mySQLDriver: {"CREATE TABLE b (id INT)", "CREATE TABLE c (id INT)"}}},
+	})()
+	require.Equal(t, 2, LatestSchemaVersion())
+
+	// apply pending migrations
+	s, mock := NewMock()
+	tUtilExpectSchemaVersion(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE b (.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE c (.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("INSERT INTO schema_migrations (.+)").
+		WithArgs(2, "second").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	ver, err := s.Migrate()
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Nil(t, err)
+	require.Equal(t, 2, ver)
+
+	// failed migration
+	s, mock = NewMock()
+	tUtilExpectSchemaVersion(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE b (.+)").WillReturnError(errMySQLStorage)
+	mock.ExpectRollback()
+
+	ver, err = s.Migrate()
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.NotNil(t, err)
+	require.Equal(t, 1, ver)
+
+	// partially applied migration
+	s, mock = NewMock()
+	tUtilExpectSchemaVersion(mock, 1)
+	mock.ExpectBegin()
+	mock.ExpectExec("CREATE TABLE b (.+)").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("CREATE TABLE c (.+)").WillReturnError(errMySQLStorage)
+	mock.ExpectRollback()
+
+	ver, err = s.Migrate()
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "#2")
+	require.Equal(t, 1, ver)
+
+	// database newer than binary
+	s, mock = NewMock()
+	tUtilExpectSchemaVersion(mock, 3)
+
+	_, err = s.Migrate()
+	require.Nil(t, mock.ExpectationsWereMet())
+	require.Equal(t, ErrSchemaTooNew, err)
+}
+
+func TestMySQLStorageInitSchema(t *testing.T) {
+	defer tUtilSetMigrations([]migration{
+		{version: 1, description: "first", up: map[string][]string{mySQLDriver: {"CREATE TABLE a (id INT)"}}},
+	})()
+
+	s, mock := NewMock()
+	tUtilExpectSchemaVersion(mock, 1)
+	require.Nil(t, s.initSchema(true))
+	require.Nil(t, mock.ExpectationsWereMet())
+
+	// refuse to start with a newer schema
+	s, mock = NewMock()
+	tUtilExpectSchemaVersion(mock, 2)
+	require.NotNil(t, s.initSchema(false))
+	require.Nil(t, mock.ExpectationsWereMet())
+
+	// pending migrations not applied in manual mode
+	s, mock = NewMock()
+	tUtilExpectSchemaVersion(mock, 0)
+	require.NotNil(t, s.initSchema(true))
+	require.Nil(t, mock.ExpectationsWereMet())
+}
+
+func TestMigrations(t *testing.T) {
+	ver := 0
+	for _, m := range migrations {
+		require.Equal(t, ver+1, m.version)
+		ver = m.version
+
+		for _, driver := range []string{mySQLDriver, postgreSQLDriver, sqliteDriver} {
+			stmts := m.up[driver]
+			require.NotEmpty(t, stmts)
+
+			// MySQL DDL is not transactional... make sure a partially applied migration can be retried
+			if driver != mySQLDriver || len(stmts) == 1 {
+				continue
+			}
+			for _, stmt := range stmts {
+				require.True(t, strings.HasPrefix(stmt, "CREATE TABLE IF NOT EXISTS "), stmt)
+			}
+		}
+	}
+	// reference schema files must be stamped with latest version
+	for _, file := range []string{"../../sql/mysql.sql", "../../sql/postgres.sql"} {
+		b, err := ioutil.ReadFile(file)
+		require.Nil(t, err)
+		require.Contains(t, string(b), fmt.Sprintf("(%d, '%s'", ver, migrations[len(migrations)-1].description))
+	}
+}
+
+func tUtilSetMigrations(m []migration) (restore func()) {
+	prev := migrations
+	migrations = m
+	return func() { migrations = prev }
+}
+
+func tUtilExpectSchemaVersion(mock sqlmock.Sqlmock, ver int) {
+	mock.ExpectExec("CREATE TABLE IF NOT EXISTS schema_migrations (.+)").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("SELECT COALESCE(.+) FROM schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(ver))
+}
@@ -0,0 +1,147 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"database/sql"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model/mucmodel"
+)
+
+var mucRoomColumns = []string{"room_jid", "name", "description", "subject", "password",
+	"public", "members_only", "moderated", "non_anonymous", "max_occupants"}
+
+// InsertOrUpdateRoom inserts a new room entity into storage,
+// or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdateRoom(room *mucmodel.Room) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		q := s.sq.Insert("muc_rooms").
+			Columns(mucRoomColumns...).
+			Columns("updated_at", "created_at").
+			Values(room.RoomJID, room.Name, room.Description, room.Subject, room.Password,
+				room.Public, room.MembersOnly, room.Moderated, room.NonAnonymous, room.MaxOccupants, nowExpr, nowExpr).
+			Suffix(s.onConflictUpdate("room_jid")+" name = ?, description = ?, subject = ?, password = ?, public = ?, members_only = ?, moderated = ?, non_anonymous = ?, max_occupants = ?, updated_at = CURRENT_TIMESTAMP",
+				room.Name, room.Description, room.Subject, room.Password,
+				room.Public, room.MembersOnly, room.Moderated, room.NonAnonymous, room.MaxOccupants)
+
+		if _, err := q.RunWith(tx).Exec(); err != nil {
+			return err
+		}
+		_, err := s.sq.Delete("muc_affiliations").Where(sq.Eq{"room_jid": room.RoomJID}).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		for _, aff := range room.Affiliations {
+			_, err := s.sq.Insert("muc_affiliations").
+				Columns("room_jid", "jid", "affiliation", "created_at").
+				Values(room.RoomJID, aff.JID, aff.Affiliation, nowExpr).
+				RunWith(tx).Exec()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// DeleteRoom deletes a room entity from storage.
+func (s *Storage) DeleteRoom(roomJID string) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		_, err := s.sq.Delete("muc_affiliations").Where(sq.Eq{"room_jid": roomJID}).RunWith(tx).Exec()
+		if err != nil {
+			return err
+		}
+		_, err = s.sq.Delete("muc_rooms").Where(sq.Eq{"room_jid": roomJID}).RunWith(tx).Exec()
+		return err
+	})
+}
+
+// FetchRoom retrieves from storage a room entity.
+func (s *Storage) FetchRoom(roomJID string) (*mucmodel.Room, error) {
+	q := s.sq.Select(mucRoomColumns...).
+		From("muc_rooms").
+		Where(sq.Eq{"room_jid": roomJID})
+
+	var room mucmodel.Room
+	err := s.scanRoomEntity(&room, q.RunWith(s.db).QueryRow())
+	switch err {
+	case nil:
+		affs, err := s.fetchRoomAffiliations(sq.Eq{"room_jid": roomJID})
+		if err != nil {
+			return nil, err
+		}
+		room.Affiliations = affs[roomJID]
+		return &room, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchRooms retrieves from storage all persisted room entities.
+func (s *Storage) FetchRooms() ([]mucmodel.Room, error) {
+	q := s.sq.Select(mucRoomColumns...).
+		From("muc_rooms").
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rooms []mucmodel.Room
+	for rows.Next() {
+		var room mucmodel.Room
+		if err := s.scanRoomEntity(&room, rows); err != nil {
+			return nil, err
+		}
+		rooms = append(rooms, room)
+	}
+	if len(rooms) == 0 {
+		return nil, nil
+	}
+	affs, err := s.fetchRoomAffiliations(nil)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rooms {
+		rooms[i].Affiliations = affs[rooms[i].RoomJID]
+	}
+	return rooms, nil
+}
+
+func (s *Storage) fetchRoomAffiliations(pred interface{}) (map[string][]mucmodel.Affiliation, error) {
+	q := s.sq.Select("room_jid", "jid", "affiliation").
+		From("muc_affiliations").
+		OrderBy("created_at")
+	if pred != nil {
+		q = q.Where(pred)
+	}
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make(map[string][]mucmodel.Affiliation)
+	for rows.Next() {
+		var roomJID string
+		var aff mucmodel.Affiliation
+		if err := rows.Scan(&roomJID, &aff.JID, &aff.Affiliation); err != nil {
+			return nil, err
+		}
+		ret[roomJID] = append(ret[roomJID], aff)
+	}
+	return ret, nil
+}
+
+func (s *Storage) scanRoomEntity(room *mucmodel.Room, scanner rowScanner) error {
+	return scanner.Scan(&room.RoomJID, &room.Name, &room.Description, &room.Subject, &room.Password,
+		&room.Public, &room.MembersOnly, &room.Moderated, &room.NonAnonymous, &room.MaxOccupants)
+}
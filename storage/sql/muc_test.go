@@ -0,0 +1,155 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	mucRoomTestColumns        = []string{"room_jid", "name", "description", "subject", "password", "public", "members_only", "moderated", "non_anonymous", "max_occupants"}
+	mucAffiliationTestColumns = []string{"room_jid", "jid", "affiliation"}
+)
+
+func TestStorageInsertRoom(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		room := mucmodel.Room{
+			RoomJID: "coven@conference.jackal.im",
+			Name:    "The Coven",
+			Affiliations: []mucmodel.Affiliation{
+				{JID: "ortuman@jackal.im", Affiliation: mucmodel.AffiliationOwner},
+			},
+		}
+		s, mock := d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO muc_rooms (.+) " + d.upsert + " (.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM muc_affiliations (.+)").
+			WithArgs("coven@conference.jackal.im").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO muc_affiliations (.+)").
+			WithArgs("coven@conference.jackal.im", "ortuman@jackal.im", "owner").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := s.InsertOrUpdateRoom(&room)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO muc_rooms (.+) " + d.upsert + " (.+)").
+			WillReturnError(errMySQLStorage)
+		mock.ExpectRollback()
+
+		err = s.InsertOrUpdateRoom(&room)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageDeleteRoom(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM muc_affiliations (.+)").
+			WithArgs("coven@conference.jackal.im").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM muc_rooms (.+)").
+			WithArgs("coven@conference.jackal.im").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := s.DeleteRoom("coven@conference.jackal.im")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM muc_affiliations (.+)").
+			WithArgs("coven@conference.jackal.im").
+			WillReturnError(errMySQLStorage)
+		mock.ExpectRollback()
+
+		err = s.DeleteRoom("coven@conference.jackal.im")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageFetchRoom(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+			WithArgs("coven@conference.jackal.im").
+			WillReturnRows(sqlmock.NewRows(mucRoomTestColumns))
+
+		room, err := s.FetchRoom("coven@conference.jackal.im")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Nil(t, room)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+			WithArgs("coven@conference.jackal.im").
+			WillReturnRows(sqlmock.NewRows(mucRoomTestColumns).
+				AddRow("coven@conference.jackal.im", "The Coven", "", "", "", true, false, false, false, 30))
+		mock.ExpectQuery("SELECT (.+) FROM muc_affiliations (.+)").
+			WithArgs("coven@conference.jackal.im").
+			WillReturnRows(sqlmock.NewRows(mucAffiliationTestColumns).
+				AddRow("coven@conference.jackal.im", "ortuman@jackal.im", "owner"))
+
+		room, err = s.FetchRoom("coven@conference.jackal.im")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.NotNil(t, room)
+		require.Equal(t, "The Coven", room.Name)
+		require.True(t, room.Public)
+		require.Equal(t, 30, room.MaxOccupants)
+		require.Equal(t, 1, len(room.Affiliations))
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+			WithArgs("coven@conference.jackal.im").
+			WillReturnError(errMySQLStorage)
+
+		_, err = s.FetchRoom("coven@conference.jackal.im")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageFetchRooms(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+			WillReturnRows(sqlmock.NewRows(mucRoomTestColumns).
+				AddRow("coven@conference.jackal.im", "The Coven", "", "", "", true, false, false, false, 30).
+				AddRow("darkcave@conference.jackal.im", "", "", "", "", false, false, false, false, 0))
+		mock.ExpectQuery("SELECT (.+) FROM muc_affiliations (.+)").
+			WillReturnRows(sqlmock.NewRows(mucAffiliationTestColumns).
+				AddRow("coven@conference.jackal.im", "ortuman@jackal.im", "owner"))
+
+		rooms, err := s.FetchRooms()
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, 2, len(rooms))
+		require.Equal(t, 1, len(rooms[0].Affiliations))
+		require.Equal(t, 0, len(rooms[1].Affiliations))
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM muc_rooms (.+)").
+			WillReturnError(errMySQLStorage)
+
+		_, err = s.FetchRooms()
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
@@ -14,7 +14,7 @@ import (
 // InsertOfflineMessage inserts a new message element into
 // user's offline queue.
 func (s *Storage) InsertOfflineMessage(message *xmpp.Message, username string) error {
-	q := sq.Insert("offline_messages").
+	q := s.sq.Insert("offline_messages").
 		Columns("username", "data", "created_at").
 		Values(username, message.String(), nowExpr)
 	_, err := q.RunWith(s.db).Exec()
@@ -23,7 +23,7 @@ func (s *Storage) InsertOfflineMessage(message *xmpp.Message, username string) e
 
 // CountOfflineMessages returns current length of user's offline queue.
 func (s *Storage) CountOfflineMessages(username string) (int, error) {
-	q := sq.Select("COUNT(*)").
+	q := s.sq.Select("COUNT(*)").
 		From("offline_messages").
 		Where(sq.Eq{"username": username}).
 		OrderBy("created_at")
@@ -40,7 +40,7 @@ func (s *Storage) CountOfflineMessages(username string) (int, error) {
 
 // FetchOfflineMessages retrieves from storage current user offline queue.
 func (s *Storage) FetchOfflineMessages(username string) ([]*xmpp.Message, error) {
-	q := sq.Select("data").
+	q := s.sq.Select("data").
 		From("offline_messages").
 		Where(sq.Eq{"username": username}).
 		OrderBy("created_at")
@@ -84,7 +84,7 @@ func (s *Storage) FetchOfflineMessages(username string) ([]*xmpp.Message, error)
 
 // DeleteOfflineMessages clears a user offline queue.
 func (s *Storage) DeleteOfflineMessages(username string) error {
-	q := sq.Delete("offline_messages").Where(sq.Eq{"username": username})
+	q := s.sq.Delete("offline_messages").Where(sq.Eq{"username": username})
 	_, err := q.RunWith(s.db).Exec()
 	return err
 }
@@ -22,10 +22,10 @@ func (s *Storage) InsertOrUpdatePrivateXML(privateXML []xmpp.XElement, namespace
 	}
 	rawXML := buf.String()
 
-	q := sq.Insert("private_storage").
+	q := s.sq.Insert("private_storage").
 		Columns("username", "namespace", "data", "updated_at", "created_at").
 		Values(username, namespace, rawXML, nowExpr, nowExpr).
-		Suffix("ON DUPLICATE KEY UPDATE data = ?, updated_at = NOW()", rawXML)
+		Suffix(s.onConflictUpdate("username", "namespace")+" data = ?, updated_at = CURRENT_TIMESTAMP", rawXML)
 
 	_, err := q.RunWith(s.db).Exec()
 	return err
@@ -33,7 +33,7 @@ func (s *Storage) InsertOrUpdatePrivateXML(privateXML []xmpp.XElement, namespace
 
 // FetchPrivateXML retrieves from storage a private element.
 func (s *Storage) FetchPrivateXML(namespace string, username string) ([]xmpp.XElement, error) {
-	q := sq.Select("data").
+	q := s.sq.Select("data").
 		From("private_storage").
 		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"namespace": namespace}})
 
@@ -60,3 +60,36 @@ func (s *Storage) FetchPrivateXML(namespace string, username string) ([]xmpp.XEl
 		return nil, err
 	}
 }
+
+// FetchPrivateXMLNamespaces retrieves from storage all private element
+// namespaces associated to a given user.
+func (s *Storage) FetchPrivateXMLNamespaces(username string) ([]string, error) {
+	q := s.sq.Select("namespace").
+		From("private_storage").
+		Where(sq.Eq{"username": username}).
+		OrderBy("namespace")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []string
+	for rows.Next() {
+		var namespace string
+		if err := rows.Scan(&namespace); err != nil {
+			return nil, err
+		}
+		ret = append(ret, namespace)
+	}
+	return ret, rows.Err()
+}
+
+// DeletePrivateXML deletes from storage all private elements
+// associated to a given user.
+func (s *Storage) DeletePrivateXML(username string) error {
+	q := s.sq.Delete("private_storage").Where(sq.Eq{"username": username})
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
@@ -13,79 +13,126 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestMySQLStorageInsertPrivateXML(t *testing.T) {
-	private := xmpp.NewElementNamespace("exodus", "exodus:ns")
-	rawXML := private.String()
-
-	s, mock := NewMock()
-	mock.ExpectExec("INSERT INTO private_storage (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", "exodus:ns", rawXML, rawXML).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	err := s.InsertOrUpdatePrivateXML([]xmpp.XElement{private}, "exodus:ns", "ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, err)
-
-	s, mock = NewMock()
-	mock.ExpectExec("INSERT INTO private_storage (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", "exodus:ns", rawXML, rawXML).
-		WillReturnError(errMySQLStorage)
-
-	err = s.InsertOrUpdatePrivateXML([]xmpp.XElement{private}, "exodus:ns", "ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Equal(t, errMySQLStorage, err)
+func TestStorageInsertPrivateXML(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		private := xmpp.NewElementNamespace("exodus", "exodus:ns")
+		rawXML := private.String()
+
+		s, mock := d.newMock()
+		mock.ExpectExec("INSERT INTO private_storage (.+) "+d.upsert+" (.+)").
+			WithArgs("ortuman", "exodus:ns", rawXML, rawXML).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		err := s.InsertOrUpdatePrivateXML([]xmpp.XElement{private}, "exodus:ns", "ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectExec("INSERT INTO private_storage (.+) "+d.upsert+" (.+)").
+			WithArgs("ortuman", "exodus:ns", rawXML, rawXML).
+			WillReturnError(errMySQLStorage)
+
+		err = s.InsertOrUpdatePrivateXML([]xmpp.XElement{private}, "exodus:ns", "ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
 }
 
-func TestMySQLStorageFetchPrivateXML(t *testing.T) {
-	var privateColumns = []string{"data"}
-
-	s, mock := NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("ortuman", "exodus:ns").
-		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow("<exodus xmlns='exodus:ns'><stuff/></exodus>"))
-
-	elems, err := s.FetchPrivateXML("exodus:ns", "ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, err)
-	require.Equal(t, 1, len(elems))
-
-	s, mock = NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("ortuman", "exodus:ns").
-		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow("<exodus xmlns='exodus:ns'><stuff/>"))
-
-	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.NotNil(t, err)
-	require.Equal(t, 0, len(elems))
-
-	s, mock = NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("ortuman", "exodus:ns").
-		WillReturnRows(sqlmock.NewRows(privateColumns).AddRow(""))
-
-	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, err)
-	require.Equal(t, 0, len(elems))
-
-	s, mock = NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("ortuman", "exodus:ns").
-		WillReturnRows(sqlmock.NewRows(privateColumns))
-
-	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, err)
-	require.Equal(t, 0, len(elems))
-
-	s, mock = NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
-		WithArgs("ortuman", "exodus:ns").
-		WillReturnError(errMySQLStorage)
-
-	elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Equal(t, errMySQLStorage, err)
-	require.Equal(t, 0, len(elems))
+func TestStorageFetchPrivateXML(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		var privateColumns = []string{"data"}
+
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
+			WithArgs("ortuman", "exodus:ns").
+			WillReturnRows(sqlmock.NewRows(privateColumns).AddRow("<exodus xmlns='exodus:ns'><stuff/></exodus>"))
+
+		elems, err := s.FetchPrivateXML("exodus:ns", "ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, 1, len(elems))
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
+			WithArgs("ortuman", "exodus:ns").
+			WillReturnRows(sqlmock.NewRows(privateColumns).AddRow("<exodus xmlns='exodus:ns'><stuff/>"))
+
+		elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.NotNil(t, err)
+		require.Equal(t, 0, len(elems))
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
+			WithArgs("ortuman", "exodus:ns").
+			WillReturnRows(sqlmock.NewRows(privateColumns).AddRow(""))
+
+		elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, 0, len(elems))
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
+			WithArgs("ortuman", "exodus:ns").
+			WillReturnRows(sqlmock.NewRows(privateColumns))
+
+		elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, 0, len(elems))
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM private_storage (.+)").
+			WithArgs("ortuman", "exodus:ns").
+			WillReturnError(errMySQLStorage)
+
+		elems, err = s.FetchPrivateXML("exodus:ns", "ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+		require.Equal(t, 0, len(elems))
+	})
+}
+
+func TestStorageFetchPrivateXMLNamespaces(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT namespace FROM private_storage WHERE (.+) ORDER BY namespace").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows([]string{"namespace"}).AddRow("exodus:ns").AddRow("storage:bookmarks"))
+
+		namespaces, err := s.FetchPrivateXMLNamespaces("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, []string{"exodus:ns", "storage:bookmarks"}, namespaces)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT namespace FROM private_storage WHERE (.+) ORDER BY namespace").
+			WithArgs("ortuman").
+			WillReturnError(errMySQLStorage)
+
+		_, err = s.FetchPrivateXMLNamespaces("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageDeletePrivateXML(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectExec("DELETE FROM private_storage (.+)").
+			WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := s.DeletePrivateXML("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectExec("DELETE FROM private_storage (.+)").
+			WithArgs("ortuman").WillReturnError(errMySQLStorage)
+
+		err = s.DeletePrivateXML("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
 }
@@ -0,0 +1,258 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"database/sql"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/xmpp"
+)
+
+var pubSubNodeColumns = []string{"host", "name", "title", "access_model", "max_items",
+	"persist_items", "deliver_payloads", "notify_retract", "notify_delete"}
+
+// InsertOrUpdatePubSubNode inserts a new pubsub node entity into storage,
+// or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdatePubSubNode(node *pubsubmodel.Node) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		opts := &node.Options
+		q := s.sq.Insert("pubsub_nodes").
+			Columns(pubSubNodeColumns...).
+			Columns("updated_at", "created_at").
+			Values(node.Host, node.Name, opts.Title, opts.AccessModel, opts.MaxItems,
+				opts.PersistItems, opts.DeliverPayloads, opts.NotifyRetract, opts.NotifyDelete, nowExpr, nowExpr).
+			Suffix(s.onConflictUpdate("host", "name")+" title = ?, access_model = ?, max_items = ?, persist_items = ?, deliver_payloads = ?, notify_retract = ?, notify_delete = ?, updated_at = CURRENT_TIMESTAMP",
+				opts.Title, opts.AccessModel, opts.MaxItems, opts.PersistItems, opts.DeliverPayloads, opts.NotifyRetract, opts.NotifyDelete)
+
+		if _, err := q.RunWith(tx).Exec(); err != nil {
+			return err
+		}
+		nodePred := sq.And{sq.Eq{"host": node.Host}, sq.Eq{"node": node.Name}}
+		if _, err := s.sq.Delete("pubsub_affiliations").Where(nodePred).RunWith(tx).Exec(); err != nil {
+			return err
+		}
+		for _, aff := range node.Affiliations {
+			_, err := s.sq.Insert("pubsub_affiliations").
+				Columns("host", "node", "jid", "affiliation", "created_at").
+				Values(node.Host, node.Name, aff.JID, aff.Affiliation, nowExpr).
+				RunWith(tx).Exec()
+			if err != nil {
+				return err
+			}
+		}
+		if _, err := s.sq.Delete("pubsub_subscriptions").Where(nodePred).RunWith(tx).Exec(); err != nil {
+			return err
+		}
+		for _, sub := range node.Subscriptions {
+			_, err := s.sq.Insert("pubsub_subscriptions").
+				Columns("host", "node", "subid", "jid", "subscription", "created_at").
+				Values(node.Host, node.Name, sub.SubID, sub.JID, sub.Subscription, nowExpr).
+				RunWith(tx).Exec()
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// FetchPubSubNode retrieves from storage a pubsub node entity.
+func (s *Storage) FetchPubSubNode(host, name string) (*pubsubmodel.Node, error) {
+	q := s.sq.Select(pubSubNodeColumns...).
+		From("pubsub_nodes").
+		Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}})
+
+	var node pubsubmodel.Node
+	err := s.scanPubSubNodeEntity(&node, q.RunWith(s.db).QueryRow())
+	switch err {
+	case nil:
+		nodePred := sq.And{sq.Eq{"host": host}, sq.Eq{"node": name}}
+		affs, err := s.fetchPubSubAffiliations(nodePred)
+		if err != nil {
+			return nil, err
+		}
+		subs, err := s.fetchPubSubSubscriptions(nodePred)
+		if err != nil {
+			return nil, err
+		}
+		node.Affiliations = affs[name]
+		node.Subscriptions = subs[name]
+		return &node, nil
+	case sql.ErrNoRows:
+		return nil, nil
+	default:
+		return nil, err
+	}
+}
+
+// FetchPubSubNodes retrieves from storage all pubsub node entities
+// associated to a given host.
+func (s *Storage) FetchPubSubNodes(host string) ([]pubsubmodel.Node, error) {
+	q := s.sq.Select(pubSubNodeColumns...).
+		From("pubsub_nodes").
+		Where(sq.Eq{"host": host}).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var nodes []pubsubmodel.Node
+	for rows.Next() {
+		var node pubsubmodel.Node
+		if err := s.scanPubSubNodeEntity(&node, rows); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) == 0 {
+		return nil, nil
+	}
+	hostPred := sq.Eq{"host": host}
+	affs, err := s.fetchPubSubAffiliations(hostPred)
+	if err != nil {
+		return nil, err
+	}
+	subs, err := s.fetchPubSubSubscriptions(hostPred)
+	if err != nil {
+		return nil, err
+	}
+	for i := range nodes {
+		nodes[i].Affiliations = affs[nodes[i].Name]
+		nodes[i].Subscriptions = subs[nodes[i].Name]
+	}
+	return nodes, nil
+}
+
+// DeletePubSubNode deletes a pubsub node entity from storage,
+// along with all its published items.
+func (s *Storage) DeletePubSubNode(host, name string) error {
+	return s.inTransaction(func(tx *sql.Tx) error {
+		nodePred := sq.And{sq.Eq{"host": host}, sq.Eq{"node": name}}
+		for _, table := range []string{"pubsub_items", "pubsub_subscriptions", "pubsub_affiliations"} {
+			if _, err := s.sq.Delete(table).Where(nodePred).RunWith(tx).Exec(); err != nil {
+				return err
+			}
+		}
+		_, err := s.sq.Delete("pubsub_nodes").Where(sq.And{sq.Eq{"host": host}, sq.Eq{"name": name}}).RunWith(tx).Exec()
+		return err
+	})
+}
+
+// InsertOrUpdatePubSubNodeItem inserts a new pubsub item entity into storage,
+// or updates it in case it's been previously inserted.
+func (s *Storage) InsertOrUpdatePubSubNodeItem(item *pubsubmodel.Item, host, name string) error {
+	var payload string
+	if item.Payload != nil {
+		payload = item.Payload.String()
+	}
+	q := s.sq.Insert("pubsub_items").
+		Columns("host", "node", "item_id", "publisher", "payload", "stamp", "created_at").
+		Values(host, name, item.ID, item.Publisher, payload, item.Stamp, nowExpr).
+		Suffix(s.onConflictUpdate("host", "node", "item_id")+" publisher = ?, payload = ?, stamp = ?", item.Publisher, payload, item.Stamp)
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
+
+// DeletePubSubNodeItem deletes a pubsub item entity from storage.
+func (s *Storage) DeletePubSubNodeItem(host, name, itemID string) error {
+	_, err := s.sq.Delete("pubsub_items").
+		Where(sq.And{sq.Eq{"host": host}, sq.Eq{"node": name}, sq.Eq{"item_id": itemID}}).
+		RunWith(s.db).Exec()
+	return err
+}
+
+// FetchPubSubNodeItems retrieves from storage all items published
+// to a given node, in chronological order.
+func (s *Storage) FetchPubSubNodeItems(host, name string) ([]pubsubmodel.Item, error) {
+	q := s.sq.Select("item_id", "publisher", "payload", "stamp").
+		From("pubsub_items").
+		Where(sq.And{sq.Eq{"host": host}, sq.Eq{"node": name}}).
+		OrderBy("stamp")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []pubsubmodel.Item
+	for rows.Next() {
+		var item pubsubmodel.Item
+		var payload string
+		if err := rows.Scan(&item.ID, &item.Publisher, &payload, &item.Stamp); err != nil {
+			return nil, err
+		}
+		if len(payload) > 0 {
+			parser := xmpp.NewParser(strings.NewReader(payload), xmpp.DefaultMode, 0)
+			item.Payload, err = parser.ParseElement()
+			if err != nil {
+				return nil, err
+			}
+		}
+		items = append(items, item)
+	}
+	return items, nil
+}
+
+func (s *Storage) fetchPubSubAffiliations(pred interface{}) (map[string][]pubsubmodel.Affiliation, error) {
+	q := s.sq.Select("node", "jid", "affiliation").
+		From("pubsub_affiliations").
+		Where(pred).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make(map[string][]pubsubmodel.Affiliation)
+	for rows.Next() {
+		var node string
+		var aff pubsubmodel.Affiliation
+		if err := rows.Scan(&node, &aff.JID, &aff.Affiliation); err != nil {
+			return nil, err
+		}
+		ret[node] = append(ret[node], aff)
+	}
+	return ret, nil
+}
+
+func (s *Storage) fetchPubSubSubscriptions(pred interface{}) (map[string][]pubsubmodel.Subscription, error) {
+	q := s.sq.Select("node", "subid", "jid", "subscription").
+		From("pubsub_subscriptions").
+		Where(pred).
+		OrderBy("created_at")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make(map[string][]pubsubmodel.Subscription)
+	for rows.Next() {
+		var node string
+		var sub pubsubmodel.Subscription
+		if err := rows.Scan(&node, &sub.SubID, &sub.JID, &sub.Subscription); err != nil {
+			return nil, err
+		}
+		ret[node] = append(ret[node], sub)
+	}
+	return ret, nil
+}
+
+func (s *Storage) scanPubSubNodeEntity(node *pubsubmodel.Node, scanner rowScanner) error {
+	opts := &node.Options
+	return scanner.Scan(&node.Host, &node.Name, &opts.Title, &opts.AccessModel, &opts.MaxItems,
+		&opts.PersistItems, &opts.DeliverPayloads, &opts.NotifyRetract, &opts.NotifyDelete)
+}
@@ -0,0 +1,261 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	pubSubNodeTestColumns         = []string{"host", "name", "title", "access_model", "max_items", "persist_items", "deliver_payloads", "notify_retract", "notify_delete"}
+	pubSubAffiliationTestColumns  = []string{"node", "jid", "affiliation"}
+	pubSubSubscriptionTestColumns = []string{"node", "subid", "jid", "subscription"}
+	pubSubItemTestColumns         = []string{"item_id", "publisher", "payload", "stamp"}
+)
+
+func TestStorageInsertPubSubNode(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		node := pubsubmodel.Node{
+			Host: "pubsub.jackal.im",
+			Name: "princely_musings",
+			Affiliations: []pubsubmodel.Affiliation{
+				{JID: "ortuman@jackal.im", Affiliation: pubsubmodel.AffiliationOwner},
+			},
+			Subscriptions: []pubsubmodel.Subscription{
+				{SubID: "1", JID: "noelia@jackal.im", Subscription: pubsubmodel.SubscriptionSubscribed},
+			},
+		}
+		s, mock := d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO pubsub_nodes (.+) " + d.upsert + " (.+)").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM pubsub_affiliations (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO pubsub_affiliations (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings", "ortuman@jackal.im", "owner").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM pubsub_subscriptions (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("INSERT INTO pubsub_subscriptions (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings", "1", "noelia@jackal.im", "subscribed").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := s.InsertOrUpdatePubSubNode(&node)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("INSERT INTO pubsub_nodes (.+) " + d.upsert + " (.+)").
+			WillReturnError(errMySQLStorage)
+		mock.ExpectRollback()
+
+		err = s.InsertOrUpdatePubSubNode(&node)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageFetchPubSubNode(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_nodes (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnRows(sqlmock.NewRows(pubSubNodeTestColumns))
+
+		node, err := s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Nil(t, node)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_nodes (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnRows(sqlmock.NewRows(pubSubNodeTestColumns).
+				AddRow("pubsub.jackal.im", "princely_musings", "Princely Musings", "open", 10, true, true, false, true))
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_affiliations (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnRows(sqlmock.NewRows(pubSubAffiliationTestColumns).
+				AddRow("princely_musings", "ortuman@jackal.im", "owner"))
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_subscriptions (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnRows(sqlmock.NewRows(pubSubSubscriptionTestColumns).
+				AddRow("princely_musings", "1", "noelia@jackal.im", "subscribed"))
+
+		node, err = s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.NotNil(t, node)
+		require.Equal(t, "Princely Musings", node.Options.Title)
+		require.Equal(t, pubsubmodel.AccessModelOpen, node.Options.AccessModel)
+		require.Equal(t, 10, node.Options.MaxItems)
+		require.Equal(t, 1, len(node.Affiliations))
+		require.Equal(t, 1, len(node.Subscriptions))
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_nodes (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnError(errMySQLStorage)
+
+		_, err = s.FetchPubSubNode("pubsub.jackal.im", "princely_musings")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageFetchPubSubNodes(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_nodes (.+)").
+			WithArgs("pubsub.jackal.im").
+			WillReturnRows(sqlmock.NewRows(pubSubNodeTestColumns).
+				AddRow("pubsub.jackal.im", "princely_musings", "", "open", 10, true, true, false, true).
+				AddRow("pubsub.jackal.im", "news", "", "whitelist", 10, true, true, false, true))
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_affiliations (.+)").
+			WithArgs("pubsub.jackal.im").
+			WillReturnRows(sqlmock.NewRows(pubSubAffiliationTestColumns).
+				AddRow("princely_musings", "ortuman@jackal.im", "owner"))
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_subscriptions (.+)").
+			WithArgs("pubsub.jackal.im").
+			WillReturnRows(sqlmock.NewRows(pubSubSubscriptionTestColumns))
+
+		nodes, err := s.FetchPubSubNodes("pubsub.jackal.im")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, 2, len(nodes))
+		require.Equal(t, 1, len(nodes[0].Affiliations))
+		require.Equal(t, 0, len(nodes[1].Affiliations))
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_nodes (.+)").
+			WithArgs("pubsub.jackal.im").
+			WillReturnError(errMySQLStorage)
+
+		_, err = s.FetchPubSubNodes("pubsub.jackal.im")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageDeletePubSubNode(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM pubsub_items (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM pubsub_subscriptions (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM pubsub_affiliations (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM pubsub_nodes (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := s.DeletePubSubNode("pubsub.jackal.im", "princely_musings")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM pubsub_items (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnError(errMySQLStorage)
+		mock.ExpectRollback()
+
+		err = s.DeletePubSubNode("pubsub.jackal.im", "princely_musings")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageInsertPubSubNodeItem(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		payload := xmpp.NewElementNamespace("entry", "http://www.w3.org/2005/Atom")
+		item := pubsubmodel.Item{ID: "1", Publisher: "ortuman@jackal.im", Payload: payload, Stamp: time.Now()}
+
+		s, mock := d.newMock()
+		mock.ExpectExec("INSERT INTO pubsub_items (.+) "+d.upsert+" (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings", "1", "ortuman@jackal.im", payload.String(), item.Stamp,
+				"ortuman@jackal.im", payload.String(), item.Stamp).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := s.InsertOrUpdatePubSubNodeItem(&item, "pubsub.jackal.im", "princely_musings")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectExec("INSERT INTO pubsub_items (.+) " + d.upsert + " (.+)").
+			WillReturnError(errMySQLStorage)
+
+		err = s.InsertOrUpdatePubSubNodeItem(&item, "pubsub.jackal.im", "princely_musings")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageDeletePubSubNodeItem(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectExec("DELETE FROM pubsub_items (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings", "1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := s.DeletePubSubNodeItem("pubsub.jackal.im", "princely_musings", "1")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectExec("DELETE FROM pubsub_items (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings", "1").
+			WillReturnError(errMySQLStorage)
+
+		err = s.DeletePubSubNodeItem("pubsub.jackal.im", "princely_musings", "1")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
+
+func TestStorageFetchPubSubNodeItems(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		payload := `<entry xmlns="http://www.w3.org/2005/Atom"/>`
+
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_items (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnRows(sqlmock.NewRows(pubSubItemTestColumns).
+				AddRow("1", "ortuman@jackal.im", payload, time.Now()).
+				AddRow("2", "ortuman@jackal.im", "", time.Now()))
+
+		items, err := s.FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, 2, len(items))
+		require.Equal(t, "entry", items[0].Payload.Name())
+		require.Nil(t, items[1].Payload)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM pubsub_items (.+)").
+			WithArgs("pubsub.jackal.im", "princely_musings").
+			WillReturnError(errMySQLStorage)
+
+		_, err = s.FetchPubSubNodeItems("pubsub.jackal.im", "princely_musings")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
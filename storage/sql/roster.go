@@ -7,6 +7,7 @@ package sql
 
 import (
 	"database/sql"
+	"fmt"
 	"strings"
 
 	sq "github.com/Masterminds/squirrel"
@@ -19,10 +20,10 @@ import (
 // or updates it in case it's been previously inserted.
 func (s *Storage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Version, error) {
 	err := s.inTransaction(func(tx *sql.Tx) error {
-		q := sq.Insert("roster_versions").
+		q := s.sq.Insert("roster_versions").
 			Columns("username", "created_at", "updated_at").
 			Values(ri.Username, nowExpr, nowExpr).
-			Suffix("ON DUPLICATE KEY UPDATE ver = ver + 1, updated_at = NOW()")
+			Suffix(s.onConflictUpdate("username") + " ver = roster_versions.ver + 1, updated_at = CURRENT_TIMESTAMP")
 
 		if _, err := q.RunWith(tx).Exec(); err != nil {
 			return err
@@ -30,10 +31,11 @@ func (s *Storage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Ve
 		groups := strings.Join(ri.Groups, ";")
 
 		verExpr := sq.Expr("(SELECT ver FROM roster_versions WHERE username = ?)", ri.Username)
-		q = sq.Insert("roster_items").
-			Columns("username", "jid", "name", "subscription", "`groups`", "ask", "ver", "created_at", "updated_at").
+		q = s.sq.Insert("roster_items").
+			Columns("username", "jid", "name", "subscription", s.quote("groups"), "ask", "ver", "created_at", "updated_at").
 			Values(ri.Username, ri.JID, ri.Name, ri.Subscription, groups, ri.Ask, verExpr, nowExpr, nowExpr).
-			Suffix("ON DUPLICATE KEY UPDATE name = ?, subscription = ?, `groups` = ?, ask = ?, ver = ver + 1, updated_at = NOW()", ri.Name, ri.Subscription, groups, ri.Ask)
+			Suffix(s.onConflictUpdate("username", "jid")+fmt.Sprintf(" name = ?, subscription = ?, %s = ?, ask = ?, ver = roster_items.ver + 1, updated_at = CURRENT_TIMESTAMP", s.quote("groups")),
+				ri.Name, ri.Subscription, groups, ri.Ask)
 
 		_, err := q.RunWith(tx).Exec()
 		return err
@@ -47,15 +49,15 @@ func (s *Storage) InsertOrUpdateRosterItem(ri *rostermodel.Item) (rostermodel.Ve
 // DeleteRosterItem deletes a roster item entity from storage.
 func (s *Storage) DeleteRosterItem(username, jid string) (rostermodel.Version, error) {
 	err := s.inTransaction(func(tx *sql.Tx) error {
-		q := sq.Insert("roster_versions").
+		q := s.sq.Insert("roster_versions").
 			Columns("username", "created_at", "updated_at").
 			Values(username, nowExpr, nowExpr).
-			Suffix("ON DUPLICATE KEY UPDATE ver = ver + 1, last_deletion_ver = ver, updated_at = NOW()")
+			Suffix(s.onConflictUpdate("username") + " last_deletion_ver = roster_versions.ver + 1, ver = roster_versions.ver + 1, updated_at = CURRENT_TIMESTAMP")
 
 		if _, err := q.RunWith(tx).Exec(); err != nil {
 			return err
 		}
-		_, err := sq.Delete("roster_items").
+		_, err := s.sq.Delete("roster_items").
 			Where(sq.And{sq.Eq{"username": username}, sq.Eq{"jid": jid}}).
 			RunWith(tx).Exec()
 		return err
@@ -69,7 +71,7 @@ func (s *Storage) DeleteRosterItem(username, jid string) (rostermodel.Version, e
 // FetchRosterItems retrieves from storage all roster item entities
 // associated to a given user.
 func (s *Storage) FetchRosterItems(username string) ([]rostermodel.Item, rostermodel.Version, error) {
-	q := sq.Select("username", "jid", "name", "subscription", "`groups`", "ask", "ver").
+	q := s.sq.Select("username", "jid", "name", "subscription", s.quote("groups"), "ask", "ver").
 		From("roster_items").
 		Where(sq.Eq{"username": username}).
 		OrderBy("created_at DESC")
@@ -93,7 +95,7 @@ func (s *Storage) FetchRosterItems(username string) ([]rostermodel.Item, rosterm
 
 // FetchRosterItem retrieves from storage a roster item entity.
 func (s *Storage) FetchRosterItem(username, jid string) (*rostermodel.Item, error) {
-	q := sq.Select("username", "jid", "name", "subscription", "`groups`", "ask", "ver").
+	q := s.sq.Select("username", "jid", "name", "subscription", s.quote("groups"), "ask", "ver").
 		From("roster_items").
 		Where(sq.And{sq.Eq{"username": username}, sq.Eq{"jid": jid}})
 
@@ -113,10 +115,10 @@ func (s *Storage) FetchRosterItem(username, jid string) (*rostermodel.Item, erro
 // into storage, or updates it in case it's been previously inserted.
 func (s *Storage) InsertOrUpdateRosterNotification(rn *rostermodel.Notification) error {
 	presenceXML := rn.Presence.String()
-	q := sq.Insert("roster_notifications").
+	q := s.sq.Insert("roster_notifications").
 		Columns("contact", "jid", "elements", "updated_at", "created_at").
 		Values(rn.Contact, rn.JID, presenceXML, nowExpr, nowExpr).
-		Suffix("ON DUPLICATE KEY UPDATE elements = ?, updated_at = NOW()", presenceXML)
+		Suffix(s.onConflictUpdate("contact", "jid")+" elements = ?, updated_at = CURRENT_TIMESTAMP", presenceXML)
 	_, err := q.RunWith(s.db).Exec()
 	return err
 }
@@ -124,7 +126,7 @@ func (s *Storage) InsertOrUpdateRosterNotification(rn *rostermodel.Notification)
 // FetchRosterNotifications retrieves from storage all roster notifications
 // associated to a given user.
 func (s *Storage) FetchRosterNotifications(contact string) ([]rostermodel.Notification, error) {
-	q := sq.Select("contact", "jid", "elements").
+	q := s.sq.Select("contact", "jid", "elements").
 		From("roster_notifications").
 		Where(sq.Eq{"contact": contact}).
 		OrderBy("created_at")
@@ -148,7 +150,7 @@ func (s *Storage) FetchRosterNotifications(contact string) ([]rostermodel.Notifi
 
 // FetchRosterNotification retrieves from storage a roster notification entity.
 func (s *Storage) FetchRosterNotification(contact string, jid string) (*rostermodel.Notification, error) {
-	q := sq.Select("contact", "jid", "elements").
+	q := s.sq.Select("contact", "jid", "elements").
 		From("roster_notifications").
 		Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"jid": jid}})
 
@@ -166,13 +168,13 @@ func (s *Storage) FetchRosterNotification(contact string, jid string) (*rostermo
 
 // DeleteRosterNotification deletes a roster notification entity from storage.
 func (s *Storage) DeleteRosterNotification(contact, jid string) error {
-	q := sq.Delete("roster_notifications").Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"jid": jid}})
+	q := s.sq.Delete("roster_notifications").Where(sq.And{sq.Eq{"contact": contact}, sq.Eq{"jid": jid}})
 	_, err := q.RunWith(s.db).Exec()
 	return err
 }
 
 func (s *Storage) fetchRosterVer(username string) (rostermodel.Version, error) {
-	q := sq.Select("IFNULL(MAX(ver), 0)", "IFNULL(MAX(last_deletion_ver), 0)").
+	q := s.sq.Select("COALESCE(MAX(ver), 0)", "COALESCE(MAX(last_deletion_ver), 0)").
 		From("roster_versions").
 		Where(sq.Eq{"username": username})
 
@@ -0,0 +1,540 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+// schema version 1: initial schema
+
+var (
+	mySQLSchemaV1 = []string{
+		`CREATE TABLE IF NOT EXISTS users (
+    username VARCHAR(256) PRIMARY KEY,
+    password TEXT NOT NULL,
+    last_presence TEXT NOT NULL,
+    last_presence_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS roster_notifications (
+    contact VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    elements TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (contact, jid),
+    INDEX i_roster_notifications_jid (jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS roster_items (
+    username VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    name TEXT NOT NULL,
+    subscription TEXT NOT NULL,
+    ` + "`groups`" + ` TEXT NOT NULL,
+    ask BOOL NOT NULL,
+    ver INT NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, jid),
+    INDEX i_roster_items_username (username),
+    INDEX i_roster_items_jid (jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS roster_versions (
+    username VARCHAR(256) NOT NULL,
+    ver INT NOT NULL DEFAULT 0,
+    last_deletion_ver INT NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS blocklist_items (
+    username VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, jid),
+    INDEX i_blocklist_items_username (username)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS private_storage (
+    username VARCHAR(256) NOT NULL,
+    namespace VARCHAR(512) NOT NULL,
+    data MEDIUMTEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, namespace),
+    INDEX i_private_storage_username (username)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS vcards (
+    username VARCHAR(256) PRIMARY KEY,
+    vcard MEDIUMTEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS offline_messages (
+    username VARCHAR(256) NOT NULL,
+    data MEDIUMTEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX i_offline_messages_username (username)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+	}
+	postgreSQLSchemaV1 = []string{
+		`CREATE TABLE IF NOT EXISTS users (
+    username VARCHAR(256) PRIMARY KEY,
+    password TEXT NOT NULL,
+    last_presence TEXT NOT NULL DEFAULT '',
+    last_presence_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS roster_notifications (
+    contact VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    elements TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (contact, jid)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_roster_notifications_jid ON roster_notifications(jid)`,
+		`CREATE TABLE IF NOT EXISTS roster_items (
+    username VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    name TEXT NOT NULL,
+    subscription TEXT NOT NULL,
+    "groups" TEXT NOT NULL,
+    ask BOOLEAN NOT NULL,
+    ver INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (username, jid)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_roster_items_username ON roster_items(username)`,
+		`CREATE INDEX IF NOT EXISTS i_roster_items_jid ON roster_items(jid)`,
+		`CREATE TABLE IF NOT EXISTS roster_versions (
+    username VARCHAR(256) NOT NULL,
+    ver INTEGER NOT NULL DEFAULT 0,
+    last_deletion_ver INTEGER NOT NULL DEFAULT 0,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (username)
+)`,
+		`CREATE TABLE IF NOT EXISTS blocklist_items (
+    username VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (username, jid)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_blocklist_items_username ON blocklist_items(username)`,
+		`CREATE TABLE IF NOT EXISTS private_storage (
+    username VARCHAR(256) NOT NULL,
+    namespace VARCHAR(512) NOT NULL,
+    data TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (username, namespace)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_private_storage_username ON private_storage(username)`,
+		`CREATE TABLE IF NOT EXISTS vcards (
+    username VARCHAR(256) PRIMARY KEY,
+    vcard TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS offline_messages (
+    username VARCHAR(256) NOT NULL,
+    data TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS i_offline_messages_username ON offline_messages(username)`,
+	}
+	sqliteSchemaV1 = []string{
+		`CREATE TABLE IF NOT EXISTS users (
+    username VARCHAR(256) PRIMARY KEY,
+    password TEXT NOT NULL,
+    last_presence TEXT NOT NULL DEFAULT '',
+    last_presence_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS roster_notifications (
+    contact VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    elements TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (contact, jid)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_roster_notifications_jid ON roster_notifications(jid)`,
+		`CREATE TABLE IF NOT EXISTS roster_items (
+    username VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    name TEXT NOT NULL,
+    subscription TEXT NOT NULL,
+    "groups" TEXT NOT NULL,
+    ask BOOL NOT NULL,
+    ver INT NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, jid)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_roster_items_username ON roster_items(username)`,
+		`CREATE INDEX IF NOT EXISTS i_roster_items_jid ON roster_items(jid)`,
+		`CREATE TABLE IF NOT EXISTS roster_versions (
+    username VARCHAR(256) NOT NULL,
+    ver INT NOT NULL DEFAULT 0,
+    last_deletion_ver INT NOT NULL DEFAULT 0,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username)
+)`,
+		`CREATE TABLE IF NOT EXISTS blocklist_items (
+    username VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, jid)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_blocklist_items_username ON blocklist_items(username)`,
+		`CREATE TABLE IF NOT EXISTS private_storage (
+    username VARCHAR(256) NOT NULL,
+    namespace VARCHAR(512) NOT NULL,
+    data TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (username, namespace)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_private_storage_username ON private_storage(username)`,
+		`CREATE TABLE IF NOT EXISTS vcards (
+    username VARCHAR(256) PRIMARY KEY,
+    vcard TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS offline_messages (
+    username VARCHAR(256) NOT NULL,
+    data TEXT NOT NULL,
+    created_at DATETIME NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS i_offline_messages_username ON offline_messages(username)`,
+	}
+)
+
+// schema version 2: user credentials
+
+var (
+	mySQLSchemaV2 = []string{
+		`ALTER TABLE users ADD COLUMN credentials TEXT NOT NULL AFTER password`,
+	}
+	postgreSQLSchemaV2 = []string{
+		`ALTER TABLE users ADD COLUMN credentials TEXT NOT NULL DEFAULT ''`,
+	}
+	sqliteSchemaV2 = []string{
+		`ALTER TABLE users ADD COLUMN credentials TEXT NOT NULL DEFAULT ''`,
+	}
+)
+
+// schema version 3: multi-user chat
+
+var (
+	mySQLSchemaV3 = []string{
+		`CREATE TABLE IF NOT EXISTS muc_rooms (
+    room_jid VARCHAR(256) PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    password VARCHAR(256) NOT NULL,
+    public BOOL NOT NULL,
+    members_only BOOL NOT NULL,
+    moderated BOOL NOT NULL,
+    non_anonymous BOOL NOT NULL,
+    max_occupants INT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS muc_affiliations (
+    room_jid VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (room_jid, jid),
+    INDEX i_muc_affiliations_room_jid (room_jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+	}
+	postgreSQLSchemaV3 = []string{
+		`CREATE TABLE IF NOT EXISTS muc_rooms (
+    room_jid VARCHAR(256) PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    password VARCHAR(256) NOT NULL,
+    public BOOLEAN NOT NULL,
+    members_only BOOLEAN NOT NULL,
+    moderated BOOLEAN NOT NULL,
+    non_anonymous BOOLEAN NOT NULL,
+    max_occupants INTEGER NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS muc_affiliations (
+    room_jid VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (room_jid, jid)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_muc_affiliations_room_jid ON muc_affiliations(room_jid)`,
+	}
+	sqliteSchemaV3 = []string{
+		`CREATE TABLE IF NOT EXISTS muc_rooms (
+    room_jid VARCHAR(256) PRIMARY KEY,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL,
+    subject TEXT NOT NULL,
+    password VARCHAR(256) NOT NULL,
+    public BOOL NOT NULL,
+    members_only BOOL NOT NULL,
+    moderated BOOL NOT NULL,
+    non_anonymous BOOL NOT NULL,
+    max_occupants INT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+)`,
+		`CREATE TABLE IF NOT EXISTS muc_affiliations (
+    room_jid VARCHAR(256) NOT NULL,
+    jid VARCHAR(512) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (room_jid, jid)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_muc_affiliations_room_jid ON muc_affiliations(room_jid)`,
+	}
+)
+
+// schema version 4: message archive
+
+var (
+	mySQLSchemaV4 = []string{
+		`CREATE TABLE IF NOT EXISTS archive_messages (
+    id VARCHAR(64) PRIMARY KEY,
+    username VARCHAR(256) NOT NULL,
+    with_jid VARCHAR(512) NOT NULL,
+    data MEDIUMTEXT NOT NULL,
+    stamp DATETIME(6) NOT NULL,
+    created_at DATETIME NOT NULL,
+    INDEX i_archive_messages_username_stamp (username, stamp)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS archive_prefs (
+    username VARCHAR(256) PRIMARY KEY,
+    default_mode VARCHAR(16) NOT NULL,
+    always TEXT NOT NULL,
+    never TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+	}
+	postgreSQLSchemaV4 = []string{
+		`CREATE TABLE IF NOT EXISTS archive_messages (
+    id VARCHAR(64) PRIMARY KEY,
+    username VARCHAR(256) NOT NULL,
+    with_jid VARCHAR(512) NOT NULL,
+    data TEXT NOT NULL,
+    stamp TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS i_archive_messages_username_stamp ON archive_messages(username, stamp)`,
+		`CREATE TABLE IF NOT EXISTS archive_prefs (
+    username VARCHAR(256) PRIMARY KEY,
+    default_mode VARCHAR(16) NOT NULL,
+    always TEXT NOT NULL,
+    never TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+)`,
+	}
+	sqliteSchemaV4 = []string{
+		`CREATE TABLE IF NOT EXISTS archive_messages (
+    id VARCHAR(64) PRIMARY KEY,
+    username VARCHAR(256) NOT NULL,
+    with_jid VARCHAR(512) NOT NULL,
+    data TEXT NOT NULL,
+    stamp DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+)`,
+		`CREATE INDEX IF NOT EXISTS i_archive_messages_username_stamp ON archive_messages(username, stamp)`,
+		`CREATE TABLE IF NOT EXISTS archive_prefs (
+    username VARCHAR(256) PRIMARY KEY,
+    default_mode VARCHAR(16) NOT NULL,
+    always TEXT NOT NULL,
+    never TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+)`,
+	}
+)
+
+// schema version 5: publish-subscribe
+
+var (
+	mySQLSchemaV5 = []string{
+		`CREATE TABLE IF NOT EXISTS pubsub_nodes (
+    host VARCHAR(256) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    title TEXT NOT NULL,
+    access_model VARCHAR(32) NOT NULL,
+    max_items INT NOT NULL,
+    persist_items BOOL NOT NULL,
+    deliver_payloads BOOL NOT NULL,
+    notify_retract BOOL NOT NULL,
+    notify_delete BOOL NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, name)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS pubsub_affiliations (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, node, jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS pubsub_subscriptions (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    subid VARCHAR(64) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    subscription VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, node, jid)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+		`CREATE TABLE IF NOT EXISTS pubsub_items (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    item_id VARCHAR(256) NOT NULL,
+    publisher VARCHAR(256) NOT NULL,
+    payload MEDIUMTEXT NOT NULL,
+    stamp DATETIME(6) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, node, item_id),
+    INDEX i_pubsub_items_host_node_stamp (host, node, stamp)
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+	}
+	postgreSQLSchemaV5 = []string{
+		`CREATE TABLE IF NOT EXISTS pubsub_nodes (
+    host VARCHAR(256) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    title TEXT NOT NULL,
+    access_model VARCHAR(32) NOT NULL,
+    max_items INTEGER NOT NULL,
+    persist_items BOOLEAN NOT NULL,
+    deliver_payloads BOOLEAN NOT NULL,
+    notify_retract BOOLEAN NOT NULL,
+    notify_delete BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, name)
+)`,
+		`CREATE TABLE IF NOT EXISTS pubsub_affiliations (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, node, jid)
+)`,
+		`CREATE TABLE IF NOT EXISTS pubsub_subscriptions (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    subid VARCHAR(64) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    subscription VARCHAR(32) NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, node, jid)
+)`,
+		`CREATE TABLE IF NOT EXISTS pubsub_items (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    item_id VARCHAR(256) NOT NULL,
+    publisher VARCHAR(256) NOT NULL,
+    payload TEXT NOT NULL,
+    stamp TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (host, node, item_id)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_pubsub_items_host_node_stamp ON pubsub_items(host, node, stamp)`,
+	}
+	sqliteSchemaV5 = []string{
+		`CREATE TABLE IF NOT EXISTS pubsub_nodes (
+    host VARCHAR(256) NOT NULL,
+    name VARCHAR(256) NOT NULL,
+    title TEXT NOT NULL,
+    access_model VARCHAR(32) NOT NULL,
+    max_items INT NOT NULL,
+    persist_items BOOL NOT NULL,
+    deliver_payloads BOOL NOT NULL,
+    notify_retract BOOL NOT NULL,
+    notify_delete BOOL NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, name)
+)`,
+		`CREATE TABLE IF NOT EXISTS pubsub_affiliations (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    affiliation VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, node, jid)
+)`,
+		`CREATE TABLE IF NOT EXISTS pubsub_subscriptions (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    subid VARCHAR(64) NOT NULL,
+    jid VARCHAR(256) NOT NULL,
+    subscription VARCHAR(32) NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, node, jid)
+)`,
+		`CREATE TABLE IF NOT EXISTS pubsub_items (
+    host VARCHAR(256) NOT NULL,
+    node VARCHAR(256) NOT NULL,
+    item_id VARCHAR(256) NOT NULL,
+    publisher VARCHAR(256) NOT NULL,
+    payload TEXT NOT NULL,
+    stamp DATETIME NOT NULL,
+    created_at DATETIME NOT NULL,
+    PRIMARY KEY (host, node, item_id)
+)`,
+		`CREATE INDEX IF NOT EXISTS i_pubsub_items_host_node_stamp ON pubsub_items(host, node, stamp)`,
+	}
+)
+
+// schema version 6: entity capabilities
+
+var (
+	mySQLSchemaV6 = []string{
+		`CREATE TABLE IF NOT EXISTS capabilities (
+    ver VARCHAR(256) PRIMARY KEY,
+    node VARCHAR(512) NOT NULL,
+    features TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+) ENGINE=InnoDB CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci`,
+	}
+	postgreSQLSchemaV6 = []string{
+		`CREATE TABLE IF NOT EXISTS capabilities (
+    ver VARCHAR(256) PRIMARY KEY,
+    node VARCHAR(512) NOT NULL,
+    features TEXT NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL
+)`,
+	}
+	sqliteSchemaV6 = []string{
+		`CREATE TABLE IF NOT EXISTS capabilities (
+    ver VARCHAR(256) PRIMARY KEY,
+    node VARCHAR(512) NOT NULL,
+    features TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    created_at DATETIME NOT NULL
+)`,
+	}
+)
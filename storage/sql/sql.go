@@ -8,17 +8,26 @@ package sql
 import (
 	"database/sql"
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/DATA-DOG/go-sqlmock"
 	sq "github.com/Masterminds/squirrel"
 	_ "github.com/go-sql-driver/mysql" // SQL driver
+	_ "github.com/lib/pq"              // SQL driver
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/pool"
 )
 
+const (
+	mySQLDriver      = "mysql"
+	postgreSQLDriver = "postgres"
+	sqliteDriver     = "sqlite3"
+)
+
 var (
-	nowExpr = sq.Expr("NOW()")
+	nowExpr = sq.Expr("CURRENT_TIMESTAMP")
 )
 
 type rowScanner interface {
@@ -37,30 +46,51 @@ type Config struct {
 	Password string `yaml:"password"`
 	Database string `yaml:"database"`
 	PoolSize int    `yaml:"pool_size"`
+	SSLMode  string `yaml:"ssl_mode"` // only used by PostgreSQL
+
+	// ManualMigrations disables automatic schema migrations at startup,
+	// requiring them to be applied through 'jackal migrate' command.
+	ManualMigrations bool `yaml:"manual_migrations"`
 }
 
 // Storage represents a SQL storage sub system.
 type Storage struct {
+	driver string
+	sq     sq.StatementBuilderType
 	db     *sql.DB
 	pool   *pool.BufferPool
 	doneCh chan chan bool
 }
 
-// New returns a SQL storage instance.
+// New returns a MySQL storage instance.
 func New(cfg *Config) *Storage {
+	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", cfg.User, cfg.Password, cfg.Host, cfg.Database)
+	return newStorage(mySQLDriver, dsn, cfg.PoolSize, cfg.ManualMigrations)
+}
+
+// NewPostgreSQL returns a PostgreSQL storage instance.
+func NewPostgreSQL(cfg *Config) *Storage {
+	dsn := url.URL{
+		Scheme: "postgres",
+		User:   url.UserPassword(cfg.User, cfg.Password),
+		Host:   cfg.Host,
+		Path:   cfg.Database,
+	}
+	if len(cfg.SSLMode) > 0 {
+		dsn.RawQuery = url.Values{"sslmode": []string{cfg.SSLMode}}.Encode()
+	}
+	return newStorage(postgreSQLDriver, dsn.String(), cfg.PoolSize, cfg.ManualMigrations)
+}
+
+func newStorage(driver, dsn string, poolSize int, manualMigrations bool) *Storage {
 	var err error
 	s := &Storage{
+		driver: driver,
+		sq:     statementBuilder(driver),
 		pool:   pool.NewBufferPool(),
 		doneCh: make(chan chan bool),
 	}
-	host := cfg.Host
-	user := cfg.User
-	pass := cfg.Password
-	db := cfg.Database
-	poolSize := cfg.PoolSize
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s)/%s?parseTime=true", user, pass, host, db)
-	s.db, err = sql.Open("mysql", dsn)
+	s.db, err = sql.Open(driver, dsn)
 	if err != nil {
 		log.Fatalf("%v", err)
 	}
@@ -69,17 +99,36 @@ func New(cfg *Config) *Storage {
 	if err := s.db.Ping(); err != nil {
 		log.Fatalf("%v", err)
 	}
+	if err := s.initSchema(manualMigrations); err != nil {
+		log.Fatalf("%v", err)
+	}
 	go s.loop()
 
 	return s
 }
 
-// NewMock returns a mocked SQL storage instance.
+// NewMock returns a mocked MySQL storage instance.
 func NewMock() (*Storage, sqlmock.Sqlmock) {
+	return newMock(mySQLDriver)
+}
+
+// NewPostgreSQLMock returns a mocked PostgreSQL storage instance.
+func NewPostgreSQLMock() (*Storage, sqlmock.Sqlmock) {
+	return newMock(postgreSQLDriver)
+}
+
+// NewSQLiteMock returns a mocked SQLite storage instance.
+func NewSQLiteMock() (*Storage, sqlmock.Sqlmock) {
+	return newMock(sqliteDriver)
+}
+
+func newMock(driver string) (*Storage, sqlmock.Sqlmock) {
 	var err error
 	var sqlMock sqlmock.Sqlmock
 	s := &Storage{
-		pool: pool.NewBufferPool(),
+		driver: driver,
+		sq:     statementBuilder(driver),
+		pool:   pool.NewBufferPool(),
 	}
 	s.db, sqlMock, err = sqlmock.New()
 	if err != nil {
@@ -125,3 +174,36 @@ func (s *Storage) inTransaction(f func(tx *sql.Tx) error) error {
 	tx.Commit()
 	return nil
 }
+
+// onConflictUpdate returns the upsert clause prefix for the underlying driver.
+// keys must enumerate the table unique key columns.
+func (s *Storage) onConflictUpdate(keys ...string) string {
+	if s.driver != mySQLDriver {
+		return fmt.Sprintf("ON CONFLICT (%s) DO UPDATE SET", strings.Join(keys, ", "))
+	}
+	return "ON DUPLICATE KEY UPDATE"
+}
+
+// insertIgnore returns an insert builder that silently skips
+// rows violating a table unique key.
+func (s *Storage) insertIgnore(table string) sq.InsertBuilder {
+	if s.driver != mySQLDriver {
+		return s.sq.Insert(table).Suffix("ON CONFLICT DO NOTHING")
+	}
+	return s.sq.Insert(table).Options("IGNORE")
+}
+
+// quote quotes an SQL identifier using driver's quoting style.
+func (s *Storage) quote(ident string) string {
+	if s.driver != mySQLDriver {
+		return `"` + ident + `"`
+	}
+	return "`" + ident + "`"
+}
+
+func statementBuilder(driver string) sq.StatementBuilderType {
+	if driver == postgreSQLDriver {
+		return sq.StatementBuilder.PlaceholderFormat(sq.Dollar)
+	}
+	return sq.StatementBuilder
+}
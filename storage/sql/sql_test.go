@@ -7,8 +7,39 @@ package sql
 
 import (
 	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
 )
 
 var (
 	errMySQLStorage = errors.New("MySQL storage error")
 )
+
+// testDialect describes how a mocked SQL dialect renders
+// the statements whose syntax is driver specific.
+type testDialect struct {
+	name    string
+	newMock func() (*Storage, sqlmock.Sqlmock)
+	upsert  string
+}
+
+var testDialects = []testDialect{
+	{name: "MySQL", newMock: NewMock, upsert: "ON DUPLICATE KEY UPDATE"},
+	{name: "PostgreSQL", newMock: NewPostgreSQLMock, upsert: `ON CONFLICT \((.+)\) DO UPDATE SET`},
+	{name: "SQLite", newMock: NewSQLiteMock, upsert: `ON CONFLICT \((.+)\) DO UPDATE SET`},
+}
+
+func (d testDialect) insertIgnore(table string) string {
+	if d.name == "MySQL" {
+		return fmt.Sprintf("INSERT IGNORE INTO %s (.+)", table)
+	}
+	return fmt.Sprintf("INSERT INTO %s (.+) ON CONFLICT DO NOTHING", table)
+}
+
+func tUtilForEachDialect(t *testing.T, f func(t *testing.T, d testDialect)) {
+	for _, d := range testDialects {
+		t.Run(d.name, func(t *testing.T) { f(t, d) })
+	}
+}
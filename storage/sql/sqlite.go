@@ -0,0 +1,19 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+// SQLiteConfig represents SQLite storage configuration.
+type SQLiteConfig struct {
+	Path string `yaml:"path"`
+}
+
+// NewSQLite returns a SQLite storage instance, creating or
+// migrating database schema in case it's not up to date.
+func NewSQLite(cfg *SQLiteConfig) *Storage {
+	// SQLite allows a single writer at a time, so stick to one connection
+	// to avoid 'database is locked' errors.
+	return newStorage(sqliteDriver, cfg.Path+"?_busy_timeout=5000", 1, false)
+}
@@ -0,0 +1,14 @@
+//go:build sqlite
+// +build sqlite
+
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import _ "github.com/mattn/go-sqlite3" // SQL driver
+
+// SQLiteSupported tells whether or not jackal has been built with SQLite support.
+const SQLiteSupported = true
@@ -0,0 +1,13 @@
+//go:build !sqlite
+// +build !sqlite
+
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+// SQLiteSupported tells whether or not jackal has been built with SQLite support.
+// SQLite driver requires cgo, so it's only included when building with 'sqlite' tag.
+const SQLiteSupported = false
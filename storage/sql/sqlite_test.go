@@ -0,0 +1,126 @@
+//go:build sqlite
+// +build sqlite
+
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package sql
+
+import (
+	"database/sql"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/pborman/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSQLiteStorageMigrations(t *testing.T) {
+	h := tUtilSQLiteSetup()
+	defer tUtilSQLiteTeardown(h)
+
+	ver, err := h.db.SchemaVersion()
+	require.Nil(t, err)
+	require.Equal(t, LatestSchemaVersion(), ver)
+
+	// already up to date
+	ver, err = h.db.Migrate()
+	require.Nil(t, err)
+	require.Equal(t, LatestSchemaVersion(), ver)
+	require.Nil(t, h.db.initSchema(false))
+
+	// database newer than binary
+	_, err = h.db.db.Exec("INSERT INTO schema_migrations (version, description, applied_at) VALUES (?, ?, CURRENT_TIMESTAMP)", LatestSchemaVersion()+1, "future")
+	require.Nil(t, err)
+	_, err = h.db.Migrate()
+	require.Equal(t, ErrSchemaTooNew, err)
+	require.NotNil(t, h.db.initSchema(false))
+}
+
+func TestSQLiteStorageUpgradeInitialSchema(t *testing.T) {
+	dataDir, _ := ioutil.TempDir("", "com.jackal.tests.sqlite."+uuid.New())
+	defer os.RemoveAll(dataDir)
+	path := filepath.Join(dataDir, "jackal.db")
+
+	// unversioned database created from initial schema
+	db, err := sql.Open(sqliteDriver, path)
+	require.Nil(t, err)
+	for _, stmt := range sqliteSchemaV1 {
+		_, err := db.Exec(stmt)
+		require.Nil(t, err)
+	}
+	_, err = db.Exec("INSERT INTO users (username, password, updated_at, created_at) VALUES ('ortuman', '1234', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP)")
+	require.Nil(t, err)
+	db.Close()
+
+	s := NewSQLite(&SQLiteConfig{Path: path})
+	defer s.Shutdown()
+
+	ver, err := s.SchemaVersion()
+	require.Nil(t, err)
+	require.Equal(t, LatestSchemaVersion(), ver)
+
+	usr, err := s.FetchUser("ortuman")
+	require.Nil(t, err)
+	require.NotNil(t, usr)
+	require.Equal(t, "1234", usr.Password)
+	require.Equal(t, 0, len(usr.Credentials))
+}
+
+type testSQLiteHelper struct {
+	db      *Storage
+	dataDir string
+}
+
+func tUtilSQLiteSetup() *testSQLiteHelper {
+	h := &testSQLiteHelper{}
+	h.dataDir, _ = ioutil.TempDir("", "com.jackal.tests.sqlite."+uuid.New())
+	h.db = NewSQLite(&SQLiteConfig{Path: filepath.Join(h.dataDir, "jackal.db")})
+	return h
+}
+
+func tUtilSQLiteTeardown(h *testSQLiteHelper) {
+	h.db.Shutdown()
+	os.RemoveAll(h.dataDir)
+}
+
+func TestSQLiteStorageUser(t *testing.T) {
+	h := tUtilSQLiteSetup()
+	defer tUtilSQLiteTeardown(h)
+
+	usr := model.User{Username: "ortuman", Password: "1234"}
+	usr.Credentials = []model.Credential{{Hash: "sha-1", Salt: []byte{1}, Iterations: 4096, StoredKey: []byte{2}, ServerKey: []byte{3}}}
+
+	err := h.db.InsertOrUpdateUser(&usr)
+	require.Nil(t, err)
+
+	usr2, err := h.db.FetchUser("ortuman")
+	require.Nil(t, err)
+	require.Equal(t, "ortuman", usr2.Username)
+	require.Equal(t, "1234", usr2.Password)
+	require.Equal(t, usr.Credentials, usr2.Credentials)
+
+	exists, err := h.db.UserExists("ortuman")
+	require.Nil(t, err)
+	require.True(t, exists)
+
+	usernames, err := h.db.FetchUsernames()
+	require.Nil(t, err)
+	require.Equal(t, []string{"ortuman"}, usernames)
+
+	usr3, err := h.db.FetchUser("ortuman2")
+	require.Nil(t, usr3)
+	require.Nil(t, err)
+
+	err = h.db.DeleteUser("ortuman")
+	require.Nil(t, err)
+
+	exists, err = h.db.UserExists("ortuman")
+	require.Nil(t, err)
+	require.False(t, exists)
+}
@@ -7,6 +7,9 @@ package sql
 
 import (
 	"database/sql"
+	"encoding/base64"
+	"fmt"
+	"strconv"
 	"strings"
 	"time"
 
@@ -26,8 +29,10 @@ func (s *Storage) InsertOrUpdateUser(u *model.User) error {
 		presenceXML = buf.String()
 		s.pool.Put(buf)
 	}
-	columns := []string{"username", "password", "updated_at", "created_at"}
-	values := []interface{}{u.Username, u.Password, nowExpr, nowExpr}
+	credentials := encodeCredentials(u.Credentials)
+
+	columns := []string{"username", "password", "credentials", "updated_at", "created_at"}
+	values := []interface{}{u.Username, u.Password, credentials, nowExpr, nowExpr}
 
 	if len(presenceXML) > 0 {
 		columns = append(columns, []string{"last_presence", "last_presence_at"}...)
@@ -36,13 +41,13 @@ func (s *Storage) InsertOrUpdateUser(u *model.User) error {
 	var suffix string
 	var suffixArgs []interface{}
 	if len(presenceXML) > 0 {
-		suffix = "ON DUPLICATE KEY UPDATE password = ?, last_presence = ?, last_presence_at = NOW(), updated_at = NOW()"
-		suffixArgs = []interface{}{u.Password, presenceXML}
+		suffix = s.onConflictUpdate("username") + " password = ?, credentials = ?, last_presence = ?, last_presence_at = CURRENT_TIMESTAMP, updated_at = CURRENT_TIMESTAMP"
+		suffixArgs = []interface{}{u.Password, credentials, presenceXML}
 	} else {
-		suffix = "ON DUPLICATE KEY UPDATE password = ?, updated_at = NOW()"
-		suffixArgs = []interface{}{u.Password}
+		suffix = s.onConflictUpdate("username") + " password = ?, credentials = ?, updated_at = CURRENT_TIMESTAMP"
+		suffixArgs = []interface{}{u.Password, credentials}
 	}
-	q := sq.Insert("users").
+	q := s.sq.Insert("users").
 		Columns(columns...).
 		Values(values...).
 		Suffix(suffix, suffixArgs...)
@@ -52,17 +57,20 @@ func (s *Storage) InsertOrUpdateUser(u *model.User) error {
 
 // FetchUser retrieves from storage a user entity.
 func (s *Storage) FetchUser(username string) (*model.User, error) {
-	q := sq.Select("username", "password", "last_presence", "last_presence_at").
+	q := s.sq.Select("username", "password", "credentials", "last_presence", "last_presence_at").
 		From("users").
 		Where(sq.Eq{"username": username})
 
-	var presenceXML string
+	var credentials, presenceXML string
 	var presenceAt time.Time
 	var usr model.User
 
-	err := q.RunWith(s.db).QueryRow().Scan(&usr.Username, &usr.Password, &presenceXML, &presenceAt)
+	err := q.RunWith(s.db).QueryRow().Scan(&usr.Username, &usr.Password, &credentials, &presenceXML, &presenceAt)
 	switch err {
 	case nil:
+		if usr.Credentials, err = decodeCredentials(credentials); err != nil {
+			return nil, err
+		}
 		if len(presenceXML) > 0 {
 			parser := xmpp.NewParser(strings.NewReader(presenceXML), xmpp.DefaultMode, 0)
 			if lastPresence, err := parser.ParseElement(); err != nil {
@@ -86,27 +94,27 @@ func (s *Storage) FetchUser(username string) (*model.User, error) {
 func (s *Storage) DeleteUser(username string) error {
 	return s.inTransaction(func(tx *sql.Tx) error {
 		var err error
-		_, err = sq.Delete("offline_messages").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = s.sq.Delete("offline_messages").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
-		_, err = sq.Delete("roster_items").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = s.sq.Delete("roster_items").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
-		_, err = sq.Delete("roster_versions").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = s.sq.Delete("roster_versions").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
-		_, err = sq.Delete("private_storage").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = s.sq.Delete("private_storage").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
-		_, err = sq.Delete("vcards").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = s.sq.Delete("vcards").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
-		_, err = sq.Delete("users").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
+		_, err = s.sq.Delete("users").Where(sq.Eq{"username": username}).RunWith(tx).Exec()
 		if err != nil {
 			return err
 		}
@@ -116,7 +124,7 @@ func (s *Storage) DeleteUser(username string) error {
 
 // UserExists returns whether or not a user exists within storage.
 func (s *Storage) UserExists(username string) (bool, error) {
-	q := sq.Select("COUNT(*)").From("users").Where(sq.Eq{"username": username})
+	q := s.sq.Select("COUNT(*)").From("users").Where(sq.Eq{"username": username})
 	var count int
 	err := q.RunWith(s.db).QueryRow().Scan(&count)
 	switch err {
@@ -126,3 +134,70 @@ func (s *Storage) UserExists(username string) (bool, error) {
 		return false, err
 	}
 }
+
+// FetchUsernames retrieves from storage the username of every user entity.
+func (s *Storage) FetchUsernames() ([]string, error) {
+	q := s.sq.Select("username").
+		From("users").
+		OrderBy("username")
+
+	rows, err := q.RunWith(s.db).Query()
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ret []string
+	for rows.Next() {
+		var username string
+		if err := rows.Scan(&username); err != nil {
+			return nil, err
+		}
+		ret = append(ret, username)
+	}
+	return ret, rows.Err()
+}
+
+// encodeCredentials serializes user credentials as a ';' separated list
+// of 'hash:iterations:salt:stored_key:server_key' base64 encoded tuples.
+func encodeCredentials(credentials []model.Credential) string {
+	var ss []string
+	for _, c := range credentials {
+		ss = append(ss, fmt.Sprintf("%s:%d:%s:%s:%s", c.Hash, c.Iterations,
+			base64.StdEncoding.EncodeToString(c.Salt),
+			base64.StdEncoding.EncodeToString(c.StoredKey),
+			base64.StdEncoding.EncodeToString(c.ServerKey),
+		))
+	}
+	return strings.Join(ss, ";")
+}
+
+func decodeCredentials(s string) ([]model.Credential, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	var credentials []model.Credential
+	for _, cs := range strings.Split(s, ";") {
+		fields := strings.Split(cs, ":")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("sql: malformed user credential: %s", cs)
+		}
+		var c model.Credential
+		var err error
+		c.Hash = fields[0]
+		if c.Iterations, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, err
+		}
+		if c.Salt, err = base64.StdEncoding.DecodeString(fields[2]); err != nil {
+			return nil, err
+		}
+		if c.StoredKey, err = base64.StdEncoding.DecodeString(fields[3]); err != nil {
+			return nil, err
+		}
+		if c.ServerKey, err = base64.StdEncoding.DecodeString(fields[4]); err != nil {
+			return nil, err
+		}
+		credentials = append(credentials, c)
+	}
+	return credentials, nil
+}
@@ -16,113 +16,175 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestMySQLStorageInsertUser(t *testing.T) {
-	from, _ := jid.NewWithString("ortuman@jackal.im/Psi+", true)
-	to, _ := jid.NewWithString("ortuman@jackal.im", true)
-	p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
+func TestStorageInsertUser(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		from, _ := jid.NewWithString("ortuman@jackal.im/Psi+", true)
+		to, _ := jid.NewWithString("ortuman@jackal.im", true)
+		p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
+
+		user := model.User{Username: "ortuman", Password: "1234", LastPresence: p}
+		user.Credentials = []model.Credential{{Hash: "sha-1", Salt: []byte{1}, Iterations: 4096, StoredKey: []byte{2}, ServerKey: []byte{3}}}
+		credentials := "sha-1:4096:AQ==:Ag==:Aw=="
+
+		s, mock := d.newMock()
+		mock.ExpectExec("INSERT INTO users (.+) "+d.upsert+" (.+)").
+			WithArgs("ortuman", "1234", credentials, p.String(), "1234", credentials, p.String()).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		err := s.InsertOrUpdateUser(&user)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectExec("INSERT INTO users (.+) "+d.upsert+" (.+)").
+			WithArgs("ortuman", "1234", credentials, p.String(), "1234", credentials, p.String()).
+			WillReturnError(errMySQLStorage)
+		err = s.InsertOrUpdateUser(&user)
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
 
-	user := model.User{Username: "ortuman", Password: "1234", LastPresence: p}
+func TestPostgreSQLStorageInsertUser(t *testing.T) {
+	user := model.User{Username: "ortuman", Password: "1234"}
 
-	s, mock := NewMock()
-	mock.ExpectExec("INSERT INTO users (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", "1234", p.String(), "1234", p.String()).
+	s, mock := NewPostgreSQLMock()
+	mock.ExpectExec(`INSERT INTO users \(username,password,credentials,updated_at,created_at\) VALUES \(\$1,\$2,\$3,CURRENT_TIMESTAMP,CURRENT_TIMESTAMP\) ON CONFLICT \(username\) DO UPDATE SET password = \$4, credentials = \$5, updated_at = CURRENT_TIMESTAMP`).
+		WithArgs("ortuman", "1234", "", "1234", "").
 		WillReturnResult(sqlmock.NewResult(1, 1))
 
 	err := s.InsertOrUpdateUser(&user)
 	require.Nil(t, mock.ExpectationsWereMet())
 	require.Nil(t, err)
-
-	s, mock = NewMock()
-	mock.ExpectExec("INSERT INTO users (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", "1234", p.String(), "1234", p.String()).
-		WillReturnError(errMySQLStorage)
-	err = s.InsertOrUpdateUser(&user)
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Equal(t, errMySQLStorage, err)
 }
 
-func TestMySQLStorageDeleteUser(t *testing.T) {
-	s, mock := NewMock()
-	mock.ExpectBegin()
-	mock.ExpectExec("DELETE FROM offline_messages (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM roster_items (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM roster_versions (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM private_storage (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM vcards (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectExec("DELETE FROM users (.+)").
-		WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
-	mock.ExpectCommit()
-
-	err := s.DeleteUser("ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, err)
-
-	s, mock = NewMock()
-	mock.ExpectBegin()
-	mock.ExpectExec("DELETE FROM offline_messages (.+)").
-		WithArgs("ortuman").WillReturnError(errMySQLStorage)
-	mock.ExpectRollback()
-
-	err = s.DeleteUser("ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Equal(t, errMySQLStorage, err)
+func TestStorageDeleteUser(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM offline_messages (.+)").
+			WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM roster_items (.+)").
+			WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM roster_versions (.+)").
+			WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM private_storage (.+)").
+			WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM vcards (.+)").
+			WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectExec("DELETE FROM users (.+)").
+			WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		err := s.DeleteUser("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectBegin()
+		mock.ExpectExec("DELETE FROM offline_messages (.+)").
+			WithArgs("ortuman").WillReturnError(errMySQLStorage)
+		mock.ExpectRollback()
+
+		err = s.DeleteUser("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
 }
 
-func TestMySQLStorageFetchUser(t *testing.T) {
-	from, _ := jid.NewWithString("ortuman@jackal.im/Psi+", true)
-	to, _ := jid.NewWithString("ortuman@jackal.im", true)
-	p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
-
-	var userColumns = []string{"username", "password", "last_presence", "last_presence_at"}
-
-	s, mock := NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
-		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(userColumns))
-
-	usr, err := s.FetchUser("ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, usr)
-
-	s, mock = NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
-		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "1234", p.String(), time.Now()))
-	_, err = s.FetchUser("ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, err)
-
-	s, mock = NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM users (.+)").
-		WithArgs("ortuman").WillReturnError(errMySQLStorage)
-	_, err = s.FetchUser("ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Equal(t, errMySQLStorage, err)
+func TestStorageFetchUser(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		from, _ := jid.NewWithString("ortuman@jackal.im/Psi+", true)
+		to, _ := jid.NewWithString("ortuman@jackal.im", true)
+		p := xmpp.NewPresence(from, to, xmpp.UnavailableType)
+
+		var userColumns = []string{"username", "password", "credentials", "last_presence", "last_presence_at"}
+
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(userColumns))
+
+		usr, err := s.FetchUser("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, usr)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "1234", "", p.String(), time.Now()))
+		_, err = s.FetchUser("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "", "sha-1:4096:AQ==:Ag==:Aw==;sha-256:4096:BA==:BQ==:Bg==", "", time.Now()))
+		usr, err = s.FetchUser("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, 2, len(usr.Credentials))
+		require.Equal(t, []byte{5}, usr.Credential("sha-256").StoredKey)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(userColumns).AddRow("ortuman", "", "sha-1:4096", "", time.Now()))
+		_, err = s.FetchUser("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.NotNil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM users (.+)").
+			WithArgs("ortuman").WillReturnError(errMySQLStorage)
+		_, err = s.FetchUser("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
 }
 
-func TestMySQLStorageUserExists(t *testing.T) {
-	countColums := []string{"count"}
-
-	s, mock := NewMock()
-	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
-		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(countColums).AddRow(1))
-
-	ok, err := s.UserExists("ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, err)
-	require.True(t, ok)
+func TestStorageUserExists(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		countColums := []string{"count"}
+
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(countColums).AddRow(1))
+
+		ok, err := s.UserExists("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.True(t, ok)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
+			WithArgs("romeo").
+			WillReturnError(errMySQLStorage)
+		_, err = s.UserExists("romeo")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
+}
 
-	s, mock = NewMock()
-	mock.ExpectQuery("SELECT COUNT(.+) FROM users (.+)").
-		WithArgs("romeo").
-		WillReturnError(errMySQLStorage)
-	_, err = s.UserExists("romeo")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Equal(t, errMySQLStorage, err)
+func TestStorageFetchUsernames(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT username FROM users ORDER BY username").
+			WillReturnRows(sqlmock.NewRows([]string{"username"}).AddRow("juliet").AddRow("romeo"))
+
+		usernames, err := s.FetchUsernames()
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Equal(t, []string{"juliet", "romeo"}, usernames)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT username FROM users ORDER BY username").
+			WillReturnError(errMySQLStorage)
+
+		_, err = s.FetchUsernames()
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
 }
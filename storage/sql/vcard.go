@@ -17,10 +17,10 @@ import (
 // or updates it in case it's been previously inserted.
 func (s *Storage) InsertOrUpdateVCard(vCard xmpp.XElement, username string) error {
 	rawXML := vCard.String()
-	q := sq.Insert("vcards").
+	q := s.sq.Insert("vcards").
 		Columns("username", "vcard", "updated_at", "created_at").
 		Values(username, rawXML, nowExpr, nowExpr).
-		Suffix("ON DUPLICATE KEY UPDATE vcard = ?, updated_at = NOW()", rawXML)
+		Suffix(s.onConflictUpdate("username")+" vcard = ?, updated_at = CURRENT_TIMESTAMP", rawXML)
 
 	_, err := q.RunWith(s.db).Exec()
 	return err
@@ -29,7 +29,7 @@ func (s *Storage) InsertOrUpdateVCard(vCard xmpp.XElement, username string) erro
 // FetchVCard retrieves from storage a vCard element associated
 // to a given user.
 func (s *Storage) FetchVCard(username string) (xmpp.XElement, error) {
-	q := sq.Select("vcard").From("vcards").Where(sq.Eq{"username": username})
+	q := s.sq.Select("vcard").From("vcards").Where(sq.Eq{"username": username})
 
 	var vCard string
 	err := q.RunWith(s.db).QueryRow().Scan(&vCard)
@@ -43,3 +43,11 @@ func (s *Storage) FetchVCard(username string) (xmpp.XElement, error) {
 		return nil, err
 	}
 }
+
+// DeleteVCard deletes from storage a vCard element associated
+// to a given user.
+func (s *Storage) DeleteVCard(username string) error {
+	q := s.sq.Delete("vcards").Where(sq.Eq{"username": username})
+	_, err := q.RunWith(s.db).Exec()
+	return err
+}
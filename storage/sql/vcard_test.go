@@ -13,59 +13,83 @@ import (
 	"github.com/stretchr/testify/require"
 )
 
-func TestMySQLStorageInsertVCard(t *testing.T) {
-	vCard := xmpp.NewElementName("vCard")
-	rawXML := vCard.String()
-
-	s, mock := NewMock()
-	mock.ExpectExec("INSERT INTO vcards (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", rawXML, rawXML).
-		WillReturnResult(sqlmock.NewResult(1, 1))
-
-	err := s.InsertOrUpdateVCard(vCard, "ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, err)
-	require.NotNil(t, vCard)
-
-	s, mock = NewMock()
-	mock.ExpectExec("INSERT INTO vcards (.+) ON DUPLICATE KEY UPDATE (.+)").
-		WithArgs("ortuman", rawXML, rawXML).
-		WillReturnError(errMySQLStorage)
-
-	err = s.InsertOrUpdateVCard(vCard, "ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Equal(t, errMySQLStorage, err)
+func TestStorageInsertVCard(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		vCard := xmpp.NewElementName("vCard")
+		rawXML := vCard.String()
+
+		s, mock := d.newMock()
+		mock.ExpectExec("INSERT INTO vcards (.+) "+d.upsert+" (.+)").
+			WithArgs("ortuman", rawXML, rawXML).
+			WillReturnResult(sqlmock.NewResult(1, 1))
+
+		err := s.InsertOrUpdateVCard(vCard, "ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.NotNil(t, vCard)
+
+		s, mock = d.newMock()
+		mock.ExpectExec("INSERT INTO vcards (.+) "+d.upsert+" (.+)").
+			WithArgs("ortuman", rawXML, rawXML).
+			WillReturnError(errMySQLStorage)
+
+		err = s.InsertOrUpdateVCard(vCard, "ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
 }
 
-func TestMySQLStorageFetchVCard(t *testing.T) {
-	var vCardColumns = []string{"vcard"}
-
-	s, mock := NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
-		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(vCardColumns).AddRow("<vCard><FN>Miguel Ángel</FN></vCard>"))
-
-	vCard, err := s.FetchVCard("ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, err)
-	require.NotNil(t, vCard)
-
-	s, mock = NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
-		WithArgs("ortuman").
-		WillReturnRows(sqlmock.NewRows(vCardColumns))
-
-	vCard, err = s.FetchVCard("ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, err)
-	require.Nil(t, vCard)
-
-	s, mock = NewMock()
-	mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
-		WithArgs("ortuman").
-		WillReturnError(errMySQLStorage)
-
-	vCard, _ = s.FetchVCard("ortuman")
-	require.Nil(t, mock.ExpectationsWereMet())
-	require.Nil(t, vCard)
+func TestStorageFetchVCard(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		var vCardColumns = []string{"vcard"}
+
+		s, mock := d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(vCardColumns).AddRow("<vCard><FN>Miguel Ángel</FN></vCard>"))
+
+		vCard, err := s.FetchVCard("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.NotNil(t, vCard)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
+			WithArgs("ortuman").
+			WillReturnRows(sqlmock.NewRows(vCardColumns))
+
+		vCard, err = s.FetchVCard("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+		require.Nil(t, vCard)
+
+		s, mock = d.newMock()
+		mock.ExpectQuery("SELECT (.+) FROM vcards (.+)").
+			WithArgs("ortuman").
+			WillReturnError(errMySQLStorage)
+
+		vCard, _ = s.FetchVCard("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, vCard)
+	})
+}
+
+func TestStorageDeleteVCard(t *testing.T) {
+	tUtilForEachDialect(t, func(t *testing.T, d testDialect) {
+		s, mock := d.newMock()
+		mock.ExpectExec("DELETE FROM vcards (.+)").
+			WithArgs("ortuman").WillReturnResult(sqlmock.NewResult(0, 1))
+
+		err := s.DeleteVCard("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Nil(t, err)
+
+		s, mock = d.newMock()
+		mock.ExpectExec("DELETE FROM vcards (.+)").
+			WithArgs("ortuman").WillReturnError(errMySQLStorage)
+
+		err = s.DeleteVCard("ortuman")
+		require.Nil(t, mock.ExpectationsWereMet())
+		require.Equal(t, errMySQLStorage, err)
+	})
 }
@@ -6,10 +6,16 @@
 package storage
 
 import (
+	"errors"
 	"sync"
 
+	"github.com/ortuman/jackal/host"
 	"github.com/ortuman/jackal/log"
 	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/capsmodel"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/mucmodel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
 	"github.com/ortuman/jackal/model/rostermodel"
 	"github.com/ortuman/jackal/storage/badgerdb"
 	"github.com/ortuman/jackal/storage/memstorage"
@@ -30,6 +36,9 @@ type userStorage interface {
 
 	// UserExists returns whether or not a user exists within storage.
 	UserExists(username string) (bool, error)
+
+	// FetchUsernames retrieves from storage the username of every user entity.
+	FetchUsernames() ([]string, error)
 }
 
 type rosterStorage interface {
@@ -85,6 +94,10 @@ type vCardStorage interface {
 	// FetchVCard retrieves from storage a vCard element associated
 	// to a given user.
 	FetchVCard(username string) (xmpp.XElement, error)
+
+	// DeleteVCard deletes from storage a vCard element associated
+	// to a given user.
+	DeleteVCard(username string) error
 }
 
 type privateStorage interface {
@@ -94,6 +107,14 @@ type privateStorage interface {
 	// InsertOrUpdatePrivateXML inserts a new private element into storage,
 	// or updates it in case it's been previously inserted.
 	InsertOrUpdatePrivateXML(privateXML []xmpp.XElement, namespace string, username string) error
+
+	// FetchPrivateXMLNamespaces retrieves from storage all private element
+	// namespaces associated to a given user.
+	FetchPrivateXMLNamespaces(username string) ([]string, error)
+
+	// DeletePrivateXML deletes from storage all private elements
+	// associated to a given user.
+	DeletePrivateXML(username string) error
 }
 
 type blockListStorage interface {
@@ -109,6 +130,80 @@ type blockListStorage interface {
 	FetchBlockListItems(username string) ([]model.BlockListItem, error)
 }
 
+type mucStorage interface {
+	// InsertOrUpdateRoom inserts a new room entity into storage,
+	// or updates it in case it's been previously inserted.
+	InsertOrUpdateRoom(room *mucmodel.Room) error
+
+	// DeleteRoom deletes a room entity from storage.
+	DeleteRoom(roomJID string) error
+
+	// FetchRoom retrieves from storage a room entity.
+	FetchRoom(roomJID string) (*mucmodel.Room, error)
+
+	// FetchRooms retrieves from storage all persisted room entities.
+	FetchRooms() ([]mucmodel.Room, error)
+}
+
+type mamStorage interface {
+	// InsertArchiveMessage inserts a new message entity into
+	// user's archive.
+	InsertArchiveMessage(message *mammodel.Message) error
+
+	// FetchArchiveMessages retrieves from storage all user's archived
+	// messages satisfying filter criteria, in chronological order.
+	FetchArchiveMessages(username string, filter *mammodel.Filter) ([]mammodel.Message, error)
+
+	// InsertOrUpdateArchivePrefs inserts a new archiving preferences entity
+	// into storage, or updates it in case it's been previously inserted.
+	InsertOrUpdateArchivePrefs(prefs *mammodel.Prefs) error
+
+	// FetchArchivePrefs retrieves from storage user's archiving preferences.
+	FetchArchivePrefs(username string) (*mammodel.Prefs, error)
+
+	// DeleteArchive deletes from storage all user's archived messages
+	// along with its archiving preferences.
+	DeleteArchive(username string) error
+}
+
+type pubSubStorage interface {
+	// InsertOrUpdatePubSubNode inserts a new pubsub node entity into storage,
+	// or updates it in case it's been previously inserted.
+	InsertOrUpdatePubSubNode(node *pubsubmodel.Node) error
+
+	// FetchPubSubNode retrieves from storage a pubsub node entity.
+	FetchPubSubNode(host, name string) (*pubsubmodel.Node, error)
+
+	// FetchPubSubNodes retrieves from storage all pubsub node entities
+	// associated to a given host.
+	FetchPubSubNodes(host string) ([]pubsubmodel.Node, error)
+
+	// DeletePubSubNode deletes a pubsub node entity from storage,
+	// along with all its published items.
+	DeletePubSubNode(host, name string) error
+
+	// InsertOrUpdatePubSubNodeItem inserts a new pubsub item entity into storage,
+	// or updates it in case it's been previously inserted.
+	InsertOrUpdatePubSubNodeItem(item *pubsubmodel.Item, host, name string) error
+
+	// DeletePubSubNodeItem deletes a pubsub item entity from storage.
+	DeletePubSubNodeItem(host, name, itemID string) error
+
+	// FetchPubSubNodeItems retrieves from storage all items published
+	// to a given node, in chronological order.
+	FetchPubSubNodeItems(host, name string) ([]pubsubmodel.Item, error)
+}
+
+type capabilitiesStorage interface {
+	// InsertOrUpdateCapabilities inserts a new capabilities entity into storage,
+	// or updates it in case it's been previously inserted.
+	InsertOrUpdateCapabilities(caps *capsmodel.Capabilities) error
+
+	// FetchCapabilities retrieves from storage a capabilities entity
+	// associated to a given verification string.
+	FetchCapabilities(ver string) (*capsmodel.Capabilities, error)
+}
+
 // Storage represents an entity storage interface.
 type Storage interface {
 	userStorage
@@ -117,6 +212,10 @@ type Storage interface {
 	vCardStorage
 	privateStorage
 	blockListStorage
+	mucStorage
+	mamStorage
+	pubSubStorage
+	capabilitiesStorage
 
 	// Shutdown shuts down storage sub system.
 	Shutdown()
@@ -137,11 +236,15 @@ func Initialize(cfg *Config) {
 	}
 	switch cfg.Type {
 	case BadgerDB:
-		inst = badgerdb.New(cfg.BadgerDB)
+		inst = newMeasuredStorage(badgerdb.New(cfg.BadgerDB), "badgerdb")
 	case MySQL:
-		inst = sql.New(cfg.MySQL)
+		inst = newMeasuredStorage(sql.New(cfg.MySQL), "mysql")
+	case PostgreSQL:
+		inst = newMeasuredStorage(sql.NewPostgreSQL(cfg.PostgreSQL), "postgresql")
+	case SQLite:
+		inst = newMeasuredStorage(sql.NewSQLite(cfg.SQLite), "sqlite")
 	case Memory:
-		inst = memstorage.New()
+		inst = newMeasuredStorage(memstorage.New(), "memory")
 	default:
 		// should not be reached
 		break
@@ -149,6 +252,89 @@ func Initialize(cfg *Config) {
 	initialized = true
 }
 
+// Migrate applies every pending schema migration to the configured
+// SQL storage, returning the resulting schema version.
+func Migrate(cfg *Config) (int, error) {
+	var s *sql.Storage
+	switch cfg.Type {
+	case MySQL:
+		mySQLCfg := *cfg.MySQL
+		mySQLCfg.ManualMigrations = false
+		s = sql.New(&mySQLCfg)
+	case PostgreSQL:
+		postgreSQLCfg := *cfg.PostgreSQL
+		postgreSQLCfg.ManualMigrations = false
+		s = sql.NewPostgreSQL(&postgreSQLCfg)
+	case SQLite:
+		s = sql.NewSQLite(cfg.SQLite)
+	default:
+		return 0, errors.New("storage: schema migrations are only supported by SQL storage types")
+	}
+	defer s.Shutdown()
+	return s.SchemaVersion()
+}
+
+// DeleteUserData deletes a user entity from storage along with every piece
+// of data stored on its behalf: roster, offline queue, vCard, private XML,
+// message archive, block list and personal eventing nodes.
+func DeleteUserData(username string) error {
+	s := Instance()
+
+	items, _, err := s.FetchRosterItems(username)
+	if err != nil {
+		return err
+	}
+	for _, itm := range items {
+		if _, err := s.DeleteRosterItem(username, itm.JID); err != nil {
+			return err
+		}
+	}
+	rns, err := s.FetchRosterNotifications(username)
+	if err != nil {
+		return err
+	}
+	for _, rn := range rns {
+		if err := s.DeleteRosterNotification(rn.Contact, rn.JID); err != nil {
+			return err
+		}
+	}
+	if err := s.DeleteOfflineMessages(username); err != nil {
+		return err
+	}
+	if err := s.DeleteVCard(username); err != nil {
+		return err
+	}
+	if err := s.DeletePrivateXML(username); err != nil {
+		return err
+	}
+	if err := s.DeleteArchive(username); err != nil {
+		return err
+	}
+	blItems, err := s.FetchBlockListItems(username)
+	if err != nil {
+		return err
+	}
+	if len(blItems) > 0 {
+		if err := s.DeleteBlockListItems(blItems); err != nil {
+			return err
+		}
+	}
+	// PEP nodes are hosted by user's bare JID
+	for _, domain := range host.HostNames() {
+		ownerJID := username + "@" + domain
+		nodes, err := s.FetchPubSubNodes(ownerJID)
+		if err != nil {
+			return err
+		}
+		for _, n := range nodes {
+			if err := s.DeletePubSubNode(ownerJID, n.Name); err != nil {
+				return err
+			}
+		}
+	}
+	return s.DeleteUser(username)
+}
+
 // Instance returns global storage sub system.
 func Instance() Storage {
 	instMu.RLock()
@@ -175,7 +361,7 @@ func ActivateMockedError() {
 	instMu.Lock()
 	defer instMu.Unlock()
 
-	switch inst := inst.(type) {
+	switch inst := backend().(type) {
 	case *memstorage.Storage:
 		inst.ActivateMockedError()
 	}
@@ -187,8 +373,15 @@ func DeactivateMockedError() {
 	instMu.Lock()
 	defer instMu.Unlock()
 
-	switch inst := inst.(type) {
+	switch inst := backend().(type) {
 	case *memstorage.Storage:
 		inst.DeactivateMockedError()
 	}
 }
+
+func backend() Storage {
+	if ms, ok := inst.(*measuredStorage); ok {
+		return ms.storage
+	}
+	return inst
+}
@@ -0,0 +1,74 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/mammodel"
+	"github.com/ortuman/jackal/model/pubsubmodel"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStorage_DeleteUserData(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	Initialize(&Config{Type: Memory})
+	defer func() {
+		Shutdown()
+		host.Shutdown()
+	}()
+	s := Instance()
+
+	from, _ := jid.NewWithString("noelia@jackal.im/garden", true)
+	to, _ := jid.NewWithString("ortuman@jackal.im", true)
+	msg := xmpp.NewMessageType("abc1234", xmpp.ChatType)
+	msg.SetFromJID(from)
+	msg.SetToJID(to)
+
+	require.Nil(t, s.InsertOrUpdateUser(&model.User{Username: "ortuman", Password: "1234"}))
+	_, err := s.InsertOrUpdateRosterItem(&rostermodel.Item{Username: "ortuman", JID: "noelia@jackal.im", Subscription: "both"})
+	require.Nil(t, err)
+	require.Nil(t, s.InsertOrUpdateRosterNotification(&rostermodel.Notification{Contact: "ortuman", JID: "romeo@jackal.im", Presence: xmpp.NewPresence(from, to, xmpp.SubscribeType)}))
+	require.Nil(t, s.InsertOfflineMessage(msg, "ortuman"))
+	require.Nil(t, s.InsertOrUpdateVCard(xmpp.NewElementNamespace("vCard", "vcard-temp"), "ortuman"))
+	require.Nil(t, s.InsertOrUpdatePrivateXML([]xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "ortuman"))
+	require.Nil(t, s.InsertArchiveMessage(&mammodel.Message{ID: "abc1234", Username: "ortuman", With: "noelia@jackal.im", Message: msg, Stamp: time.Now()}))
+	require.Nil(t, s.InsertBlockListItems([]model.BlockListItem{{Username: "ortuman", JID: "romeo@jackal.im"}}))
+	require.Nil(t, s.InsertOrUpdatePubSubNode(&pubsubmodel.Node{Host: "ortuman@jackal.im", Name: "urn:xmpp:avatar:data"}))
+
+	require.Nil(t, DeleteUserData("ortuman"))
+
+	usr, _ := s.FetchUser("ortuman")
+	require.Nil(t, usr)
+	items, _, _ := s.FetchRosterItems("ortuman")
+	require.Equal(t, 0, len(items))
+	rns, _ := s.FetchRosterNotifications("ortuman")
+	require.Equal(t, 0, len(rns))
+	cnt, _ := s.CountOfflineMessages("ortuman")
+	require.Equal(t, 0, cnt)
+	vCard, _ := s.FetchVCard("ortuman")
+	require.Nil(t, vCard)
+	namespaces, _ := s.FetchPrivateXMLNamespaces("ortuman")
+	require.Equal(t, 0, len(namespaces))
+	msgs, _ := s.FetchArchiveMessages("ortuman", &mammodel.Filter{})
+	require.Equal(t, 0, len(msgs))
+	blItems, _ := s.FetchBlockListItems("ortuman")
+	require.Equal(t, 0, len(blItems))
+	nodes, _ := s.FetchPubSubNodes("ortuman@jackal.im")
+	require.Equal(t, 0, len(nodes))
+
+	// storage failure
+	require.Nil(t, s.InsertOrUpdateUser(&model.User{Username: "ortuman", Password: "1234"}))
+	ActivateMockedError()
+	require.NotNil(t, DeleteUserData("ortuman"))
+	DeactivateMockedError()
+}
@@ -0,0 +1,348 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0227
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/storage"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+)
+
+const (
+	pieNamespace          = "urn:xmpp:pie:0"
+	scramNamespace        = "urn:xmpp:pie:0#scram"
+	rosterNamespace       = "jabber:iq:roster"
+	vCardNamespace        = "vcard-temp"
+	privateNamespace      = "jabber:iq:private"
+	blockingNamespace     = "urn:xmpp:blocking"
+	jabberClientNamespace = "jabber:client"
+)
+
+// scram credential hash to SASL mechanism name
+var scramMechanisms = map[string]string{
+	"sha-1":   "SCRAM-SHA-1",
+	"sha-256": "SCRAM-SHA-256",
+}
+
+// Export writes every user account contained in storage s as a XEP-0227
+// document, under the given host domain.
+func Export(w io.Writer, s storage.Storage, domain string) error {
+	usernames, err := s.FetchUsernames()
+	if err != nil {
+		return err
+	}
+	io.WriteString(w, xml.Header)
+	io.WriteString(w, `<server-data xmlns="`+pieNamespace+`">`)
+	io.WriteString(w, `<host jid="`+escapeAttr(domain)+`">`)
+	for _, username := range usernames {
+		elem, err := exportUser(s, username)
+		if err != nil {
+			return fmt.Errorf("xep0227: %s: %v", username, err)
+		}
+		if elem == nil {
+			continue // deleted meanwhile
+		}
+		elem.ToXML(w, true)
+	}
+	_, err = io.WriteString(w, "</host></server-data>\n")
+	return err
+}
+
+// Import reads a XEP-0227 document from r and stores every contained
+// user account into storage s, returning the number of imported users.
+// Accounts from every host element are imported, and already existing
+// users are overwritten.
+func Import(r io.Reader, s storage.Storage) (int, error) {
+	root, err := parseDocument(r)
+	if err != nil {
+		return 0, err
+	}
+	if root.Name() != "server-data" || root.Namespace() != pieNamespace {
+		return 0, fmt.Errorf("xep0227: unexpected root element: %s", root.Name())
+	}
+	var count int
+	for _, hostEl := range root.Elements().Children("host") {
+		for _, userEl := range hostEl.Elements().Children("user") {
+			if err := importUser(s, userEl); err != nil {
+				return count, fmt.Errorf("xep0227: %s: %v", userEl.Attributes().Get("name"), err)
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+func exportUser(s storage.Storage, username string) (*xmpp.Element, error) {
+	user, err := s.FetchUser(username)
+	if err != nil || user == nil {
+		return nil, err
+	}
+	elem := xmpp.NewElementName("user")
+	elem.SetAttribute("name", escapeAttr(user.Username))
+	if len(user.Password) > 0 {
+		elem.SetAttribute("password", escapeAttr(user.Password))
+	}
+	for _, c := range user.Credentials {
+		mechanism, ok := scramMechanisms[c.Hash]
+		if !ok {
+			continue
+		}
+		elem.AppendElement(scramCredentialsElement(mechanism, &c))
+	}
+	// roster
+	items, _, err := s.FetchRosterItems(username)
+	if err != nil {
+		return nil, err
+	}
+	if len(items) > 0 {
+		query := xmpp.NewElementNamespace("query", rosterNamespace)
+		for _, itm := range items {
+			itemEl := xmpp.NewElementFromElement(itm.Element())
+			if len(itm.Name) > 0 {
+				itemEl.SetAttribute("name", escapeAttr(itm.Name))
+			}
+			query.AppendElement(itemEl)
+		}
+		elem.AppendElement(query)
+	}
+	// vCard
+	vCard, err := s.FetchVCard(username)
+	if err != nil {
+		return nil, err
+	}
+	if vCard != nil {
+		elem.AppendElement(vCard)
+	}
+	// private XML
+	namespaces, err := s.FetchPrivateXMLNamespaces(username)
+	if err != nil {
+		return nil, err
+	}
+	if len(namespaces) > 0 {
+		query := xmpp.NewElementNamespace("query", privateNamespace)
+		for _, ns := range namespaces {
+			prvs, err := s.FetchPrivateXML(ns, username)
+			if err != nil {
+				return nil, err
+			}
+			query.AppendElements(prvs)
+		}
+		elem.AppendElement(query)
+	}
+	// block list
+	blItems, err := s.FetchBlockListItems(username)
+	if err != nil {
+		return nil, err
+	}
+	if len(blItems) > 0 {
+		blockList := xmpp.NewElementNamespace("blocklist", blockingNamespace)
+		for _, blItem := range blItems {
+			itm := xmpp.NewElementName("item")
+			itm.SetAttribute("jid", blItem.JID)
+			blockList.AppendElement(itm)
+		}
+		elem.AppendElement(blockList)
+	}
+	// offline messages
+	messages, err := s.FetchOfflineMessages(username)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) > 0 {
+		offline := xmpp.NewElementName("offline-messages")
+		for _, msg := range messages {
+			msgEl := xmpp.NewElementFromElement(msg)
+			msgEl.SetNamespace(jabberClientNamespace)
+			offline.AppendElement(msgEl)
+		}
+		elem.AppendElement(offline)
+	}
+	return elem, nil
+}
+
+func importUser(s storage.Storage, elem xmpp.XElement) error {
+	username := elem.Attributes().Get("name")
+	if len(username) == 0 {
+		return errors.New("user 'name' attribute is required")
+	}
+	user := &model.User{
+		Username: username,
+		Password: elem.Attributes().Get("password"),
+	}
+	for _, credEl := range elem.Elements().ChildrenNamespace("scram-credentials", scramNamespace) {
+		c, err := parseScramCredentials(credEl)
+		if err != nil {
+			return err
+		}
+		if c != nil {
+			user.Credentials = append(user.Credentials, *c)
+		}
+	}
+	if len(user.Credentials) > 0 {
+		// hashed credentials supersede legacy plaintext password
+		user.Password = ""
+	}
+	if err := s.InsertOrUpdateUser(user); err != nil {
+		return err
+	}
+	// roster
+	if query := elem.Elements().ChildNamespace("query", rosterNamespace); query != nil {
+		for _, itemEl := range query.Elements().Children("item") {
+			ri, err := rostermodel.NewItem(itemEl)
+			if err != nil {
+				return err
+			}
+			ri.Username = username
+			if _, err := s.InsertOrUpdateRosterItem(ri); err != nil {
+				return err
+			}
+		}
+	}
+	// vCard
+	if vCard := elem.Elements().ChildNamespace("vCard", vCardNamespace); vCard != nil {
+		if err := s.InsertOrUpdateVCard(vCard, username); err != nil {
+			return err
+		}
+	}
+	// private XML
+	if query := elem.Elements().ChildNamespace("query", privateNamespace); query != nil {
+		var namespaces []string
+		prvs := make(map[string][]xmpp.XElement)
+		for _, prv := range query.Elements().All() {
+			ns := prv.Namespace()
+			if _, ok := prvs[ns]; !ok {
+				namespaces = append(namespaces, ns)
+			}
+			prvs[ns] = append(prvs[ns], prv)
+		}
+		for _, ns := range namespaces {
+			if err := s.InsertOrUpdatePrivateXML(prvs[ns], ns, username); err != nil {
+				return err
+			}
+		}
+	}
+	// block list
+	if blockList := elem.Elements().ChildNamespace("blocklist", blockingNamespace); blockList != nil {
+		var blItems []model.BlockListItem
+		for _, itm := range blockList.Elements().Children("item") {
+			j, err := jid.NewWithString(itm.Attributes().Get("jid"), false)
+			if err != nil {
+				return err
+			}
+			blItems = append(blItems, model.BlockListItem{Username: username, JID: j.String()})
+		}
+		if len(blItems) > 0 {
+			if err := s.InsertBlockListItems(blItems); err != nil {
+				return err
+			}
+		}
+	}
+	// offline messages
+	if offline := elem.Elements().Child("offline-messages"); offline != nil {
+		for _, msgEl := range offline.Elements().Children("message") {
+			fromJID, err := jid.NewWithString(msgEl.From(), false)
+			if err != nil {
+				return err
+			}
+			toJID, err := jid.NewWithString(msgEl.To(), false)
+			if err != nil {
+				return err
+			}
+			msg, err := xmpp.NewMessageFromElement(msgEl, fromJID, toJID)
+			if err != nil {
+				return err
+			}
+			if err := s.InsertOfflineMessage(msg, username); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func scramCredentialsElement(mechanism string, c *model.Credential) xmpp.XElement {
+	elem := xmpp.NewElementNamespace("scram-credentials", scramNamespace)
+	elem.SetAttribute("mechanism", mechanism)
+
+	iterCount := xmpp.NewElementName("iter-count")
+	iterCount.SetText(strconv.Itoa(c.Iterations))
+	salt := xmpp.NewElementName("salt")
+	salt.SetText(base64.StdEncoding.EncodeToString(c.Salt))
+	serverKey := xmpp.NewElementName("server-key")
+	serverKey.SetText(base64.StdEncoding.EncodeToString(c.ServerKey))
+	storedKey := xmpp.NewElementName("stored-key")
+	storedKey.SetText(base64.StdEncoding.EncodeToString(c.StoredKey))
+
+	elem.AppendElements([]xmpp.XElement{iterCount, salt, serverKey, storedKey})
+	return elem
+}
+
+func parseScramCredentials(elem xmpp.XElement) (*model.Credential, error) {
+	mechanism := elem.Attributes().Get("mechanism")
+	var c model.Credential
+	for hash, m := range scramMechanisms {
+		if m == mechanism {
+			c.Hash = hash
+			break
+		}
+	}
+	if len(c.Hash) == 0 {
+		return nil, nil // unsupported mechanism
+	}
+	var err error
+	if c.Iterations, err = strconv.Atoi(childText(elem, "iter-count")); err != nil {
+		return nil, fmt.Errorf("invalid %s iteration count: %v", mechanism, err)
+	}
+	if c.Salt, err = base64.StdEncoding.DecodeString(childText(elem, "salt")); err != nil {
+		return nil, err
+	}
+	if c.ServerKey, err = base64.StdEncoding.DecodeString(childText(elem, "server-key")); err != nil {
+		return nil, err
+	}
+	if c.StoredKey, err = base64.StdEncoding.DecodeString(childText(elem, "stored-key")); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func parseDocument(r io.Reader) (xmpp.XElement, error) {
+	p := xmpp.NewParser(r, xmpp.DefaultMode, 0)
+	for {
+		elem, err := p.ParseElement()
+		if err != nil {
+			return nil, err
+		}
+		if elem != nil {
+			return elem, nil
+		}
+		// skip XML declaration and leading whitespace
+	}
+}
+
+func childText(elem xmpp.XElement, name string) string {
+	if child := elem.Elements().Child(name); child != nil {
+		return child.Text()
+	}
+	return ""
+}
+
+// escapeAttr escapes s, as element attribute values are
+// serialized verbatim.
+func escapeAttr(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
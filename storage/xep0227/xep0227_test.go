@@ -0,0 +1,141 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package xep0227
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/ortuman/jackal/model"
+	"github.com/ortuman/jackal/model/rostermodel"
+	"github.com/ortuman/jackal/storage/memstorage"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/ortuman/jackal/xmpp/jid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestXEP0227_ExportImport(t *testing.T) {
+	src := memstorage.New()
+
+	usr := &model.User{Username: "ortuman"}
+	usr.Credentials = []model.Credential{
+		{Hash: "sha-1", Salt: []byte{1}, Iterations: 4096, StoredKey: []byte{2}, ServerKey: []byte{3}},
+		{Hash: "sha-256", Salt: []byte{4}, Iterations: 4096, StoredKey: []byte{5}, ServerKey: []byte{6}},
+	}
+	require.Nil(t, src.InsertOrUpdateUser(usr))
+	require.Nil(t, src.InsertOrUpdateUser(&model.User{Username: "romeo", Password: "a&b<c>\"d"}))
+
+	_, err := src.InsertOrUpdateRosterItem(&rostermodel.Item{
+		Username:     "ortuman",
+		JID:          "romeo@jackal.im",
+		Name:         "Romeo & co.",
+		Subscription: rostermodel.SubscriptionBoth,
+		Groups:       []string{"Friends"},
+	})
+	require.Nil(t, err)
+
+	vCard := xmpp.NewElementNamespace("vCard", "vcard-temp")
+	fn := xmpp.NewElementName("FN")
+	fn.SetText("Miguel Ángel")
+	vCard.AppendElement(fn)
+	require.Nil(t, src.InsertOrUpdateVCard(vCard, "ortuman"))
+
+	require.Nil(t, src.InsertOrUpdatePrivateXML([]xmpp.XElement{xmpp.NewElementNamespace("exodus", "exodus:ns")}, "exodus:ns", "ortuman"))
+	require.Nil(t, src.InsertBlockListItems([]model.BlockListItem{{Username: "ortuman", JID: "noelia@jackal.im"}}))
+
+	from, _ := jid.NewWithString("romeo@jackal.im/balcony", true)
+	to, _ := jid.NewWithString("ortuman@jackal.im", true)
+	msg := xmpp.NewElementName("message")
+	msg.SetID("id-1")
+	body := xmpp.NewElementName("body")
+	body.SetText("Hi!")
+	msg.AppendElement(body)
+	message, _ := xmpp.NewMessageFromElement(msg, from, to)
+	require.Nil(t, src.InsertOfflineMessage(message, "ortuman"))
+
+	buf := bytes.NewBuffer(nil)
+	require.Nil(t, Export(buf, src, "jackal.im"))
+
+	dst := memstorage.New()
+	count, err := Import(buf, dst)
+	require.Nil(t, err)
+	require.Equal(t, 2, count)
+
+	usr2, _ := dst.FetchUser("ortuman")
+	require.NotNil(t, usr2)
+	require.Equal(t, usr.Credentials, usr2.Credentials)
+
+	usr3, _ := dst.FetchUser("romeo")
+	require.NotNil(t, usr3)
+	require.Equal(t, "a&b<c>\"d", usr3.Password)
+
+	items, _, _ := dst.FetchRosterItems("ortuman")
+	require.Equal(t, 1, len(items))
+	require.Equal(t, "romeo@jackal.im", items[0].JID)
+	require.Equal(t, "Romeo & co.", items[0].Name)
+	require.Equal(t, rostermodel.SubscriptionBoth, items[0].Subscription)
+	require.Equal(t, []string{"Friends"}, items[0].Groups)
+
+	vCard2, _ := dst.FetchVCard("ortuman")
+	require.NotNil(t, vCard2)
+	require.Equal(t, "Miguel Ángel", vCard2.Elements().Child("FN").Text())
+
+	prvs, _ := dst.FetchPrivateXML("exodus:ns", "ortuman")
+	require.Equal(t, 1, len(prvs))
+
+	blItems, _ := dst.FetchBlockListItems("ortuman")
+	require.Equal(t, []model.BlockListItem{{Username: "ortuman", JID: "noelia@jackal.im"}}, blItems)
+
+	messages, _ := dst.FetchOfflineMessages("ortuman")
+	require.Equal(t, 1, len(messages))
+	require.Equal(t, "id-1", messages[0].ID())
+	require.Equal(t, "romeo@jackal.im/balcony", messages[0].From())
+	require.Equal(t, "Hi!", messages[0].Elements().Child("body").Text())
+}
+
+func TestXEP0227_Import(t *testing.T) {
+	doc := `<?xml version="1.0" encoding="UTF-8"?>
+<server-data xmlns="urn:xmpp:pie:0">
+  <host jid="capulet.lit">
+    <user name="juliet" password="s3crEt">
+      <query xmlns="jabber:iq:roster">
+        <item jid="romeo@montague.lit" subscription="to" ask="subscribe"/>
+      </query>
+    </user>
+  </host>
+  <host jid="montague.lit">
+    <user name="romeo"/>
+  </host>
+</server-data>`
+
+	s := memstorage.New()
+	count, err := Import(strings.NewReader(doc), s)
+	require.Nil(t, err)
+	require.Equal(t, 2, count)
+
+	usr, _ := s.FetchUser("juliet")
+	require.NotNil(t, usr)
+	require.Equal(t, "s3crEt", usr.Password)
+
+	items, _, _ := s.FetchRosterItems("juliet")
+	require.Equal(t, 1, len(items))
+	require.True(t, items[0].Ask)
+
+	ok, _ := s.UserExists("romeo")
+	require.True(t, ok)
+
+	_, err = Import(strings.NewReader(`<stream xmlns="jabber:client"/>`), s)
+	require.NotNil(t, err)
+
+	_, err = Import(strings.NewReader(`<server-data xmlns="urn:xmpp:pie:0"><host><user/></host></server-data>`), s)
+	require.NotNil(t, err)
+
+	s.ActivateMockedError()
+	_, err = Import(strings.NewReader(doc), s)
+	require.NotNil(t, err)
+	require.Equal(t, memstorage.ErrMockedError, Export(bytes.NewBuffer(nil), s, "capulet.lit"))
+}
@@ -0,0 +1,558 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package transport
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/log"
+	"github.com/ortuman/jackal/transport/compress"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/pborman/uuid"
+)
+
+const (
+	boshNamespace         = "http://jabber.org/protocol/httpbind"
+	xboshNamespace        = "urn:xmpp:xbosh"
+	streamNamespace       = "http://etherx.jabber.org/streams"
+	framedStreamNamespace = "urn:ietf:params:xml:ns:xmpp-framing"
+	boshVersion           = "1.11"
+)
+
+const boshInMailboxSize = 64
+
+var errBOSHClosed = errors.New("bosh: transport closed")
+
+// BOSHConfig represents a BOSH (XEP-0124) transport configuration.
+type BOSHConfig struct {
+	// Wait is the longest time a request will be held by the server.
+	Wait time.Duration
+
+	// Hold is the maximum number of requests the server will keep
+	// waiting at any one time.
+	Hold int
+
+	// Inactivity is the longest allowable time with no requests held
+	// before the session is terminated.
+	Inactivity time.Duration
+
+	// Polling is the shortest allowable polling interval.
+	Polling time.Duration
+
+	// MaxRequestSize is the maximum allowed size of a request body.
+	MaxRequestSize int
+}
+
+// BOSHHandler handles HTTP binding requests, creating a new
+// BOSH transport for every initiated session.
+type BOSHHandler struct {
+	cfg       BOSHConfig
+	onSession func(Transport)
+	mu        sync.RWMutex
+	sessions  map[string]*boshTransport
+}
+
+// NewBOSHHandler returns a new BOSH HTTP handler. onSession will be invoked
+// each time a new session transport is created.
+func NewBOSHHandler(cfg BOSHConfig, onSession func(Transport)) *BOSHHandler {
+	return &BOSHHandler{
+		cfg:       cfg,
+		onSession: onSession,
+		sessions:  make(map[string]*boshTransport),
+	}
+}
+
+// ServeHTTP satisfies http.Handler interface.
+func (h *BOSHHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+
+	switch r.Method {
+	case http.MethodOptions:
+		w.WriteHeader(http.StatusOK)
+		return
+	case http.MethodPost:
+		break
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	var resp []byte
+	body, err := h.readBody(r)
+	if err != nil {
+		log.Error(err)
+		resp = boshTerminateBody("bad-request")
+	} else if sid := body.Attributes().Get("sid"); len(sid) == 0 {
+		resp = h.createSession(body, r.RemoteAddr)
+	} else if tr := h.session(sid); tr != nil {
+		resp = tr.handleRequest(body)
+	} else {
+		resp = boshTerminateBody("item-not-found")
+	}
+	w.Header().Set("Content-Type", "text/xml; charset=utf-8")
+	w.Write(resp)
+}
+
+func (h *BOSHHandler) readBody(r *http.Request) (xmpp.XElement, error) {
+	var rd io.Reader = r.Body
+	if h.cfg.MaxRequestSize > 0 {
+		rd = io.LimitReader(r.Body, int64(h.cfg.MaxRequestSize))
+	}
+	p := xmpp.NewParser(rd, xmpp.DefaultMode, h.cfg.MaxRequestSize)
+	for {
+		elem, err := p.ParseElement()
+		if err != nil {
+			return nil, err
+		}
+		if elem == nil {
+			continue
+		}
+		if elem.Name() != "body" || elem.Namespace() != boshNamespace {
+			return nil, fmt.Errorf("bosh: unexpected element: %s", elem.Name())
+		}
+		return elem, nil
+	}
+}
+
+func (h *BOSHHandler) createSession(body xmpp.XElement, remoteAddr string) []byte {
+	attrs := body.Attributes()
+	rid, err := strconv.ParseInt(attrs.Get("rid"), 10, 64)
+	if err != nil || len(body.To()) == 0 {
+		return boshTerminateBody("bad-request")
+	}
+	if !host.IsLocalHost(body.To()) {
+		return boshTerminateBody("host-unknown")
+	}
+	wait := h.cfg.Wait
+	if w, err := strconv.Atoi(attrs.Get("wait")); err == nil && w >= 0 && time.Duration(w)*time.Second < wait {
+		wait = time.Duration(w) * time.Second
+	}
+	hold := h.cfg.Hold
+	if hl, err := strconv.Atoi(attrs.Get("hold")); err == nil && hl >= 0 && hl < hold {
+		hold = hl
+	}
+	tr := &boshTransport{
+		sid:        uuid.New(),
+		to:         body.To(),
+		lang:       attrs.Get("xml:lang"),
+		wait:       wait,
+		hold:       hold,
+		inactivity: h.cfg.Inactivity,
+		polling:    h.cfg.Polling,
+		inCh:       make(chan []byte, boshInMailboxSize),
+		nextRid:    rid,
+		ridCh:      make(chan struct{}),
+		responses:  make(map[int64][]byte),
+		closeCh:    make(chan struct{}),
+	}
+	if addr, err := net.ResolveTCPAddr("tcp", remoteAddr); err == nil {
+		tr.remoteAddr = addr
+	}
+	tr.onClose = func() { h.unregisterSession(tr.sid) }
+
+	h.mu.Lock()
+	h.sessions[tr.sid] = tr
+	h.mu.Unlock()
+
+	log.Infof("bosh: created session... (sid: %s)", tr.sid)
+
+	h.onSession(tr)
+	return tr.handleRequest(body)
+}
+
+func (h *BOSHHandler) session(sid string) *boshTransport {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.sessions[sid]
+}
+
+func (h *BOSHHandler) unregisterSession(sid string) {
+	h.mu.Lock()
+	delete(h.sessions, sid)
+	h.mu.Unlock()
+	log.Infof("bosh: terminated session... (sid: %s)", sid)
+}
+
+type boshRequest struct {
+	rid      int64
+	creation bool
+	respCh   chan []byte
+}
+
+type boshTransport struct {
+	sid        string
+	to         string
+	lang       string
+	wait       time.Duration
+	hold       int
+	inactivity time.Duration
+	polling    time.Duration
+	inCh       chan []byte
+	rd         []byte
+	mu         sync.Mutex
+	authID     string
+	out        [][]byte
+	held       []*boshRequest
+	nextRid    int64
+	ridCh      chan struct{}
+	feeding    bool
+	inFlight   int
+	created    bool
+	responses  map[int64][]byte
+	lastPoll   time.Time
+	inactTm    *time.Timer
+	closed     bool
+	closeCh    chan struct{}
+	onClose    func()
+	remoteAddr net.Addr
+}
+
+func (t *boshTransport) Read(p []byte) (n int, err error) {
+	if len(t.rd) == 0 {
+		// deliver any pending data before notifying closure
+		select {
+		case t.rd = <-t.inCh:
+		default:
+			select {
+			case t.rd = <-t.inCh:
+			case <-t.closeCh:
+				return 0, io.EOF
+			}
+		}
+	}
+	n = copy(p, t.rd)
+	t.rd = t.rd[n:]
+	return n, nil
+}
+
+func (t *boshTransport) Write(p []byte) (n int, err error) {
+	switch {
+	case bytes.HasPrefix(p, []byte("<open ")):
+		// stream opening is implicit in BOSH session creation and restart
+		t.setAuthID(p)
+		return len(p), nil
+	case bytes.HasPrefix(p, []byte("<close ")):
+		return len(p), nil
+	}
+	b := make([]byte, len(p))
+	copy(b, p)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return 0, errBOSHClosed
+	}
+	t.out = append(t.out, b)
+	if len(t.held) > 0 {
+		req := t.held[0]
+		t.held = t.held[1:]
+		t.respond(req, t.takeOut())
+	}
+	return len(p), nil
+}
+
+func (t *boshTransport) Close() error {
+	t.mu.Lock()
+	if t.closed {
+		t.mu.Unlock()
+		return nil
+	}
+	t.closed = true
+	close(t.closeCh)
+	if t.inactTm != nil {
+		t.inactTm.Stop()
+	}
+	out := t.takeOut()
+	for _, req := range t.held {
+		req.respCh <- boshTerminateBodyWithPayload("", out)
+		out = nil
+	}
+	t.held = nil
+	t.mu.Unlock()
+
+	if t.onClose != nil {
+		t.onClose()
+	}
+	return nil
+}
+
+func (t *boshTransport) Type() TransportType {
+	return BOSH
+}
+
+func (t *boshTransport) WriteString(s string) (n int, err error) {
+	return t.Write([]byte(s))
+}
+
+func (t *boshTransport) StartTLS(_ *tls.Config, _ bool) {
+}
+
+func (t *boshTransport) EnableCompression(_ compress.Level) {
+}
+
+func (t *boshTransport) ChannelBindingBytes(_ ChannelBindingMechanism) []byte {
+	return nil
+}
+
+func (t *boshTransport) PeerCertificates() []*x509.Certificate {
+	return nil
+}
+
+func (t *boshTransport) RemoteAddr() net.Addr {
+	return t.remoteAddr
+}
+
+func (t *boshTransport) handleRequest(body xmpp.XElement) []byte {
+	rid, err := strconv.ParseInt(body.Attributes().Get("rid"), 10, 64)
+	if err != nil {
+		return boshTerminateBody("bad-request")
+	}
+	t.mu.Lock()
+	t.inFlight++
+	if t.inactTm != nil {
+		t.inactTm.Stop()
+	}
+	t.mu.Unlock()
+	defer t.requestDone()
+
+	// process requests in order
+	for {
+		t.mu.Lock()
+		switch {
+		case t.closed:
+			t.mu.Unlock()
+			return boshTerminateBody("")
+
+		case rid < t.nextRid || (rid == t.nextRid && t.feeding):
+			// retransmitted request
+			resp, ok := t.responses[rid]
+			t.mu.Unlock()
+			if ok {
+				return resp
+			}
+			return t.terminate("item-not-found")
+
+		case rid > t.nextRid+int64(t.hold):
+			t.mu.Unlock()
+			return t.terminate("item-not-found")
+
+		case rid == t.nextRid:
+			return t.processRequest(rid, body)
+		}
+		ridCh := t.ridCh
+		t.mu.Unlock()
+
+		select {
+		case <-ridCh:
+		case <-time.After(t.wait):
+			return t.terminate("item-not-found")
+		}
+	}
+}
+
+// processRequest is invoked holding t.mu lock.
+func (t *boshTransport) processRequest(rid int64, body xmpp.XElement) []byte {
+	isEmpty := body.Elements().Count() == 0
+	if isEmpty && t.hold == 0 && t.polling > 0 && t.created {
+		if time.Since(t.lastPoll) < t.polling {
+			t.mu.Unlock()
+			return t.terminate("policy-violation")
+		}
+		t.lastPoll = time.Now()
+	}
+	creation := !t.created
+	t.created = true
+	t.feeding = true
+	t.mu.Unlock()
+
+	// feed stream session
+	switch {
+	case body.Type() == "terminate":
+		t.feed([]byte(fmt.Sprintf(`<close xmlns="%s"/>`, framedStreamNamespace)))
+
+	case creation || body.Attributes().Get("xmpp:restart") == "true":
+		t.feed(t.openFrame())
+
+	case !isEmpty:
+		buf := &bytes.Buffer{}
+		for _, elem := range body.Elements().All() {
+			elem.ToXML(buf, true)
+		}
+		t.feed(buf.Bytes())
+	}
+
+	t.mu.Lock()
+	t.feeding = false
+	t.nextRid++
+	close(t.ridCh)
+	t.ridCh = make(chan struct{})
+
+	if body.Type() == "terminate" {
+		t.mu.Unlock()
+		t.Close()
+		return boshTerminateBody("")
+	}
+	req := &boshRequest{rid: rid, creation: creation, respCh: make(chan []byte, 1)}
+	if len(t.out) > 0 {
+		t.respond(req, t.takeOut())
+		t.mu.Unlock()
+		return <-req.respCh
+	}
+	t.held = append(t.held, req)
+	if len(t.held) > t.hold {
+		// release oldest held request
+		old := t.held[0]
+		t.held = t.held[1:]
+		t.respond(old, nil)
+	}
+	t.mu.Unlock()
+
+	select {
+	case resp := <-req.respCh:
+		return resp
+	case <-time.After(t.wait):
+		t.mu.Lock()
+		for i, r := range t.held {
+			if r == req {
+				t.held = append(t.held[:i], t.held[i+1:]...)
+				t.respond(req, nil)
+				break
+			}
+		}
+		t.mu.Unlock()
+		return <-req.respCh
+	}
+}
+
+func (t *boshTransport) requestDone() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.inFlight--
+	if t.inFlight > 0 || t.closed || t.inactivity == 0 {
+		return
+	}
+	if t.inactTm != nil {
+		t.inactTm.Reset(t.inactivity)
+		return
+	}
+	t.inactTm = time.AfterFunc(t.inactivity, t.inactivityTimeout)
+}
+
+func (t *boshTransport) inactivityTimeout() {
+	t.mu.Lock()
+	inactive := t.inFlight == 0 && !t.closed
+	t.mu.Unlock()
+	if inactive {
+		log.Infof("bosh: session inactivity timeout... (sid: %s)", t.sid)
+		t.Close()
+	}
+}
+
+func (t *boshTransport) terminate(condition string) []byte {
+	t.Close()
+	return boshTerminateBody(condition)
+}
+
+func (t *boshTransport) feed(b []byte) {
+	select {
+	case t.inCh <- b:
+	case <-t.closeCh:
+	}
+}
+
+// respond is invoked holding t.mu lock.
+func (t *boshTransport) respond(req *boshRequest, payload [][]byte) {
+	resp := t.buildBody(req, payload)
+	t.responses[req.rid] = resp
+	delete(t.responses, req.rid-int64(t.hold)-1)
+	req.respCh <- resp
+}
+
+// takeOut is invoked holding t.mu lock.
+func (t *boshTransport) takeOut() [][]byte {
+	out := t.out
+	t.out = nil
+	return out
+}
+
+func (t *boshTransport) buildBody(req *boshRequest, payload [][]byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`<body xmlns="` + boshNamespace + `"`)
+	if req.creation {
+		fmt.Fprintf(buf, ` sid="%s" wait="%d" hold="%d" requests="%d"`, t.sid, int(t.wait.Seconds()), t.hold, t.hold+1)
+		fmt.Fprintf(buf, ` inactivity="%d" polling="%d"`, int(t.inactivity.Seconds()), int(t.polling.Seconds()))
+		fmt.Fprintf(buf, ` ver="%s" from="%s" secure="true"`, boshVersion, boshEscape(t.to))
+		if len(t.authID) > 0 {
+			fmt.Fprintf(buf, ` authid="%s"`, boshEscape(t.authID))
+		}
+		fmt.Fprintf(buf, ` xmpp:version="1.0" xmlns:xmpp="%s"`, xboshNamespace)
+	}
+	return boshWritePayload(buf, payload)
+}
+
+func (t *boshTransport) openFrame() []byte {
+	open := xmpp.NewElementNamespace("open", framedStreamNamespace)
+	open.SetAttribute("to", t.to)
+	open.SetAttribute("xml:lang", t.lang)
+	open.SetAttribute("version", "1.0")
+	buf := &bytes.Buffer{}
+	open.ToXML(buf, true)
+	return buf.Bytes()
+}
+
+func (t *boshTransport) setAuthID(p []byte) {
+	elem, err := xmpp.NewParser(bytes.NewReader(p), xmpp.DefaultMode, 0).ParseElement()
+	if err != nil || elem == nil {
+		return
+	}
+	t.mu.Lock()
+	t.authID = elem.ID()
+	t.mu.Unlock()
+}
+
+func boshEscape(s string) string {
+	buf := &bytes.Buffer{}
+	xml.EscapeText(buf, []byte(s))
+	return buf.String()
+}
+
+func boshTerminateBody(condition string) []byte {
+	return boshTerminateBodyWithPayload(condition, nil)
+}
+
+func boshTerminateBodyWithPayload(condition string, payload [][]byte) []byte {
+	buf := &bytes.Buffer{}
+	buf.WriteString(`<body xmlns="` + boshNamespace + `" type="terminate"`)
+	if len(condition) > 0 {
+		buf.WriteString(` condition="` + condition + `"`)
+	}
+	return boshWritePayload(buf, payload)
+}
+
+func boshWritePayload(buf *bytes.Buffer, payload [][]byte) []byte {
+	if len(payload) == 0 {
+		buf.WriteString("/>")
+		return buf.Bytes()
+	}
+	buf.WriteString(` xmlns:stream="` + streamNamespace + `">`)
+	for _, b := range payload {
+		buf.Write(b)
+	}
+	buf.WriteString("</body>")
+	return buf.Bytes()
+}
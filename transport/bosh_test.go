@@ -0,0 +1,212 @@
+/*
+ * Copyright (c) 2018 Miguel Ángel Ortuño.
+ * See the LICENSE file for more information.
+ */
+
+package transport
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ortuman/jackal/host"
+	"github.com/ortuman/jackal/xmpp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBOSHTransport_Type(t *testing.T) {
+	tr := &boshTransport{}
+	require.Equal(t, BOSH, tr.Type())
+	require.Equal(t, "bosh", BOSH.String())
+}
+
+func TestBOSHHandler_CreateSession(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	defer host.Shutdown()
+
+	h, trCh := tUtilBOSHHandler(BOSHConfig{Wait: time.Second * 5, Hold: 1, Inactivity: time.Second * 5})
+
+	// invalid requests
+	resp := tUtilBOSHRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" rid="1"/>`)
+	require.Equal(t, "terminate", resp.Type())
+	require.Equal(t, "bad-request", resp.Attributes().Get("condition"))
+
+	resp = tUtilBOSHRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" sid="abcd" rid="1"/>`)
+	require.Equal(t, "terminate", resp.Type())
+	require.Equal(t, "item-not-found", resp.Attributes().Get("condition"))
+
+	// not a local host
+	resp = tUtilBOSHRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" rid="1" to="example.org"/>`)
+	require.Equal(t, "terminate", resp.Type())
+	require.Equal(t, "host-unknown", resp.Attributes().Get("condition"))
+
+	respCh := tUtilBOSHAsyncRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" xmlns:xmpp="urn:xmpp:xbosh" rid="100" to="jackal.im" wait="30" hold="1" xmpp:version="1.0"/>`)
+	tr := <-trCh
+	require.Equal(t, "192.0.2.1:1234", tr.RemoteAddr().String())
+	p := xmpp.NewParser(tr, xmpp.WebSocketStream, 0)
+	open, err := p.ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, "open", open.Name())
+	require.Equal(t, "jackal.im", open.To())
+
+	tr.WriteString(`<open xmlns="urn:ietf:params:xml:ns:xmpp-framing" id="stm-1" from="jackal.im" version="1.0"/>`)
+	tr.WriteString(`<stream:features><bind xmlns="urn:ietf:params:xml:ns:xmpp-bind"/></stream:features>`)
+
+	resp = <-respCh
+	require.True(t, len(resp.Attributes().Get("sid")) > 0)
+	require.Equal(t, "stm-1", resp.Attributes().Get("authid"))
+	require.Equal(t, "5", resp.Attributes().Get("wait"))
+	require.Equal(t, "2", resp.Attributes().Get("requests"))
+	require.Equal(t, "jackal.im", resp.From())
+	require.NotNil(t, resp.Elements().Child("stream:features"))
+
+	tr.Close()
+}
+
+func TestBOSHTransport_BuildBody(t *testing.T) {
+	tr := &boshTransport{sid: "abcd", to: `jackal.im"`, authID: `stm-1" foo="bar`}
+	b := tr.buildBody(&boshRequest{creation: true}, nil)
+
+	resp, err := xmpp.NewParser(bytes.NewReader(b), xmpp.DefaultMode, 0).ParseElement()
+	require.Nil(t, err)
+	require.Equal(t, `jackal.im"`, resp.From())
+	require.Equal(t, `stm-1" foo="bar`, resp.Attributes().Get("authid"))
+	require.Equal(t, "", resp.Attributes().Get("foo"))
+}
+
+func TestBOSHHandler_Requests(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	defer host.Shutdown()
+
+	h, trCh := tUtilBOSHHandler(BOSHConfig{Wait: time.Second * 5, Hold: 1, Inactivity: time.Second * 5})
+
+	sid, tr, p := tUtilBOSHSession(t, h, trCh)
+	defer tr.Close()
+
+	// echo stanzas back
+	go func() {
+		for {
+			elem, err := p.ParseElement()
+			if err != nil {
+				return
+			}
+			if elem.Name() == "message" {
+				buf := &bytes.Buffer{}
+				elem.ToXML(buf, true)
+				tr.Write(buf.Bytes())
+			}
+		}
+	}()
+	resp := tUtilBOSHRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" sid="`+sid+`" rid="101"><message xmlns="jabber:client" id="m1" to="noelia@jackal.im"/></body>`)
+	require.NotNil(t, resp.Elements().Child("message"))
+
+	// retransmitted request
+	resp2 := tUtilBOSHRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" sid="`+sid+`" rid="101"><message xmlns="jabber:client" id="m1" to="noelia@jackal.im"/></body>`)
+	require.Equal(t, resp.Elements().Count(), resp2.Elements().Count())
+
+	// out of window
+	resp = tUtilBOSHRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" sid="`+sid+`" rid="200"/>`)
+	require.Equal(t, "terminate", resp.Type())
+	require.Equal(t, "item-not-found", resp.Attributes().Get("condition"))
+	require.Nil(t, h.session(sid))
+}
+
+func TestBOSHHandler_Hold(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	defer host.Shutdown()
+
+	h, trCh := tUtilBOSHHandler(BOSHConfig{Wait: time.Millisecond * 250, Hold: 1, Inactivity: time.Second * 5})
+
+	sid, tr, _ := tUtilBOSHSession(t, h, trCh)
+	defer tr.Close()
+
+	// empty request held until wait expires
+	start := time.Now()
+	resp := tUtilBOSHRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" sid="`+sid+`" rid="101"/>`)
+	require.Equal(t, 0, resp.Elements().Count())
+	require.True(t, time.Since(start) >= time.Millisecond*250)
+
+	// a new request releases the held one
+	respCh := tUtilBOSHAsyncRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" sid="`+sid+`" rid="102"/>`)
+	time.Sleep(time.Millisecond * 50)
+	respCh2 := tUtilBOSHAsyncRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" sid="`+sid+`" rid="103"/>`)
+
+	select {
+	case resp = <-respCh:
+		require.Equal(t, 0, resp.Elements().Count())
+	case <-time.After(time.Millisecond * 150):
+		require.Fail(t, "held request not released")
+	}
+	// pending output is delivered to held request
+	tr.WriteString(`<message id="m1"/>`)
+	resp = <-respCh2
+	require.NotNil(t, resp.Elements().Child("message"))
+}
+
+func TestBOSHHandler_Terminate(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	defer host.Shutdown()
+
+	h, trCh := tUtilBOSHHandler(BOSHConfig{Wait: time.Second * 5, Hold: 1, Inactivity: time.Second * 5})
+
+	sid, _, p := tUtilBOSHSession(t, h, trCh)
+
+	resp := tUtilBOSHRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" sid="`+sid+`" rid="101" type="terminate"/>`)
+	require.Equal(t, "terminate", resp.Type())
+
+	_, err := p.ParseElement()
+	require.Equal(t, xmpp.ErrStreamClosedByPeer, err)
+	require.Nil(t, h.session(sid))
+}
+
+func TestBOSHHandler_Inactivity(t *testing.T) {
+	host.Initialize([]host.Config{{Name: "jackal.im"}})
+	defer host.Shutdown()
+
+	h, trCh := tUtilBOSHHandler(BOSHConfig{Wait: time.Second * 5, Hold: 1, Inactivity: time.Millisecond * 100})
+
+	sid, _, p := tUtilBOSHSession(t, h, trCh)
+	time.Sleep(time.Millisecond * 250)
+
+	_, err := p.ParseElement()
+	require.NotNil(t, err)
+	require.Nil(t, h.session(sid))
+}
+
+func tUtilBOSHHandler(cfg BOSHConfig) (*BOSHHandler, chan Transport) {
+	trCh := make(chan Transport, 1)
+	return NewBOSHHandler(cfg, func(tr Transport) { trCh <- tr }), trCh
+}
+
+func tUtilBOSHSession(t *testing.T, h *BOSHHandler, trCh chan Transport) (string, Transport, *xmpp.Parser) {
+	respCh := tUtilBOSHAsyncRequest(h, `<body xmlns="http://jabber.org/protocol/httpbind" rid="100" to="jackal.im"/>`)
+	tr := <-trCh
+	p := xmpp.NewParser(tr, xmpp.WebSocketStream, 0)
+	_, err := p.ParseElement() // open
+	require.Nil(t, err)
+	tr.WriteString(`<stream:features/>`)
+	resp := <-respCh
+	return resp.Attributes().Get("sid"), tr, p
+}
+
+func tUtilBOSHAsyncRequest(h *BOSHHandler, body string) <-chan xmpp.XElement {
+	respCh := make(chan xmpp.XElement, 1)
+	go func() { respCh <- tUtilBOSHRequest(h, body) }()
+	return respCh
+}
+
+func tUtilBOSHRequest(h *BOSHHandler, body string) xmpp.XElement {
+	req := httptest.NewRequest(http.MethodPost, "/http-bind", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	elem, _ := xmpp.NewParser(rec.Body, xmpp.DefaultMode, 0).ParseElement()
+	if elem == nil {
+		return &xmpp.Element{}
+	}
+	return elem
+}
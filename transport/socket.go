@@ -108,3 +108,7 @@ func (s *socketTransport) PeerCertificates() []*x509.Certificate {
 	}
 	return nil
 }
+
+func (s *socketTransport) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
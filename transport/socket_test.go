@@ -76,6 +76,7 @@ func TestSocket(t *testing.T) {
 
 	require.Nil(t, st2.ChannelBindingBytes(ChannelBindingMechanism(99)))
 	require.Nil(t, st2.ChannelBindingBytes(TLSUnique))
+	require.Equal(t, remoteAddr, st.RemoteAddr())
 
 	st.Close()
 	require.True(t, conn.closed)
@@ -9,6 +9,7 @@ import (
 	"crypto/tls"
 	"crypto/x509"
 	"io"
+	"net"
 
 	"github.com/ortuman/jackal/transport/compress"
 )
@@ -22,6 +23,9 @@ const (
 
 	// WebSocket represents a websocket transport type.
 	WebSocket
+
+	// BOSH represents a BOSH (XEP-0206) transport type.
+	BOSH
 )
 
 // String returns TransportType string representation.
@@ -31,6 +35,8 @@ func (tt TransportType) String() string {
 		return "socket"
 	case WebSocket:
 		return "websocket"
+	case BOSH:
+		return "bosh"
 	}
 	return ""
 }
@@ -67,6 +73,9 @@ type Transport interface {
 	// PeerCertificates returns the certificate chain
 	// presented by remote peer.
 	PeerCertificates() []*x509.Certificate
+
+	// RemoteAddr returns remote peer network address.
+	RemoteAddr() net.Addr
 }
 
 type tlsStateQueryable interface {
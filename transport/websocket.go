@@ -24,6 +24,7 @@ type WebSocketConn interface {
 	NextWriter(int) (io.WriteCloser, error)
 	Close() error
 	UnderlyingConn() net.Conn
+	RemoteAddr() net.Addr
 	SetReadDeadline(t time.Time) error
 }
 
@@ -106,3 +107,7 @@ func (wst *webSocketTransport) PeerCertificates() []*x509.Certificate {
 	}
 	return nil
 }
+
+func (wst *webSocketTransport) RemoteAddr() net.Addr {
+	return wst.conn.RemoteAddr()
+}
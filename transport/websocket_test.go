@@ -49,6 +49,7 @@ func (c *fakeWebSocketConn) NextWriter(int) (writer io.WriteCloser, err error)
 func (c *fakeWebSocketConn) Close() error                                          { c.closed = true; return nil }
 func (c *fakeWebSocketConn) SetReadDeadline(t time.Time) error                     { return nil }
 func (c *fakeWebSocketConn) UnderlyingConn() net.Conn                              { return &tls.Conn{} }
+func (c *fakeWebSocketConn) RemoteAddr() net.Addr                                  { return nil }
 
 func TestWebSocketTransport(t *testing.T) {
 	buff := make([]byte, 4096)
@@ -34,3 +34,16 @@ func TestLoadCertificate(t *testing.T) {
 		require.Equal(t, "must specify a private key and a server certificate for the domain 'jackal.im'", err.Error())
 	})
 }
+
+func TestLoadCertPool(t *testing.T) {
+	pool, err := LoadCertPool("../testdata/cert/test.server.crt")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(pool.Subjects()))
+
+	_, err = LoadCertPool("../testdata/cert/test.server.key")
+	require.NotNil(t, err)
+	require.Equal(t, "no certificates found in CA bundle '../testdata/cert/test.server.key'", err.Error())
+
+	_, err = LoadCertPool("../testdata/cert/unknown.crt")
+	require.NotNil(t, err)
+}